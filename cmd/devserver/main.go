@@ -0,0 +1,55 @@
+// Command devserver runs the same app as cmd/server with the development
+// routes always mounted, whatever APP_ENV says, and a short shutdown so
+// restarts during local work are quick.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/app"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+	slog.SetDefault(logger.Init())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("invalid configuration",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	a, err := app.New(ctx, cfg, app.WithDevRoutes(true))
+	if err != nil {
+		slog.Error("failed to initialize dev server",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	if err := a.Run(ctx, ":"+port, 5*time.Second); err != nil {
+		slog.Error("dev server exited with error",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiError is a failed admin API request, carrying the server's message.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	switch e.Status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Sprintf("server returned %d: %s (check GZLN_ADMIN_TOKEN)", e.Status, e.Message)
+	case http.StatusNotFound:
+		if e.Message == "" || strings.HasPrefix(e.Message, "404") {
+			return "server returned 404: is ADMIN_API_TOKEN set on the server?"
+		}
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+}
+
+// adminClient calls the admin API of one server with its token.
+type adminClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAdminClient(server, token string) *adminClient {
+	return &adminClient{
+		baseURL: strings.TrimRight(server, "/") + "/api/v1/admin",
+		token:   token,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// call sends in as the JSON body, when not nil, and returns the data of the
+// response undecoded so it can be printed as is or decoded into a type.
+func (c *adminClient) call(ctx context.Context, method, path string, query url.Values, in any) (json.RawMessage, error) {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+	var envelope struct {
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	decodeErr := json.Unmarshal(raw, &envelope)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := envelope.Message
+		if decodeErr != nil || message == "" {
+			message = strings.TrimSpace(string(raw))
+		}
+		return nil, &apiError{Status: resp.StatusCode, Message: message}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("invalid response: %w", decodeErr)
+	}
+	return envelope.Data, nil
+}
+
+// callInto calls the API and also decodes the response data into out.
+func (c *adminClient) callInto(ctx context.Context, method, path string, query url.Values, in, out any) (json.RawMessage, error) {
+	data, err := c.call(ctx, method, path, query, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return data, nil
+}
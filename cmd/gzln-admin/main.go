@@ -0,0 +1,724 @@
+// Command gzln-admin runs operator tasks against the admin API of a gzln
+// server. The token comes from GZLN_ADMIN_TOKEN so it stays out of the shell
+// history and process list.
+//
+//	gzln-admin files [-status STATUS] [-uploader-ip IP] [-limit N]
+//	gzln-admin expire [-yes] SHARE_ID...
+//	gzln-admin notes [-set TEXT] SHARE_ID
+//	gzln-admin watermark [-clear] SHARE_ID [TEXT]
+//	gzln-admin rate [-clear] SHARE_ID [BYTES_PER_SECOND]
+//	gzln-admin preview SHARE_ID
+//	gzln-admin stats
+//	gzln-admin transfers [-days N] [-limit N]
+//	gzln-admin cleanup [-yes]
+//	gzln-admin flags [NAME on|off]
+//	gzln-admin read-only [on|off]
+//	gzln-admin rate-limits [-limit N]
+//	gzln-admin blocklist [-reason TEXT] [add|remove NETWORK]
+//	gzln-admin jobs
+//	gzln-admin reports [-generate] [MONTH]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/middleware"
+)
+
+const usage = `usage:
+  gzln-admin files [flags]              list files, newest first
+  gzln-admin expire [flags] SHARE_ID... expire shares at once
+  gzln-admin notes [flags] SHARE_ID     show or replace the admin notes of a file
+  gzln-admin watermark [flags] SHARE_ID [TEXT] set the watermark of a server encrypted share
+  gzln-admin rate [flags] SHARE_ID [BYTES_PER_SECOND] override the download rate of a file
+  gzln-admin preview [flags] SHARE_ID   issue a support preview token for a share
+  gzln-admin stats [flags]              file counts and bytes per status
+  gzln-admin transfers [flags]          bytes served per day and the files served most
+  gzln-admin cleanup [flags]            run cleanup now
+  gzln-admin flags [flags] [NAME on|off] list or toggle feature flags
+  gzln-admin read-only [flags] [on|off] show or switch read-only mode of one instance
+  gzln-admin rate-limits [flags]        rate limit rejections and exemptions since the last report
+  gzln-admin blocklist [flags] [add|remove NETWORK] list, block or unblock networks
+  gzln-admin jobs [flags]               scheduled jobs and background verifications
+  gzln-admin reports [flags] [MONTH]    list retention reports, or show one (YYYY-MM)
+
+Every command takes -server (default GZLN_SERVER) and -json. The admin token
+is read from GZLN_ADMIN_TOKEN. Run gzln-admin <command> -h for the flags of
+a command.
+`
+
+var errAborted = errors.New("aborted")
+
+// command is what every subcommand shares: the API client, the output mode
+// and where confirmations are read from.
+type command struct {
+	fs     *flag.FlagSet
+	server *string
+	json   *bool
+	api    *adminClient
+	out    io.Writer
+	in     io.Reader
+}
+
+func newCommand(name string) *command {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return &command{
+		fs:     fs,
+		server: fs.String("server", defaultServer(), "API server URL (default from GZLN_SERVER)"),
+		json:   fs.Bool("json", false, "print the API response as JSON instead of a table"),
+		out:    os.Stdout,
+		in:     os.Stdin,
+	}
+}
+
+// parse parses the flags and sets up the client.
+func (c *command) parse(args []string) error {
+	c.fs.Parse(args)
+	token := os.Getenv("GZLN_ADMIN_TOKEN")
+	if token == "" {
+		return errors.New("GZLN_ADMIN_TOKEN is not set")
+	}
+	c.api = newAdminClient(*c.server, token)
+	return nil
+}
+
+// print writes data as indented JSON with -json, and with table otherwise.
+func (c *command) print(data json.RawMessage, table func(w io.Writer)) error {
+	if *c.json {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", "  "); err != nil {
+			return err
+		}
+		indented.WriteByte('\n')
+		_, err := indented.WriteTo(c.out)
+		return err
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// confirm asks before a destructive operation unless yes is set. Without a
+// terminal to ask on, -yes is required.
+func (c *command) confirm(yes bool, prompt string) error {
+	if yes {
+		return nil
+	}
+	if f, ok := c.in.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return errors.New("not a terminal, pass -yes to confirm")
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(c.in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func(context.Context, []string) error{
+		"files":       listFiles,
+		"expire":      expire,
+		"notes":       notes,
+		"watermark":   setWatermark,
+		"rate":        setDownloadRate,
+		"preview":     previewToken,
+		"stats":       stats,
+		"transfers":   transfers,
+		"cleanup":     cleanup,
+		"flags":       featureFlags,
+		"read-only":   readOnly,
+		"rate-limits": rateLimits,
+		"blocklist":   blocklist,
+		"jobs":        jobs,
+		"reports":     retentionReports,
+	}
+	run, ok := commands[os.Args[1]]
+	switch {
+	case os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "--help" || os.Args[1] == "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	case !ok:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "gzln-admin:", err)
+		os.Exit(1)
+	}
+}
+
+func defaultServer() string {
+	if server := os.Getenv("GZLN_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:8080"
+}
+
+func listFiles(ctx context.Context, args []string) error {
+	c := newCommand("files")
+	status := c.fs.String("status", "", "only files in this status")
+	uploaderIP := c.fs.String("uploader-ip", "", "only files uploaded from this IP")
+	minSize := c.fs.Int64("min-size", 0, "only files of at least this many bytes")
+	maxSize := c.fs.Int64("max-size", 0, "only files of at most this many bytes")
+	limit := c.fs.Int("limit", 0, "files per page (default server setting)")
+	offset := c.fs.Int("offset", 0, "files to skip")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]string{"status": *status, "uploader_ip": *uploaderIP} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	for name, value := range map[string]int64{"min_size": *minSize, "max_size": *maxSize, "limit": int64(*limit), "offset": int64(*offset)} {
+		if value != 0 {
+			query.Set(name, strconv.FormatInt(value, 10))
+		}
+	}
+
+	var list types.AdminFileList
+	data, err := c.api.callInto(ctx, http.MethodGet, "/files", query, nil, &list)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintln(w, "SHARE ID\tSTATUS\tSIZE\tCHUNKS\tDOWNLOADS\tUPLOADER\tCREATED\tEXPIRES")
+		for _, f := range list.Files {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				f.ShareID, f.Status, formatBytes(f.TotalSize), f.ChunkCount,
+				formatDownloads(f.DownloadCount, f.MaxDownloads), f.UploaderIP, f.CreatedAt, f.ExpiresAt)
+		}
+		if len(list.Files) == list.Limit {
+			fmt.Fprintf(w, "\nmore files may follow, pass -offset %d\n", list.Offset+list.Limit)
+		}
+	})
+}
+
+func expire(ctx context.Context, args []string) error {
+	c := newCommand("expire")
+	yes := c.fs.Bool("yes", false, "do not ask for confirmation")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if c.fs.NArg() == 0 {
+		return errors.New("expire takes at least one SHARE_ID")
+	}
+	shareIDs := c.fs.Args()
+
+	prompt := fmt.Sprintf("Expire %d share(s)? Their chunks are deleted on the next cleanup.", len(shareIDs))
+	if err := c.confirm(*yes, prompt); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, shareID := range shareIDs {
+		if _, err := c.api.call(ctx, http.MethodPost, "/files/"+url.PathEscape(shareID)+"/expire", nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", shareID, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(c.out, "expired %s\n", shareID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d shares not expired", failed, len(shareIDs))
+	}
+	return nil
+}
+
+func notes(ctx context.Context, args []string) error {
+	c := newCommand("notes")
+	set := c.fs.String("set", "", "replace the notes with this text")
+	clearNotes := c.fs.Bool("clear", false, "remove the notes")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if c.fs.NArg() != 1 {
+		return errors.New("notes takes exactly one SHARE_ID")
+	}
+	path := "/files/" + url.PathEscape(c.fs.Arg(0)) + "/notes"
+
+	if *set != "" || *clearNotes {
+		if _, err := c.api.call(ctx, http.MethodPut, path, nil, types.AdminNotesRequest{Notes: *set}); err != nil {
+			return err
+		}
+	}
+
+	var resp types.AdminNotesResponse
+	data, err := c.api.callInto(ctx, http.MethodGet, path, nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintf(w, "notes:\t%s\n\n", resp.Notes)
+		fmt.Fprintln(w, "WHEN\tACTOR\tACTION\tDETAILS")
+		for _, entry := range resp.History {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.CreatedAt, entry.Actor, entry.Action, entry.Details)
+		}
+	})
+}
+
+func setWatermark(ctx context.Context, args []string) error {
+	c := newCommand("watermark")
+	clearMark := c.fs.Bool("clear", false, "remove the watermark")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case *clearMark && c.fs.NArg() == 1:
+	case !*clearMark && c.fs.NArg() == 2:
+		text = c.fs.Arg(1)
+	default:
+		return errors.New("watermark takes SHARE_ID TEXT, or -clear SHARE_ID")
+	}
+	shareID := c.fs.Arg(0)
+
+	data, err := c.api.call(ctx, http.MethodPut, "/files/"+url.PathEscape(shareID)+"/watermark", nil,
+		types.AdminWatermarkRequest{Text: text})
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		if text == "" {
+			fmt.Fprintf(w, "%s\twatermark removed\n", shareID)
+			return
+		}
+		fmt.Fprintf(w, "%s\twatermarked with %q\n", shareID, text)
+	})
+}
+
+func setDownloadRate(ctx context.Context, args []string) error {
+	c := newCommand("rate")
+	clearRate := c.fs.Bool("clear", false, "follow DOWNLOAD_RATE_LIMIT again")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	var rate *int64
+	switch {
+	case *clearRate && c.fs.NArg() == 1:
+	case !*clearRate && c.fs.NArg() == 2:
+		n, err := strconv.ParseInt(c.fs.Arg(1), 10, 64)
+		if err != nil {
+			return fmt.Errorf("rate must be a number of bytes per second, got %q", c.fs.Arg(1))
+		}
+		rate = &n
+	default:
+		return errors.New("rate takes SHARE_ID BYTES_PER_SECOND, or -clear SHARE_ID")
+	}
+	shareID := c.fs.Arg(0)
+
+	data, err := c.api.call(ctx, http.MethodPut, "/files/"+url.PathEscape(shareID)+"/download-rate", nil,
+		types.AdminDownloadRateRequest{BytesPerSecond: rate})
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		switch {
+		case rate == nil:
+			fmt.Fprintf(w, "%s\tfollows the global download rate\n", shareID)
+		case *rate == 0:
+			fmt.Fprintf(w, "%s\tdownloads unthrottled\n", shareID)
+		default:
+			fmt.Fprintf(w, "%s\tdownloads limited to %s/s\n", shareID, formatBytes(*rate))
+		}
+	})
+}
+
+func previewToken(ctx context.Context, args []string) error {
+	c := newCommand("preview")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if c.fs.NArg() != 1 {
+		return errors.New("preview takes exactly one SHARE_ID")
+	}
+
+	var token types.SupportPreviewToken
+	data, err := c.api.callInto(ctx, http.MethodPost, "/files/"+url.PathEscape(c.fs.Arg(0))+"/preview-token", nil, nil, &token)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintf(w, "token:\t%s\n", token.Token)
+		fmt.Fprintf(w, "expires:\t%s\n", token.ExpiresAt)
+		fmt.Fprintf(w, "url:\t%s/api/v1/support/files/%s\n", strings.TrimRight(*c.server, "/"), url.PathEscape(token.ShareID))
+	})
+}
+
+func stats(ctx context.Context, args []string) error {
+	c := newCommand("stats")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	var totals types.StorageTotals
+	data, err := c.api.callInto(ctx, http.MethodGet, "/storage", nil, nil, &totals)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintln(w, "STATUS\tFILES\tSIZE")
+		for _, total := range totals.ByStatus {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", total.Status, total.Files, formatBytes(total.Bytes))
+		}
+		fmt.Fprintf(w, "total\t%d\t%s\n", totals.Files, formatBytes(totals.Bytes))
+		fmt.Fprintf(w, "\nstored:\t%s\n", formatBytes(totals.StoredBytes))
+	})
+}
+
+func transfers(ctx context.Context, args []string) error {
+	c := newCommand("transfers")
+	days := c.fs.Int("days", 0, "days to sum, today included (default server setting)")
+	limit := c.fs.Int("limit", 0, "files to list (default server setting)")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]int{"days": *days, "limit": *limit} {
+		if value != 0 {
+			query.Set(name, strconv.Itoa(value))
+		}
+	}
+
+	var totals types.TransferTotals
+	data, err := c.api.callInto(ctx, http.MethodGet, "/transfers", query, nil, &totals)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintln(w, "DAY\tSERVED")
+		for _, day := range totals.ByDay {
+			fmt.Fprintf(w, "%s\t%s\n", day.Day, formatBytes(day.Bytes))
+		}
+		fmt.Fprintf(w, "total\t%s\n", formatBytes(totals.Bytes))
+		fmt.Fprintln(w, "\nSHARE ID\tSTATUS\tSIZE\tSERVED")
+		for _, file := range totals.TopFiles {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", file.ShareID, file.Status, formatBytes(file.TotalSize), formatBytes(file.Bytes))
+		}
+	})
+}
+
+func cleanup(ctx context.Context, args []string) error {
+	c := newCommand("cleanup")
+	yes := c.fs.Bool("yes", false, "do not ask for confirmation")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if err := c.confirm(*yes, "Expire due files and delete their chunks now?"); err != nil {
+		return err
+	}
+
+	var resp types.AdminCleanupResponse
+	data, err := c.api.callInto(ctx, http.MethodPost, "/cleanup", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintf(w, "expired %d file(s)\n", resp.Expired)
+	})
+}
+
+func featureFlags(ctx context.Context, args []string) error {
+	c := newCommand("flags")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	table := func(all []flags.Flag) func(w io.Writer) {
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "FLAG\tENABLED\tDEFAULT\tOVERRIDDEN")
+			for _, f := range all {
+				fmt.Fprintf(w, "%s\t%t\t%t\t%t\n", f.Name, f.Enabled, f.Default, f.Overridden)
+			}
+		}
+	}
+
+	switch c.fs.NArg() {
+	case 0:
+		var all []flags.Flag
+		data, err := c.api.callInto(ctx, http.MethodGet, "/flags", nil, nil, &all)
+		if err != nil {
+			return err
+		}
+		return c.print(data, table(all))
+	case 2:
+		var enabled bool
+		switch c.fs.Arg(1) {
+		case "on":
+			enabled = true
+		case "off":
+		default:
+			return fmt.Errorf("flag state must be on or off, got %q", c.fs.Arg(1))
+		}
+		var f flags.Flag
+		data, err := c.api.callInto(ctx, http.MethodPut, "/flags/"+url.PathEscape(c.fs.Arg(0)), nil,
+			types.AdminFeatureFlagRequest{Enabled: &enabled}, &f)
+		if err != nil {
+			return err
+		}
+		return c.print(data, table([]flags.Flag{f}))
+	default:
+		return errors.New("flags takes no arguments to list, or NAME on|off to toggle")
+	}
+}
+
+func readOnly(ctx context.Context, args []string) error {
+	c := newCommand("read-only")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	var resp types.AdminReadOnlyResponse
+	var data []byte
+	var err error
+	switch c.fs.NArg() {
+	case 0:
+		data, err = c.api.callInto(ctx, http.MethodGet, "/read-only", nil, nil, &resp)
+	case 1:
+		var enabled bool
+		switch c.fs.Arg(0) {
+		case "on":
+			enabled = true
+		case "off":
+		default:
+			return fmt.Errorf("read-only state must be on or off, got %q", c.fs.Arg(0))
+		}
+		data, err = c.api.callInto(ctx, http.MethodPut, "/read-only", nil,
+			types.AdminReadOnlyRequest{Enabled: &enabled}, &resp)
+	default:
+		return errors.New("read-only takes no arguments to show, or on|off to switch")
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintf(w, "read-only: %t\n", resp.Enabled)
+	})
+}
+
+func rateLimits(ctx context.Context, args []string) error {
+	c := newCommand("rate-limits")
+	limit := c.fs.Int("limit", 0, "IPs to list (default server setting)")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *limit != 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+
+	var resp types.AdminRateLimits
+	data, err := c.api.callInto(ctx, http.MethodGet, "/rate-limits", query, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		for i, section := range []struct {
+			name  string
+			stats middleware.RateLimitStats
+		}{{"REJECTED", resp.Rejected}, {"EXEMPTED", resp.Exempted}} {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			limiters := make([]string, 0, len(section.stats.ByLimiter))
+			for name := range section.stats.ByLimiter {
+				limiters = append(limiters, name)
+			}
+			sort.Strings(limiters)
+
+			fmt.Fprintf(w, "LIMITER\t%s\n", section.name)
+			for _, name := range limiters {
+				fmt.Fprintf(w, "%s\t%d\n", name, section.stats.ByLimiter[name])
+			}
+			fmt.Fprintf(w, "\nIP\t%s\n", section.name)
+			for _, ip := range section.stats.TopIPs {
+				fmt.Fprintf(w, "%s\t%d\n", ip.IP, ip.Count)
+			}
+		}
+	})
+}
+
+func blocklist(ctx context.Context, args []string) error {
+	c := newCommand("blocklist")
+	reason := c.fs.String("reason", "", "why the network is blocked, with add")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	table := func(networks []types.BlockedNetwork) func(w io.Writer) {
+		return func(w io.Writer) {
+			fmt.Fprintln(w, "NETWORK\tBLOCKED\tREASON")
+			for _, n := range networks {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", n.Network, n.CreatedAt, n.Reason)
+			}
+		}
+	}
+
+	switch {
+	case c.fs.NArg() == 0:
+		var networks []types.BlockedNetwork
+		data, err := c.api.callInto(ctx, http.MethodGet, "/blocklist", nil, nil, &networks)
+		if err != nil {
+			return err
+		}
+		return c.print(data, table(networks))
+	case c.fs.NArg() == 2 && c.fs.Arg(0) == "add":
+		var blocked types.BlockedNetwork
+		data, err := c.api.callInto(ctx, http.MethodPost, "/blocklist", nil,
+			types.AdminBlockRequest{Network: c.fs.Arg(1), Reason: *reason}, &blocked)
+		if err != nil {
+			return err
+		}
+		return c.print(data, table([]types.BlockedNetwork{blocked}))
+	case c.fs.NArg() == 2 && c.fs.Arg(0) == "remove":
+		data, err := c.api.call(ctx, http.MethodDelete, "/blocklist", url.Values{"network": {c.fs.Arg(1)}}, nil)
+		if err != nil {
+			return err
+		}
+		return c.print(data, func(w io.Writer) {
+			fmt.Fprintf(w, "unblocked %s\n", c.fs.Arg(1))
+		})
+	default:
+		return errors.New("blocklist takes no arguments to list, or add|remove NETWORK")
+	}
+}
+
+func jobs(ctx context.Context, args []string) error {
+	c := newCommand("jobs")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	var resp types.AdminJobs
+	data, err := c.api.callInto(ctx, http.MethodGet, "/jobs", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	return c.print(data, func(w io.Writer) {
+		fmt.Fprintln(w, "JOB\tEVERY\tRUNNING\tRUNS\tFAILURES\tLAST FINISHED\tLAST ERROR")
+		for _, j := range resp.Jobs {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%d\t%s\t%s\n", j.Name, time.Duration(j.IntervalSeconds)*time.Second,
+				j.Running, j.Runs, j.Failures, orDash(j.LastFinished), orDash(j.LastError))
+		}
+		fmt.Fprintf(w, "\nverifying here:\t%d\n", resp.Verification.Running)
+		fmt.Fprintf(w, "verifying files:\t%d\n", resp.Verification.Files)
+	})
+}
+
+func retentionReports(ctx context.Context, args []string) error {
+	c := newCommand("reports")
+	generate := c.fs.Bool("generate", false, "build the report of MONTH now, replacing the stored one")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	switch c.fs.NArg() {
+	case 0:
+		if *generate {
+			return errors.New("-generate needs a MONTH")
+		}
+		var reports []types.RetentionReportSummary
+		data, err := c.api.callInto(ctx, http.MethodGet, "/reports/retention", nil, nil, &reports)
+		if err != nil {
+			return err
+		}
+		return c.print(data, func(w io.Writer) {
+			fmt.Fprintln(w, "MONTH	GENERATED")
+			for _, r := range reports {
+				fmt.Fprintf(w, "%s\t%s\n", r.Month, r.GeneratedAt)
+			}
+		})
+	case 1:
+		method := http.MethodGet
+		if *generate {
+			method = http.MethodPost
+		}
+		var report types.RetentionReport
+		data, err := c.api.callInto(ctx, method, "/reports/retention/"+url.PathEscape(c.fs.Arg(0)), nil, nil, &report)
+		if err != nil {
+			return err
+		}
+		return c.print(data, func(w io.Writer) {
+			fmt.Fprintf(w, "month:\t%s\n", report.Month)
+			fmt.Fprintf(w, "generated:\t%s\n", report.GeneratedAt)
+			fmt.Fprintf(w, "created:\t%d\t%s\n", report.FilesCreated, formatBytes(report.BytesCreated))
+			fmt.Fprintf(w, "expired:\t%d\n", report.FilesExpired)
+			fmt.Fprintf(w, "purged:\t%d\t%s\n", report.FilesPurged, formatBytes(report.BytesPurged))
+			fmt.Fprintf(w, "average lifetime:\t%s\n", time.Duration(report.AverageLifetimeSeconds)*time.Second)
+			if o := report.OldestRetained; o != nil {
+				fmt.Fprintf(w, "oldest retained:\t%s\t%s\n", o.ShareID, o.CreatedAt)
+			} else {
+				fmt.Fprintln(w, "oldest retained:\t-")
+			}
+		})
+	default:
+		return errors.New("reports takes no arguments to list, or a MONTH (YYYY-MM) to show")
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDownloads shows downloads against the limit, where -1 is unlimited.
+func formatDownloads(count, limit int32) string {
+	if limit < 0 {
+		return fmt.Sprintf("%d/-", count)
+	}
+	return fmt.Sprintf("%d/%d", count, limit)
+}
+
+// orDash shows an empty value as "-" so table columns stay aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+// Command gzln-cli uploads and downloads files from the command line with
+// the same client-side encryption as the web frontend, for scripts and
+// server-to-server transfers.
+//
+//	gzln-cli upload [-server URL] [-expires HOURS] [-max-downloads N] FILE
+//	gzln-cli download [-server URL] [-o PATH] LINK
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ilkin0/gzln/pkg/client"
+)
+
+const usage = `usage:
+  gzln-cli upload [flags] FILE     encrypt and upload FILE, print its share link
+  gzln-cli download [flags] LINK   download and decrypt a share link
+
+Run gzln-cli <command> -h for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "upload":
+		err = upload(ctx, os.Args[2:])
+	case "download":
+		err = download(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gzln-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func defaultServer() string {
+	if server := os.Getenv("GZLN_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:8080"
+}
+
+func upload(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	server := fs.String("server", defaultServer(), "API server URL (default from GZLN_SERVER)")
+	linkBase := fs.String("link-base", "", "frontend URL share links point to (default -server)")
+	expires := fs.Int("expires", 0, "hours until the share expires (default server setting)")
+	maxDownloads := fs.Int("max-downloads", 0, "downloads allowed, -1 for unlimited (default server setting)")
+	concurrency := fs.Int("concurrency", 0, "chunks uploaded at once (default server hint)")
+	name := fs.String("name", "", "file name recipients see (default the file's base name)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("upload takes exactly one FILE")
+	}
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if *name == "" {
+		*name = filepath.Base(path)
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(*name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	c := client.New(*server).WithConcurrency(*concurrency)
+	result, err := c.Upload(ctx, f, info.Size(), *name, mimeType, client.UploadOptions{
+		ExpiresInHours: *expires,
+		MaxDownloads:   int32(*maxDownloads),
+	})
+	if err != nil {
+		return err
+	}
+
+	if *linkBase == "" {
+		*linkBase = *server
+	}
+	// The link goes to stdout on its own so scripts can capture it
+	fmt.Println(result.ShareURL(*linkBase))
+	fmt.Fprintf(os.Stderr, "deletion token: %s\n", result.DeletionToken)
+	if result.ExpiresAt != "" {
+		fmt.Fprintf(os.Stderr, "expires at:     %s\n", result.ExpiresAt)
+	}
+	return nil
+}
+
+func download(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	server := fs.String("server", "", "API server URL (default the link's host)")
+	output := fs.String("o", "", "output path (default the shared file name in the current directory)")
+	concurrency := fs.Int("concurrency", 0, "chunks downloaded at once (default server hint)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("download takes exactly one LINK")
+	}
+	linkBase, shareID, password, err := client.ParseShareURL(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *server == "" {
+		*server = linkBase
+	}
+
+	c := client.New(*server).WithConcurrency(*concurrency)
+	// Only an explicit -o may overwrite an existing file
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *output == "" {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		meta, err := c.Metadata(ctx, shareID, password)
+		if err != nil {
+			return err
+		}
+		// The name comes from the uploader, so never let it pick a directory
+		*output = filepath.Base(meta.Name)
+		if *output == "." || *output == ".." || *output == string(filepath.Separator) {
+			return errors.New("shared file name is not usable, pass -o")
+		}
+	}
+
+	f, err := os.OpenFile(*output, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	result, err := c.Download(ctx, shareID, password, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "saved %s (%d bytes, %s)\n", *output, result.Size, result.MimeType)
+	return nil
+}
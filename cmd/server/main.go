@@ -1,108 +1,106 @@
+// Command server runs the gzln API server.
+//
+//	server [-check] [-migrations DIR]
+//
+// With -check it only validates the configuration, reaches the database and
+// object storage, looks for pending migrations and prints the outcome,
+// exiting non-zero if anything failed. Migrations are compared with those
+// built into the binary unless -migrations names a directory.
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
-	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/ilkin0/gzln/internal/api/routes"
-	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/db"
+	"github.com/ilkin0/gzln/internal/app"
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/logger"
-	custommiddleware "github.com/ilkin0/gzln/internal/middleware"
-	"github.com/ilkin0/gzln/internal/scheduler"
-	"github.com/ilkin0/gzln/internal/service"
-	"github.com/ilkin0/gzln/internal/storage"
 	"github.com/joho/godotenv"
 )
 
+const version = "1.0.1"
+
 func main() {
+	check := flag.Bool("check", false, "check the configuration and dependencies, then exit")
+	migrationsDir := flag.String("migrations", "", "directory of the migrations -check compares with the database (default the embedded ones)")
+	flag.Parse()
+
 	_ = godotenv.Load()
 	slog.SetDefault(logger.Init())
 
-	ctx := context.Background()
-
-	slog.Info("starting gzln file sharing service",
-		slog.String("version", "1.0.1"),
-	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Restore default signal handling so a second signal forces exit
+	context.AfterFunc(ctx, stop)
+
+	if *check {
+		migrations := db.Migrations()
+		if *migrationsDir != "" {
+			migrations = os.DirFS(*migrationsDir)
+		}
+		results := app.Check(ctx, migrations)
+		printCheckResults(os.Stdout, results)
+		if results.Failed() {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Initialize Database
-	db, err := database.NewDatabase(ctx)
+	cfg, err := config.Load()
 	if err != nil {
-		slog.Error("failed to initialize database",
+		slog.Error("invalid configuration",
 			slog.String("error", err.Error()),
 		)
 		os.Exit(1)
 	}
-	runTx := database.NewTxRunner(db.Pool)
-	defer db.Pool.Close()
-
-	slog.Info("database initialized successfully")
 
-	// Initialize MinIO client
-	minioClient, err := storage.NewMinIOClient()
+	a, err := app.New(ctx, cfg)
 	if err != nil {
-		slog.Error("failed to initialize MinIO",
+		slog.Error("failed to initialize server",
 			slog.String("error", err.Error()),
 		)
 		os.Exit(1)
 	}
 
-	slog.Info("minio client initialized successfully",
-		slog.String("bucket", minioClient.BucketName),
-	)
-
-	// Initialize services
-	fileService := service.NewFileService(db.Queries, runTx, minioClient.Client)
-	chunkService := service.NewChunkService(db.Queries, minioClient.Client, minioClient.BucketName)
-
-	cleanupService := service.NewCleanupService(db.Queries, minioClient.Client, minioClient.BucketName)
-
-	// Start scheduler
-	sched := scheduler.New(cleanupService, 5*time.Minute)
-	sched.Start(ctx)
-
-	// Setup router
-	r := chi.NewRouter()
-
-	// CORS middleware
-	r.Use(custommiddleware.CORS)
-
-	// Standard middleware
-	r.Use(logger.RequestLogger)
-	r.Use(logger.RequestID)
-	r.Use(middleware.Recoverer)
-
-	// Health check endpoint
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
-	})
-
-	// Mount routes
-	r.Mount("/api/v1/files", routes.FileRoutes(fileService, chunkService, minioClient.BucketName))
-	r.Mount("/api/v1/download", routes.DownloadRoutes(fileService, chunkService, minioClient.BucketName))
-
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	slog.Info("server starting",
-		slog.String("port", port),
-		slog.String("address", fmt.Sprintf("http://localhost:%s", port)),
-	)
+	a.LogBanner(version, ":"+port)
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		slog.Error("server failed",
+	if err := a.Run(ctx, ":"+port, loadShutdownTimeout()); err != nil {
+		slog.Error("server exited with error",
 			slog.String("error", err.Error()),
 			slog.String("port", port),
 		)
 		os.Exit(1)
 	}
 }
+
+func loadShutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func printCheckResults(out io.Writer, results app.CheckResults) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Status, r.Detail)
+	}
+	tw.Flush()
+}
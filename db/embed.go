@@ -0,0 +1,18 @@
+// Package db embeds the SQL migrations, so the server can apply them
+// without the goose CLI or the source tree.
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migration/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the goose migrations of db/migration.
+func Migrations() fs.FS {
+	// The path is valid, so Sub cannot fail
+	sub, _ := fs.Sub(migrationFiles, "migration")
+	return sub
+}
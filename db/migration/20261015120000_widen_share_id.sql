@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files ALTER COLUMN share_id TYPE VARCHAR(32);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files ALTER COLUMN share_id TYPE VARCHAR(12);
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files ADD COLUMN admin_notes TEXT;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    file_id UUID REFERENCES files (id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(64) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_log_file_id ON audit_log (file_id, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+ALTER TABLE files DROP COLUMN IF EXISTS admin_notes;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS file_keys (
+    file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    algorithm TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_file_keys_key_id ON file_keys (key_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS file_keys;
+-- +goose StatementEnd
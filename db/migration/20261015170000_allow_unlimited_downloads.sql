@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files DROP CONSTRAINT IF EXISTS chk_max_downloads;
+ALTER TABLE files ADD CONSTRAINT chk_max_downloads CHECK (max_downloads > 0 OR max_downloads = -1);
+
+-- The server's DEFAULT_MAX_DOWNLOADS is the only default
+ALTER TABLE files ALTER COLUMN max_downloads DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE files SET max_downloads = 2147483647 WHERE max_downloads = -1;
+
+ALTER TABLE files ALTER COLUMN max_downloads SET DEFAULT 10;
+ALTER TABLE files DROP CONSTRAINT IF EXISTS chk_max_downloads;
+ALTER TABLE files ADD CONSTRAINT chk_max_downloads CHECK (max_downloads > 0);
+-- +goose StatementEnd
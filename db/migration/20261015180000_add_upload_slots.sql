@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS upload_slots (
+    token_hash TEXT PRIMARY KEY,
+    max_size BIGINT NOT NULL,
+    expires_in_hours INTEGER NOT NULL,
+    max_downloads INTEGER NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_upload_slots_max_size CHECK (max_size > 0)
+);
+
+CREATE INDEX idx_upload_slots_expires_at ON upload_slots (expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS upload_slots;
+-- +goose StatementEnd
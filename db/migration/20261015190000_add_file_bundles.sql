@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS file_bundles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id VARCHAR(32) NOT NULL UNIQUE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS bundle_files (
+    file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+    bundle_id UUID NOT NULL REFERENCES file_bundles (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_bundle_files_bundle_id ON bundle_files (bundle_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS bundle_files;
+DROP TABLE IF EXISTS file_bundles;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS pastes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id VARCHAR(32) NOT NULL UNIQUE,
+    ciphertext TEXT NOT NULL,
+    salt TEXT NOT NULL,
+    pbkdf2_iterations INTEGER NOT NULL,
+    max_downloads INTEGER NOT NULL,
+    download_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_pastes_max_downloads CHECK (max_downloads > 0 OR max_downloads = -1)
+);
+
+CREATE INDEX idx_pastes_expires_at ON pastes (expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pastes;
+-- +goose StatementEnd
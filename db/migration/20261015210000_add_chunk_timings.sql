@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- How long a chunk took to arrive from the client and to be written to
+-- storage. NULL for chunks uploaded straight to storage.
+ALTER TABLE chunks
+    ADD COLUMN receive_ms INTEGER,
+    ADD COLUMN store_ms   INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chunks
+    DROP COLUMN IF EXISTS receive_ms,
+    DROP COLUMN IF EXISTS store_ms;
+-- +goose StatementEnd
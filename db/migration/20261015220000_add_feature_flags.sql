@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Flags toggled at runtime through the admin API. They override the defaults
+-- from FEATURE_FLAGS on every instance.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(64) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS feature_flags;
+-- +goose StatementEnd
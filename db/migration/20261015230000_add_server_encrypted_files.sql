@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Files uploaded in plaintext by clients without E2EE. Their chunks are
+-- sealed with the server key key_id before they reach storage.
+CREATE TABLE IF NOT EXISTS server_encrypted_files (
+    file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS server_encrypted_files;
+-- +goose StatementEnd
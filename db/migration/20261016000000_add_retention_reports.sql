@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When cleanup deleted a file's chunks. Files expired before this column
+-- existed are backfilled with their expiry, the closest time known.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS purged_at TIMESTAMPTZ;
+UPDATE files
+SET purged_at = LEAST(COALESCE(expires_at, created_at), now())
+WHERE status = 'expired'
+  AND purged_at IS NULL;
+
+-- Monthly retention reports kept for compliance reviews, one per calendar
+-- month in UTC.
+CREATE TABLE IF NOT EXISTS retention_reports (
+    id BIGSERIAL PRIMARY KEY,
+    period_start TIMESTAMPTZ NOT NULL UNIQUE,
+    period_end TIMESTAMPTZ NOT NULL,
+    report JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS retention_reports;
+ALTER TABLE files DROP COLUMN IF EXISTS purged_at;
+-- +goose StatementEnd
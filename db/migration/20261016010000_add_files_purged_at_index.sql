@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Cleanup deletes expired files once they were purged long enough ago.
+CREATE INDEX IF NOT EXISTS idx_files_purged_at ON files (purged_at)
+    WHERE status = 'expired';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_files_purged_at;
+-- +goose StatementEnd
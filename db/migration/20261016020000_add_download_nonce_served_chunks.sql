@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Indexes of the chunks served whole to the download a nonce was issued
+-- for. The download counts once every chunk of the file was served.
+ALTER TABLE download_nonces
+    ADD COLUMN served_chunks INTEGER[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE download_nonces
+    DROP COLUMN IF EXISTS served_chunks;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Text the watermark transformer marks downloads of a server encrypted file
+-- with, usually naming its recipient. Set through the admin API.
+ALTER TABLE server_encrypted_files ADD COLUMN IF NOT EXISTS watermark TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE server_encrypted_files DROP COLUMN IF EXISTS watermark;
+-- +goose StatementEnd
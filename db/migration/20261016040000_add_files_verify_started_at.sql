@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When background verification of a finalized upload began. Files left
+-- verifying by a restarted server are moved back to uploading once it is old.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS verify_started_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN IF EXISTS verify_started_at;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Links an uploader hands to single recipients in place of the share ID.
+-- Each has its own expiry and download limit and can be revoked alone.
+CREATE TABLE IF NOT EXISTS share_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id VARCHAR(32) NOT NULL UNIQUE,
+    file_id UUID NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+    label TEXT,
+    max_downloads INTEGER NOT NULL,
+    download_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_share_links_max_downloads CHECK (max_downloads > 0)
+);
+
+CREATE INDEX idx_share_links_file_id ON share_links (file_id, created_at);
+
+-- The link a download was started through, counted with the download
+ALTER TABLE download_nonces
+    ADD COLUMN share_link_id UUID REFERENCES share_links (id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE download_nonces
+    DROP COLUMN IF EXISTS share_link_id;
+DROP TABLE IF EXISTS share_links;
+-- +goose StatementEnd
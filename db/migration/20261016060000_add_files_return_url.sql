@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Where the uploader wants a recipient sent once their download finished.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS return_url TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN IF EXISTS return_url;
+-- +goose StatementEnd
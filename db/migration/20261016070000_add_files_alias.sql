@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A name the uploader picked for the share, resolved by the download routes
+-- like its share ID. It is freed for reuse once the share expires.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS alias VARCHAR(32);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_alias ON files (alias)
+    WHERE alias IS NOT NULL AND status <> 'expired';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_files_alias;
+ALTER TABLE files DROP COLUMN IF EXISTS alias;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS upload_squatters (
+    ip INET PRIMARY KEY,
+    unfinished_uploads INTEGER NOT NULL,
+    flagged_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_upload_squatters_flagged_until ON upload_squatters (flagged_until);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS upload_squatters;
+-- +goose StatementEnd
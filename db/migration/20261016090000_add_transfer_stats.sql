@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS transfer_stats (
+    file_id UUID NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    bytes_served BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (file_id, day)
+);
+
+CREATE INDEX idx_transfer_stats_day ON transfer_stats (day);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS transfer_stats;
+-- +goose StatementEnd
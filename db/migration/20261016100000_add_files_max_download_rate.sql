@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Overrides DOWNLOAD_RATE_LIMIT for downloads of a file, in bytes per
+-- second; 0 leaves them unthrottled and NULL follows the global limit.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS max_download_rate BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN IF EXISTS max_download_rate;
+-- +goose StatementEnd
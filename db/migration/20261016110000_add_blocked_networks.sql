@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Networks blocked at runtime through the admin API. IP reputation checks
+-- deny them on every instance, on top of IP_REPUTATION_BLOCKLIST.
+CREATE TABLE IF NOT EXISTS blocked_networks (
+    network CIDR PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS blocked_networks;
+-- +goose StatementEnd
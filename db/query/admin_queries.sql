@@ -0,0 +1,33 @@
+-- name: ListAdminFiles :many
+SELECT id,
+       share_id,
+       status,
+       total_size,
+       chunk_count,
+       download_count,
+       max_downloads,
+       uploader_ip,
+       created_at,
+       expires_at
+FROM files
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND (sqlc.narg('min_size')::bigint IS NULL OR total_size >= sqlc.narg('min_size'))
+  AND (sqlc.narg('max_size')::bigint IS NULL OR total_size <= sqlc.narg('max_size'))
+  AND (sqlc.narg('uploader_ip')::inet IS NULL OR uploader_ip = sqlc.narg('uploader_ip'))
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetStorageTotals :many
+SELECT status,
+       COUNT(*)                           AS files,
+       COALESCE(SUM(total_size), 0)::bigint AS bytes
+FROM files
+GROUP BY status
+ORDER BY status;
+
+-- name: ForceExpireFile :one
+UPDATE files
+SET expires_at = LEAST(expires_at, now())
+WHERE share_id = $1
+  AND status != 'expired'
+RETURNING id;
@@ -0,0 +1,20 @@
+-- name: CreateAuditLogEntry :one
+INSERT INTO audit_log (file_id,
+                       action,
+                       actor,
+                       details)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListAuditLogByFileId :many
+SELECT *
+FROM audit_log
+WHERE file_id = $1
+ORDER BY created_at, id;
+
+-- name: ListAuditLogByFileIdsAndAction :many
+SELECT *
+FROM audit_log
+WHERE file_id = ANY ($1::uuid[])
+  AND action = $2
+ORDER BY created_at, id;
@@ -0,0 +1,16 @@
+-- name: ListBlockedNetworks :many
+SELECT *
+FROM blocked_networks
+ORDER BY network;
+
+-- name: UpsertBlockedNetwork :one
+INSERT INTO blocked_networks (network, reason)
+VALUES ($1, $2)
+ON CONFLICT (network) DO UPDATE
+    SET reason = EXCLUDED.reason
+RETURNING *;
+
+-- name: DeleteBlockedNetwork :execrows
+DELETE
+FROM blocked_networks
+WHERE network = $1;
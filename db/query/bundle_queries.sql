@@ -0,0 +1,41 @@
+-- name: CreateFileBundle :one
+INSERT INTO file_bundles (share_id,
+                          token_hash,
+                          expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetFileBundleByTokenHash :one
+SELECT *
+FROM file_bundles
+WHERE token_hash = $1
+  AND expires_at > now();
+
+-- name: GetFileBundleByShareId :one
+SELECT *
+FROM file_bundles
+WHERE share_id = $1
+  AND expires_at > now();
+
+-- name: AddBundleFile :exec
+INSERT INTO bundle_files (file_id,
+                          bundle_id)
+VALUES ($1, $2);
+
+-- name: CountBundleFiles :one
+SELECT COUNT(*)
+FROM bundle_files
+WHERE bundle_id = $1;
+
+-- name: ListReadyBundleFiles :many
+SELECT f.share_id,
+       f.encrypted_filename,
+       f.encrypted_mime_type,
+       f.total_size,
+       f.chunk_count
+FROM bundle_files b
+         JOIN files f ON f.id = b.file_id
+WHERE b.bundle_id = $1
+  AND f.status = 'ready'
+  AND f.expires_at > now()
+ORDER BY b.created_at, f.id;
@@ -11,13 +11,17 @@ INSERT INTO chunks (
     chunk_index,
     storage_path,
     encrypted_size,
-    chunk_hash
+    chunk_hash,
+    receive_ms,
+    store_ms
 ) VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7
 )
 RETURNING id;
 
@@ -34,12 +38,87 @@ SELECT
 FROM chunks
 WHERE file_id = $1;
 
+-- name: GetChunkHashByFileIdAndIndex :one
+SELECT chunk_hash
+FROM chunks
+WHERE file_id = $1 and chunk_index = $2;
+
 -- name: GetChunkByIndexAndFileShareID :one
 SELECT
     f.max_downloads,
     f.download_count,
-    c.storage_path
+    f.max_download_rate,
+    c.file_id,
+    c.storage_path,
+    c.encrypted_size,
+    c.chunk_hash,
+    sef.key_id AS server_key_id,
+    sef.watermark
 FROM chunks c
 JOIN files f on f.id = c.file_id
+LEFT JOIN server_encrypted_files sef on sef.file_id = c.file_id
 WHERE f.share_id = $1 and c.chunk_index = $2
-  AND f.status = 'ready' AND f.expires_at > NOW();
\ No newline at end of file
+  AND f.status = 'ready' AND f.expires_at > NOW();
+
+-- name: ListChunkIndexesByFileId :many
+SELECT chunk_index
+FROM chunks
+WHERE file_id = $1
+ORDER BY chunk_index;
+
+-- name: ListChunksByFileId :many
+SELECT *
+FROM chunks
+WHERE file_id = $1
+ORDER BY chunk_index;
+
+-- name: ListChunksOfUnpurgedFile :many
+SELECT c.chunk_index, c.uploaded_at
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE c.file_id = $1
+  AND f.purged_at IS NULL
+ORDER BY c.chunk_index;
+
+-- name: ListUnpurgedFileIdsWithChunks :many
+SELECT DISTINCT c.file_id
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE f.purged_at IS NULL;
+
+-- name: DeleteChunkOfUnpurgedFile :execrows
+DELETE FROM chunks c
+USING files f
+WHERE f.id = c.file_id
+  AND c.file_id = $1
+  AND c.chunk_index = $2
+  AND f.purged_at IS NULL;
+
+-- name: ListChunkManifestByShareId :many
+SELECT
+    f.chunk_count,
+    f.total_size,
+    f.max_downloads,
+    f.download_count,
+    c.chunk_index,
+    c.encrypted_size,
+    c.chunk_hash
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE f.share_id = $1
+  AND f.status = 'ready' AND f.expires_at > NOW()
+ORDER BY c.chunk_index;
+
+-- name: GetChunkThroughput :one
+SELECT COUNT(*)::int                            AS chunks,
+       COALESCE(SUM(encrypted_size), 0)::bigint AS bytes,
+       COALESCE(SUM(receive_ms), 0)::bigint     AS receive_ms,
+       COALESCE(SUM(store_ms), 0)::bigint       AS store_ms
+FROM (
+    SELECT encrypted_size, receive_ms, store_ms
+    FROM chunks
+    WHERE file_id = $1
+      AND receive_ms IS NOT NULL
+    ORDER BY uploaded_at DESC
+    LIMIT $2
+) recent;
@@ -0,0 +1,48 @@
+-- name: CreateDownloadNonce :execrows
+INSERT INTO download_nonces (nonce_hash,
+                             file_id,
+                             expires_at,
+                             share_link_id)
+SELECT $1, f.id, $3, $4
+FROM files f
+WHERE f.share_id = $2
+  AND f.status = 'ready';
+
+-- name: ConsumeDownloadNonce :execrows
+UPDATE download_nonces n
+SET used_at = now()
+FROM files f
+WHERE n.file_id = f.id
+  AND n.nonce_hash = $1
+  AND f.share_id = $2
+  AND n.used_at IS NULL
+  AND n.expires_at > now()
+  AND cardinality(n.served_chunks) >= f.chunk_count;
+
+-- name: GetDownloadNonce :one
+SELECT n.used_at,
+       cardinality(n.served_chunks)::int AS served_chunks,
+       f.chunk_count,
+       n.share_link_id
+FROM download_nonces n
+         JOIN files f ON f.id = n.file_id
+WHERE n.nonce_hash = $1
+  AND f.share_id = $2
+  AND n.expires_at > now();
+
+-- name: RecordDownloadNonceChunks :one
+UPDATE download_nonces n
+SET served_chunks = ARRAY(SELECT DISTINCT unnest(n.served_chunks || sqlc.arg('chunk_indexes')::int[]) ORDER BY 1)
+FROM files f
+WHERE n.file_id = f.id
+  AND n.nonce_hash = sqlc.arg('nonce_hash')
+  AND f.share_id = sqlc.arg('share_id')
+  AND n.used_at IS NULL
+  AND n.expires_at > now()
+  AND NOT (sqlc.arg('chunk_indexes')::int[] <@ n.served_chunks)
+RETURNING cardinality(n.served_chunks)::int AS served_chunks, f.chunk_count;
+
+-- name: DeleteExpiredDownloadNonces :execrows
+DELETE
+FROM download_nonces
+WHERE expires_at <= now();
@@ -0,0 +1,12 @@
+-- name: ListFeatureFlags :many
+SELECT *
+FROM feature_flags
+ORDER BY name;
+
+-- name: UpsertFeatureFlag :one
+INSERT INTO feature_flags (name, enabled)
+VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE
+    SET enabled    = EXCLUDED.enabled,
+        updated_at = now()
+RETURNING *;
@@ -0,0 +1,12 @@
+-- name: CreateFileKey :execrows
+INSERT INTO file_keys (file_id,
+                       key_id,
+                       wrapped_key,
+                       algorithm)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (file_id) DO NOTHING;
+
+-- name: GetFileKeyByFileId :one
+SELECT *
+FROM file_keys
+WHERE file_id = $1;
@@ -0,0 +1,19 @@
+-- name: CreateFileWebhook :execrows
+INSERT INTO file_webhooks (file_id,
+                           url,
+                           secret)
+VALUES ($1, $2, $3);
+
+-- name: GetFileWebhookByFileId :one
+SELECT *
+FROM file_webhooks
+WHERE file_id = $1;
+
+-- name: ListFileWebhooksByFileIds :many
+SELECT w.file_id,
+       w.url,
+       w.secret,
+       f.share_id
+FROM file_webhooks w
+         JOIN files f ON f.id = w.file_id
+WHERE w.file_id = ANY ($1::uuid[]);
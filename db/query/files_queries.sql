@@ -10,8 +10,10 @@ INSERT INTO files (share_id,
                    expires_at,
                    max_downloads,
                    deletion_token_hash,
-                   uploader_ip)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+                   uploader_ip,
+                   return_url,
+                   alias)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 RETURNING *;
 
 -- name: GetFileByID :one
@@ -30,11 +32,61 @@ SET status = $2
 WHERE id = $1
 RETURNING *;
 
+-- name: MarkFileReady :one
+UPDATE files
+SET status = 'ready'
+WHERE id = $1
+  AND status IN ('uploading', 'verifying')
+RETURNING *;
+
+-- name: StartFileVerification :one
+UPDATE files
+SET status            = 'verifying',
+    verify_started_at = now()
+WHERE id = $1
+  AND status = 'uploading'
+RETURNING *;
+
+-- name: ResetStaleVerifications :execrows
+UPDATE files
+SET status = 'uploading'
+WHERE status = 'verifying'
+  AND verify_started_at < sqlc.arg('started_before');
+
+-- name: MarkUnpurgedFileCorrupt :exec
+UPDATE files
+SET status = 'corrupt'
+WHERE id = $1
+  AND status NOT IN ('uploading', 'verifying')
+  AND purged_at IS NULL;
+
+-- name: RevokeFile :one
+UPDATE files
+SET status = 'revoked'
+WHERE id = $1
+  AND status = 'ready'
+RETURNING *;
+
 -- name: GetFileSaltByShareId :one
 SELECT salt
 FROM files
 WHERE share_id = $1;
 
+-- name: GetShareIDByAlias :one
+SELECT share_id
+FROM files
+WHERE alias = $1
+  AND status <> 'expired'
+  AND NOT EXISTS(SELECT 1
+                 FROM files f
+                 WHERE f.share_id = $1);
+
+-- name: ShareNameTaken :one
+SELECT EXISTS(SELECT 1
+              FROM files
+              WHERE share_id = $1
+                 OR (alias = $1 AND status <> 'expired'));
+
 -- name: GetFileMetadataByShareId :one
 SELECT encrypted_filename,
        encrypted_mime_type,
@@ -43,7 +95,13 @@ SELECT encrypted_filename,
        chunk_count,
        expires_at,
        max_downloads,
-       download_count
+       download_count,
+       created_at,
+       status,
+       return_url,
+       EXISTS(SELECT 1
+              FROM server_encrypted_files sef
+              WHERE sef.file_id = files.id) AS server_encrypted
 FROM files
 WHERE share_id = $1;
 
@@ -55,7 +113,7 @@ WITH updated AS (
             last_downloaded_at = now()
         WHERE share_id = $1
             AND status = 'ready'
-            AND (max_downloads = 0 OR download_count < max_downloads)
+            AND (max_downloads = -1 OR download_count < max_downloads)
             AND (expires_at IS NULL OR expires_at > now())
         RETURNING id, share_id, download_count, max_downloads, expires_at)
 SELECT u.id,
@@ -67,8 +125,14 @@ SELECT u.id,
 FROM updated u;
 
 
+-- name: CountActiveUploads :one
+SELECT COUNT(*)
+FROM files
+WHERE status = 'uploading'
+  AND created_at > now() - interval '1 hour';
+
 -- name: GetExpiredFiles :many
-SELECT id, chunk_count
+SELECT id, share_id, chunk_count
 FROM files
 WHERE status != 'expired'
   AND (
@@ -77,5 +141,34 @@ WHERE status != 'expired'
 
 -- name: ExpireFilesByIds :exec
 UPDATE files
-SET status = 'expired'
-WHERE id = ANY ($1::uuid[]);
\ No newline at end of file
+SET status    = 'expired',
+    purged_at = now()
+WHERE id = ANY ($1::uuid[]);
+
+-- name: DeletePurgedFiles :execrows
+DELETE
+FROM files
+WHERE id IN (SELECT id
+             FROM files
+             WHERE status = 'expired'
+               AND purged_at < sqlc.arg('purged_before')
+             ORDER BY purged_at
+             LIMIT sqlc.arg('batch_size'));
+
+-- name: UpdateFileAdminNotes :one
+UPDATE files
+SET admin_notes = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateFileMaxDownloadRate :execrows
+UPDATE files
+SET max_download_rate = $2
+WHERE id = $1
+  AND status != 'expired';
+
+-- name: ListFilesByDeletionTokens :many
+SELECT *
+FROM files
+WHERE deletion_token_hash = ANY ($1::text[])
+ORDER BY created_at, id;
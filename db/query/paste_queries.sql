@@ -0,0 +1,23 @@
+-- name: CreatePaste :one
+INSERT INTO pastes (share_id, ciphertext, salt, pbkdf2_iterations, max_downloads, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetPasteByShareId :one
+SELECT *
+FROM pastes
+WHERE share_id = $1;
+
+-- name: ReadPasteByShareId :one
+UPDATE pastes
+SET download_count = download_count + 1
+WHERE share_id = $1
+  AND expires_at > now()
+  AND (max_downloads = -1 OR download_count < max_downloads)
+RETURNING *;
+
+-- name: DeleteExpiredPastes :execrows
+DELETE
+FROM pastes
+WHERE expires_at <= now()
+   OR (max_downloads > 0 AND download_count >= max_downloads);
@@ -0,0 +1,36 @@
+-- name: GetRetentionStats :one
+SELECT COUNT(*) FILTER (WHERE created_at >= sqlc.arg('period_start') AND created_at < sqlc.arg('period_end'))                AS files_created,
+       COALESCE(SUM(total_size) FILTER (WHERE created_at >= sqlc.arg('period_start') AND created_at < sqlc.arg('period_end')), 0)::bigint AS bytes_created,
+       COUNT(*) FILTER (WHERE expires_at >= sqlc.arg('period_start') AND expires_at < sqlc.arg('period_end'))                AS files_expired,
+       COUNT(*) FILTER (WHERE purged_at >= sqlc.arg('period_start') AND purged_at < sqlc.arg('period_end'))                  AS files_purged,
+       COALESCE(SUM(total_size) FILTER (WHERE purged_at >= sqlc.arg('period_start') AND purged_at < sqlc.arg('period_end')), 0)::bigint AS bytes_purged,
+       COALESCE(AVG(EXTRACT(EPOCH FROM purged_at - created_at))
+                FILTER (WHERE purged_at >= sqlc.arg('period_start') AND purged_at < sqlc.arg('period_end')), 0)::float8 AS average_lifetime_seconds
+FROM files;
+
+-- name: GetOldestRetainedFile :one
+SELECT share_id, created_at
+FROM files
+WHERE created_at < sqlc.arg('period_end')
+  AND (purged_at IS NULL OR purged_at >= sqlc.arg('period_end'))
+ORDER BY created_at, id
+LIMIT 1;
+
+-- name: UpsertRetentionReport :one
+INSERT INTO retention_reports (period_start, period_end, report)
+VALUES ($1, $2, $3)
+ON CONFLICT (period_start) DO UPDATE
+    SET period_end = EXCLUDED.period_end,
+        report     = EXCLUDED.report,
+        created_at = now()
+RETURNING *;
+
+-- name: GetRetentionReport :one
+SELECT *
+FROM retention_reports
+WHERE period_start = $1;
+
+-- name: ListRetentionReports :many
+SELECT period_start, period_end, created_at
+FROM retention_reports
+ORDER BY period_start DESC;
@@ -0,0 +1,19 @@
+-- name: CreateServerEncryptedFile :exec
+INSERT INTO server_encrypted_files (file_id, key_id)
+VALUES ($1, $2);
+
+-- name: GetServerEncryptionKeyIdByFileId :one
+SELECT key_id
+FROM server_encrypted_files
+WHERE file_id = $1;
+
+-- name: GetWatermarkByShareId :one
+SELECT sef.watermark
+FROM server_encrypted_files sef
+JOIN files f ON f.id = sef.file_id
+WHERE f.share_id = $1;
+
+-- name: UpdateServerEncryptedFileWatermark :execrows
+UPDATE server_encrypted_files
+SET watermark = $2
+WHERE file_id = $1;
@@ -0,0 +1,46 @@
+-- name: CreateShareLink :one
+INSERT INTO share_links (link_id,
+                         file_id,
+                         label,
+                         max_downloads,
+                         expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: CountShareLinksByFileId :one
+SELECT COUNT(*)
+FROM share_links
+WHERE file_id = $1;
+
+-- name: GetShareLink :one
+SELECT l.id,
+       l.link_id,
+       l.max_downloads,
+       l.download_count,
+       l.expires_at,
+       l.revoked_at,
+       f.share_id
+FROM share_links l
+         JOIN files f ON f.id = l.file_id
+WHERE l.link_id = $1;
+
+-- name: ListShareLinksByFileId :many
+SELECT *
+FROM share_links
+WHERE file_id = $1
+ORDER BY created_at, id;
+
+-- name: RevokeShareLink :one
+UPDATE share_links
+SET revoked_at = COALESCE(revoked_at, now())
+WHERE link_id = $1
+  AND file_id = $2
+RETURNING *;
+
+-- name: CountShareLinkDownload :execrows
+UPDATE share_links
+SET download_count = download_count + 1
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND download_count < max_downloads
+  AND (expires_at IS NULL OR expires_at > now());
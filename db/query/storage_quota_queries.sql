@@ -0,0 +1,20 @@
+-- name: GetStoredBytes :one
+SELECT COALESCE(SUM(total_size), 0)::bigint
+FROM files
+WHERE status != 'expired';
+
+-- name: ListEvictionCandidates :many
+SELECT id, share_id, total_size, chunk_count
+FROM files
+WHERE status = 'exhausted'
+   OR (status = 'ready' AND expires_at <= now())
+ORDER BY status = 'exhausted' DESC, expires_at
+LIMIT $1;
+
+-- name: EvictFile :one
+UPDATE files
+SET status    = 'expired',
+    purged_at = now()
+WHERE id = $1
+  AND status != 'expired'
+RETURNING share_id;
@@ -0,0 +1,25 @@
+-- name: AddTransferBytes :exec
+INSERT INTO transfer_stats (file_id, day, bytes_served)
+VALUES ($1, $2, $3)
+ON CONFLICT (file_id, day) DO UPDATE
+    SET bytes_served = transfer_stats.bytes_served + EXCLUDED.bytes_served;
+
+-- name: ListDailyTransferTotals :many
+SELECT day,
+       SUM(bytes_served)::bigint AS bytes
+FROM transfer_stats
+WHERE day >= sqlc.arg('since')::date
+GROUP BY day
+ORDER BY day;
+
+-- name: ListTopTransferFiles :many
+SELECT f.share_id,
+       f.status,
+       f.total_size,
+       SUM(t.bytes_served)::bigint AS bytes
+FROM transfer_stats t
+         JOIN files f ON f.id = t.file_id
+WHERE t.day >= sqlc.arg('since')::date
+GROUP BY f.id
+ORDER BY bytes DESC, f.share_id
+LIMIT sqlc.arg('limit');
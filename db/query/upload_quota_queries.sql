@@ -0,0 +1,14 @@
+-- name: CountActiveSharesByUploader :one
+SELECT COUNT(*)
+FROM files
+WHERE uploader_ip = $1
+  AND status IN ('uploading', 'verifying', 'ready')
+  AND expires_at > now()
+  AND NOT (max_downloads > 0 AND download_count >= max_downloads);
+
+-- name: GetUploaderUsage :one
+SELECT COUNT(*)                          AS upload_count,
+       COALESCE(SUM(total_size), 0)::bigint AS total_bytes
+FROM files
+WHERE uploader_ip = $1
+  AND created_at >= sqlc.arg(since)::timestamptz;
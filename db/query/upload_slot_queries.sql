@@ -0,0 +1,27 @@
+-- name: CreateUploadSlot :exec
+INSERT INTO upload_slots (token_hash,
+                          max_size,
+                          expires_in_hours,
+                          max_downloads,
+                          expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeUploadSlot :one
+DELETE
+FROM upload_slots
+WHERE token_hash = $1
+  AND max_size >= $2
+  AND expires_at > now()
+RETURNING *;
+
+-- name: GetUploadSlot :one
+SELECT *
+FROM upload_slots
+WHERE token_hash = $1
+  AND max_size >= $2
+  AND expires_at > now();
+
+-- name: DeleteExpiredUploadSlots :execrows
+DELETE
+FROM upload_slots
+WHERE expires_at <= now();
@@ -0,0 +1,27 @@
+-- name: CountUnfinishedUploads :one
+SELECT COUNT(*)
+FROM files
+WHERE uploader_ip = $1
+  AND status = 'uploading'
+  AND created_at >= sqlc.arg(since)::timestamptz
+  AND created_at < sqlc.arg(before)::timestamptz;
+
+-- name: GetUploadSquatter :one
+SELECT *
+FROM upload_squatters
+WHERE ip = $1
+  AND flagged_until > now();
+
+-- name: FlagUploadSquatter :one
+INSERT INTO upload_squatters (ip, unfinished_uploads, flagged_until)
+VALUES ($1, $2, $3)
+ON CONFLICT (ip) DO UPDATE
+    SET unfinished_uploads = EXCLUDED.unfinished_uploads,
+        flagged_until      = EXCLUDED.flagged_until,
+        updated_at         = now()
+RETURNING *;
+
+-- name: DeleteExpiredUploadSquatters :execrows
+DELETE
+FROM upload_squatters
+WHERE flagged_until <= now();
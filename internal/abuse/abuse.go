@@ -0,0 +1,80 @@
+// Package abuse scores upload requests so spam and abusive uploads can be
+// turned away before they reach storage.
+package abuse
+
+import (
+	"context"
+	"expvar"
+	"net/netip"
+	"time"
+)
+
+// Verdict is what should happen to a scored request.
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Challenge
+	Deny
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Challenge:
+		return "challenge"
+	case Deny:
+		return "deny"
+	default:
+		return "allow"
+	}
+}
+
+var verdicts = expvar.NewMap("abuse_verdicts")
+
+// Request describes an upload being initialized.
+type Request struct {
+	ClientIP     netip.Addr
+	TotalSize    int64
+	ChunkCount   int32
+	ExpiresIn    time.Duration
+	MaxDownloads int32
+	Metadata     Metadata
+}
+
+// Metadata holds request details that are not part of the upload itself.
+type Metadata struct {
+	UserAgent string
+	Origin    string
+}
+
+// Decision is a Scorer's verdict together with the score behind it and the
+// signals that contributed.
+type Decision struct {
+	Verdict Verdict
+	Score   int
+	Reasons []string
+}
+
+// Scorer rates an upload request. Implementations must be safe for
+// concurrent use.
+type Scorer interface {
+	Score(ctx context.Context, req Request) (Decision, error)
+}
+
+// Record counts a decision in the abuse_verdicts metric.
+func Record(d Decision) {
+	verdicts.Add(d.Verdict.String(), 1)
+}
+
+type metadataKey struct{}
+
+// WithMetadata attaches request metadata to ctx for the scorer.
+func WithMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+// MetadataFromContext returns the metadata attached by WithMetadata.
+func MetadataFromContext(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataKey{}).(Metadata)
+	return m
+}
@@ -0,0 +1,266 @@
+package abuse
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Weights are the points each signal adds to a request's score.
+type Weights struct {
+	Burst        int
+	DisposableIP int
+	LargeFile    int
+	LongExpiry   int
+	NoUserAgent  int
+}
+
+// HeuristicConfig tunes the default scorer.
+type HeuristicConfig struct {
+	Weights Weights
+	// ChallengeScore and DenyScore are the scores at which requests are
+	// challenged or denied.
+	ChallengeScore int
+	DenyScore      int
+	// More than BurstLimit inits from one IP within BurstWindow is a burst.
+	BurstLimit  int
+	BurstWindow time.Duration
+	// Uploads at least LargeFileSize bytes, or kept for at least LongExpiry,
+	// are weighted as riskier.
+	LargeFileSize int64
+	LongExpiry    time.Duration
+	// DisposableIPs lists networks of throwaway hosts such as public VPN exits
+	// and cheap VPS ranges.
+	DisposableIPs []netip.Prefix
+}
+
+func DefaultHeuristicConfig() HeuristicConfig {
+	return HeuristicConfig{
+		Weights: Weights{
+			Burst:        50,
+			DisposableIP: 40,
+			LargeFile:    10,
+			LongExpiry:   10,
+			NoUserAgent:  20,
+		},
+		ChallengeScore: 50,
+		DenyScore:      100,
+		BurstLimit:     10,
+		BurstWindow:    time.Minute,
+		LargeFileSize:  1 << 30,
+		LongExpiry:     7 * 24 * time.Hour,
+	}
+}
+
+// maxTrackedIPs bounds the burst tracker; beyond it stale IPs are swept.
+const maxTrackedIPs = 10000
+
+// Heuristic is the default Scorer. It adds up weighted signals and compares
+// the total against the configured thresholds.
+type Heuristic struct {
+	cfg HeuristicConfig
+	now func() time.Time
+
+	mu     sync.Mutex
+	recent map[netip.Addr][]time.Time
+}
+
+func NewHeuristic(cfg HeuristicConfig) *Heuristic {
+	return &Heuristic{
+		cfg:    cfg,
+		now:    time.Now,
+		recent: make(map[netip.Addr][]time.Time),
+	}
+}
+
+func (h *Heuristic) Score(ctx context.Context, req Request) (Decision, error) {
+	var d Decision
+	add := func(weight int, reason string) {
+		if weight > 0 {
+			d.Score += weight
+			d.Reasons = append(d.Reasons, reason)
+		}
+	}
+
+	ip := req.ClientIP.Unmap()
+	if h.burst(ip) {
+		add(h.cfg.Weights.Burst, "burst")
+	}
+	if h.disposable(ip) {
+		add(h.cfg.Weights.DisposableIP, "disposable_ip")
+	}
+	if h.cfg.LargeFileSize > 0 && req.TotalSize >= h.cfg.LargeFileSize {
+		add(h.cfg.Weights.LargeFile, "large_file")
+	}
+	if h.cfg.LongExpiry > 0 && req.ExpiresIn >= h.cfg.LongExpiry {
+		add(h.cfg.Weights.LongExpiry, "long_expiry")
+	}
+	if req.Metadata.UserAgent == "" {
+		add(h.cfg.Weights.NoUserAgent, "no_user_agent")
+	}
+
+	switch {
+	case d.Score >= h.cfg.DenyScore:
+		d.Verdict = Deny
+	case d.Score >= h.cfg.ChallengeScore:
+		d.Verdict = Challenge
+	}
+	return d, nil
+}
+
+// burst records an init from ip and reports whether it pushes the IP over
+// the burst limit.
+func (h *Heuristic) burst(ip netip.Addr) bool {
+	if h.cfg.BurstLimit <= 0 || !ip.IsValid() {
+		return false
+	}
+
+	now := h.now()
+	cutoff := now.Add(-h.cfg.BurstWindow)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.recent) >= maxTrackedIPs {
+		for addr, times := range h.recent {
+			if times[len(times)-1].Before(cutoff) {
+				delete(h.recent, addr)
+			}
+		}
+	}
+
+	times := h.recent[ip]
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	h.recent[ip] = kept
+
+	return len(kept) > h.cfg.BurstLimit
+}
+
+func (h *Heuristic) disposable(ip netip.Addr) bool {
+	for _, prefix := range h.cfg.DisposableIPs {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromEnv builds the heuristic scorer when ABUSE_SCORING is "heuristic",
+// tuned by the ABUSE_* variables. It returns nil when scoring is off.
+func FromEnv() (Scorer, error) {
+	switch strings.ToLower(os.Getenv("ABUSE_SCORING")) {
+	case "", "off":
+		return nil, nil
+	case "heuristic":
+	default:
+		return nil, fmt.Errorf("unknown ABUSE_SCORING %q", os.Getenv("ABUSE_SCORING"))
+	}
+
+	cfg := DefaultHeuristicConfig()
+
+	if v := os.Getenv("ABUSE_WEIGHTS"); v != "" {
+		if err := parseWeights(v, &cfg.Weights); err != nil {
+			return nil, err
+		}
+	}
+
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"ABUSE_CHALLENGE_SCORE", &cfg.ChallengeScore},
+		{"ABUSE_DENY_SCORE", &cfg.DenyScore},
+		{"ABUSE_BURST_LIMIT", &cfg.BurstLimit},
+	}
+	for _, i := range ints {
+		if v := os.Getenv(i.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", i.key, err)
+			}
+			*i.dst = n
+		}
+	}
+	if cfg.ChallengeScore <= 0 || cfg.DenyScore < cfg.ChallengeScore {
+		return nil, fmt.Errorf("ABUSE_CHALLENGE_SCORE must be positive and at most ABUSE_DENY_SCORE")
+	}
+
+	if v := os.Getenv("ABUSE_BURST_WINDOW_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid ABUSE_BURST_WINDOW_SECONDS %q", v)
+		}
+		cfg.BurstWindow = time.Duration(n) * time.Second
+	}
+
+	if v := os.Getenv("ABUSE_DISPOSABLE_IPS"); v != "" {
+		prefixes, err := parsePrefixes(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DisposableIPs = prefixes
+	}
+
+	return NewHeuristic(cfg), nil
+}
+
+// parseWeights reads a comma-separated list of name=points pairs, such as
+// "burst=50,disposable_ip=40".
+func parseWeights(s string, w *Weights) error {
+	fields := map[string]*int{
+		"burst":         &w.Burst,
+		"disposable_ip": &w.DisposableIP,
+		"large_file":    &w.LargeFile,
+		"long_expiry":   &w.LongExpiry,
+		"no_user_agent": &w.NoUserAgent,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		dst, known := fields[name]
+		if !ok || !known {
+			return fmt.Errorf("invalid ABUSE_WEIGHTS entry %q", pair)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid ABUSE_WEIGHTS entry %q", pair)
+		}
+		*dst = n
+	}
+	return nil
+}
+
+// parsePrefixes reads a comma-separated list of CIDRs or single addresses.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ABUSE_DISPOSABLE_IPS entry %q: %w", field, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ABUSE_DISPOSABLE_IPS entry %q: %w", field, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,141 @@
+package abuse
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func benignRequest() Request {
+	return Request{
+		ClientIP:  netip.MustParseAddr("198.51.100.7"),
+		TotalSize: 1 << 20,
+		ExpiresIn: 24 * time.Hour,
+		Metadata:  Metadata{UserAgent: "Mozilla/5.0"},
+	}
+}
+
+func TestHeuristic_AllowsBenignRequest(t *testing.T) {
+	h := NewHeuristic(DefaultHeuristicConfig())
+
+	d, err := h.Score(context.Background(), benignRequest())
+
+	require.NoError(t, err)
+	assert.Equal(t, Allow, d.Verdict)
+	assert.Zero(t, d.Score)
+	assert.Empty(t, d.Reasons)
+}
+
+func TestHeuristic_BurstChallenges(t *testing.T) {
+	cfg := DefaultHeuristicConfig()
+	cfg.BurstLimit = 3
+	h := NewHeuristic(cfg)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 3 {
+		d, err := h.Score(ctx, benignRequest())
+		require.NoError(t, err)
+		assert.Equal(t, Allow, d.Verdict)
+	}
+
+	d, err := h.Score(ctx, benignRequest())
+	require.NoError(t, err)
+	assert.Equal(t, Challenge, d.Verdict)
+	assert.Equal(t, []string{"burst"}, d.Reasons)
+
+	// The burst is forgotten once the window has passed
+	now = now.Add(cfg.BurstWindow + time.Second)
+	d, err = h.Score(ctx, benignRequest())
+	require.NoError(t, err)
+	assert.Equal(t, Allow, d.Verdict)
+}
+
+func TestHeuristic_CombinedSignalsDeny(t *testing.T) {
+	cfg := DefaultHeuristicConfig()
+	cfg.DisposableIPs = []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}
+	cfg.DenyScore = 80
+	h := NewHeuristic(cfg)
+
+	req := benignRequest()
+	req.ClientIP = netip.MustParseAddr("::ffff:203.0.113.9")
+	req.TotalSize = 2 << 30
+	req.ExpiresIn = 30 * 24 * time.Hour
+	req.Metadata.UserAgent = ""
+
+	d, err := h.Score(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, Deny, d.Verdict)
+	assert.Equal(t, 80, d.Score)
+	assert.ElementsMatch(t, []string{"disposable_ip", "large_file", "long_expiry", "no_user_agent"}, d.Reasons)
+}
+
+func TestHeuristic_ZeroWeightIgnoresSignal(t *testing.T) {
+	cfg := DefaultHeuristicConfig()
+	cfg.Weights.NoUserAgent = 0
+	h := NewHeuristic(cfg)
+
+	req := benignRequest()
+	req.Metadata.UserAgent = ""
+
+	d, err := h.Score(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Empty(t, d.Reasons)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Run("off by default", func(t *testing.T) {
+		t.Setenv("ABUSE_SCORING", "")
+
+		sc, err := FromEnv()
+
+		require.NoError(t, err)
+		assert.Nil(t, sc)
+	})
+
+	t.Run("heuristic with overrides", func(t *testing.T) {
+		t.Setenv("ABUSE_SCORING", "heuristic")
+		t.Setenv("ABUSE_WEIGHTS", "burst=70, no_user_agent=0")
+		t.Setenv("ABUSE_DENY_SCORE", "150")
+		t.Setenv("ABUSE_BURST_WINDOW_SECONDS", "30")
+		t.Setenv("ABUSE_DISPOSABLE_IPS", "203.0.113.0/24,2001:db8::1")
+
+		sc, err := FromEnv()
+
+		require.NoError(t, err)
+		h := sc.(*Heuristic)
+		assert.Equal(t, 70, h.cfg.Weights.Burst)
+		assert.Equal(t, 0, h.cfg.Weights.NoUserAgent)
+		assert.Equal(t, 40, h.cfg.Weights.DisposableIP)
+		assert.Equal(t, 150, h.cfg.DenyScore)
+		assert.Equal(t, 30*time.Second, h.cfg.BurstWindow)
+		assert.Len(t, h.cfg.DisposableIPs, 2)
+	})
+
+	invalid := map[string]map[string]string{
+		"unknown scorer":       {"ABUSE_SCORING": "magic"},
+		"unknown weight":       {"ABUSE_SCORING": "heuristic", "ABUSE_WEIGHTS": "karma=10"},
+		"negative weight":      {"ABUSE_SCORING": "heuristic", "ABUSE_WEIGHTS": "burst=-1"},
+		"deny below challenge": {"ABUSE_SCORING": "heuristic", "ABUSE_DENY_SCORE": "10"},
+		"bad prefix":           {"ABUSE_SCORING": "heuristic", "ABUSE_DISPOSABLE_IPS": "300.0.0.0/8"},
+		"bad window":           {"ABUSE_SCORING": "heuristic", "ABUSE_BURST_WINDOW_SECONDS": "0"},
+	}
+	for name, env := range invalid {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+
+			_, err := FromEnv()
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package alert delivers operational events to an external webhook.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/httpclient"
+)
+
+const sendTimeout = 10 * time.Second
+
+// Event is the JSON body posted to the webhook.
+type Event struct {
+	Type       string         `json:"type"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// Webhook posts events to a URL. Delivery is best effort: failures are
+// logged and never block the caller.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(rawURL string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook URL must be http or https, got %q", rawURL)
+	}
+
+	return &Webhook{
+		url:    u.String(),
+		client: &http.Client{Timeout: sendTimeout},
+	}, nil
+}
+
+// FromEnv builds a Webhook sending through pool from ALERT_WEBHOOK_URL. It
+// returns nil when no webhook is configured.
+func FromEnv(pool *httpclient.Pool) (*Webhook, error) {
+	rawURL := os.Getenv("ALERT_WEBHOOK_URL")
+	if rawURL == "" {
+		return nil, nil
+	}
+	w, err := NewWebhook(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	w.client = pool.Client(sendTimeout)
+	return w, nil
+}
+
+// Notify sends e in the background.
+func (w *Webhook) Notify(e Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+
+	go func() {
+		if err := w.send(e); err != nil {
+			slog.Error("failed to deliver alert",
+				slog.String("error", err.Error()),
+				slog.String("type", e.Type),
+			)
+		}
+	}()
+}
+
+func (w *Webhook) send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var e Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		received <- e
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL)
+	require.NoError(t, err)
+
+	w.Notify(Event{
+		Type:    "chunk_missing",
+		Message: "chunk missing from storage",
+		Details: map[string]any{"share_id": "abc"},
+	})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, "chunk_missing", e.Type)
+		assert.Equal(t, "abc", e.Details["share_id"])
+		assert.False(t, e.OccurredAt.IsZero())
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestWebhook_SendReportsHTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL)
+	require.NoError(t, err)
+
+	err = w.send(Event{Type: "test"})
+
+	assert.Error(t, err)
+}
+
+func TestNewWebhook_InvalidURL(t *testing.T) {
+	for _, rawURL := range []string{"ftp://example.com/hook", "not a url", "://missing"} {
+		_, err := NewWebhook(rawURL)
+		assert.Error(t, err, rawURL)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("ALERT_WEBHOOK_URL", "")
+	w, err := FromEnv(nil)
+	require.NoError(t, err)
+	assert.Nil(t, w)
+
+	t.Setenv("ALERT_WEBHOOK_URL", "https://hooks.example.com/gzln")
+	w, err = FromEnv(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, w)
+}
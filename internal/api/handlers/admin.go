@@ -0,0 +1,597 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/middleware"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// adminActor is recorded in the audit log for changes made through the
+// admin API, which has a single shared token.
+const adminActor = "admin"
+
+// Bounds for the number of IPs listed by GetRateLimits.
+const (
+	defaultRateLimitIPs = 10
+	maxRateLimitIPs     = 100
+)
+
+type AdminHandler struct {
+	adminService     *service.AdminService
+	cleanupService   *service.CleanupService
+	retentionService *service.RetentionService
+}
+
+func NewAdminHandler(adminService *service.AdminService, cleanupService *service.CleanupService, retentionService *service.RetentionService) *AdminHandler {
+	return &AdminHandler{
+		adminService:     adminService,
+		cleanupService:   cleanupService,
+		retentionService: retentionService,
+	}
+}
+
+// ListFiles returns a page of files filtered by the status, min_size,
+// max_size and uploader_ip query parameters.
+func (h *AdminHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	query := r.URL.Query()
+
+	filter := types.AdminFileFilter{
+		Status:     query.Get("status"),
+		UploaderIP: query.Get("uploader_ip"),
+	}
+	for name, dst := range map[string]*int64{"min_size": &filter.MinSize, "max_size": &filter.MaxSize} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				utils.Error(w, http.StatusBadRequest, name+" must be an integer")
+				return
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				utils.Error(w, http.StatusBadRequest, name+" must be an integer")
+				return
+			}
+			*dst = n
+		}
+	}
+
+	files, err := h.adminService.ListFiles(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAdminFilter) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to list files",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to list files")
+		return
+	}
+
+	utils.Ok(w, files)
+}
+
+func (h *AdminHandler) ExpireFile(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	if err := h.adminService.ForceExpire(r.Context(), shareID, adminActor); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found or already expired")
+			return
+		}
+		log.Error("failed to expire file",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to expire file")
+		return
+	}
+
+	utils.Ok(w, map[string]string{"share_id": shareID})
+}
+
+func (h *AdminHandler) GetNotes(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	notes, err := h.adminService.GetAdminNotes(r.Context(), shareID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		log.Error("failed to get admin notes",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get admin notes")
+		return
+	}
+
+	utils.Ok(w, notes)
+}
+
+func (h *AdminHandler) UpdateNotes(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req types.AdminNotesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	if err := h.adminService.UpdateAdminNotes(r.Context(), shareID, req.Notes, adminActor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAdminNotesTooLong):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		default:
+			log.Error("failed to update admin notes",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to update admin notes")
+		}
+		return
+	}
+
+	utils.Ok(w, map[string]string{"share_id": shareID})
+}
+
+// NotServerEncryptedCode is returned with 409 when a watermark is set on a
+// share the server cannot decrypt.
+const NotServerEncryptedCode = "not_server_encrypted"
+
+func (h *AdminHandler) SetWatermark(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req types.AdminWatermarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	if err := h.adminService.SetWatermark(r.Context(), shareID, req.Text, adminActor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrWatermarkTooLong):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrWatermarkingOff):
+			utils.Error(w, http.StatusServiceUnavailable, "Watermarking is not enabled")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		case errors.Is(err, service.ErrNotServerEncrypted):
+			utils.ErrorWithCode(w, http.StatusConflict, NotServerEncryptedCode, "Only server encrypted shares can be watermarked")
+		default:
+			log.Error("failed to set watermark",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to set watermark")
+		}
+		return
+	}
+
+	utils.Ok(w, map[string]string{"share_id": shareID})
+}
+
+// SetDownloadRate overrides DOWNLOAD_RATE_LIMIT for downloads of a file.
+func (h *AdminHandler) SetDownloadRate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req types.AdminDownloadRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	if err := h.adminService.SetDownloadRate(r.Context(), shareID, req.BytesPerSecond, adminActor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDownloadRate):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found or already expired")
+		default:
+			log.Error("failed to set download rate",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to set download rate")
+		}
+		return
+	}
+
+	utils.Ok(w, map[string]string{"share_id": shareID})
+}
+
+// CreatePreviewToken issues a time-limited token letting support staff view
+// a share's metadata and event history.
+func (h *AdminHandler) CreatePreviewToken(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token, err := h.adminService.CreatePreviewToken(r.Context(), shareID, adminActor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSupportPreviewsOff):
+			utils.Error(w, http.StatusServiceUnavailable, "Support previews are not enabled")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		default:
+			log.Error("failed to create preview token",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to create preview token")
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.APIResponse{Success: true, Data: token})
+}
+
+func (h *AdminHandler) GetStorageTotals(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	totals, err := h.adminService.StorageTotals(r.Context())
+	if err != nil {
+		log.Error("failed to get storage totals",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get storage totals")
+		return
+	}
+
+	utils.Ok(w, totals)
+}
+
+// GetTransferTotals returns the bytes served per day and by the files served
+// the most, over the days and limit query parameters.
+func (h *AdminHandler) GetTransferTotals(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	query := r.URL.Query()
+
+	var days, limit int
+	for name, dst := range map[string]*int{"days": &days, "limit": &limit} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				utils.Error(w, http.StatusBadRequest, name+" must be an integer")
+				return
+			}
+			*dst = n
+		}
+	}
+
+	totals, err := h.adminService.TransferTotals(r.Context(), days, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAdminFilter) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to get transfer totals",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get transfer totals")
+		return
+	}
+
+	utils.Ok(w, totals)
+}
+
+// RunCleanup expires due files and deletes their chunks without waiting for
+// the next scheduled cleanup.
+func (h *AdminHandler) RunCleanup(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	expired, err := h.cleanupService.CleanupExpiredFiles(r.Context())
+	if err != nil {
+		log.Error("on-demand cleanup failed",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Cleanup failed")
+		return
+	}
+
+	log.Info("on-demand cleanup finished",
+		slog.Int("expired", expired),
+	)
+	utils.Ok(w, types.AdminCleanupResponse{Expired: expired})
+}
+
+func (h *AdminHandler) ListFlags(w http.ResponseWriter, r *http.Request) {
+	utils.Ok(w, h.adminService.FeatureFlags())
+}
+
+// SetFlag toggles a feature flag on every instance. Other instances apply it
+// on their next refresh.
+func (h *AdminHandler) SetFlag(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	name := chi.URLParam(r, "name")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req types.AdminFeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		utils.Error(w, http.StatusBadRequest, "Request body must be {\"enabled\": true|false}")
+		return
+	}
+
+	flag, err := h.adminService.SetFeatureFlag(r.Context(), name, *req.Enabled, adminActor)
+	if err != nil {
+		if errors.Is(err, flags.ErrUnknownFlag) {
+			utils.Error(w, http.StatusNotFound, "Unknown feature flag")
+			return
+		}
+		log.Error("failed to set feature flag",
+			slog.String("error", err.Error()),
+			slog.String("flag", name),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to set feature flag")
+		return
+	}
+
+	utils.Ok(w, flag)
+}
+
+func (h *AdminHandler) GetReadOnly(w http.ResponseWriter, r *http.Request) {
+	utils.Ok(w, types.AdminReadOnlyResponse{Enabled: h.adminService.ReadOnly()})
+}
+
+// GetRateLimits returns the rate limit counters since the last report, with
+// the limit query parameter (default 10) capping the IPs listed.
+func (h *AdminHandler) GetRateLimits(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRateLimitIPs
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRateLimitIPs {
+			utils.Error(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxRateLimitIPs))
+			return
+		}
+		limit = n
+	}
+
+	utils.Ok(w, types.AdminRateLimits{
+		Rejected: middleware.RateLimitSnapshot(limit),
+		Exempted: middleware.ExemptionSnapshot(limit),
+	})
+}
+
+// GetJobs returns the scheduled jobs of the instance answering and the
+// background chunk verifications.
+func (h *AdminHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	jobs, err := h.adminService.Jobs(r.Context())
+	if err != nil {
+		log.Error("failed to get jobs",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get jobs")
+		return
+	}
+
+	utils.Ok(w, jobs)
+}
+
+func (h *AdminHandler) ListBlocklist(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	networks, err := h.adminService.BlockedNetworks(r.Context())
+	if err != nil {
+		log.Error("failed to list blocked networks",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to list blocked networks")
+		return
+	}
+
+	utils.Ok(w, networks)
+}
+
+// BlockNetwork adds a network to the blocklist of every instance. Other
+// instances apply it on their next refresh.
+func (h *AdminHandler) BlockNetwork(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req types.AdminBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Network == "" {
+		utils.Error(w, http.StatusBadRequest, "Request body must be {\"network\": \"...\", \"reason\": \"...\"}")
+		return
+	}
+
+	blocked, err := h.adminService.BlockNetwork(r.Context(), req.Network, req.Reason, adminActor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidNetwork) || errors.Is(err, service.ErrBlockReasonTooLong) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to block network",
+			slog.String("error", err.Error()),
+			slog.String("network", req.Network),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to block network")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.APIResponse{Success: true, Data: blocked})
+}
+
+// UnblockNetwork removes the network in the network query parameter from the
+// blocklist.
+func (h *AdminHandler) UnblockNetwork(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	network := r.URL.Query().Get("network")
+
+	if err := h.adminService.UnblockNetwork(r.Context(), network, adminActor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidNetwork):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "Network is not blocked")
+		default:
+			log.Error("failed to unblock network",
+				slog.String("error", err.Error()),
+				slog.String("network", network),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to unblock network")
+		}
+		return
+	}
+
+	utils.Ok(w, map[string]string{"network": network})
+}
+
+// SetReadOnly switches read-only mode on the instance serving the request
+// only. Set READ_ONLY to switch a whole deployment.
+func (h *AdminHandler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req types.AdminReadOnlyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		utils.Error(w, http.StatusBadRequest, "Request body must be {\"enabled\": true|false}")
+		return
+	}
+
+	h.adminService.SetReadOnly(r.Context(), *req.Enabled, adminActor)
+	utils.Ok(w, types.AdminReadOnlyResponse{Enabled: *req.Enabled})
+}
+
+func (h *AdminHandler) ListRetentionReports(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	reports, err := h.retentionService.ListReports(r.Context())
+	if err != nil {
+		log.Error("failed to list retention reports",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to list retention reports")
+		return
+	}
+
+	utils.Ok(w, reports)
+}
+
+// GetRetentionReport returns the stored report of a month as JSON or, with
+// ?format=csv, as a CSV download.
+func (h *AdminHandler) GetRetentionReport(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		utils.Error(w, http.StatusBadRequest, "Format must be json or csv")
+		return
+	}
+	month, err := service.ParseReportMonth(chi.URLParam(r, "month"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.retentionService.GetReport(r.Context(), month)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "No retention report for this month")
+			return
+		}
+		log.Error("failed to get retention report",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get retention report")
+		return
+	}
+
+	if format != "csv" {
+		utils.Ok(w, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gzln-retention-%s.csv"`, report.Month))
+	if err := writeRetentionReportCSV(w, report); err != nil {
+		log.Error("failed to write retention report csv",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// GenerateRetentionReport builds the report of a past month now, replacing
+// the stored one. The scheduler only generates last month's report.
+func (h *AdminHandler) GenerateRetentionReport(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	month, err := service.ParseReportMonth(chi.URLParam(r, "month"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.retentionService.GenerateReport(r.Context(), month)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReportMonth) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to generate retention report",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to generate retention report")
+		return
+	}
+
+	utils.Ok(w, report)
+}
+
+// writeRetentionReportCSV writes the report as a header row and a value row.
+// The oldest retained columns are empty when nothing was retained.
+func writeRetentionReportCSV(w http.ResponseWriter, report types.RetentionReport) error {
+	var oldestShareID, oldestCreatedAt, oldestAge string
+	if o := report.OldestRetained; o != nil {
+		oldestShareID = o.ShareID
+		oldestCreatedAt = o.CreatedAt
+		oldestAge = strconv.FormatInt(o.AgeSeconds, 10)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"month", "period_start", "period_end", "generated_at",
+		"files_created", "bytes_created", "files_expired", "files_purged", "bytes_purged",
+		"average_lifetime_seconds", "oldest_retained_share_id", "oldest_retained_created_at", "oldest_retained_age_seconds",
+	})
+	cw.Write([]string{
+		report.Month, report.PeriodStart, report.PeriodEnd, report.GeneratedAt,
+		strconv.FormatInt(report.FilesCreated, 10),
+		strconv.FormatInt(report.BytesCreated, 10),
+		strconv.FormatInt(report.FilesExpired, 10),
+		strconv.FormatInt(report.FilesPurged, 10),
+		strconv.FormatInt(report.BytesPurged, 10),
+		strconv.FormatInt(report.AverageLifetimeSeconds, 10),
+		oldestShareID, oldestCreatedAt, oldestAge,
+	})
+	cw.Flush()
+	return cw.Error()
+}
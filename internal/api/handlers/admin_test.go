@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_MANAGE_SESSION", "1")
+	middleware.ReloadConfig()
+	t.Cleanup(middleware.ReloadConfig)
+
+	limited := middleware.AdminLimiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/admin/storage", nil)
+		req.RemoteAddr = "203.0.113.77:4000"
+		limited.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	h := NewAdminHandler(nil, nil, nil)
+	w := httptest.NewRecorder()
+	h.GetRateLimits(w, httptest.NewRequest(http.MethodGet, "/admin/rate-limits?limit=100", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data types.AdminRateLimits `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.GreaterOrEqual(t, resp.Data.Rejected.ByLimiter["admin"], int64(2))
+	assert.Contains(t, resp.Data.Rejected.TopIPs, middleware.LimitedIP{IP: "203.0.113.77", Count: 2})
+	assert.NotNil(t, resp.Data.Exempted.ByLimiter)
+}
+
+func TestGetRateLimits_InvalidLimit(t *testing.T) {
+	h := NewAdminHandler(nil, nil, nil)
+
+	for _, limit := range []string{"0", "101", "many"} {
+		w := httptest.NewRecorder()
+		h.GetRateLimits(w, httptest.NewRequest(http.MethodGet, "/admin/rate-limits?limit="+limit, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "limit=%s", limit)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RequireUploadToken rejects requests whose Bearer token does not match the
+// upload token issued for the file in the {fileID} URL parameter.
+func (h *FileHandler) RequireUploadToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+
+		token := bearerToken(r)
+		if token == "" {
+			log.Warn("missing authorization header")
+			utils.Error(w, http.StatusUnauthorized, "Authorization required")
+			return
+		}
+
+		fileIDStr := chi.URLParam(r, "fileID")
+		var fileID pgtype.UUID
+		if err := fileID.Scan(fileIDStr); err != nil {
+			log.Warn("invalid file ID",
+				slog.String("file_id_str", fileIDStr),
+				slog.String("error", err.Error()),
+			)
+			utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+			return
+		}
+
+		err := h.fileService.VerifyUploadToken(r.Context(), fileID, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidUploadToken):
+				utils.Error(w, http.StatusForbidden, "Invalid upload token")
+			case errors.Is(err, service.ErrNotFound):
+				utils.Error(w, http.StatusNotFound, "File not found")
+			default:
+				log.Error("failed to verify upload token",
+					slog.String("error", err.Error()),
+					slog.String("file_id", fileIDStr),
+				)
+				utils.Error(w, http.StatusInternalServerError, "Failed to verify upload token")
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// RequireDownloadNonce rejects chunk and stream downloads of the share in the
+// {shareID} URL parameter that do not carry a live download nonce, so the
+// data cannot be fetched without it counting towards max_downloads.
+func (h *ChunkHandler) RequireDownloadNonce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		shareID := chi.URLParam(r, "shareID")
+
+		err := h.chunkService.CheckDownloadNonce(r.Context(), shareID, r.Header.Get(DownloadNonceHeader))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidDownloadNonce):
+				log.Warn("download without a live nonce",
+					slog.String("share_id", shareID),
+				)
+				utils.Error(w, http.StatusForbidden, "Invalid or expired download nonce")
+			case errors.Is(err, readonly.ErrReadOnly):
+				readonly.Reject(w)
+			default:
+				log.Error("failed to check download nonce",
+					slog.String("error", err.Error()),
+					slog.String("share_id", shareID),
+				)
+				utils.Error(w, http.StatusInternalServerError, "Failed to check download nonce")
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+func (h *FileHandler) CreateBundle(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	var req types.CreateBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid JSON in bundle request",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	bundle, err := h.fileService.CreateBundle(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBundleRequest) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to create bundle",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to create bundle")
+		return
+	}
+
+	utils.Ok(w, bundle)
+}
+
+func (h *FileHandler) GetBundleManifest(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	manifest, err := h.fileService.GetBundleManifest(r.Context(), shareID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "Bundle not found or has expired")
+			return
+		}
+		log.Error("failed to get bundle manifest",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get bundle manifest")
+		return
+	}
+
+	utils.Ok(w, manifest)
+}
+
+// DownloadBundleArchive streams the files of a bundle as one ZIP archive. It
+// has no Content-Length, so an error after the headers are sent breaks the
+// connection to tell the client the archive is incomplete.
+func (h *ChunkHandler) DownloadBundleArchive(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	archive, err := h.chunkService.OpenBundleArchive(r.Context(), shareID, getClientIP(r))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "Bundle not found or has expired")
+			return
+		}
+		log.Error("failed to open bundle archive",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to download bundle")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WithFilename(archive.ShareID + ".zip")(w)
+	utils.WithoutTimeouts(w)
+
+	if err := archive.WriteZip(w); err != nil {
+		log.Error("failed to stream bundle archive",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		panic(http.ErrAbortHandler)
+	}
+
+	log.Info("bundle archive streamed",
+		slog.String("share_id", shareID),
+	)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// ClientConfigSignatureHeader carries the base64 Ed25519 signature of the
+// config bundle body, so it can be checked without fetching the detached
+// signature separately.
+const ClientConfigSignatureHeader = "X-Gzln-Config-Signature"
+
+// ClientConfigVersion is the bundle format version.
+const ClientConfigVersion = 1
+
+// clientConfigMaxAge is how long clients and caches may reuse a bundle.
+// Feature flag changes reach clients within it.
+const clientConfigMaxAge = 5 * time.Minute
+
+// clientEndpoints are the API paths a client needs, keyed by what they do.
+var clientEndpoints = map[string]string{
+	"openapi":           "/api/v1/openapi.json",
+	"upload_init":       "/api/v1/files/upload/init",
+	"chunk_upload":      "/api/v1/files/{fileID}/chunks",
+	"chunk_status":      "/api/v1/files/{fileID}/chunks/status",
+	"upload_finalize":   "/api/v1/files/{fileID}/finalize",
+	"metadata":          "/api/v1/download/{shareID}/metadata",
+	"manifest":          "/api/v1/download/{shareID}/manifest",
+	"chunk_download":    "/api/v1/download/{shareID}/chunks/{chunkIndex}",
+	"download_complete": "/api/v1/download/{shareID}/complete",
+}
+
+type ClientConfigHandler struct {
+	cfg              config.Config
+	presignedUploads bool
+	flags            *flags.Provider
+	signer           *crypto.Signer
+}
+
+func NewClientConfigHandler(cfg config.Config, presignedUploads bool, flags *flags.Provider, signer *crypto.Signer) *ClientConfigHandler {
+	return &ClientConfigHandler{
+		cfg:              cfg,
+		presignedUploads: presignedUploads,
+		flags:            flags,
+		signer:           signer,
+	}
+}
+
+// GetConfig serves the config bundle. Its body is served as signed, without
+// the usual response envelope, so clients verify the exact bytes received.
+func (h *ClientConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	body, signature, ok := h.signedBundle(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set(ClientConfigSignatureHeader, signature)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// GetSignature serves the detached signature of the bundle GetConfig serves
+// now. Both share an ETag, so a client can tell whether they match.
+func (h *ClientConfigHandler) GetSignature(w http.ResponseWriter, r *http.Request) {
+	_, signature, ok := h.signedBundle(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", utils.TextContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(signature + "\n"))
+}
+
+// signedBundle encodes and signs the bundle and sets its cache headers. It
+// answers the request itself, reporting false, when the client's copy is
+// current or the bundle cannot be built.
+func (h *ClientConfigHandler) signedBundle(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	body, err := json.Marshal(h.bundle())
+	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to encode client config",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to build client config")
+		return nil, "", false
+	}
+
+	etag := `"` + crypto.HashBytes(body) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(clientConfigMaxAge.Seconds())))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil, "", false
+	}
+
+	return body, base64.StdEncoding.EncodeToString(h.signer.Sign(body)), true
+}
+
+func (h *ClientConfigHandler) bundle() types.ClientConfig {
+	features := make(map[string]bool)
+	for _, f := range h.flags.All() {
+		features[f.Name] = f.Enabled
+	}
+	// The flag only switches presigned uploads off where they are set up
+	features[flags.PresignedUploads] = features[flags.PresignedUploads] && h.presignedUploads
+
+	return types.ClientConfig{
+		Version: ClientConfigVersion,
+		Limits: types.ClientLimits{
+			MaxFileSize:          h.cfg.Limits.MaxFileSize,
+			MaxChunkSize:         h.cfg.Limits.MaxChunkSize,
+			MaxChunkCount:        h.cfg.Limits.MaxChunkCount,
+			DefaultMaxDownloads:  h.cfg.Limits.DefaultMaxDownloads,
+			DefaultExpirySeconds: int64(h.cfg.Limits.DefaultExpiry.Seconds()),
+		},
+		Transfer: types.ClientTransfer{
+			UploadConcurrency: h.cfg.Transfer.UploadConcurrency,
+			DownloadPrefetch:  h.cfg.Transfer.DownloadPrefetch,
+		},
+		Endpoints: clientEndpoints,
+		Features:  features,
+		PublicKeys: []types.ConfigPublicKey{{
+			KeyID:     h.signer.KeyID(),
+			Algorithm: "ed25519",
+			Use:       "client_config",
+			PublicKey: base64.StdEncoding.EncodeToString(h.signer.PublicKey()),
+		}},
+	}
+}
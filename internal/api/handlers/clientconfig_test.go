@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClientConfigHandler(t *testing.T) (*ClientConfigHandler, *crypto.Signer) {
+	t.Helper()
+	signer, err := crypto.NewSigner(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	require.NoError(t, err)
+	provider, err := flags.New(nil, map[string]bool{flags.QuotaEviction: false})
+	require.NoError(t, err)
+
+	cfg := config.Config{Limits: config.DefaultLimits()}
+	return NewClientConfigHandler(cfg, false, provider, signer), signer
+}
+
+func TestClientConfig_Signed(t *testing.T) {
+	h, signer := newTestClientConfigHandler(t)
+
+	w := httptest.NewRecorder()
+	h.GetConfig(w, httptest.NewRequest(http.MethodGet, "/.well-known/gzln-config.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.Bytes()
+
+	signature, err := base64.StdEncoding.DecodeString(w.Header().Get(ClientConfigSignatureHeader))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(signer.PublicKey(), body, signature))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age=")
+
+	var bundle types.ClientConfig
+	require.NoError(t, json.Unmarshal(body, &bundle))
+	assert.Equal(t, ClientConfigVersion, bundle.Version)
+	assert.Equal(t, config.DefaultLimits().MaxChunkSize, bundle.Limits.MaxChunkSize)
+	assert.False(t, bundle.Features[flags.QuotaEviction])
+	assert.False(t, bundle.Features[flags.PresignedUploads], "presigned uploads are not set up")
+	require.Len(t, bundle.PublicKeys, 1)
+	assert.Equal(t, signer.KeyID(), bundle.PublicKeys[0].KeyID)
+
+	// The detached signature matches the same bundle
+	sigW := httptest.NewRecorder()
+	h.GetSignature(sigW, httptest.NewRequest(http.MethodGet, "/.well-known/gzln-config.json.sig", nil))
+	require.Equal(t, http.StatusOK, sigW.Code)
+	assert.Equal(t, w.Header().Get("ETag"), sigW.Header().Get("ETag"))
+	assert.Equal(t, w.Header().Get(ClientConfigSignatureHeader), strings.TrimSpace(sigW.Body.String()))
+}
+
+func TestClientConfig_NotModified(t *testing.T) {
+	h, _ := newTestClientConfigHandler(t)
+
+	w := httptest.NewRecorder()
+	h.GetConfig(w, httptest.NewRequest(http.MethodGet, "/.well-known/gzln-config.json", nil))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/gzln-config.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.GetConfig(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/utils"
+	"github.com/ilkin0/gzln/pkg/e2ee"
+)
+
+type ConformanceHandler struct {
+	limits config.Limits
+}
+
+func NewConformanceHandler(limits config.Limits) *ConformanceHandler {
+	return &ConformanceHandler{
+		limits: limits,
+	}
+}
+
+// GetVectors serves the protocol test vectors so alternative clients can
+// check their key derivation, chunk sealing and hashing against the server.
+func (h *ConformanceHandler) GetVectors(w http.ResponseWriter, r *http.Request) {
+	utils.Ok(w, types.ConformanceResponse{
+		ChunkLayout: types.ChunkLayout{
+			Format:        "nonce || ciphertext || tag",
+			NonceSize:     e2ee.NonceSize,
+			TagSize:       e2ee.TagSize,
+			Overhead:      e2ee.Overhead,
+			HashAlgorithm: "sha256",
+			MaxChunkSize:  h.limits.MaxChunkSize,
+		},
+		Vectors: e2ee.Vectors,
+	})
+}
@@ -2,26 +2,40 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/events"
 	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/service"
 	"github.com/ilkin0/gzln/internal/utils"
 )
 
 func (h *FileHandler) GetFileSalt(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 	shareID := chi.URLParam(r, "shareID")
-	ctx := context.Background()
+	ctx := r.Context()
 
 	log.Debug("fetching file salt",
 		slog.String("share_id", shareID),
 	)
 
 	fs, err := h.fileService.GetFileSalt(ctx, shareID)
+	if errors.Is(err, service.ErrOperationTimeout) {
+		log.Error("file salt lookup timed out",
+			slog.String("share_id", shareID),
+			slog.String("error", err.Error()),
+		)
+		utils.ErrorWithCode(w, http.StatusGatewayTimeout, OperationTimeoutCode, "Timed out fetching file salt")
+		return
+	}
 	if err != nil {
 		log.Warn("file salt not found",
 			slog.String("share_id", shareID),
@@ -36,13 +50,25 @@ func (h *FileHandler) GetFileSalt(w http.ResponseWriter, r *http.Request) {
 func (h *FileHandler) GetFileMetadata(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 	shareID := chi.URLParam(r, "shareID")
-	ctx := context.Background()
+	ctx := r.Context()
 
 	log.Info("fetching file metadata",
 		slog.String("share_id", shareID),
 	)
 
 	mdata, err := h.fileService.GetFileMetadataByShareID(ctx, shareID)
+	if errors.Is(err, service.ErrRevoked) {
+		utils.ErrorWithCode(w, http.StatusGone, ShareRevokedCode, "Share was revoked by its uploader")
+		return
+	}
+	if errors.Is(err, service.ErrOperationTimeout) {
+		log.Error("file metadata lookup timed out",
+			slog.String("share_id", shareID),
+			slog.String("error", err.Error()),
+		)
+		utils.ErrorWithCode(w, http.StatusGatewayTimeout, OperationTimeoutCode, "Timed out fetching file metadata")
+		return
+	}
 	if err != nil {
 		log.Warn("file metadata not found",
 			slog.String("share_id", shareID),
@@ -52,9 +78,214 @@ func (h *FileHandler) GetFileMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.Ok(w, mdata)
+	// Files that are not ready get no nonce; they cannot be completed anyway
+	nonce, err := h.fileService.IssueDownloadNonce(r.Context(), shareID)
+	if err != nil && !errors.Is(err, service.ErrNotReady) {
+		log.Error("failed to issue download nonce",
+			slog.String("share_id", shareID),
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to fetch file metadata")
+		return
+	}
+
+	utils.Ok(w, types.FileMetadataResponse{
+		ShareMetadata:    mdata,
+		DownloadPrefetch: h.fileService.Transfer().DownloadPrefetch,
+		DownloadNonce:    nonce,
+	})
 }
 
+// GetShareStats returns download statistics of a share to its uploader, who
+// authenticates with the deletion token as a Bearer token.
+func (h *FileHandler) GetShareStats(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	stats, err := h.fileService.GetShareStats(r.Context(), shareID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		default:
+			log.Error("failed to get share stats",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to get share stats")
+		}
+		return
+	}
+
+	utils.Ok(w, stats)
+}
+
+// RevokeShare stops all downloads of a share for the holder of its deletion
+// token. Its data stays until the share expires.
+func (h *FileHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	stats, err := h.fileService.RevokeShare(r.Context(), shareID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		case errors.Is(err, service.ErrNotReady):
+			utils.Error(w, http.StatusConflict, "Only ready shares can be revoked")
+		default:
+			log.Error("failed to revoke share",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to revoke share")
+		}
+		return
+	}
+
+	utils.Ok(w, stats)
+}
+
+// shareEventsPing is how often an idle share event stream is pinged.
+const shareEventsPing = 30 * time.Second
+
+// WatchShare streams the status of a share as server-sent events: a "status"
+// event when watching begins and after every chunk stored, counted download,
+// revocation, expiry or step of a background verification. The stream ends
+// once the share can no longer be downloaded.
+func (h *FileHandler) WatchShare(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	watch, err := h.fileService.WatchShare(r.Context(), shareID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		log.Error("failed to watch share",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to watch share")
+		return
+	}
+	defer watch.Stop()
+
+	log.Debug("watching share",
+		slog.String("share_id", shareID),
+	)
+
+	streamShareWatch(w, r, watch)
+}
+
+// streamShareWatch writes the status of watch as server-sent events until
+// the share ends or the client goes away.
+func streamShareWatch(w http.ResponseWriter, r *http.Request, watch *service.ShareWatch) {
+	sse := utils.NewSSEWriter(w)
+	if err := sse.Event("status", watch.Status); err != nil || watch.Ended() {
+		return
+	}
+
+	// Cleanup may mark the share expired well after it expires
+	var expiry <-chan time.Time
+	if !watch.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(watch.ExpiresAt))
+		defer timer.Stop()
+		expiry = timer.C
+	}
+
+	ping := time.NewTicker(shareEventsPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-watch.Events:
+			if !ok {
+				return
+			}
+			watch.Apply(e)
+		case <-expiry:
+			watch.Apply(events.Event{Type: events.TypeExpired, ShareID: watch.Status.ShareID})
+		case <-ping.C:
+			if err := sse.Ping(); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err := sse.Event("status", watch.Status); err != nil || watch.Ended() {
+			return
+		}
+	}
+}
+
+func (h *ChunkHandler) GetDownloadManifest(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	manifest, err := h.chunkService.GetDownloadManifest(r.Context(), shareID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found or has expired")
+		case errors.Is(err, service.ErrRevoked):
+			utils.ErrorWithCode(w, http.StatusGone, ShareRevokedCode, "Share was revoked by its uploader")
+		case errors.Is(err, service.ErrDownloadLimitReached):
+			utils.Error(w, http.StatusForbidden, "Download limit reached")
+		default:
+			log.Error("failed to get download manifest",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to get download manifest")
+		}
+		return
+	}
+
+	// Recipients of a link must not learn the share behind it
+	if link := service.ShareLinkFromContext(r.Context()); link != nil {
+		manifest.ShareID = link.LinkID
+	}
+
+	utils.Ok(w, manifest)
+}
+
+// ChunkMissingCode is returned with 410 when a chunk's object is gone from
+// storage and the file has been marked corrupt.
+const ChunkMissingCode = "chunk_missing"
+
+// ShareRevokedCode is returned with 410 for shares their uploader revoked.
+const ShareRevokedCode = "share_revoked"
+
+// WatermarkedShareCode is returned with 409 when a chunk of a watermarked
+// share is requested on its own; such shares are only streamed.
+const WatermarkedShareCode = "watermarked_share"
+
+// RangeNotSatisfiableCode is returned with 416 when a Range starts past the
+// end of a chunk.
+const RangeNotSatisfiableCode = "range_not_satisfiable"
+
 func (h *ChunkHandler) DownloadChunk(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 	shareID := chi.URLParam(r, "shareID")
@@ -75,26 +306,27 @@ func (h *ChunkHandler) DownloadChunk(w http.ResponseWriter, r *http.Request) {
 		slog.Int64("chunk_index", chunkIndex),
 	)
 
-	ctx := context.Background()
-	chunkReader, err := h.chunkService.DownloadChunk(ctx, shareID, chunkIndex)
+	download, err := h.chunkService.FetchChunk(r.Context(), shareID, chunkIndex, r.Header.Get("If-None-Match"))
 
-	if err != nil {
-		status := http.StatusInternalServerError
-		message := "Failed to download chunk"
-
-		errMsg := err.Error()
-		switch {
-		case strings.Contains(errMsg, "not found") || strings.Contains(errMsg, "no rows"):
-			status = http.StatusNotFound
-			message = "File not found or has expired"
-		case strings.Contains(errMsg, "limit reached"):
-			status = http.StatusForbidden
-			message = "Download limit reached"
-		case strings.Contains(errMsg, "storage path"):
-			status = http.StatusNotFound
-			message = "Chunk not found"
-		}
+	if errors.Is(err, service.ErrChunkMissing) {
+		log.Error("chunk missing from storage",
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+		)
+		utils.ErrorWithCode(w, http.StatusGone, ChunkMissingCode, "Chunk is missing from storage; the file must be uploaded again")
+		return
+	}
+	if errors.Is(err, service.ErrWatermarkedShare) {
+		utils.ErrorWithCode(w, http.StatusConflict, WatermarkedShareCode, "Share is watermarked; download it from the stream endpoint")
+		return
+	}
+	if errors.Is(err, service.ErrRevoked) {
+		utils.ErrorWithCode(w, http.StatusGone, ShareRevokedCode, "Share was revoked by its uploader")
+		return
+	}
 
+	if err != nil {
+		status, message := chunkDownloadError(err)
 		log.Error("chunk download failed",
 			slog.String("error", err.Error()),
 			slog.String("share_id", shareID),
@@ -106,14 +338,56 @@ func (h *ChunkHandler) DownloadChunk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	defer chunkReader.Close()
+	if download.NotModified {
+		w.Header().Set("ETag", download.ETag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	defer download.Body.Close()
+
+	// If-Range only keeps the range when the client's copy is current
+	body := io.Reader(download.Body)
+	rng, partial, err := utils.ParseRange(r.Header.Get("Range"), download.Size)
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != download.ETag {
+		partial, err = false, nil
+	}
+	if errors.Is(err, utils.ErrRangeNotSatisfiable) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", download.Size))
+		utils.ErrorWithCode(w, http.StatusRequestedRangeNotSatisfiable, RangeNotSatisfiableCode, "Range is outside the chunk")
+		return
+	}
 
 	log.Debug("streaming chunk data",
 		slog.String("share_id", shareID),
 		slog.Int64("chunk_index", chunkIndex),
+		slog.Bool("partial", partial),
 	)
 
-	err = utils.StreamBinary(w, chunkReader)
+	opts := []func(http.ResponseWriter){
+		utils.WithETag(download.ETag),
+		utils.WithContentLength(download.Size),
+		withAcceptRanges,
+	}
+	if partial {
+		// Chunks are at most MaxChunkSize, so skipping to the range start
+		// costs little and works for sealed and paced bodies alike
+		if _, err := io.CopyN(io.Discard, body, rng.Start); err != nil {
+			log.Error("failed to skip to chunk range",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+				slog.Int64("chunk_index", chunkIndex),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to download chunk")
+			return
+		}
+		body = io.LimitReader(body, rng.Length())
+		opts = append(opts,
+			utils.WithContentLength(rng.Length()),
+			withPartialContent(rng.ContentRange(download.Size)),
+		)
+	}
+
+	err = utils.StreamBinary(w, utils.Throttle(r.Context(), body, download.MaxRate), opts...)
 	if err != nil {
 		log.Error("failed to stream chunk",
 			slog.String("error", err.Error()),
@@ -122,6 +396,11 @@ func (h *ChunkHandler) DownloadChunk(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
+	// A download manager fetching a chunk in ranges has it once the range
+	// reaching its end is served
+	if !partial || rng.End == download.Size-1 {
+		h.recordServed(r, shareID, []int32{int32(chunkIndex)})
+	}
 
 	log.Info("chunk downloaded successfully",
 		slog.String("share_id", shareID),
@@ -129,6 +408,160 @@ func (h *ChunkHandler) DownloadChunk(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// HeadChunk answers HEAD for a chunk with its size and ETag, so download
+// managers can probe it before fetching ranges. Storage is not read.
+func (h *ChunkHandler) HeadChunk(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	chunkIndex, err := strconv.ParseInt(chi.URLParam(r, "chunkIndex"), 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	download, err := h.chunkService.StatChunk(r.Context(), shareID, chunkIndex, r.Header.Get("If-None-Match"))
+	if err != nil {
+		status, _ := chunkDownloadError(err)
+		log.Warn("chunk probe failed",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+			slog.Int("http_status", status),
+		)
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("ETag", download.ETag)
+	if download.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.FormatInt(download.Size, 10))
+	withAcceptRanges(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// chunkDownloadError maps a failed chunk lookup to a status and message.
+func chunkDownloadError(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrRevoked):
+		return http.StatusGone, "Share was revoked by its uploader"
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, "File not found or has expired"
+	case errors.Is(err, service.ErrDownloadLimitReached):
+		return http.StatusForbidden, "Download limit reached"
+	}
+	return http.StatusInternalServerError, "Failed to download chunk"
+}
+
+func withAcceptRanges(w http.ResponseWriter) {
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+// withPartialContent sends a 206 for the range in contentRange.
+func withPartialContent(contentRange string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Range", contentRange)
+		w.WriteHeader(http.StatusPartialContent)
+	}
+}
+
+// StreamFile serves all chunks of a share concatenated in order, for clients
+// that decrypt a stream but do not fetch chunks themselves. An error after the
+// headers are sent cuts the response short of its Content-Length.
+func (h *ChunkHandler) StreamFile(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	log.Info("streaming file",
+		slog.String("share_id", shareID),
+	)
+
+	stream, err := h.chunkService.StreamFile(r.Context(), shareID, getClientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found or has expired")
+		case errors.Is(err, service.ErrRevoked):
+			utils.ErrorWithCode(w, http.StatusGone, ShareRevokedCode, "Share was revoked by its uploader")
+		case errors.Is(err, service.ErrDownloadLimitReached):
+			utils.Error(w, http.StatusForbidden, "Download limit reached")
+		case errors.Is(err, service.ErrChunkMissing):
+			utils.ErrorWithCode(w, http.StatusGone, ChunkMissingCode, "Chunk is missing from storage; the file must be uploaded again")
+		case errors.Is(err, service.ErrWatermarkUnavailable):
+			utils.Error(w, http.StatusServiceUnavailable, "Share is watermarked but watermarking is not available")
+		default:
+			log.Error("failed to open file stream",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to stream file")
+		}
+		return
+	}
+	defer stream.Body.Close()
+
+	// Watermarked streams have no known size
+	opts := []func(http.ResponseWriter){utils.WithoutTimeouts}
+	if stream.Size >= 0 {
+		opts = append(opts, utils.WithContentLength(stream.Size))
+	}
+	err = utils.StreamBinary(w, utils.Throttle(r.Context(), stream.Body, stream.MaxRate), opts...)
+	if err != nil {
+		log.Error("failed to stream file",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		if stream.Size < 0 {
+			// Without a Content-Length, only a broken connection tells the
+			// client the response is incomplete
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
+	log.Info("file streamed successfully",
+		slog.String("share_id", shareID),
+		slog.Int("chunk_count", int(stream.ChunkCount)),
+		slog.Int64("size", stream.Size),
+	)
+
+	chunkIndexes := make([]int32, stream.ChunkCount)
+	for i := range chunkIndexes {
+		chunkIndexes[i] = int32(i)
+	}
+	h.recordServed(r, shareID, chunkIndexes)
+}
+
+// recordServed counts chunks served to their end against the download nonce
+// the request carries. They were sent already, so failures are only logged, and
+// a client closing the connection does not stop the download being counted.
+func (h *ChunkHandler) recordServed(r *http.Request, shareID string, chunkIndexes []int32) {
+	ctx := context.WithoutCancel(r.Context())
+	err := h.chunkService.RecordChunksServed(ctx, shareID, r.Header.Get(DownloadNonceHeader), chunkIndexes)
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to count served chunks",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+	}
+}
+
+// DownloadNonceHeader carries the nonce from the metadata response on chunk
+// and stream requests, which count towards its download, and when completing
+// it.
+const DownloadNonceHeader = "X-Download-Nonce"
+
+// DownloadIncompleteCode is returned with 409 when a download is completed
+// before every chunk was served to its nonce.
+const DownloadIncompleteCode = "download_incomplete"
+
+// CompleteDownload confirms a download. Downloads are counted as their last
+// chunk is served, so this only counts one whose counting failed then.
 func (h *FileHandler) CompleteDownload(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 	shareID := chi.URLParam(r, "shareID")
@@ -137,9 +570,21 @@ func (h *FileHandler) CompleteDownload(w http.ResponseWriter, r *http.Request) {
 		slog.String("share_id", shareID),
 	)
 
-	ctx := context.Background()
-	err := h.fileService.CompleteDownload(ctx, shareID)
+	nonce := r.Header.Get(DownloadNonceHeader)
+	err := h.fileService.CompleteDownload(r.Context(), shareID, nonce)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidDownloadNonce) {
+			utils.Error(w, http.StatusForbidden, "Invalid or expired download nonce")
+			return
+		}
+		if errors.Is(err, service.ErrDownloadLimitReached) {
+			utils.Error(w, http.StatusForbidden, "Download limit reached")
+			return
+		}
+		if errors.Is(err, service.ErrDownloadIncomplete) {
+			utils.ErrorWithCode(w, http.StatusConflict, DownloadIncompleteCode, "Every chunk must be downloaded before the download is complete")
+			return
+		}
 		log.Error("failed to complete download",
 			slog.String("error", err.Error()),
 			slog.String("share_id", shareID),
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/crypto"
 	"github.com/ilkin0/gzln/internal/database"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -23,6 +24,29 @@ func cleanupTestFiles(t *testing.T, db *database.Database) {
 	require.NoError(t, err)
 }
 
+// issueTestNonce returns a nonce every chunk of the file was served to, so
+// its download can be completed.
+func issueTestNonce(t *testing.T, handler *FileHandler, db *database.Database, shareID string) string {
+	t.Helper()
+	ctx := context.Background()
+	nonce, err := handler.fileService.IssueDownloadNonce(ctx, shareID)
+	require.NoError(t, err)
+
+	file, err := db.Queries.GetFileByShareID(ctx, shareID)
+	require.NoError(t, err)
+	chunkIndexes := make([]int32, file.ChunkCount)
+	for i := range chunkIndexes {
+		chunkIndexes[i] = int32(i)
+	}
+	_, err = db.Queries.RecordDownloadNonceChunks(ctx, sqlc.RecordDownloadNonceChunksParams{
+		ChunkIndexes: chunkIndexes,
+		NonceHash:    crypto.HashBytes([]byte(nonce)),
+		ShareID:      shareID,
+	})
+	require.NoError(t, err)
+	return nonce
+}
+
 func TestGetFileMetadata_Integration_Success(t *testing.T) {
 	handler, db, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -78,6 +102,7 @@ func TestGetFileMetadata_Integration_Success(t *testing.T) {
 	assert.Equal(t, float64(4), data["chunk_count"])
 	assert.Equal(t, float64(5), data["max_downloads"])
 	assert.Equal(t, float64(0), data["download_count"])
+	assert.NotEmpty(t, data["download_nonce"])
 }
 
 func TestGetFileMetadata_Integration_FileNotFound(t *testing.T) {
@@ -146,7 +171,8 @@ func TestGetFileMetadata_Integration_FileExpired(t *testing.T) {
 	assert.True(t, response["success"].(bool))
 
 	data := response["data"].(map[string]interface{})
-	assert.NotNil(t, data["expires_at"])
+	assert.Equal(t, now.Add(-1*time.Hour).UTC().Format(time.RFC3339), data["expires_at"])
+	assert.Equal(t, now.Add(-2*time.Hour).UTC().Format(time.RFC3339), data["created_at"])
 }
 
 func TestCompleteDownload_Integration_Success(t *testing.T) {
@@ -182,6 +208,7 @@ func TestCompleteDownload_Integration_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	req := httptest.NewRequest("POST", "/"+file.ShareID+"/complete", nil)
+	req.Header.Set(DownloadNonceHeader, issueTestNonce(t, handler, db, file.ShareID))
 	rctx := chi.NewRouteContext()
 	rctx.URLParams.Add("shareID", file.ShareID)
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
@@ -303,6 +330,7 @@ func TestCompleteDownload_Integration_LimitReached(t *testing.T) {
 	require.NoError(t, err)
 
 	req := httptest.NewRequest("POST", "/"+file.ShareID+"/complete", nil)
+	req.Header.Set(DownloadNonceHeader, issueTestNonce(t, handler, db, file.ShareID))
 	rctx := chi.NewRouteContext()
 	rctx.URLParams.Add("shareID", file.ShareID)
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
@@ -397,6 +425,7 @@ func TestCompleteDownload_Integration_MultipleDownloads(t *testing.T) {
 
 	for i := 1; i <= 3; i++ {
 		req := httptest.NewRequest("POST", "/"+file.ShareID+"/complete", nil)
+		req.Header.Set(DownloadNonceHeader, issueTestNonce(t, handler, db, file.ShareID))
 		rctx := chi.NewRouteContext()
 		rctx.URLParams.Add("shareID", file.ShareID)
 		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
@@ -424,3 +453,64 @@ func TestCompleteDownload_Integration_MultipleDownloads(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w4.Code)
 	assert.Contains(t, w4.Body.String(), "download limit")
 }
+
+func TestCompleteDownload_Integration_ReplayCountedOnce(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+	cleanupTestFiles(t, db)
+
+	ctx := context.Background()
+
+	file, err := db.Queries.CreateFile(ctx, sqlc.CreateFileParams{
+		ShareID:           "replay12345",
+		EncryptedFilename: "encrypted-filename",
+		EncryptedMimeType: "encrypted-mime",
+		Salt:              "test-salt",
+		Pbkdf2Iterations:  100000,
+		TotalSize:         1024 * 1024,
+		ChunkCount:        4,
+		ChunkSize:         256 * 1024,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(24 * time.Hour),
+			Valid: true,
+		},
+		MaxDownloads:      5,
+		DeletionTokenHash: pgtype.Text{String: "token-hash", Valid: true},
+		UploaderIp:        netip.MustParseAddr("192.168.1.1"),
+	})
+	require.NoError(t, err)
+
+	_, err = db.Queries.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     file.ID,
+		Status: "ready",
+	})
+	require.NoError(t, err)
+
+	nonce := issueTestNonce(t, handler, db, file.ShareID)
+	unserved, err := handler.fileService.IssueDownloadNonce(ctx, file.ShareID)
+	require.NoError(t, err)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("shareID", file.ShareID)
+
+	for i, tc := range []struct {
+		nonce string
+		code  int
+	}{
+		{nonce: nonce, code: http.StatusOK},
+		{nonce: nonce, code: http.StatusOK},
+		{nonce: unserved, code: http.StatusConflict},
+		{nonce: "", code: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("POST", "/"+file.ShareID+"/complete", nil)
+		req.Header.Set(DownloadNonceHeader, tc.nonce)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		handler.CompleteDownload(w, req)
+		assert.Equal(t, tc.code, w.Code, fmt.Sprintf("Request %d", i+1))
+	}
+
+	updatedFile, err := db.Queries.GetFileByShareID(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), updatedFile.DownloadCount)
+}
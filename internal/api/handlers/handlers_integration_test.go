@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/database"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/ilkin0/gzln/internal/service"
@@ -27,7 +28,7 @@ func setupTestHandler(t *testing.T) (*FileHandler, *database.Database, func()) {
 	containers := testutil.SetupTestContainers(t)
 
 	txRunner := database.NewTxRunner(containers.Database.Pool)
-	fileService := service.NewFileService(containers.Database.Queries, txRunner, containers.MinioClient.Client)
+	fileService := service.NewFileService(containers.Querier(), txRunner, containers.MinioClient.Client, config.DefaultLimits())
 	handler := NewFileHandler(fileService, containers.MinioClient.BucketName)
 
 	return handler, containers.Database, containers.Cleanup
@@ -98,6 +99,75 @@ func TestInitUpload_Integration_InvalidJSON(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Failed to parse request body")
 }
 
+func TestInitUpload_ChunkLayoutDetails(t *testing.T) {
+	limits := config.DefaultLimits()
+	limits.MaxChunkSize = 1 << 20
+	handler := NewFileHandler(service.NewFileService(nil, nil, nil, limits), "test-bucket")
+
+	tests := []struct {
+		name      string
+		chunkSize int32
+		count     int32
+		want      types.ChunkLayoutDetails
+	}{
+		{
+			name:      "chunk count mismatch",
+			chunkSize: 300 << 10,
+			count:     2,
+			want: types.ChunkLayoutDetails{
+				TotalSize:             1 << 20,
+				ChunkSize:             300 << 10,
+				ExpectedChunkCount:    4,
+				ExpectedLastChunkSize: 1<<20 - 3*(300<<10),
+				MaxChunkSize:          1 << 20,
+				MaxChunkCount:         config.DefaultLimits().MaxChunkCount,
+			},
+		},
+		{
+			name:      "chunk size above maximum",
+			chunkSize: 2 << 20,
+			count:     1,
+			want: types.ChunkLayoutDetails{
+				TotalSize:             1 << 20,
+				ChunkSize:             1 << 20,
+				ExpectedChunkCount:    1,
+				ExpectedLastChunkSize: 1 << 20,
+				MaxChunkSize:          1 << 20,
+				MaxChunkCount:         config.DefaultLimits().MaxChunkCount,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(types.InitUploadRequest{
+				Salt:              "test-salt",
+				EncryptedFilename: "encrypted-filename",
+				EncryptedMimeType: "encrypted-mime-type",
+				TotalSize:         1 << 20,
+				ChunkCount:        tt.count,
+				ChunkSize:         tt.chunkSize,
+				Pbkdf2Iterations:  100000,
+			})
+			require.NoError(t, err)
+
+			httpReq := httptest.NewRequest("POST", "/upload/init", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.InitUpload(w, httpReq)
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var resp struct {
+				Code    string                   `json:"code"`
+				Details types.ChunkLayoutDetails `json:"details"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, ChunkLayoutCode, resp.Code)
+			assert.Equal(t, tt.want, resp.Details)
+		})
+	}
+}
+
 func TestInitUpload_Integration_MissingRequiredFields(t *testing.T) {
 	handler, _, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -262,6 +332,45 @@ func TestInitUpload_Integration_DefaultValues(t *testing.T) {
 	assert.WithinDuration(t, expectedExpiry, expiryTime, 5*time.Second)
 }
 
+func TestGetUploadAdvice_Integration_Success(t *testing.T) {
+	handler, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	httpReq := httptest.NewRequest("GET", "/upload/advice?size=10485761", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetUploadAdvice(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var wrappedResp struct {
+		Success bool                       `json:"success"`
+		Data    types.UploadAdviceResponse `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &wrappedResp)
+	require.NoError(t, err)
+	require.True(t, wrappedResp.Success)
+
+	assert.Equal(t, int64(10485761), wrappedResp.Data.TotalSize)
+	assert.Equal(t, int32(5<<20), wrappedResp.Data.ChunkSize)
+	assert.Equal(t, int32(3), wrappedResp.Data.ChunkCount)
+	assert.False(t, wrappedResp.Data.Busy)
+}
+
+func TestGetUploadAdvice_Integration_InvalidSize(t *testing.T) {
+	handler, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for _, query := range []string{"", "?size=abc", "?size=0", "?size=99999999999999"} {
+		httpReq := httptest.NewRequest("GET", "/upload/advice"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetUploadAdvice(w, httpReq)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func TestFinalizeUpload_Integration_Success(t *testing.T) {
 	handler, db, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -366,8 +475,8 @@ func TestFinalizeUpload_Integration_FileNotFound(t *testing.T) {
 
 	handler.FinalizeFileUpload(w, httpReq)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "failed to get file metadata")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"file_not_found"`)
 }
 
 func TestFinalizeUpload_Integration_ChunkCountMismatch(t *testing.T) {
@@ -431,4 +540,5 @@ func TestFinalizeUpload_Integration_ChunkCountMismatch(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w2.Code)
 	assert.Contains(t, w2.Body.String(), "chunk count does not match")
+	assert.Contains(t, w2.Body.String(), `"code":"chunk_count_mismatch"`)
 }
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// CreateShareLink mints a link for one recipient of a share. Like stats, it
+// is authorized by the share's deletion token.
+func (h *FileHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req types.CreateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	link, err := h.fileService.CreateShareLink(r.Context(), shareID, token, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLinkRequest):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		case errors.Is(err, service.ErrNotReady), errors.Is(err, service.ErrTooManyShareLinks):
+			utils.Error(w, http.StatusConflict, err.Error())
+		default:
+			log.Error("failed to create share link",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to create share link")
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.APIResponse{Success: true, Data: link})
+}
+
+// ListShareLinks returns every link of a share with its download count.
+func (h *FileHandler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	links, err := h.fileService.ListShareLinks(r.Context(), shareID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		default:
+			log.Error("failed to list share links",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to list share links")
+		}
+		return
+	}
+
+	utils.Ok(w, links)
+}
+
+// RevokeShareLink ends one link of a share, leaving the share and its other
+// links as they are.
+func (h *FileHandler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+	linkID := chi.URLParam(r, "linkID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	link, err := h.fileService.RevokeShareLink(r.Context(), shareID, token, linkID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		case errors.Is(err, service.ErrShareLinkNotFound):
+			utils.Error(w, http.StatusNotFound, "Share link not found")
+		default:
+			log.Error("failed to revoke share link",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to revoke share link")
+		}
+		return
+	}
+
+	utils.Ok(w, link)
+}
+
+// ResolveShareLink lets the download routes accept a link ID in the
+// {shareID} URL parameter. It swaps the link for the share it points to and
+// attaches the link to the request context, so its limits apply and its
+// downloads are counted. Share IDs pass through unchanged.
+func (h *FileHandler) ResolveShareLink(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context())
+		linkID := chi.URLParam(r, "shareID")
+
+		if len(linkID) != service.ShareLinkIDLength {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		link, err := h.fileService.ResolveShareLink(r.Context(), linkID)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrNotFound):
+				utils.Error(w, http.StatusNotFound, "File not found or has expired")
+			case errors.Is(err, service.ErrDownloadLimitReached):
+				utils.Error(w, http.StatusForbidden, "Download limit reached")
+			default:
+				log.Error("failed to resolve share link",
+					slog.String("error", err.Error()),
+				)
+				utils.Error(w, http.StatusInternalServerError, "Failed to resolve share link")
+			}
+			return
+		}
+		if link == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setShareID(r, link.ShareID)
+		next.ServeHTTP(w, r.WithContext(service.WithShareLink(r.Context(), link)))
+	})
+}
+
+// ResolveAlias lets the download routes accept a share's alias in the
+// {shareID} URL parameter, swapping it for the share ID. It runs after
+// ResolveShareLink, so link IDs win over aliases as share IDs do. Anything
+// that is not a live alias passes through unchanged.
+func (h *FileHandler) ResolveAlias(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "shareID")
+		if !service.IsAlias(alias) || service.ShareLinkFromContext(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		shareID, err := h.fileService.ResolveAlias(r.Context(), alias)
+		if err != nil {
+			if !errors.Is(err, service.ErrNotFound) {
+				logger.FromContext(r.Context()).Error("failed to resolve alias",
+					slog.String("error", err.Error()),
+				)
+				utils.Error(w, http.StatusInternalServerError, "Failed to resolve alias")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setShareID(r, shareID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setShareID replaces the {shareID} URL parameter of r.
+func setShareID(r *http.Request, shareID string) {
+	params := &chi.RouteContext(r.Context()).URLParams
+	for i, key := range params.Keys {
+		if key == "shareID" {
+			params.Values[i] = shareID
+		}
+	}
+}
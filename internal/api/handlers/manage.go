@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+type ManageHandler struct {
+	sessionService *service.SessionService
+	exportService  *service.ExportService
+}
+
+func NewManageHandler(sessionService *service.SessionService, exportService *service.ExportService) *ManageHandler {
+	return &ManageHandler{
+		sessionService: sessionService,
+		exportService:  exportService,
+	}
+}
+
+// CreateSession exchanges the deletion token sent as a Bearer token for a
+// short-lived management session token.
+func (h *ManageHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	session, err := h.sessionService.CreateManagementSession(r.Context(), shareID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDeletionToken):
+			utils.Error(w, http.StatusForbidden, "Invalid deletion token")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		default:
+			log.Error("failed to create management session",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to create management session")
+		}
+		return
+	}
+
+	utils.Ok(w, session)
+}
+
+// ExportShares returns the metadata and download history of the shares whose
+// deletion tokens are in the body, as JSON or, with ?format=csv, as CSV.
+func (h *ManageHandler) ExportShares(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	format := r.URL.Query().Get("format")
+	if format == "" && utils.AcceptsNDJSON(r) {
+		format = "ndjson"
+	}
+	if format != "" && format != "json" && format != "csv" && format != "ndjson" {
+		utils.Error(w, http.StatusBadRequest, "Format must be json, csv or ndjson")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req types.ShareExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	export, err := h.exportService.ExportShares(r.Context(), req.DeletionTokens)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoExportTokens), errors.Is(err, service.ErrTooManyExportTokens):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Error("failed to export shares",
+				slog.String("error", err.Error()),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to export shares")
+		}
+		return
+	}
+
+	if format == "ndjson" {
+		if err := writeExportNDJSON(w, export); err != nil {
+			log.Error("failed to write export ndjson",
+				slog.String("error", err.Error()),
+			)
+		}
+		return
+	}
+
+	if format != "csv" {
+		utils.Ok(w, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="gzln-export.csv"`)
+	if err := writeExportCSV(w, export); err != nil {
+		log.Error("failed to write export csv",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// ExportUnmatchedHeader carries the number of unmatched deletion tokens in
+// NDJSON exports, whose lines are only shares.
+const ExportUnmatchedHeader = "X-Export-Unmatched"
+
+// writeExportNDJSON writes one share per line, in the same shape as the
+// shares of the JSON export.
+func writeExportNDJSON(w http.ResponseWriter, export types.ShareExportResponse) error {
+	w.Header().Set(ExportUnmatchedHeader, strconv.Itoa(export.Unmatched))
+	nw := utils.NewNDJSONWriter(w)
+	for _, share := range export.Shares {
+		if err := nw.Write(share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExportCSV writes one row per share. Download times are joined with
+// semicolons in the downloads column.
+func writeExportCSV(w http.ResponseWriter, export types.ShareExportResponse) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"share_id", "status", "total_size", "created_at", "expires_at",
+		"max_downloads", "download_count", "last_downloaded_at", "downloads",
+	})
+	for _, share := range export.Shares {
+		cw.Write([]string{
+			share.ShareID,
+			share.Status,
+			strconv.FormatInt(share.TotalSize, 10),
+			share.CreatedAt,
+			share.ExpiresAt,
+			strconv.Itoa(int(share.MaxDownloads)),
+			strconv.Itoa(int(share.DownloadCount)),
+			share.LastDownloadedAt,
+			strings.Join(share.Downloads, ";"),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
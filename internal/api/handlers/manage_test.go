@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExportNDJSON(t *testing.T) {
+	export := types.ShareExportResponse{
+		Shares: []types.ShareExport{
+			{ShareID: "share-one", Status: "ready", Downloads: []string{}},
+			{ShareID: "share-two", Status: "expired", Downloads: []string{"2026-01-01T00:00:00Z"}},
+		},
+		Unmatched: 3,
+	}
+	w := httptest.NewRecorder()
+
+	err := writeExportNDJSON(w, export)
+
+	require.NoError(t, err)
+	assert.Equal(t, utils.NDJSONContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "3", w.Header().Get(ExportUnmatchedHeader))
+	assert.True(t, w.Flushed, "lines should be flushed as they are written")
+
+	var shares []types.ShareExport
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var share types.ShareExport
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &share))
+		shares = append(shares, share)
+	}
+	assert.Equal(t, export.Shares, shares)
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "application/json", want: false},
+		{accept: "application/x-ndjson", want: true},
+		{accept: "application/json;q=0.5, application/x-ndjson", want: true},
+		{accept: "Application/X-NDJSON; charset=utf-8", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/export", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			assert.Equal(t, tt.want, utils.AcceptsNDJSON(r))
+		})
+	}
+}
+
+// revokeQuerier serves a single ready share and records its revocation.
+type revokeQuerier struct {
+	sqlc.Querier
+	file    sqlc.File
+	revoked bool
+}
+
+func (q *revokeQuerier) GetFileByShareID(ctx context.Context, shareID string) (sqlc.File, error) {
+	return q.file, nil
+}
+
+func (q *revokeQuerier) RevokeFile(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	q.revoked = true
+	file := q.file
+	file.Status = service.FileStatusRevoked
+	return file, nil
+}
+
+func (q *revokeQuerier) CreateAuditLogEntry(ctx context.Context, arg sqlc.CreateAuditLogEntryParams) (sqlc.AuditLog, error) {
+	return sqlc.AuditLog{}, nil
+}
+
+func TestRevokeShare_ManagementSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionFor  string
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{name: "session for the share", sessionFor: "abc123def456", wantStatus: http.StatusOK, wantRevoked: true},
+		{name: "session for another share", sessionFor: "zzz999yyy888", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &revokeQuerier{file: sqlc.File{
+				ID:                pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+				ShareID:           tt.sessionFor,
+				Status:            "ready",
+				DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+			}}
+			sessions := service.NewSessionService(q, []byte("session-secret"), time.Minute)
+			session, err := sessions.CreateManagementSession(context.Background(), tt.sessionFor, "deletion-token")
+			require.NoError(t, err)
+
+			q.file.ShareID = "abc123def456"
+			fileService := service.NewFileService(q, nil, nil, config.DefaultLimits()).
+				WithManagementSessions(sessions)
+			router := chi.NewRouter()
+			router.Post("/{shareID}/revoke", NewFileHandler(fileService, "test-bucket").RevokeShare)
+
+			r := httptest.NewRequest(http.MethodPost, "/abc123def456/revoke", nil)
+			r.Header.Set("Authorization", "Bearer "+session.SessionToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantRevoked, q.revoked)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// maxPasteRequestSize fits the base64 of the largest paste and the other
+// fields.
+const maxPasteRequestSize = service.MaxPasteSize*4/3 + 4<<10
+
+type PasteHandler struct {
+	pasteService *service.PasteService
+}
+
+func NewPasteHandler(pasteService *service.PasteService) *PasteHandler {
+	return &PasteHandler{
+		pasteService: pasteService,
+	}
+}
+
+func (h *PasteHandler) CreatePaste(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteRequestSize)
+	var req types.CreatePasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.Error(w, http.StatusRequestEntityTooLarge, "Paste is too large, upload it as a file instead")
+			return
+		}
+		log.Warn("invalid JSON in paste request",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	paste, err := h.pasteService.CreatePaste(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPasteRequest) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrShareIDExhausted) {
+			utils.Error(w, http.StatusServiceUnavailable, "Could not allocate a share ID, please retry")
+			return
+		}
+		log.Error("failed to create paste",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to create paste")
+		return
+	}
+
+	utils.Ok(w, paste)
+}
+
+// ReadPaste returns the paste and counts the read as a download.
+func (h *PasteHandler) ReadPaste(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	paste, err := h.pasteService.ReadPaste(r.Context(), shareID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "Paste not found")
+		case errors.Is(err, service.ErrExpired):
+			utils.Error(w, http.StatusGone, "Paste has expired")
+		case errors.Is(err, service.ErrDownloadLimitReached):
+			utils.Error(w, http.StatusForbidden, "Download limit reached")
+		case errors.Is(err, readonly.ErrReadOnly):
+			readonly.Reject(w)
+		default:
+			log.Error("failed to read paste",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to read paste")
+		}
+		return
+	}
+
+	utils.Ok(w, paste)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+type SupportHandler struct {
+	adminService *service.AdminService
+}
+
+func NewSupportHandler(adminService *service.AdminService) *SupportHandler {
+	return &SupportHandler{adminService: adminService}
+}
+
+// PreviewShare shows support staff a share's metadata and event history,
+// authorized by a preview token sent as a Bearer token.
+func (h *SupportHandler) PreviewShare(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	shareID := chi.URLParam(r, "shareID")
+
+	token := bearerToken(r)
+	if token == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	preview, err := h.adminService.PreviewShare(r.Context(), shareID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPreviewToken):
+			utils.Error(w, http.StatusForbidden, "Invalid preview token")
+		case errors.Is(err, service.ErrPreviewTokenExpired):
+			utils.Error(w, http.StatusUnauthorized, "Preview token expired")
+		case errors.Is(err, service.ErrNotFound):
+			utils.Error(w, http.StatusNotFound, "File not found")
+		case errors.Is(err, service.ErrSupportPreviewsOff):
+			utils.Error(w, http.StatusServiceUnavailable, "Support previews are not enabled")
+		default:
+			log.Error("failed to preview share",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to preview share")
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.Ok(w, preview)
+}
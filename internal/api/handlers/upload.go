@@ -1,22 +1,25 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/ilkin0/gzln/internal/abuse"
 	"github.com/ilkin0/gzln/internal/api/types"
 	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/middleware"
 	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/spool"
 	"github.com/ilkin0/gzln/internal/utils"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
@@ -64,7 +67,7 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	ext := filepath.Ext(header.Filename)
 	objectname := fmt.Sprintf("%s%s", fileID, ext)
 
-	ctx := context.Background()
+	ctx := r.Context()
 	info, err := h.fileService.GetMinIOClient().PutObject(
 		ctx,
 		h.bucketName,
@@ -88,7 +91,7 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		FileName:    header.Filename,
 		Size:        info.Size,
 		ContentType: header.Header.Get("Content-Type"),
-		UploadedAt:  time.Now(),
+		UploadedAt:  time.Now().UTC().Format(time.RFC3339),
 		URL:         fmt.Sprintf("/api/v1/files/%s", fileID+ext),
 	}
 
@@ -101,38 +104,161 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// chunkFormFields are the text fields a chunk upload may carry, with the most
+// bytes each may hold: a chunk index is at most ten digits and a hash is hex.
+var chunkFormFields = map[string]int{
+	"chunk_index": 16,
+	"hash":        128,
+}
+
+// InvalidFormFieldCode marks chunk uploads with an unknown, repeated or
+// oversized form field.
+const InvalidFormFieldCode = "invalid_form_field"
+
+// chunkFieldError rejects a form field of a chunk upload before it is read
+// further.
+type chunkFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *chunkFieldError) Error() string {
+	return fmt.Sprintf("form field %q %s", e.Field, e.Reason)
+}
+
+// chunkForm is a parsed chunk upload. Close removes the chunk's spilled
+// file, if any.
+type chunkForm struct {
+	chunk       *spool.Part
+	filename    string
+	contentType string
+	fields      map[string]string
+}
+
+func (f *chunkForm) Close() {
+	if f.chunk != nil {
+		f.chunk.Close()
+	}
+}
+
+// readPart reads one part of a chunk upload. Text fields outside
+// chunkFormFields, and fields sent twice, are rejected unread.
+func (f *chunkForm) readPart(part *multipart.Part, spooler *spool.Spooler, maxSize int64) error {
+	name := part.FormName()
+	if name == "chunk" {
+		if f.chunk != nil {
+			return &chunkFieldError{Field: name, Reason: "is sent more than once"}
+		}
+		f.filename = part.FileName()
+		f.contentType = part.Header.Get("Content-Type")
+		var err error
+		f.chunk, err = spooler.Spool(part, maxSize)
+		return err
+	}
+
+	maxLen, ok := chunkFormFields[name]
+	if !ok {
+		return &chunkFieldError{Field: name, Reason: "is not allowed"}
+	}
+	if _, seen := f.fields[name]; seen {
+		return &chunkFieldError{Field: name, Reason: "is sent more than once"}
+	}
+
+	value, err := io.ReadAll(io.LimitReader(part, int64(maxLen)+1))
+	if err != nil {
+		return err
+	}
+	if len(value) > maxLen {
+		return &chunkFieldError{Field: name, Reason: fmt.Sprintf("is longer than %d bytes", maxLen)}
+	}
+	f.fields[name] = string(value)
+	return nil
+}
+
+// readChunkForm reads a chunk upload part by part instead of through
+// ParseMultipartForm, so the chunk is kept in memory or spilled to disk under
+// the configured limits and every other field stays small.
+func readChunkForm(r *http.Request, spooler *spool.Spooler, maxSize int64) (*chunkForm, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &chunkForm{fields: make(map[string]string)}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			form.Close()
+			return nil, err
+		}
+
+		err = form.readPart(part, spooler, maxSize)
+		part.Close()
+		if err != nil {
+			form.Close()
+			return nil, err
+		}
+	}
+}
+
 func (h *ChunkHandler) HandleChunkUpload(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 
-	// TODO validate token??
-	err := r.ParseForm()
-	if err != nil {
-		log.Warn("failed to parse form",
-			slog.String("error", err.Error()),
+	// Reject oversized chunks before anything is buffered
+	maxRequestSize := h.chunkService.MaxChunkRequestSize()
+	if r.ContentLength > maxRequestSize {
+		log.Warn("chunk upload request too large",
+			slog.Int64("content_length", r.ContentLength),
+			slog.Int64("max_request_size", maxRequestSize),
 		)
-		utils.Error(w, http.StatusBadRequest, "Failed to parse form")
+		utils.Error(w, http.StatusRequestEntityTooLarge, "Chunk too large")
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 
-	authToken := r.Header.Get("Authorization")
-	if authToken == "" {
-		log.Warn("missing authorization header")
-		utils.Error(w, http.StatusUnauthorized, "Authorization required")
-		return
-	}
+	multipartUploads := h.chunkService.Multipart()
+	spooler := spool.New(multipartUploads.MemoryBytes, multipartUploads.TempDir)
 
-	/// TODO add max chunk Size validation
-	file, header, err := r.FormFile("chunk")
+	// Parsing reads the whole body, so it times the transfer from the client
+	receiveStart := time.Now()
+	form, err := readChunkForm(r, spooler, maxRequestSize)
+	receiveDuration := time.Since(receiveStart)
 	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "File chunk is missing")
+		var maxBytesErr *http.MaxBytesError
+		var fieldErr *chunkFieldError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, spool.ErrTooLarge):
+			log.Warn("chunk upload request too large",
+				slog.Int64("max_request_size", maxRequestSize),
+			)
+			utils.Error(w, http.StatusRequestEntityTooLarge, "Chunk too large")
+		case errors.Is(err, spool.ErrDisk):
+			log.Error("failed to spool chunk to disk",
+				slog.String("error", err.Error()),
+				slog.String("temp_dir", multipartUploads.TempDir),
+			)
+			utils.Error(w, http.StatusInsufficientStorage, "Failed to receive chunk")
+		case errors.As(err, &fieldErr):
+			log.Warn("invalid chunk upload form field",
+				slog.String("field", fieldErr.Field),
+				slog.String("reason", fieldErr.Reason),
+			)
+			utils.ErrorWithCode(w, http.StatusBadRequest, InvalidFormFieldCode, err.Error())
+		default:
+			log.Warn("failed to parse form",
+				slog.String("error", err.Error()),
+			)
+			utils.Error(w, http.StatusBadRequest, "Failed to parse form")
+		}
 		return
 	}
-	defer file.Close()
+	defer form.Close()
 
-	// Validate Hash
-	chunkBytes, err := io.ReadAll(file)
-	if err != nil {
-		utils.Error(w, http.StatusInternalServerError, "Failed to read chunk")
+	if form.chunk == nil {
+		utils.Error(w, http.StatusBadRequest, "File chunk is missing")
 		return
 	}
 
@@ -148,7 +274,7 @@ func (h *ChunkHandler) HandleChunkUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	chunkIndexStr := r.FormValue("chunk_index")
+	chunkIndexStr := form.fields["chunk_index"]
 	chunkIndex64, err := strconv.ParseInt(chunkIndexStr, 10, 32)
 	if err != nil {
 		log.Warn("invalid chunk index",
@@ -162,27 +288,30 @@ func (h *ChunkHandler) HandleChunkUpload(w http.ResponseWriter, r *http.Request)
 	log.Info("processing chunk upload",
 		slog.String("file_id", fileIDStr),
 		slog.Int64("chunk_index", chunkIndex64),
-		slog.Int64("chunk_size", int64(len(chunkBytes))),
+		slog.Int64("chunk_size", form.chunk.Size()),
+		slog.Bool("spilled", form.chunk.Spilled()),
 	)
 
-	ctx := context.Background()
 	req := types.ChunkUploadRequest{
 		FileID:       fileID,
 		ChunkIndex:   chunkIndex64,
-		ChunkData:    chunkBytes,
-		ExpectedHash: r.FormValue("hash"),
-		ContentType:  header.Header.Get("Content-Type"),
-		Filename:     header.Filename,
+		Chunk:        form.chunk,
+		Size:         form.chunk.Size(),
+		ExpectedHash: form.fields["hash"],
+		ContentType:  form.contentType,
+		Filename:     form.filename,
+
+		ReceiveDuration: receiveDuration,
 	}
-	result, err := h.chunkService.ProcessChunkUpload(ctx, req)
+	result, err := h.chunkService.ProcessChunkUpload(r.Context(), req)
 	if err != nil {
 		log.Error("chunk upload failed",
 			slog.String("error", err.Error()),
 			slog.String("file_id", fileIDStr),
 			slog.Int64("chunk_index", chunkIndex64),
 		)
-		status := mapServiceErrorToHTTP(err)
-		utils.Error(w, status, err.Error())
+		status, code := mapServiceErrorToHTTP(err)
+		utils.ErrorWithCode(w, status, code, err.Error())
 		return
 	}
 
@@ -192,13 +321,247 @@ func (h *ChunkHandler) HandleChunkUpload(w http.ResponseWriter, r *http.Request)
 		slog.String("hash", result.ReceivedHash),
 	)
 
-	utils.Ok(w, types.ChunkUploadResponse{
-		ChunkIndex:   result.ChunkIndex,
-		Status:       result.Status,
-		ReceivedHash: result.ReceivedHash,
-	})
+	utils.Ok(w, result)
+}
+
+// ConfirmChunkUpload records a chunk the client uploaded straight to storage
+// through a presigned URL.
+func (h *ChunkHandler) ConfirmChunkUpload(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	fileIDStr := chi.URLParam(r, "fileID")
+	var fileID pgtype.UUID
+	if err := fileID.Scan(fileIDStr); err != nil {
+		log.Warn("invalid file ID",
+			slog.String("file_id_str", fileIDStr),
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	chunkIndexStr := chi.URLParam(r, "chunkIndex")
+	chunkIndex64, err := strconv.ParseInt(chunkIndexStr, 10, 32)
+	if err != nil {
+		log.Warn("invalid chunk index",
+			slog.String("chunk_index_str", chunkIndexStr),
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Invalid chunk index")
+		return
+	}
+
+	var req types.ChunkConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	result, err := h.chunkService.ConfirmChunkUpload(r.Context(), fileID, chunkIndex64, req.Hash)
+	if err != nil {
+		log.Error("chunk confirmation failed",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileIDStr),
+			slog.Int64("chunk_index", chunkIndex64),
+		)
+		status, code := mapServiceErrorToHTTP(err)
+		utils.ErrorWithCode(w, status, code, err.Error())
+		return
+	}
+
+	utils.Ok(w, result)
+}
+
+// GetChunkUploadURLs presigns the next batch of chunk uploads for a
+// presigned upload, from the chunk in the from query parameter on.
+func (h *ChunkHandler) GetChunkUploadURLs(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	fileIDStr := chi.URLParam(r, "fileID")
+	var fileID pgtype.UUID
+	if err := fileID.Scan(fileIDStr); err != nil {
+		log.Warn("invalid file ID",
+			slog.String("file_id_str", fileIDStr),
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 32)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "from must be a chunk index")
+		return
+	}
+
+	result, err := h.chunkService.ChunkUploadURLs(r.Context(), fileID, int32(from))
+	if err != nil {
+		log.Error("failed to presign chunk uploads",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileIDStr),
+			slog.Int64("from", from),
+		)
+		status, code := mapServiceErrorToHTTP(err)
+		utils.ErrorWithCode(w, status, code, err.Error())
+		return
+	}
+
+	utils.Ok(w, result)
+}
+
+func (h *ChunkHandler) GetChunkStatus(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	fileIDStr := chi.URLParam(r, "fileID")
+	var fileID pgtype.UUID
+	err := fileID.Scan(fileIDStr)
+	if err != nil {
+		log.Warn("invalid file ID",
+			slog.String("file_id_str", fileIDStr),
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	progress, err := h.chunkService.GetUploadProgress(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		log.Error("failed to get upload progress",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileIDStr),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get upload progress")
+		return
+	}
+
+	utils.Ok(w, progress)
 }
 
+func (h *ChunkHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	fileIDStr := chi.URLParam(r, "fileID")
+	var fileID pgtype.UUID
+	if err := fileID.Scan(fileIDStr); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	status, err := h.chunkService.GetUploadStatus(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		log.Error("failed to get upload status",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileIDStr),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get upload status")
+		return
+	}
+
+	utils.Ok(w, status)
+}
+
+// WatchUpload streams the status of an upload's share like WatchShare, so
+// the uploader can follow its chunks being stored, the finalize and the
+// downloads that follow without polling.
+func (h *FileHandler) WatchUpload(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	fileIDStr := chi.URLParam(r, "fileID")
+	var fileID pgtype.UUID
+	if err := fileID.Scan(fileIDStr); err != nil {
+		utils.Error(w, http.StatusBadRequest, "Invalid file ID")
+		return
+	}
+
+	watch, err := h.fileService.WatchUpload(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		log.Error("failed to watch upload",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileIDStr),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to watch upload")
+		return
+	}
+	defer watch.Stop()
+
+	log.Debug("watching upload",
+		slog.String("file_id", fileIDStr),
+	)
+
+	streamShareWatch(w, r, watch)
+}
+
+func (h *FileHandler) GetUploadAdvice(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	size, err := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "Invalid size")
+		return
+	}
+
+	advice, err := h.fileService.GetUploadAdvice(r.Context(), size)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUploadSize) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error("failed to get upload advice",
+			slog.String("error", err.Error()),
+			slog.Int64("total_size", size),
+		)
+		utils.Error(w, http.StatusInternalServerError, "Failed to get upload advice")
+		return
+	}
+
+	utils.Ok(w, advice)
+}
+
+// ChunkLayoutCode marks init failures whose details hold a chunk layout the
+// server would accept.
+const ChunkLayoutCode = "invalid_chunk_layout"
+
+// UploadQuotaCode marks init failures over the uploader IP's daily quota.
+// Their details hold the quota, what was used and when it resets.
+const UploadQuotaCode = "upload_quota_exceeded"
+
+// Upload init responses carry what is left of the uploader IP's daily quota
+// in these headers, so clients can pace themselves. Each is only set while
+// its limit is enforced.
+const (
+	UploadsRemainingHeader    = "X-Uploads-Remaining"
+	QuotaRemainingBytesHeader = "X-Quota-Remaining-Bytes"
+)
+
+// Codes for upload inits turned away by abuse scoring.
+const (
+	UploadDeniedCode      = "upload_denied"
+	ChallengeRequiredCode = "challenge_required"
+)
+
+// AliasTakenCode marks init failures whose alias a live share already has.
+const AliasTakenCode = "alias_taken"
+
+// UnfinishedUploadsCode marks init failures of an IP flagged for leaving
+// uploads unfinished, while it still has one in progress.
+const UnfinishedUploadsCode = "unfinished_uploads"
+
+// ActiveSharesCode marks init failures of an IP that already has as many
+// active shares as UPLOAD_QUOTA_ACTIVE_SHARES allows.
+const ActiveSharesCode = "active_shares_exceeded"
+
 func (h *FileHandler) InitUpload(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 
@@ -219,7 +582,10 @@ func (h *FileHandler) InitUpload(w http.ResponseWriter, r *http.Request) {
 		slog.String("client_ip", clientIP),
 	)
 
-	ctx := context.Background()
+	ctx := abuse.WithMetadata(r.Context(), abuse.Metadata{
+		UserAgent: r.UserAgent(),
+		Origin:    r.Header.Get("Origin"),
+	})
 	response, err := h.fileService.InitFileUpload(ctx, req, clientIP)
 	if err != nil {
 		log.Error("failed to initialize upload",
@@ -228,6 +594,54 @@ func (h *FileHandler) InitUpload(w http.ResponseWriter, r *http.Request) {
 			slog.Int64("total_size", req.TotalSize),
 			slog.Int("chunk_count", int(req.ChunkCount)),
 		)
+		switch {
+		case errors.Is(err, service.ErrUploadDenied):
+			utils.ErrorWithCode(w, http.StatusForbidden, UploadDeniedCode, "Upload denied")
+			return
+		case errors.Is(err, service.ErrUploadChallenged):
+			utils.ErrorWithCode(w, http.StatusForbidden, ChallengeRequiredCode, "Upload requires additional verification")
+			return
+		case errors.Is(err, service.ErrInvalidUploadSlot):
+			utils.Error(w, http.StatusForbidden, "Upload slot is invalid, used, expired or too small")
+			return
+		case errors.Is(err, service.ErrInvalidBundleToken):
+			utils.Error(w, http.StatusForbidden, "Bundle token is invalid or the bundle has expired")
+			return
+		case errors.Is(err, service.ErrBundleFull):
+			utils.Error(w, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, service.ErrAliasTaken):
+			utils.ErrorWithCode(w, http.StatusConflict, AliasTakenCode, "Alias is already taken")
+			return
+		case errors.Is(err, service.ErrStorageFull):
+			utils.Error(w, http.StatusInsufficientStorage, "Not enough storage space for this upload")
+			return
+		case errors.Is(err, service.ErrUnfinishedUploads):
+			utils.ErrorWithCode(w, http.StatusTooManyRequests, UnfinishedUploadsCode, "Finish or abandon your unfinished upload first")
+			return
+		case errors.Is(err, service.ErrTooManyActiveShares):
+			utils.ErrorWithCode(w, http.StatusTooManyRequests, ActiveSharesCode, "Too many active shares, delete one or wait for one to expire")
+			return
+		case errors.Is(err, service.ErrShareIDExhausted):
+			utils.Error(w, http.StatusServiceUnavailable, "Could not allocate a share ID, please retry")
+			return
+		case errors.Is(err, service.ErrOperationTimeout):
+			utils.ErrorWithCode(w, http.StatusGatewayTimeout, OperationTimeoutCode, "Timed out initializing upload, please retry")
+			return
+		}
+		var layoutErr *service.ChunkLayoutError
+		if errors.As(err, &layoutErr) {
+			utils.ErrorWithDetails(w, http.StatusBadRequest, ChunkLayoutCode, err.Error(), layoutErr.Details)
+			return
+		}
+		var quotaErr *service.UploadQuotaError
+		if errors.As(err, &quotaErr) {
+			retryAfter := int(time.Until(quotaErr.ResetsAt).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			setUploadQuotaHeaders(w, quotaErr.Details)
+			utils.ErrorWithDetails(w, http.StatusTooManyRequests, UploadQuotaCode, "Daily upload quota exceeded", quotaErr.Details)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -237,9 +651,74 @@ func (h *FileHandler) InitUpload(w http.ResponseWriter, r *http.Request) {
 		slog.String("file_id", response.FileID),
 	)
 
+	// The upload went through either way, so it is not failed for this
+	usage, err := h.fileService.UploadQuotaUsage(r.Context(), clientIP)
+	if err != nil {
+		log.Warn("failed to get upload quota usage",
+			slog.String("error", err.Error()),
+			slog.String("client_ip", clientIP),
+		)
+	} else if usage != nil {
+		setUploadQuotaHeaders(w, *usage)
+	}
+
 	utils.Ok(w, response)
 }
 
+func setUploadQuotaHeaders(w http.ResponseWriter, usage types.UploadQuotaDetails) {
+	if usage.DailyCount > 0 {
+		w.Header().Set(UploadsRemainingHeader, strconv.FormatInt(usage.RemainingCount(), 10))
+	}
+	if usage.DailyBytes > 0 {
+		w.Header().Set(QuotaRemainingBytesHeader, strconv.FormatInt(usage.RemainingBytes(), 10))
+	}
+}
+
+// CreateUploadSlot lets a trusted backend, authenticated by its API key,
+// reserve an upload whose one-time token it hands to a browser.
+func (h *FileHandler) CreateUploadSlot(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	apiKey := bearerToken(r)
+	if apiKey == "" {
+		log.Warn("missing authorization header")
+		utils.Error(w, http.StatusUnauthorized, "Authorization required")
+		return
+	}
+
+	var req types.CreateUploadSlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid JSON in upload slot request",
+			slog.String("error", err.Error()),
+		)
+		utils.Error(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	slot, err := h.fileService.CreateUploadSlot(r.Context(), apiKey, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUploadSlotsDisabled):
+			utils.Error(w, http.StatusNotFound, "Upload slots are not enabled")
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			log.Warn("invalid upload slot API key",
+				slog.String("client_ip", getClientIP(r)),
+			)
+			utils.Error(w, http.StatusForbidden, "Invalid API key")
+		case errors.Is(err, service.ErrInvalidSlotRequest):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Error("failed to create upload slot",
+				slog.String("error", err.Error()),
+			)
+			utils.Error(w, http.StatusInternalServerError, "Failed to create upload slot")
+		}
+		return
+	}
+
+	utils.Ok(w, slot)
+}
+
 func (h *FileHandler) FinalizeFileUpload(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 
@@ -259,15 +738,25 @@ func (h *FileHandler) FinalizeFileUpload(w http.ResponseWriter, r *http.Request)
 		slog.String("file_id", fileIDStr),
 	)
 
-	ctx := context.Background()
+	ctx := r.Context()
 	ures, err := h.fileService.FinalizeUpload(ctx, fileID)
 	if err != nil {
 		log.Error("failed to finalize upload",
 			slog.String("error", err.Error()),
 			slog.String("file_id", fileIDStr),
 		)
-		status := mapServiceErrorToHTTP(err)
-		utils.Error(w, status, err.Error())
+		status, code := mapServiceErrorToHTTP(err)
+		utils.ErrorWithCode(w, status, code, err.Error())
+		return
+	}
+
+	if ures.Verifying {
+		// The share is ready once its chunks are verified in the background
+		log.Info("upload verifying",
+			slog.String("file_id", fileIDStr),
+			slog.String("share_id", ures.ShareID),
+		)
+		utils.WriteJSON(w, http.StatusAccepted, utils.APIResponse{Success: true, Data: ures})
 		return
 	}
 
@@ -279,32 +768,61 @@ func (h *FileHandler) FinalizeFileUpload(w http.ResponseWriter, r *http.Request)
 	utils.Ok(w, ures)
 }
 
-func mapServiceErrorToHTTP(err error) int {
-	errMsg := err.Error()
-	switch {
-	case strings.Contains(errMsg, "already uploaded"):
-		return http.StatusConflict
-	case strings.Contains(errMsg, "invalid"):
-		return http.StatusBadRequest
-	case strings.Contains(errMsg, "hash mismatch"):
-		return http.StatusBadRequest
-	case strings.Contains(errMsg, "not found"):
-		return http.StatusNotFound
-	case strings.Contains(errMsg, "not in uploading state"):
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
-	}
+// Codes for chunk upload and finalize failures.
+const (
+	ChunkAlreadyUploadedCode = "chunk_already_uploaded"
+	HashMismatchCode         = "hash_mismatch"
+	InvalidChunkCode         = "invalid_chunk"
+	InvalidChunkIndexCode    = "invalid_chunk_index"
+	InvalidChunkSizeCode     = "invalid_chunk_size"
+	ChunkNotUploadedCode     = "chunk_not_uploaded"
+	ChunkCountMismatchCode   = "chunk_count_mismatch"
+	ChunkMismatchCode        = "chunk_mismatch"
+	NotUploadingCode         = "not_uploading"
+	PresignedDisabledCode    = "presigned_disabled"
+	FileNotFoundCode         = "file_not_found"
+	StorageTimeoutCode       = "storage_timeout"
+	OperationTimeoutCode     = "operation_timeout"
+)
+
+// serviceErrors maps upload service errors to their status and code, most
+// specific first.
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{service.ErrChunkAlreadyUploaded, http.StatusConflict, ChunkAlreadyUploadedCode},
+	{service.ErrHashMismatch, http.StatusBadRequest, HashMismatchCode},
+	{service.ErrInvalidChunk, http.StatusBadRequest, InvalidChunkCode},
+	{service.ErrInvalidChunkIndex, http.StatusBadRequest, InvalidChunkIndexCode},
+	{service.ErrInvalidChunkSize, http.StatusBadRequest, InvalidChunkSizeCode},
+	{service.ErrChunkNotUploaded, http.StatusNotFound, ChunkNotUploadedCode},
+	{service.ErrChunkCountMismatch, http.StatusBadRequest, ChunkCountMismatchCode},
+	{service.ErrChunkMismatch, http.StatusUnprocessableEntity, ChunkMismatchCode},
+	{service.ErrNotUploading, http.StatusBadRequest, NotUploadingCode},
+	{service.ErrPresignedDisabled, http.StatusBadRequest, PresignedDisabledCode},
+	{service.ErrNotFound, http.StatusNotFound, FileNotFoundCode},
+	{service.ErrStorageTimeout, http.StatusGatewayTimeout, StorageTimeoutCode},
+	{service.ErrOperationTimeout, http.StatusGatewayTimeout, OperationTimeoutCode},
 }
 
-func getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
+// mapServiceErrorToHTTP returns the status and code of an upload service
+// error. Unexpected errors are 500 without a code.
+func mapServiceErrorToHTTP(err error) (int, string) {
+	for _, e := range serviceErrors {
+		if errors.Is(err, e.err) {
+			return e.status, e.code
+		}
 	}
+	return http.StatusInternalServerError, ""
+}
 
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// getClientIP returns the client address quotas and abuse scoring are keyed
+// on. Forwarding headers only count from trusted proxies.
+func getClientIP(r *http.Request) string {
+	if addr, ok := middleware.ClientAddr(r); ok {
+		return addr.String()
 	}
-
 	return r.RemoteAddr
 }
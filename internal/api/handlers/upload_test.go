@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClientIP_IgnoresUntrustedForwarding(t *testing.T) {
+	middleware.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	t.Cleanup(func() { middleware.SetTrustedProxies(nil) })
+
+	direct := httptest.NewRequest(http.MethodPost, "/upload/init", nil)
+	direct.RemoteAddr = "203.0.113.7:5000"
+	direct.Header.Set("X-Forwarded-For", "198.51.100.1")
+	direct.Header.Set("X-Real-IP", "198.51.100.2")
+	assert.Equal(t, "203.0.113.7", getClientIP(direct))
+
+	proxied := httptest.NewRequest(http.MethodPost, "/upload/init", nil)
+	proxied.RemoteAddr = "10.0.0.2:5000"
+	proxied.Header.Set("X-Forwarded-For", "192.0.2.9, 198.51.100.1")
+	assert.Equal(t, "198.51.100.1", getClientIP(proxied))
+}
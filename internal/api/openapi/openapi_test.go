@@ -0,0 +1,100 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/api/routes"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type document struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]json.RawMessage `json:"components"`
+}
+
+func parseSpec(t *testing.T) document {
+	t.Helper()
+	var doc document
+	require.NoError(t, json.Unmarshal(Spec(), &doc))
+	return doc
+}
+
+func TestSpec_Version(t *testing.T) {
+	doc := parseSpec(t)
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."), "got openapi %q", doc.OpenAPI)
+}
+
+func TestSpec_DocumentsEveryRoute(t *testing.T) {
+	doc := parseSpec(t)
+	fileService := service.NewFileService(nil, nil, nil, config.DefaultLimits())
+	chunkService := service.NewChunkService(nil, nil, "test-bucket", config.DefaultLimits())
+
+	mounts := map[string]chi.Router{
+		"/files":    routes.FileRoutes(fileService, chunkService, "test-bucket"),
+		"/download": routes.DownloadRoutes(fileService, chunkService, "test-bucket"),
+	}
+	for prefix, router := range mounts {
+		err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			path := prefix + route
+			_, ok := doc.Paths[path][strings.ToLower(method)]
+			assert.True(t, ok, "%s %s is not in openapi.json", method, path)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestSpec_RefsResolve(t *testing.T) {
+	doc := parseSpec(t)
+
+	for _, ref := range findRefs(t, Spec()) {
+		parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+		require.Len(t, parts, 2, "unexpected $ref %q", ref)
+		_, ok := doc.Components[parts[0]][parts[1]]
+		assert.True(t, ok, "$ref %q does not resolve", ref)
+	}
+}
+
+func findRefs(t *testing.T, raw []byte) []string {
+	t.Helper()
+	var v any
+	require.NoError(t, json.Unmarshal(raw, &v))
+
+	var refs []string
+	var walk func(any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if ref, ok := child.(string); ok && key == "$ref" {
+					refs = append(refs, ref)
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return refs
+}
+
+func TestUIHandler_PointsAtSpec(t *testing.T) {
+	w := httptest.NewRecorder()
+	UIHandler("/api/v1/openapi.json").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `"/api/v1/openapi.json"`)
+}
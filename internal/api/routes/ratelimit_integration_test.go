@@ -1,11 +1,10 @@
-package routes
+package routes_test
 
 import (
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -13,19 +12,20 @@ import (
 	"testing"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/app"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
 	"github.com/ilkin0/gzln/internal/database"
 	"github.com/ilkin0/gzln/internal/middleware"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
-	"github.com/ilkin0/gzln/internal/service"
 	"github.com/ilkin0/gzln/internal/testutil"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupRateLimitTest(t *testing.T) (chi.Router, *database.Database, func()) {
+func setupRateLimitTest(t *testing.T) (http.Handler, *database.Database, func()) {
 	t.Helper()
 
 	t.Setenv("RATE_LIMIT_UPLOAD_INIT", "3")
@@ -40,15 +40,16 @@ func setupRateLimitTest(t *testing.T) (chi.Router, *database.Database, func()) {
 
 	containers := testutil.SetupTestContainers(t)
 
-	runTx := database.NewTxRunner(containers.Database.Pool)
-	fileService := service.NewFileService(containers.Database.Queries, runTx, containers.MinioClient.Client)
-	chunkService := service.NewChunkService(containers.Database.Queries, containers.MinioClient.Client, containers.MinioClient.BucketName)
+	cfg, err := config.Load()
+	require.NoError(t, err)
 
-	r := chi.NewRouter()
-	r.Mount("/api/v1/files", FileRoutes(fileService, chunkService, containers.MinioClient.BucketName))
-	r.Mount("/api/v1/download", DownloadRoutes(fileService, chunkService, containers.MinioClient.BucketName))
+	a, err := app.New(context.Background(), cfg,
+		app.WithDatabase(containers.Database),
+		app.WithStorage(containers.MinioClient),
+	)
+	require.NoError(t, err)
 
-	return r, containers.Database, containers.Cleanup
+	return a.Handler(), containers.Database, containers.Cleanup
 }
 
 func TestRateLimit_UploadInit(t *testing.T) {
@@ -112,11 +113,12 @@ func TestRateLimit_ChunkUpload(t *testing.T) {
 		EncryptedMimeType: "application/octet-stream",
 		Salt:              "test-salt",
 		Pbkdf2Iterations:  100000,
-		TotalSize:         1024,
+		TotalSize:         10 * 1024,
 		ChunkCount:        10,
 		ChunkSize:         1024,
 		ExpiresAt:         pgtype.Timestamptz{Time: time.Now().Add(24 * time.Hour), Valid: true},
 		MaxDownloads:      5,
+		DeletionTokenHash: pgtype.Text{String: "test-token", Valid: true},
 		UploaderIp:        netip.MustParseAddr("192.0.2.1"),
 	})
 	require.NoError(t, err)
@@ -125,11 +127,12 @@ func TestRateLimit_ChunkUpload(t *testing.T) {
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
+		chunk := testutil.ChunkData(file, int32(chunkIndex))
 		part, _ := writer.CreateFormFile("chunk", "chunk.enc")
-		io.WriteString(part, "test chunk data")
+		part.Write(chunk)
 
 		writer.WriteField("chunk_index", fmt.Sprintf("%d", chunkIndex))
-		writer.WriteField("hash", "34fa0947d659ce6343cbfe6be3a1ca882f6b21b35232210f194791d545440c40")
+		writer.WriteField("hash", crypto.HashBytes(chunk))
 		writer.Close()
 
 		req := httptest.NewRequest("POST", "/api/v1/files/"+file.ID.String()+"/chunks", body)
@@ -3,7 +3,11 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/ilkin0/gzln/internal/api/handlers"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/flags"
 	"github.com/ilkin0/gzln/internal/middleware"
+	"github.com/ilkin0/gzln/internal/readonly"
 	"github.com/ilkin0/gzln/internal/service"
 )
 
@@ -15,15 +19,51 @@ func FileRoutes(fileService *service.FileService, chunkService *service.ChunkSer
 	// File routes
 	r.Post("/upload", fileHandler.UploadFile)
 
-	r.With(middleware.UploadInitLimiter()).
+	r.With(middleware.UploadAdviceLimiter()).
+		Get("/upload/advice", fileHandler.GetUploadAdvice)
+
+	r.With(middleware.Reputation(), middleware.UploadInitLimiter()).
 		Post("/upload/init", fileHandler.InitUpload)
 
-	r.With(middleware.ChunkUploadLimiter()).
+	r.With(middleware.UploadSlotLimiter()).
+		Post("/upload/slots", fileHandler.CreateUploadSlot)
+
+	r.With(middleware.ChunkUploadLimiter(), fileHandler.RequireUploadToken).
 		Post("/{fileID}/chunks", chunkHandler.HandleChunkUpload)
 
-	r.With(middleware.UploadFinalizeLimiter()).
+	r.With(middleware.ChunkUploadLimiter(), fileHandler.RequireUploadToken).
+		Post("/{fileID}/chunks/{chunkIndex}/confirm", chunkHandler.ConfirmChunkUpload)
+
+	r.With(middleware.ChunkUploadLimiter(), fileHandler.RequireUploadToken).
+		Get("/{fileID}/chunks/upload-urls", chunkHandler.GetChunkUploadURLs)
+
+	r.With(middleware.ChunkStatusLimiter(), fileHandler.RequireUploadToken).
+		Get("/{fileID}/chunks/status", chunkHandler.GetChunkStatus)
+
+	r.With(middleware.ChunkStatusLimiter(), fileHandler.RequireUploadToken).
+		Get("/{fileID}/status", chunkHandler.GetUploadStatus)
+
+	r.With(middleware.ShareEventsLimiter(), fileHandler.RequireUploadToken).
+		Get("/{fileID}/events", fileHandler.WatchUpload)
+
+	r.With(middleware.UploadFinalizeLimiter(), fileHandler.RequireUploadToken).
 		Post("/{fileID}/finalize", fileHandler.FinalizeFileUpload)
 
+	r.With(middleware.ShareStatsLimiter()).
+		Get("/{shareID}/stats", fileHandler.GetShareStats)
+
+	r.With(middleware.ShareRevokeLimiter()).
+		Post("/{shareID}/revoke", fileHandler.RevokeShare)
+
+	r.With(middleware.ShareLinksLimiter()).
+		Post("/{shareID}/links", fileHandler.CreateShareLink)
+
+	r.With(middleware.ShareLinksLimiter()).
+		Get("/{shareID}/links", fileHandler.ListShareLinks)
+
+	r.With(middleware.ShareLinksLimiter()).
+		Delete("/{shareID}/links/{linkID}", fileHandler.RevokeShareLink)
+
 	return r
 }
 
@@ -33,14 +73,144 @@ func DownloadRoutes(fileService *service.FileService, chunkService *service.Chun
 	chunkHandler := handlers.NewChunkHandler(chunkService, bucketName)
 
 	// Download routes
-	r.With(middleware.MetadataLimiter()).
+	r.With(middleware.MetadataLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias).
 		Get("/{shareID}/metadata", fileHandler.GetFileMetadata)
 
-	r.With(middleware.ChunkDownloadLimiter()).
+	r.With(middleware.ManifestLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias).
+		Get("/{shareID}/manifest", chunkHandler.GetDownloadManifest)
+
+	r.With(middleware.Reputation(), middleware.ChunkDownloadLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias, chunkHandler.RequireDownloadNonce, middleware.DownloadStreamLimiter()).
 		Get("/{shareID}/chunks/{chunkIndex}", chunkHandler.DownloadChunk)
 
-	r.With(middleware.DownloadCompleteLimiter()).
+	r.With(middleware.Reputation(), middleware.ChunkDownloadLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias).
+		Head("/{shareID}/chunks/{chunkIndex}", chunkHandler.HeadChunk)
+
+	r.With(middleware.Reputation(), middleware.StreamLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias, chunkHandler.RequireDownloadNonce, middleware.DownloadStreamLimiter()).
+		Get("/{shareID}/stream", chunkHandler.StreamFile)
+
+	r.With(middleware.DownloadCompleteLimiter(), fileHandler.ResolveShareLink, fileHandler.ResolveAlias).
 		Post("/{shareID}/complete", fileHandler.CompleteDownload)
 
+	r.With(middleware.ShareEventsLimiter(), fileHandler.ResolveAlias).
+		Get("/{shareID}/events", fileHandler.WatchShare)
+
+	return r
+}
+
+// BundleRoutes creates bundles, lists their files and serves them as one ZIP
+// archive. The files themselves are uploaded and downloaded through
+// FileRoutes and DownloadRoutes.
+func BundleRoutes(fileService *service.FileService, chunkService *service.ChunkService, bucketName string) chi.Router {
+	r := chi.NewRouter()
+	fileHandler := handlers.NewFileHandler(fileService, bucketName)
+	chunkHandler := handlers.NewChunkHandler(chunkService, bucketName)
+
+	r.With(middleware.BundleCreateLimiter()).
+		Post("/", fileHandler.CreateBundle)
+
+	r.With(middleware.BundleManifestLimiter()).
+		Get("/{shareID}", fileHandler.GetBundleManifest)
+
+	r.With(middleware.Reputation(), middleware.BundleArchiveLimiter()).
+		Get("/{shareID}/zip", chunkHandler.DownloadBundleArchive)
+
+	return r
+}
+
+// PasteRoutes shares small encrypted texts without chunk uploads.
+func PasteRoutes(pasteService *service.PasteService) chi.Router {
+	r := chi.NewRouter()
+	pasteHandler := handlers.NewPasteHandler(pasteService)
+
+	r.With(middleware.PasteCreateLimiter()).
+		Post("/", pasteHandler.CreatePaste)
+
+	r.With(middleware.PasteReadLimiter()).
+		Get("/{shareID}", pasteHandler.ReadPaste)
+
+	return r
+}
+
+// ManageRoutes serves uploaders managing their shares. Exports only read, so
+// unlike sessions they stay available in read-only mode.
+func ManageRoutes(sessionService *service.SessionService, exportService *service.ExportService, readOnly *readonly.Switch) chi.Router {
+	r := chi.NewRouter()
+	manageHandler := handlers.NewManageHandler(sessionService, exportService)
+
+	// Management routes
+	r.With(middleware.ManageSessionLimiter(), readOnly.RejectWrites).
+		Post("/{shareID}/session", manageHandler.CreateSession)
+
+	r.With(middleware.ManageExportLimiter()).
+		Post("/export", manageHandler.ExportShares)
+
+	return r
+}
+
+// AdminRoutes is the operator API. Every route requires the admin API token.
+func AdminRoutes(adminService *service.AdminService, cleanupService *service.CleanupService, retentionService *service.RetentionService, adminToken string) chi.Router {
+	r := chi.NewRouter()
+	adminHandler := handlers.NewAdminHandler(adminService, cleanupService, retentionService)
+
+	r.Use(middleware.AdminLimiter(), middleware.RequireAdminToken(adminToken))
+
+	r.Get("/files", adminHandler.ListFiles)
+	r.Post("/files/{shareID}/expire", adminHandler.ExpireFile)
+	r.Get("/files/{shareID}/notes", adminHandler.GetNotes)
+	r.Put("/files/{shareID}/notes", adminHandler.UpdateNotes)
+	r.Put("/files/{shareID}/watermark", adminHandler.SetWatermark)
+	r.Put("/files/{shareID}/download-rate", adminHandler.SetDownloadRate)
+	r.Post("/files/{shareID}/preview-token", adminHandler.CreatePreviewToken)
+	r.Get("/storage", adminHandler.GetStorageTotals)
+	r.Get("/transfers", adminHandler.GetTransferTotals)
+	r.Post("/cleanup", adminHandler.RunCleanup)
+	r.Get("/flags", adminHandler.ListFlags)
+	r.Put("/flags/{name}", adminHandler.SetFlag)
+	r.Get("/read-only", adminHandler.GetReadOnly)
+	r.Put("/read-only", adminHandler.SetReadOnly)
+	r.Get("/rate-limits", adminHandler.GetRateLimits)
+	r.Get("/jobs", adminHandler.GetJobs)
+	r.Get("/blocklist", adminHandler.ListBlocklist)
+	r.Post("/blocklist", adminHandler.BlockNetwork)
+	r.Delete("/blocklist", adminHandler.UnblockNetwork)
+	r.Get("/reports/retention", adminHandler.ListRetentionReports)
+	r.Get("/reports/retention/{month}", adminHandler.GetRetentionReport)
+	r.Post("/reports/retention/{month}", adminHandler.GenerateRetentionReport)
+
+	return r
+}
+
+// SupportRoutes lets support staff view a share with a preview token issued
+// through the admin API.
+func SupportRoutes(adminService *service.AdminService) chi.Router {
+	r := chi.NewRouter()
+	supportHandler := handlers.NewSupportHandler(adminService)
+
+	r.With(middleware.SupportPreviewLimiter()).
+		Get("/files/{shareID}", supportHandler.PreviewShare)
+
+	return r
+}
+
+// WellKnownRoutes serves the signed client config bundle and its detached
+// signature.
+func WellKnownRoutes(cfg config.Config, presignedUploads bool, featureFlags *flags.Provider, signer *crypto.Signer) chi.Router {
+	r := chi.NewRouter()
+	clientConfigHandler := handlers.NewClientConfigHandler(cfg, presignedUploads, featureFlags, signer)
+
+	r.Get("/gzln-config.json", clientConfigHandler.GetConfig)
+	r.Get("/gzln-config.json.sig", clientConfigHandler.GetSignature)
+
+	return r
+}
+
+// DevRoutes exposes development-only helpers. It must not be mounted in
+// production.
+func DevRoutes(limits config.Limits) chi.Router {
+	r := chi.NewRouter()
+	conformanceHandler := handlers.NewConformanceHandler(limits)
+
+	r.Get("/conformance/vectors", conformanceHandler.GetVectors)
+
 	return r
 }
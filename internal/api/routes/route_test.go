@@ -5,13 +5,15 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/pkg/e2ee"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFileRoutes_EndpointsRegistered(t *testing.T) {
-	fileService := service.NewFileService(nil, nil, nil)
-	chunkService := service.NewChunkService(nil, nil, "test-bucket")
+	fileService := service.NewFileService(nil, nil, nil, config.DefaultLimits())
+	chunkService := service.NewChunkService(nil, nil, "test-bucket", config.DefaultLimits())
 	router := FileRoutes(fileService, chunkService, "test-bucket")
 
 	tests := []struct {
@@ -47,8 +49,8 @@ func TestFileRoutes_EndpointsRegistered(t *testing.T) {
 }
 
 func TestFileRoutes_MethodNotAllowed(t *testing.T) {
-	fileService := service.NewFileService(nil, nil, nil)
-	chunkService := service.NewChunkService(nil, nil, "test-bucket")
+	fileService := service.NewFileService(nil, nil, nil, config.DefaultLimits())
+	chunkService := service.NewChunkService(nil, nil, "test-bucket", config.DefaultLimits())
 	router := FileRoutes(fileService, chunkService, "test-bucket")
 
 	tests := []struct {
@@ -93,8 +95,8 @@ func TestFileRoutes_MethodNotAllowed(t *testing.T) {
 }
 
 func TestFileRoutes_NonExistentPath(t *testing.T) {
-	fileService := service.NewFileService(nil, nil, nil)
-	chunkService := service.NewChunkService(nil, nil, "test-bucket")
+	fileService := service.NewFileService(nil, nil, nil, config.DefaultLimits())
+	chunkService := service.NewChunkService(nil, nil, "test-bucket", config.DefaultLimits())
 	router := FileRoutes(fileService, chunkService, "test-bucket")
 
 	req := httptest.NewRequest("GET", "/nonexistent", nil)
@@ -106,9 +108,37 @@ func TestFileRoutes_NonExistentPath(t *testing.T) {
 }
 
 func TestDownloadRoutes_Creation(t *testing.T) {
-	fileService := service.NewFileService(nil, nil, nil)
-	chunkService := service.NewChunkService(nil, nil, "test-bucket")
+	fileService := service.NewFileService(nil, nil, nil, config.DefaultLimits())
+	chunkService := service.NewChunkService(nil, nil, "test-bucket", config.DefaultLimits())
 
 	router := DownloadRoutes(fileService, chunkService, "test-bucket")
 	assert.NotNil(t, router, "Download routes should be created successfully")
 }
+
+func TestDevRoutes_ConformanceVectors(t *testing.T) {
+	router := DevRoutes(config.DefaultLimits())
+
+	req := httptest.NewRequest(http.MethodGet, "/conformance/vectors", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"vectors"`)
+	assert.Contains(t, w.Body.String(), e2ee.Vectors[0].ChunkHash)
+}
+
+func TestAdminRoutes_RequireToken(t *testing.T) {
+	router := AdminRoutes(service.NewAdminService(nil, nil), nil, nil, "admin-token-0123456789abcdef012345")
+
+	for _, path := range []string{"/files", "/storage"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package types
+
+import (
+	"encoding/json"
+
+	"github.com/ilkin0/gzln/internal/middleware"
+)
+
+type AdminNotesRequest struct {
+	Notes string `json:"notes"`
+}
+
+type AdminNotesResponse struct {
+	ShareID string          `json:"share_id"`
+	Notes   string          `json:"notes"`
+	History []AuditLogEntry `json:"history"`
+}
+
+// AdminWatermarkRequest sets the watermark of a server encrypted share. An
+// empty Text removes it.
+type AdminWatermarkRequest struct {
+	Text string `json:"text"`
+}
+
+// AdminDownloadRateRequest sets the download rate of a file in bytes per
+// second; 0 leaves its downloads unthrottled and null makes it follow
+// DOWNLOAD_RATE_LIMIT again.
+type AdminDownloadRateRequest struct {
+	BytesPerSecond *int64 `json:"bytes_per_second"`
+}
+
+type AuditLogEntry struct {
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// AdminFileFilter narrows the admin file list. Zero values do not filter.
+type AdminFileFilter struct {
+	Status     string
+	MinSize    int64
+	MaxSize    int64
+	UploaderIP string
+	Limit      int
+	Offset     int
+}
+
+type AdminFile struct {
+	ShareID       string `json:"share_id"`
+	Status        string `json:"status"`
+	TotalSize     int64  `json:"total_size"`
+	ChunkCount    int32  `json:"chunk_count"`
+	DownloadCount int32  `json:"download_count"`
+	MaxDownloads  int32  `json:"max_downloads"`
+	UploaderIP    string `json:"uploader_ip"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+type AdminFileList struct {
+	Files  []AdminFile `json:"files"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// StorageTotals counts files and their bytes by status. StoredBytes covers
+// every status but expired, whose chunks are gone.
+type StorageTotals struct {
+	Files       int64         `json:"files"`
+	Bytes       int64         `json:"bytes"`
+	StoredBytes int64         `json:"stored_bytes"`
+	ByStatus    []StatusTotal `json:"by_status"`
+}
+
+type StatusTotal struct {
+	Status string `json:"status"`
+	Files  int64  `json:"files"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// TransferTotals sums the bytes served in the Days days up to today, UTC,
+// by day and for the files served the most.
+type TransferTotals struct {
+	Days     int             `json:"days"`
+	Since    string          `json:"since"`
+	Bytes    int64           `json:"bytes"`
+	ByDay    []DailyTransfer `json:"by_day"`
+	TopFiles []FileTransfer  `json:"top_files"`
+}
+
+type DailyTransfer struct {
+	Day   string `json:"day"`
+	Bytes int64  `json:"bytes"`
+}
+
+type FileTransfer struct {
+	ShareID   string `json:"share_id"`
+	Status    string `json:"status"`
+	TotalSize int64  `json:"total_size"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type AdminCleanupResponse struct {
+	Expired int `json:"expired"`
+}
+
+// AdminFeatureFlagRequest toggles a feature flag. Enabled is required.
+type AdminFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// AdminReadOnlyRequest switches read-only mode. Enabled is required.
+type AdminReadOnlyRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type AdminReadOnlyResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// JobStatus is the state of a scheduled job on the instance answering. Times
+// are empty until the job first starts and finishes.
+type JobStatus struct {
+	Name            string `json:"name"`
+	IntervalSeconds int64  `json:"interval_seconds"`
+	Running         bool   `json:"running"`
+	Runs            int64  `json:"runs"`
+	Failures        int64  `json:"failures"`
+	LastStarted     string `json:"last_started,omitempty"`
+	LastFinished    string `json:"last_finished,omitempty"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+// VerificationStatus counts background chunk verifications: those running
+// on the instance answering, and the files verifying on any instance.
+type VerificationStatus struct {
+	Running int64 `json:"running"`
+	Files   int64 `json:"files"`
+}
+
+// AdminJobs is the state of the scheduled jobs and background verifications.
+type AdminJobs struct {
+	Jobs         []JobStatus        `json:"jobs"`
+	Verification VerificationStatus `json:"verification"`
+}
+
+// BlockedNetwork is a network blocked through the admin API.
+type BlockedNetwork struct {
+	Network   string `json:"network"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AdminBlockRequest blocks a network, given as a CIDR or a single address.
+type AdminBlockRequest struct {
+	Network string `json:"network"`
+	Reason  string `json:"reason"`
+}
+
+// AdminRateLimits counts requests the rate limiters rejected, and those an
+// exemption let through, since the last rate limit report.
+type AdminRateLimits struct {
+	Rejected middleware.RateLimitStats `json:"rejected"`
+	Exempted middleware.RateLimitStats `json:"exempted"`
+}
+
+// RetentionReport summarizes one calendar month (UTC) of file retention.
+// Purged files are those whose chunks cleanup deleted during the month; their
+// average lifetime runs from upload to purge. OldestRetained is the oldest
+// file still stored at the end of the month, if any.
+type RetentionReport struct {
+	Month                  string           `json:"month"`
+	PeriodStart            string           `json:"period_start"`
+	PeriodEnd              string           `json:"period_end"`
+	GeneratedAt            string           `json:"generated_at"`
+	FilesCreated           int64            `json:"files_created"`
+	BytesCreated           int64            `json:"bytes_created"`
+	FilesExpired           int64            `json:"files_expired"`
+	FilesPurged            int64            `json:"files_purged"`
+	BytesPurged            int64            `json:"bytes_purged"`
+	AverageLifetimeSeconds int64            `json:"average_lifetime_seconds"`
+	OldestRetained         *RetainedFileAge `json:"oldest_retained"`
+}
+
+type RetainedFileAge struct {
+	ShareID    string `json:"share_id"`
+	CreatedAt  string `json:"created_at"`
+	AgeSeconds int64  `json:"age_seconds"`
+}
+
+// RetentionReportSummary lists a stored report without its contents.
+type RetentionReportSummary struct {
+	Month       string `json:"month"`
+	GeneratedAt string `json:"generated_at"`
+}
+
+// SupportPreviewToken lets support staff view one share until ExpiresAt.
+type SupportPreviewToken struct {
+	ShareID   string `json:"share_id"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// SupportPreview is what support staff see of a share to troubleshoot a
+// transfer. It never includes the salt, encrypted names, tokens, uploader IP
+// or content.
+type SupportPreview struct {
+	ShareID          string         `json:"share_id"`
+	Status           string         `json:"status"`
+	TotalSize        int64          `json:"total_size"`
+	ChunkCount       int32          `json:"chunk_count"`
+	ChunkSize        int32          `json:"chunk_size"`
+	ChunksUploaded   int64          `json:"chunks_uploaded"`
+	DownloadCount    int32          `json:"download_count"`
+	MaxDownloads     int32          `json:"max_downloads"`
+	CreatedAt        string         `json:"created_at"`
+	ExpiresAt        string         `json:"expires_at"`
+	LastDownloadedAt string         `json:"last_downloaded_at,omitempty"`
+	History          []SupportEvent `json:"history"`
+}
+
+// SupportEvent is an audit log entry without its details.
+type SupportEvent struct {
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	CreatedAt string `json:"created_at"`
+}
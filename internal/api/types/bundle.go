@@ -0,0 +1,57 @@
+package types
+
+// CreateBundleRequest starts a bundle of files shared under one link.
+// ExpiresInHours defaults like InitUploadRequest and applies to every file.
+type CreateBundleRequest struct {
+	ExpiresInHours int `json:"expires_in_hours,omitempty"`
+}
+
+type CreateBundleResponse struct {
+	ShareID string `json:"share_id"`
+	// BundleToken adds files to the bundle when sent as bundle_token in
+	// upload init requests. It is only returned here.
+	BundleToken string `json:"bundle_token"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// BundleManifestResponse lists the ready files of a bundle. Each file is
+// downloaded through its own share ID.
+type BundleManifestResponse struct {
+	ShareID   string       `json:"share_id"`
+	ExpiresAt string       `json:"expires_at"`
+	Files     []BundleFile `json:"files"`
+}
+
+type BundleFile struct {
+	ShareID           string `json:"share_id"`
+	EncryptedFilename string `json:"encrypted_filename"`
+	EncryptedMimeType string `json:"encrypted_mime_type"`
+	TotalSize         int64  `json:"total_size"`
+	ChunkCount        int32  `json:"chunk_count"`
+}
+
+// BundleArchiveManifest is the manifest.json of a bundle's ZIP archive.
+// Files lists the files in the archive, Skipped those that could not be
+// served.
+type BundleArchiveManifest struct {
+	ShareID   string              `json:"share_id"`
+	ExpiresAt string              `json:"expires_at"`
+	Files     []BundleArchiveFile `json:"files"`
+	Skipped   []BundleArchiveSkip `json:"skipped"`
+}
+
+// BundleArchiveFile is stored at Path as its encrypted chunks concatenated
+// in order, which Chunks lists to split them again. Chunks is empty for
+// watermarked files, which are stored as served.
+type BundleArchiveFile struct {
+	BundleFile
+	Path   string          `json:"path"`
+	Chunks []ManifestChunk `json:"chunks,omitempty"`
+}
+
+type BundleArchiveSkip struct {
+	ShareID string `json:"share_id"`
+	// Reason is expired, revoked, download_limit_reached, chunk_missing,
+	// watermark_unavailable or not_ready.
+	Reason string `json:"reason"`
+}
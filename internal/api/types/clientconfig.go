@@ -0,0 +1,35 @@
+package types
+
+// ClientConfig is the signed bundle clients fetch at startup to learn the
+// protocol parameters of a server. Its Version changes only when the meaning
+// of existing fields does.
+type ClientConfig struct {
+	Version    int               `json:"version"`
+	Limits     ClientLimits      `json:"limits"`
+	Transfer   ClientTransfer    `json:"transfer"`
+	Endpoints  map[string]string `json:"endpoints"`
+	Features   map[string]bool   `json:"features"`
+	PublicKeys []ConfigPublicKey `json:"public_keys"`
+}
+
+type ClientLimits struct {
+	MaxFileSize          int64 `json:"max_file_size"`
+	MaxChunkSize         int64 `json:"max_chunk_size"`
+	MaxChunkCount        int32 `json:"max_chunk_count"`
+	DefaultMaxDownloads  int32 `json:"default_max_downloads"`
+	DefaultExpirySeconds int64 `json:"default_expiry_seconds"`
+}
+
+type ClientTransfer struct {
+	UploadConcurrency int `json:"upload_concurrency"`
+	DownloadPrefetch  int `json:"download_prefetch"`
+}
+
+// ConfigPublicKey is a key clients can pin. Use names what it verifies.
+type ConfigPublicKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	Use       string `json:"use"`
+	// PublicKey is base64 encoded.
+	PublicKey string `json:"public_key"`
+}
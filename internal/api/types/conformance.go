@@ -0,0 +1,18 @@
+package types
+
+import "github.com/ilkin0/gzln/pkg/e2ee"
+
+type ConformanceResponse struct {
+	ChunkLayout ChunkLayout   `json:"chunk_layout"`
+	Vectors     []e2ee.Vector `json:"vectors"`
+}
+
+// ChunkLayout describes how an encrypted chunk is laid out on the wire.
+type ChunkLayout struct {
+	Format        string `json:"format"`
+	NonceSize     int    `json:"nonce_size"`
+	TagSize       int    `json:"tag_size"`
+	Overhead      int    `json:"overhead"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	MaxChunkSize  int64  `json:"max_chunk_size"`
+}
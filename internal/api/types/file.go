@@ -1,6 +1,171 @@
 package types
 
+import (
+	"io"
+)
+
 type FileMetadata struct {
 	FileSize int64  `json:"file_size"`
 	MimeType string `json:"mime_type"`
 }
+
+// ShareMetadata is what a recipient needs to download and decrypt a share.
+// Timestamps are RFC 3339 in UTC; ExpiresAt is empty when unset.
+type ShareMetadata struct {
+	EncryptedFilename string      `json:"encrypted_filename"`
+	EncryptedMimeType string      `json:"encrypted_mime_type"`
+	Salt              string      `json:"salt"`
+	TotalSize         int64       `json:"total_size"`
+	ChunkCount        int32       `json:"chunk_count"`
+	ExpiresAt         string      `json:"expires_at"`
+	MaxDownloads      int32       `json:"max_downloads"`
+	DownloadCount     int32       `json:"download_count"`
+	CreatedAt         string      `json:"created_at"`
+	Policy            SharePolicy `json:"policy"`
+	// Encryption is EncryptionServer for files the server decrypts on
+	// download; their name and type are not encrypted and there is no salt.
+	Encryption string `json:"encryption"`
+	// ReturnURL is where the uploader wants the recipient sent once the
+	// download finished.
+	ReturnURL string `json:"return_url,omitempty"`
+}
+
+// Reasons a share ends, listed in SharePolicy.
+const (
+	// ExpiryReasonTime ends a share at its expires_at.
+	ExpiryReasonTime = "time"
+	// ExpiryReasonDownloadLimit ends a share once max_downloads are counted.
+	ExpiryReasonDownloadLimit = "download_limit"
+	// ExpiryReasonRemoved is a share ended early by the operator, by an
+	// admin or to free storage.
+	ExpiryReasonRemoved = "removed"
+)
+
+// AtLimitDeleteContent deletes the encrypted content of a share that ended.
+const AtLimitDeleteContent = "delete_content"
+
+// SharePolicy describes how the server ends a share, so recipient UIs can
+// explain it in their own language instead of hard-coding server policy.
+type SharePolicy struct {
+	// ExpiresBy lists the reasons that will end the share.
+	ExpiresBy []string `json:"expires_by"`
+	// ExpiryReason is why the share ended; empty while it is active.
+	ExpiryReason string `json:"expiry_reason,omitempty"`
+	// AtLimit is what happens to the share when it ends.
+	AtLimit string `json:"at_limit"`
+	// RetentionSeconds is the longest the content is kept after the share
+	// ends, until the next cleanup deletes it.
+	RetentionSeconds int64 `json:"retention_seconds"`
+}
+
+type FileMetadataResponse struct {
+	ShareMetadata
+	DownloadPrefetch int    `json:"download_prefetch"`
+	DownloadNonce    string `json:"download_nonce,omitempty"`
+}
+
+type ManifestChunk struct {
+	Index int32  `json:"index"`
+	Size  int64  `json:"size"`
+	Hash  string `json:"hash"`
+	// ETag matches the ETag header of the chunk's download response, so it
+	// can be sent back in If-None-Match.
+	ETag string `json:"etag"`
+}
+
+type DownloadManifestResponse struct {
+	ShareID    string          `json:"share_id"`
+	ChunkCount int32           `json:"chunk_count"`
+	TotalSize  int64           `json:"total_size"`
+	Chunks     []ManifestChunk `json:"chunks"`
+}
+
+// ChunkDownload is a chunk about to be served. Body is nil when the client
+// already holds the chunk. MaxRate is the rate in bytes per second to serve
+// it at, or 0 for no limit.
+type ChunkDownload struct {
+	Body        io.ReadCloser
+	ETag        string
+	Size        int64
+	NotModified bool
+	MaxRate     int64
+}
+
+// FileStream is every chunk of a share concatenated in order. Size is the sum
+// of the chunk sizes, and Chunks lists them unless the stream is
+// watermarked. MaxRate is as for ChunkDownload.
+type FileStream struct {
+	Body       io.ReadCloser
+	Size       int64
+	ChunkCount int32
+	Chunks     []ManifestChunk
+	MaxRate    int64
+}
+
+// ShareStatsResponse is what an uploader sees about their share. Timestamps
+// are RFC 3339 and empty when unset; RemainingDownloads is null when
+// downloads are unlimited.
+type ShareStatsResponse struct {
+	ShareID            string `json:"share_id"`
+	Status             string `json:"status"`
+	DownloadCount      int32  `json:"download_count"`
+	MaxDownloads       int32  `json:"max_downloads"`
+	RemainingDownloads *int32 `json:"remaining_downloads"`
+	LastDownloadedAt   string `json:"last_downloaded_at"`
+	ExpiresAt          string `json:"expires_at"`
+	Expired            bool   `json:"expired"`
+}
+
+// ShareStatusResponse is what a recipient watching a share sees, when
+// watching begins and after every change. ExpiresAt is RFC 3339 and empty
+// when unset; RemainingDownloads is null when downloads are unlimited.
+type ShareStatusResponse struct {
+	ShareID            string `json:"share_id"`
+	Status             string `json:"status"`
+	DownloadCount      int32  `json:"download_count"`
+	MaxDownloads       int32  `json:"max_downloads"`
+	RemainingDownloads *int32 `json:"remaining_downloads"`
+	ExpiresAt          string `json:"expires_at"`
+	// Upload is set while the status is uploading.
+	Upload *UploadedChunks `json:"upload,omitempty"`
+	// Verification is set while the status is verifying.
+	Verification *VerificationProgress `json:"verification,omitempty"`
+}
+
+// CreateShareLinkRequest mints a link for one recipient. MaxDownloads of 0
+// makes it single-use, and ExpiresInHours of 0 lets it last as long as the
+// share.
+type CreateShareLinkRequest struct {
+	Label          string `json:"label,omitempty"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"`
+	MaxDownloads   int32  `json:"max_downloads,omitempty"`
+}
+
+// ShareLinkResponse is a recipient link as its uploader sees it. LinkID
+// takes the place of the share ID in download URLs. Status is active,
+// revoked, expired or exhausted; timestamps are RFC 3339 and empty when
+// unset.
+type ShareLinkResponse struct {
+	LinkID             string `json:"link_id"`
+	Label              string `json:"label,omitempty"`
+	Status             string `json:"status"`
+	DownloadCount      int32  `json:"download_count"`
+	MaxDownloads       int32  `json:"max_downloads"`
+	RemainingDownloads int32  `json:"remaining_downloads"`
+	ExpiresAt          string `json:"expires_at"`
+	RevokedAt          string `json:"revoked_at"`
+	CreatedAt          string `json:"created_at"`
+}
+
+// UploadedChunks is how many chunks of an upload are stored so far.
+type UploadedChunks struct {
+	ChunksUploaded int32 `json:"chunks_uploaded"`
+	ChunkCount     int32 `json:"chunk_count"`
+}
+
+// VerificationProgress is how far the background check of a finalized
+// upload's chunks has got.
+type VerificationProgress struct {
+	ChunksVerified int32 `json:"chunks_verified"`
+	ChunkCount     int32 `json:"chunk_count"`
+}
@@ -0,0 +1,21 @@
+package types
+
+import "github.com/ilkin0/gzln/internal/flags"
+
+// ReadinessResponse reports whether the server can serve requests, with the
+// state of its dependencies and feature flags for operators.
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+	Flags  []flags.Flag      `json:"flags"`
+	// ReadOnly is set while the instance refuses uploads and other changes.
+	ReadOnly bool `json:"read_only"`
+}
+
+// HealthResponse is the outcome of the last background probe of the
+// server's dependencies.
+type HealthResponse struct {
+	Status    string            `json:"status"`
+	Checks    map[string]string `json:"checks"`
+	CheckedAt string            `json:"checked_at"`
+}
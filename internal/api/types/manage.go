@@ -0,0 +1,32 @@
+package types
+
+type ManagementSessionResponse struct {
+	SessionToken string `json:"session_token"`
+	ExpiresAt    string `json:"expires_at"`
+}
+
+type ShareExportRequest struct {
+	DeletionTokens []string `json:"deletion_tokens"`
+}
+
+type ShareExportResponse struct {
+	ExportedAt string        `json:"exported_at"`
+	Shares     []ShareExport `json:"shares"`
+	// Unmatched counts tokens that belong to no share, e.g. because the
+	// share was never created or the token was mistyped.
+	Unmatched int `json:"unmatched"`
+}
+
+// ShareExport is the metadata of one share. Timestamps are RFC 3339 and
+// empty when unset.
+type ShareExport struct {
+	ShareID          string   `json:"share_id"`
+	Status           string   `json:"status"`
+	TotalSize        int64    `json:"total_size"`
+	CreatedAt        string   `json:"created_at"`
+	ExpiresAt        string   `json:"expires_at"`
+	MaxDownloads     int32    `json:"max_downloads"`
+	DownloadCount    int32    `json:"download_count"`
+	LastDownloadedAt string   `json:"last_downloaded_at"`
+	Downloads        []string `json:"downloads"`
+}
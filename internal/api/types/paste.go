@@ -0,0 +1,30 @@
+package types
+
+// CreatePasteRequest shares a small encrypted text payload stored inline
+// instead of in chunks. Ciphertext is base64 and decrypts with a key derived
+// from the password and Salt. ExpiresInHours and MaxDownloads default like
+// InitUploadRequest.
+type CreatePasteRequest struct {
+	Ciphertext       string `json:"ciphertext"`
+	Salt             string `json:"salt"`
+	Pbkdf2Iterations int32  `json:"pbkdf2_iterations"`
+	ExpiresInHours   int    `json:"expires_in_hours,omitempty"`
+	MaxDownloads     int32  `json:"max_downloads,omitempty"`
+}
+
+type CreatePasteResponse struct {
+	ShareID   string `json:"share_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// PasteResponse is returned by every read of a paste, each of which counts
+// as a download.
+type PasteResponse struct {
+	ShareID          string `json:"share_id"`
+	Ciphertext       string `json:"ciphertext"`
+	Salt             string `json:"salt"`
+	Pbkdf2Iterations int32  `json:"pbkdf2_iterations"`
+	DownloadCount    int32  `json:"download_count"`
+	MaxDownloads     int32  `json:"max_downloads"`
+	ExpiresAt        string `json:"expires_at"`
+}
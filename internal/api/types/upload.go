@@ -1,6 +1,7 @@
 package types
 
 import (
+	"io"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
@@ -14,42 +15,210 @@ type InitUploadRequest struct {
 	ChunkCount        int32  `json:"chunk_count"`
 	ChunkSize         int32  `json:"chunk_size"`
 	ExpiresInHours    int    `json:"expires_in_hours,omitempty"`
-	MaxDownloads      int32  `json:"max_downloads,omitempty"`
-	Pbkdf2Iterations  int32  `json:"pbkdf2_iterations"`
+	// MaxDownloads of 0 uses the server default and -1 allows unlimited
+	// downloads.
+	MaxDownloads     int32 `json:"max_downloads,omitempty"`
+	Pbkdf2Iterations int32 `json:"pbkdf2_iterations"`
+	// UploadMode selects how chunks reach storage: through the API (the
+	// default) or directly to object storage with UploadModePresigned.
+	UploadMode string `json:"upload_mode,omitempty"`
+	// WebhookURL receives signed download_completed, limit_reached and
+	// file_expired events for the file.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// ReturnURL is shown to recipients once their download finished, e.g.
+	// to hand them back to a workflow. Its scheme must be allowed by
+	// RETURN_URL_SCHEMES.
+	ReturnURL string `json:"return_url,omitempty"`
+	// Alias is a vanity name, e.g. "q3-report", that the download routes
+	// accept in place of the share ID while the share is live.
+	Alias string `json:"alias,omitempty"`
+	// SlotToken redeems an upload slot reserved by a trusted backend. The
+	// slot's size limit, expiry and download limit then apply to the file.
+	SlotToken string `json:"slot_token,omitempty"`
+	// BundleToken adds the file to a bundle, whose expiry it takes.
+	BundleToken string `json:"bundle_token,omitempty"`
+	// Encryption is EncryptionClient (the default) for chunks the client
+	// encrypted, or EncryptionServer for plaintext chunks the server
+	// encrypts at rest. Server encrypted uploads need no salt or
+	// pbkdf2_iterations and take the name and type as they should be shown.
+	Encryption string `json:"encryption,omitempty"`
 }
 
+const (
+	UploadModeProxy     = "proxy"
+	UploadModePresigned = "presigned"
+)
+
+const (
+	EncryptionClient = "client"
+	EncryptionServer = "server"
+)
+
 type InitUploadResponse struct {
-	FileID      string `json:"file_id"`
-	ShareID     string `json:"share_id"`
-	UploadToken string `json:"upload_token"`
-	ExpiresAt   string `json:"expires_at"`
+	FileID            string `json:"file_id"`
+	ShareID           string `json:"share_id"`
+	UploadToken       string `json:"upload_token"`
+	ExpiresAt         string `json:"expires_at"`
+	UploadConcurrency int    `json:"upload_concurrency"`
+	// UploadURLs is set for presigned uploads, for the first batch of
+	// chunks. It is empty if presigning failed; fetch the missing ones like
+	// later batches.
+	UploadURLs         []PresignedChunkUpload `json:"upload_urls,omitempty"`
+	UploadURLsExpireAt string                 `json:"upload_urls_expire_at,omitempty"`
+	// WebhookSecret keys the signatures of webhook events. It is only
+	// returned here.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	Alias         string `json:"alias,omitempty"`
+}
+
+// CreateUploadSlotRequest reserves one upload of at most MaxSize bytes.
+// ExpiresInHours and MaxDownloads follow the InitUploadRequest defaults.
+type CreateUploadSlotRequest struct {
+	MaxSize        int64 `json:"max_size"`
+	ExpiresInHours int   `json:"expires_in_hours,omitempty"`
+	MaxDownloads   int32 `json:"max_downloads,omitempty"`
+}
+
+// UploadSlotResponse carries the one-time token handed to the browser.
+type UploadSlotResponse struct {
+	SlotToken string `json:"slot_token"`
+	MaxSize   int64  `json:"max_size"`
+	// ExpiresAt is when the slot token stops being accepted.
+	ExpiresAt string `json:"expires_at"`
+}
+
+// PresignedChunkUpload is a form the client POSTs one encrypted chunk to as
+// multipart/form-data: Fields first, then the chunk as the "file" field.
+// Storage refuses any other size than the chunk's.
+type PresignedChunkUpload struct {
+	ChunkIndex int32             `json:"chunk_index"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Fields     map[string]string `json:"fields"`
+}
+
+// ChunkUploadURLsResponse is the next batch of presigned chunk uploads.
+type ChunkUploadURLsResponse struct {
+	UploadURLs         []PresignedChunkUpload `json:"upload_urls"`
+	UploadURLsExpireAt string                 `json:"upload_urls_expire_at"`
+}
+
+// ChunkConfirmRequest reports a chunk uploaded through a presigned URL.
+type ChunkConfirmRequest struct {
+	Hash string `json:"hash"`
+}
+
+// ChunkLayoutDetails describes a chunk layout init would accept for a file.
+// It accompanies 400 responses so clients can correct their request and
+// retry.
+type ChunkLayoutDetails struct {
+	TotalSize             int64 `json:"total_size"`
+	ChunkSize             int32 `json:"chunk_size"`
+	ExpectedChunkCount    int64 `json:"expected_chunk_count"`
+	ExpectedLastChunkSize int64 `json:"expected_last_chunk_size"`
+	MaxChunkSize          int64 `json:"max_chunk_size"`
+	MaxChunkCount         int32 `json:"max_chunk_count"`
+}
+
+// UploadQuotaDetails accompanies 429 responses to uploads over the uploader
+// IP's daily quota. Zero limits are not enforced.
+type UploadQuotaDetails struct {
+	DailyCount  int64  `json:"daily_count"`
+	UsedCount   int64  `json:"used_count"`
+	DailyBytes  int64  `json:"daily_bytes"`
+	UsedBytes   int64  `json:"used_bytes"`
+	RequestSize int64  `json:"request_size"`
+	ResetsAt    string `json:"resets_at"`
+}
+
+// RemainingCount is how many more uploads the quota allows today.
+func (d UploadQuotaDetails) RemainingCount() int64 {
+	return max(d.DailyCount-d.UsedCount, 0)
+}
+
+// RemainingBytes is how many more bytes the quota allows today.
+func (d UploadQuotaDetails) RemainingBytes() int64 {
+	return max(d.DailyBytes-d.UsedBytes, 0)
+}
+
+type UploadAdviceResponse struct {
+	TotalSize         int64 `json:"total_size"`
+	ChunkSize         int32 `json:"chunk_size"`
+	ChunkCount        int32 `json:"chunk_count"`
+	MaxChunkSize      int64 `json:"max_chunk_size"`
+	UploadConcurrency int   `json:"upload_concurrency"`
+	Busy              bool  `json:"busy"`
 }
 
 type UploadResponse struct {
-	FileID      string    `json:"file_id"`
-	FileName    string    `json:"file_name"`
-	Size        int64     `json:"size"`
-	ContentType string    `json:"content_type"`
-	UploadedAt  time.Time `json:"uploaded_at"`
-	URL         string    `json:"url"`
+	FileID      string `json:"file_id"`
+	FileName    string `json:"file_name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	UploadedAt  string `json:"uploaded_at"`
+	URL         string `json:"url"`
 }
 
 type ChunkUploadRequest struct {
-	FileID       pgtype.UUID
-	ChunkIndex   int64
-	ChunkData    []byte
+	FileID     pgtype.UUID
+	ChunkIndex int64
+	// Chunk streams the encrypted chunk; Size is its exact length.
+	Chunk        io.Reader
+	Size         int64
 	ExpectedHash string
 	ContentType  string
 	Filename     string
+	// ReceiveDuration is how long reading the chunk from the client took.
+	ReceiveDuration time.Duration
 }
 
+// ChunkUploadResponse acknowledges a chunk. ReceiveMs and StoreMs tell the
+// client how long the chunk took to arrive and to be stored, so it can tell
+// a slow connection from a slow server and adapt its parallelism.
 type ChunkUploadResponse struct {
 	ChunkIndex   int64  `json:"chunk_index"`
 	Status       string `json:"status"`
 	ReceivedHash string `json:"received_hash"`
+	ReceiveMs    int64  `json:"receive_ms,omitempty"`
+	StoreMs      int64  `json:"store_ms,omitempty"`
+}
+
+type UploadProgressResponse struct {
+	FileID         string            `json:"file_id"`
+	ChunkCount     int32             `json:"chunk_count"`
+	UploadedChunks []int32           `json:"uploaded_chunks"`
+	Throughput     *UploadThroughput `json:"throughput,omitempty"`
+}
+
+// UploadStatusResponse lets upload UIs poll a file without parsing errors.
+type UploadStatusResponse struct {
+	FileID         string `json:"file_id"`
+	Status         string `json:"status"`
+	ChunkCount     int32  `json:"chunk_count"`
+	UploadedChunks int64  `json:"uploaded_chunks"`
+	// VerifyStartedAt is set while the file is verifying.
+	VerifyStartedAt *time.Time `json:"verify_started_at,omitempty"`
+	// Stalled is set when a verification has run so long that the server
+	// checking it has likely gone away.
+	Stalled bool `json:"stalled,omitempty"`
+}
+
+// UploadThroughput is measured over the most recent chunks uploaded through
+// the API. Chunks uploaded through presigned URLs are not timed.
+type UploadThroughput struct {
+	WindowChunks int32 `json:"window_chunks"`
+	// ClientBytesPerSecond is how fast chunks arrived from the client.
+	ClientBytesPerSecond int64 `json:"client_bytes_per_second"`
+	// StorageBytesPerSecond is how fast the server wrote them to storage.
+	StorageBytesPerSecond int64 `json:"storage_bytes_per_second"`
 }
 
 type FinalizeUploadResponse struct {
 	ShareID       string `json:"share_id"`
 	DeletionToken string `json:"deletion_token"`
+	// AlreadyFinalized is set when an earlier finalize made the file ready.
+	AlreadyFinalized bool `json:"already_finalized,omitempty"`
+	// Verifying is set when the chunks are still being checked in the
+	// background. The share becomes downloadable once they pass.
+	Verifying bool `json:"verifying,omitempty"`
 }
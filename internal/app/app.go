@@ -0,0 +1,751 @@
+// Package app wires the database, storage, services, routes and background
+// jobs together from a config.Config, so the server binaries and the
+// integration tests run the same graph.
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ilkin0/gzln/db"
+	"github.com/ilkin0/gzln/internal/abuse"
+	"github.com/ilkin0/gzln/internal/alert"
+	"github.com/ilkin0/gzln/internal/api/openapi"
+	"github.com/ilkin0/gzln/internal/api/routes"
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/envelope"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/fairshare"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/health"
+	"github.com/ilkin0/gzln/internal/httpclient"
+	"github.com/ilkin0/gzln/internal/idgen"
+	"github.com/ilkin0/gzln/internal/logger"
+	custommiddleware "github.com/ilkin0/gzln/internal/middleware"
+	"github.com/ilkin0/gzln/internal/mirror"
+	"github.com/ilkin0/gzln/internal/notify"
+	"github.com/ilkin0/gzln/internal/publish"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/reputation"
+	"github.com/ilkin0/gzln/internal/scheduler"
+	"github.com/ilkin0/gzln/internal/service"
+	"github.com/ilkin0/gzln/internal/spool"
+	"github.com/ilkin0/gzln/internal/storage"
+	"github.com/ilkin0/gzln/internal/utils"
+	"github.com/ilkin0/gzln/internal/watermark"
+)
+
+type options struct {
+	db        *database.Database
+	storage   *storage.MinIOClient
+	devRoutes *bool
+}
+
+type Option func(*options)
+
+// WithDatabase uses an already connected database instead of opening one
+// from the config. The caller keeps ownership and closes it.
+func WithDatabase(db *database.Database) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithStorage uses an already initialized storage client instead of creating
+// one from the environment. The caller keeps ownership and closes it.
+func WithStorage(client *storage.MinIOClient) Option {
+	return func(o *options) {
+		o.storage = client
+	}
+}
+
+// WithDevRoutes overrides whether /api/v1/dev is mounted, which otherwise
+// follows APP_ENV.
+func WithDevRoutes(enabled bool) Option {
+	return func(o *options) {
+		o.devRoutes = &enabled
+	}
+}
+
+// App is a fully wired server. New builds it, Start runs the HTTP server and
+// background jobs and Stop shuts them down and releases what New opened.
+type App struct {
+	Config  config.Config
+	DB      *database.Database
+	Storage *storage.MinIOClient
+
+	FileService      *service.FileService
+	ChunkService     *service.ChunkService
+	CleanupService   *service.CleanupService
+	SessionService   *service.SessionService
+	ExportService    *service.ExportService
+	AdminService     *service.AdminService
+	RetentionService *service.RetentionService
+	PasteService     *service.PasteService
+
+	router     chi.Router
+	events     *events.Bus
+	flags      *flags.Provider
+	readOnly   *readonly.Switch
+	mirror     *mirror.Mirror
+	fairShare  *fairshare.Scheduler
+	reputation *reputation.Checker
+	blocklist  *reputation.ManagedBlocklist
+	transfers  *service.TransferStats
+	scheduler  *scheduler.Scheduler
+	health     *health.Prober
+	server     *http.Server
+
+	// configSigner signs the client config bundle, which is not served
+	// without it.
+	configSigner *crypto.Signer
+
+	cancelBackground context.CancelFunc
+	closers          []func()
+}
+
+func New(ctx context.Context, cfg config.Config, opts ...Option) (*App, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	a := &App{Config: cfg}
+	if err := a.build(ctx, o); err != nil {
+		a.close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *App) build(ctx context.Context, o options) error {
+	cfg := a.Config
+
+	// Initialize Database
+	a.DB = o.db
+	if a.DB == nil {
+		var db *database.Database
+		err := connectWithRetry(ctx, "database", cfg.Database.ConnectAttempts, startupBackoff, database.IsRetryable, func() error {
+			var err error
+			db, err = database.NewDatabase(ctx, cfg.Database)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		a.DB = db
+		a.closers = append(a.closers, db.Pool.Close)
+
+		slog.Info("database initialized successfully")
+	}
+	if cfg.RunMigrations {
+		applied, err := database.Migrate(ctx, a.DB.Pool, db.Migrations())
+		if err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+		slog.Info("database migrations up to date",
+			slog.Int("applied", len(applied)),
+		)
+	}
+	retryPolicy := database.DefaultRetryPolicy(cfg.Database.RetryAttempts)
+	runTx := database.NewRetryingTxRunner(a.DB.Pool, retryPolicy)
+	queries := database.NewRetryingQuerier(a.DB.Queries, retryPolicy)
+
+	// Initialize object storage
+	minioClient := o.storage
+	if minioClient == nil {
+		var client *storage.MinIOClient
+		err := connectWithRetry(ctx, "storage", cfg.StorageConnectAttempts, startupBackoff, storage.IsUnreachable, func() error {
+			var err error
+			client, err = storage.New()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		minioClient = client
+		a.closers = append(a.closers, client.Close)
+
+		slog.Info("storage client initialized successfully",
+			slog.String("provider", minioClient.Provider),
+			slog.String("bucket", minioClient.BucketName),
+		)
+	}
+	a.Storage = minioClient
+
+	shareIDGen, err := idgen.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid share ID configuration: %w", err)
+	}
+
+	storageEnvelope, err := envelope.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid storage encryption configuration: %w", err)
+	}
+
+	serverSealer, err := crypto.SealerFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid server encryption configuration: %w", err)
+	}
+
+	a.configSigner, err = crypto.SignerFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid config signing configuration: %w", err)
+	}
+
+	watermarker, err := watermark.CommandFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid watermark configuration: %w", err)
+	}
+
+	abuseScorer, err := abuse.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid abuse scoring configuration: %w", err)
+	}
+
+	outbound, err := httpclient.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid outbound HTTP configuration: %w", err)
+	}
+
+	a.reputation, err = reputation.FromEnv(outbound)
+	if err != nil {
+		return fmt.Errorf("invalid IP reputation configuration: %w", err)
+	}
+	if a.reputation != nil {
+		slog.Info("IP reputation checks enabled")
+	}
+
+	alerts, err := alert.FromEnv(outbound)
+	if err != nil {
+		return fmt.Errorf("invalid alert configuration: %w", err)
+	}
+
+	publishHook, err := publish.FromEnv(outbound)
+	if err != nil {
+		return fmt.Errorf("invalid publish hook configuration: %w", err)
+	}
+
+	notifier, err := notify.FromEnv(outbound)
+	if err != nil {
+		return fmt.Errorf("invalid webhook configuration: %w", err)
+	}
+
+	a.mirror, err = mirror.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid request mirroring configuration: %w", err)
+	}
+	if a.mirror != nil {
+		slog.Info("request mirroring enabled",
+			slog.Int("percent", a.mirror.Percent()),
+		)
+	}
+
+	a.flags, err = flags.FromEnv(queries)
+	if err != nil {
+		return fmt.Errorf("invalid feature flag configuration: %w", err)
+	}
+	// Missing overrides only leave the defaults in place until the next refresh
+	if err := a.flags.Refresh(ctx); err != nil {
+		slog.Warn("failed to load feature flags",
+			slog.String("error", err.Error()),
+		)
+	}
+
+	a.blocklist = reputation.NewManagedBlocklist(queries)
+	// Until the next refresh only IP_REPUTATION_BLOCKLIST applies
+	if err := a.blocklist.Refresh(ctx); err != nil {
+		slog.Warn("failed to load blocked networks",
+			slog.String("error", err.Error()),
+		)
+	}
+	if a.reputation == nil {
+		a.reputation = reputation.NewChecker(nil, reputation.DefaultConfig())
+	}
+	a.reputation.WithBlocklist(a.blocklist)
+
+	a.readOnly = readonly.New(cfg.ReadOnly)
+	if cfg.ReadOnly {
+		slog.Warn("read-only mode enabled, uploads and other changes are refused")
+	}
+
+	sessionSecret, err := loadSessionSecret()
+	if err != nil {
+		return err
+	}
+
+	// Share status changes are fanned out to recipients and uploaders
+	// watching a share
+	a.events = events.NewBus()
+
+	// Chunks spilled by a previous process that crashed mid-upload
+	spooler := spool.New(cfg.Multipart.MemoryBytes, cfg.Multipart.TempDir)
+	if removed, err := spooler.RemoveStale(); err != nil {
+		slog.Warn("failed to remove stale spool files",
+			slog.String("error", err.Error()),
+		)
+	} else if removed > 0 {
+		slog.Info("removed stale spool files",
+			slog.Int("count", removed),
+		)
+	}
+
+	// Initialize services
+	fileService := service.NewFileService(queries, runTx, minioClient.Client, cfg.Limits).
+		WithShareIDGenerator(shareIDGen).
+		WithTransfer(cfg.Transfer).
+		WithPublishHook(publishHook).
+		WithNotifier(notifier).
+		WithUploadSlots(cfg.UploadSlots).
+		WithCleanupInterval(cfg.CleanupInterval).
+		WithReturnURLSchemes(cfg.ReturnURLSchemes).
+		WithEvents(a.events).
+		WithFlags(a.flags).
+		WithReadOnly(a.readOnly).
+		WithTimeouts(cfg.Timeouts)
+	chunkService := service.NewChunkService(queries, minioClient.Client, minioClient.BucketName, cfg.Limits).
+		WithAlerts(alerts).
+		WithMultipart(cfg.Multipart).
+		WithStorageUpload(cfg.StorageUpload).
+		WithDownloadCompleter(fileService).
+		WithDownloadRate(cfg.DownloadRate).
+		WithEvents(a.events).
+		WithReadOnly(a.readOnly)
+	a.transfers = service.NewTransferStats(queries)
+	chunkService.WithTransferStats(a.transfers)
+	if len(cfg.UploadSlots.APIKeys) > 0 {
+		slog.Info("upload slots enabled",
+			slog.Int("api_keys", len(cfg.UploadSlots.APIKeys)),
+			slog.Duration("slot_ttl", cfg.UploadSlots.TTL),
+		)
+	}
+	if abuseScorer != nil {
+		fileService.WithAbuseScorer(abuseScorer)
+
+		slog.Info("abuse scoring enabled")
+	}
+	if storageEnvelope != nil {
+		chunkService.WithEnvelope(storageEnvelope)
+
+		slog.Info("storage envelope encryption enabled",
+			slog.String("key_id", storageEnvelope.KeyID()),
+		)
+	}
+	if cfg.FinalizeVerify != config.FinalizeVerifyOff {
+		fileService.WithChunkVerifier(chunkService, cfg.FinalizeVerify == config.FinalizeVerifyHash).
+			WithAsyncVerification(cfg.Verification.AsyncChunks)
+		chunkService.WithVerifyWorkers(cfg.Verification.Workers)
+
+		slog.Info("finalize chunk verification enabled",
+			slog.String("mode", cfg.FinalizeVerify),
+			slog.Int("workers", cfg.Verification.Workers),
+		)
+	}
+	if serverSealer != nil {
+		fileService.WithServerEncryption(serverSealer)
+		chunkService.WithServerEncryption(serverSealer)
+
+		slog.Info("server-side encryption enabled",
+			slog.String("key_id", serverSealer.KeyID()),
+		)
+	}
+
+	if watermarker != nil {
+		chunkService.WithWatermarker(watermarker)
+
+		slog.Info("download watermarking enabled")
+	}
+
+	if cfg.PresignedURLTTL > 0 {
+		if minioClient.PresignClient == nil {
+			slog.Warn("presigned uploads disabled: not supported by storage provider",
+				slog.String("provider", minioClient.Provider),
+			)
+		} else if storageEnvelope != nil {
+			slog.Warn("presigned uploads disabled: chunks must pass through the API to be sealed")
+		} else {
+			chunkService.WithPresignedUploads(minioClient.PresignClient, cfg.PresignedURLTTL)
+			fileService.WithChunkPresigner(chunkService)
+
+			slog.Info("presigned chunk uploads enabled",
+				slog.Duration("url_ttl", cfg.PresignedURLTTL),
+			)
+		}
+	}
+
+	if cfg.DownloadBandwidth > 0 {
+		a.fairShare = fairshare.New(cfg.DownloadBandwidth)
+		chunkService.WithFairShare(a.fairShare)
+
+		slog.Info("fair share download pacing enabled",
+			slog.Int64("bytes_per_second", cfg.DownloadBandwidth),
+		)
+	}
+
+	// Cleanup runs on a schedule and simply tries again next interval
+	cleanupQueries := database.NewRetryingQuerier(a.DB.Queries, database.DefaultRetryPolicy(1))
+	cleanupService := service.NewCleanupService(cleanupQueries, minioClient.Client, minioClient.BucketName)
+	if !minioClient.BulkDelete {
+		cleanupService.WithSingleDeletes()
+	}
+	cleanupService.WithNotifier(notifier).
+		WithEvents(a.events).
+		WithStaleUploadSweep(cfg.StorageUpload.StaleUploadAge).
+		WithExpiredRetention(cfg.ExpiredRetention).
+		WithOrphanGrace(cfg.PresignedURLTTL).
+		WithReconciliation(cfg.Reconciliation)
+	if notifier != nil {
+		slog.Info("uploader webhooks enabled")
+	}
+	if cfg.UploadQuota.DailyCount > 0 || cfg.UploadQuota.DailyBytes > 0 {
+		fileService.WithUploadQuota(cfg.UploadQuota)
+
+		slog.Info("daily upload quota enabled",
+			slog.Int64("daily_count", cfg.UploadQuota.DailyCount),
+			slog.Int64("daily_bytes", cfg.UploadQuota.DailyBytes),
+		)
+	}
+
+	if cfg.UploadSquatting.Threshold > 0 {
+		fileService.WithUploadSquatting(cfg.UploadSquatting)
+
+		slog.Info("upload squatting detection enabled",
+			slog.Int64("threshold", cfg.UploadSquatting.Threshold),
+			slog.Duration("window", cfg.UploadSquatting.Window),
+			slog.String("action", cfg.UploadSquatting.Action),
+		)
+	}
+	if cfg.StorageQuota.Bytes > 0 {
+		if cfg.StorageQuota.Policy == config.QuotaPolicyEvict {
+			fileService.WithStorageQuota(cfg.StorageQuota.Bytes, cleanupService)
+		} else {
+			fileService.WithStorageQuota(cfg.StorageQuota.Bytes, nil)
+		}
+
+		slog.Info("storage quota enabled",
+			slog.Int64("quota_bytes", cfg.StorageQuota.Bytes),
+			slog.String("policy", cfg.StorageQuota.Policy),
+		)
+	}
+
+	a.FileService = fileService
+	a.ChunkService = chunkService
+	a.CleanupService = cleanupService
+	a.SessionService = service.NewSessionService(queries, sessionSecret, loadSessionTTL())
+	fileService.WithManagementSessions(a.SessionService)
+	a.ExportService = service.NewExportService(queries)
+	a.AdminService = service.NewAdminService(queries, runTx).
+		WithEvents(a.events).
+		WithFlags(a.flags).
+		WithReadOnly(a.readOnly).
+		WithBlocklist(a.blocklist).
+		WithWatermarking(watermarker != nil).
+		WithSupportPreviews(sessionSecret, cfg.SupportPreviewTTL)
+	a.RetentionService = service.NewRetentionService(queries)
+	a.PasteService = service.NewPasteService(queries, cfg.Limits).
+		WithShareIDGenerator(shareIDGen).
+		WithReadOnly(a.readOnly)
+
+	a.health = health.New().
+		Add("database", a.DB.Pool.Ping).
+		Add("storage", func(ctx context.Context) error {
+			exists, err := a.Storage.Client.BucketExists(ctx, a.Storage.BucketName)
+			if err == nil && !exists {
+				err = fmt.Errorf("bucket %s does not exist", a.Storage.BucketName)
+			}
+			return err
+		})
+
+	a.scheduler = scheduler.New(cleanupService, cfg.CleanupInterval).
+		WithRetentionReports(a.RetentionService).
+		WithReconciliation(cleanupService, cfg.Reconciliation.Interval).
+		WithTransferStats(a.transfers)
+	a.AdminService.WithJobs(a.scheduler, fileService)
+
+	devRoutes := isDevelopment(os.Getenv("APP_ENV"))
+	if o.devRoutes != nil {
+		devRoutes = *o.devRoutes
+	}
+	a.router = a.newRouter(devRoutes)
+
+	return nil
+}
+
+func (a *App) newRouter(devRoutes bool) chi.Router {
+	cfg := a.Config
+	bucketName := a.Storage.BucketName
+
+	r := chi.NewRouter()
+
+	// CORS middleware
+	r.Use(custommiddleware.CORS(cfg.CORS))
+
+	// Applies to every rate limiter mounted below
+	custommiddleware.SetRateLimitExemptions(cfg.RateLimitExemptions)
+	custommiddleware.SetReputation(a.reputation)
+	custommiddleware.SetDownloadStreamLimit(cfg.DownloadStreamsPerShare)
+	custommiddleware.SetTrustedProxies(cfg.TrustedProxies)
+
+	// Standard middleware
+	r.Use(logger.RequestLogger)
+	r.Use(logger.RequestID)
+	r.Use(middleware.Recoverer)
+	r.Use(custommiddleware.PlainTextErrors())
+	r.Use(custommiddleware.LimitJSONBody(cfg.Server.MaxJSONBodyBytes))
+
+	// Chunks are encrypted and would not shrink, so only text is compressed
+	if cfg.CompressionLevel > 0 {
+		r.Use(middleware.Compress(cfg.CompressionLevel,
+			"application/json",
+			utils.NDJSONContentType,
+			"text/csv",
+		))
+	}
+
+	// Replay a sample of read-only requests against the canary
+	if a.mirror != nil {
+		r.Use(a.flags.Gate(flags.RequestMirroring, a.mirror.Middleware))
+	}
+
+	// Health check endpoint
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	// Answered from the background probes unless ?live=1
+	r.Get("/healthz", a.health.ServeHTTP)
+	r.Head("/healthz", a.health.ServeHTTP)
+
+	// Readiness endpoint
+	r.Get("/readyz", a.readyz)
+
+	// Metrics endpoint
+	r.Get("/metrics", serveMetrics)
+
+	// API description
+	r.Get("/api/v1/openapi.json", openapi.Handler)
+	r.Get("/api/v1/docs", openapi.UIHandler("/api/v1/openapi.json"))
+
+	// Mount routes
+	// Read-only mode refuses every change but the admin API's, which can
+	// switch it off again
+	r.With(a.readOnly.RejectWrites).Mount("/api/v1/files", routes.FileRoutes(a.FileService, a.ChunkService, bucketName))
+	r.With(a.readOnly.RejectWrites).Mount("/api/v1/download", routes.DownloadRoutes(a.FileService, a.ChunkService, bucketName))
+	r.With(a.readOnly.RejectWrites).Mount("/api/v1/bundles", routes.BundleRoutes(a.FileService, a.ChunkService, bucketName))
+	r.With(a.readOnly.RejectWrites).Mount("/api/v1/pastes", routes.PasteRoutes(a.PasteService))
+	r.Mount("/api/v1/manage", routes.ManageRoutes(a.SessionService, a.ExportService, a.readOnly))
+	if cfg.AdminAPIToken != "" {
+		r.Mount("/api/v1/admin", routes.AdminRoutes(a.AdminService, a.CleanupService, a.RetentionService, cfg.AdminAPIToken))
+		// Preview tokens are only issued through the admin API
+		r.Mount("/api/v1/support", routes.SupportRoutes(a.AdminService))
+
+		slog.Info("admin API enabled")
+	}
+
+	// Signed protocol parameters for clients to pin
+	if a.configSigner != nil {
+		r.Mount("/.well-known", routes.WellKnownRoutes(cfg, a.ChunkService.PresignedUploads(), a.flags, a.configSigner))
+
+		slog.Info("client config bundle enabled",
+			slog.String("key_id", a.configSigner.KeyID()),
+		)
+	}
+
+	// Development-only routes
+	if devRoutes {
+		r.Mount("/api/v1/dev", routes.DevRoutes(cfg.Limits))
+	}
+
+	return r
+}
+
+// readyz reports 503 while the database is unreachable, as of the last
+// background probe unless ?live=1 asks for a fresh one. Feature flags are
+// included so operators can see what this instance has switched off.
+func (a *App) readyz(w http.ResponseWriter, r *http.Request) {
+	probe := a.health.StatusFor(r)
+
+	resp := types.ReadinessResponse{
+		Status:   "ready",
+		Checks:   probe.Checks,
+		Flags:    a.flags.All(),
+		ReadOnly: a.readOnly.Enabled(),
+	}
+	status := http.StatusOK
+	if probe.Checks["database"] != "ok" {
+		resp.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	utils.WriteJSON(w, status, utils.APIResponse{Success: status == http.StatusOK, Data: resp})
+}
+
+// Handler returns the router with every route mounted, for serving it
+// without Start, e.g. from httptest.
+func (a *App) Handler() http.Handler {
+	return a.router
+}
+
+// Start runs the background jobs and serves on addr. The returned channel
+// receives the error that stopped the server, unless Stop stopped it.
+func (a *App) Start(addr string) <-chan error {
+	bgCtx, cancel := context.WithCancel(context.Background())
+	a.cancelBackground = cancel
+
+	if a.fairShare != nil {
+		a.fairShare.Start(bgCtx)
+	}
+	a.scheduler.Start(bgCtx)
+	a.flags.Start(bgCtx, flags.DefaultRefreshInterval)
+	a.blocklist.Start(bgCtx, reputation.DefaultBlocklistRefreshInterval)
+	a.health.Start(bgCtx, a.Config.HealthProbeInterval)
+	custommiddleware.StartRateLimitReporter(bgCtx)
+
+	a.server = &http.Server{
+		Addr:              addr,
+		Handler:           a.router,
+		ReadHeaderTimeout: a.Config.Server.ReadHeaderTimeout,
+		ReadTimeout:       a.Config.Server.ReadTimeout,
+		WriteTimeout:      a.Config.Server.WriteTimeout,
+		IdleTimeout:       a.Config.Server.IdleTimeout,
+		MaxHeaderBytes:    a.Config.Server.MaxHeaderBytes,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting",
+			slog.String("address", addr),
+		)
+		if err := a.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	return serverErr
+}
+
+// Stop lets in-flight requests finish until ctx is done, waits for a running
+// scheduled job for what is left of ctx, records the bytes served since the
+// last transfer stats flush and then closes the database and storage clients
+// New opened.
+func (a *App) Stop(ctx context.Context) error {
+	// Share event streams would otherwise hold Shutdown until ctx is done
+	a.events.Close()
+
+	var err error
+	if a.server != nil {
+		if shutdownErr := a.server.Shutdown(ctx); shutdownErr != nil {
+			err = fmt.Errorf("server shutdown failed: %w", shutdownErr)
+		}
+	}
+
+	if a.cancelBackground != nil {
+		// A running cleanup finishes its batch unless ctx runs out first
+		if stopErr := a.scheduler.Stop(ctx); stopErr != nil {
+			err = errors.Join(err, stopErr)
+		}
+		// Bytes served since the last scheduled flush
+		if flushErr := a.transfers.Flush(ctx); flushErr != nil {
+			err = errors.Join(err, flushErr)
+		}
+		a.cancelBackground()
+	}
+
+	a.close()
+	return err
+}
+
+// Run starts the app and blocks until ctx is done or the server fails, then
+// stops it, giving in-flight requests up to shutdownTimeout to finish.
+func (a *App) Run(ctx context.Context, addr string, shutdownTimeout time.Duration) error {
+	var runErr error
+	select {
+	case err := <-a.Start(addr):
+		runErr = fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		slog.Info("shutdown signal received")
+	}
+
+	// Stop accepting new requests and let in-flight uploads finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.Stop(shutdownCtx); err != nil {
+		runErr = errors.Join(runErr, err)
+	}
+
+	slog.Info("server stopped")
+	return runErr
+}
+
+func (a *App) close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
+
+// runtimeVars are the variables the expvar package publishes itself. They
+// expose the command line, which may hold secrets, and process internals,
+// so /metrics leaves them out.
+var runtimeVars = map[string]bool{"cmdline": true, "memstats": true}
+
+// serveMetrics writes the app's own expvar counters as one JSON object, in
+// the format of expvar.Handler.
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if runtimeVars[kv.Key] {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
+
+// isDevelopment reports whether env asks for development-only routes. An
+// unset APP_ENV does not, so a deploy that forgets it is not exposed.
+func isDevelopment(env string) bool {
+	return env == "development"
+}
+
+func loadSessionSecret() ([]byte, error) {
+	if secret := os.Getenv("MANAGEMENT_SESSION_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+
+	slog.Warn("MANAGEMENT_SESSION_SECRET not set, generating a random secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate session secret: %w", err)
+	}
+	return secret, nil
+}
+
+func loadSessionTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("MANAGEMENT_SESSION_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilkin0/gzln/db"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Integration_MountsRoutes(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	t.Setenv("ADMIN_API_TOKEN", "")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		devRoutes bool
+		wantDev   int
+	}{
+		{name: "dev routes enabled", devRoutes: true, wantDev: http.StatusOK},
+		{name: "dev routes disabled", devRoutes: false, wantDev: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(context.Background(), cfg,
+				WithDatabase(containers.Database),
+				WithStorage(containers.MinioClient),
+				WithDevRoutes(tt.devRoutes),
+			)
+			require.NoError(t, err)
+			defer a.Stop(context.Background())
+
+			w := httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			w = httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/files", nil))
+			assert.Equal(t, http.StatusNotFound, w.Code, "admin API must not be mounted without a token")
+
+			w = httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/dev/conformance/vectors", nil))
+			assert.Equal(t, tt.wantDev, w.Code)
+		})
+	}
+}
+
+func TestApp_Integration_RunMigrations(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.RunMigrations = true
+
+	// The containers are migrated already, so there is nothing left to apply
+	a, err := New(context.Background(), cfg,
+		WithDatabase(containers.Database),
+		WithStorage(containers.MinioClient),
+	)
+	require.NoError(t, err)
+	defer a.Stop(context.Background())
+
+	pending, err := database.PendingMigrations(context.Background(), containers.Database.Pool, db.Migrations())
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	applied, err := database.Migrate(context.Background(), containers.Database.Pool, db.Migrations())
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
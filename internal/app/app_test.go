@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDevelopment(t *testing.T) {
+	tests := []struct {
+		env  string
+		want bool
+	}{
+		{env: "development", want: true},
+		{env: "", want: false},
+		{env: "production", want: false},
+		{env: "Development", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			assert.Equal(t, tt.want, isDevelopment(tt.env))
+		})
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	serveMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "share_id_collisions")
+	assert.NotContains(t, vars, "cmdline")
+	assert.NotContains(t, vars, "memstats")
+}
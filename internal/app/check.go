@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/abuse"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/envelope"
+	"github.com/ilkin0/gzln/internal/httpclient"
+	"github.com/ilkin0/gzln/internal/idgen"
+	"github.com/ilkin0/gzln/internal/storage"
+	"github.com/ilkin0/gzln/internal/watermark"
+)
+
+// Outcomes of a diagnostics check.
+const (
+	CheckOK      = "ok"
+	CheckWarn    = "warn"
+	CheckFail    = "fail"
+	CheckSkipped = "skipped"
+)
+
+// checkTimeout bounds reaching each dependency during diagnostics, which
+// try once instead of waiting for dependencies to come up.
+const checkTimeout = 5 * time.Second
+
+// CheckResult is the outcome of one diagnostics check.
+type CheckResult struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+type CheckResults []CheckResult
+
+// Failed reports whether any check failed. Warnings do not fail.
+func (r CheckResults) Failed() bool {
+	for _, result := range r {
+		if result.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates the configuration, reaches the database and object
+// storage once and compares the applied migrations with those in
+// migrations, without starting anything. It is meant for pre-deploy gates
+// and first-run troubleshooting.
+func Check(ctx context.Context, migrations fs.FS) CheckResults {
+	var results CheckResults
+	add := func(name, status, detail string) {
+		results = append(results, CheckResult{Name: name, Status: status, Detail: detail})
+	}
+
+	// Dependencies are still checked with the defaults if the config is bad
+	cfg, err := config.Load()
+	if err != nil {
+		add("config", CheckFail, err.Error())
+	} else {
+		add("config", CheckOK, "profile "+cfg.Profile)
+	}
+
+	if err := checkEnv(); err != nil {
+		add("environment", CheckFail, err.Error())
+	} else if os.Getenv("MANAGEMENT_SESSION_SECRET") == "" {
+		add("environment", CheckWarn, "MANAGEMENT_SESSION_SECRET not set, sessions end on restart")
+	} else {
+		add("environment", CheckOK, "")
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	db, err := database.NewDatabase(dbCtx, cfg.Database)
+	if err != nil {
+		add("database", CheckFail, err.Error())
+		add("migrations", CheckSkipped, "database not reachable")
+	} else {
+		defer db.Pool.Close()
+		add("database", CheckOK, "")
+
+		pending, err := database.PendingMigrations(dbCtx, db.Pool, migrations)
+		switch {
+		case err != nil:
+			add("migrations", CheckFail, err.Error())
+		case len(pending) > 0:
+			add("migrations", CheckFail, fmt.Sprintf("%d pending, oldest %d", len(pending), pending[0]))
+		default:
+			add("migrations", CheckOK, "up to date")
+		}
+	}
+
+	// storage.New does not take a context, so its own timeouts apply
+	client, err := storage.New()
+	if err != nil {
+		add("storage", CheckFail, err.Error())
+	} else {
+		client.Close()
+		add("storage", CheckOK, fmt.Sprintf("%s bucket %s", client.Provider, client.BucketName))
+	}
+
+	return results
+}
+
+// checkEnv runs the environment parsing New does outside of config.Load, for
+// the settings that need no dependencies to validate.
+func checkEnv() error {
+	var errs []error
+	check := func(what string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s configuration: %w", what, err))
+		}
+	}
+
+	_, err := idgen.FromEnv()
+	check("share ID", err)
+	_, err = envelope.FromEnv()
+	check("storage encryption", err)
+	_, err = crypto.SealerFromEnv()
+	check("server encryption", err)
+	_, err = crypto.SignerFromEnv()
+	check("config signing", err)
+	_, err = watermark.CommandFromEnv()
+	check("watermark", err)
+	_, err = abuse.FromEnv()
+	check("abuse scoring", err)
+	_, err = httpclient.FromEnv()
+	check("outbound HTTP", err)
+
+	return errors.Join(errs...)
+}
+
+// LogBanner logs what the server is about to run with in one structured
+// line, so a deployment's effective settings can be read from its logs.
+func (a *App) LogBanner(version, addr string) {
+	cfg := a.Config
+	slog.Info("gzln starting",
+		slog.String("version", version),
+		slog.String("go", runtime.Version()),
+		slog.String("profile", cfg.Profile),
+		slog.String("address", addr),
+		slog.Bool("read_only", cfg.ReadOnly),
+		slog.Group("database",
+			slog.Int("max_conns", int(cfg.Database.MaxConns)),
+			slog.Int("min_conns", int(cfg.Database.MinConns)),
+			slog.Duration("statement_timeout", cfg.Database.StatementTimeout),
+		),
+		slog.Group("storage",
+			slog.String("provider", a.Storage.Provider),
+			slog.String("bucket", a.Storage.BucketName),
+		),
+		slog.Group("limits",
+			slog.Int64("max_file_size", cfg.Limits.MaxFileSize),
+			slog.Int64("max_chunk_size", cfg.Limits.MaxChunkSize),
+			slog.Int("max_chunk_count", int(cfg.Limits.MaxChunkCount)),
+			slog.Duration("default_expiry", cfg.Limits.DefaultExpiry),
+		),
+		slog.Group("timeouts",
+			slog.Duration("read", cfg.Server.ReadTimeout),
+			slog.Duration("write", cfg.Server.WriteTimeout),
+			slog.Duration("finalize", cfg.Timeouts.Finalize),
+		),
+	)
+}
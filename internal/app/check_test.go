@@ -0,0 +1,33 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("PROFILE", "bogus")
+	t.Setenv("DB_URL", "")
+	t.Setenv("STORAGE_PROVIDER", "bogus")
+	t.Setenv("MANAGEMENT_SESSION_SECRET", "")
+
+	results := Check(context.Background(), fstest.MapFS{})
+
+	status := make(map[string]string, len(results))
+	for _, r := range results {
+		status[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]string{
+		"config":      CheckFail,
+		"environment": CheckWarn,
+		"database":    CheckFail,
+		"migrations":  CheckSkipped,
+		"storage":     CheckFail,
+	}, status)
+	assert.True(t, results.Failed())
+
+	assert.False(t, CheckResults{{Name: "environment", Status: CheckWarn}}.Failed())
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// backoff spaces out the attempts to reach a dependency at startup.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// startupBackoff waits about a minute over ten attempts.
+var startupBackoff = backoff{base: 500 * time.Millisecond, max: 10 * time.Second}
+
+// connectWithRetry runs connect until it succeeds, fails with an error that
+// retryable rejects, or attempts are used up, so the server can start next
+// to dependencies that are still coming up. The wait between attempts
+// doubles up to the backoff's max, with jitter so instances started
+// together do not retry in lockstep.
+func connectWithRetry(ctx context.Context, name string, attempts int, b backoff, retryable func(error) bool, connect func() error) error {
+	delay := b.base
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("dependency reachable",
+					slog.String("dependency", name),
+					slog.Int("attempt", attempt),
+				)
+			}
+			return nil
+		}
+		if attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		wait := delay/2 + rand.N(delay/2+1)
+		slog.Warn("dependency not reachable, retrying",
+			slog.String("dependency", name),
+			slog.Int("attempt", attempt),
+			slog.Int("attempts", attempts),
+			slog.Duration("retry_in", wait),
+			slog.String("error", err.Error()),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, b.max)
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectWithRetry(t *testing.T) {
+	fast := backoff{base: time.Millisecond, max: 2 * time.Millisecond}
+	errDown := errors.New("connection refused")
+	errAuth := errors.New("password authentication failed")
+	retryable := func(err error) bool { return errors.Is(err, errDown) }
+
+	tests := []struct {
+		name      string
+		failures  []error
+		attempts  int
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "up at once", attempts: 3, wantCalls: 1},
+		{name: "comes up", failures: []error{errDown, errDown}, attempts: 3, wantCalls: 3},
+		{name: "gives up", failures: []error{errDown, errDown, errDown}, attempts: 3, wantErr: errDown, wantCalls: 3},
+		{name: "not retryable", failures: []error{errAuth}, attempts: 3, wantErr: errAuth, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := connectWithRetry(context.Background(), "database", tt.attempts, fast, retryable, func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestConnectWithRetry_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+
+	err := connectWithRetry(ctx, "storage", 10, startupBackoff, func(error) bool { return true }, func() error {
+		calls++
+		return errors.New("connection refused")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
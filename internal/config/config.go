@@ -0,0 +1,1208 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits holds the upload defaults and ceilings enforced by the services.
+type Limits struct {
+	DefaultMaxDownloads int32
+	DefaultExpiry       time.Duration
+	MaxFileSize         int64
+	MaxChunkSize        int64
+	// MaxChunkCount caps the chunks of one file, which bounds the rows,
+	// requests and presigned URLs a single upload can ask for.
+	MaxChunkCount int32
+	// MaxChunkRequestSize caps the body of a chunk upload request, including
+	// the encryption overhead and multipart framing around the chunk.
+	MaxChunkRequestSize int64
+}
+
+// UnlimitedDownloads as max_downloads lets a share be downloaded any number
+// of times until it expires.
+const UnlimitedDownloads = -1
+
+// ChunkRequestOverhead is the default allowance on top of MaxChunkSize for
+// the rest of a chunk upload request.
+const ChunkRequestOverhead = 1 << 20
+
+// DefaultRetryAttempts is how many times a read or transaction runs before a
+// transient database error is returned.
+const DefaultRetryAttempts = 3
+
+// Defaults for the Postgres pool beyond its size.
+const (
+	DefaultDBMaxConnLifetime   = time.Hour
+	DefaultDBHealthCheckPeriod = time.Minute
+)
+
+// DefaultConnectAttempts is how often the database and object storage are
+// tried at startup, waiting about a minute in total for them to come up.
+const DefaultConnectAttempts = 10
+
+// maxPresignedTTLMinutes is the longest validity S3 allows for a presigned
+// URL: seven days.
+const maxPresignedTTLMinutes = 7 * 24 * 60
+
+// minUploadSlotKeyLength keeps upload slot API keys out of brute force range.
+const minUploadSlotKeyLength = 32
+
+// minAdminTokenLength keeps the admin API token out of brute force range.
+const minAdminTokenLength = 32
+
+// DefaultSupportPreviewTTL is how long a support preview token stays valid.
+const DefaultSupportPreviewTTL = time.Hour
+
+// DefaultReconcileInterval is how often storage is reconciled with the
+// database when RECONCILE_INTERVAL_HOURS is unset.
+const DefaultReconcileInterval = 6 * time.Hour
+
+// DefaultExpiredRetention is how long the rows of expired files are kept
+// when EXPIRED_RETENTION_DAYS is unset. It outlasts the month a file may
+// count towards in retention reports.
+const DefaultExpiredRetention = 90 * 24 * time.Hour
+
+// defaultUploadSlotTTLMinutes is how long a reserved upload slot may wait for
+// its upload.
+const defaultUploadSlotTTLMinutes = 15
+
+type Config struct {
+	Profile         string
+	Server          Server
+	Limits          Limits
+	Database        Database
+	Transfer        Transfer
+	CleanupInterval time.Duration
+	// ExpiredRetention is how long the rows of an expired file are kept
+	// after its chunks were purged, before they are deleted for good. Zero
+	// keeps them forever.
+	ExpiredRetention time.Duration
+	Reconciliation   Reconciliation
+	// DownloadBandwidth caps total chunk download throughput in bytes per
+	// second, shared fairly between shares. Zero disables pacing.
+	DownloadBandwidth int64
+	// DownloadRate caps each chunk download and file stream in bytes per
+	// second, unless the file overrides it. Zero leaves them unthrottled.
+	DownloadRate int64
+	// DownloadStreamsPerShare caps the chunk downloads and streams of one
+	// share in progress at once on an instance. Zero removes the cap.
+	DownloadStreamsPerShare int
+	// PresignedURLTTL is how long presigned chunk upload URLs stay valid.
+	// Zero disables presigned uploads.
+	PresignedURLTTL time.Duration
+	UploadSlots     UploadSlots
+	// CompressionLevel is the gzip level (1-9) for JSON, NDJSON and CSV
+	// responses. Zero disables compression.
+	CompressionLevel int
+	StorageQuota     StorageQuota
+	UploadQuota      UploadQuota
+	UploadSquatting  UploadSquatting
+	// AdminAPIToken authenticates the operator admin API. The API is not
+	// mounted without it.
+	AdminAPIToken string
+	// SupportPreviewTTL is how long the preview tokens the admin API issues
+	// to support staff stay valid.
+	SupportPreviewTTL time.Duration
+	Multipart         Multipart
+	StorageUpload     StorageUpload
+	Timeouts          OperationTimeouts
+	// FinalizeVerify is how stored chunks are checked before a file is
+	// marked ready: FinalizeVerifyOff, FinalizeVerifyStat or
+	// FinalizeVerifyHash.
+	FinalizeVerify string
+	Verification   Verification
+	CORS           CORS
+	// RateLimitExemptions lets internal clients through the rate limits.
+	RateLimitExemptions RateLimitExemptions
+	// TrustedProxies are the networks whose X-Forwarded-For names the
+	// client that quotas and abuse scoring apply to.
+	TrustedProxies []netip.Prefix
+	// ReturnURLSchemes are the URL schemes uploaders may send recipients to
+	// once their download finished.
+	ReturnURLSchemes []string
+	// ReadOnly starts the server refusing uploads and other changes, while
+	// existing shares can still be downloaded.
+	ReadOnly bool
+	// HealthProbeInterval is how often the database and storage are
+	// probed for the health endpoints.
+	HealthProbeInterval time.Duration
+	// StorageConnectAttempts bounds how often object storage is tried at
+	// startup before the server gives up on it.
+	StorageConnectAttempts int
+	// RunMigrations applies pending database migrations at startup.
+	RunMigrations bool
+}
+
+// DefaultHealthProbeInterval keeps health endpoints at most this stale.
+const DefaultHealthProbeInterval = 10 * time.Second
+
+// DefaultReturnURLSchemes allows return URLs on https only.
+var DefaultReturnURLSchemes = []string{"https"}
+
+// unsafeReturnURLSchemes run code or read local data in the recipient's
+// browser, so they are never allowed as return URLs.
+var unsafeReturnURLSchemes = []string{"javascript", "data", "vbscript", "file", "blob"}
+
+// schemePattern matches a URL scheme as RFC 3986 defines it.
+var schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// DefaultRateLimitRaiseFactor multiplies every rate limit for the networks
+// in RateLimitExemptions.Raised.
+const DefaultRateLimitRaiseFactor = 10
+
+// RateLimitExemptions lists client networks that are not held to the normal
+// rate limits, such as health checkers and internal batch jobs. Clients are
+// matched by their connection address, never by forwarding headers.
+type RateLimitExemptions struct {
+	// Bypass networks skip rate limiting entirely.
+	Bypass []netip.Prefix
+	// Raised networks get every limit multiplied by RaiseFactor.
+	Raised      []netip.Prefix
+	RaiseFactor int
+}
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response.
+const DefaultCORSMaxAge = 24 * time.Hour
+
+// devOrigins are the local frontend dev servers, allowed while
+// CORS_ALLOWED_ORIGINS is unset.
+var devOrigins = []string{
+	"http://localhost:5173",
+	"http://localhost:4173",
+	"http://localhost:3000",
+}
+
+// CORS decides which browser origins may call the API.
+type CORS struct {
+	// AllowedOrigins are exact origins such as https://gzln.example.com.
+	AllowedOrigins []string
+	// AllowAll allows every origin, for APIs used by any frontend. Since
+	// browsers then send no credentials, it suits token-authenticated use
+	// only.
+	AllowAll bool
+	// OriginPattern, when set, also allows origins it fully matches.
+	OriginPattern *regexp.Regexp
+	MaxAge        time.Duration
+}
+
+// DefaultMultipartMemory is how much of a chunk upload is held in memory
+// before the rest spills to disk.
+const DefaultMultipartMemory = 1 << 20
+
+// Multipart bounds the resources used to receive multipart chunk uploads.
+type Multipart struct {
+	// MemoryBytes of a chunk are held in memory; larger chunks spill to a
+	// temporary file in TempDir until they are written to storage.
+	MemoryBytes int64
+	// TempDir receives spilled chunks. Empty uses the system temporary
+	// directory.
+	TempDir string
+}
+
+func DefaultMultipart() Multipart {
+	return Multipart{MemoryBytes: DefaultMultipartMemory}
+}
+
+// S3 multipart part size bounds.
+const (
+	MinStoragePartSize = 5 << 20
+	MaxStoragePartSize = 5 << 30
+)
+
+// Defaults for bounding chunk writes and sweeping what failed ones leave.
+const (
+	DefaultStoragePutTimeout     = 5 * time.Minute
+	DefaultStorageStaleUploadAge = 24 * time.Hour
+)
+
+// StorageUpload tunes how chunks are written to object storage, for backends
+// that prefer larger parts, more parallelism or no multipart at all. Zero
+// part settings keep the storage client's defaults.
+type StorageUpload struct {
+	// PartSize splits chunks larger than it into multipart uploads.
+	PartSize uint64
+	// Threads is how many parts of one chunk are uploaded at once.
+	Threads uint
+	// ConcurrentStreamParts uploads parts of streamed chunks in parallel
+	// too, buffering up to Threads parts in memory per upload.
+	ConcurrentStreamParts bool
+	// SinglePutMaxBytes sends chunks up to this size in a single PUT,
+	// however they compare to PartSize.
+	SinglePutMaxBytes int64
+	// PutTimeout bounds each chunk write. A write that fails or runs out of
+	// time has its multipart upload aborted. Zero means no deadline.
+	PutTimeout time.Duration
+	// StaleUploadAge is how old an incomplete multipart upload must be for
+	// cleanup to abort it. Zero disables the sweep.
+	StaleUploadAge time.Duration
+}
+
+// Defaults for the HTTP server. A chunk upload or download must fit in the
+// read or write timeout.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 10 * time.Minute
+	DefaultWriteTimeout      = 10 * time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultMaxHeaderBytes    = 64 << 10
+	DefaultMaxJSONBodyBytes  = 1 << 20
+)
+
+// Server bounds what a client may hold on to or send, so slow clients and
+// huge bodies cannot exhaust the server. Zero timeouts mean no deadline.
+type Server struct {
+	// ReadHeaderTimeout bounds reading a request's headers, which is what
+	// slowloris clients drag out.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading a whole request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing a response. Event and NDJSON streams, file
+	// streams and bundle archives are exempt.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection may wait for its
+	// next request.
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+	// MaxJSONBodyBytes caps request bodies other than multipart chunk
+	// uploads, which have their own limit. Zero disables the cap.
+	MaxJSONBodyBytes int64
+}
+
+// Defaults for bounding the work requests start in the services.
+const (
+	DefaultLookupTimeout   = 10 * time.Second
+	DefaultInitTimeout     = 30 * time.Second
+	DefaultFinalizeTimeout = 5 * time.Minute
+)
+
+// OperationTimeouts bounds service operations on top of the request they run
+// for, so a stuck database or storage call fails instead of holding on to
+// connections. Zero means no deadline.
+type OperationTimeouts struct {
+	// Lookup bounds reading a share's salt or metadata.
+	Lookup time.Duration
+	// Init bounds an upload init, including its quota and abuse checks.
+	Init time.Duration
+	// Finalize bounds finalizing an upload, including the synchronous
+	// verification of its chunks.
+	Finalize time.Duration
+}
+
+// Finalize verification modes. Stat compares the size of every chunk object
+// with its row; hash also reads each object back and compares its hash.
+const (
+	FinalizeVerifyOff  = "off"
+	FinalizeVerifyStat = "stat"
+	FinalizeVerifyHash = "hash"
+)
+
+// Defaults for checking the chunks of a file being finalized.
+const (
+	DefaultVerifyWorkers     = 4
+	MaxVerifyWorkers         = 64
+	DefaultAsyncVerifyChunks = 100
+)
+
+// Verification tunes how finalize checks stored chunks.
+type Verification struct {
+	// Workers is how many chunks of one file are checked at once.
+	Workers int
+	// AsyncChunks is the chunk count from which FinalizeVerifyHash runs in
+	// the background, the file staying verifying until it is done. Zero
+	// always verifies before finalize answers.
+	AsyncChunks int32
+}
+
+func DefaultVerification() Verification {
+	return Verification{Workers: DefaultVerifyWorkers, AsyncChunks: DefaultAsyncVerifyChunks}
+}
+
+// Storage quota policies.
+const (
+	QuotaPolicyReject = "reject"
+	QuotaPolicyEvict  = "evict"
+)
+
+// StorageQuota caps the total size of stored shares. The quota is soft:
+// uploads running at the same time may overshoot it. Zero Bytes disables it.
+type StorageQuota struct {
+	Bytes int64
+	// Policy decides what happens when an upload would exceed the quota. The
+	// upload is refused either way; QuotaPolicyEvict also removes fully
+	// downloaded and expired shares in the background to make room for the
+	// next ones.
+	Policy string
+}
+
+// Reconciliation cross-checks the chunk objects in storage against the
+// chunk rows of files that have not been purged. Zero Interval disables it.
+type Reconciliation struct {
+	Interval time.Duration
+	// RemoveObjects deletes chunk objects without a row.
+	RemoveObjects bool
+	// RemoveRows deletes chunk rows whose object is gone, marking their file
+	// corrupt unless it is still uploading.
+	RemoveRows bool
+}
+
+// UploadQuota caps what a single uploader IP may upload per UTC day and how
+// many of its shares may be active at once. Zero disables the respective
+// limit.
+type UploadQuota struct {
+	DailyCount int64
+	DailyBytes int64
+	// ActiveShares bounds the shares of one IP that have not expired or
+	// reached their download limit.
+	ActiveShares int64
+}
+
+// Actions taken against IPs flagged for upload squatting.
+const (
+	// SquatActionLimit lets a flagged IP have one unfinished upload at a
+	// time.
+	SquatActionLimit = "limit"
+	// SquatActionChallenge challenges every upload init of a flagged IP.
+	SquatActionChallenge = "challenge"
+)
+
+// UploadSquatting flags IPs that start uploads they never finish, holding
+// on to storage and share IDs. An IP with Threshold or more unfinished
+// uploads older than Grace, started within Window, is flagged for Penalty.
+// Zero Threshold disables the detection.
+type UploadSquatting struct {
+	Threshold int64
+	Window    time.Duration
+	Grace     time.Duration
+	Penalty   time.Duration
+	Action    string
+}
+
+// UploadSlots lets trusted backends reserve single uploads for browsers.
+// Slots are disabled when APIKeys is empty.
+type UploadSlots struct {
+	APIKeys []string
+	// TTL is how long a slot token can be exchanged for an upload.
+	TTL time.Duration
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		DefaultMaxDownloads: 5,
+		DefaultExpiry:       72 * time.Hour,
+		MaxFileSize:         5 << 30, // 5GB
+		MaxChunkSize:        64 << 20,
+		MaxChunkCount:       10000,
+		MaxChunkRequestSize: 64<<20 + ChunkRequestOverhead,
+	}
+}
+
+// Load reads configuration from the environment, falling back to the
+// PROFILE preset and then to defaults for unset variables. Set but malformed
+// values are reported as errors so a typo does not silently fall back to a
+// default.
+func Load() (Config, error) {
+	profile, err := LookupProfile(os.Getenv("PROFILE"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	limits := DefaultLimits()
+
+	maxDownloads, err := envInt("DEFAULT_MAX_DOWNLOADS", int64(limits.DefaultMaxDownloads))
+	if err != nil {
+		return Config{}, err
+	}
+	expiresInHours, err := envInt("DEFAULT_EXPIRES_IN_HOURS", int64(limits.DefaultExpiry/time.Hour))
+	if err != nil {
+		return Config{}, err
+	}
+	maxFileSize, err := envInt("MAX_FILE_SIZE", limits.MaxFileSize)
+	if err != nil {
+		return Config{}, err
+	}
+	maxChunkSize, err := envInt("MAX_CHUNK_SIZE", limits.MaxChunkSize)
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxChunkCount, err := envInt("MAX_CHUNK_COUNT", int64(limits.MaxChunkCount))
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxChunkRequestSize, err := envInt("MAX_CHUNK_REQUEST_SIZE", maxChunkSize+ChunkRequestOverhead)
+	if err != nil {
+		return Config{}, err
+	}
+
+	limits = Limits{
+		DefaultMaxDownloads: int32(maxDownloads),
+		DefaultExpiry:       time.Duration(expiresInHours) * time.Hour,
+		MaxFileSize:         maxFileSize,
+		MaxChunkSize:        maxChunkSize,
+		MaxChunkCount:       int32(min(max(maxChunkCount, 0), math.MaxInt32)),
+		MaxChunkRequestSize: maxChunkRequestSize,
+	}
+	if err := limits.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	maxConns, err := envInt("DB_MAX_CONNS", int64(profile.Database.MaxConns))
+	if err != nil {
+		return Config{}, err
+	}
+	minConns, err := envInt("DB_MIN_CONNS", int64(profile.Database.MinConns))
+	if err != nil {
+		return Config{}, err
+	}
+	if maxConns <= 0 || minConns < 0 || minConns > maxConns {
+		return Config{}, fmt.Errorf("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS")
+	}
+	retryAttempts, err := envInt("DB_RETRY_ATTEMPTS", DefaultRetryAttempts)
+	if err != nil {
+		return Config{}, err
+	}
+	if retryAttempts <= 0 {
+		return Config{}, fmt.Errorf("DB_RETRY_ATTEMPTS must be positive")
+	}
+	connLifetimeMinutes, err := envInt("DB_MAX_CONN_LIFETIME_MINUTES", int64(DefaultDBMaxConnLifetime/time.Minute))
+	if err != nil {
+		return Config{}, err
+	}
+	healthCheckSeconds, err := envInt("DB_HEALTH_CHECK_PERIOD_SECONDS", int64(DefaultDBHealthCheckPeriod/time.Second))
+	if err != nil {
+		return Config{}, err
+	}
+	if connLifetimeMinutes <= 0 || healthCheckSeconds <= 0 {
+		return Config{}, fmt.Errorf("DB_MAX_CONN_LIFETIME_MINUTES and DB_HEALTH_CHECK_PERIOD_SECONDS must be positive")
+	}
+	statementTimeoutSeconds, err := envInt("DB_STATEMENT_TIMEOUT_SECONDS", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if statementTimeoutSeconds < 0 {
+		return Config{}, fmt.Errorf("DB_STATEMENT_TIMEOUT_SECONDS must not be negative")
+	}
+	connectAttempts, err := envInt("DB_CONNECT_ATTEMPTS", DefaultConnectAttempts)
+	if err != nil {
+		return Config{}, err
+	}
+	if connectAttempts <= 0 {
+		return Config{}, fmt.Errorf("DB_CONNECT_ATTEMPTS must be positive")
+	}
+
+	uploadConcurrency, err := envInt("UPLOAD_CONCURRENCY", int64(profile.Transfer.UploadConcurrency))
+	if err != nil {
+		return Config{}, err
+	}
+	downloadPrefetch, err := envInt("DOWNLOAD_PREFETCH", int64(profile.Transfer.DownloadPrefetch))
+	if err != nil {
+		return Config{}, err
+	}
+	if uploadConcurrency <= 0 || downloadPrefetch <= 0 {
+		return Config{}, fmt.Errorf("UPLOAD_CONCURRENCY and DOWNLOAD_PREFETCH must be positive")
+	}
+
+	cleanupMinutes, err := envInt("CLEANUP_INTERVAL_MINUTES", int64(profile.CleanupInterval/time.Minute))
+	if err != nil {
+		return Config{}, err
+	}
+	if cleanupMinutes <= 0 {
+		return Config{}, fmt.Errorf("CLEANUP_INTERVAL_MINUTES must be positive")
+	}
+
+	retentionDays, err := envInt("EXPIRED_RETENTION_DAYS", int64(DefaultExpiredRetention/(24*time.Hour)))
+	if err != nil {
+		return Config{}, err
+	}
+	if retentionDays < 0 {
+		return Config{}, fmt.Errorf("EXPIRED_RETENTION_DAYS must not be negative")
+	}
+
+	reconciliation, err := loadReconciliation()
+	if err != nil {
+		return Config{}, err
+	}
+
+	downloadBandwidth, err := envInt("DOWNLOAD_BANDWIDTH_LIMIT", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if downloadBandwidth < 0 {
+		return Config{}, fmt.Errorf("DOWNLOAD_BANDWIDTH_LIMIT must not be negative")
+	}
+	downloadRate, err := envInt("DOWNLOAD_RATE_LIMIT", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if downloadRate < 0 {
+		return Config{}, fmt.Errorf("DOWNLOAD_RATE_LIMIT must not be negative")
+	}
+	downloadStreams, err := envInt("DOWNLOAD_STREAMS_PER_SHARE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if downloadStreams < 0 {
+		return Config{}, fmt.Errorf("DOWNLOAD_STREAMS_PER_SHARE must not be negative")
+	}
+
+	presignedTTLMinutes, err := envInt("PRESIGNED_URL_TTL_MINUTES", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if presignedTTLMinutes < 0 || presignedTTLMinutes > maxPresignedTTLMinutes {
+		return Config{}, fmt.Errorf("PRESIGNED_URL_TTL_MINUTES must be between 0 and %d", maxPresignedTTLMinutes)
+	}
+
+	compressionLevel, err := envInt("RESPONSE_COMPRESSION_LEVEL", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	if compressionLevel < 0 || compressionLevel > 9 {
+		return Config{}, fmt.Errorf("RESPONSE_COMPRESSION_LEVEL must be between 0 and 9")
+	}
+
+	uploadSlots, err := loadUploadSlots()
+	if err != nil {
+		return Config{}, err
+	}
+
+	storageQuota, err := loadStorageQuota()
+	if err != nil {
+		return Config{}, err
+	}
+
+	uploadQuota, err := loadUploadQuota()
+	if err != nil {
+		return Config{}, err
+	}
+
+	uploadSquatting, err := loadUploadSquatting()
+	if err != nil {
+		return Config{}, err
+	}
+
+	multipart, err := loadMultipart()
+	if err != nil {
+		return Config{}, err
+	}
+
+	storageUpload, err := loadStorageUpload()
+	if err != nil {
+		return Config{}, err
+	}
+
+	timeouts, err := loadOperationTimeouts()
+	if err != nil {
+		return Config{}, err
+	}
+
+	server, err := loadServer()
+	if err != nil {
+		return Config{}, err
+	}
+
+	finalizeVerify := os.Getenv("FINALIZE_VERIFY")
+	switch finalizeVerify {
+	case "":
+		finalizeVerify = FinalizeVerifyOff
+	case FinalizeVerifyOff, FinalizeVerifyStat, FinalizeVerifyHash:
+	default:
+		return Config{}, fmt.Errorf("FINALIZE_VERIFY must be %q, %q or %q", FinalizeVerifyOff, FinalizeVerifyStat, FinalizeVerifyHash)
+	}
+
+	verification, err := loadVerification()
+	if err != nil {
+		return Config{}, err
+	}
+
+	cors, err := loadCORS()
+	if err != nil {
+		return Config{}, err
+	}
+
+	exemptions, err := loadRateLimitExemptions()
+	if err != nil {
+		return Config{}, err
+	}
+
+	trustedProxies, err := envPrefixes("TRUSTED_PROXY_CIDRS")
+	if err != nil {
+		return Config{}, err
+	}
+
+	returnURLSchemes, err := loadReturnURLSchemes()
+	if err != nil {
+		return Config{}, err
+	}
+
+	readOnly, err := envBool("READ_ONLY", false)
+	if err != nil {
+		return Config{}, err
+	}
+
+	runMigrations, err := envBool("RUN_MIGRATIONS", false)
+	if err != nil {
+		return Config{}, err
+	}
+
+	storageAttempts, err := envInt("STORAGE_CONNECT_ATTEMPTS", DefaultConnectAttempts)
+	if err != nil {
+		return Config{}, err
+	}
+	if storageAttempts <= 0 {
+		return Config{}, fmt.Errorf("STORAGE_CONNECT_ATTEMPTS must be positive")
+	}
+
+	healthSeconds, err := envInt("HEALTH_PROBE_INTERVAL_SECONDS", int64(DefaultHealthProbeInterval/time.Second))
+	if err != nil {
+		return Config{}, err
+	}
+	if healthSeconds < 1 {
+		return Config{}, fmt.Errorf("HEALTH_PROBE_INTERVAL_SECONDS must be positive")
+	}
+
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+	if adminToken != "" && len(adminToken) < minAdminTokenLength {
+		return Config{}, fmt.Errorf("ADMIN_API_TOKEN must be at least %d characters", minAdminTokenLength)
+	}
+
+	previewTTLMinutes, err := envInt("SUPPORT_PREVIEW_TTL_MINUTES", int64(DefaultSupportPreviewTTL/time.Minute))
+	if err != nil {
+		return Config{}, err
+	}
+	if previewTTLMinutes <= 0 {
+		return Config{}, fmt.Errorf("SUPPORT_PREVIEW_TTL_MINUTES must be positive")
+	}
+
+	return Config{
+		Profile: profile.Name,
+		Server:  server,
+		Limits:  limits,
+		Database: Database{
+			MaxConns:          int32(maxConns),
+			MinConns:          int32(minConns),
+			RetryAttempts:     int(retryAttempts),
+			MaxConnLifetime:   time.Duration(connLifetimeMinutes) * time.Minute,
+			HealthCheckPeriod: time.Duration(healthCheckSeconds) * time.Second,
+			StatementTimeout:  time.Duration(statementTimeoutSeconds) * time.Second,
+			ConnectAttempts:   int(connectAttempts),
+		},
+		Transfer: Transfer{
+			UploadConcurrency: int(uploadConcurrency),
+			DownloadPrefetch:  int(downloadPrefetch),
+		},
+		CleanupInterval:         time.Duration(cleanupMinutes) * time.Minute,
+		ExpiredRetention:        time.Duration(retentionDays) * 24 * time.Hour,
+		Reconciliation:          reconciliation,
+		DownloadBandwidth:       downloadBandwidth,
+		DownloadRate:            downloadRate,
+		DownloadStreamsPerShare: int(downloadStreams),
+		PresignedURLTTL:         time.Duration(presignedTTLMinutes) * time.Minute,
+		UploadSlots:             uploadSlots,
+		CompressionLevel:        int(compressionLevel),
+		StorageQuota:            storageQuota,
+		UploadQuota:             uploadQuota,
+		UploadSquatting:         uploadSquatting,
+		AdminAPIToken:           adminToken,
+		SupportPreviewTTL:       time.Duration(previewTTLMinutes) * time.Minute,
+		Multipart:               multipart,
+		StorageUpload:           storageUpload,
+		Timeouts:                timeouts,
+		FinalizeVerify:          finalizeVerify,
+		Verification:            verification,
+		CORS:                    cors,
+
+		RateLimitExemptions: exemptions,
+		TrustedProxies:      trustedProxies,
+		ReturnURLSchemes:    returnURLSchemes,
+		ReadOnly:            readOnly,
+		HealthProbeInterval: time.Duration(healthSeconds) * time.Second,
+
+		StorageConnectAttempts: int(storageAttempts),
+		RunMigrations:          runMigrations,
+	}, nil
+}
+
+func loadReconciliation() (Reconciliation, error) {
+	hours, err := envInt("RECONCILE_INTERVAL_HOURS", int64(DefaultReconcileInterval/time.Hour))
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	if hours < 0 {
+		return Reconciliation{}, fmt.Errorf("RECONCILE_INTERVAL_HOURS must not be negative")
+	}
+
+	removeObjects, err := envBool("RECONCILE_REMOVE_OBJECTS", true)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	removeRows, err := envBool("RECONCILE_REMOVE_ROWS", false)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+
+	return Reconciliation{
+		Interval:      time.Duration(hours) * time.Hour,
+		RemoveObjects: removeObjects,
+		RemoveRows:    removeRows,
+	}, nil
+}
+
+func loadVerification() (Verification, error) {
+	workers, err := envInt("FINALIZE_VERIFY_WORKERS", DefaultVerifyWorkers)
+	if err != nil {
+		return Verification{}, err
+	}
+	if workers < 1 || workers > MaxVerifyWorkers {
+		return Verification{}, fmt.Errorf("FINALIZE_VERIFY_WORKERS must be between 1 and %d", MaxVerifyWorkers)
+	}
+
+	asyncChunks, err := envInt("FINALIZE_ASYNC_CHUNKS", DefaultAsyncVerifyChunks)
+	if err != nil {
+		return Verification{}, err
+	}
+	if asyncChunks < 0 || asyncChunks > math.MaxInt32 {
+		return Verification{}, fmt.Errorf("FINALIZE_ASYNC_CHUNKS must be between 0 and %d", math.MaxInt32)
+	}
+
+	return Verification{Workers: int(workers), AsyncChunks: int32(asyncChunks)}, nil
+}
+
+func loadStorageQuota() (StorageQuota, error) {
+	quotaBytes, err := envInt("STORAGE_QUOTA_BYTES", 0)
+	if err != nil {
+		return StorageQuota{}, err
+	}
+	if quotaBytes < 0 {
+		return StorageQuota{}, fmt.Errorf("STORAGE_QUOTA_BYTES must not be negative")
+	}
+
+	policy := os.Getenv("STORAGE_QUOTA_POLICY")
+	switch policy {
+	case "":
+		policy = QuotaPolicyReject
+	case QuotaPolicyReject, QuotaPolicyEvict:
+	default:
+		return StorageQuota{}, fmt.Errorf("STORAGE_QUOTA_POLICY must be %q or %q", QuotaPolicyReject, QuotaPolicyEvict)
+	}
+
+	return StorageQuota{Bytes: quotaBytes, Policy: policy}, nil
+}
+
+func loadUploadQuota() (UploadQuota, error) {
+	dailyCount, err := envInt("UPLOAD_QUOTA_DAILY_COUNT", 0)
+	if err != nil {
+		return UploadQuota{}, err
+	}
+	dailyBytes, err := envInt("UPLOAD_QUOTA_DAILY_BYTES", 0)
+	if err != nil {
+		return UploadQuota{}, err
+	}
+	if dailyCount < 0 || dailyBytes < 0 {
+		return UploadQuota{}, fmt.Errorf("UPLOAD_QUOTA_DAILY_COUNT and UPLOAD_QUOTA_DAILY_BYTES must not be negative")
+	}
+	activeShares, err := envInt("UPLOAD_QUOTA_ACTIVE_SHARES", 0)
+	if err != nil {
+		return UploadQuota{}, err
+	}
+	if activeShares < 0 {
+		return UploadQuota{}, fmt.Errorf("UPLOAD_QUOTA_ACTIVE_SHARES must not be negative")
+	}
+
+	return UploadQuota{DailyCount: dailyCount, DailyBytes: dailyBytes, ActiveShares: activeShares}, nil
+}
+
+func loadUploadSquatting() (UploadSquatting, error) {
+	threshold, err := envInt("UPLOAD_SQUAT_THRESHOLD", 0)
+	if err != nil {
+		return UploadSquatting{}, err
+	}
+	windowHours, err := envInt("UPLOAD_SQUAT_WINDOW_HOURS", 24)
+	if err != nil {
+		return UploadSquatting{}, err
+	}
+	graceMinutes, err := envInt("UPLOAD_SQUAT_GRACE_MINUTES", 60)
+	if err != nil {
+		return UploadSquatting{}, err
+	}
+	penaltyHours, err := envInt("UPLOAD_SQUAT_PENALTY_HOURS", windowHours)
+	if err != nil {
+		return UploadSquatting{}, err
+	}
+	if threshold < 0 {
+		return UploadSquatting{}, fmt.Errorf("UPLOAD_SQUAT_THRESHOLD must not be negative")
+	}
+	if windowHours <= 0 || penaltyHours <= 0 || graceMinutes < 0 {
+		return UploadSquatting{}, fmt.Errorf("UPLOAD_SQUAT_WINDOW_HOURS and UPLOAD_SQUAT_PENALTY_HOURS must be positive, UPLOAD_SQUAT_GRACE_MINUTES not negative")
+	}
+	window := time.Duration(windowHours) * time.Hour
+	grace := time.Duration(graceMinutes) * time.Minute
+	if grace >= window {
+		return UploadSquatting{}, fmt.Errorf("UPLOAD_SQUAT_GRACE_MINUTES must be shorter than UPLOAD_SQUAT_WINDOW_HOURS")
+	}
+
+	action := os.Getenv("UPLOAD_SQUAT_ACTION")
+	switch action {
+	case "":
+		action = SquatActionLimit
+	case SquatActionLimit, SquatActionChallenge:
+	default:
+		return UploadSquatting{}, fmt.Errorf("invalid UPLOAD_SQUAT_ACTION %q, must be %s or %s", action, SquatActionLimit, SquatActionChallenge)
+	}
+
+	return UploadSquatting{
+		Threshold: threshold,
+		Window:    window,
+		Grace:     grace,
+		Penalty:   time.Duration(penaltyHours) * time.Hour,
+		Action:    action,
+	}, nil
+}
+
+func loadStorageUpload() (StorageUpload, error) {
+	partSize, err := envInt("STORAGE_PART_SIZE_BYTES", 0)
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if partSize != 0 && (partSize < MinStoragePartSize || partSize > MaxStoragePartSize) {
+		return StorageUpload{}, fmt.Errorf("STORAGE_PART_SIZE_BYTES must be 0 or between %d and %d", MinStoragePartSize, MaxStoragePartSize)
+	}
+
+	threads, err := envInt("STORAGE_PART_THREADS", 0)
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if threads < 0 {
+		return StorageUpload{}, fmt.Errorf("STORAGE_PART_THREADS must not be negative")
+	}
+
+	concurrentStream, err := envBool("STORAGE_CONCURRENT_STREAM_PARTS", false)
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if concurrentStream && threads < 2 {
+		return StorageUpload{}, fmt.Errorf("STORAGE_CONCURRENT_STREAM_PARTS needs STORAGE_PART_THREADS of at least 2")
+	}
+
+	singlePutMax, err := envInt("STORAGE_SINGLE_PUT_MAX_BYTES", 0)
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if singlePutMax < 0 || singlePutMax > MaxStoragePartSize {
+		return StorageUpload{}, fmt.Errorf("STORAGE_SINGLE_PUT_MAX_BYTES must be between 0 and %d", MaxStoragePartSize)
+	}
+
+	putTimeout, err := envInt("STORAGE_PUT_TIMEOUT_SECONDS", int64(DefaultStoragePutTimeout/time.Second))
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if putTimeout < 0 {
+		return StorageUpload{}, fmt.Errorf("STORAGE_PUT_TIMEOUT_SECONDS must not be negative")
+	}
+
+	staleHours, err := envInt("STORAGE_STALE_UPLOAD_HOURS", int64(DefaultStorageStaleUploadAge/time.Hour))
+	if err != nil {
+		return StorageUpload{}, err
+	}
+	if staleHours < 0 {
+		return StorageUpload{}, fmt.Errorf("STORAGE_STALE_UPLOAD_HOURS must not be negative")
+	}
+
+	return StorageUpload{
+		PartSize:              uint64(partSize),
+		Threads:               uint(threads),
+		ConcurrentStreamParts: concurrentStream,
+		SinglePutMaxBytes:     singlePutMax,
+		PutTimeout:            time.Duration(putTimeout) * time.Second,
+		StaleUploadAge:        time.Duration(staleHours) * time.Hour,
+	}, nil
+}
+
+func loadServer() (Server, error) {
+	readHeader, err := envInt("SERVER_READ_HEADER_TIMEOUT_SECONDS", int64(DefaultReadHeaderTimeout/time.Second))
+	if err != nil {
+		return Server{}, err
+	}
+	read, err := envInt("SERVER_READ_TIMEOUT_SECONDS", int64(DefaultReadTimeout/time.Second))
+	if err != nil {
+		return Server{}, err
+	}
+	write, err := envInt("SERVER_WRITE_TIMEOUT_SECONDS", int64(DefaultWriteTimeout/time.Second))
+	if err != nil {
+		return Server{}, err
+	}
+	idle, err := envInt("SERVER_IDLE_TIMEOUT_SECONDS", int64(DefaultIdleTimeout/time.Second))
+	if err != nil {
+		return Server{}, err
+	}
+	if readHeader < 0 || read < 0 || write < 0 || idle < 0 {
+		return Server{}, fmt.Errorf("SERVER_*_TIMEOUT_SECONDS must not be negative")
+	}
+
+	maxHeader, err := envInt("SERVER_MAX_HEADER_BYTES", DefaultMaxHeaderBytes)
+	if err != nil {
+		return Server{}, err
+	}
+	if maxHeader < 4<<10 || maxHeader > 1<<30 {
+		return Server{}, fmt.Errorf("SERVER_MAX_HEADER_BYTES must be between 4096 and %d", 1<<30)
+	}
+	maxJSONBody, err := envInt("MAX_JSON_BODY_BYTES", DefaultMaxJSONBodyBytes)
+	if err != nil {
+		return Server{}, err
+	}
+	if maxJSONBody < 0 {
+		return Server{}, fmt.Errorf("MAX_JSON_BODY_BYTES must not be negative")
+	}
+
+	return Server{
+		ReadHeaderTimeout: time.Duration(readHeader) * time.Second,
+		ReadTimeout:       time.Duration(read) * time.Second,
+		WriteTimeout:      time.Duration(write) * time.Second,
+		IdleTimeout:       time.Duration(idle) * time.Second,
+		MaxHeaderBytes:    int(maxHeader),
+		MaxJSONBodyBytes:  maxJSONBody,
+	}, nil
+}
+
+func loadOperationTimeouts() (OperationTimeouts, error) {
+	lookup, err := envInt("LOOKUP_TIMEOUT_SECONDS", int64(DefaultLookupTimeout/time.Second))
+	if err != nil {
+		return OperationTimeouts{}, err
+	}
+	initTimeout, err := envInt("INIT_TIMEOUT_SECONDS", int64(DefaultInitTimeout/time.Second))
+	if err != nil {
+		return OperationTimeouts{}, err
+	}
+	finalize, err := envInt("FINALIZE_TIMEOUT_SECONDS", int64(DefaultFinalizeTimeout/time.Second))
+	if err != nil {
+		return OperationTimeouts{}, err
+	}
+	if lookup < 0 || initTimeout < 0 || finalize < 0 {
+		return OperationTimeouts{}, fmt.Errorf("LOOKUP_TIMEOUT_SECONDS, INIT_TIMEOUT_SECONDS and FINALIZE_TIMEOUT_SECONDS must not be negative")
+	}
+
+	return OperationTimeouts{
+		Lookup:   time.Duration(lookup) * time.Second,
+		Init:     time.Duration(initTimeout) * time.Second,
+		Finalize: time.Duration(finalize) * time.Second,
+	}, nil
+}
+
+// DefaultCORS allows the local frontend dev servers.
+func DefaultCORS() CORS {
+	return CORS{AllowedOrigins: slices.Clone(devOrigins), MaxAge: DefaultCORSMaxAge}
+}
+
+func loadCORS() (CORS, error) {
+	cors := DefaultCORS()
+
+	if env, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok && strings.TrimSpace(env) != "" {
+		cors.AllowedOrigins = nil
+		for origin := range strings.SplitSeq(env, ",") {
+			origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+			switch {
+			case origin == "":
+			case origin == "*":
+				cors.AllowAll = true
+			case !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://"):
+				return CORS{}, fmt.Errorf("CORS_ALLOWED_ORIGINS entries must start with http:// or https://, got %q", origin)
+			default:
+				cors.AllowedOrigins = append(cors.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if pattern := os.Getenv("CORS_ALLOWED_ORIGIN_REGEX"); pattern != "" {
+		// Anchored so a pattern cannot match an attacker's look-alike origin
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return CORS{}, fmt.Errorf("invalid CORS_ALLOWED_ORIGIN_REGEX: %w", err)
+		}
+		cors.OriginPattern = re
+	}
+
+	maxAge, err := envInt("CORS_MAX_AGE_SECONDS", int64(DefaultCORSMaxAge/time.Second))
+	if err != nil {
+		return CORS{}, err
+	}
+	if maxAge < 0 {
+		return CORS{}, fmt.Errorf("CORS_MAX_AGE_SECONDS must not be negative")
+	}
+	cors.MaxAge = time.Duration(maxAge) * time.Second
+
+	return cors, nil
+}
+
+func loadRateLimitExemptions() (RateLimitExemptions, error) {
+	bypass, err := envPrefixes("RATE_LIMIT_BYPASS_CIDRS")
+	if err != nil {
+		return RateLimitExemptions{}, err
+	}
+	raised, err := envPrefixes("RATE_LIMIT_RAISED_CIDRS")
+	if err != nil {
+		return RateLimitExemptions{}, err
+	}
+
+	factor, err := envInt("RATE_LIMIT_RAISE_FACTOR", DefaultRateLimitRaiseFactor)
+	if err != nil {
+		return RateLimitExemptions{}, err
+	}
+	if factor < 1 {
+		return RateLimitExemptions{}, fmt.Errorf("RATE_LIMIT_RAISE_FACTOR must be at least 1")
+	}
+
+	return RateLimitExemptions{Bypass: bypass, Raised: raised, RaiseFactor: int(factor)}, nil
+}
+
+func loadReturnURLSchemes() ([]string, error) {
+	var schemes []string
+	for scheme := range strings.SplitSeq(os.Getenv("RETURN_URL_SCHEMES"), ",") {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		switch {
+		case scheme == "":
+		case !schemePattern.MatchString(scheme):
+			return nil, fmt.Errorf("RETURN_URL_SCHEMES entries must be URL schemes, got %q", scheme)
+		case slices.Contains(unsafeReturnURLSchemes, scheme):
+			return nil, fmt.Errorf("RETURN_URL_SCHEMES must not allow %q", scheme)
+		default:
+			schemes = append(schemes, scheme)
+		}
+	}
+	if len(schemes) == 0 {
+		return DefaultReturnURLSchemes, nil
+	}
+	return schemes, nil
+}
+
+// envPrefixes parses a comma separated list of CIDRs. A bare address is a
+// network of that address alone.
+func envPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for entry := range strings.SplitSeq(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s entries must be CIDRs or IP addresses, got %q", key, entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func loadMultipart() (Multipart, error) {
+	memory, err := envInt("MULTIPART_MEMORY_BYTES", DefaultMultipartMemory)
+	if err != nil {
+		return Multipart{}, err
+	}
+	if memory <= 0 {
+		return Multipart{}, fmt.Errorf("MULTIPART_MEMORY_BYTES must be positive")
+	}
+
+	dir := os.Getenv("MULTIPART_TEMP_DIR")
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return Multipart{}, fmt.Errorf("invalid MULTIPART_TEMP_DIR: %w", err)
+		}
+		if !info.IsDir() {
+			return Multipart{}, fmt.Errorf("MULTIPART_TEMP_DIR %q is not a directory", dir)
+		}
+	}
+
+	return Multipart{MemoryBytes: memory, TempDir: dir}, nil
+}
+
+func loadUploadSlots() (UploadSlots, error) {
+	var keys []string
+	for key := range strings.SplitSeq(os.Getenv("UPLOAD_SLOT_API_KEYS"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if len(key) < minUploadSlotKeyLength {
+			return UploadSlots{}, fmt.Errorf("UPLOAD_SLOT_API_KEYS entries must be at least %d characters", minUploadSlotKeyLength)
+		}
+		keys = append(keys, key)
+	}
+
+	ttlMinutes, err := envInt("UPLOAD_SLOT_TTL_MINUTES", defaultUploadSlotTTLMinutes)
+	if err != nil {
+		return UploadSlots{}, err
+	}
+	if ttlMinutes <= 0 {
+		return UploadSlots{}, fmt.Errorf("UPLOAD_SLOT_TTL_MINUTES must be positive")
+	}
+
+	return UploadSlots{
+		APIKeys: keys,
+		TTL:     time.Duration(ttlMinutes) * time.Minute,
+	}, nil
+}
+
+func (l Limits) Validate() error {
+	if l.DefaultMaxDownloads <= 0 && l.DefaultMaxDownloads != UnlimitedDownloads {
+		return fmt.Errorf("DEFAULT_MAX_DOWNLOADS must be positive or %d for unlimited", UnlimitedDownloads)
+	}
+	if l.DefaultExpiry <= 0 {
+		return fmt.Errorf("DEFAULT_EXPIRES_IN_HOURS must be positive")
+	}
+	if l.MaxFileSize <= 0 {
+		return fmt.Errorf("MAX_FILE_SIZE must be positive")
+	}
+	if l.MaxChunkSize <= 0 {
+		return fmt.Errorf("MAX_CHUNK_SIZE must be positive")
+	}
+	if l.MaxChunkCount <= 0 {
+		return fmt.Errorf("MAX_CHUNK_COUNT must be positive")
+	}
+	// Otherwise the largest files allowed could not be split into chunks
+	if int64(l.MaxChunkCount)*l.MaxChunkSize < l.MaxFileSize {
+		return fmt.Errorf("MAX_CHUNK_COUNT chunks of MAX_CHUNK_SIZE must fit MAX_FILE_SIZE")
+	}
+	if l.MaxChunkRequestSize <= l.MaxChunkSize {
+		return fmt.Errorf("MAX_CHUNK_REQUEST_SIZE must be larger than MAX_CHUNK_SIZE")
+	}
+	return nil
+}
+
+func envInt(key string, defaultValue int64) (int64, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func envBool(key string, defaultValue bool) (bool, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
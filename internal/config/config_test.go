@@ -0,0 +1,592 @@
+package config
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("DEFAULT_MAX_DOWNLOADS", "")
+	t.Setenv("DEFAULT_EXPIRES_IN_HOURS", "")
+	t.Setenv("MAX_FILE_SIZE", "")
+	t.Setenv("MAX_CHUNK_SIZE", "")
+	t.Setenv("MAX_CHUNK_COUNT", "")
+	t.Setenv("MAX_CHUNK_REQUEST_SIZE", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLimits(), cfg.Limits)
+}
+
+func TestLoad_FromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_MAX_DOWNLOADS", "10")
+	t.Setenv("DEFAULT_EXPIRES_IN_HOURS", "24")
+	t.Setenv("MAX_FILE_SIZE", "1073741824")
+	t.Setenv("MAX_CHUNK_SIZE", "1048576")
+	t.Setenv("MAX_CHUNK_COUNT", "2048")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(10), cfg.Limits.DefaultMaxDownloads)
+	assert.Equal(t, 24*time.Hour, cfg.Limits.DefaultExpiry)
+	assert.Equal(t, int64(1<<30), cfg.Limits.MaxFileSize)
+	assert.Equal(t, int64(1<<20), cfg.Limits.MaxChunkSize)
+	assert.Equal(t, int32(2048), cfg.Limits.MaxChunkCount)
+	assert.Equal(t, int64(1<<20+ChunkRequestOverhead), cfg.Limits.MaxChunkRequestSize)
+}
+
+func TestLoad_UnlimitedDefaultMaxDownloads(t *testing.T) {
+	t.Setenv("DEFAULT_MAX_DOWNLOADS", "-1")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(UnlimitedDownloads), cfg.Limits.DefaultMaxDownloads)
+}
+
+func TestLoad_MaxChunkRequestSize(t *testing.T) {
+	t.Setenv("MAX_CHUNK_SIZE", "1048576")
+	t.Setenv("MAX_CHUNK_REQUEST_SIZE", "2097152")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2<<20), cfg.Limits.MaxChunkRequestSize)
+}
+
+func TestLoad_DownloadBandwidth(t *testing.T) {
+	t.Setenv("DOWNLOAD_BANDWIDTH_LIMIT", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DownloadBandwidth)
+
+	t.Setenv("DOWNLOAD_BANDWIDTH_LIMIT", "10485760")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(10<<20), cfg.DownloadBandwidth)
+}
+
+func TestLoad_DownloadRate(t *testing.T) {
+	t.Setenv("DOWNLOAD_RATE_LIMIT", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DownloadRate)
+
+	t.Setenv("DOWNLOAD_RATE_LIMIT", "1048576")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<20), cfg.DownloadRate)
+}
+
+func TestLoad_DownloadStreamsPerShare(t *testing.T) {
+	t.Setenv("DOWNLOAD_STREAMS_PER_SHARE", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DownloadStreamsPerShare)
+
+	t.Setenv("DOWNLOAD_STREAMS_PER_SHARE", "8")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.DownloadStreamsPerShare)
+}
+
+func TestLoad_PresignedURLTTL(t *testing.T) {
+	t.Setenv("PRESIGNED_URL_TTL_MINUTES", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Zero(t, cfg.PresignedURLTTL)
+
+	t.Setenv("PRESIGNED_URL_TTL_MINUTES", "15")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, cfg.PresignedURLTTL)
+}
+
+func TestLoad_Reconciliation(t *testing.T) {
+	t.Setenv("RECONCILE_INTERVAL_HOURS", "")
+	t.Setenv("RECONCILE_REMOVE_OBJECTS", "")
+	t.Setenv("RECONCILE_REMOVE_ROWS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, Reconciliation{Interval: DefaultReconcileInterval, RemoveObjects: true}, cfg.Reconciliation)
+
+	t.Setenv("RECONCILE_INTERVAL_HOURS", "0")
+	t.Setenv("RECONCILE_REMOVE_OBJECTS", "false")
+	t.Setenv("RECONCILE_REMOVE_ROWS", "true")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, Reconciliation{RemoveRows: true}, cfg.Reconciliation)
+}
+
+func TestLoad_ExpiredRetention(t *testing.T) {
+	t.Setenv("EXPIRED_RETENTION_DAYS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultExpiredRetention, cfg.ExpiredRetention)
+
+	t.Setenv("EXPIRED_RETENTION_DAYS", "0")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Zero(t, cfg.ExpiredRetention)
+}
+
+func TestLoad_DatabasePool(t *testing.T) {
+	for _, key := range []string{"DB_MAX_CONN_LIFETIME_MINUTES", "DB_HEALTH_CHECK_PERIOD_SECONDS", "DB_STATEMENT_TIMEOUT_SECONDS", "DB_CONNECT_ATTEMPTS"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDBMaxConnLifetime, cfg.Database.MaxConnLifetime)
+	assert.Equal(t, DefaultDBHealthCheckPeriod, cfg.Database.HealthCheckPeriod)
+	assert.Zero(t, cfg.Database.StatementTimeout)
+	assert.Equal(t, DefaultConnectAttempts, cfg.Database.ConnectAttempts)
+
+	t.Setenv("DB_MAX_CONN_LIFETIME_MINUTES", "30")
+	t.Setenv("DB_HEALTH_CHECK_PERIOD_SECONDS", "15")
+	t.Setenv("DB_STATEMENT_TIMEOUT_SECONDS", "20")
+	t.Setenv("DB_CONNECT_ATTEMPTS", "1")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, cfg.Database.MaxConnLifetime)
+	assert.Equal(t, 15*time.Second, cfg.Database.HealthCheckPeriod)
+	assert.Equal(t, 20*time.Second, cfg.Database.StatementTimeout)
+	assert.Equal(t, 1, cfg.Database.ConnectAttempts)
+}
+
+func TestLoad_RetryAttempts(t *testing.T) {
+	t.Setenv("DB_RETRY_ATTEMPTS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRetryAttempts, cfg.Database.RetryAttempts)
+
+	t.Setenv("DB_RETRY_ATTEMPTS", "1")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Database.RetryAttempts)
+}
+
+func TestLoad_UploadSlots(t *testing.T) {
+	t.Setenv("UPLOAD_SLOT_API_KEYS", "")
+	t.Setenv("UPLOAD_SLOT_TTL_MINUTES", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Empty(t, cfg.UploadSlots.APIKeys)
+	assert.Equal(t, 15*time.Minute, cfg.UploadSlots.TTL)
+
+	first := strings.Repeat("a", 32)
+	second := strings.Repeat("b", 40)
+	t.Setenv("UPLOAD_SLOT_API_KEYS", first+", "+second+",")
+	t.Setenv("UPLOAD_SLOT_TTL_MINUTES", "5")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{first, second}, cfg.UploadSlots.APIKeys)
+	assert.Equal(t, 5*time.Minute, cfg.UploadSlots.TTL)
+}
+
+func TestLoad_StorageQuota(t *testing.T) {
+	t.Setenv("STORAGE_QUOTA_BYTES", "")
+	t.Setenv("STORAGE_QUOTA_POLICY", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, StorageQuota{Bytes: 0, Policy: QuotaPolicyReject}, cfg.StorageQuota)
+
+	t.Setenv("STORAGE_QUOTA_BYTES", "1073741824")
+	t.Setenv("STORAGE_QUOTA_POLICY", "evict")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, StorageQuota{Bytes: 1 << 30, Policy: QuotaPolicyEvict}, cfg.StorageQuota)
+}
+
+func TestLoad_UploadQuota(t *testing.T) {
+	t.Setenv("UPLOAD_QUOTA_DAILY_COUNT", "")
+	t.Setenv("UPLOAD_QUOTA_DAILY_BYTES", "")
+	t.Setenv("UPLOAD_QUOTA_ACTIVE_SHARES", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, UploadQuota{}, cfg.UploadQuota)
+
+	t.Setenv("UPLOAD_QUOTA_DAILY_COUNT", "20")
+	t.Setenv("UPLOAD_QUOTA_DAILY_BYTES", "10737418240")
+	t.Setenv("UPLOAD_QUOTA_ACTIVE_SHARES", "50")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, UploadQuota{DailyCount: 20, DailyBytes: 10 << 30, ActiveShares: 50}, cfg.UploadQuota)
+}
+
+func TestLoad_UploadSquatting(t *testing.T) {
+	for _, key := range []string{"UPLOAD_SQUAT_THRESHOLD", "UPLOAD_SQUAT_WINDOW_HOURS", "UPLOAD_SQUAT_GRACE_MINUTES", "UPLOAD_SQUAT_PENALTY_HOURS", "UPLOAD_SQUAT_ACTION"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, UploadSquatting{
+		Window:  24 * time.Hour,
+		Grace:   time.Hour,
+		Penalty: 24 * time.Hour,
+		Action:  SquatActionLimit,
+	}, cfg.UploadSquatting)
+
+	t.Setenv("UPLOAD_SQUAT_THRESHOLD", "5")
+	t.Setenv("UPLOAD_SQUAT_WINDOW_HOURS", "6")
+	t.Setenv("UPLOAD_SQUAT_GRACE_MINUTES", "30")
+	t.Setenv("UPLOAD_SQUAT_ACTION", "challenge")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, UploadSquatting{
+		Threshold: 5,
+		Window:    6 * time.Hour,
+		Grace:     30 * time.Minute,
+		Penalty:   6 * time.Hour,
+		Action:    SquatActionChallenge,
+	}, cfg.UploadSquatting)
+}
+
+func TestLoad_Multipart(t *testing.T) {
+	t.Setenv("MULTIPART_MEMORY_BYTES", "")
+	t.Setenv("MULTIPART_TEMP_DIR", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMultipart(), cfg.Multipart)
+
+	dir := t.TempDir()
+	t.Setenv("MULTIPART_MEMORY_BYTES", "4194304")
+	t.Setenv("MULTIPART_TEMP_DIR", dir)
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, Multipart{MemoryBytes: 4 << 20, TempDir: dir}, cfg.Multipart)
+}
+
+func TestLoad_StorageUpload(t *testing.T) {
+	t.Setenv("STORAGE_PART_SIZE_BYTES", "")
+	t.Setenv("STORAGE_PART_THREADS", "")
+	t.Setenv("STORAGE_CONCURRENT_STREAM_PARTS", "")
+	t.Setenv("STORAGE_SINGLE_PUT_MAX_BYTES", "")
+	t.Setenv("STORAGE_PUT_TIMEOUT_SECONDS", "")
+	t.Setenv("STORAGE_STALE_UPLOAD_HOURS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, StorageUpload{
+		PutTimeout:     DefaultStoragePutTimeout,
+		StaleUploadAge: DefaultStorageStaleUploadAge,
+	}, cfg.StorageUpload)
+
+	t.Setenv("STORAGE_PART_SIZE_BYTES", "8388608")
+	t.Setenv("STORAGE_PART_THREADS", "4")
+	t.Setenv("STORAGE_CONCURRENT_STREAM_PARTS", "true")
+	t.Setenv("STORAGE_SINGLE_PUT_MAX_BYTES", "33554432")
+	t.Setenv("STORAGE_PUT_TIMEOUT_SECONDS", "0")
+	t.Setenv("STORAGE_STALE_UPLOAD_HOURS", "6")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, StorageUpload{
+		PartSize:              8 << 20,
+		Threads:               4,
+		ConcurrentStreamParts: true,
+		SinglePutMaxBytes:     32 << 20,
+		StaleUploadAge:        6 * time.Hour,
+	}, cfg.StorageUpload)
+}
+
+func TestLoad_Server(t *testing.T) {
+	for _, key := range []string{"SERVER_READ_HEADER_TIMEOUT_SECONDS", "SERVER_READ_TIMEOUT_SECONDS", "SERVER_WRITE_TIMEOUT_SECONDS", "SERVER_IDLE_TIMEOUT_SECONDS", "SERVER_MAX_HEADER_BYTES", "MAX_JSON_BODY_BYTES"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, Server{
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		ReadTimeout:       DefaultReadTimeout,
+		WriteTimeout:      DefaultWriteTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		MaxJSONBodyBytes:  DefaultMaxJSONBodyBytes,
+	}, cfg.Server)
+
+	t.Setenv("SERVER_READ_HEADER_TIMEOUT_SECONDS", "5")
+	t.Setenv("SERVER_WRITE_TIMEOUT_SECONDS", "0")
+	t.Setenv("SERVER_MAX_HEADER_BYTES", "8192")
+	t.Setenv("MAX_JSON_BODY_BYTES", "0")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadHeaderTimeout)
+	assert.Zero(t, cfg.Server.WriteTimeout)
+	assert.Equal(t, 8192, cfg.Server.MaxHeaderBytes)
+	assert.Zero(t, cfg.Server.MaxJSONBodyBytes)
+}
+
+func TestLoad_OperationTimeouts(t *testing.T) {
+	t.Setenv("LOOKUP_TIMEOUT_SECONDS", "")
+	t.Setenv("INIT_TIMEOUT_SECONDS", "")
+	t.Setenv("FINALIZE_TIMEOUT_SECONDS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, OperationTimeouts{
+		Lookup:   DefaultLookupTimeout,
+		Init:     DefaultInitTimeout,
+		Finalize: DefaultFinalizeTimeout,
+	}, cfg.Timeouts)
+
+	t.Setenv("LOOKUP_TIMEOUT_SECONDS", "5")
+	t.Setenv("INIT_TIMEOUT_SECONDS", "0")
+	t.Setenv("FINALIZE_TIMEOUT_SECONDS", "600")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, OperationTimeouts{Lookup: 5 * time.Second, Finalize: 10 * time.Minute}, cfg.Timeouts)
+}
+
+func TestLoad_FinalizeVerify(t *testing.T) {
+	t.Setenv("FINALIZE_VERIFY", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, FinalizeVerifyOff, cfg.FinalizeVerify)
+
+	t.Setenv("FINALIZE_VERIFY", "hash")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, FinalizeVerifyHash, cfg.FinalizeVerify)
+}
+
+func TestLoad_Verification(t *testing.T) {
+	t.Setenv("FINALIZE_VERIFY_WORKERS", "")
+	t.Setenv("FINALIZE_ASYNC_CHUNKS", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultVerification(), cfg.Verification)
+
+	t.Setenv("FINALIZE_VERIFY_WORKERS", "16")
+	t.Setenv("FINALIZE_ASYNC_CHUNKS", "0")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, Verification{Workers: 16}, cfg.Verification)
+}
+
+func TestLoad_CORS(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("CORS_ALLOWED_ORIGIN_REGEX", "")
+	t.Setenv("CORS_MAX_AGE_SECONDS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCORS(), cfg.CORS)
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://gzln.example.com/, *")
+	t.Setenv("CORS_ALLOWED_ORIGIN_REGEX", `https://.*\.example\.com`)
+	t.Setenv("CORS_MAX_AGE_SECONDS", "600")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://gzln.example.com"}, cfg.CORS.AllowedOrigins)
+	assert.True(t, cfg.CORS.AllowAll)
+	assert.Equal(t, 10*time.Minute, cfg.CORS.MaxAge)
+	assert.True(t, cfg.CORS.OriginPattern.MatchString("https://app.example.com"))
+	assert.False(t, cfg.CORS.OriginPattern.MatchString("https://app.example.com.evil.net"))
+}
+
+func TestLoad_RateLimitExemptions(t *testing.T) {
+	t.Setenv("RATE_LIMIT_BYPASS_CIDRS", "")
+	t.Setenv("RATE_LIMIT_RAISED_CIDRS", "")
+	t.Setenv("RATE_LIMIT_RAISE_FACTOR", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitExemptions{RaiseFactor: DefaultRateLimitRaiseFactor}, cfg.RateLimitExemptions)
+
+	t.Setenv("RATE_LIMIT_BYPASS_CIDRS", "10.0.0.7, fd00::/8")
+	t.Setenv("RATE_LIMIT_RAISED_CIDRS", "192.168.1.77/24")
+	t.Setenv("RATE_LIMIT_RAISE_FACTOR", "4")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitExemptions{
+		Bypass:      []netip.Prefix{netip.MustParsePrefix("10.0.0.7/32"), netip.MustParsePrefix("fd00::/8")},
+		Raised:      []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+		RaiseFactor: 4,
+	}, cfg.RateLimitExemptions)
+}
+
+func TestLoad_TrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXY_CIDRS", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TrustedProxies)
+
+	t.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8, 172.16.0.1")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("172.16.0.1/32")}, cfg.TrustedProxies)
+}
+
+func TestLoad_ReturnURLSchemes(t *testing.T) {
+	t.Setenv("RETURN_URL_SCHEMES", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultReturnURLSchemes, cfg.ReturnURLSchemes)
+
+	t.Setenv("RETURN_URL_SCHEMES", "HTTPS, ms-teams")
+
+	cfg, err = Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https", "ms-teams"}, cfg.ReturnURLSchemes)
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "non-numeric max downloads", key: "DEFAULT_MAX_DOWNLOADS", value: "many"},
+		{name: "negative max downloads", key: "DEFAULT_MAX_DOWNLOADS", value: "-2"},
+		{name: "zero expiry", key: "DEFAULT_EXPIRES_IN_HOURS", value: "0"},
+		{name: "negative max file size", key: "MAX_FILE_SIZE", value: "-1"},
+		{name: "non-numeric chunk size", key: "MAX_CHUNK_SIZE", value: "5MB"},
+		{name: "zero chunk count", key: "MAX_CHUNK_COUNT", value: "0"},
+		{name: "chunk count too low for max file size", key: "MAX_CHUNK_COUNT", value: "10"},
+		{name: "chunk request size below chunk size", key: "MAX_CHUNK_REQUEST_SIZE", value: "1024"},
+		{name: "zero retry attempts", key: "DB_RETRY_ATTEMPTS", value: "0"},
+		{name: "negative download bandwidth", key: "DOWNLOAD_BANDWIDTH_LIMIT", value: "-1"},
+		{name: "negative download rate", key: "DOWNLOAD_RATE_LIMIT", value: "-1"},
+		{name: "negative download streams", key: "DOWNLOAD_STREAMS_PER_SHARE", value: "-1"},
+		{name: "presigned TTL beyond seven days", key: "PRESIGNED_URL_TTL_MINUTES", value: "10081"},
+		{name: "short upload slot API key", key: "UPLOAD_SLOT_API_KEYS", value: "short-key"},
+		{name: "zero upload slot TTL", key: "UPLOAD_SLOT_TTL_MINUTES", value: "0"},
+		{name: "compression level above 9", key: "RESPONSE_COMPRESSION_LEVEL", value: "10"},
+		{name: "negative storage quota", key: "STORAGE_QUOTA_BYTES", value: "-1"},
+		{name: "unknown quota policy", key: "STORAGE_QUOTA_POLICY", value: "lru"},
+		{name: "negative daily upload count", key: "UPLOAD_QUOTA_DAILY_COUNT", value: "-1"},
+		{name: "non-numeric daily upload bytes", key: "UPLOAD_QUOTA_DAILY_BYTES", value: "10GB"},
+		{name: "negative active shares", key: "UPLOAD_QUOTA_ACTIVE_SHARES", value: "-1"},
+		{name: "negative finalize timeout", key: "FINALIZE_TIMEOUT_SECONDS", value: "-1"},
+		{name: "negative read timeout", key: "SERVER_READ_TIMEOUT_SECONDS", value: "-1"},
+		{name: "tiny header limit", key: "SERVER_MAX_HEADER_BYTES", value: "100"},
+		{name: "zero health probe interval", key: "HEALTH_PROBE_INTERVAL_SECONDS", value: "0"},
+		{name: "zero connection lifetime", key: "DB_MAX_CONN_LIFETIME_MINUTES", value: "0"},
+		{name: "negative statement timeout", key: "DB_STATEMENT_TIMEOUT_SECONDS", value: "-5"},
+		{name: "zero connect attempts", key: "DB_CONNECT_ATTEMPTS", value: "0"},
+		{name: "zero storage connect attempts", key: "STORAGE_CONNECT_ATTEMPTS", value: "0"},
+		{name: "negative squat threshold", key: "UPLOAD_SQUAT_THRESHOLD", value: "-1"},
+		{name: "squat grace beyond window", key: "UPLOAD_SQUAT_GRACE_MINUTES", value: "1440"},
+		{name: "unknown squat action", key: "UPLOAD_SQUAT_ACTION", value: "ban"},
+		{name: "short admin API token", key: "ADMIN_API_TOKEN", value: "admin"},
+		{name: "zero support preview TTL", key: "SUPPORT_PREVIEW_TTL_MINUTES", value: "0"},
+		{name: "origin without scheme", key: "CORS_ALLOWED_ORIGINS", value: "gzln.example.com"},
+		{name: "invalid origin regex", key: "CORS_ALLOWED_ORIGIN_REGEX", value: "("},
+		{name: "negative CORS max age", key: "CORS_MAX_AGE_SECONDS", value: "-1"},
+		{name: "invalid bypass CIDR", key: "RATE_LIMIT_BYPASS_CIDRS", value: "10.0.0.0/33"},
+		{name: "hostname as raised CIDR", key: "RATE_LIMIT_RAISED_CIDRS", value: "batch.internal"},
+		{name: "zero raise factor", key: "RATE_LIMIT_RAISE_FACTOR", value: "0"},
+		{name: "hostname as trusted proxy", key: "TRUSTED_PROXY_CIDRS", value: "lb.internal"},
+		{name: "return URL scheme with colon", key: "RETURN_URL_SCHEMES", value: "https://"},
+		{name: "javascript return URL scheme", key: "RETURN_URL_SCHEMES", value: "https,javascript"},
+		{name: "non-boolean read-only", key: "READ_ONLY", value: "maybe"},
+		{name: "non-boolean run migrations", key: "RUN_MIGRATIONS", value: "maybe"},
+		{name: "zero multipart memory", key: "MULTIPART_MEMORY_BYTES", value: "0"},
+		{name: "missing multipart temp dir", key: "MULTIPART_TEMP_DIR", value: "/nonexistent/gzln-spool"},
+		{name: "part size below S3 minimum", key: "STORAGE_PART_SIZE_BYTES", value: "1048576"},
+		{name: "negative part threads", key: "STORAGE_PART_THREADS", value: "-1"},
+		{name: "concurrent stream parts without threads", key: "STORAGE_CONCURRENT_STREAM_PARTS", value: "true"},
+		{name: "non-boolean concurrent stream parts", key: "STORAGE_CONCURRENT_STREAM_PARTS", value: "yes"},
+		{name: "single put above 5GB", key: "STORAGE_SINGLE_PUT_MAX_BYTES", value: "6442450944"},
+		{name: "negative reconcile interval", key: "RECONCILE_INTERVAL_HOURS", value: "-1"},
+		{name: "negative expired retention", key: "EXPIRED_RETENTION_DAYS", value: "-1"},
+		{name: "invalid reconcile remove rows", key: "RECONCILE_REMOVE_ROWS", value: "maybe"},
+		{name: "negative put timeout", key: "STORAGE_PUT_TIMEOUT_SECONDS", value: "-1"},
+		{name: "negative stale upload age", key: "STORAGE_STALE_UPLOAD_HOURS", value: "-1"},
+		{name: "unknown finalize verify mode", key: "FINALIZE_VERIFY", value: "deep"},
+		{name: "zero verify workers", key: "FINALIZE_VERIFY_WORKERS", value: "0"},
+		{name: "too many verify workers", key: "FINALIZE_VERIFY_WORKERS", value: "65"},
+		{name: "negative async verify chunks", key: "FINALIZE_ASYNC_CHUNKS", value: "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := Load()
+
+			assert.Error(t, err)
+		})
+	}
+}
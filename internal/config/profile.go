@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const DefaultProfile = "medium"
+
+// Database tunes the Postgres connection pool.
+type Database struct {
+	MaxConns int32
+	MinConns int32
+	// RetryAttempts bounds how often reads and transactions run after
+	// transient failures such as a failover. 1 disables retries.
+	RetryAttempts int
+	// MaxConnLifetime recycles connections, e.g. to pick up a failed over
+	// primary behind the same name.
+	MaxConnLifetime time.Duration
+	// HealthCheckPeriod is how often idle connections are checked.
+	HealthCheckPeriod time.Duration
+	// StatementTimeout makes Postgres cancel statements running longer.
+	// Zero leaves the server's setting.
+	StatementTimeout time.Duration
+	// ConnectAttempts bounds how often the database is tried at startup
+	// before the server gives up on it.
+	ConnectAttempts int
+}
+
+// RateLimits holds the per-IP request limits for each limiter, per
+// RATE_LIMIT_WINDOW_SECONDS.
+type RateLimits struct {
+	UploadAdvice     int
+	UploadInit       int
+	ChunkUpload      int
+	ChunkStatus      int
+	UploadFinalize   int
+	Metadata         int
+	Manifest         int
+	ChunkDownload    int
+	DownloadComplete int
+	ManageSession    int
+}
+
+// Transfer holds the parallelism hints advertised to clients.
+type Transfer struct {
+	UploadConcurrency int
+	DownloadPrefetch  int
+}
+
+// Profile is a preset of tuning knobs for a deployment size. Every value can
+// still be overridden by its own environment variable.
+type Profile struct {
+	Name            string
+	Database        Database
+	RateLimits      RateLimits
+	Transfer        Transfer
+	CleanupInterval time.Duration
+}
+
+var profiles = map[string]Profile{
+	// small suits a single-core VPS or Raspberry Pi serving a handful of users
+	"small": {
+		Name:     "small",
+		Database: Database{MaxConns: 4, MinConns: 1},
+		RateLimits: RateLimits{
+			UploadAdvice:     15,
+			UploadInit:       5,
+			ChunkUpload:      30,
+			ChunkStatus:      15,
+			UploadFinalize:   10,
+			Metadata:         15,
+			Manifest:         15,
+			ChunkDownload:    60,
+			DownloadComplete: 10,
+			ManageSession:    5,
+		},
+		Transfer:        Transfer{UploadConcurrency: 2, DownloadPrefetch: 1},
+		CleanupInterval: 15 * time.Minute,
+	},
+	"medium": {
+		Name:     "medium",
+		Database: Database{MaxConns: 10, MinConns: 2},
+		RateLimits: RateLimits{
+			UploadAdvice:     30,
+			UploadInit:       10,
+			ChunkUpload:      60,
+			ChunkStatus:      30,
+			UploadFinalize:   20,
+			Metadata:         30,
+			Manifest:         30,
+			ChunkDownload:    110,
+			DownloadComplete: 20,
+			ManageSession:    10,
+		},
+		Transfer:        Transfer{UploadConcurrency: 5, DownloadPrefetch: 2},
+		CleanupInterval: 5 * time.Minute,
+	},
+	// large suits a dedicated host behind a proxy serving many concurrent users
+	"large": {
+		Name:     "large",
+		Database: Database{MaxConns: 30, MinConns: 5},
+		RateLimits: RateLimits{
+			UploadAdvice:     60,
+			UploadInit:       20,
+			ChunkUpload:      120,
+			ChunkStatus:      60,
+			UploadFinalize:   40,
+			Metadata:         60,
+			Manifest:         60,
+			ChunkDownload:    240,
+			DownloadComplete: 40,
+			ManageSession:    20,
+		},
+		Transfer:        Transfer{UploadConcurrency: 8, DownloadPrefetch: 4},
+		CleanupInterval: time.Minute,
+	},
+}
+
+// DefaultTransfer returns the transfer hints of DefaultProfile.
+func DefaultTransfer() Transfer {
+	return profiles[DefaultProfile].Transfer
+}
+
+// DefaultCleanupInterval returns the cleanup interval of DefaultProfile.
+func DefaultCleanupInterval() time.Duration {
+	return profiles[DefaultProfile].CleanupInterval
+}
+
+// LookupProfile returns the named preset. An empty name selects
+// DefaultProfile.
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	p, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown PROFILE %q (expected small, medium or large)", name)
+	}
+	return p, nil
+}
+
+// ProfileFromEnv returns the preset selected by PROFILE, falling back to
+// DefaultProfile when it is unset or unknown. Load reports unknown names.
+func ProfileFromEnv() Profile {
+	p, err := LookupProfile(os.Getenv("PROFILE"))
+	if err != nil {
+		p, _ = LookupProfile(DefaultProfile)
+	}
+	return p
+}
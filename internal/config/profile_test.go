@@ -0,0 +1,96 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "empty selects default", input: "", expected: DefaultProfile},
+		{name: "small", input: "small", expected: "small"},
+		{name: "case insensitive", input: "LARGE", expected: "large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LookupProfile(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p.Name)
+		})
+	}
+}
+
+func TestLookupProfile_Unknown(t *testing.T) {
+	_, err := LookupProfile("huge")
+	assert.Error(t, err)
+}
+
+func TestProfileFromEnv_UnknownFallsBackToDefault(t *testing.T) {
+	t.Setenv("PROFILE", "huge")
+	assert.Equal(t, DefaultProfile, ProfileFromEnv().Name)
+}
+
+func TestProfiles_ScaleWithSize(t *testing.T) {
+	small, _ := LookupProfile("small")
+	medium, _ := LookupProfile("medium")
+	large, _ := LookupProfile("large")
+
+	assert.Less(t, small.Database.MaxConns, medium.Database.MaxConns)
+	assert.Less(t, medium.Database.MaxConns, large.Database.MaxConns)
+	assert.Less(t, small.RateLimits.ChunkUpload, medium.RateLimits.ChunkUpload)
+	assert.Less(t, medium.RateLimits.ChunkUpload, large.RateLimits.ChunkUpload)
+	assert.Less(t, small.Transfer.UploadConcurrency, large.Transfer.UploadConcurrency)
+}
+
+func TestLoad_Profile(t *testing.T) {
+	t.Setenv("PROFILE", "small")
+	t.Setenv("DB_MAX_CONNS", "")
+	t.Setenv("UPLOAD_CONCURRENCY", "")
+	t.Setenv("CLEANUP_INTERVAL_MINUTES", "")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, "small", cfg.Profile)
+	assert.Equal(t, int32(4), cfg.Database.MaxConns)
+	assert.Equal(t, 2, cfg.Transfer.UploadConcurrency)
+	assert.Equal(t, 15*time.Minute, cfg.CleanupInterval)
+}
+
+func TestLoad_ProfileOverride(t *testing.T) {
+	t.Setenv("PROFILE", "small")
+	t.Setenv("DB_MAX_CONNS", "12")
+	t.Setenv("UPLOAD_CONCURRENCY", "6")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(12), cfg.Database.MaxConns)
+	assert.Equal(t, int32(1), cfg.Database.MinConns)
+	assert.Equal(t, 6, cfg.Transfer.UploadConcurrency)
+}
+
+func TestLoad_UnknownProfile(t *testing.T) {
+	t.Setenv("PROFILE", "huge")
+
+	_, err := Load()
+
+	assert.Error(t, err)
+}
+
+func TestLoad_InvalidPoolSize(t *testing.T) {
+	t.Setenv("DB_MAX_CONNS", "2")
+	t.Setenv("DB_MIN_CONNS", "5")
+
+	_, err := Load()
+
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
@@ -24,3 +25,13 @@ func HashReader(r io.Reader) (string, error) {
 func CompareHash(expected, computed string) bool {
 	return expected == computed
 }
+
+func Sign(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(key []byte, data, signature string) bool {
+	return hmac.Equal([]byte(Sign(key, data)), []byte(signature))
+}
@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSealer(t *testing.T, fill byte) *Sealer {
+	t.Helper()
+	s, err := NewSealer(bytes.Repeat([]byte{fill}, SealerKeySize))
+	require.NoError(t, err)
+	return s
+}
+
+func TestSealer_RoundTrip(t *testing.T) {
+	s := testSealer(t, 1)
+	plaintext := []byte("plain chunk")
+
+	sealed, err := s.Seal(plaintext, []byte("file/0.enc"))
+	require.NoError(t, err)
+	assert.Len(t, sealed, len(plaintext)+SealerOverhead)
+	assert.NotContains(t, string(sealed), "plain chunk")
+
+	opened, err := s.Open(s.KeyID(), sealed, []byte("file/0.enc"))
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestSealer_RejectsOtherObjectOrKey(t *testing.T) {
+	s := testSealer(t, 1)
+	sealed, err := s.Seal([]byte("plain chunk"), []byte("file/0.enc"))
+	require.NoError(t, err)
+
+	_, err = s.Open(s.KeyID(), sealed, []byte("file/1.enc"))
+	assert.Error(t, err)
+
+	other := testSealer(t, 2)
+	_, err = other.Open(s.KeyID(), sealed, []byte("file/0.enc"))
+	assert.ErrorIs(t, err, ErrSealerKeyMismatch)
+}
+
+func TestSealerFromEnv(t *testing.T) {
+	t.Setenv("SERVER_ENCRYPTION_KEY", "")
+	s, err := SealerFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	t.Setenv("SERVER_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)))
+	_, err = SealerFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("SERVER_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, SealerKeySize)))
+	s, err = SealerFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, testSealer(t, 1).KeyID(), s.KeyID())
+}
@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// Signer signs documents the server publishes with an Ed25519 key, so
+// clients holding its public key can check they came from this server.
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSigner builds a Signer from a 32-byte Ed25519 seed.
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &Signer{key: key, keyID: "ed25519:" + hex.EncodeToString(sum[:8])}, nil
+}
+
+// SignerFromEnv builds a Signer from the base64 CONFIG_SIGNING_KEY seed. It
+// returns nil when no key is set.
+func SignerFromEnv() (*Signer, error) {
+	encoded := os.Getenv("CONFIG_SIGNING_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONFIG_SIGNING_KEY: %w", err)
+	}
+	s, err := NewSigner(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONFIG_SIGNING_KEY: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign returns the Ed25519 signature of data.
+func (s *Signer) Sign(data []byte) []byte {
+	return ed25519.Sign(s.key, data)
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	s, err := NewSigner(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	require.NoError(t, err)
+
+	signature := s.Sign([]byte("bundle"))
+	assert.True(t, ed25519.Verify(s.PublicKey(), []byte("bundle"), signature))
+	assert.False(t, ed25519.Verify(s.PublicKey(), []byte("bundle!"), signature))
+
+	other, err := NewSigner(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	require.NoError(t, err)
+	assert.NotEqual(t, s.KeyID(), other.KeyID())
+}
+
+func TestSignerFromEnv(t *testing.T) {
+	t.Setenv("CONFIG_SIGNING_KEY", "")
+	s, err := SignerFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	t.Setenv("CONFIG_SIGNING_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)))
+	_, err = SignerFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("CONFIG_SIGNING_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, ed25519.SeedSize)))
+	s, err = SignerFromEnv()
+	require.NoError(t, err)
+	assert.Contains(t, s.KeyID(), "ed25519:")
+}
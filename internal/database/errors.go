@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of failures the repository layer reports, so services do not need to
+// know pgx or Postgres error codes. The driver's error stays reachable with
+// errors.Is and errors.As.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrInvalidRef = errors.New("invalid reference")
+)
+
+// classified tags a driver error with its kind.
+type classified struct {
+	kind error
+	err  error
+}
+
+func (e *classified) Error() string {
+	return e.err.Error()
+}
+
+func (e *classified) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Classify tags err with ErrNotFound when a query returned no rows,
+// ErrConflict on unique violations and ErrInvalidRef on foreign key
+// violations. Other errors are returned unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var c *classified
+	if errors.As(err, &c) {
+		return err
+	}
+
+	var kind error
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		kind = ErrNotFound
+	case !errors.As(err, &pgErr):
+		return err
+	case pgErr.Code == "23505": // unique_violation
+		kind = ErrConflict
+	case pgErr.Code == "23503": // foreign_key_violation
+		kind = ErrInvalidRef
+	default:
+		return err
+	}
+	return &classified{kind: kind, err: err}
+}
+
+// Constraint returns the name of the constraint err violated, or "" if it
+// violated none.
+func Constraint(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// classify is Classify for calls that return a value.
+func classify[T any](v T, err error) (T, error) {
+	return v, Classify(err)
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"no rows", pgx.ErrNoRows, ErrNotFound},
+		{"wrapped no rows", fmt.Errorf("failed to get file: %w", pgx.ErrNoRows), ErrNotFound},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, ErrConflict},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, ErrInvalidRef},
+		{"other postgres error", &pgconn.PgError{Code: "40001"}, nil},
+		{"other error", errors.New("connection reset"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Classify(tt.err)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err.Error(), err.Error())
+			for _, kind := range []error{ErrNotFound, ErrConflict, ErrInvalidRef} {
+				assert.Equal(t, kind == tt.kind, errors.Is(err, kind), kind.Error())
+			}
+			if tt.kind == nil {
+				assert.Same(t, tt.err, err)
+			}
+		})
+	}
+
+	assert.NoError(t, Classify(nil))
+
+	once := Classify(pgx.ErrNoRows)
+	assert.Same(t, once, Classify(once))
+}
+
+func TestConstraint(t *testing.T) {
+	err := Classify(&pgconn.PgError{Code: "23505", ConstraintName: "idx_files_alias"})
+
+	assert.Equal(t, "idx_files_alias", Constraint(fmt.Errorf("failed to create file: %w", err)))
+	assert.Empty(t, Constraint(pgx.ErrNoRows))
+}
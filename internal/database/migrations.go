@@ -0,0 +1,257 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID is the advisory lock that keeps instances started together
+// from applying the same migrations at once.
+const migrationLockID = 0x677a6c6e // "gzln"
+
+const (
+	gooseUp            = "-- +goose Up"
+	gooseDown          = "-- +goose Down"
+	gooseNoTransaction = "-- +goose NO TRANSACTION"
+)
+
+// ErrMissingMigration reports a migration older than the newest applied one
+// that was never applied, as when branches with migrations merge out of
+// order. goose refuses to apply those by default too; renumber the migration
+// above the newest applied version.
+var ErrMissingMigration = errors.New("migration older than the newest applied one is not applied")
+
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+type migration struct {
+	version int64
+	name    string
+}
+
+// Migrate applies the goose migrations in fsys that the database has not
+// seen, oldest first, each in its own transaction unless it is marked
+// NO TRANSACTION, and returns their versions. Like goose, it applies none
+// and fails with ErrMissingMigration if one of them is older than an applied
+// one. Applied versions are kept in goose's goose_db_version table, so the
+// goose CLI and Migrate can be used on the same database.
+//
+// The Up section of a migration runs as one multi-statement query, which
+// Postgres refuses for statements such as CREATE INDEX CONCURRENTLY; those
+// need a migration of their own.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) ([]int64, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+		id SERIAL PRIMARY KEY,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL,
+		tstamp TIMESTAMP DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration table: %w", err)
+	}
+	_, err = conn.Exec(ctx, `INSERT INTO goose_db_version (version_id, is_applied)
+		SELECT 0, true WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize migration table: %w", err)
+	}
+
+	migrations, err := migrationFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := pendingMigrations(ctx, conn, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int64
+	for _, m := range pending {
+		if err := applyMigration(ctx, conn.Conn(), fsys, m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.version)
+		slog.Info("migration applied",
+			slog.String("migration", m.name),
+		)
+	}
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, conn *pgx.Conn, fsys fs.FS, m migration) error {
+	content, err := fs.ReadFile(fsys, m.name)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", m.name, err)
+	}
+	up, inTx, err := parseMigration(string(content))
+	if err != nil {
+		return fmt.Errorf("invalid migration %s: %w", m.name, err)
+	}
+
+	record := "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)"
+	if !inTx {
+		if _, err := conn.Exec(ctx, up); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		if _, err := conn.Exec(ctx, record, m.version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+		}
+		return nil
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, up); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		if _, err := tx.Exec(ctx, record, m.version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+		}
+		return nil
+	})
+}
+
+// parseMigration returns the Up section of a goose SQL migration and
+// whether it runs in a transaction.
+func parseMigration(content string) (string, bool, error) {
+	_, up, ok := strings.Cut(content, gooseUp)
+	if !ok {
+		return "", false, errors.New("no " + gooseUp + " annotation")
+	}
+	up, _, _ = strings.Cut(up, gooseDown)
+	return up, !strings.Contains(content, gooseNoTransaction), nil
+}
+
+// PendingMigrations returns the versions of the goose migrations in fsys
+// that have not been applied to the database, oldest first. A database goose
+// never ran against has every migration pending. It fails with
+// ErrMissingMigration if one of them is older than an applied one.
+func PendingMigrations(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) ([]int64, error) {
+	migrations, err := migrationFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := pendingMigrations(ctx, pool, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(pending))
+	for i, m := range pending {
+		versions[i] = m.version
+	}
+	return versions, nil
+}
+
+func pendingMigrations(ctx context.Context, q queryer, migrations []migration) ([]migration, error) {
+	applied := make(map[int64]bool)
+	rows, err := q.Query(ctx, "SELECT version_id FROM goose_db_version WHERE is_applied")
+	if err == nil {
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+			}
+			applied[v] = true
+		}
+		rows.Close()
+		err = rows.Err()
+	}
+	var pgErr *pgconn.PgError
+	if err != nil && !(errors.As(err, &pgErr) && pgErr.Code == "42P01") { // undefined_table
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return unapplied(migrations, applied)
+}
+
+// unapplied returns the migrations not in applied. They must all be newer
+// than the newest applied version: applying an older one would run it after
+// migrations written against a schema without it.
+func unapplied(migrations []migration, applied map[int64]bool) ([]migration, error) {
+	var current int64
+	for v := range applied {
+		current = max(current, v)
+	}
+
+	var pending, missing []migration
+	for _, m := range migrations {
+		switch {
+		case applied[m.version]:
+		case m.version < current:
+			missing = append(missing, m)
+		default:
+			pending = append(pending, m)
+		}
+	}
+	if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, m := range missing {
+			names[i] = m.name
+		}
+		return nil, fmt.Errorf("%w: %s (database is at %d)", ErrMissingMigration, strings.Join(names, ", "), current)
+	}
+	return pending, nil
+}
+
+// MigrationVersions returns the versions of the .sql migrations at the root
+// of fsys, named VERSION_description.sql as goose creates them, in order.
+func MigrationVersions(fsys fs.FS) ([]int64, error) {
+	migrations, err := migrationFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(migrations))
+	for i, m := range migrations {
+		versions[i] = m.version
+	}
+	return versions, nil
+}
+
+func migrationFiles(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		v, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %q has no version prefix", name)
+		}
+		migrations = append(migrations, migration{version: v, name: name})
+	}
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return cmp.Compare(a.version, b.version)
+	})
+	return migrations, nil
+}
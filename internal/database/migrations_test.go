@@ -0,0 +1,68 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20261015120000_widen_share_id.sql":  {},
+		"20251010204542_add_files_table.sql": {},
+		"README.md":                          {},
+		"archive/20240101000000_old.sql":     {},
+	}
+
+	versions, err := MigrationVersions(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{20251010204542, 20261015120000}, versions)
+
+	fsys["add_files.sql"] = &fstest.MapFile{}
+	_, err = MigrationVersions(fsys)
+	assert.Error(t, err)
+}
+
+func TestParseMigration(t *testing.T) {
+	up, inTx, err := parseMigration(`-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files ADD COLUMN alias VARCHAR(32);
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN alias;
+`)
+	require.NoError(t, err)
+	assert.True(t, inTx)
+	assert.Contains(t, up, "ADD COLUMN alias")
+	assert.NotContains(t, up, "DROP COLUMN")
+
+	_, inTx, err = parseMigration("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY idx ON files (alias);\n")
+	require.NoError(t, err)
+	assert.False(t, inTx)
+
+	_, _, err = parseMigration("CREATE TABLE t (id INT);")
+	assert.Error(t, err)
+}
+
+func TestUnapplied(t *testing.T) {
+	migrations := []migration{
+		{version: 20251010204542, name: "20251010204542_add_files_table.sql"},
+		{version: 20261015120000, name: "20261015120000_widen_share_id.sql"},
+		{version: 20261016110000, name: "20261016110000_add_blocked_networks.sql"},
+	}
+
+	pending, err := unapplied(migrations, map[int64]bool{0: true, 20251010204542: true})
+	require.NoError(t, err)
+	assert.Equal(t, migrations[1:], pending)
+
+	pending, err = unapplied(migrations, map[int64]bool{})
+	require.NoError(t, err)
+	assert.Equal(t, migrations, pending)
+
+	_, err = unapplied(migrations, map[int64]bool{0: true, 20251010204542: true, 20261016110000: true})
+	assert.ErrorIs(t, err, ErrMissingMigration)
+	assert.ErrorContains(t, err, "20261015120000_widen_share_id.sql")
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -14,17 +16,43 @@ type Database struct {
 	Queries *sqlc.Queries
 }
 
-func NewDatabase(ctx context.Context) (*Database, error) {
+// NewDatabase opens a pool to DB_URL, tuned by cfg, and pings it, so the
+// server does not start before the database does. Errors of the ping are
+// IsRetryable while the database is still coming up.
+func NewDatabase(ctx context.Context, cfg config.Database) (*Database, error) {
 	dbURL := os.Getenv("DB_URL")
 	if dbURL == "" {
 		return nil, fmt.Errorf("DB_URL environment variable is not set")
 	}
 
-	pool, err := pgxpool.New(ctx, dbURL)
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DB_URL: %w", err)
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	poolConfig.MinConns = cfg.MinConns
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to reach database: %w", err)
+	}
+
 	queries := sqlc.New(pool)
 
 	return &Database{
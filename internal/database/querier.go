@@ -0,0 +1,514 @@
+package database
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RetryingQuerier retries idempotent reads after transient failures, such as
+// a Postgres failover. Writes are passed through unchanged since they may
+// already have been applied when the error surfaced. Every error it returns
+// is passed through Classify.
+type RetryingQuerier struct {
+	q      sqlc.Querier
+	policy RetryPolicy
+}
+
+var _ sqlc.Querier = (*RetryingQuerier)(nil)
+
+func NewRetryingQuerier(q sqlc.Querier, policy RetryPolicy) *RetryingQuerier {
+	return &RetryingQuerier{q: q, policy: policy}
+}
+
+func (r *RetryingQuerier) AddBundleFile(ctx context.Context, arg sqlc.AddBundleFileParams) error {
+	return Classify(r.q.AddBundleFile(ctx, arg))
+}
+
+func (r *RetryingQuerier) AddTransferBytes(ctx context.Context, arg sqlc.AddTransferBytesParams) error {
+	return Classify(r.q.AddTransferBytes(ctx, arg))
+}
+
+func (r *RetryingQuerier) ChunkExistsByFileIdAndIndex(ctx context.Context, arg sqlc.ChunkExistsByFileIdAndIndexParams) (bool, error) {
+	return classify(retryValue(ctx, r.policy, func() (bool, error) {
+		return r.q.ChunkExistsByFileIdAndIndex(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) CompleteFileDownloadByShareId(ctx context.Context, shareID string) (sqlc.CompleteFileDownloadByShareIdRow, error) {
+	return classify(r.q.CompleteFileDownloadByShareId(ctx, shareID))
+}
+
+func (r *RetryingQuerier) ConsumeDownloadNonce(ctx context.Context, arg sqlc.ConsumeDownloadNonceParams) (int64, error) {
+	return classify(r.q.ConsumeDownloadNonce(ctx, arg))
+}
+
+func (r *RetryingQuerier) ConsumeUploadSlot(ctx context.Context, arg sqlc.ConsumeUploadSlotParams) (sqlc.UploadSlot, error) {
+	return classify(r.q.ConsumeUploadSlot(ctx, arg))
+}
+
+func (r *RetryingQuerier) CountActiveSharesByUploader(ctx context.Context, uploaderIp netip.Addr) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountActiveSharesByUploader(ctx, uploaderIp)
+	}))
+}
+
+func (r *RetryingQuerier) CountActiveUploads(ctx context.Context) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountActiveUploads(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) CountBundleFiles(ctx context.Context, bundleID pgtype.UUID) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountBundleFiles(ctx, bundleID)
+	}))
+}
+
+func (r *RetryingQuerier) CountChunksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountChunksByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) CountShareLinkDownload(ctx context.Context, id pgtype.UUID) (int64, error) {
+	return classify(r.q.CountShareLinkDownload(ctx, id))
+}
+
+func (r *RetryingQuerier) CountShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountShareLinksByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) CountUnfinishedUploads(ctx context.Context, arg sqlc.CountUnfinishedUploadsParams) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.CountUnfinishedUploads(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) CreateAuditLogEntry(ctx context.Context, arg sqlc.CreateAuditLogEntryParams) (sqlc.AuditLog, error) {
+	return classify(r.q.CreateAuditLogEntry(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateChunk(ctx context.Context, arg sqlc.CreateChunkParams) (int64, error) {
+	return classify(r.q.CreateChunk(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateDownloadNonce(ctx context.Context, arg sqlc.CreateDownloadNonceParams) (int64, error) {
+	return classify(r.q.CreateDownloadNonce(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateFile(ctx context.Context, arg sqlc.CreateFileParams) (sqlc.File, error) {
+	return classify(r.q.CreateFile(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateFileBundle(ctx context.Context, arg sqlc.CreateFileBundleParams) (sqlc.FileBundle, error) {
+	return classify(r.q.CreateFileBundle(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateFileKey(ctx context.Context, arg sqlc.CreateFileKeyParams) (int64, error) {
+	return classify(r.q.CreateFileKey(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateFileWebhook(ctx context.Context, arg sqlc.CreateFileWebhookParams) (int64, error) {
+	return classify(r.q.CreateFileWebhook(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreatePaste(ctx context.Context, arg sqlc.CreatePasteParams) (sqlc.Paste, error) {
+	return classify(r.q.CreatePaste(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateServerEncryptedFile(ctx context.Context, arg sqlc.CreateServerEncryptedFileParams) error {
+	return Classify(r.q.CreateServerEncryptedFile(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateShareLink(ctx context.Context, arg sqlc.CreateShareLinkParams) (sqlc.ShareLink, error) {
+	return classify(r.q.CreateShareLink(ctx, arg))
+}
+
+func (r *RetryingQuerier) CreateUploadSlot(ctx context.Context, arg sqlc.CreateUploadSlotParams) error {
+	return Classify(r.q.CreateUploadSlot(ctx, arg))
+}
+
+func (r *RetryingQuerier) DeleteExpiredDownloadNonces(ctx context.Context) (int64, error) {
+	return classify(r.q.DeleteExpiredDownloadNonces(ctx))
+}
+
+func (r *RetryingQuerier) DeleteExpiredPastes(ctx context.Context) (int64, error) {
+	return classify(r.q.DeleteExpiredPastes(ctx))
+}
+
+func (r *RetryingQuerier) DeleteBlockedNetwork(ctx context.Context, network netip.Prefix) (int64, error) {
+	return classify(r.q.DeleteBlockedNetwork(ctx, network))
+}
+
+func (r *RetryingQuerier) DeleteChunkOfUnpurgedFile(ctx context.Context, arg sqlc.DeleteChunkOfUnpurgedFileParams) (int64, error) {
+	return classify(r.q.DeleteChunkOfUnpurgedFile(ctx, arg))
+}
+
+func (r *RetryingQuerier) DeleteExpiredUploadSlots(ctx context.Context) (int64, error) {
+	return classify(r.q.DeleteExpiredUploadSlots(ctx))
+}
+
+func (r *RetryingQuerier) DeleteExpiredUploadSquatters(ctx context.Context) (int64, error) {
+	return classify(r.q.DeleteExpiredUploadSquatters(ctx))
+}
+
+func (r *RetryingQuerier) DeletePurgedFiles(ctx context.Context, arg sqlc.DeletePurgedFilesParams) (int64, error) {
+	return classify(r.q.DeletePurgedFiles(ctx, arg))
+}
+
+func (r *RetryingQuerier) EvictFile(ctx context.Context, id pgtype.UUID) (string, error) {
+	return classify(r.q.EvictFile(ctx, id))
+}
+
+func (r *RetryingQuerier) ExpireFilesByIds(ctx context.Context, dollar_1 []pgtype.UUID) error {
+	return Classify(r.q.ExpireFilesByIds(ctx, dollar_1))
+}
+
+func (r *RetryingQuerier) FileExistsByIdAndStatus(ctx context.Context, arg sqlc.FileExistsByIdAndStatusParams) (bool, error) {
+	return classify(retryValue(ctx, r.policy, func() (bool, error) {
+		return r.q.FileExistsByIdAndStatus(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) FlagUploadSquatter(ctx context.Context, arg sqlc.FlagUploadSquatterParams) (sqlc.UploadSquatter, error) {
+	return classify(r.q.FlagUploadSquatter(ctx, arg))
+}
+
+func (r *RetryingQuerier) ForceExpireFile(ctx context.Context, shareID string) (pgtype.UUID, error) {
+	return classify(r.q.ForceExpireFile(ctx, shareID))
+}
+
+func (r *RetryingQuerier) GetChunkByIndexAndFileShareID(ctx context.Context, arg sqlc.GetChunkByIndexAndFileShareIDParams) (sqlc.GetChunkByIndexAndFileShareIDRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetChunkByIndexAndFileShareIDRow, error) {
+		return r.q.GetChunkByIndexAndFileShareID(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetChunkHashByFileIdAndIndex(ctx context.Context, arg sqlc.GetChunkHashByFileIdAndIndexParams) (string, error) {
+	return classify(retryValue(ctx, r.policy, func() (string, error) {
+		return r.q.GetChunkHashByFileIdAndIndex(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetChunkThroughput(ctx context.Context, arg sqlc.GetChunkThroughputParams) (sqlc.GetChunkThroughputRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetChunkThroughputRow, error) {
+		return r.q.GetChunkThroughput(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetDownloadNonce(ctx context.Context, arg sqlc.GetDownloadNonceParams) (sqlc.GetDownloadNonceRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetDownloadNonceRow, error) {
+		return r.q.GetDownloadNonce(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetExpiredFiles(ctx context.Context) ([]sqlc.GetExpiredFilesRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.GetExpiredFilesRow, error) {
+		return r.q.GetExpiredFiles(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileBundleByShareId(ctx context.Context, shareID string) (sqlc.FileBundle, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.FileBundle, error) {
+		return r.q.GetFileBundleByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileBundleByTokenHash(ctx context.Context, tokenHash string) (sqlc.FileBundle, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.FileBundle, error) {
+		return r.q.GetFileBundleByTokenHash(ctx, tokenHash)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileByID(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.File, error) {
+		return r.q.GetFileByID(ctx, id)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileByShareID(ctx context.Context, shareID string) (sqlc.File, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.File, error) {
+		return r.q.GetFileByShareID(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileKeyByFileId(ctx context.Context, fileID pgtype.UUID) (sqlc.FileKey, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.FileKey, error) {
+		return r.q.GetFileKeyByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileMetadataByShareId(ctx context.Context, shareID string) (sqlc.GetFileMetadataByShareIdRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetFileMetadataByShareIdRow, error) {
+		return r.q.GetFileMetadataByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileSaltByShareId(ctx context.Context, shareID string) (string, error) {
+	return classify(retryValue(ctx, r.policy, func() (string, error) {
+		return r.q.GetFileSaltByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetShareIDByAlias(ctx context.Context, alias pgtype.Text) (string, error) {
+	return classify(retryValue(ctx, r.policy, func() (string, error) {
+		return r.q.GetShareIDByAlias(ctx, alias)
+	}))
+}
+
+func (r *RetryingQuerier) ShareNameTaken(ctx context.Context, shareID string) (bool, error) {
+	return classify(retryValue(ctx, r.policy, func() (bool, error) {
+		return r.q.ShareNameTaken(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetFileWebhookByFileId(ctx context.Context, fileID pgtype.UUID) (sqlc.FileWebhook, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.FileWebhook, error) {
+		return r.q.GetFileWebhookByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) GetOldestRetainedFile(ctx context.Context, periodEnd pgtype.Timestamptz) (sqlc.GetOldestRetainedFileRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetOldestRetainedFileRow, error) {
+		return r.q.GetOldestRetainedFile(ctx, periodEnd)
+	}))
+}
+
+func (r *RetryingQuerier) GetPasteByShareId(ctx context.Context, shareID string) (sqlc.Paste, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.Paste, error) {
+		return r.q.GetPasteByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) GetRetentionReport(ctx context.Context, periodStart pgtype.Timestamptz) (sqlc.RetentionReport, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.RetentionReport, error) {
+		return r.q.GetRetentionReport(ctx, periodStart)
+	}))
+}
+
+func (r *RetryingQuerier) GetRetentionStats(ctx context.Context, arg sqlc.GetRetentionStatsParams) (sqlc.GetRetentionStatsRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetRetentionStatsRow, error) {
+		return r.q.GetRetentionStats(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetServerEncryptionKeyIdByFileId(ctx context.Context, fileID pgtype.UUID) (string, error) {
+	return classify(retryValue(ctx, r.policy, func() (string, error) {
+		return r.q.GetServerEncryptionKeyIdByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) GetShareLink(ctx context.Context, linkID string) (sqlc.GetShareLinkRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetShareLinkRow, error) {
+		return r.q.GetShareLink(ctx, linkID)
+	}))
+}
+
+func (r *RetryingQuerier) GetStorageTotals(ctx context.Context) ([]sqlc.GetStorageTotalsRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.GetStorageTotalsRow, error) {
+		return r.q.GetStorageTotals(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) GetStoredBytes(ctx context.Context) (int64, error) {
+	return classify(retryValue(ctx, r.policy, func() (int64, error) {
+		return r.q.GetStoredBytes(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) GetUploadSlot(ctx context.Context, arg sqlc.GetUploadSlotParams) (sqlc.UploadSlot, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.UploadSlot, error) {
+		return r.q.GetUploadSlot(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetUploadSquatter(ctx context.Context, ip netip.Addr) (sqlc.UploadSquatter, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.UploadSquatter, error) {
+		return r.q.GetUploadSquatter(ctx, ip)
+	}))
+}
+
+func (r *RetryingQuerier) GetUploaderUsage(ctx context.Context, arg sqlc.GetUploaderUsageParams) (sqlc.GetUploaderUsageRow, error) {
+	return classify(retryValue(ctx, r.policy, func() (sqlc.GetUploaderUsageRow, error) {
+		return r.q.GetUploaderUsage(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) GetWatermarkByShareId(ctx context.Context, shareID string) (pgtype.Text, error) {
+	return classify(retryValue(ctx, r.policy, func() (pgtype.Text, error) {
+		return r.q.GetWatermarkByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) ListAdminFiles(ctx context.Context, arg sqlc.ListAdminFilesParams) ([]sqlc.ListAdminFilesRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListAdminFilesRow, error) {
+		return r.q.ListAdminFiles(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) ListAuditLogByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.AuditLog, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.AuditLog, error) {
+		return r.q.ListAuditLogByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) ListAuditLogByFileIdsAndAction(ctx context.Context, arg sqlc.ListAuditLogByFileIdsAndActionParams) ([]sqlc.AuditLog, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.AuditLog, error) {
+		return r.q.ListAuditLogByFileIdsAndAction(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) ListBlockedNetworks(ctx context.Context) ([]sqlc.BlockedNetwork, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.BlockedNetwork, error) {
+		return r.q.ListBlockedNetworks(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) ListChunkIndexesByFileId(ctx context.Context, fileID pgtype.UUID) ([]int32, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]int32, error) {
+		return r.q.ListChunkIndexesByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) ListChunkManifestByShareId(ctx context.Context, shareID string) ([]sqlc.ListChunkManifestByShareIdRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListChunkManifestByShareIdRow, error) {
+		return r.q.ListChunkManifestByShareId(ctx, shareID)
+	}))
+}
+
+func (r *RetryingQuerier) ListChunksByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.Chunk, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.Chunk, error) {
+		return r.q.ListChunksByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) ListChunksOfUnpurgedFile(ctx context.Context, fileID pgtype.UUID) ([]sqlc.ListChunksOfUnpurgedFileRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListChunksOfUnpurgedFileRow, error) {
+		return r.q.ListChunksOfUnpurgedFile(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) ListDailyTransferTotals(ctx context.Context, since pgtype.Date) ([]sqlc.ListDailyTransferTotalsRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListDailyTransferTotalsRow, error) {
+		return r.q.ListDailyTransferTotals(ctx, since)
+	}))
+}
+
+func (r *RetryingQuerier) ListEvictionCandidates(ctx context.Context, limit int32) ([]sqlc.ListEvictionCandidatesRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListEvictionCandidatesRow, error) {
+		return r.q.ListEvictionCandidates(ctx, limit)
+	}))
+}
+
+func (r *RetryingQuerier) ListFeatureFlags(ctx context.Context) ([]sqlc.FeatureFlag, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.FeatureFlag, error) {
+		return r.q.ListFeatureFlags(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) ListFileWebhooksByFileIds(ctx context.Context, dollar_1 []pgtype.UUID) ([]sqlc.ListFileWebhooksByFileIdsRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListFileWebhooksByFileIdsRow, error) {
+		return r.q.ListFileWebhooksByFileIds(ctx, dollar_1)
+	}))
+}
+
+func (r *RetryingQuerier) ListFilesByDeletionTokens(ctx context.Context, dollar_1 []string) ([]sqlc.File, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.File, error) {
+		return r.q.ListFilesByDeletionTokens(ctx, dollar_1)
+	}))
+}
+
+func (r *RetryingQuerier) ListReadyBundleFiles(ctx context.Context, bundleID pgtype.UUID) ([]sqlc.ListReadyBundleFilesRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListReadyBundleFilesRow, error) {
+		return r.q.ListReadyBundleFiles(ctx, bundleID)
+	}))
+}
+
+func (r *RetryingQuerier) ListRetentionReports(ctx context.Context) ([]sqlc.ListRetentionReportsRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListRetentionReportsRow, error) {
+		return r.q.ListRetentionReports(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) ListShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.ShareLink, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ShareLink, error) {
+		return r.q.ListShareLinksByFileId(ctx, fileID)
+	}))
+}
+
+func (r *RetryingQuerier) ListTopTransferFiles(ctx context.Context, arg sqlc.ListTopTransferFilesParams) ([]sqlc.ListTopTransferFilesRow, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]sqlc.ListTopTransferFilesRow, error) {
+		return r.q.ListTopTransferFiles(ctx, arg)
+	}))
+}
+
+func (r *RetryingQuerier) ListUnpurgedFileIdsWithChunks(ctx context.Context) ([]pgtype.UUID, error) {
+	return classify(retryValue(ctx, r.policy, func() ([]pgtype.UUID, error) {
+		return r.q.ListUnpurgedFileIdsWithChunks(ctx)
+	}))
+}
+
+func (r *RetryingQuerier) MarkFileReady(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	return classify(r.q.MarkFileReady(ctx, id))
+}
+
+func (r *RetryingQuerier) MarkUnpurgedFileCorrupt(ctx context.Context, id pgtype.UUID) error {
+	return Classify(r.q.MarkUnpurgedFileCorrupt(ctx, id))
+}
+
+func (r *RetryingQuerier) ReadPasteByShareId(ctx context.Context, shareID string) (sqlc.Paste, error) {
+	return classify(r.q.ReadPasteByShareId(ctx, shareID))
+}
+
+func (r *RetryingQuerier) RecordDownloadNonceChunks(ctx context.Context, arg sqlc.RecordDownloadNonceChunksParams) (sqlc.RecordDownloadNonceChunksRow, error) {
+	return classify(r.q.RecordDownloadNonceChunks(ctx, arg))
+}
+
+func (r *RetryingQuerier) ResetStaleVerifications(ctx context.Context, startedBefore pgtype.Timestamptz) (int64, error) {
+	return classify(r.q.ResetStaleVerifications(ctx, startedBefore))
+}
+
+func (r *RetryingQuerier) RevokeFile(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	return classify(r.q.RevokeFile(ctx, id))
+}
+
+func (r *RetryingQuerier) RevokeShareLink(ctx context.Context, arg sqlc.RevokeShareLinkParams) (sqlc.ShareLink, error) {
+	return classify(r.q.RevokeShareLink(ctx, arg))
+}
+
+func (r *RetryingQuerier) StartFileVerification(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	return classify(r.q.StartFileVerification(ctx, id))
+}
+
+func (r *RetryingQuerier) UpdateFileAdminNotes(ctx context.Context, arg sqlc.UpdateFileAdminNotesParams) (sqlc.File, error) {
+	return classify(r.q.UpdateFileAdminNotes(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpdateFileMaxDownloadRate(ctx context.Context, arg sqlc.UpdateFileMaxDownloadRateParams) (int64, error) {
+	return classify(r.q.UpdateFileMaxDownloadRate(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpdateFileStatus(ctx context.Context, arg sqlc.UpdateFileStatusParams) (sqlc.File, error) {
+	return classify(r.q.UpdateFileStatus(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpdateServerEncryptedFileWatermark(ctx context.Context, arg sqlc.UpdateServerEncryptedFileWatermarkParams) (int64, error) {
+	return classify(r.q.UpdateServerEncryptedFileWatermark(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpsertBlockedNetwork(ctx context.Context, arg sqlc.UpsertBlockedNetworkParams) (sqlc.BlockedNetwork, error) {
+	return classify(r.q.UpsertBlockedNetwork(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpsertFeatureFlag(ctx context.Context, arg sqlc.UpsertFeatureFlagParams) (sqlc.FeatureFlag, error) {
+	return classify(r.q.UpsertFeatureFlag(ctx, arg))
+}
+
+func (r *RetryingQuerier) UpsertRetentionReport(ctx context.Context, arg sqlc.UpsertRetentionReportParams) (sqlc.RetentionReport, error) {
+	return classify(r.q.UpsertRetentionReport(ctx, arg))
+}
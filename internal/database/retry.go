@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var retries = expvar.NewInt("db_retries")
+
+// RetryPolicy bounds how often and how quickly transient failures are
+// retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy rides out a typical Postgres failover without holding
+// requests for long.
+func DefaultRetryPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: attempts,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+}
+
+// IsRetryable reports whether err is a transient failure after which the same
+// statement or transaction can safely run again.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+
+	// A commit whose outcome is unknown must not be replayed
+	var ce *commitError
+	if errors.As(err, &ce) {
+		// Serialization failures at commit mean the server rolled back
+		if errors.As(ce.err, &pgErr) {
+			return pgErr.Code == "40001" || pgErr.Code == "40P01"
+		}
+		return pgconn.SafeToRetry(ce.err)
+	}
+
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08: connection exceptions
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Retry runs fn until it succeeds, fails with a non-retryable error, or the
+// policy's attempts are used up. Backoff doubles from BaseDelay with jitter.
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	delay := policy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		retries.Add(1)
+		wait := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, policy.MaxDelay)
+	}
+}
+
+// retryValue is Retry for calls that return a value.
+func retryValue[T any](ctx context.Context, policy RetryPolicy, fn func() (T, error)) (T, error) {
+	var result T
+	err := Retry(ctx, policy, func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+// commitError marks a failed COMMIT so it is only retried when the server
+// cannot have applied it.
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string {
+	return "commit failed: " + e.err.Error()
+}
+
+func (e *commitError) Unwrap() error {
+	return e.err
+}
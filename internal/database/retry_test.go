@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "no rows", err: pgx.ErrNoRows, want: false},
+		{name: "context canceled", err: context.Canceled, want: false},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, want: true},
+		{name: "deadlock", err: &pgconn.PgError{Code: "40P01"}, want: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, want: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, want: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: false},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+		{name: "wrapped serialization failure", err: fmt.Errorf("query: %w", &pgconn.PgError{Code: "40001"}), want: true},
+		{name: "commit reset", err: &commitError{err: syscall.ECONNRESET}, want: false},
+		{name: "commit serialization failure", err: &commitError{err: &pgconn.PgError{Code: "40001"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestRetry_SucceedsAfterTransientErrors(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), testPolicy, func() error {
+		calls++
+		if calls < 3 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_StopsAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), testPolicy, func() error {
+		calls++
+		return syscall.ECONNRESET
+	})
+
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.Equal(t, testPolicy.MaxAttempts, calls)
+}
+
+func TestRetry_DoesNotRetryPermanentErrors(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), testPolicy, func() error {
+		calls++
+		return pgx.ErrNoRows
+	})
+
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetry_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	calls := 0
+	err := Retry(ctx, policy, func() error {
+		calls++
+		cancel()
+		return syscall.ECONNRESET
+	})
+
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.Equal(t, 1, calls)
+}
+
+// flakyQuerier fails every call once with a connection reset.
+type flakyQuerier struct {
+	sqlc.Querier
+	calls map[string]int
+}
+
+func (f *flakyQuerier) fail(name string) error {
+	f.calls[name]++
+	if f.calls[name] == 1 {
+		return syscall.ECONNRESET
+	}
+	return nil
+}
+
+func (f *flakyQuerier) GetFileByID(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	return sqlc.File{ID: id}, f.fail("GetFileByID")
+}
+
+func (f *flakyQuerier) UpdateFileStatus(ctx context.Context, arg sqlc.UpdateFileStatusParams) (sqlc.File, error) {
+	return sqlc.File{}, f.fail("UpdateFileStatus")
+}
+
+func TestRetryingQuerier_RetriesReadsOnly(t *testing.T) {
+	flaky := &flakyQuerier{calls: map[string]int{}}
+	q := NewRetryingQuerier(flaky, testPolicy)
+	ctx := context.Background()
+
+	_, err := q.GetFileByID(ctx, pgtype.UUID{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, flaky.calls["GetFileByID"])
+
+	_, err = q.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{})
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.Equal(t, 1, flaky.calls["UpdateFileStatus"])
+}
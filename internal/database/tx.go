@@ -9,15 +9,28 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type TxRunner = func(ctx context.Context, fn func(q *sqlc.Queries) error) error
+type TxRunner = func(ctx context.Context, fn func(q sqlc.Querier) error) error
 
 func NewTxRunner(pool *pgxpool.Pool) TxRunner {
-	return func(ctx context.Context, fn func(q *sqlc.Queries) error) error {
+	return func(ctx context.Context, fn func(q sqlc.Querier) error) error {
 		return RunWithTx(ctx, pool, fn)
 	}
 }
 
-func RunWithTx(ctx context.Context, pool *pgxpool.Pool, fn func(q *sqlc.Queries) error) error {
+// NewRetryingTxRunner is NewTxRunner that reruns the whole transaction after
+// transient failures such as a Postgres failover. fn must only have effects
+// inside the transaction, since it may be called more than once.
+func NewRetryingTxRunner(pool *pgxpool.Pool, policy RetryPolicy) TxRunner {
+	return func(ctx context.Context, fn func(q sqlc.Querier) error) error {
+		return Retry(ctx, policy, func() error {
+			return RunWithTx(ctx, pool, fn)
+		})
+	}
+}
+
+// RunWithTx runs fn in a transaction, committing it when fn succeeds. The
+// error fn fails with is passed through Classify.
+func RunWithTx(ctx context.Context, pool *pgxpool.Pool, fn func(q sqlc.Querier) error) error {
 	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
@@ -26,11 +39,15 @@ func RunWithTx(ctx context.Context, pool *pgxpool.Pool, fn func(q *sqlc.Queries)
 	q := sqlc.New(tx)
 
 	if err := fn(q); err != nil {
+		err = Classify(err)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			return fmt.Errorf("rollback error: %v; original: %w", rbErr, err)
 		}
 		return err
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return &commitError{err: err}
+	}
+	return nil
 }
@@ -0,0 +1,173 @@
+// Package envelope wraps stored chunks in a server-side encryption layer on
+// top of client E2EE. Each file gets its own data key, which is itself
+// encrypted ("wrapped") by a master key held by a KeyProvider, so bucket
+// contents are useless without access to the provider.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// Algorithm identifies the cipher used for data keys and sealed chunks.
+	Algorithm = "AES-256-GCM"
+
+	KeySize   = 32
+	nonceSize = 12
+	// Overhead is the number of bytes Seal adds to each chunk.
+	Overhead = nonceSize + 16
+)
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+// KeyProvider wraps and unwraps data keys with a master key it never
+// exposes. Implementations may call out to an external KMS.
+type KeyProvider interface {
+	// KeyID identifies the master key new data keys are wrapped with.
+	KeyID() string
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope generates per-file data keys and seals chunks with them.
+type Envelope struct {
+	provider KeyProvider
+}
+
+func New(provider KeyProvider) *Envelope {
+	return &Envelope{provider: provider}
+}
+
+// FromEnv builds an Envelope backed by a LocalKeyProvider when
+// STORAGE_MASTER_KEY is set. It returns nil when envelope encryption is
+// disabled.
+func FromEnv() (*Envelope, error) {
+	encoded := os.Getenv("STORAGE_MASTER_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_MASTER_KEY: %w", err)
+	}
+	provider, err := NewLocalKeyProvider(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_MASTER_KEY: %w", err)
+	}
+	return New(provider), nil
+}
+
+func (e *Envelope) KeyID() string {
+	return e.provider.KeyID()
+}
+
+// NewDataKey returns a fresh data key together with its wrapped form.
+func (e *Envelope) NewDataKey(ctx context.Context) (dataKey, wrapped []byte, err error) {
+	dataKey = make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err = e.provider.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return dataKey, wrapped, nil
+}
+
+func (e *Envelope) UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	dataKey, err := e.provider.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Seal encrypts plaintext with dataKey. aad binds the ciphertext to its
+// storage location so chunks cannot be swapped between objects.
+func Seal(dataKey, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize, nonceSize+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func Open(dataKey, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < Overhead {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed data: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKeyProvider wraps data keys with a master key held in process memory.
+type LocalKeyProvider struct {
+	key []byte
+	id  string
+}
+
+func NewLocalKeyProvider(masterKey []byte) (*LocalKeyProvider, error) {
+	if len(masterKey) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(masterKey))
+	}
+
+	// Derive a stable, non-secret ID so rows record which master key
+	// wrapped them
+	sum := sha256.Sum256(masterKey)
+	return &LocalKeyProvider{
+		key: masterKey,
+		id:  "local:" + hex.EncodeToString(sum[:8]),
+	}, nil
+}
+
+func (p *LocalKeyProvider) KeyID() string {
+	return p.id
+}
+
+func (p *LocalKeyProvider) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	return Seal(p.key, dataKey, []byte(p.id))
+}
+
+func (p *LocalKeyProvider) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.id {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return Open(p.key, wrapped, []byte(keyID))
+}
@@ -0,0 +1,110 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEnvelope(t *testing.T) *Envelope {
+	t.Helper()
+	provider, err := NewLocalKeyProvider(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(t, err)
+	return New(provider)
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	env := testEnvelope(t)
+
+	dataKey, wrapped, err := env.NewDataKey(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, dataKey, wrapped)
+
+	unwrapped, err := env.UnwrapDataKey(ctx, env.KeyID(), wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	plaintext := []byte("client-encrypted chunk")
+	sealed, err := Seal(dataKey, plaintext, []byte("file/0.enc"))
+	require.NoError(t, err)
+	assert.Len(t, sealed, len(plaintext)+Overhead)
+
+	opened, err := Open(unwrapped, sealed, []byte("file/0.enc"))
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestOpen_RejectsWrongAAD(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	sealed, err := Seal(key, []byte("chunk"), []byte("file/0.enc"))
+	require.NoError(t, err)
+
+	_, err = Open(key, sealed, []byte("file/1.enc"))
+
+	assert.Error(t, err)
+}
+
+func TestOpen_RejectsTruncated(t *testing.T) {
+	_, err := Open(bytes.Repeat([]byte{1}, KeySize), make([]byte, Overhead-1), nil)
+
+	assert.Error(t, err)
+}
+
+func TestLocalKeyProvider_UnknownKeyID(t *testing.T) {
+	ctx := context.Background()
+	env := testEnvelope(t)
+	_, wrapped, err := env.NewDataKey(ctx)
+	require.NoError(t, err)
+
+	_, err = env.UnwrapDataKey(ctx, "local:0000000000000000", wrapped)
+
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestNewLocalKeyProvider_InvalidKeySize(t *testing.T) {
+	_, err := NewLocalKeyProvider(make([]byte, 16))
+
+	assert.Error(t, err)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Run("unset disables envelope", func(t *testing.T) {
+		t.Setenv("STORAGE_MASTER_KEY", "")
+
+		env, err := FromEnv()
+
+		require.NoError(t, err)
+		assert.Nil(t, env)
+	})
+
+	t.Run("valid key", func(t *testing.T) {
+		t.Setenv("STORAGE_MASTER_KEY", base64.StdEncoding.EncodeToString(make([]byte, KeySize)))
+
+		env, err := FromEnv()
+
+		require.NoError(t, err)
+		require.NotNil(t, env)
+		assert.Contains(t, env.KeyID(), "local:")
+	})
+
+	t.Run("malformed key", func(t *testing.T) {
+		t.Setenv("STORAGE_MASTER_KEY", "not-base64!")
+
+		_, err := FromEnv()
+
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		t.Setenv("STORAGE_MASTER_KEY", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+
+		_, err := FromEnv()
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,137 @@
+// Package events fans out share status changes to subscribers in the same
+// process, such as recipients watching a share over server-sent events.
+// Events are not shared between instances.
+package events
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Share status changes.
+const (
+	// TypeChunkReceived reports a chunk stored while the file is uploading.
+	TypeChunkReceived   = "chunk_received"
+	TypeDownloadCounted = "download_counted"
+	TypeRevoked         = "revoked"
+	TypeExpired         = "expired"
+	// TypeRevokedByUploader keeps the share's data, unlike TypeRevoked
+	// from operators and eviction.
+	TypeRevokedByUploader = "revoked_by_uploader"
+	// TypeVerifying reports the progress of a background chunk check.
+	TypeVerifying = "verifying"
+	TypeReady     = "ready"
+	TypeCorrupt   = "corrupt"
+	// TypeVerificationFailed means storage could not be checked, leaving
+	// the file uploading for the client to finalize again.
+	TypeVerificationFailed = "verification_failed"
+)
+
+// subscriberBuffer bounds the events queued for one subscriber. Events for a
+// subscriber that falls further behind are dropped rather than blocking
+// publishers.
+const subscriberBuffer = 8
+
+var dropped = expvar.NewInt("events_dropped")
+
+type Event struct {
+	Type          string
+	ShareID       string
+	DownloadCount int32
+	MaxDownloads  int32
+	// ChunksVerified of ChunkCount is the progress of a TypeVerifying event.
+	ChunksVerified int32
+	ChunkCount     int32
+	// ChunkIndex is the chunk stored by a TypeChunkReceived event.
+	ChunkIndex int32
+	OccurredAt time.Time
+}
+
+// Bus delivers each published event to the current subscribers of its share.
+// A nil Bus drops every event.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan Event]struct{}
+	closed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Publish never blocks. Subscribers whose buffer is full miss the event.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[e.ShareID] {
+		select {
+		case ch <- e:
+		default:
+			dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe returns the events of shareID published from now on, and a
+// function that ends the subscription. The channel is closed when the
+// subscription ends or the bus is closed. Subscribing to a nil Bus returns a
+// channel that never delivers.
+func (b *Bus) Subscribe(shareID string) (<-chan Event, func()) {
+	if b == nil {
+		return nil, func() {}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[shareID] == nil {
+		b.subs[shareID] = make(map[chan Event]struct{})
+	}
+	b.subs[shareID][ch] = struct{}{}
+
+	return ch, func() { b.unsubscribe(shareID, ch) }
+}
+
+func (b *Bus) unsubscribe(shareID string, ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[shareID][ch]; !ok {
+		return
+	}
+	delete(b.subs[shareID], ch)
+	if len(b.subs[shareID]) == 0 {
+		delete(b.subs, shareID)
+	}
+	close(ch)
+}
+
+// Close ends every subscription, so long-lived watchers return before the
+// server shuts down.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for shareID, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(b.subs, shareID)
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_DeliversToShareSubscribers(t *testing.T) {
+	b := NewBus()
+	ch, stop := b.Subscribe("abc123")
+	defer stop()
+	other, stopOther := b.Subscribe("zzz999")
+	defer stopOther()
+
+	b.Publish(Event{Type: TypeDownloadCounted, ShareID: "abc123", DownloadCount: 1, MaxDownloads: 3})
+
+	select {
+	case e := <-ch:
+		assert.Equal(t, TypeDownloadCounted, e.Type)
+		assert.Equal(t, int32(1), e.DownloadCount)
+		assert.False(t, e.OccurredAt.IsZero())
+	default:
+		t.Fatal("event was not delivered")
+	}
+	assert.Empty(t, other)
+}
+
+func TestBus_DropsForSlowSubscribers(t *testing.T) {
+	b := NewBus()
+	ch, stop := b.Subscribe("abc123")
+	defer stop()
+
+	before := dropped.Value()
+	for range subscriberBuffer + 2 {
+		b.Publish(Event{Type: TypeDownloadCounted, ShareID: "abc123"})
+	}
+
+	assert.Len(t, ch, subscriberBuffer)
+	assert.Equal(t, before+2, dropped.Value())
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	b := NewBus()
+	ch, stop := b.Subscribe("abc123")
+	stop()
+	stop()
+
+	b.Publish(Event{Type: TypeRevoked, ShareID: "abc123"})
+
+	_, ok := <-ch
+	assert.False(t, ok)
+	assert.Empty(t, b.subs)
+}
+
+func TestBus_Close(t *testing.T) {
+	b := NewBus()
+	ch, stop := b.Subscribe("abc123")
+	b.Close()
+	stop()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	late, _ := b.Subscribe("abc123")
+	_, ok = <-late
+	assert.False(t, ok)
+}
+
+func TestBus_Nil(t *testing.T) {
+	var b *Bus
+	b.Publish(Event{Type: TypeExpired, ShareID: "abc123"})
+	b.Close()
+
+	ch, stop := b.Subscribe("abc123")
+	stop()
+	require.Nil(t, ch)
+}
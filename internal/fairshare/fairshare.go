@@ -0,0 +1,204 @@
+// Package fairshare divides download bandwidth fairly between shares.
+//
+// Every share with pending reads is a flow. Flows are served round-robin with
+// deficit counters, so each active share gets an equal slice of the
+// configured rate no matter how many chunk requests it has in flight. A
+// small file competing with a large one therefore finishes at the speed its
+// fair slice allows instead of waiting behind the large file's parallel
+// requests.
+package fairshare
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQuantum is the largest read granted to a flow in one round.
+	DefaultQuantum = 32 * 1024
+
+	tick = 10 * time.Millisecond
+)
+
+var ErrStopped = errors.New("fair share scheduler stopped")
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+type flow struct {
+	key     string
+	waiters []*waiter
+	deficit int64
+}
+
+// Scheduler grants bandwidth to flows at a fixed total rate.
+type Scheduler struct {
+	rate    int64 // bytes per second
+	quantum int64
+	burst   int64
+
+	mu     sync.Mutex
+	budget int64
+	flows  map[string]*flow
+	active []*flow
+	next   int
+	done   chan struct{}
+}
+
+// New returns a Scheduler that serves rate bytes per second in total.
+func New(rate int64) *Scheduler {
+	burst := rate / 10
+	if burst < DefaultQuantum {
+		burst = DefaultQuantum
+	}
+
+	return &Scheduler{
+		rate:    rate,
+		quantum: DefaultQuantum,
+		burst:   burst,
+		flows:   make(map[string]*flow),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start refills the budget until ctx is cancelled. Pending and later Acquire
+// calls then fail with ErrStopped.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.mu.Lock()
+				s.budget = min(s.budget+int64(now.Sub(last).Seconds()*float64(s.rate)), s.burst)
+				s.dispatchLocked()
+				s.mu.Unlock()
+				last = now
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Acquire blocks until n bytes may be sent for the flow identified by key.
+// n is capped at the scheduler quantum; callers should read in chunks of at
+// most that size.
+func (s *Scheduler) Acquire(ctx context.Context, key string, n int) error {
+	w := &waiter{n: min(int64(n), s.quantum), ready: make(chan struct{})}
+
+	s.mu.Lock()
+	f, ok := s.flows[key]
+	if !ok {
+		f = &flow{key: key}
+		s.flows[key] = f
+		s.active = append(s.active, f)
+	}
+	f.waiters = append(f.waiters, w)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.cancel(f, w)
+		return ctx.Err()
+	case <-s.done:
+		return ErrStopped
+	}
+}
+
+// Reader wraps r so every read is paced by the flow for key.
+func (s *Scheduler) Reader(ctx context.Context, key string, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, key: key, r: r, s: s}
+}
+
+// dispatchLocked grants waiting reads round-robin, at most one quantum per
+// flow per turn, until the budget or the waiters run out.
+func (s *Scheduler) dispatchLocked() {
+	for len(s.active) > 0 {
+		if s.next >= len(s.active) {
+			s.next = 0
+		}
+
+		f := s.active[s.next]
+		f.deficit = min(f.deficit+s.quantum, s.quantum)
+
+		for len(f.waiters) > 0 && f.waiters[0].n <= f.deficit {
+			w := f.waiters[0]
+			if w.n > s.budget {
+				// Out of budget: resume with this flow on the next refill
+				return
+			}
+			f.waiters = f.waiters[1:]
+			f.deficit -= w.n
+			s.budget -= w.n
+			close(w.ready)
+		}
+
+		if len(f.waiters) == 0 {
+			s.removeLocked(s.next)
+		} else {
+			s.next++
+		}
+	}
+}
+
+func (s *Scheduler) cancel(f *flow, w *waiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, fw := range f.waiters {
+		if fw == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(f.waiters) == 0 {
+		for i, af := range s.active {
+			if af == f {
+				s.removeLocked(i)
+				break
+			}
+		}
+	}
+}
+
+func (s *Scheduler) removeLocked(i int) {
+	f := s.active[i]
+	s.active = append(s.active[:i], s.active[i+1:]...)
+	if i < s.next {
+		s.next--
+	}
+	if s.flows[f.key] == f {
+		delete(s.flows, f.key)
+	}
+}
+
+type reader struct {
+	ctx context.Context
+	key string
+	r   io.Reader
+	s   *Scheduler
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.s.quantum {
+		p = p[:r.s.quantum]
+	}
+	if err := r.s.Acquire(r.ctx, r.key, len(p)); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
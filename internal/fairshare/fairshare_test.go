@@ -0,0 +1,157 @@
+package fairshare
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader_CopiesAllData(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(64 << 20)
+	s.Start(ctx)
+
+	data := bytes.Repeat([]byte("gzln"), 50_000)
+	var out bytes.Buffer
+
+	_, err := io.Copy(&out, s.Reader(ctx, "share", bytes.NewReader(data)))
+
+	require.NoError(t, err)
+	assert.Equal(t, data, out.Bytes())
+}
+
+func TestScheduler_SmallShareNotStarvedByParallelLargeShare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 4MB/s: the large share alone would need ~2s for its 8MB
+	s := New(4 << 20)
+	s.Start(ctx)
+
+	var wg sync.WaitGroup
+	largeDone := make(chan time.Time, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = io.Copy(io.Discard, s.Reader(ctx, "large", bytes.NewReader(make([]byte, 1<<20))))
+			largeDone <- time.Now()
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	_, err := io.Copy(io.Discard, s.Reader(ctx, "small", bytes.NewReader(make([]byte, 256<<10))))
+	require.NoError(t, err)
+	smallElapsed := time.Since(start)
+
+	// With a fair half of 4MB/s the small share needs ~125ms; sharing
+	// per request with 8 large requests would take ~560ms.
+	assert.Less(t, smallElapsed, 400*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestAcquire_ContextCancelled(t *testing.T) {
+	s := New(1)
+	s.Start(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.Acquire(ctx, "share", DefaultQuantum)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Empty(t, s.active)
+	assert.Empty(t, s.flows)
+}
+
+func TestAcquire_SchedulerStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(1)
+	s.Start(ctx)
+	cancel()
+
+	err := s.Acquire(context.Background(), "share", DefaultQuantum)
+
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestDispatch_RoundRobinAcrossFlows(t *testing.T) {
+	s := New(1 << 20)
+
+	var waiters []*waiter
+	for _, key := range []string{"a", "a", "a", "b"} {
+		w := &waiter{n: DefaultQuantum, ready: make(chan struct{})}
+		f, ok := s.flows[key]
+		if !ok {
+			f = &flow{key: key}
+			s.flows[key] = f
+			s.active = append(s.active, f)
+		}
+		f.waiters = append(f.waiters, w)
+		waiters = append(waiters, w)
+	}
+
+	// Budget for two quanta: one per flow, not two for the busier flow
+	s.budget = 2 * DefaultQuantum
+	s.dispatchLocked()
+
+	assert.True(t, isClosed(waiters[0].ready))
+	assert.False(t, isClosed(waiters[1].ready))
+	assert.True(t, isClosed(waiters[3].ready))
+	require.Len(t, s.active, 1)
+	assert.Equal(t, "a", s.active[0].key)
+
+	s.budget = 2 * DefaultQuantum
+	s.dispatchLocked()
+
+	assert.True(t, isClosed(waiters[1].ready))
+	assert.True(t, isClosed(waiters[2].ready))
+	assert.Empty(t, s.active)
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(1 << 40)
+	s.Start(ctx)
+	data := make([]byte, 1<<20)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for range b.N {
+		_, _ = io.Copy(io.Discard, s.Reader(ctx, "share", bytes.NewReader(data)))
+	}
+}
+
+func BenchmarkReader_Unthrottled(b *testing.B) {
+	data := make([]byte, 1<<20)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for range b.N {
+		_, _ = io.Copy(io.Discard, struct{ io.Reader }{bytes.NewReader(data)})
+	}
+}
@@ -0,0 +1,224 @@
+// Package flags switches risky features off and on at runtime without a
+// redeploy. Defaults come from FEATURE_FLAGS; flags toggled through the admin
+// API are stored in the database, override the defaults and reach every
+// instance on its next refresh.
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+)
+
+// Flags guard features that are already enabled by their own configuration,
+// so they default to on and act as kill switches.
+const (
+	// PresignedUploads lets upload init hand out presigned chunk URLs.
+	// Uploads already started can still fetch URLs and confirm chunks.
+	PresignedUploads = "presigned_uploads"
+	// QuotaEviction lets a full storage quota evict shares that can no
+	// longer be downloaded.
+	QuotaEviction = "quota_eviction"
+	// RequestMirroring replays sampled requests against the canary.
+	RequestMirroring = "request_mirroring"
+)
+
+// DefaultRefreshInterval is how often stored overrides are reloaded.
+const DefaultRefreshInterval = 30 * time.Second
+
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// known is kept sorted by name.
+var known = []string{PresignedUploads, QuotaEviction, RequestMirroring}
+
+// Store reads the overrides toggled through the admin API.
+type Store interface {
+	ListFeatureFlags(ctx context.Context) ([]sqlc.FeatureFlag, error)
+}
+
+// Flag is the state of one flag. Overridden is set when the admin API
+// toggled it, taking precedence over its default.
+type Flag struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Default    bool   `json:"default"`
+	Overridden bool   `json:"overridden"`
+}
+
+// Provider answers whether a flag is enabled. A nil Provider enables every
+// flag, leaving features to their own configuration.
+type Provider struct {
+	store    Store
+	defaults map[string]bool
+
+	mu        sync.RWMutex
+	overrides map[string]bool
+}
+
+// New returns a Provider with every known flag enabled unless defaults says
+// otherwise. Overrides are loaded from store by Refresh.
+func New(store Store, defaults map[string]bool) (*Provider, error) {
+	p := &Provider{
+		store:     store,
+		defaults:  make(map[string]bool, len(known)),
+		overrides: make(map[string]bool),
+	}
+	for _, name := range known {
+		p.defaults[name] = true
+	}
+	for name, enabled := range defaults {
+		if !Known(name) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownFlag, name)
+		}
+		p.defaults[name] = enabled
+	}
+	return p, nil
+}
+
+// FromEnv builds a Provider with defaults from FEATURE_FLAGS, a comma
+// separated list of name=true|false pairs.
+func FromEnv(store Store) (*Provider, error) {
+	defaults := make(map[string]bool)
+	for pair := range strings.SplitSeq(os.Getenv("FEATURE_FLAGS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("FEATURE_FLAGS entries must be name=true|false, got %q", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("FEATURE_FLAGS value for %q must be true or false, got %q", name, value)
+		}
+		defaults[strings.TrimSpace(name)] = enabled
+	}
+	return New(store, defaults)
+}
+
+// Known reports whether name is a flag the server checks.
+func Known(name string) bool {
+	return slices.Contains(known, name)
+}
+
+func (p *Provider) Enabled(name string) bool {
+	if p == nil {
+		return true
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if enabled, ok := p.overrides[name]; ok {
+		return enabled
+	}
+	return p.defaults[name]
+}
+
+// Override applies a toggle to this instance at once, after it was stored.
+// Other instances pick it up on their next refresh.
+func (p *Provider) Override(name string, enabled bool) error {
+	if !Known(name) {
+		return fmt.Errorf("%w: %q", ErrUnknownFlag, name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.overrides[name] = enabled
+	return nil
+}
+
+// Get returns the state of a known flag.
+func (p *Provider) Get(name string) (Flag, bool) {
+	if !Known(name) {
+		return Flag{}, false
+	}
+	if p == nil {
+		return Flag{Name: name, Enabled: true, Default: true}, true
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	f := Flag{Name: name, Enabled: p.defaults[name], Default: p.defaults[name]}
+	if enabled, ok := p.overrides[name]; ok {
+		f.Enabled, f.Overridden = enabled, true
+	}
+	return f, true
+}
+
+// All returns every known flag in name order.
+func (p *Provider) All() []Flag {
+	all := make([]Flag, 0, len(known))
+	for _, name := range known {
+		f, _ := p.Get(name)
+		all = append(all, f)
+	}
+	return all
+}
+
+// Refresh replaces the overrides with those in the store. Stored flags the
+// server no longer knows are ignored.
+func (p *Provider) Refresh(ctx context.Context) error {
+	stored, err := p.store.ListFeatureFlags(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list feature flags: %w", err)
+	}
+
+	overrides := make(map[string]bool, len(stored))
+	for _, f := range stored {
+		if Known(f.Name) {
+			overrides[f.Name] = f.Enabled
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.overrides = overrides
+	return nil
+}
+
+// Start refreshes the overrides every interval until ctx is done. A failed
+// refresh keeps the previous overrides.
+func (p *Provider) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+					slog.Warn("failed to refresh feature flags",
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Gate applies mw to requests only while the flag is enabled.
+func (p *Provider) Gate(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		gated := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p.Enabled(name) {
+				gated.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
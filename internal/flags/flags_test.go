@@ -0,0 +1,100 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	flags []sqlc.FeatureFlag
+	err   error
+}
+
+func (f *fakeStore) ListFeatureFlags(context.Context) ([]sqlc.FeatureFlag, error) {
+	return f.flags, f.err
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("FEATURE_FLAGS", "presigned_uploads=false, quota_eviction=true")
+
+	p, err := FromEnv(&fakeStore{})
+	require.NoError(t, err)
+	assert.False(t, p.Enabled(PresignedUploads))
+	assert.True(t, p.Enabled(QuotaEviction))
+	assert.True(t, p.Enabled(RequestMirroring))
+}
+
+func TestFromEnv_Invalid(t *testing.T) {
+	for _, v := range []string{"presigned_uploads", "presigned_uploads=maybe", "dedup=false"} {
+		t.Setenv("FEATURE_FLAGS", v)
+		_, err := FromEnv(&fakeStore{})
+		assert.Error(t, err, v)
+	}
+}
+
+func TestProvider_OverridesTakePrecedence(t *testing.T) {
+	store := &fakeStore{flags: []sqlc.FeatureFlag{
+		{Name: RequestMirroring, Enabled: false},
+		{Name: "retired_flag", Enabled: true},
+	}}
+	p, err := New(store, map[string]bool{PresignedUploads: false})
+	require.NoError(t, err)
+	require.NoError(t, p.Refresh(context.Background()))
+
+	assert.False(t, p.Enabled(RequestMirroring))
+	require.NoError(t, p.Override(PresignedUploads, true))
+	assert.True(t, p.Enabled(PresignedUploads))
+	assert.ErrorIs(t, p.Override("retired_flag", true), ErrUnknownFlag)
+
+	assert.Equal(t, []Flag{
+		{Name: PresignedUploads, Enabled: true, Default: false, Overridden: true},
+		{Name: QuotaEviction, Enabled: true, Default: true},
+		{Name: RequestMirroring, Enabled: false, Default: true, Overridden: true},
+	}, p.All())
+}
+
+func TestProvider_FailedRefreshKeepsOverrides(t *testing.T) {
+	store := &fakeStore{flags: []sqlc.FeatureFlag{{Name: QuotaEviction, Enabled: false}}}
+	p, err := New(store, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.Refresh(context.Background()))
+
+	store.err = errors.New("connection refused")
+	assert.Error(t, p.Refresh(context.Background()))
+	assert.False(t, p.Enabled(QuotaEviction))
+}
+
+func TestProvider_Nil(t *testing.T) {
+	var p *Provider
+	assert.True(t, p.Enabled(PresignedUploads))
+	assert.Len(t, p.All(), 3)
+}
+
+func TestProvider_Gate(t *testing.T) {
+	p, err := New(&fakeStore{}, nil)
+	require.NoError(t, err)
+
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Gated", "1")
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := p.Gate(RequestMirroring, mw)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "1", w.Header().Get("X-Gated"))
+
+	require.NoError(t, p.Override(RequestMirroring, false))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("X-Gated"))
+}
@@ -0,0 +1,157 @@
+// Package health probes the server's dependencies in the background, so the
+// health checks orchestrators poll every few seconds are answered from
+// memory instead of reaching the database and storage on every request.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// DefaultTimeout bounds each check of a probe.
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether one dependency is usable.
+type Check func(ctx context.Context) error
+
+// Status is the outcome of a probe.
+type Status struct {
+	Healthy bool
+	// Checks holds "ok" or "unreachable" per dependency.
+	Checks    map[string]string
+	CheckedAt time.Time
+
+	// body is the response of the probe, rendered once
+	body []byte
+}
+
+// Prober runs its checks on an interval and keeps the last outcome.
+type Prober struct {
+	names   []string
+	checks  []Check
+	timeout time.Duration
+
+	// mu keeps live probes from running next to the background ones
+	mu   sync.Mutex
+	last atomic.Pointer[Status]
+}
+
+func New() *Prober {
+	return &Prober{timeout: DefaultTimeout}
+}
+
+// Add registers a check under name. Checks are added before the prober is
+// started or serves requests.
+func (p *Prober) Add(name string, check Check) *Prober {
+	p.names = append(p.names, name)
+	p.checks = append(p.checks, check)
+	return p
+}
+
+// Start probes now and then every interval until ctx is done.
+func (p *Prober) Start(ctx context.Context, interval time.Duration) {
+	p.Probe(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Probe(ctx)
+			}
+		}
+	}()
+}
+
+// Probe runs every check and keeps the outcome as the current status.
+func (p *Prober) Probe(ctx context.Context) *Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := &Status{
+		Healthy:   true,
+		Checks:    make(map[string]string, len(p.checks)),
+		CheckedAt: time.Now(),
+	}
+	for i, check := range p.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		err := check(checkCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("health check failed",
+				slog.String("check", p.names[i]),
+				slog.String("error", err.Error()),
+			)
+			status.Healthy = false
+			status.Checks[p.names[i]] = "unreachable"
+			continue
+		}
+		status.Checks[p.names[i]] = "ok"
+	}
+
+	resp := types.HealthResponse{
+		Status:    "ok",
+		Checks:    status.Checks,
+		CheckedAt: status.CheckedAt.UTC().Format(time.RFC3339),
+	}
+	if !status.Healthy {
+		resp.Status = "unavailable"
+	}
+	// Encoding a struct of strings cannot fail
+	status.body, _ = json.Marshal(utils.APIResponse{Success: status.Healthy, Data: resp})
+	status.body = append(status.body, '\n')
+
+	p.last.Store(status)
+	return status
+}
+
+// Status returns the outcome of the last probe, probing first if there was
+// none yet.
+func (p *Prober) Status(ctx context.Context) *Status {
+	if status := p.last.Load(); status != nil {
+		return status
+	}
+	return p.Probe(ctx)
+}
+
+// StatusFor returns the status to answer r with: a fresh probe if r asks
+// for one with ?live=1, the last one otherwise.
+func (p *Prober) StatusFor(r *http.Request) *Status {
+	if r.URL.RawQuery != "" && r.URL.Query().Get("live") == "1" {
+		return p.Probe(r.Context())
+	}
+	return p.Status(r.Context())
+}
+
+var (
+	jsonContentType = []string{"application/json"}
+	noStore         = []string{"no-store"}
+)
+
+// ServeHTTP answers with the last probe's outcome, 200 or 503, without
+// allocating. HEAD requests get the status only and ?live=1 probes first.
+func (p *Prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := p.StatusFor(r)
+
+	h := w.Header()
+	h["Content-Type"] = jsonContentType
+	h["Cache-Control"] = noStore
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		w.Write(status.body)
+	}
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProber_ServesLastProbe(t *testing.T) {
+	var calls atomic.Int32
+	var fail atomic.Bool
+	p := New().
+		Add("database", func(ctx context.Context) error {
+			calls.Add(1)
+			if fail.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		}).
+		Add("storage", func(ctx context.Context) error { return nil })
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := serve(http.MethodGet, "/healthz")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"ok"`)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Cached until the next probe
+	fail.Store(true)
+	w = serve(http.MethodHead, "/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+	assert.Equal(t, int32(1), calls.Load())
+
+	w = serve(http.MethodGet, "/healthz?live=1")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int32(2), calls.Load())
+
+	status := p.Status(context.Background())
+	assert.False(t, status.Healthy)
+	assert.Equal(t, map[string]string{"database": "unreachable", "storage": "ok"}, status.Checks)
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+func TestProber_ServeHTTPAllocations(t *testing.T) {
+	p := New().Add("database", func(ctx context.Context) error { return nil })
+	p.Probe(context.Background())
+
+	w := &discardWriter{header: http.Header{}}
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		p.ServeHTTP(w, req)
+	})
+	assert.Zero(t, allocs)
+}
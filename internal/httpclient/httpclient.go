@@ -0,0 +1,318 @@
+// Package httpclient builds the clients the server calls other services
+// with: uploader webhooks, the alert and publish hooks and IP reputation
+// lookups. Their requests go through the proxy set by HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY, may only reach the hosts the allow and deny lists permit and
+// share one pool of connections.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxIdleConnsPerHost bounds the idle connections kept open to one
+// destination.
+const DefaultMaxIdleConnsPerHost = 8
+
+const dialTimeout = 10 * time.Second
+
+var (
+	ErrDestinationDenied = errors.New("destination is not allowed")
+	ErrPrivateAddress    = errors.New("address is not public")
+)
+
+// Config limits where outbound requests may go. Hosts are names, matched
+// exactly or, written as "*.example.com", with every subdomain, or IP
+// addresses and CIDR ranges, matched against hosts given as addresses.
+type Config struct {
+	// Allow lists the only hosts requests may go to. Empty allows every
+	// host.
+	Allow []string
+	// Deny lists hosts requests must not go to, even if they are allowed.
+	Deny                []string
+	MaxIdleConnsPerHost int
+	// Proxy picks the proxy of a request. Nil uses HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// Pool makes clients sharing its connections and rules. A nil Pool makes
+// clients with the default rules, which allow every host.
+type Pool struct {
+	allow, deny []hostPattern
+	proxy       func(*http.Request) (*url.URL, error)
+
+	// proxies holds the addresses of the proxies in use, which are dialed
+	// even when private
+	proxies sync.Map
+
+	transport       *http.Transport
+	publicTransport *http.Transport
+}
+
+var defaultPool = sync.OnceValue(func() *Pool {
+	p, _ := New(Config{})
+	return p
+})
+
+func New(cfg Config) (*Pool, error) {
+	allow, err := parsePatterns(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parsePatterns(cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if cfg.Proxy == nil {
+		cfg.Proxy = http.ProxyFromEnvironment
+	}
+
+	p := &Pool{allow: allow, deny: deny, proxy: cfg.Proxy}
+	p.transport = p.newTransport(cfg.MaxIdleConnsPerHost, &net.Dialer{Timeout: dialTimeout})
+	p.publicTransport = p.newTransport(cfg.MaxIdleConnsPerHost, &net.Dialer{
+		Timeout: dialTimeout,
+		// Checked at connect time so DNS cannot point a public name at
+		// an internal address
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !IsPublic(addr) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	})
+	return p, nil
+}
+
+// FromEnv builds a Pool from OUTBOUND_ALLOW_HOSTS and OUTBOUND_DENY_HOSTS,
+// both comma separated, and OUTBOUND_MAX_IDLE_CONNS_PER_HOST.
+func FromEnv() (*Pool, error) {
+	cfg := Config{
+		Allow:               splitList(os.Getenv("OUTBOUND_ALLOW_HOSTS")),
+		Deny:                splitList(os.Getenv("OUTBOUND_DENY_HOSTS")),
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+	}
+	if v := os.Getenv("OUTBOUND_MAX_IDLE_CONNS_PER_HOST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid OUTBOUND_MAX_IDLE_CONNS_PER_HOST %q", v)
+		}
+		cfg.MaxIdleConnsPerHost = n
+	}
+	return New(cfg)
+}
+
+// Client returns a client on the pool's connections. Timeout bounds each
+// request, 0 leaves it to the request's context.
+func (p *Pool) Client(timeout time.Duration) *http.Client {
+	if p == nil {
+		p = defaultPool()
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &guard{pool: p, base: p.transport},
+	}
+}
+
+// PublicClient is Client for URLs chosen by users. It also refuses to
+// connect to loopback, private and link-local addresses, so users cannot
+// make the server call internal services.
+func (p *Pool) PublicClient(timeout time.Duration) *http.Client {
+	if p == nil {
+		p = defaultPool()
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &guard{pool: p, base: p.publicTransport, public: true},
+	}
+}
+
+// Allowed reports whether the allow and deny lists permit requests to host.
+func (p *Pool) Allowed(host string) bool {
+	if p == nil {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range p.deny {
+		if d.match(host) {
+			return false
+		}
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, a := range p.allow {
+		if a.match(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) newTransport(maxIdlePerHost int, dialer *net.Dialer) *http.Transport {
+	direct := &net.Dialer{Timeout: dialTimeout}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdlePerHost
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := p.proxy(req)
+		if u != nil {
+			p.proxies.Store(proxyAddr(u), true)
+		}
+		return u, err
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// Proxies are set by the operator, so they may be internal
+		if _, ok := p.proxies.Load(addr); ok {
+			return direct.DialContext(ctx, network, addr)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return t
+}
+
+// guard checks every request, including the ones of redirects, against
+// the pool's rules before sending it.
+type guard struct {
+	pool   *Pool
+	base   *http.Transport
+	public bool
+}
+
+func (g *guard) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if !g.pool.Allowed(host) {
+		return nil, fmt.Errorf("%w: %s", ErrDestinationDenied, host)
+	}
+
+	if g.public {
+		proxyURL, err := g.pool.proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		// Proxied requests are resolved by the proxy, so the connect
+		// time check never sees their address
+		if proxyURL != nil {
+			if err := checkPublic(req.Context(), host); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.base.RoundTrip(req)
+}
+
+func checkPublic(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !IsPublic(addr) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !IsPublic(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// IsPublic reports whether addr is a global unicast address outside the
+// private ranges.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsUnspecified()
+}
+
+// proxyAddr returns the host:port a proxy URL is dialed at.
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+type hostPattern struct {
+	name   string
+	suffix bool
+	prefix netip.Prefix
+}
+
+func (h hostPattern) match(host string) bool {
+	if h.prefix.IsValid() {
+		addr, err := netip.ParseAddr(host)
+		return err == nil && h.prefix.Contains(addr.Unmap())
+	}
+	if h.suffix {
+		return strings.HasSuffix(host, "."+h.name)
+	}
+	return host == h.name
+}
+
+func parsePatterns(raw []string) ([]hostPattern, error) {
+	patterns := make([]hostPattern, 0, len(raw))
+	for _, r := range raw {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if prefix, err := netip.ParsePrefix(r); err == nil {
+			patterns = append(patterns, hostPattern{prefix: prefix.Masked()})
+			continue
+		}
+		if addr, err := netip.ParseAddr(r); err == nil {
+			patterns = append(patterns, hostPattern{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+			continue
+		}
+
+		name, suffix := strings.CutPrefix(r, "*.")
+		name = strings.TrimSuffix(name, ".")
+		if name == "" || strings.ContainsAny(name, "*/:@ ") {
+			return nil, fmt.Errorf("invalid outbound host %q", r)
+		}
+		patterns = append(patterns, hostPattern{name: name, suffix: suffix})
+	}
+	return patterns, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_Allowed(t *testing.T) {
+	p, err := New(Config{
+		Allow: []string{"hooks.example.com", "*.partner.example", "203.0.113.0/24"},
+		Deny:  []string{"bad.partner.example", "203.0.113.66"},
+	})
+	require.NoError(t, err)
+
+	for _, host := range []string{"hooks.example.com", "HOOKS.example.com.", "eu.partner.example", "203.0.113.10"} {
+		assert.True(t, p.Allowed(host), host)
+	}
+	for _, host := range []string{"example.com", "partner.example", "bad.partner.example", "203.0.113.66", "198.51.100.1"} {
+		assert.False(t, p.Allowed(host), host)
+	}
+
+	var nilPool *Pool
+	assert.True(t, nilPool.Allowed("anything.example"))
+}
+
+func TestPool_DeniesDestinations(t *testing.T) {
+	var called atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer srv.Close()
+
+	p, err := New(Config{Deny: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	_, err = p.Client(0).Get(srv.URL)
+
+	assert.ErrorIs(t, err, ErrDestinationDenied)
+	assert.False(t, called.Load())
+}
+
+func TestPool_PublicClient(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	t.Run("refuses private addresses", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer srv.Close()
+
+		p, err := New(Config{})
+		require.NoError(t, err)
+
+		_, err = p.PublicClient(0).Get(srv.URL)
+		assert.ErrorIs(t, err, ErrPrivateAddress)
+
+		resp, err := p.Client(0).Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	t.Run("dials a private proxy", func(t *testing.T) {
+		p, err := New(Config{Proxy: http.ProxyURL(proxyURL)})
+		require.NoError(t, err)
+
+		resp, err := p.PublicClient(0).Get("http://203.0.113.10/hook")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "http://203.0.113.10/hook", proxied.Load())
+	})
+
+	t.Run("checks proxied destinations", func(t *testing.T) {
+		p, err := New(Config{Proxy: http.ProxyURL(proxyURL)})
+		require.NoError(t, err)
+
+		_, err = p.PublicClient(0).Get("http://10.0.0.1/hook")
+		assert.ErrorIs(t, err, ErrPrivateAddress)
+	})
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OUTBOUND_ALLOW_HOSTS", "hooks.example.com, *.partner.example")
+	t.Setenv("OUTBOUND_DENY_HOSTS", "")
+	t.Setenv("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", "")
+	p, err := FromEnv()
+	require.NoError(t, err)
+	assert.True(t, p.Allowed("eu.partner.example"))
+	assert.False(t, p.Allowed("example.com"))
+
+	t.Setenv("OUTBOUND_ALLOW_HOSTS", "https://hooks.example.com")
+	_, err = FromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("OUTBOUND_ALLOW_HOSTS", "")
+	t.Setenv("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", "0")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
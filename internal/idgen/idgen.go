@@ -0,0 +1,191 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"expvar"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlphanumericCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	NanoIDAlphabet      = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	DefaultLength = 12
+	// MaxLength matches the width of the files.share_id column.
+	MaxLength = 32
+)
+
+var (
+	generated  = expvar.NewMap("share_id_generated")
+	retries    = expvar.NewInt("share_id_retries")
+	collisions = expvar.NewInt("share_id_collisions")
+)
+
+// Generator produces share IDs.
+type Generator interface {
+	Generate() (string, error)
+	Name() string
+}
+
+// RecordCollision counts a generated ID that collided with an existing one.
+func RecordCollision() {
+	collisions.Add(1)
+}
+
+// RecordRetry counts a regeneration attempt after a failure.
+func RecordRetry() {
+	retries.Add(1)
+}
+
+// Alphanumeric generates IDs from [a-zA-Z0-9]. It is the default strategy.
+type Alphanumeric struct {
+	Length int
+}
+
+func (g Alphanumeric) Name() string { return "alphanumeric" }
+
+func (g Alphanumeric) Generate() (string, error) {
+	id, err := randomString(AlphanumericCharset, g.Length)
+	if err != nil {
+		return "", err
+	}
+	generated.Add(g.Name(), 1)
+	return id, nil
+}
+
+// NanoID generates IDs from a custom alphabet.
+type NanoID struct {
+	Alphabet string
+	Length   int
+}
+
+func (g NanoID) Name() string { return "nanoid" }
+
+func (g NanoID) Generate() (string, error) {
+	id, err := randomString(g.Alphabet, g.Length)
+	if err != nil {
+		return "", err
+	}
+	generated.Add(g.Name(), 1)
+	return id, nil
+}
+
+// ULID generates 26 character, lexicographically sortable IDs made of a
+// millisecond timestamp followed by 80 random bits.
+type ULID struct {
+	now func() time.Time
+}
+
+func (g ULID) Name() string { return "ulid" }
+
+func (g ULID) Generate() (string, error) {
+	now := time.Now
+	if g.now != nil {
+		now = g.now
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now().UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+
+	generated.Add(g.Name(), 1)
+	return encodeCrockford(b), nil
+}
+
+// FromEnv builds a Generator from SHARE_ID_STRATEGY, SHARE_ID_LENGTH and
+// SHARE_ID_ALPHABET.
+func FromEnv() (Generator, error) {
+	length := DefaultLength
+	if v := os.Getenv("SHARE_ID_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHARE_ID_LENGTH: %w", err)
+		}
+		length = n
+	}
+
+	return New(os.Getenv("SHARE_ID_STRATEGY"), length, os.Getenv("SHARE_ID_ALPHABET"))
+}
+
+func New(strategy string, length int, alphabet string) (Generator, error) {
+	if length < 8 || length > MaxLength {
+		return nil, fmt.Errorf("share ID length must be between 8 and %d, got %d", MaxLength, length)
+	}
+
+	switch strings.ToLower(strategy) {
+	case "", "alphanumeric":
+		return Alphanumeric{Length: length}, nil
+	case "nanoid":
+		if alphabet == "" {
+			alphabet = NanoIDAlphabet
+		}
+		if err := validateAlphabet(alphabet); err != nil {
+			return nil, err
+		}
+		return NanoID{Alphabet: alphabet, Length: length}, nil
+	case "ulid":
+		return ULID{}, nil
+	default:
+		return nil, fmt.Errorf("unknown share ID strategy %q", strategy)
+	}
+}
+
+// urlSafe lists the characters that need no escaping in a URL path.
+const urlSafe = AlphanumericCharset + "-._~"
+
+// validateAlphabet accepts at least 2 distinct URL-safe ASCII characters, so
+// every generated ID can be used in a link as is.
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return fmt.Errorf("share ID alphabet must have at least 2 characters")
+	}
+	var seen [128]bool
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if c >= 128 || !strings.ContainsRune(urlSafe, rune(c)) {
+			return fmt.Errorf("share ID alphabet may only contain letters, digits and -._~, got %q", alphabet)
+		}
+		if seen[c] {
+			return fmt.Errorf("share ID alphabet repeats %q", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+func randomString(charset string, length int) (string, error) {
+	b := make([]byte, length)
+	limit := big.NewInt(int64(len(charset)))
+
+	for i := range b {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random index: %w", err)
+		}
+		b[i] = charset[n.Int64()]
+	}
+	return string(b), nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func encodeCrockford(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
@@ -0,0 +1,92 @@
+package idgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlphanumeric_Generate(t *testing.T) {
+	g := Alphanumeric{Length: DefaultLength}
+
+	shareID1, err := g.Generate()
+	require.NoError(t, err)
+	assert.Len(t, shareID1, 12)
+
+	shareID2, err := g.Generate()
+	require.NoError(t, err)
+	assert.Len(t, shareID2, 12)
+	assert.NotEqual(t, shareID1, shareID2)
+
+	for _, char := range shareID1 {
+		assert.Contains(t, AlphanumericCharset, string(char))
+	}
+}
+
+func TestNanoID_CustomAlphabet(t *testing.T) {
+	g := NanoID{Alphabet: "abc", Length: 20}
+
+	id, err := g.Generate()
+	require.NoError(t, err)
+	assert.Len(t, id, 20)
+
+	for _, char := range id {
+		assert.Contains(t, "abc", string(char))
+	}
+}
+
+func TestULID_Generate(t *testing.T) {
+	fixed := time.UnixMilli(1700000000000)
+	g := ULID{now: func() time.Time { return fixed }}
+
+	id1, err := g.Generate()
+	require.NoError(t, err)
+	assert.Len(t, id1, 26)
+
+	id2, err := g.Generate()
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+	assert.Equal(t, id1[:10], id2[:10], "timestamp prefix should match")
+
+	later := ULID{now: func() time.Time { return fixed.Add(time.Second) }}
+	id3, err := later.Generate()
+	require.NoError(t, err)
+	assert.Less(t, id1, id3, "ULIDs should sort by time")
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		length   int
+		alphabet string
+		want     string
+		wantErr  bool
+	}{
+		{name: "default", strategy: "", length: 12, want: "alphanumeric"},
+		{name: "nanoid", strategy: "nanoid", length: 21, want: "nanoid"},
+		{name: "ulid", strategy: "ULID", length: 12, want: "ulid"},
+		{name: "unknown strategy", strategy: "uuid", length: 12, wantErr: true},
+		{name: "too short", strategy: "alphanumeric", length: 4, wantErr: true},
+		{name: "too long", strategy: "alphanumeric", length: 64, wantErr: true},
+		{name: "single char alphabet", strategy: "nanoid", length: 12, alphabet: "a", wantErr: true},
+		{name: "custom alphabet", strategy: "nanoid", length: 12, alphabet: "0123456789~", want: "nanoid"},
+		{name: "repeated char", strategy: "nanoid", length: 12, alphabet: "abca", wantErr: true},
+		{name: "not url-safe", strategy: "nanoid", length: 12, alphabet: "ab/c", wantErr: true},
+		{name: "multi-byte char", strategy: "nanoid", length: 12, alphabet: "abcé", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.strategy, tt.length, tt.alphabet)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, g.Name())
+		})
+	}
+}
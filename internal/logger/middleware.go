@@ -74,3 +74,9 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	rw.bytes += n
 	return n, err
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can flush through the logger.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
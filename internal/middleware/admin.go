@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// RequireAdminToken rejects requests whose Bearer token is not the admin API
+// token.
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || given == "" {
+				utils.Error(w, http.StatusUnauthorized, "Authorization required")
+				return
+			}
+
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(given)), []byte(token)) != 1 {
+				logger.FromContext(r.Context()).Warn("invalid admin token",
+					slog.String("path", r.URL.Path),
+				)
+				utils.Error(w, http.StatusForbidden, "Invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
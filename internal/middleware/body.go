@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// LimitJSONBody caps request bodies at limit bytes, so a huge body cannot
+// tie up the server while a handler decodes it. Multipart bodies carry
+// chunks, which the upload handlers limit themselves, and are left alone.
+// Bodies declared larger are refused with 413 up front; handlers see a
+// *http.MaxBytesError once an undeclared body runs past the limit. Zero
+// disables the limit.
+func LimitJSONBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if r.Body != nil && mediaType != "multipart/form-data" {
+				if r.ContentLength > limit {
+					utils.Error(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
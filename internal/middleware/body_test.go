@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitJSONBody(t *testing.T) {
+	decoding := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	big := `{"data":"` + strings.Repeat("a", 64) + `"}`
+
+	tests := []struct {
+		name        string
+		limit       int64
+		contentType string
+		body        io.Reader
+		want        int
+	}{
+		{name: "within limit", limit: 128, contentType: "application/json", body: strings.NewReader(big), want: http.StatusNoContent},
+		{name: "declared too large", limit: 32, contentType: "application/json", body: strings.NewReader(big), want: http.StatusRequestEntityTooLarge},
+		{name: "undeclared too large", limit: 32, contentType: "application/json", body: io.MultiReader(strings.NewReader(big)), want: http.StatusRequestEntityTooLarge},
+		{name: "no content type", limit: 32, body: strings.NewReader(big), want: http.StatusRequestEntityTooLarge},
+		{name: "multipart left alone", limit: 32, contentType: "multipart/form-data; boundary=x", body: strings.NewReader(big), want: http.StatusNoContent},
+		{name: "disabled", limit: 0, contentType: "application/json", body: strings.NewReader(big), want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", tt.body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			LimitJSONBody(tt.limit)(decoding).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// trustedProxies is set once at startup, before the server accepts requests.
+var trustedProxies []netip.Prefix
+
+// SetTrustedProxies lets requests arriving from the given networks name the
+// client they forward for in X-Forwarded-For.
+func SetTrustedProxies(prefixes []netip.Prefix) {
+	trustedProxies = prefixes
+}
+
+func trustedProxy(addr netip.Addr) bool {
+	return slices.ContainsFunc(trustedProxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// ClientAddr returns the address of the client behind r. It is the
+// connection address unless that is a trusted proxy, in which case
+// X-Forwarded-For is read from the right, skipping the trusted proxies
+// that appended to it, so clients cannot choose the address by sending
+// the header themselves.
+func ClientAddr(r *http.Request) (netip.Addr, bool) {
+	addr, ok := connAddr(r)
+	if !ok || !trustedProxy(addr) {
+		return addr, ok
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !trustedProxy(addr) {
+			break
+		}
+	}
+	return addr, true
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAddr(t *testing.T) {
+	previous := trustedProxies
+	SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	t.Cleanup(func() { SetTrustedProxies(previous) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "header from untrusted client", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed entry before proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"192.0.2.9, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chained proxies", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, want: "198.51.100.1"},
+		{name: "garbage from proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"not-an-ip"}, want: "10.0.0.2"},
+		{name: "mapped IPv4", remoteAddr: "[::ffff:203.0.113.7]:5000", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			addr, ok := ClientAddr(req)
+
+			require.True(t, ok)
+			assert.Equal(t, tt.want, addr.String())
+		})
+	}
+}
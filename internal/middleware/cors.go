@@ -2,54 +2,64 @@ package middleware
 
 import (
 	"net/http"
-	"os"
 	"slices"
-	"strings"
+	"strconv"
+
+	appconfig "github.com/ilkin0/gzln/internal/config"
 )
 
-func CORS(next http.Handler) http.Handler {
-	allowedOrigins := getAllowedOrigins()
+const (
+	corsAllowMethods  = "GET, HEAD, POST, PUT, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, X-Requested-With, X-Download-Nonce, If-None-Match, Range, If-Range"
+	corsExposeHeaders = "ETag, Accept-Ranges, Content-Range, X-Gzln-Config-Signature"
+)
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
+// CORS answers preflight requests and adds CORS headers for allowed origins.
+// Disallowed origins get no CORS headers, so browsers block the response,
+// and their preflights are refused outright.
+func CORS(cfg appconfig.CORS) func(http.Handler) http.Handler {
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
 
-		if origin != "" {
-			isAllowed := slices.Contains(allowedOrigins, origin)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
 
-			if isAllowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Access-Control-Allow-Credentials", "true")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-				w.Header().Set("Access-Control-Max-Age", "86400")
-			}
-		}
+			if origin != "" {
+				// Caches must not serve one origin's headers to another
+				w.Header().Add("Vary", "Origin")
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
+				switch {
+				case slices.Contains(cfg.AllowedOrigins, origin),
+					cfg.OriginPattern != nil && cfg.OriginPattern.MatchString(origin):
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				case cfg.AllowAll:
+					// Browsers refuse credentials with a wildcard origin
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				default:
+					if preflight {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
 
-func getAllowedOrigins() []string {
-	defaults := []string{
-		"http://localhost:5173",
-		"http://localhost:4173",
-		"http://localhost:3000",
-	}
+				w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+				if preflight {
+					w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+					w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+			}
 
-	env := os.Getenv("CORS_ALLOWED_ORIGINS")
-	if env == "" {
-		return defaults
-	}
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
 
-	origins := strings.Split(env, ",")
-	for i := range origins {
-		origins[i] = strings.TrimSpace(origins[i])
+			next.ServeHTTP(w, r)
+		})
 	}
-
-	return append(defaults, origins...)
 }
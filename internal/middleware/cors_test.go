@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	appconfig "github.com/ilkin0/gzln/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func corsHandler(cfg appconfig.CORS) http.Handler {
+	return CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestCORS_Origins(t *testing.T) {
+	cfg := appconfig.CORS{
+		AllowedOrigins: []string{"https://gzln.example.com"},
+		OriginPattern:  regexp.MustCompile(`^(?:https://[a-z0-9-]+\.preview\.example\.com)$`),
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		origin      string
+		wantAllowed string
+	}{
+		{name: "exact origin", origin: "https://gzln.example.com", wantAllowed: "https://gzln.example.com"},
+		{name: "pattern origin", origin: "https://pr-42.preview.example.com", wantAllowed: "https://pr-42.preview.example.com"},
+		{name: "disallowed origin", origin: "https://evil.example.net"},
+		{name: "look-alike origin", origin: "https://gzln.example.com.evil.net"},
+		{name: "dev origin not allowed once configured", origin: "http://localhost:5173"},
+		{name: "no origin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/download/abc/metadata", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+
+			corsHandler(cfg).ServeHTTP(w, req)
+
+			// Disallowed origins are still served; the browser blocks them
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantAllowed, w.Header().Get("Access-Control-Allow-Origin"))
+			if tt.wantAllowed == "" {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	cfg := appconfig.CORS{AllowedOrigins: []string{"https://gzln.example.com"}, MaxAge: 10 * time.Minute}
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/files/upload/init", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		corsHandler(cfg).ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://gzln.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Download-Nonce")
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	w = preflight("https://evil.example.net")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORS_AllowAll(t *testing.T) {
+	cfg := appconfig.CORS{AllowAll: true, MaxAge: time.Hour}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/download/abc/metadata", nil)
+	req.Header.Set("Origin", "https://any.example.org")
+	w := httptest.NewRecorder()
+
+	corsHandler(cfg).ServeHTTP(w, req)
+
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// TooManyStreamsCode rejects a download while its share already has the
+// maximum number of downloads in progress.
+const TooManyStreamsCode = "too_many_streams"
+
+// downloadStreams counts the chunk downloads and streams in progress per
+// share on this instance. Instances do not share counts, so behind a load
+// balancer a share gets the limit on each of them.
+var downloadStreams = newStreamCounter(0)
+
+// SetDownloadStreamLimit caps the downloads of one share in progress at
+// once. Zero removes the cap. It applies to limiters created afterwards.
+func SetDownloadStreamLimit(limit int) {
+	downloadStreams = newStreamCounter(limit)
+}
+
+type streamCounter struct {
+	limit int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func newStreamCounter(limit int) *streamCounter {
+	return &streamCounter{limit: limit, active: make(map[string]int)}
+}
+
+func (c *streamCounter) acquire(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[key] >= c.limit {
+		return false
+	}
+	c.active[key]++
+	return true
+}
+
+func (c *streamCounter) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[key]--; c.active[key] <= 0 {
+		delete(c.active, key)
+	}
+}
+
+// DownloadStreamLimiter turns away a download with 429 while its share has
+// the limit set by SetDownloadStreamLimit in progress, so parallel
+// downloaders of one share cannot starve the others. It goes after the
+// share link and alias resolvers, so every name of a share counts as one.
+func DownloadStreamLimiter() func(http.Handler) http.Handler {
+	counter := downloadStreams
+	return func(next http.Handler) http.Handler {
+		if counter.limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			shareID := chi.URLParam(r, "shareID")
+			if !counter.acquire(shareID) {
+				rejections.Add("download_streams", 1)
+				tracker.record("download_streams", r)
+				logger.FromContext(r.Context()).Warn("too many downloads of share",
+					slog.String("share_id", shareID),
+					slog.Int("limit", counter.limit),
+				)
+
+				w.Header().Set("Retry-After", "1")
+				utils.ErrorWithCode(w, http.StatusTooManyRequests, TooManyStreamsCode, "Too many downloads of this share in progress. Please try again shortly.")
+				return
+			}
+			defer counter.release(shareID)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadStreamLimiter(t *testing.T) {
+	SetDownloadStreamLimit(1)
+	t.Cleanup(func() { SetDownloadStreamLimit(0) })
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	r := chi.NewRouter()
+	r.With(DownloadStreamLimiter()).Get("/{shareID}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "shareID") == "busy" {
+			close(entered)
+			<-unblock
+		}
+	})
+	get := func(shareID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+shareID, nil))
+		return w
+	}
+
+	done := make(chan int)
+	go func() { done <- get("busy").Code }()
+	<-entered
+
+	w := get("busy")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), TooManyStreamsCode)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, get("other").Code)
+
+	close(unblock)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Empty(t, downloadStreams.active)
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// PlainTextErrors renders the errors of requests that prefer text/plain as a
+// single "code: message" line, so script clients like curl can show them
+// as is. Successful responses stay JSON.
+func PlainTextErrors() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if utils.PrefersText(r) {
+				w = utils.WithTextErrors(w)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
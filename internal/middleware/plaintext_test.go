@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/ilkin0/gzln/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainTextErrors(t *testing.T) {
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.ErrorWithCode(w, http.StatusConflict, "chunk_already_uploaded", "Chunk already uploaded")
+	})
+
+	tests := []struct {
+		name   string
+		accept string
+		text   bool
+	}{
+		{name: "no accept header", accept: ""},
+		{name: "any type", accept: "*/*"},
+		{name: "plain text", accept: "text/plain", text: true},
+		{name: "any text", accept: "text/*", text: true},
+		{name: "text preferred", accept: "application/json;q=0.5, text/plain", text: true},
+		{name: "json preferred", accept: "text/plain;q=0.5, application/json"},
+		{name: "equal preference", accept: "text/plain, application/json"},
+		{name: "browser", accept: "text/html,application/xhtml+xml,*/*;q=0.8"},
+		{name: "text refused", accept: "text/plain;q=0, */*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			PlainTextErrors()(failing).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+			if tt.text {
+				assert.Equal(t, utils.TextContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "chunk_already_uploaded: Chunk already uploaded\n", w.Body.String())
+			} else {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.JSONEq(t, `{"success":false,"code":"chunk_already_uploaded","message":"Chunk already uploaded"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPlainTextErrors_ThroughWrappedWriter(t *testing.T) {
+	// Writers wrapped by later middleware still render text errors
+	wrap := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor), r)
+		})
+	}
+	h := PlainTextErrors()(wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Error(w, http.StatusNotFound, "File not found")
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "File not found\n", w.Body.String())
+}
+
+func TestPlainTextErrors_SuccessStaysJSON(t *testing.T) {
+	h := PlainTextErrors()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Ok(w, map[string]string{"share_id": "abc"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"success":true,"data":{"share_id":"abc"}}`, w.Body.String())
+}
@@ -8,29 +8,46 @@ import (
 	"time"
 
 	"github.com/go-chi/httprate"
+	appconfig "github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/utils"
 )
 
 type RateLimitConfig struct {
+	UploadAdviceLimit     int
 	UploadInitLimit       int
 	ChunkUploadLimit      int
+	ChunkStatusLimit      int
 	UploadFinalizeLimit   int
 	MetadataLimit         int
+	ManifestLimit         int
 	ChunkDownloadLimit    int
 	DownloadCompleteLimit int
+	ManageSessionLimit    int
 	TimeWindow            time.Duration
+	ReportInterval        time.Duration
 }
 
+// LoadRateLimitConfig reads limits from the environment, defaulting to the
+// values of the PROFILE preset.
 func LoadRateLimitConfig() RateLimitConfig {
+	defaults := appconfig.ProfileFromEnv().RateLimits
+
 	return RateLimitConfig{
-		UploadInitLimit:       getEnvInt("RATE_LIMIT_UPLOAD_INIT", 10),
-		ChunkUploadLimit:      getEnvInt("RATE_LIMIT_CHUNK_UPLOAD", 60),
-		UploadFinalizeLimit:   getEnvInt("RATE_LIMIT_UPLOAD_FINALIZE", 20),
-		MetadataLimit:         getEnvInt("RATE_LIMIT_METADATA", 30),
-		ChunkDownloadLimit:    getEnvInt("RATE_LIMIT_CHUNK_DOWNLOAD", 110),
-		DownloadCompleteLimit: getEnvInt("RATE_LIMIT_DOWNLOAD_COMPLETE", 20),
+		UploadAdviceLimit:     getEnvInt("RATE_LIMIT_UPLOAD_ADVICE", defaults.UploadAdvice),
+		UploadInitLimit:       getEnvInt("RATE_LIMIT_UPLOAD_INIT", defaults.UploadInit),
+		ChunkUploadLimit:      getEnvInt("RATE_LIMIT_CHUNK_UPLOAD", defaults.ChunkUpload),
+		ChunkStatusLimit:      getEnvInt("RATE_LIMIT_CHUNK_STATUS", defaults.ChunkStatus),
+		UploadFinalizeLimit:   getEnvInt("RATE_LIMIT_UPLOAD_FINALIZE", defaults.UploadFinalize),
+		MetadataLimit:         getEnvInt("RATE_LIMIT_METADATA", defaults.Metadata),
+		ManifestLimit:         getEnvInt("RATE_LIMIT_MANIFEST", defaults.Manifest),
+		ChunkDownloadLimit:    getEnvInt("RATE_LIMIT_CHUNK_DOWNLOAD", defaults.ChunkDownload),
+		DownloadCompleteLimit: getEnvInt("RATE_LIMIT_DOWNLOAD_COMPLETE", defaults.DownloadComplete),
+		ManageSessionLimit:    getEnvInt("RATE_LIMIT_MANAGE_SESSION", defaults.ManageSession),
 		TimeWindow: time.
 			Duration(getEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second,
+		ReportInterval: time.
+			Duration(getEnvInt("RATE_LIMIT_REPORT_INTERVAL_SECONDS", 300)) * time.Second,
 	}
 }
 
@@ -49,50 +66,154 @@ func ReloadConfig() {
 	config = LoadRateLimitConfig()
 }
 
+func UploadAdviceLimiter() func(http.Handler) http.Handler {
+	return createLimiter("upload_advice", config.UploadAdviceLimit)
+}
+
 func UploadInitLimiter() func(http.Handler) http.Handler {
-	return createLimiter(config.UploadInitLimit)
+	return createLimiter("upload_init", config.UploadInitLimit)
 }
 
 func ChunkUploadLimiter() func(http.Handler) http.Handler {
-	return createLimiter(config.ChunkUploadLimit)
+	return createLimiter("chunk_upload", config.ChunkUploadLimit)
+}
+
+func ChunkStatusLimiter() func(http.Handler) http.Handler {
+	return createLimiter("chunk_status", config.ChunkStatusLimit)
 }
 
 func UploadFinalizeLimiter() func(http.Handler) http.Handler {
-	return createLimiter(config.UploadFinalizeLimit)
+	return createLimiter("upload_finalize", config.UploadFinalizeLimit)
 }
 
-func MetadataLimiter() func(http.Handler) http.Handler { return createLimiter(config.MetadataLimit) }
+func MetadataLimiter() func(http.Handler) http.Handler {
+	return createLimiter("metadata", config.MetadataLimit)
+}
+
+func ManifestLimiter() func(http.Handler) http.Handler {
+	return createLimiter("manifest", config.ManifestLimit)
+}
 
 func ChunkDownloadLimiter() func(http.Handler) http.Handler {
-	return createLimiter(config.ChunkDownloadLimit)
+	return createLimiter("chunk_download", config.ChunkDownloadLimit)
 }
 
 func DownloadCompleteLimiter() func(http.Handler) http.Handler {
-	return createLimiter(config.DownloadCompleteLimit)
+	return createLimiter("download_complete", config.DownloadCompleteLimit)
+}
+
+func ManageSessionLimiter() func(http.Handler) http.Handler {
+	return createLimiter("manage_session", config.ManageSessionLimit)
+}
+
+// ManageExportLimiter shares the management session limit, since both accept
+// deletion tokens.
+func ManageExportLimiter() func(http.Handler) http.Handler {
+	return createLimiter("manage_export", config.ManageSessionLimit)
+}
+
+// ShareStatsLimiter also uses the management session limit, as stats are
+// authorized by deletion token.
+func ShareStatsLimiter() func(http.Handler) http.Handler {
+	return createLimiter("share_stats", config.ManageSessionLimit)
+}
+
+// ShareRevokeLimiter uses the management session limit, as revocation is
+// authorized by deletion token.
+func ShareRevokeLimiter() func(http.Handler) http.Handler {
+	return createLimiter("share_revoke", config.ManageSessionLimit)
+}
+
+// ShareLinksLimiter uses the management session limit too, as links are
+// managed with the deletion token.
+func ShareLinksLimiter() func(http.Handler) http.Handler {
+	return createLimiter("share_links", config.ManageSessionLimit)
+}
+
+// UploadSlotLimiter shares the upload init limit, as every slot is redeemed
+// by an upload init.
+func UploadSlotLimiter() func(http.Handler) http.Handler {
+	return createLimiter("upload_slot", config.UploadInitLimit)
 }
 
-func createLimiter(limit int) func(http.Handler) http.Handler {
-	return httprate.Limit(
+// StreamLimiter shares the manifest limit, as a stream takes the place of a
+// manifest and its chunk requests.
+func StreamLimiter() func(http.Handler) http.Handler {
+	return createLimiter("stream", config.ManifestLimit)
+}
+
+// BundleCreateLimiter shares the upload init limit, as a bundle is created
+// ahead of the uploads that fill it.
+func BundleCreateLimiter() func(http.Handler) http.Handler {
+	return createLimiter("bundle_create", config.UploadInitLimit)
+}
+
+// BundleManifestLimiter shares the metadata limit, as the bundle manifest is
+// the first request of a bundle download.
+func BundleManifestLimiter() func(http.Handler) http.Handler {
+	return createLimiter("bundle_manifest", config.MetadataLimit)
+}
+
+// BundleArchiveLimiter shares the manifest limit, as a bundle archive takes
+// the place of a stream per file.
+func BundleArchiveLimiter() func(http.Handler) http.Handler {
+	return createLimiter("bundle_archive", config.ManifestLimit)
+}
+
+// PasteCreateLimiter shares the upload init limit, as a paste takes the
+// place of an upload.
+func PasteCreateLimiter() func(http.Handler) http.Handler {
+	return createLimiter("paste_create", config.UploadInitLimit)
+}
+
+// PasteReadLimiter shares the metadata limit, as reading a paste takes the
+// place of a whole download.
+func PasteReadLimiter() func(http.Handler) http.Handler {
+	return createLimiter("paste_read", config.MetadataLimit)
+}
+
+// ShareEventsLimiter shares the metadata limit, as a recipient watches a
+// share while on its download page.
+func ShareEventsLimiter() func(http.Handler) http.Handler {
+	return createLimiter("share_events", config.MetadataLimit)
+}
+
+// AdminLimiter shares the manage session limit, which guards the other
+// token-authenticated endpoints against guessing.
+func AdminLimiter() func(http.Handler) http.Handler {
+	return createLimiter("admin", config.ManageSessionLimit)
+}
+
+// SupportPreviewLimiter shares the manage session limit, as preview tokens
+// are bearer tokens too.
+func SupportPreviewLimiter() func(http.Handler) http.Handler {
+	return createLimiter("support_preview", config.ManageSessionLimit)
+}
+
+func createLimiter(name string, limit int) func(http.Handler) http.Handler {
+	return withExemptions(name, limit, httprate.Limit(
 		limit,
 		config.TimeWindow,
 		httprate.WithKeyFuncs(httprate.KeyByIP),
-		httprate.WithLimitHandler(rateLimitExceededHandler(config.TimeWindow)),
-	)
+		httprate.WithLimitHandler(rateLimitExceededHandler(name, config.TimeWindow)),
+	))
 }
 
-func rateLimitExceededHandler(retryAfter time.Duration) http.HandlerFunc {
+func rateLimitExceededHandler(name string, retryAfter time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rejections.Add(name, 1)
+		tracker.record(name, r)
+
 		log := logger.FromContext(r.Context())
 		log.Warn("rate limit exceeded",
+			slog.String("limiter", name),
 			slog.String("ip", r.RemoteAddr),
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 			slog.String("user_agent", r.UserAgent()),
 		)
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusTooManyRequests)
+		utils.Error(w, http.StatusTooManyRequests, "Rate limit exceeded. Please try again later.")
 		w.Header().Set("Retry-After", retryAfter.String())
-		w.Write([]byte(`{"success":false,"message":"Rate limit exceeded. Please try again later."}`))
 	}
 }
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/go-chi/httprate"
+	appconfig "github.com/ilkin0/gzln/internal/config"
+)
+
+// exemptions is set once at startup, before the server accepts requests.
+var exemptions = appconfig.RateLimitExemptions{RaiseFactor: appconfig.DefaultRateLimitRaiseFactor}
+
+// exempted counts requests let through by an exemption, by limiter. Raised
+// requests are counted under the limiter name with a "_raised" suffix.
+var exempted = expvar.NewMap("rate_limit_exempted")
+
+// exemptTracker records exempted requests for the periodic report, so
+// operators can see how much traffic skips the limits and from where.
+var exemptTracker = newRejectionTracker()
+
+// SetRateLimitExemptions lets the given networks bypass or exceed every rate
+// limit.
+func SetRateLimitExemptions(e appconfig.RateLimitExemptions) {
+	exemptions = e
+}
+
+type exemption int
+
+const (
+	notExempt exemption = iota
+	exemptBypass
+	exemptRaised
+)
+
+// exemptionFor matches the connection address, which unlike forwarding
+// headers cannot be chosen by the client.
+func exemptionFor(r *http.Request) exemption {
+	if len(exemptions.Bypass) == 0 && len(exemptions.Raised) == 0 {
+		return notExempt
+	}
+
+	addr, ok := connAddr(r)
+	if !ok {
+		return notExempt
+	}
+
+	contains := func(p netip.Prefix) bool { return p.Contains(addr) }
+	switch {
+	case slices.ContainsFunc(exemptions.Bypass, contains):
+		return exemptBypass
+	case slices.ContainsFunc(exemptions.Raised, contains):
+		return exemptRaised
+	default:
+		return notExempt
+	}
+}
+
+// connAddr returns the address of the connection r arrived on.
+func connAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// withExemptions wraps a limiter so bypassed clients skip it, raised
+// clients are held to limit times the raise factor and clients throttled for
+// their IP reputation to limit divided by the throttle factor.
+func withExemptions(name string, limit int, limiter func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := limiter(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch exemptionFor(r) {
+			case exemptBypass:
+				exempted.Add(name, 1)
+				exemptTracker.record(name, r)
+				next.ServeHTTP(w, r)
+				return
+			case exemptRaised:
+				exempted.Add(name+"_raised", 1)
+				exemptTracker.record(name, r)
+				r = r.WithContext(httprate.WithRequestLimit(r.Context(), limit*exemptions.RaiseFactor))
+			default:
+				if factor, ok := r.Context().Value(throttledKey{}).(int); ok {
+					r = r.WithContext(httprate.WithRequestLimit(r.Context(), max(1, limit/factor)))
+				}
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
+func logExemptionSummary(stats RateLimitStats, interval time.Duration) {
+	if len(stats.ByLimiter) == 0 {
+		return
+	}
+
+	var total int64
+	for _, count := range stats.ByLimiter {
+		total += count
+	}
+
+	slog.Info("rate limit exemption summary",
+		slog.Duration("interval", interval),
+		slog.Int64("exempted_requests", total),
+		slog.Any("by_limiter", stats.ByLimiter),
+		slog.Any("top_ips", stats.TopIPs),
+	)
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	appconfig "github.com/ilkin0/gzln/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func setExemptions(t *testing.T, e appconfig.RateLimitExemptions) {
+	t.Helper()
+	previous := exemptions
+	SetRateLimitExemptions(e)
+	t.Cleanup(func() { SetRateLimitExemptions(previous) })
+}
+
+// allowedRequests sends n requests from addr and counts those let through.
+func allowedRequests(h http.Handler, addr string, n int) int {
+	allowed := 0
+	for range n {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestWithExemptions(t *testing.T) {
+	setExemptions(t, appconfig.RateLimitExemptions{
+		Bypass:      []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Raised:      []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")},
+		RaiseFactor: 3,
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		addr    string
+		allowed int
+	}{
+		{name: "normal client", addr: "203.0.113.5:4000", allowed: 2},
+		{name: "bypassed client", addr: "10.1.2.3:4000", allowed: 10},
+		{name: "raised client", addr: "192.168.4.5:4000", allowed: 6},
+		{name: "IPv4-mapped bypassed client", addr: "[::ffff:10.1.2.3]:4000", allowed: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createLimiter("exempt_test", 2)(ok)
+
+			assert.Equal(t, tt.allowed, allowedRequests(h, tt.addr, 10))
+		})
+	}
+}
+
+func TestWithExemptions_RecordsExemptedTraffic(t *testing.T) {
+	setExemptions(t, appconfig.RateLimitExemptions{
+		Bypass:      []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		RaiseFactor: appconfig.DefaultRateLimitRaiseFactor,
+	})
+	before := exemptTracker.snapshot(5).ByLimiter["exempt_record_test"]
+	h := createLimiter("exempt_record_test", 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	allowedRequests(h, "10.0.0.9:4000", 3)
+
+	assert.Equal(t, before+3, exemptTracker.snapshot(5).ByLimiter["exempt_record_test"])
+	assert.Equal(t, "3", exempted.Get("exempt_record_test").String())
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reportTopIPs is the number of most limited IPs included in summaries.
+const reportTopIPs = 5
+
+var rejections = expvar.NewMap("rate_limit_rejections")
+
+// LimitedIP is the number of rejected requests seen from one client.
+type LimitedIP struct {
+	IP    string `json:"ip"`
+	Count int64  `json:"count"`
+}
+
+// RateLimitStats summarizes rejected requests since the last report.
+type RateLimitStats struct {
+	ByLimiter map[string]int64 `json:"by_limiter"`
+	TopIPs    []LimitedIP      `json:"top_ips"`
+}
+
+type rejectionTracker struct {
+	mu        sync.Mutex
+	byLimiter map[string]int64
+	byIP      map[string]int64
+}
+
+var tracker = newRejectionTracker()
+
+func newRejectionTracker() *rejectionTracker {
+	return &rejectionTracker{
+		byLimiter: make(map[string]int64),
+		byIP:      make(map[string]int64),
+	}
+}
+
+// record counts a request against limiter. Clients are only counted while
+// reports are on, since only a report empties the per-IP counts again.
+func (t *rejectionTracker) record(limiter string, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byLimiter[limiter]++
+	if config.ReportInterval > 0 {
+		t.byIP[ip]++
+	}
+}
+
+func (t *rejectionTracker) snapshot(topN int) RateLimitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return summarize(t.byLimiter, t.byIP, topN)
+}
+
+// drain returns what was recorded so far and starts counting afresh, in one
+// step so no request recorded in between is lost.
+func (t *rejectionTracker) drain(topN int) RateLimitStats {
+	t.mu.Lock()
+	byLimiter, byIP := t.byLimiter, t.byIP
+	t.byLimiter = make(map[string]int64)
+	t.byIP = make(map[string]int64)
+	t.mu.Unlock()
+
+	return summarize(byLimiter, byIP, topN)
+}
+
+func summarize(byLimiter, byIP map[string]int64, topN int) RateLimitStats {
+	stats := RateLimitStats{
+		ByLimiter: make(map[string]int64, len(byLimiter)),
+		TopIPs:    make([]LimitedIP, 0, len(byIP)),
+	}
+	for name, count := range byLimiter {
+		stats.ByLimiter[name] = count
+	}
+	for ip, count := range byIP {
+		stats.TopIPs = append(stats.TopIPs, LimitedIP{IP: ip, Count: count})
+	}
+
+	sort.Slice(stats.TopIPs, func(i, j int) bool {
+		if stats.TopIPs[i].Count != stats.TopIPs[j].Count {
+			return stats.TopIPs[i].Count > stats.TopIPs[j].Count
+		}
+		return stats.TopIPs[i].IP < stats.TopIPs[j].IP
+	})
+	if len(stats.TopIPs) > topN {
+		stats.TopIPs = stats.TopIPs[:topN]
+	}
+	return stats
+}
+
+// RateLimitSnapshot returns rejections recorded since the last report,
+// limited to the topN most limited IPs.
+func RateLimitSnapshot(topN int) RateLimitStats {
+	return tracker.snapshot(topN)
+}
+
+// ExemptionSnapshot returns requests let past a limit by an exemption since
+// the last report, limited to the topN most exempted IPs.
+func ExemptionSnapshot(topN int) RateLimitStats {
+	return exemptTracker.snapshot(topN)
+}
+
+// StartRateLimitReporter logs summaries of rejected and exempted requests
+// every RATE_LIMIT_REPORT_INTERVAL_SECONDS until ctx is cancelled. Nothing is
+// logged for quiet intervals, and a non-positive interval disables reporting.
+func StartRateLimitReporter(ctx context.Context) {
+	interval := config.ReportInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				logRateLimitSummary(tracker.drain(reportTopIPs), interval)
+				logExemptionSummary(exemptTracker.drain(reportTopIPs), interval)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func logRateLimitSummary(stats RateLimitStats, interval time.Duration) {
+	if len(stats.ByLimiter) == 0 {
+		return
+	}
+
+	var busiest string
+	for name, count := range stats.ByLimiter {
+		if busiest == "" || count > stats.ByLimiter[busiest] ||
+			(count == stats.ByLimiter[busiest] && name < busiest) {
+			busiest = name
+		}
+	}
+
+	slog.Warn("rate limit summary",
+		slog.Duration("interval", interval),
+		slog.String("top_limiter", busiest),
+		slog.Int64("top_limiter_rejections", stats.ByLimiter[busiest]),
+		slog.Any("by_limiter", stats.ByLimiter),
+		slog.Any("top_ips", stats.TopIPs),
+	)
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectionTracker_Snapshot(t *testing.T) {
+	tr := newRejectionTracker()
+
+	for _, addr := range []string{"10.0.0.1:1000", "10.0.0.1:1001", "10.0.0.2:1000", "10.0.0.3:1000", "10.0.0.1:1002"} {
+		req := httptest.NewRequest(http.MethodPost, "/upload/init", nil)
+		req.RemoteAddr = addr
+		tr.record("upload_init", req)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/metadata", nil)
+	req.RemoteAddr = "10.0.0.2:1000"
+	tr.record("metadata", req)
+
+	stats := tr.snapshot(2)
+
+	assert.Equal(t, map[string]int64{"upload_init": 5, "metadata": 1}, stats.ByLimiter)
+	assert.Equal(t, []LimitedIP{
+		{IP: "10.0.0.1", Count: 3},
+		{IP: "10.0.0.2", Count: 2},
+	}, stats.TopIPs)
+}
+
+func TestRejectionTracker_Drain(t *testing.T) {
+	tr := newRejectionTracker()
+	tr.record("chunk_upload", httptest.NewRequest(http.MethodPost, "/", nil))
+
+	drained := tr.drain(5)
+
+	assert.Equal(t, map[string]int64{"chunk_upload": 1}, drained.ByLimiter)
+	assert.Len(t, drained.TopIPs, 1)
+	stats := tr.snapshot(5)
+	assert.Empty(t, stats.ByLimiter)
+	assert.Empty(t, stats.TopIPs)
+}
+
+func TestRejectionTracker_NoIPsWithoutReports(t *testing.T) {
+	previous := config.ReportInterval
+	config.ReportInterval = 0
+	t.Cleanup(func() { config.ReportInterval = previous })
+
+	tr := newRejectionTracker()
+	tr.record("chunk_upload", httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.Equal(t, map[string]int64{"chunk_upload": 1}, tr.snapshot(5).ByLimiter)
+	assert.Empty(t, tr.byIP, "nothing would ever empty per-IP counts")
+}
+
+func TestRateLimitExceededHandler_RecordsRejection(t *testing.T) {
+	before := tracker.snapshot(5).ByLimiter["test_limiter"]
+
+	w := httptest.NewRecorder()
+	rateLimitExceededHandler("test_limiter", config.TimeWindow)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, before+1, tracker.snapshot(5).ByLimiter["test_limiter"])
+	assert.Equal(t, "1", rejections.Get("test_limiter").String())
+}
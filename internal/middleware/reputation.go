@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ilkin0/gzln/internal/logger"
+	"github.com/ilkin0/gzln/internal/reputation"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// Codes of requests turned away for the reputation of their IP. Challenged
+// requests share the code of challenged upload inits.
+const (
+	ReputationDeniedCode    = "ip_reputation_denied"
+	ReputationChallengeCode = "challenge_required"
+)
+
+// reputationChecker is set once at startup, before the server accepts
+// requests. A nil checker allows every request.
+var reputationChecker *reputation.Checker
+
+// SetReputation screens the requests of routes using Reputation with c.
+func SetReputation(c *reputation.Checker) {
+	reputationChecker = c
+}
+
+// throttledKey marks requests whose rate limits are divided by the throttle
+// factor it holds.
+type throttledKey struct{}
+
+// Reputation looks up the IP of each request and turns away denied and
+// challenged ones with 403. Throttled requests go on to the rate limiters
+// after it, which hold them to a fraction of their limit. Clients in the
+// rate limit exemptions are trusted and not looked up.
+func Reputation() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reputationChecker == nil || exemptionFor(r) != notExempt {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addr, ok := connAddr(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			action := reputationChecker.Check(r.Context(), addr)
+			if action == reputation.Deny || action == reputation.Challenge {
+				logger.FromContext(r.Context()).Warn("request refused for IP reputation",
+					slog.String("ip", addr.String()),
+					slog.String("action", action.String()),
+					slog.String("path", r.URL.Path),
+				)
+			}
+
+			switch action {
+			case reputation.Deny:
+				utils.ErrorWithCode(w, http.StatusForbidden, ReputationDeniedCode, "Requests from this network are not allowed")
+				return
+			case reputation.Challenge:
+				utils.ErrorWithCode(w, http.StatusForbidden, ReputationChallengeCode, "Request requires additional verification")
+				return
+			case reputation.Throttle:
+				r = r.WithContext(context.WithValue(r.Context(), throttledKey{}, reputationChecker.ThrottleFactor()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
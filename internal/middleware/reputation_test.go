@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	appconfig "github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/reputation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedScores map[string]int
+
+func (f fixedScores) Score(_ context.Context, ip netip.Addr) (int, error) {
+	return f[ip.String()], nil
+}
+
+func setReputation(t *testing.T, c *reputation.Checker) {
+	t.Helper()
+	previous := reputationChecker
+	SetReputation(c)
+	t.Cleanup(func() { SetReputation(previous) })
+}
+
+func TestReputation(t *testing.T) {
+	cfg := reputation.DefaultConfig()
+	cfg.ThrottleFactor = 5
+	setReputation(t, reputation.NewChecker(fixedScores{
+		"203.0.113.2": 30,
+		"203.0.113.3": 60,
+		"203.0.113.4": 90,
+		"10.1.2.3":    90,
+	}, cfg))
+	setExemptions(t, appconfig.RateLimitExemptions{
+		Bypass:      []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+		RaiseFactor: appconfig.DefaultRateLimitRaiseFactor,
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		addr    string
+		allowed int
+		code    string
+	}{
+		{name: "clean client", addr: "203.0.113.1:4000", allowed: 10},
+		{name: "throttled client", addr: "203.0.113.2:4000", allowed: 2},
+		{name: "challenged client", addr: "203.0.113.3:4000", code: ReputationChallengeCode},
+		{name: "denied client", addr: "203.0.113.4:4000", code: ReputationDeniedCode},
+		{name: "private address is not looked up", addr: "10.1.2.3:4000", allowed: 10},
+		{name: "exempt client is not looked up", addr: "198.51.100.7:4000", allowed: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Reputation()(createLimiter("reputation_test_"+tt.name, 10)(ok))
+
+			assert.Equal(t, tt.allowed, allowedRequests(h, tt.addr, 20))
+			if tt.code == "" {
+				return
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.addr
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			var body struct {
+				Code string `json:"code"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Code)
+		})
+	}
+}
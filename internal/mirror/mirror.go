@@ -0,0 +1,219 @@
+// Package mirror replays a sample of read-only requests against a canary
+// deployment and logs when its status codes differ from ours, to validate an
+// upgrade or storage migration on live traffic before switching over.
+package mirror
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	sendTimeout = 5 * time.Second
+
+	// maxInFlight bounds concurrent mirrored requests. Samples beyond it are
+	// dropped so a slow canary never builds up goroutines.
+	maxInFlight = 32
+
+	// DefaultPercent is the share of eligible requests mirrored when
+	// MIRROR_PERCENT is unset.
+	DefaultPercent = 10
+
+	// Header marks mirrored requests so the canary can tell them apart.
+	Header = "X-Gzln-Mirror"
+)
+
+var results = expvar.NewMap("mirror_results")
+
+// route is a read-only route eligible for mirroring. Chunks are mirrored as
+// HEAD so the canary does not stream chunk data back.
+type route struct {
+	pattern string
+	method  string
+}
+
+var routes = []route{
+	{pattern: "/api/v1/download/*/metadata", method: http.MethodGet},
+	{pattern: "/api/v1/download/*/manifest", method: http.MethodGet},
+	{pattern: "/api/v1/download/*/chunks/*", method: http.MethodHead},
+}
+
+// Mirror is a middleware that replays sampled requests against a canary
+// base URL. The primary response is never delayed or changed.
+type Mirror struct {
+	baseURL  *url.URL
+	percent  int
+	client   *http.Client
+	inFlight chan struct{}
+	sample   func() bool
+}
+
+func New(rawBaseURL string, percent int) (*Mirror, error) {
+	u, err := url.Parse(rawBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("mirror base URL must be http or https, got %q", rawBaseURL)
+	}
+	if percent < 1 || percent > 100 {
+		return nil, fmt.Errorf("mirror percent must be between 1 and 100, got %d", percent)
+	}
+
+	m := &Mirror{
+		baseURL:  u,
+		percent:  percent,
+		client:   &http.Client{Timeout: sendTimeout},
+		inFlight: make(chan struct{}, maxInFlight),
+	}
+	m.sample = func() bool { return rand.IntN(100) < m.percent }
+	return m, nil
+}
+
+// FromEnv builds a Mirror from MIRROR_BASE_URL and MIRROR_PERCENT. It
+// returns nil when no canary is configured.
+func FromEnv() (*Mirror, error) {
+	rawURL := os.Getenv("MIRROR_BASE_URL")
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	percent := DefaultPercent
+	if v := os.Getenv("MIRROR_PERCENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("MIRROR_PERCENT must be an integer, got %q", v)
+		}
+		percent = n
+	}
+	return New(rawURL, percent)
+}
+
+// Percent returns the share of eligible requests that are mirrored.
+func (m *Mirror) Percent() int {
+	return m.percent
+}
+
+// Middleware serves the request and then, for a sample of GET requests to
+// read-only routes, replays it against the canary in the background.
+func (m *Mirror) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := mirrorMethod(r)
+		if !ok || !m.sample() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// Handlers that only write a body answer with an implicit 200
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Our own rate limiter answered, not the route being validated
+		if status == http.StatusTooManyRequests {
+			return
+		}
+
+		select {
+		case m.inFlight <- struct{}{}:
+		default:
+			results.Add("dropped", 1)
+			return
+		}
+
+		req := m.newRequest(r, method)
+		go func() {
+			defer func() { <-m.inFlight }()
+			m.compare(req, status)
+		}()
+	})
+}
+
+func mirrorMethod(r *http.Request) (string, bool) {
+	if r.Method != http.MethodGet {
+		return "", false
+	}
+	for _, rt := range routes {
+		if ok, _ := path.Match(rt.pattern, r.URL.Path); ok {
+			return rt.method, true
+		}
+	}
+	return "", false
+}
+
+// newRequest copies what the canary needs to answer like we did. It must run
+// before the middleware returns, after which r may be reused.
+func (m *Mirror) newRequest(r *http.Request, method string) *http.Request {
+	target := m.baseURL.JoinPath(r.URL.Path)
+	target.RawQuery = r.URL.RawQuery
+
+	req, _ := http.NewRequest(method, target.String(), nil)
+	req.Header.Set(Header, "1")
+	if v := r.Header.Get("Accept"); v != "" {
+		req.Header.Set("Accept", v)
+	}
+
+	// Keep the client's address so the canary rate limits per client rather
+	// than throttling all mirrored traffic as one
+	clientIP := r.Header.Get("X-Forwarded-For")
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if clientIP != "" {
+			clientIP += ", " + host
+		} else {
+			clientIP = host
+		}
+	}
+	if clientIP != "" {
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+	return req
+}
+
+func (m *Mirror) compare(req *http.Request, primaryStatus int) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+	if err != nil {
+		results.Add("error", 1)
+		slog.Warn("mirrored request failed",
+			slog.String("error", err.Error()),
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+		)
+		return
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == primaryStatus:
+		results.Add("match", 1)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// Throttling on the canary says nothing about its behaviour
+		results.Add("throttled", 1)
+	default:
+		results.Add("mismatch", 1)
+		slog.Warn("mirrored status mismatch",
+			slog.String("method", req.Method),
+			slog.String("path", strings.TrimPrefix(req.URL.Path, m.baseURL.Path)),
+			slog.Int("primary_status", primaryStatus),
+			slog.Int("canary_status", resp.StatusCode),
+		)
+	}
+}
@@ -0,0 +1,189 @@
+package mirror
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mirrored struct {
+	method       string
+	path         string
+	forwardedFor string
+	mirrorHeader string
+}
+
+func newCanary(t *testing.T, status int) (*httptest.Server, chan mirrored) {
+	t.Helper()
+
+	received := make(chan mirrored, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- mirrored{
+			method:       r.Method,
+			path:         r.URL.RequestURI(),
+			forwardedFor: r.Header.Get("X-Forwarded-For"),
+			mirrorHeader: r.Header.Get(Header),
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func resultCount(key string) int64 {
+	if v, ok := results.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func serve(m *Mirror, status int, method, target string) {
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = "192.0.2.7:4321"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMirror_MirrorsReadOnlyRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantMethod string
+	}{
+		{name: "metadata", target: "/api/v1/download/abc123/metadata?x=1", wantMethod: http.MethodGet},
+		{name: "manifest", target: "/api/v1/download/abc123/manifest", wantMethod: http.MethodGet},
+		{name: "chunk", target: "/api/v1/download/abc123/chunks/3", wantMethod: http.MethodHead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary, received := newCanary(t, http.StatusOK)
+			m, err := New(canary.URL, 100)
+			require.NoError(t, err)
+
+			serve(m, http.StatusOK, http.MethodGet, tt.target)
+
+			select {
+			case got := <-received:
+				assert.Equal(t, tt.wantMethod, got.method)
+				assert.Equal(t, tt.target, got.path)
+				assert.Equal(t, "192.0.2.7", got.forwardedFor)
+				assert.Equal(t, "1", got.mirrorHeader)
+			case <-time.After(2 * time.Second):
+				t.Fatal("request was not mirrored")
+			}
+		})
+	}
+}
+
+func TestMirror_SkipsOtherRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{name: "write", method: http.MethodPost, target: "/api/v1/download/abc123/complete", status: http.StatusOK},
+		{name: "unlisted route", method: http.MethodGet, target: "/api/v1/download/abc123/stream", status: http.StatusOK},
+		{name: "rate limited", method: http.MethodGet, target: "/api/v1/download/abc123/metadata", status: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary, received := newCanary(t, http.StatusOK)
+			m, err := New(canary.URL, 100)
+			require.NoError(t, err)
+
+			serve(m, tt.status, tt.method, tt.target)
+
+			select {
+			case got := <-received:
+				t.Fatalf("unexpected mirrored request %s %s", got.method, got.path)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestMirror_SkipsUnsampledRequests(t *testing.T) {
+	canary, received := newCanary(t, http.StatusOK)
+	m, err := New(canary.URL, 1)
+	require.NoError(t, err)
+	m.sample = func() bool { return false }
+
+	serve(m, http.StatusOK, http.MethodGet, "/api/v1/download/abc123/metadata")
+
+	select {
+	case <-received:
+		t.Fatal("unsampled request was mirrored")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirror_CountsMismatches(t *testing.T) {
+	canary, received := newCanary(t, http.StatusNotFound)
+	m, err := New(canary.URL, 100)
+	require.NoError(t, err)
+
+	before := resultCount("mismatch")
+	serve(m, http.StatusOK, http.MethodGet, "/api/v1/download/abc123/metadata")
+	<-received
+
+	assert.Eventually(t, func() bool {
+		return resultCount("mismatch") == before+1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		percent int
+		wantErr string
+	}{
+		{name: "scheme", rawURL: "ftp://canary.internal", percent: 10, wantErr: "http or https"},
+		{name: "zero percent", rawURL: "http://canary.internal", percent: 0, wantErr: "between 1 and 100"},
+		{name: "over 100 percent", rawURL: "http://canary.internal", percent: 101, wantErr: "between 1 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rawURL, tt.percent)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Run("disabled without base URL", func(t *testing.T) {
+		t.Setenv("MIRROR_BASE_URL", "")
+
+		m, err := FromEnv()
+		require.NoError(t, err)
+		assert.Nil(t, m)
+	})
+
+	t.Run("default percent", func(t *testing.T) {
+		t.Setenv("MIRROR_BASE_URL", "http://canary.internal")
+		t.Setenv("MIRROR_PERCENT", "")
+
+		m, err := FromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, DefaultPercent, m.Percent())
+	})
+
+	t.Run("malformed percent", func(t *testing.T) {
+		t.Setenv("MIRROR_BASE_URL", "http://canary.internal")
+		t.Setenv("MIRROR_PERCENT", "ten")
+
+		_, err := FromEnv()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,214 @@
+// Package notify delivers signed transfer events to webhooks registered by
+// uploaders.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/httpclient"
+)
+
+// Events sent to uploader webhooks.
+const (
+	EventDownloadCompleted = "download_completed"
+	EventLimitReached      = "limit_reached"
+	EventFileExpired       = "file_expired"
+	EventFileEvicted       = "file_evicted"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the body, keyed with
+	// the secret returned when the webhook was registered.
+	SignatureHeader = "X-Gzln-Signature"
+
+	// MaxURLLength bounds registered webhook URLs.
+	MaxURLLength = 2048
+
+	sendTimeout    = 10 * time.Second
+	maxAttempts    = 5
+	maxConcurrency = 32
+)
+
+var (
+	ErrInvalidURL     = errors.New("invalid webhook url")
+	ErrPrivateAddress = httpclient.ErrPrivateAddress
+)
+
+// Event is the JSON body posted to a webhook.
+type Event struct {
+	Type       string         `json:"type"`
+	ShareID    string         `json:"share_id"`
+	Details    map[string]any `json:"details,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// Target is a registered webhook.
+type Target struct {
+	URL    string
+	Secret string
+}
+
+// Notifier posts events to uploader webhooks in the background, retrying
+// with exponential backoff. Unless private addresses are allowed, it refuses
+// to connect to loopback, private and link-local addresses, so uploaders
+// cannot make the server call internal services.
+type Notifier struct {
+	client       *http.Client
+	pool         *httpclient.Pool
+	allowPrivate bool
+	backoff      time.Duration
+	sem          chan struct{}
+}
+
+func New(allowPrivate bool) *Notifier {
+	return newNotifier(nil, allowPrivate)
+}
+
+func newNotifier(pool *httpclient.Pool, allowPrivate bool) *Notifier {
+	n := &Notifier{
+		pool:         pool,
+		allowPrivate: allowPrivate,
+		backoff:      time.Second,
+		sem:          make(chan struct{}, maxConcurrency),
+	}
+
+	if allowPrivate {
+		n.client = pool.Client(sendTimeout)
+	} else {
+		n.client = pool.PublicClient(sendTimeout)
+	}
+	// Redirects could lead anywhere; a webhook must answer itself
+	n.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return n
+}
+
+// FromEnv builds a Notifier sending through pool when
+// NOTIFY_WEBHOOKS_ENABLED is true. It returns nil when uploader webhooks are
+// disabled.
+func FromEnv(pool *httpclient.Pool) (*Notifier, error) {
+	enabled, err := envBool("NOTIFY_WEBHOOKS_ENABLED")
+	if err != nil || !enabled {
+		return nil, err
+	}
+	allowPrivate, err := envBool("NOTIFY_ALLOW_PRIVATE_ADDRESSES")
+	if err != nil {
+		return nil, err
+	}
+	return newNotifier(pool, allowPrivate), nil
+}
+
+func envBool(key string) (bool, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return v, nil
+}
+
+// ValidateURL rejects webhook URLs that cannot or must not be called.
+func (n *Notifier) ValidateURL(rawURL string) error {
+	if len(rawURL) > MaxURLLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidURL, MaxURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: must be http or https", ErrInvalidURL)
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("%w: must have a host and no credentials", ErrInvalidURL)
+	}
+	if !n.pool.Allowed(u.Hostname()) {
+		return fmt.Errorf("%w: host is not allowed", ErrInvalidURL)
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !n.allowPrivate && !httpclient.IsPublic(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret for a new webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Notify sends e to target in the background.
+func (n *Notifier) Notify(target Target, e Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+
+	go func() {
+		n.sem <- struct{}{}
+		defer func() { <-n.sem }()
+
+		var err error
+		for attempt := range maxAttempts {
+			if attempt > 0 {
+				time.Sleep(n.backoff << (attempt - 1))
+			}
+			if err = n.send(target, e); err == nil {
+				return
+			}
+		}
+		slog.Warn("failed to deliver webhook",
+			slog.String("error", err.Error()),
+			slog.String("type", e.Type),
+			slog.String("share_id", e.ShareID),
+		)
+	}()
+}
+
+func (n *Notifier) send(target Target, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, crypto.Sign([]byte(target.Secret), string(body)))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
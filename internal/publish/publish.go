@@ -0,0 +1,144 @@
+// Package publish announces shares that became ready to an external hook,
+// such as an intranet document index.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/httpclient"
+)
+
+const (
+	sendTimeout = 10 * time.Second
+	maxAttempts = 3
+
+	// EventShareReady is sent once a share's upload is finalized.
+	EventShareReady = "share.ready"
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the body when a secret
+	// is configured.
+	SignatureHeader = "X-Gzln-Signature"
+)
+
+// Share holds only non-sensitive descriptors: no deletion token, uploader IP
+// or encrypted metadata.
+type Share struct {
+	ShareID      string     `json:"share_id"`
+	TotalSize    int64      `json:"total_size"`
+	ChunkCount   int32      `json:"chunk_count"`
+	MaxDownloads int32      `json:"max_downloads"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Event is the JSON body posted to the hook.
+type Event struct {
+	Type       string    `json:"type"`
+	Share      Share     `json:"share"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Hook posts events to a URL. Delivery is best effort with a few retries;
+// failures are logged and never block the caller.
+type Hook struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration
+}
+
+func NewHook(rawURL string, secret []byte) (*Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publish hook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("publish hook URL must be http or https, got %q", rawURL)
+	}
+
+	return &Hook{
+		url:     u.String(),
+		secret:  secret,
+		client:  &http.Client{Timeout: sendTimeout},
+		backoff: time.Second,
+	}, nil
+}
+
+// FromEnv builds a Hook sending through pool from PUBLISH_HOOK_URL and
+// PUBLISH_HOOK_SECRET. It returns nil when no hook is configured.
+func FromEnv(pool *httpclient.Pool) (*Hook, error) {
+	rawURL := os.Getenv("PUBLISH_HOOK_URL")
+	if rawURL == "" {
+		return nil, nil
+	}
+	h, err := NewHook(rawURL, []byte(os.Getenv("PUBLISH_HOOK_SECRET")))
+	if err != nil {
+		return nil, err
+	}
+	h.client = pool.Client(sendTimeout)
+	return h, nil
+}
+
+// Publish announces share in the background.
+func (h *Hook) Publish(share Share) {
+	e := Event{
+		Type:       EventShareReady,
+		Share:      share,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	go func() {
+		var err error
+		for attempt := range maxAttempts {
+			if attempt > 0 {
+				time.Sleep(h.backoff << (attempt - 1))
+			}
+			if err = h.send(e); err == nil {
+				return
+			}
+		}
+		slog.Error("failed to publish share",
+			slog.String("error", err.Error()),
+			slog.String("share_id", share.ShareID),
+		)
+	}()
+}
+
+func (h *Hook) send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.secret) > 0 {
+		req.Header.Set(SignatureHeader, crypto.Sign(h.secret, string(body)))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("publish hook returned %s", resp.Status)
+	}
+	return nil
+}
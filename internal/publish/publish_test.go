@@ -0,0 +1,75 @@
+package publish
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHook_PublishSigned(t *testing.T) {
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.True(t, crypto.VerifySignature([]byte("secret"), string(body), r.Header.Get(SignatureHeader)))
+
+		var e Event
+		assert.NoError(t, json.Unmarshal(body, &e))
+		received <- e
+	}))
+	defer srv.Close()
+
+	h, err := NewHook(srv.URL, []byte("secret"))
+	require.NoError(t, err)
+
+	h.Publish(Share{ShareID: "abc123def456", TotalSize: 1024})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, EventShareReady, e.Type)
+		assert.Equal(t, "abc123def456", e.Share.ShareID)
+		assert.Nil(t, e.Share.ExpiresAt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("hook was not called")
+	}
+}
+
+func TestHook_RetriesFailures(t *testing.T) {
+	var calls atomic.Int32
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(SignatureHeader))
+		if calls.Add(1) < maxAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(done)
+	}))
+	defer srv.Close()
+
+	h, err := NewHook(srv.URL, nil)
+	require.NoError(t, err)
+	h.backoff = time.Millisecond
+
+	h.Publish(Share{ShareID: "abc123def456"})
+
+	select {
+	case <-done:
+		assert.Equal(t, int32(maxAttempts), calls.Load())
+	case <-time.After(2 * time.Second):
+		t.Fatal("hook was not retried")
+	}
+}
+
+func TestNewHook_RejectsBadURL(t *testing.T) {
+	_, err := NewHook("ftp://example.com", nil)
+	assert.Error(t, err)
+}
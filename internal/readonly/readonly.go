@@ -0,0 +1,67 @@
+// Package readonly switches the server to serving existing shares only, e.g.
+// from a restored replica while the primary write path is repaired. Uploads
+// and other changes are refused, while metadata and chunk downloads keep
+// working without being counted.
+package readonly
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// Code is the error code of requests refused in read-only mode.
+const Code = "read_only"
+
+// RetryAfter is when refused clients are told to try again.
+const RetryAfter = 5 * time.Minute
+
+// ErrReadOnly is returned for changes refused in read-only mode.
+var ErrReadOnly = errors.New("server is read-only")
+
+// Switch turns read-only mode on and off at runtime. A nil Switch is never
+// on.
+type Switch struct {
+	enabled atomic.Bool
+}
+
+func New(enabled bool) *Switch {
+	s := &Switch{}
+	s.enabled.Store(enabled)
+	return s
+}
+
+func (s *Switch) Enabled() bool {
+	return s != nil && s.enabled.Load()
+}
+
+// Set turns read-only mode on or off for this instance only.
+func (s *Switch) Set(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// RejectWrites answers requests with unsafe methods with 503 while read-only
+// mode is on.
+func (s *Switch) RejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if s.Enabled() {
+				Reject(w)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reject answers a request refused in read-only mode.
+func Reject(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(RetryAfter.Seconds())))
+	utils.ErrorWithCode(w, http.StatusServiceUnavailable, Code, "Server is in read-only mode")
+}
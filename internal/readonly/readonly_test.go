@@ -0,0 +1,52 @@
+package readonly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwitch_RejectWrites(t *testing.T) {
+	sw := New(true)
+	handler := sw.RejectWrites(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{method: http.MethodGet, want: http.StatusNoContent},
+		{method: http.MethodHead, want: http.StatusNoContent},
+		{method: http.MethodPost, want: http.StatusServiceUnavailable},
+		{method: http.MethodPut, want: http.StatusServiceUnavailable},
+		{method: http.MethodDelete, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, "/files/upload/init", nil))
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusServiceUnavailable {
+				assert.Equal(t, "300", w.Header().Get("Retry-After"))
+				assert.Contains(t, w.Body.String(), Code)
+			}
+		})
+	}
+
+	sw.Set(false)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/files/upload/init", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestSwitch_Nil(t *testing.T) {
+	var sw *Switch
+
+	assert.False(t, sw.Enabled())
+}
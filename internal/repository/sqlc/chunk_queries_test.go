@@ -309,3 +309,69 @@ func TestCountChunksByFileId_NonExistentFile(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(0), count)
 }
+
+func TestListChunkIndexesByFileId_ReturnsSortedIndexes(t *testing.T) {
+	if testQueries == nil {
+		t.Skip("Database not available")
+	}
+
+	ctx := context.Background()
+	cleanupFiles(t)
+
+	file := createTestFileForChunks(t, ctx)
+
+	for _, i := range []int32{3, 0, 1} {
+		_, err := testQueries.CreateChunk(ctx, createTestChunkParams(file.ID, i))
+		require.NoError(t, err)
+	}
+
+	indexes, err := testQueries.ListChunkIndexesByFileId(ctx, file.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1, 3}, indexes)
+}
+
+func TestListChunkManifestByShareId_ReadyFile(t *testing.T) {
+	if testQueries == nil {
+		t.Skip("Database not available")
+	}
+
+	ctx := context.Background()
+	cleanupFiles(t)
+
+	file := createTestFileForChunks(t, ctx)
+	for _, i := range []int32{1, 0} {
+		_, err := testQueries.CreateChunk(ctx, createTestChunkParams(file.ID, i))
+		require.NoError(t, err)
+	}
+	_, err := testQueries.UpdateFileStatus(ctx, UpdateFileStatusParams{ID: file.ID, Status: "ready"})
+	require.NoError(t, err)
+
+	rows, err := testQueries.ListChunkManifestByShareId(ctx, file.ShareID)
+
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, int32(0), rows[0].ChunkIndex)
+	assert.Equal(t, int32(1), rows[1].ChunkIndex)
+	assert.Equal(t, file.ChunkCount, rows[0].ChunkCount)
+	assert.Equal(t, int64(1024), rows[0].EncryptedSize)
+	assert.Equal(t, "test-hash-value", rows[0].ChunkHash)
+}
+
+func TestListChunkManifestByShareId_UploadingFile(t *testing.T) {
+	if testQueries == nil {
+		t.Skip("Database not available")
+	}
+
+	ctx := context.Background()
+	cleanupFiles(t)
+
+	file := createTestFileForChunks(t, ctx)
+	_, err := testQueries.CreateChunk(ctx, createTestChunkParams(file.ID, 0))
+	require.NoError(t, err)
+
+	rows, err := testQueries.ListChunkManifestByShareId(ctx, file.ShareID)
+
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
@@ -51,13 +51,17 @@ INSERT INTO chunks (
     chunk_index,
     storage_path,
     encrypted_size,
-    chunk_hash
+    chunk_hash,
+    receive_ms,
+    store_ms
 ) VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7
 )
 RETURNING id
 `
@@ -68,6 +72,8 @@ type CreateChunkParams struct {
 	StoragePath   string      `json:"storage_path"`
 	EncryptedSize int64       `json:"encrypted_size"`
 	ChunkHash     string      `json:"chunk_hash"`
+	ReceiveMs     pgtype.Int4 `json:"receive_ms"`
+	StoreMs       pgtype.Int4 `json:"store_ms"`
 }
 
 func (q *Queries) CreateChunk(ctx context.Context, arg CreateChunkParams) (int64, error) {
@@ -77,12 +83,36 @@ func (q *Queries) CreateChunk(ctx context.Context, arg CreateChunkParams) (int64
 		arg.StoragePath,
 		arg.EncryptedSize,
 		arg.ChunkHash,
+		arg.ReceiveMs,
+		arg.StoreMs,
 	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
+const deleteChunkOfUnpurgedFile = `-- name: DeleteChunkOfUnpurgedFile :execrows
+DELETE FROM chunks c
+USING files f
+WHERE f.id = c.file_id
+  AND c.file_id = $1
+  AND c.chunk_index = $2
+  AND f.purged_at IS NULL
+`
+
+type DeleteChunkOfUnpurgedFileParams struct {
+	FileID     pgtype.UUID `json:"file_id"`
+	ChunkIndex int32       `json:"chunk_index"`
+}
+
+func (q *Queries) DeleteChunkOfUnpurgedFile(ctx context.Context, arg DeleteChunkOfUnpurgedFileParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteChunkOfUnpurgedFile, arg.FileID, arg.ChunkIndex)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const fileExistsByIdAndStatus = `-- name: FileExistsByIdAndStatus :one
 SELECT EXISTS(
   SELECT 1
@@ -107,9 +137,16 @@ const getChunkByIndexAndFileShareID = `-- name: GetChunkByIndexAndFileShareID :o
 SELECT
     f.max_downloads,
     f.download_count,
-    c.storage_path
+    f.max_download_rate,
+    c.file_id,
+    c.storage_path,
+    c.encrypted_size,
+    c.chunk_hash,
+    sef.key_id AS server_key_id,
+    sef.watermark
 FROM chunks c
 JOIN files f on f.id = c.file_id
+LEFT JOIN server_encrypted_files sef on sef.file_id = c.file_id
 WHERE f.share_id = $1 and c.chunk_index = $2
   AND f.status = 'ready' AND f.expires_at > NOW()
 `
@@ -120,14 +157,266 @@ type GetChunkByIndexAndFileShareIDParams struct {
 }
 
 type GetChunkByIndexAndFileShareIDRow struct {
-	MaxDownloads  int32  `json:"max_downloads"`
-	DownloadCount int32  `json:"download_count"`
-	StoragePath   string `json:"storage_path"`
+	MaxDownloads    int32       `json:"max_downloads"`
+	DownloadCount   int32       `json:"download_count"`
+	MaxDownloadRate pgtype.Int8 `json:"max_download_rate"`
+	FileID          pgtype.UUID `json:"file_id"`
+	StoragePath     string      `json:"storage_path"`
+	EncryptedSize   int64       `json:"encrypted_size"`
+	ChunkHash       string      `json:"chunk_hash"`
+	ServerKeyID     pgtype.Text `json:"server_key_id"`
+	Watermark       pgtype.Text `json:"watermark"`
 }
 
 func (q *Queries) GetChunkByIndexAndFileShareID(ctx context.Context, arg GetChunkByIndexAndFileShareIDParams) (GetChunkByIndexAndFileShareIDRow, error) {
 	row := q.db.QueryRow(ctx, getChunkByIndexAndFileShareID, arg.ShareID, arg.ChunkIndex)
 	var i GetChunkByIndexAndFileShareIDRow
-	err := row.Scan(&i.MaxDownloads, &i.DownloadCount, &i.StoragePath)
+	err := row.Scan(
+		&i.MaxDownloads,
+		&i.DownloadCount,
+		&i.MaxDownloadRate,
+		&i.FileID,
+		&i.StoragePath,
+		&i.EncryptedSize,
+		&i.ChunkHash,
+		&i.ServerKeyID,
+		&i.Watermark,
+	)
 	return i, err
 }
+
+const getChunkHashByFileIdAndIndex = `-- name: GetChunkHashByFileIdAndIndex :one
+SELECT chunk_hash
+FROM chunks
+WHERE file_id = $1 and chunk_index = $2
+`
+
+type GetChunkHashByFileIdAndIndexParams struct {
+	FileID     pgtype.UUID `json:"file_id"`
+	ChunkIndex int32       `json:"chunk_index"`
+}
+
+func (q *Queries) GetChunkHashByFileIdAndIndex(ctx context.Context, arg GetChunkHashByFileIdAndIndexParams) (string, error) {
+	row := q.db.QueryRow(ctx, getChunkHashByFileIdAndIndex, arg.FileID, arg.ChunkIndex)
+	var chunk_hash string
+	err := row.Scan(&chunk_hash)
+	return chunk_hash, err
+}
+
+const getChunkThroughput = `-- name: GetChunkThroughput :one
+SELECT COUNT(*)::int                            AS chunks,
+       COALESCE(SUM(encrypted_size), 0)::bigint AS bytes,
+       COALESCE(SUM(receive_ms), 0)::bigint     AS receive_ms,
+       COALESCE(SUM(store_ms), 0)::bigint       AS store_ms
+FROM (
+    SELECT encrypted_size, receive_ms, store_ms
+    FROM chunks
+    WHERE file_id = $1
+      AND receive_ms IS NOT NULL
+    ORDER BY uploaded_at DESC
+    LIMIT $2
+) recent
+`
+
+type GetChunkThroughputParams struct {
+	FileID pgtype.UUID `json:"file_id"`
+	Limit  int32       `json:"limit"`
+}
+
+type GetChunkThroughputRow struct {
+	Chunks    int32 `json:"chunks"`
+	Bytes     int64 `json:"bytes"`
+	ReceiveMs int64 `json:"receive_ms"`
+	StoreMs   int64 `json:"store_ms"`
+}
+
+func (q *Queries) GetChunkThroughput(ctx context.Context, arg GetChunkThroughputParams) (GetChunkThroughputRow, error) {
+	row := q.db.QueryRow(ctx, getChunkThroughput, arg.FileID, arg.Limit)
+	var i GetChunkThroughputRow
+	err := row.Scan(
+		&i.Chunks,
+		&i.Bytes,
+		&i.ReceiveMs,
+		&i.StoreMs,
+	)
+	return i, err
+}
+
+const listChunkIndexesByFileId = `-- name: ListChunkIndexesByFileId :many
+SELECT chunk_index
+FROM chunks
+WHERE file_id = $1
+ORDER BY chunk_index
+`
+
+func (q *Queries) ListChunkIndexesByFileId(ctx context.Context, fileID pgtype.UUID) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listChunkIndexesByFileId, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var chunk_index int32
+		if err := rows.Scan(&chunk_index); err != nil {
+			return nil, err
+		}
+		items = append(items, chunk_index)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChunksByFileId = `-- name: ListChunksByFileId :many
+SELECT id, file_id, chunk_index, storage_path, encrypted_size, chunk_hash, uploaded_at, receive_ms, store_ms
+FROM chunks
+WHERE file_id = $1
+ORDER BY chunk_index
+`
+
+func (q *Queries) ListChunksByFileId(ctx context.Context, fileID pgtype.UUID) ([]Chunk, error) {
+	rows, err := q.db.Query(ctx, listChunksByFileId, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Chunk{}
+	for rows.Next() {
+		var i Chunk
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileID,
+			&i.ChunkIndex,
+			&i.StoragePath,
+			&i.EncryptedSize,
+			&i.ChunkHash,
+			&i.UploadedAt,
+			&i.ReceiveMs,
+			&i.StoreMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChunkManifestByShareId = `-- name: ListChunkManifestByShareId :many
+SELECT
+    f.chunk_count,
+    f.total_size,
+    f.max_downloads,
+    f.download_count,
+    c.chunk_index,
+    c.encrypted_size,
+    c.chunk_hash
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE f.share_id = $1
+  AND f.status = 'ready' AND f.expires_at > NOW()
+ORDER BY c.chunk_index
+`
+
+type ListChunkManifestByShareIdRow struct {
+	ChunkCount    int32  `json:"chunk_count"`
+	TotalSize     int64  `json:"total_size"`
+	MaxDownloads  int32  `json:"max_downloads"`
+	DownloadCount int32  `json:"download_count"`
+	ChunkIndex    int32  `json:"chunk_index"`
+	EncryptedSize int64  `json:"encrypted_size"`
+	ChunkHash     string `json:"chunk_hash"`
+}
+
+func (q *Queries) ListChunkManifestByShareId(ctx context.Context, shareID string) ([]ListChunkManifestByShareIdRow, error) {
+	rows, err := q.db.Query(ctx, listChunkManifestByShareId, shareID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChunkManifestByShareIdRow{}
+	for rows.Next() {
+		var i ListChunkManifestByShareIdRow
+		if err := rows.Scan(
+			&i.ChunkCount,
+			&i.TotalSize,
+			&i.MaxDownloads,
+			&i.DownloadCount,
+			&i.ChunkIndex,
+			&i.EncryptedSize,
+			&i.ChunkHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChunksOfUnpurgedFile = `-- name: ListChunksOfUnpurgedFile :many
+SELECT c.chunk_index, c.uploaded_at
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE c.file_id = $1
+  AND f.purged_at IS NULL
+ORDER BY c.chunk_index
+`
+
+type ListChunksOfUnpurgedFileRow struct {
+	ChunkIndex int32              `json:"chunk_index"`
+	UploadedAt pgtype.Timestamptz `json:"uploaded_at"`
+}
+
+func (q *Queries) ListChunksOfUnpurgedFile(ctx context.Context, fileID pgtype.UUID) ([]ListChunksOfUnpurgedFileRow, error) {
+	rows, err := q.db.Query(ctx, listChunksOfUnpurgedFile, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChunksOfUnpurgedFileRow{}
+	for rows.Next() {
+		var i ListChunksOfUnpurgedFileRow
+		if err := rows.Scan(&i.ChunkIndex, &i.UploadedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnpurgedFileIdsWithChunks = `-- name: ListUnpurgedFileIdsWithChunks :many
+SELECT DISTINCT c.file_id
+FROM chunks c
+JOIN files f on f.id = c.file_id
+WHERE f.purged_at IS NULL
+`
+
+func (q *Queries) ListUnpurgedFileIdsWithChunks(ctx context.Context) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, listUnpurgedFileIdsWithChunks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []pgtype.UUID{}
+	for rows.Next() {
+		var file_id pgtype.UUID
+		if err := rows.Scan(&file_id); err != nil {
+			return nil, err
+		}
+		items = append(items, file_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
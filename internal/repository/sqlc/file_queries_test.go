@@ -246,3 +246,30 @@ func TestUpdateFileStatus_NotFound(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no rows")
 }
+
+func TestCountActiveUploads(t *testing.T) {
+	if testQueries == nil {
+		t.Skip("Database not available")
+	}
+	cleanupFiles(t)
+
+	ctx := context.Background()
+
+	_, err := testQueries.CreateFile(ctx, createTestFileParams("act12345678"))
+	require.NoError(t, err)
+
+	ready, err := testQueries.CreateFile(ctx, createTestFileParams("rdy12345678"))
+	require.NoError(t, err)
+	_, err = testQueries.UpdateFileStatus(ctx, UpdateFileStatusParams{ID: ready.ID, Status: "ready"})
+	require.NoError(t, err)
+
+	stale, err := testQueries.CreateFile(ctx, createTestFileParams("old12345678"))
+	require.NoError(t, err)
+	_, err = testPool.Exec(ctx, "UPDATE files SET created_at = now() - interval '2 hours' WHERE id = $1", stale.ID)
+	require.NoError(t, err)
+
+	count, err := testQueries.CountActiveUploads(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -20,7 +20,7 @@ WITH updated AS (
             last_downloaded_at = now()
         WHERE share_id = $1
             AND status = 'ready'
-            AND (max_downloads = 0 OR download_count < max_downloads)
+            AND (max_downloads = -1 OR download_count < max_downloads)
             AND (expires_at IS NULL OR expires_at > now())
         RETURNING id, share_id, download_count, max_downloads, expires_at)
 SELECT u.id,
@@ -55,6 +55,20 @@ func (q *Queries) CompleteFileDownloadByShareId(ctx context.Context, shareID str
 	return i, err
 }
 
+const countActiveUploads = `-- name: CountActiveUploads :one
+SELECT COUNT(*)
+FROM files
+WHERE status = 'uploading'
+  AND created_at > now() - interval '1 hour'
+`
+
+func (q *Queries) CountActiveUploads(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countActiveUploads)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFile = `-- name: CreateFile :one
 INSERT INTO files (share_id,
                    encrypted_filename,
@@ -67,9 +81,11 @@ INSERT INTO files (share_id,
                    expires_at,
                    max_downloads,
                    deletion_token_hash,
-                   uploader_ip)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip
+                   uploader_ip,
+                   return_url,
+                   alias)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
 `
 
 type CreateFileParams struct {
@@ -85,6 +101,8 @@ type CreateFileParams struct {
 	MaxDownloads      int32              `json:"max_downloads"`
 	DeletionTokenHash pgtype.Text        `json:"deletion_token_hash"`
 	UploaderIp        netip.Addr         `json:"uploader_ip"`
+	ReturnUrl         pgtype.Text        `json:"return_url"`
+	Alias             pgtype.Text        `json:"alias"`
 }
 
 func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, error) {
@@ -101,6 +119,8 @@ func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, e
 		arg.MaxDownloads,
 		arg.DeletionTokenHash,
 		arg.UploaderIp,
+		arg.ReturnUrl,
+		arg.Alias,
 	)
 	var i File
 	err := row.Scan(
@@ -121,13 +141,44 @@ func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, e
 		&i.DownloadCount,
 		&i.DeletionTokenHash,
 		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
 	)
 	return i, err
 }
 
+const deletePurgedFiles = `-- name: DeletePurgedFiles :execrows
+DELETE
+FROM files
+WHERE id IN (SELECT id
+             FROM files
+             WHERE status = 'expired'
+               AND purged_at < $1
+             ORDER BY purged_at
+             LIMIT $2)
+`
+
+type DeletePurgedFilesParams struct {
+	PurgedBefore pgtype.Timestamptz `json:"purged_before"`
+	BatchSize    int32              `json:"batch_size"`
+}
+
+func (q *Queries) DeletePurgedFiles(ctx context.Context, arg DeletePurgedFilesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePurgedFiles, arg.PurgedBefore, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const expireFilesByIds = `-- name: ExpireFilesByIds :exec
 UPDATE files
-SET status = 'expired'
+SET status    = 'expired',
+    purged_at = now()
 WHERE id = ANY ($1::uuid[])
 `
 
@@ -137,7 +188,7 @@ func (q *Queries) ExpireFilesByIds(ctx context.Context, dollar_1 []pgtype.UUID)
 }
 
 const getExpiredFiles = `-- name: GetExpiredFiles :many
-SELECT id, chunk_count
+SELECT id, share_id, chunk_count
 FROM files
 WHERE status != 'expired'
   AND (
@@ -147,6 +198,7 @@ WHERE status != 'expired'
 
 type GetExpiredFilesRow struct {
 	ID         pgtype.UUID `json:"id"`
+	ShareID    string      `json:"share_id"`
 	ChunkCount int32       `json:"chunk_count"`
 }
 
@@ -159,7 +211,7 @@ func (q *Queries) GetExpiredFiles(ctx context.Context) ([]GetExpiredFilesRow, er
 	items := []GetExpiredFilesRow{}
 	for rows.Next() {
 		var i GetExpiredFilesRow
-		if err := rows.Scan(&i.ID, &i.ChunkCount); err != nil {
+		if err := rows.Scan(&i.ID, &i.ShareID, &i.ChunkCount); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -171,7 +223,7 @@ func (q *Queries) GetExpiredFiles(ctx context.Context) ([]GetExpiredFilesRow, er
 }
 
 const getFileByID = `-- name: GetFileByID :one
-SELECT id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip
+SELECT id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
 FROM files
 WHERE id = $1
 `
@@ -197,12 +249,18 @@ func (q *Queries) GetFileByID(ctx context.Context, id pgtype.UUID) (File, error)
 		&i.DownloadCount,
 		&i.DeletionTokenHash,
 		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
 	)
 	return i, err
 }
 
 const getFileByShareID = `-- name: GetFileByShareID :one
-SELECT id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip
+SELECT id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
 FROM files
 WHERE share_id = $1
 `
@@ -228,6 +286,12 @@ func (q *Queries) GetFileByShareID(ctx context.Context, shareID string) (File, e
 		&i.DownloadCount,
 		&i.DeletionTokenHash,
 		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
 	)
 	return i, err
 }
@@ -240,7 +304,13 @@ SELECT encrypted_filename,
        chunk_count,
        expires_at,
        max_downloads,
-       download_count
+       download_count,
+       created_at,
+       status,
+       return_url,
+       EXISTS(SELECT 1
+              FROM server_encrypted_files sef
+              WHERE sef.file_id = files.id) AS server_encrypted
 FROM files
 WHERE share_id = $1
 `
@@ -254,6 +324,10 @@ type GetFileMetadataByShareIdRow struct {
 	ExpiresAt         pgtype.Timestamptz `json:"expires_at"`
 	MaxDownloads      int32              `json:"max_downloads"`
 	DownloadCount     int32              `json:"download_count"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	Status            string             `json:"status"`
+	ReturnUrl         pgtype.Text        `json:"return_url"`
+	ServerEncrypted   bool               `json:"server_encrypted"`
 }
 
 func (q *Queries) GetFileMetadataByShareId(ctx context.Context, shareID string) (GetFileMetadataByShareIdRow, error) {
@@ -268,6 +342,10 @@ func (q *Queries) GetFileMetadataByShareId(ctx context.Context, shareID string)
 		&i.ExpiresAt,
 		&i.MaxDownloads,
 		&i.DownloadCount,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ReturnUrl,
+		&i.ServerEncrypted,
 	)
 	return i, err
 }
@@ -285,11 +363,259 @@ func (q *Queries) GetFileSaltByShareId(ctx context.Context, shareID string) (str
 	return salt, err
 }
 
+const getShareIDByAlias = `-- name: GetShareIDByAlias :one
+SELECT share_id
+FROM files
+WHERE alias = $1
+  AND status <> 'expired'
+  AND NOT EXISTS(SELECT 1
+                 FROM files f
+                 WHERE f.share_id = $1)
+`
+
+func (q *Queries) GetShareIDByAlias(ctx context.Context, alias pgtype.Text) (string, error) {
+	row := q.db.QueryRow(ctx, getShareIDByAlias, alias)
+	var share_id string
+	err := row.Scan(&share_id)
+	return share_id, err
+}
+
+const listFilesByDeletionTokens = `-- name: ListFilesByDeletionTokens :many
+SELECT id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
+FROM files
+WHERE deletion_token_hash = ANY ($1::text[])
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListFilesByDeletionTokens(ctx context.Context, dollar_1 []string) ([]File, error) {
+	rows, err := q.db.Query(ctx, listFilesByDeletionTokens, dollar_1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []File{}
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShareID,
+			&i.EncryptedFilename,
+			&i.EncryptedMimeType,
+			&i.Salt,
+			&i.Pbkdf2Iterations,
+			&i.TotalSize,
+			&i.ChunkCount,
+			&i.ChunkSize,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastDownloadedAt,
+			&i.MaxDownloads,
+			&i.DownloadCount,
+			&i.DeletionTokenHash,
+			&i.UploaderIp,
+			&i.AdminNotes,
+			&i.PurgedAt,
+			&i.VerifyStartedAt,
+			&i.ReturnUrl,
+			&i.Alias,
+			&i.MaxDownloadRate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markFileReady = `-- name: MarkFileReady :one
+UPDATE files
+SET status = 'ready'
+WHERE id = $1
+  AND status IN ('uploading', 'verifying')
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
+`
+
+func (q *Queries) MarkFileReady(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, markFileReady, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.ShareID,
+		&i.EncryptedFilename,
+		&i.EncryptedMimeType,
+		&i.Salt,
+		&i.Pbkdf2Iterations,
+		&i.TotalSize,
+		&i.ChunkCount,
+		&i.ChunkSize,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastDownloadedAt,
+		&i.MaxDownloads,
+		&i.DownloadCount,
+		&i.DeletionTokenHash,
+		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
+	)
+	return i, err
+}
+
+const markUnpurgedFileCorrupt = `-- name: MarkUnpurgedFileCorrupt :exec
+UPDATE files
+SET status = 'corrupt'
+WHERE id = $1
+  AND status NOT IN ('uploading', 'verifying')
+  AND purged_at IS NULL
+`
+
+func (q *Queries) MarkUnpurgedFileCorrupt(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, markUnpurgedFileCorrupt, id)
+	return err
+}
+
+const resetStaleVerifications = `-- name: ResetStaleVerifications :execrows
+UPDATE files
+SET status = 'uploading'
+WHERE status = 'verifying'
+  AND verify_started_at < $1
+`
+
+func (q *Queries) ResetStaleVerifications(ctx context.Context, startedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, resetStaleVerifications, startedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeFile = `-- name: RevokeFile :one
+UPDATE files
+SET status = 'revoked'
+WHERE id = $1
+  AND status = 'ready'
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
+`
+
+func (q *Queries) RevokeFile(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, revokeFile, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.ShareID,
+		&i.EncryptedFilename,
+		&i.EncryptedMimeType,
+		&i.Salt,
+		&i.Pbkdf2Iterations,
+		&i.TotalSize,
+		&i.ChunkCount,
+		&i.ChunkSize,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastDownloadedAt,
+		&i.MaxDownloads,
+		&i.DownloadCount,
+		&i.DeletionTokenHash,
+		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
+	)
+	return i, err
+}
+
+const shareNameTaken = `-- name: ShareNameTaken :one
+SELECT EXISTS(SELECT 1
+              FROM files
+              WHERE share_id = $1
+                 OR (alias = $1 AND status <> 'expired'))
+`
+
+func (q *Queries) ShareNameTaken(ctx context.Context, shareID string) (bool, error) {
+	row := q.db.QueryRow(ctx, shareNameTaken, shareID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const startFileVerification = `-- name: StartFileVerification :one
+UPDATE files
+SET status            = 'verifying',
+    verify_started_at = now()
+WHERE id = $1
+  AND status = 'uploading'
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
+`
+
+func (q *Queries) StartFileVerification(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, startFileVerification, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.ShareID,
+		&i.EncryptedFilename,
+		&i.EncryptedMimeType,
+		&i.Salt,
+		&i.Pbkdf2Iterations,
+		&i.TotalSize,
+		&i.ChunkCount,
+		&i.ChunkSize,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastDownloadedAt,
+		&i.MaxDownloads,
+		&i.DownloadCount,
+		&i.DeletionTokenHash,
+		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
+	)
+	return i, err
+}
+
+const updateFileMaxDownloadRate = `-- name: UpdateFileMaxDownloadRate :execrows
+UPDATE files
+SET max_download_rate = $2
+WHERE id = $1
+  AND status != 'expired'
+`
+
+type UpdateFileMaxDownloadRateParams struct {
+	ID              pgtype.UUID `json:"id"`
+	MaxDownloadRate pgtype.Int8 `json:"max_download_rate"`
+}
+
+func (q *Queries) UpdateFileMaxDownloadRate(ctx context.Context, arg UpdateFileMaxDownloadRateParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateFileMaxDownloadRate, arg.ID, arg.MaxDownloadRate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateFileStatus = `-- name: UpdateFileStatus :one
 UPDATE files
 SET status = $2
 WHERE id = $1
-RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
 `
 
 type UpdateFileStatusParams struct {
@@ -318,6 +644,55 @@ func (q *Queries) UpdateFileStatus(ctx context.Context, arg UpdateFileStatusPara
 		&i.DownloadCount,
 		&i.DeletionTokenHash,
 		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
+	)
+	return i, err
+}
+
+const updateFileAdminNotes = `-- name: UpdateFileAdminNotes :one
+UPDATE files
+SET admin_notes = $2
+WHERE id = $1
+RETURNING id, share_id, encrypted_filename, encrypted_mime_type, salt, pbkdf2_iterations, total_size, chunk_count, chunk_size, status, created_at, expires_at, last_downloaded_at, max_downloads, download_count, deletion_token_hash, uploader_ip, admin_notes, purged_at, verify_started_at, return_url, alias, max_download_rate
+`
+
+type UpdateFileAdminNotesParams struct {
+	ID         pgtype.UUID `json:"id"`
+	AdminNotes pgtype.Text `json:"admin_notes"`
+}
+
+func (q *Queries) UpdateFileAdminNotes(ctx context.Context, arg UpdateFileAdminNotesParams) (File, error) {
+	row := q.db.QueryRow(ctx, updateFileAdminNotes, arg.ID, arg.AdminNotes)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.ShareID,
+		&i.EncryptedFilename,
+		&i.EncryptedMimeType,
+		&i.Salt,
+		&i.Pbkdf2Iterations,
+		&i.TotalSize,
+		&i.ChunkCount,
+		&i.ChunkSize,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastDownloadedAt,
+		&i.MaxDownloads,
+		&i.DownloadCount,
+		&i.DeletionTokenHash,
+		&i.UploaderIp,
+		&i.AdminNotes,
+		&i.PurgedAt,
+		&i.VerifyStartedAt,
+		&i.ReturnUrl,
+		&i.Alias,
+		&i.MaxDownloadRate,
 	)
 	return i, err
 }
@@ -10,6 +10,27 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditLog struct {
+	ID        int64              `json:"id"`
+	FileID    pgtype.UUID        `json:"file_id"`
+	Action    string             `json:"action"`
+	Actor     string             `json:"actor"`
+	Details   []byte             `json:"details"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type BlockedNetwork struct {
+	Network   netip.Prefix       `json:"network"`
+	Reason    string             `json:"reason"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type BundleFile struct {
+	FileID    pgtype.UUID        `json:"file_id"`
+	BundleID  pgtype.UUID        `json:"bundle_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Chunk struct {
 	ID            int64              `json:"id"`
 	FileID        pgtype.UUID        `json:"file_id"`
@@ -18,6 +39,24 @@ type Chunk struct {
 	EncryptedSize int64              `json:"encrypted_size"`
 	ChunkHash     string             `json:"chunk_hash"`
 	UploadedAt    pgtype.Timestamptz `json:"uploaded_at"`
+	ReceiveMs     pgtype.Int4        `json:"receive_ms"`
+	StoreMs       pgtype.Int4        `json:"store_ms"`
+}
+
+type DownloadNonce struct {
+	NonceHash    string             `json:"nonce_hash"`
+	FileID       pgtype.UUID        `json:"file_id"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	UsedAt       pgtype.Timestamptz `json:"used_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	ServedChunks []int32            `json:"served_chunks"`
+	ShareLinkID  pgtype.UUID        `json:"share_link_id"`
+}
+
+type FeatureFlag struct {
+	Name      string             `json:"name"`
+	Enabled   bool               `json:"enabled"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
 type File struct {
@@ -38,4 +77,95 @@ type File struct {
 	DownloadCount     int32              `json:"download_count"`
 	DeletionTokenHash pgtype.Text        `json:"deletion_token_hash"`
 	UploaderIp        netip.Addr         `json:"uploader_ip"`
+	AdminNotes        pgtype.Text        `json:"admin_notes"`
+	PurgedAt          pgtype.Timestamptz `json:"purged_at"`
+	VerifyStartedAt   pgtype.Timestamptz `json:"verify_started_at"`
+	ReturnUrl         pgtype.Text        `json:"return_url"`
+	Alias             pgtype.Text        `json:"alias"`
+	MaxDownloadRate   pgtype.Int8        `json:"max_download_rate"`
+}
+
+type FileBundle struct {
+	ID        pgtype.UUID        `json:"id"`
+	ShareID   string             `json:"share_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type FileKey struct {
+	FileID     pgtype.UUID        `json:"file_id"`
+	KeyID      string             `json:"key_id"`
+	WrappedKey []byte             `json:"wrapped_key"`
+	Algorithm  string             `json:"algorithm"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type FileWebhook struct {
+	FileID    pgtype.UUID        `json:"file_id"`
+	Url       string             `json:"url"`
+	Secret    string             `json:"secret"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Paste struct {
+	ID               pgtype.UUID        `json:"id"`
+	ShareID          string             `json:"share_id"`
+	Ciphertext       string             `json:"ciphertext"`
+	Salt             string             `json:"salt"`
+	Pbkdf2Iterations int32              `json:"pbkdf2_iterations"`
+	MaxDownloads     int32              `json:"max_downloads"`
+	DownloadCount    int32              `json:"download_count"`
+	ExpiresAt        pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+}
+
+type RetentionReport struct {
+	ID          int64              `json:"id"`
+	PeriodStart pgtype.Timestamptz `json:"period_start"`
+	PeriodEnd   pgtype.Timestamptz `json:"period_end"`
+	Report      []byte             `json:"report"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type ServerEncryptedFile struct {
+	FileID    pgtype.UUID        `json:"file_id"`
+	KeyID     string             `json:"key_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Watermark pgtype.Text        `json:"watermark"`
+}
+
+type ShareLink struct {
+	ID            pgtype.UUID        `json:"id"`
+	LinkID        string             `json:"link_id"`
+	FileID        pgtype.UUID        `json:"file_id"`
+	Label         pgtype.Text        `json:"label"`
+	MaxDownloads  int32              `json:"max_downloads"`
+	DownloadCount int32              `json:"download_count"`
+	ExpiresAt     pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt     pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+}
+
+type TransferStat struct {
+	FileID      pgtype.UUID `json:"file_id"`
+	Day         pgtype.Date `json:"day"`
+	BytesServed int64       `json:"bytes_served"`
+}
+
+type UploadSlot struct {
+	TokenHash      string             `json:"token_hash"`
+	MaxSize        int64              `json:"max_size"`
+	ExpiresInHours int32              `json:"expires_in_hours"`
+	MaxDownloads   int32              `json:"max_downloads"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type UploadSquatter struct {
+	Ip                netip.Addr         `json:"ip"`
+	UnfinishedUploads int32              `json:"unfinished_uploads"`
+	FlaggedUntil      pgtype.Timestamptz `json:"flagged_until"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
 }
@@ -6,25 +6,108 @@ package sqlc
 
 import (
 	"context"
+	"net/netip"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AddBundleFile(ctx context.Context, arg AddBundleFileParams) error
+	AddTransferBytes(ctx context.Context, arg AddTransferBytesParams) error
 	ChunkExistsByFileIdAndIndex(ctx context.Context, arg ChunkExistsByFileIdAndIndexParams) (bool, error)
 	CompleteFileDownloadByShareId(ctx context.Context, shareID string) (CompleteFileDownloadByShareIdRow, error)
+	ConsumeDownloadNonce(ctx context.Context, arg ConsumeDownloadNonceParams) (int64, error)
+	ConsumeUploadSlot(ctx context.Context, arg ConsumeUploadSlotParams) (UploadSlot, error)
+	CountActiveSharesByUploader(ctx context.Context, uploaderIp netip.Addr) (int64, error)
+	CountActiveUploads(ctx context.Context) (int64, error)
+	CountBundleFiles(ctx context.Context, bundleID pgtype.UUID) (int64, error)
 	CountChunksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error)
+	CountShareLinkDownload(ctx context.Context, id pgtype.UUID) (int64, error)
+	CountShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error)
+	CountUnfinishedUploads(ctx context.Context, arg CountUnfinishedUploadsParams) (int64, error)
+	CreateAuditLogEntry(ctx context.Context, arg CreateAuditLogEntryParams) (AuditLog, error)
 	CreateChunk(ctx context.Context, arg CreateChunkParams) (int64, error)
+	CreateDownloadNonce(ctx context.Context, arg CreateDownloadNonceParams) (int64, error)
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
+	CreateFileBundle(ctx context.Context, arg CreateFileBundleParams) (FileBundle, error)
+	CreateFileKey(ctx context.Context, arg CreateFileKeyParams) (int64, error)
+	CreateFileWebhook(ctx context.Context, arg CreateFileWebhookParams) (int64, error)
+	CreatePaste(ctx context.Context, arg CreatePasteParams) (Paste, error)
+	CreateServerEncryptedFile(ctx context.Context, arg CreateServerEncryptedFileParams) error
+	CreateShareLink(ctx context.Context, arg CreateShareLinkParams) (ShareLink, error)
+	CreateUploadSlot(ctx context.Context, arg CreateUploadSlotParams) error
+	DeleteBlockedNetwork(ctx context.Context, network netip.Prefix) (int64, error)
+	DeleteChunkOfUnpurgedFile(ctx context.Context, arg DeleteChunkOfUnpurgedFileParams) (int64, error)
+	DeleteExpiredDownloadNonces(ctx context.Context) (int64, error)
+	DeleteExpiredPastes(ctx context.Context) (int64, error)
+	DeleteExpiredUploadSlots(ctx context.Context) (int64, error)
+	DeleteExpiredUploadSquatters(ctx context.Context) (int64, error)
+	DeletePurgedFiles(ctx context.Context, arg DeletePurgedFilesParams) (int64, error)
+	EvictFile(ctx context.Context, id pgtype.UUID) (string, error)
 	ExpireFilesByIds(ctx context.Context, dollar_1 []pgtype.UUID) error
 	FileExistsByIdAndStatus(ctx context.Context, arg FileExistsByIdAndStatusParams) (bool, error)
+	FlagUploadSquatter(ctx context.Context, arg FlagUploadSquatterParams) (UploadSquatter, error)
+	ForceExpireFile(ctx context.Context, shareID string) (pgtype.UUID, error)
 	GetChunkByIndexAndFileShareID(ctx context.Context, arg GetChunkByIndexAndFileShareIDParams) (GetChunkByIndexAndFileShareIDRow, error)
+	GetChunkHashByFileIdAndIndex(ctx context.Context, arg GetChunkHashByFileIdAndIndexParams) (string, error)
+	GetChunkThroughput(ctx context.Context, arg GetChunkThroughputParams) (GetChunkThroughputRow, error)
+	GetDownloadNonce(ctx context.Context, arg GetDownloadNonceParams) (GetDownloadNonceRow, error)
 	GetExpiredFiles(ctx context.Context) ([]GetExpiredFilesRow, error)
+	GetFileBundleByShareId(ctx context.Context, shareID string) (FileBundle, error)
+	GetFileBundleByTokenHash(ctx context.Context, tokenHash string) (FileBundle, error)
 	GetFileByID(ctx context.Context, id pgtype.UUID) (File, error)
 	GetFileByShareID(ctx context.Context, shareID string) (File, error)
+	GetFileKeyByFileId(ctx context.Context, fileID pgtype.UUID) (FileKey, error)
 	GetFileMetadataByShareId(ctx context.Context, shareID string) (GetFileMetadataByShareIdRow, error)
 	GetFileSaltByShareId(ctx context.Context, shareID string) (string, error)
+	GetFileWebhookByFileId(ctx context.Context, fileID pgtype.UUID) (FileWebhook, error)
+	GetOldestRetainedFile(ctx context.Context, periodEnd pgtype.Timestamptz) (GetOldestRetainedFileRow, error)
+	GetPasteByShareId(ctx context.Context, shareID string) (Paste, error)
+	GetRetentionReport(ctx context.Context, periodStart pgtype.Timestamptz) (RetentionReport, error)
+	GetRetentionStats(ctx context.Context, arg GetRetentionStatsParams) (GetRetentionStatsRow, error)
+	GetServerEncryptionKeyIdByFileId(ctx context.Context, fileID pgtype.UUID) (string, error)
+	GetShareIDByAlias(ctx context.Context, alias pgtype.Text) (string, error)
+	GetShareLink(ctx context.Context, linkID string) (GetShareLinkRow, error)
+	GetStorageTotals(ctx context.Context) ([]GetStorageTotalsRow, error)
+	GetStoredBytes(ctx context.Context) (int64, error)
+	GetUploadSlot(ctx context.Context, arg GetUploadSlotParams) (UploadSlot, error)
+	GetUploadSquatter(ctx context.Context, ip netip.Addr) (UploadSquatter, error)
+	GetUploaderUsage(ctx context.Context, arg GetUploaderUsageParams) (GetUploaderUsageRow, error)
+	GetWatermarkByShareId(ctx context.Context, shareID string) (pgtype.Text, error)
+	ListAdminFiles(ctx context.Context, arg ListAdminFilesParams) ([]ListAdminFilesRow, error)
+	ListAuditLogByFileId(ctx context.Context, fileID pgtype.UUID) ([]AuditLog, error)
+	ListAuditLogByFileIdsAndAction(ctx context.Context, arg ListAuditLogByFileIdsAndActionParams) ([]AuditLog, error)
+	ListBlockedNetworks(ctx context.Context) ([]BlockedNetwork, error)
+	ListChunkIndexesByFileId(ctx context.Context, fileID pgtype.UUID) ([]int32, error)
+	ListChunkManifestByShareId(ctx context.Context, shareID string) ([]ListChunkManifestByShareIdRow, error)
+	ListChunksByFileId(ctx context.Context, fileID pgtype.UUID) ([]Chunk, error)
+	ListChunksOfUnpurgedFile(ctx context.Context, fileID pgtype.UUID) ([]ListChunksOfUnpurgedFileRow, error)
+	ListDailyTransferTotals(ctx context.Context, since pgtype.Date) ([]ListDailyTransferTotalsRow, error)
+	ListEvictionCandidates(ctx context.Context, limit int32) ([]ListEvictionCandidatesRow, error)
+	ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error)
+	ListFileWebhooksByFileIds(ctx context.Context, dollar_1 []pgtype.UUID) ([]ListFileWebhooksByFileIdsRow, error)
+	ListFilesByDeletionTokens(ctx context.Context, dollar_1 []string) ([]File, error)
+	ListReadyBundleFiles(ctx context.Context, bundleID pgtype.UUID) ([]ListReadyBundleFilesRow, error)
+	ListRetentionReports(ctx context.Context) ([]ListRetentionReportsRow, error)
+	ListShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) ([]ShareLink, error)
+	ListTopTransferFiles(ctx context.Context, arg ListTopTransferFilesParams) ([]ListTopTransferFilesRow, error)
+	ListUnpurgedFileIdsWithChunks(ctx context.Context) ([]pgtype.UUID, error)
+	MarkFileReady(ctx context.Context, id pgtype.UUID) (File, error)
+	MarkUnpurgedFileCorrupt(ctx context.Context, id pgtype.UUID) error
+	ReadPasteByShareId(ctx context.Context, shareID string) (Paste, error)
+	RecordDownloadNonceChunks(ctx context.Context, arg RecordDownloadNonceChunksParams) (RecordDownloadNonceChunksRow, error)
+	ResetStaleVerifications(ctx context.Context, startedBefore pgtype.Timestamptz) (int64, error)
+	RevokeFile(ctx context.Context, id pgtype.UUID) (File, error)
+	RevokeShareLink(ctx context.Context, arg RevokeShareLinkParams) (ShareLink, error)
+	ShareNameTaken(ctx context.Context, shareID string) (bool, error)
+	StartFileVerification(ctx context.Context, id pgtype.UUID) (File, error)
+	UpdateFileAdminNotes(ctx context.Context, arg UpdateFileAdminNotesParams) (File, error)
+	UpdateFileMaxDownloadRate(ctx context.Context, arg UpdateFileMaxDownloadRateParams) (int64, error)
 	UpdateFileStatus(ctx context.Context, arg UpdateFileStatusParams) (File, error)
+	UpdateServerEncryptedFileWatermark(ctx context.Context, arg UpdateServerEncryptedFileWatermarkParams) (int64, error)
+	UpsertBlockedNetwork(ctx context.Context, arg UpsertBlockedNetworkParams) (BlockedNetwork, error)
+	UpsertFeatureFlag(ctx context.Context, arg UpsertFeatureFlagParams) (FeatureFlag, error)
+	UpsertRetentionReport(ctx context.Context, arg UpsertRetentionReportParams) (RetentionReport, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,70 @@
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+)
+
+// DefaultAbuseIPDBURL is the AbuseIPDB API the provider queries.
+const DefaultAbuseIPDBURL = "https://api.abuseipdb.com"
+
+// AbuseIPDB scores IPs by their AbuseIPDB abuse confidence, which is already
+// on the 0 to 100 scale.
+type AbuseIPDB struct {
+	APIKey  string
+	BaseURL string
+	// MaxAgeDays limits the reports counted to the most recent days.
+	MaxAgeDays int
+	HTTP       *http.Client
+}
+
+func NewAbuseIPDB(apiKey string) *AbuseIPDB {
+	return &AbuseIPDB{
+		APIKey:     apiKey,
+		BaseURL:    DefaultAbuseIPDBURL,
+		MaxAgeDays: 90,
+		HTTP:       http.DefaultClient,
+	}
+}
+
+func (a *AbuseIPDB) Score(ctx context.Context, ip netip.Addr) (int, error) {
+	query := url.Values{
+		"ipAddress":    {ip.String()},
+		"maxAgeInDays": {strconv.Itoa(a.MaxAgeDays)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.BaseURL+"/api/v2/check?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Key", a.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("abuseipdb: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return 0, fmt.Errorf("abuseipdb: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			AbuseConfidenceScore *int `json:"abuseConfidenceScore"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err != nil {
+		return 0, fmt.Errorf("abuseipdb: invalid response: %w", err)
+	}
+	if body.Data.AbuseConfidenceScore == nil {
+		return 0, fmt.Errorf("abuseipdb: response has no abuse confidence score")
+	}
+	return *body.Data.AbuseConfidenceScore, nil
+}
@@ -0,0 +1,111 @@
+package reputation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+)
+
+// DefaultBlocklistRefreshInterval is how often a ManagedBlocklist reloads
+// the stored networks.
+const DefaultBlocklistRefreshInterval = 30 * time.Second
+
+// BlocklistStore reads the networks blocked through the admin API.
+type BlocklistStore interface {
+	ListBlockedNetworks(ctx context.Context) ([]sqlc.BlockedNetwork, error)
+}
+
+// ManagedBlocklist holds the networks operators block through the admin API.
+// A Checker denies them ahead of its provider and cache, so a block applies
+// at once on the instance that stored it and on the others at their next
+// refresh. A nil ManagedBlocklist blocks nothing.
+type ManagedBlocklist struct {
+	store BlocklistStore
+
+	mu       sync.RWMutex
+	networks []netip.Prefix
+}
+
+func NewManagedBlocklist(store BlocklistStore) *ManagedBlocklist {
+	return &ManagedBlocklist{store: store}
+}
+
+// Contains reports whether ip is in a blocked network.
+func (b *ManagedBlocklist) Contains(ip netip.Addr) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, network := range b.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh replaces the blocked networks with those in the store.
+func (b *ManagedBlocklist) Refresh(ctx context.Context) error {
+	stored, err := b.store.ListBlockedNetworks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list blocked networks: %w", err)
+	}
+
+	networks := make([]netip.Prefix, len(stored))
+	for i, n := range stored {
+		networks[i] = n.Network
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.networks = networks
+	return nil
+}
+
+// Start refreshes the blocked networks every interval until ctx is done. A
+// failed refresh keeps the previous networks.
+func (b *ManagedBlocklist) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.Refresh(ctx); err != nil && ctx.Err() == nil {
+					slog.Warn("failed to refresh blocked networks",
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// ParseNetwork reads a CIDR, or a single address as the network of just
+// that address.
+func ParseNetwork(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,338 @@
+// Package reputation looks up how abusive client IPs are known to be, so
+// requests from bad networks can be denied, challenged or rate limited
+// harder. Lookups are cached, and fail open: a provider that errors or times
+// out lets the request through.
+package reputation
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/httpclient"
+)
+
+// Action is what should happen to a request from an IP.
+type Action int
+
+const (
+	Allow Action = iota
+	// Throttle holds the IP to a fraction of the usual rate limits.
+	Throttle
+	Challenge
+	Deny
+)
+
+func (a Action) String() string {
+	switch a {
+	case Throttle:
+		return "throttle"
+	case Challenge:
+		return "challenge"
+	case Deny:
+		return "deny"
+	default:
+		return "allow"
+	}
+}
+
+// verdicts counts checks by action, failed lookups under "error" and checks
+// answered from the cache under "cache_hit".
+var verdicts = expvar.NewMap("ip_reputation")
+
+// Provider rates an IP from 0, nothing known against it, to 100, certainly
+// abusive. Implementations must be safe for concurrent use.
+type Provider interface {
+	Score(ctx context.Context, ip netip.Addr) (int, error)
+}
+
+// Thresholds are the scores from which each action applies, the strictest
+// matching action winning. Zero disables an action.
+type Thresholds struct {
+	Throttle  int
+	Challenge int
+	Deny      int
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{Throttle: 25, Challenge: 50, Deny: 75}
+}
+
+func (t Thresholds) action(score int) Action {
+	switch {
+	case t.Deny > 0 && score >= t.Deny:
+		return Deny
+	case t.Challenge > 0 && score >= t.Challenge:
+		return Challenge
+	case t.Throttle > 0 && score >= t.Throttle:
+		return Throttle
+	default:
+		return Allow
+	}
+}
+
+// Config tunes a Checker.
+type Config struct {
+	Thresholds Thresholds
+	// CacheTTL is how long a score is reused before the provider is asked
+	// again.
+	CacheTTL time.Duration
+	// Timeout bounds each provider lookup.
+	Timeout time.Duration
+	// ThrottleFactor divides the rate limits of throttled IPs.
+	ThrottleFactor int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Thresholds:     DefaultThresholds(),
+		CacheTTL:       time.Hour,
+		Timeout:        500 * time.Millisecond,
+		ThrottleFactor: 10,
+	}
+}
+
+// failureTTL is how long a failed lookup is remembered, so a provider that
+// is down is not asked again on every request.
+const failureTTL = time.Minute
+
+// maxCachedIPs bounds the cache; beyond it expired entries are swept.
+const maxCachedIPs = 10000
+
+type entry struct {
+	action  Action
+	expires time.Time
+}
+
+// Checker turns provider scores into actions. A nil Checker allows every
+// request.
+type Checker struct {
+	provider  Provider
+	blocklist *ManagedBlocklist
+	cfg       Config
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[netip.Addr]entry
+}
+
+// NewChecker returns a Checker scoring IPs with p. With a nil p only the
+// blocklist set by WithBlocklist is checked.
+func NewChecker(p Provider, cfg Config) *Checker {
+	return &Checker{
+		provider: p,
+		cfg:      cfg,
+		now:      time.Now,
+		cache:    make(map[netip.Addr]entry),
+	}
+}
+
+// WithBlocklist denies the networks of b without asking the provider.
+func (c *Checker) WithBlocklist(b *ManagedBlocklist) *Checker {
+	c.blocklist = b
+	return c
+}
+
+// ThrottleFactor is what the rate limits of throttled IPs are divided by.
+func (c *Checker) ThrottleFactor() int {
+	return c.cfg.ThrottleFactor
+}
+
+// Check returns the action for requests from ip. Blocked networks are
+// denied first; private and loopback addresses are never looked up.
+func (c *Checker) Check(ctx context.Context, ip netip.Addr) Action {
+	if c == nil {
+		return Allow
+	}
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return Allow
+	}
+	if c.blocklist.Contains(ip) {
+		verdicts.Add(Deny.String(), 1)
+		return Deny
+	}
+	if c.provider == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return Allow
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	cached, ok := c.cache[ip]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		verdicts.Add("cache_hit", 1)
+		verdicts.Add(cached.action.String(), 1)
+		return cached.action
+	}
+
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cfg.Timeout)
+	defer cancel()
+	score, err := c.provider.Score(lookupCtx, ip)
+	if err != nil {
+		verdicts.Add("error", 1)
+		slog.Warn("IP reputation lookup failed, allowing request",
+			slog.String("error", err.Error()),
+			slog.String("ip", ip.String()),
+		)
+		c.store(ip, Allow, now.Add(min(failureTTL, c.cfg.CacheTTL)))
+		return Allow
+	}
+
+	action := c.cfg.Thresholds.action(score)
+	verdicts.Add(action.String(), 1)
+	if action != Allow {
+		slog.Info("IP reputation flagged client",
+			slog.String("ip", ip.String()),
+			slog.Int("score", score),
+			slog.String("action", action.String()),
+		)
+	}
+	c.store(ip, action, now.Add(c.cfg.CacheTTL))
+	return action
+}
+
+func (c *Checker) store(ip netip.Addr, action Action, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= maxCachedIPs {
+		now := c.now()
+		for addr, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, addr)
+			}
+		}
+		// Every entry is still fresh: start over rather than grow unbounded
+		if len(c.cache) >= maxCachedIPs {
+			clear(c.cache)
+		}
+	}
+	c.cache[ip] = entry{action: action, expires: expires}
+}
+
+// Multi asks every provider and takes the highest score. A provider that
+// fails is skipped unless all of them fail.
+type Multi []Provider
+
+func (m Multi) Score(ctx context.Context, ip netip.Addr) (int, error) {
+	best, failed := 0, 0
+	var lastErr error
+	for _, p := range m {
+		score, err := p.Score(ctx, ip)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		best = max(best, score)
+	}
+	if failed == len(m) && lastErr != nil {
+		return 0, lastErr
+	}
+	return best, nil
+}
+
+// Blocklist scores the IPs in its networks 100 and every other IP 0.
+type Blocklist []netip.Prefix
+
+func (b Blocklist) Score(_ context.Context, ip netip.Addr) (int, error) {
+	for _, prefix := range b {
+		if prefix.Contains(ip) {
+			return 100, nil
+		}
+	}
+	return 0, nil
+}
+
+// FromEnv builds a Checker from IP_REPUTATION_BLOCKLIST, a comma-separated
+// list of CIDRs or addresses, and ABUSEIPDB_API_KEY, tuned by the other
+// IP_REPUTATION_* variables. AbuseIPDB is called through pool. It returns nil
+// when neither is set.
+func FromEnv(pool *httpclient.Pool) (*Checker, error) {
+	var providers Multi
+	if v := os.Getenv("IP_REPUTATION_BLOCKLIST"); v != "" {
+		prefixes, err := parsePrefixes(v)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, Blocklist(prefixes))
+	}
+	if key := os.Getenv("ABUSEIPDB_API_KEY"); key != "" {
+		abuseIPDB := NewAbuseIPDB(key)
+		abuseIPDB.HTTP = pool.Client(0)
+		providers = append(providers, abuseIPDB)
+	}
+	if len(providers) == 0 {
+		return nil, nil
+	}
+
+	cfg := DefaultConfig()
+	ints := []struct {
+		key      string
+		dst      *int
+		min, max int
+	}{
+		{"IP_REPUTATION_THROTTLE_SCORE", &cfg.Thresholds.Throttle, 0, 100},
+		{"IP_REPUTATION_CHALLENGE_SCORE", &cfg.Thresholds.Challenge, 0, 100},
+		{"IP_REPUTATION_DENY_SCORE", &cfg.Thresholds.Deny, 0, 100},
+		{"IP_REPUTATION_THROTTLE_FACTOR", &cfg.ThrottleFactor, 1, 1000},
+	}
+	for _, i := range ints {
+		if v := os.Getenv(i.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < i.min || n > i.max {
+				return nil, fmt.Errorf("%s must be between %d and %d", i.key, i.min, i.max)
+			}
+			*i.dst = n
+		}
+	}
+
+	durations := []struct {
+		key  string
+		dst  *time.Duration
+		unit time.Duration
+	}{
+		{"IP_REPUTATION_CACHE_SECONDS", &cfg.CacheTTL, time.Second},
+		{"IP_REPUTATION_TIMEOUT_MS", &cfg.Timeout, time.Millisecond},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", d.key)
+			}
+			*d.dst = time.Duration(n) * d.unit
+		}
+	}
+
+	var p Provider = providers
+	if len(providers) == 1 {
+		p = providers[0]
+	}
+	return NewChecker(p, cfg), nil
+}
+
+// parsePrefixes reads a comma-separated list of CIDRs or single addresses.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		prefix, err := ParseNetwork(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP_REPUTATION_BLOCKLIST entry %q: %w", field, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,239 @@
+package reputation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider returns fixed scores by IP and counts lookups.
+type stubProvider struct {
+	scores  map[string]int
+	err     error
+	lookups atomic.Int32
+}
+
+func (p *stubProvider) Score(_ context.Context, ip netip.Addr) (int, error) {
+	p.lookups.Add(1)
+	return p.scores[ip.String()], p.err
+}
+
+func TestChecker_Actions(t *testing.T) {
+	provider := &stubProvider{scores: map[string]int{
+		"203.0.113.1": 10,
+		"203.0.113.2": 30,
+		"203.0.113.3": 60,
+		"203.0.113.4": 90,
+	}}
+	c := NewChecker(provider, DefaultConfig())
+
+	tests := []struct {
+		ip   string
+		want Action
+	}{
+		{"203.0.113.1", Allow},
+		{"203.0.113.2", Throttle},
+		{"203.0.113.3", Challenge},
+		{"203.0.113.4", Deny},
+		{"::ffff:203.0.113.4", Deny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.Check(context.Background(), netip.MustParseAddr(tt.ip)))
+		})
+	}
+}
+
+func TestChecker_DisabledThreshold(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Thresholds.Deny = 0
+	c := NewChecker(&stubProvider{scores: map[string]int{"203.0.113.4": 100}}, cfg)
+
+	assert.Equal(t, Challenge, c.Check(context.Background(), netip.MustParseAddr("203.0.113.4")))
+}
+
+func TestChecker_CachesLookups(t *testing.T) {
+	provider := &stubProvider{scores: map[string]int{"203.0.113.4": 90}}
+	c := NewChecker(provider, DefaultConfig())
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ip := netip.MustParseAddr("203.0.113.4")
+
+	c.Check(context.Background(), ip)
+	c.Check(context.Background(), ip)
+	assert.Equal(t, int32(1), provider.lookups.Load())
+
+	now = now.Add(DefaultConfig().CacheTTL)
+	c.Check(context.Background(), ip)
+	assert.Equal(t, int32(2), provider.lookups.Load())
+}
+
+func TestChecker_FailsOpen(t *testing.T) {
+	provider := &stubProvider{scores: map[string]int{"203.0.113.4": 90}, err: errors.New("provider down")}
+	c := NewChecker(provider, DefaultConfig())
+	ip := netip.MustParseAddr("203.0.113.4")
+
+	assert.Equal(t, Allow, c.Check(context.Background(), ip))
+	// The failure is remembered briefly instead of retried on every request
+	assert.Equal(t, Allow, c.Check(context.Background(), ip))
+	assert.Equal(t, int32(1), provider.lookups.Load())
+}
+
+func TestChecker_SkipsPrivateAddresses(t *testing.T) {
+	provider := &stubProvider{}
+	c := NewChecker(provider, DefaultConfig())
+
+	for _, ip := range []string{"10.0.0.1", "127.0.0.1", "::1", "fe80::1"} {
+		assert.Equal(t, Allow, c.Check(context.Background(), netip.MustParseAddr(ip)))
+	}
+	assert.Zero(t, provider.lookups.Load())
+
+	var nilChecker *Checker
+	assert.Equal(t, Allow, nilChecker.Check(context.Background(), netip.MustParseAddr("203.0.113.4")))
+}
+
+// stubBlocklistStore returns fixed blocked networks.
+type stubBlocklistStore struct {
+	networks []sqlc.BlockedNetwork
+	err      error
+}
+
+func (s *stubBlocklistStore) ListBlockedNetworks(context.Context) ([]sqlc.BlockedNetwork, error) {
+	return s.networks, s.err
+}
+
+func TestChecker_ManagedBlocklist(t *testing.T) {
+	store := &stubBlocklistStore{networks: []sqlc.BlockedNetwork{
+		{Network: netip.MustParsePrefix("198.51.100.0/24")},
+		{Network: netip.MustParsePrefix("10.1.2.3/32")},
+	}}
+	blocklist := NewManagedBlocklist(store)
+	require.NoError(t, blocklist.Refresh(context.Background()))
+
+	provider := &stubProvider{}
+	c := NewChecker(provider, DefaultConfig()).WithBlocklist(blocklist)
+	assert.Equal(t, Deny, c.Check(context.Background(), netip.MustParseAddr("198.51.100.7")))
+	// Blocking a private address is an explicit choice and applies too
+	assert.Equal(t, Deny, c.Check(context.Background(), netip.MustParseAddr("10.1.2.3")))
+	assert.Zero(t, provider.lookups.Load())
+	assert.Equal(t, Allow, c.Check(context.Background(), netip.MustParseAddr("203.0.113.9")))
+
+	// A failed refresh keeps the networks loaded before
+	store.err = errors.New("database down")
+	assert.Error(t, blocklist.Refresh(context.Background()))
+	assert.True(t, blocklist.Contains(netip.MustParseAddr("198.51.100.7")))
+
+	store.err, store.networks = nil, nil
+	require.NoError(t, blocklist.Refresh(context.Background()))
+	assert.Equal(t, Allow, c.Check(context.Background(), netip.MustParseAddr("198.51.100.7")))
+}
+
+func TestChecker_BlocklistOnly(t *testing.T) {
+	blocklist := NewManagedBlocklist(&stubBlocklistStore{networks: []sqlc.BlockedNetwork{
+		{Network: netip.MustParsePrefix("198.51.100.0/24")},
+	}})
+	require.NoError(t, blocklist.Refresh(context.Background()))
+	c := NewChecker(nil, DefaultConfig()).WithBlocklist(blocklist)
+
+	assert.Equal(t, Deny, c.Check(context.Background(), netip.MustParseAddr("198.51.100.7")))
+	assert.Equal(t, Allow, c.Check(context.Background(), netip.MustParseAddr("203.0.113.9")))
+
+	var nilBlocklist *ManagedBlocklist
+	assert.False(t, nilBlocklist.Contains(netip.MustParseAddr("198.51.100.7")))
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "203.0.113.7", want: "203.0.113.7/32"},
+		{in: "::ffff:203.0.113.7", want: "203.0.113.7/32"},
+		{in: "203.0.113.77/24", want: "203.0.113.0/24"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		prefix, err := ParseNetwork(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, prefix.String())
+	}
+
+	for _, bad := range []string{"", "example.com", "203.0.113.0/33"} {
+		_, err := ParseNetwork(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	ip := netip.MustParseAddr("203.0.113.4")
+	blocklist := Blocklist{netip.MustParsePrefix("203.0.113.0/24")}
+	failing := &stubProvider{err: errors.New("provider down")}
+
+	score, err := Multi{failing, blocklist}.Score(context.Background(), ip)
+	require.NoError(t, err)
+	assert.Equal(t, 100, score)
+
+	_, err = Multi{failing}.Score(context.Background(), ip)
+	assert.Error(t, err)
+}
+
+func TestAbuseIPDB(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/api/v2/check", r.URL.Path)
+		assert.Equal(t, "203.0.113.4", r.URL.Query().Get("ipAddress"))
+		w.Write([]byte(`{"data":{"ipAddress":"203.0.113.4","abuseConfidenceScore":87}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	provider := NewAbuseIPDB("test-key")
+	provider.BaseURL = srv.URL
+	score, err := provider.Score(context.Background(), netip.MustParseAddr("203.0.113.4"))
+	require.NoError(t, err)
+	assert.Equal(t, 87, score)
+
+	provider.APIKey = "wrong-key"
+	_, err = provider.Score(context.Background(), netip.MustParseAddr("203.0.113.4"))
+	assert.Error(t, err)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("IP_REPUTATION_BLOCKLIST", "")
+	t.Setenv("ABUSEIPDB_API_KEY", "")
+	c, err := FromEnv(nil)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	t.Setenv("IP_REPUTATION_BLOCKLIST", "198.51.100.0/24, 203.0.113.9")
+	t.Setenv("IP_REPUTATION_THROTTLE_FACTOR", "4")
+	c, err = FromEnv(nil)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, 4, c.ThrottleFactor())
+	assert.Equal(t, Deny, c.Check(context.Background(), netip.MustParseAddr("203.0.113.9")))
+	assert.Equal(t, Allow, c.Check(context.Background(), netip.MustParseAddr("203.0.113.10")))
+
+	for key, value := range map[string]string{
+		"IP_REPUTATION_BLOCKLIST":     "example.com",
+		"IP_REPUTATION_DENY_SCORE":    "101",
+		"IP_REPUTATION_CACHE_SECONDS": "0",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := FromEnv(nil)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -2,15 +2,71 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/ilkin0/gzln/internal/api/types"
 	"github.com/ilkin0/gzln/internal/service"
 )
 
+// reportCheckInterval is how often the scheduler looks for a month without a
+// retention report. Checking hourly rather than once a month lets a report
+// missed while the server was down be generated soon after it restarts.
+const reportCheckInterval = time.Hour
+
+// transferFlushInterval is how often the bytes served are written to the
+// transfer stats. A crash loses at most this much of them.
+const transferFlushInterval = time.Minute
+
+// cleaner, reporter, reconciler and flusher are the jobs the scheduler runs.
+type cleaner interface {
+	CleanupExpiredFiles(ctx context.Context) (int, error)
+}
+
+type reporter interface {
+	GenerateDueReport(ctx context.Context) (bool, error)
+}
+
+type reconciler interface {
+	Reconcile(ctx context.Context) (service.ReconcileReport, error)
+}
+
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
 type Scheduler struct {
-	cleanupService *service.CleanupService
-	interval       time.Duration
+	cleanupService   cleaner
+	retentionService reporter
+	reconciler       reconciler
+	transferStats    flusher
+	interval         time.Duration
+	reconcileEvery   time.Duration
+	wg               sync.WaitGroup
+
+	// stop ends the job loops. cancelJobs aborts a job that is still running
+	// when Stop gives up waiting for it.
+	stop       chan struct{}
+	stopOnce   sync.Once
+	cancelJobs context.CancelFunc
+
+	// mu guards jobs, the state of each job started, in start order.
+	mu   sync.Mutex
+	jobs []*jobState
+}
+
+// jobState is what the admin API reports about a job.
+type jobState struct {
+	name         string
+	interval     time.Duration
+	running      bool
+	runs         int64
+	failures     int64
+	lastStarted  time.Time
+	lastFinished time.Time
+	lastErr      error
 }
 
 func New(cleanupService *service.CleanupService, interval time.Duration) *Scheduler {
@@ -20,36 +76,215 @@ func New(cleanupService *service.CleanupService, interval time.Duration) *Schedu
 	}
 }
 
+// WithRetentionReports generates the retention report of each month once it
+// is over.
+func (s *Scheduler) WithRetentionReports(retentionService *service.RetentionService) *Scheduler {
+	if retentionService != nil {
+		s.retentionService = retentionService
+	}
+	return s
+}
+
+// WithReconciliation reconciles storage with the database every interval.
+// A zero interval disables it.
+func (s *Scheduler) WithReconciliation(cleanupService *service.CleanupService, interval time.Duration) *Scheduler {
+	if interval > 0 {
+		s.reconciler = cleanupService
+		s.reconcileEvery = interval
+	}
+	return s
+}
+
+// WithTransferStats writes the bytes served to the transfer stats every
+// minute.
+func (s *Scheduler) WithTransferStats(stats *service.TransferStats) *Scheduler {
+	if stats != nil {
+		s.transferStats = stats
+	}
+	return s
+}
+
+// Start runs the jobs until ctx is done or Stop is called. Jobs run on a
+// context of their own, so neither cuts a running cleanup short mid-batch.
 func (s *Scheduler) Start(ctx context.Context) {
 	slog.Info("scheduler started", slog.Duration("interval", s.interval))
-	go s.runCleanupJob(ctx)
+	s.stop = make(chan struct{})
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancelJobs = cancel
+
+	s.start(ctx, jobCtx, "cleanup", s.interval, s.executeCleanup)
+	if s.retentionService != nil {
+		s.start(ctx, jobCtx, "retention_report", reportCheckInterval, s.executeReport)
+	}
+	if s.reconciler != nil {
+		s.start(ctx, jobCtx, "reconciliation", s.reconcileEvery, s.executeReconcile)
+	}
+	if s.transferStats != nil {
+		s.start(ctx, jobCtx, "transfer_flush", transferFlushInterval, s.executeTransferFlush)
+	}
+}
+
+func (s *Scheduler) start(ctx, jobCtx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	state := &jobState{name: name, interval: interval}
+	s.mu.Lock()
+	s.jobs = append(s.jobs, state)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run(ctx, jobCtx, interval, func(ctx context.Context) {
+		s.track(ctx, state, job)
+	})
 }
 
-func (s *Scheduler) runCleanupJob(ctx context.Context) {
-	s.executeCleanup(ctx)
+// track runs job, keeping its state up to date for Jobs.
+func (s *Scheduler) track(ctx context.Context, state *jobState, job func(context.Context) error) {
+	s.mu.Lock()
+	state.running = true
+	state.lastStarted = time.Now()
+	s.mu.Unlock()
 
-	ticker := time.NewTicker(s.interval)
+	err := job(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.running = false
+	state.lastFinished = time.Now()
+	state.runs++
+	state.lastErr = err
+	if err != nil {
+		state.failures++
+	}
+}
+
+// Jobs returns the state of the jobs running on this instance, in the order
+// they were started.
+func (s *Scheduler) Jobs() []types.JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]types.JobStatus, len(s.jobs))
+	for i, state := range s.jobs {
+		jobs[i] = types.JobStatus{
+			Name:            state.name,
+			IntervalSeconds: int64(state.interval / time.Second),
+			Running:         state.running,
+			Runs:            state.runs,
+			Failures:        state.failures,
+			LastStarted:     formatTime(state.lastStarted),
+			LastFinished:    formatTime(state.lastFinished),
+		}
+		if state.lastErr != nil {
+			jobs[i].LastError = state.lastErr.Error()
+		}
+	}
+	return jobs
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Stop ends the job loops and waits for a job in progress to finish. If ctx
+// is done first, the job is cancelled and Stop returns once it has given up.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	defer s.cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		slog.Warn("scheduler stop timed out, cancelling running job")
+		s.cancelJobs()
+		<-done
+		return fmt.Errorf("scheduler stop: %w", ctx.Err())
+	}
+}
+
+// Wait blocks until the scheduler has stopped after its context was
+// cancelled, letting in-progress jobs finish.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// run executes job at once and then every interval until ctx is done or the
+// scheduler is stopped. A job in progress is never interrupted by either.
+func (s *Scheduler) run(ctx, jobCtx context.Context, interval time.Duration, job func(context.Context)) {
+	defer s.wg.Done()
+
+	job(jobCtx)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			s.executeCleanup(ctx)
+			job(jobCtx)
 		case <-ctx.Done():
-			slog.Info("scheduler stopped")
+			return
+		case <-s.stop:
 			return
 		}
 	}
 }
 
-func (s *Scheduler) executeCleanup(ctx context.Context) {
+func (s *Scheduler) executeCleanup(ctx context.Context) error {
 	deleted, err := s.cleanupService.CleanupExpiredFiles(ctx)
 	if err != nil {
 		slog.Error("cleanup job failed", slog.String("error", err.Error()))
-		return
+		return err
 	}
 
 	if deleted > 0 {
 		slog.Info("cleanup job completed", slog.Int("deleted_files", deleted))
 	}
+	return nil
+}
+
+func (s *Scheduler) executeReport(ctx context.Context) error {
+	if _, err := s.retentionService.GenerateDueReport(ctx); err != nil {
+		slog.Error("retention report job failed", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+func (s *Scheduler) executeReconcile(ctx context.Context) error {
+	report, err := s.reconciler.Reconcile(ctx)
+	if err != nil {
+		slog.Error("reconciliation job failed", slog.String("error", err.Error()))
+		return err
+	}
+
+	if report.OrphanedObjects > 0 || report.MissingObjects > 0 {
+		slog.Warn("reconciliation found storage drift",
+			slog.Int("orphaned_objects", report.OrphanedObjects),
+			slog.Int("missing_objects", report.MissingObjects),
+			slog.Int("removed_objects", report.RemovedObjects),
+			slog.Int("removed_rows", report.RemovedRows),
+		)
+	}
+	return nil
+}
+
+func (s *Scheduler) executeTransferFlush(ctx context.Context) error {
+	if err := s.transferStats.Flush(ctx); err != nil {
+		slog.Warn("transfer stats flush failed, retrying next interval", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
 }
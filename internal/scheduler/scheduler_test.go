@@ -2,10 +2,12 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ilkin0/gzln/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -193,3 +195,151 @@ func TestScheduler_ContinuesAfterError(t *testing.T) {
 	count := executionCount.Load()
 	assert.GreaterOrEqual(t, count, int32(2), "Scheduler should continue after error")
 }
+
+func TestScheduler_WaitReturnsAfterCancel(t *testing.T) {
+	s := New(nil, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+	}()
+
+	waited := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after context cancel")
+	}
+}
+
+func TestScheduler_WaitWithoutStart(t *testing.T) {
+	s := New(nil, time.Hour)
+	s.Wait()
+}
+
+// blockingCleaner holds each cleanup until release is closed or its context
+// is cancelled.
+type blockingCleaner struct {
+	started   chan struct{}
+	release   chan struct{}
+	cancelled atomic.Bool
+	finished  atomic.Bool
+}
+
+func (b *blockingCleaner) CleanupExpiredFiles(ctx context.Context) (int, error) {
+	close(b.started)
+	select {
+	case <-b.release:
+		b.finished.Store(true)
+		return 0, nil
+	case <-ctx.Done():
+		b.cancelled.Store(true)
+		return 0, ctx.Err()
+	}
+}
+
+func TestScheduler_StopWaitsForRunningJob(t *testing.T) {
+	cleaner := &blockingCleaner{started: make(chan struct{}), release: make(chan struct{})}
+	s := &Scheduler{cleanupService: cleaner, interval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	<-cleaner.started
+
+	// Cancelling the start context must not cut the cleanup short
+	cancel()
+	time.AfterFunc(50*time.Millisecond, func() { close(cleaner.release) })
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	require.NoError(t, s.Stop(stopCtx))
+
+	assert.True(t, cleaner.finished.Load())
+	assert.False(t, cleaner.cancelled.Load())
+}
+
+func TestScheduler_StopCancelsJobAfterTimeout(t *testing.T) {
+	cleaner := &blockingCleaner{started: make(chan struct{}), release: make(chan struct{})}
+	s := &Scheduler{cleanupService: cleaner, interval: time.Hour}
+
+	s.Start(context.Background())
+	<-cleaner.started
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stopCancel()
+	err := s.Stop(stopCtx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, cleaner.cancelled.Load())
+}
+
+func TestScheduler_StopWithoutStart(t *testing.T) {
+	s := New(nil, time.Hour)
+	assert.NoError(t, s.Stop(context.Background()))
+}
+
+type countingReconciler struct {
+	calls atomic.Int32
+}
+
+func (c *countingReconciler) Reconcile(ctx context.Context) (service.ReconcileReport, error) {
+	c.calls.Add(1)
+	return service.ReconcileReport{}, nil
+}
+
+func TestScheduler_RunsReconciliation(t *testing.T) {
+	reconciler := &countingReconciler{}
+	s := &Scheduler{
+		cleanupService: &MockCleanupService{},
+		reconciler:     reconciler,
+		interval:       time.Hour,
+		reconcileEvery: 20 * time.Millisecond,
+	}
+
+	s.Start(context.Background())
+	assert.Eventually(t, func() bool { return reconciler.calls.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+func TestScheduler_ReconciliationDisabled(t *testing.T) {
+	s := New(nil, time.Hour).WithReconciliation(nil, 0)
+	assert.Nil(t, s.reconciler)
+}
+
+func TestScheduler_Jobs(t *testing.T) {
+	s := &Scheduler{
+		cleanupService: &MockCleanupService{cleanupError: errors.New("database down")},
+		reconciler:     &countingReconciler{},
+		interval:       time.Hour,
+		reconcileEvery: time.Hour,
+	}
+	assert.Empty(t, s.Jobs())
+
+	s.Start(context.Background())
+	assert.Eventually(t, func() bool {
+		jobs := s.Jobs()
+		return len(jobs) == 2 && jobs[0].Runs == 1 && jobs[1].Runs == 1
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+
+	jobs := s.Jobs()
+	assert.Equal(t, "cleanup", jobs[0].Name)
+	assert.Equal(t, int64(3600), jobs[0].IntervalSeconds)
+	assert.False(t, jobs[0].Running)
+	assert.Equal(t, int64(1), jobs[0].Failures)
+	assert.Equal(t, "database down", jobs[0].LastError)
+	assert.NotEmpty(t, jobs[0].LastFinished)
+
+	assert.Equal(t, "reconciliation", jobs[1].Name)
+	assert.Zero(t, jobs[1].Failures)
+	assert.Empty(t, jobs[1].LastError)
+}
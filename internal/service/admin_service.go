@@ -0,0 +1,815 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/internal/reputation"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// MaxAdminNotesLength bounds the size of a file's admin notes in bytes.
+	MaxAdminNotesLength = 10_000
+	// MaxWatermarkLength bounds the size of a share's watermark in bytes.
+	MaxWatermarkLength = 500
+
+	AuditActionAdminNotesUpdated = "admin_notes.updated"
+	AuditActionAdminExpired      = "admin.expired"
+	AuditActionFeatureFlagSet    = "feature_flag.set"
+	AuditActionWatermarkSet      = "watermark.set"
+	AuditActionDownloadRateSet   = "download_rate.set"
+	AuditActionReadOnlySet       = "read_only.set"
+	AuditActionNetworkBlocked    = "blocklist.added"
+	AuditActionNetworkUnblocked  = "blocklist.removed"
+
+	// MaxBlockReasonLength bounds the reason given for blocking a network.
+	MaxBlockReasonLength = 500
+
+	// DefaultAdminListLimit and MaxAdminListLimit bound a page of the admin
+	// file list.
+	DefaultAdminListLimit = 50
+	MaxAdminListLimit     = 500
+
+	// DefaultTransferDays and MaxTransferDays bound the days transfer totals
+	// cover, and DefaultTransferTopFiles the files they list by default.
+	DefaultTransferDays     = 30
+	MaxTransferDays         = 366
+	DefaultTransferTopFiles = 10
+)
+
+var (
+	ErrAdminNotesTooLong   = errors.New("admin notes too long")
+	ErrInvalidAdminFilter  = errors.New("invalid file filter")
+	ErrWatermarkTooLong    = errors.New("watermark too long")
+	ErrInvalidDownloadRate = errors.New("download rate must not be negative")
+	ErrWatermarkingOff     = errors.New("watermarking is not enabled")
+	// ErrNotServerEncrypted means a share was uploaded end-to-end
+	// encrypted, so the server cannot transform it.
+	ErrNotServerEncrypted = errors.New("share is not server encrypted")
+	ErrInvalidNetwork     = errors.New("network must be a CIDR or an IP address")
+	ErrBlockReasonTooLong = errors.New("block reason too long")
+)
+
+// fileStatuses are the statuses a file moves through.
+var fileStatuses = []string{"uploading", FileStatusVerifying, "ready", "exhausted", "expired", FileStatusCorrupt, FileStatusRevoked}
+
+type AdminService struct {
+	repository sqlc.Querier
+	runTx      database.TxRunner
+	events     *events.Bus
+	flags      *flags.Provider
+	readOnly   *readonly.Switch
+	// watermarking is set when downloads can be watermarked
+	watermarking bool
+	// previewSecret signs support preview tokens; nil disables them
+	previewSecret []byte
+	previewTTL    time.Duration
+	blocklist     *reputation.ManagedBlocklist
+	jobs          JobReporter
+	files         *FileService
+}
+
+// JobReporter reports the scheduled jobs of this instance.
+type JobReporter interface {
+	Jobs() []types.JobStatus
+}
+
+func NewAdminService(repository sqlc.Querier, runTx database.TxRunner) *AdminService {
+	return &AdminService{
+		repository: repository,
+		runTx:      runTx,
+	}
+}
+
+// WithEvents publishes shares expired by an admin to bus as revoked.
+func (s *AdminService) WithEvents(bus *events.Bus) *AdminService {
+	s.events = bus
+	return s
+}
+
+// WithFlags lets the admin API list and toggle feature flags.
+func (s *AdminService) WithFlags(p *flags.Provider) *AdminService {
+	s.flags = p
+	return s
+}
+
+// WithReadOnly lets the admin API switch read-only mode on and off.
+func (s *AdminService) WithReadOnly(sw *readonly.Switch) *AdminService {
+	s.readOnly = sw
+	return s
+}
+
+// WithBlocklist lets the admin API block networks, applying changes to b on
+// this instance at once.
+func (s *AdminService) WithBlocklist(b *reputation.ManagedBlocklist) *AdminService {
+	s.blocklist = b
+	return s
+}
+
+// WithJobs lets the admin API report the scheduled jobs and the background
+// verifications of files.
+func (s *AdminService) WithJobs(jobs JobReporter, files *FileService) *AdminService {
+	s.jobs = jobs
+	s.files = files
+	return s
+}
+
+// WithWatermarking lets watermarks be set on server encrypted shares.
+func (s *AdminService) WithWatermarking(enabled bool) *AdminService {
+	s.watermarking = enabled
+	return s
+}
+
+type adminNotesChange struct {
+	Previous string `json:"previous"`
+	Notes    string `json:"notes"`
+}
+
+// UpdateAdminNotes replaces the admin notes of a file and records the change
+// in the audit log. Admin notes are never included in public responses.
+func (s *AdminService) UpdateAdminNotes(ctx context.Context, shareID, notes, actor string) error {
+	if len(notes) > MaxAdminNotesLength {
+		return ErrAdminNotesTooLong
+	}
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	details, err := json.Marshal(adminNotesChange{
+		Previous: file.AdminNotes.String,
+		Notes:    notes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		_, err := q.UpdateFileAdminNotes(ctx, sqlc.UpdateFileAdminNotesParams{
+			ID:         file.ID,
+			AdminNotes: pgtype.Text{String: notes, Valid: notes != ""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update admin notes: %w", err)
+		}
+
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  file.ID,
+			Action:  AuditActionAdminNotesUpdated,
+			Actor:   actor,
+			Details: details,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to update admin notes",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return err
+	}
+
+	slog.Info("admin notes updated",
+		slog.String("share_id", shareID),
+		slog.String("actor", actor),
+	)
+	return nil
+}
+
+type watermarkChange struct {
+	Watermark string `json:"watermark"`
+}
+
+// SetWatermark sets the text downloads of a server encrypted share are
+// watermarked with, or removes it when text is empty, and records the
+// change in the audit log. Once set, the share is only served watermarked.
+func (s *AdminService) SetWatermark(ctx context.Context, shareID, text, actor string) error {
+	if len(text) > MaxWatermarkLength {
+		return ErrWatermarkTooLong
+	}
+	if text != "" && !s.watermarking {
+		return ErrWatermarkingOff
+	}
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	details, err := json.Marshal(watermarkChange{Watermark: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		rows, err := q.UpdateServerEncryptedFileWatermark(ctx, sqlc.UpdateServerEncryptedFileWatermarkParams{
+			FileID:    file.ID,
+			Watermark: pgtype.Text{String: text, Valid: text != ""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update watermark: %w", err)
+		}
+		if rows == 0 {
+			return ErrNotServerEncrypted
+		}
+
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  file.ID,
+			Action:  AuditActionWatermarkSet,
+			Actor:   actor,
+			Details: details,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotServerEncrypted) {
+		return err
+	}
+	if err != nil {
+		slog.Error("failed to set watermark",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return err
+	}
+
+	slog.Info("watermark set",
+		slog.String("share_id", shareID),
+		slog.Bool("watermarked", text != ""),
+		slog.String("actor", actor),
+	)
+	return nil
+}
+
+type downloadRateChange struct {
+	Previous       *int64 `json:"previous"`
+	BytesPerSecond *int64 `json:"bytes_per_second"`
+}
+
+// SetDownloadRate overrides the global download rate of a file with
+// bytesPerSecond, or makes the file follow it again when nil, and records
+// the change in the audit log. Downloads already running keep their rate.
+func (s *AdminService) SetDownloadRate(ctx context.Context, shareID string, bytesPerSecond *int64, actor string) error {
+	if bytesPerSecond != nil && *bytesPerSecond < 0 {
+		return ErrInvalidDownloadRate
+	}
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	change := downloadRateChange{BytesPerSecond: bytesPerSecond}
+	if file.MaxDownloadRate.Valid {
+		change.Previous = &file.MaxDownloadRate.Int64
+	}
+	details, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	rate := pgtype.Int8{}
+	if bytesPerSecond != nil {
+		rate = pgtype.Int8{Int64: *bytesPerSecond, Valid: true}
+	}
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		rows, err := q.UpdateFileMaxDownloadRate(ctx, sqlc.UpdateFileMaxDownloadRateParams{
+			ID:              file.ID,
+			MaxDownloadRate: rate,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update download rate: %w", err)
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  file.ID,
+			Action:  AuditActionDownloadRateSet,
+			Actor:   actor,
+			Details: details,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		slog.Error("failed to set download rate",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return err
+	}
+
+	slog.Info("download rate set",
+		slog.String("share_id", shareID),
+		slog.Any("bytes_per_second", bytesPerSecond),
+		slog.String("actor", actor),
+	)
+	return nil
+}
+
+// GetAdminNotes returns a file's admin notes with the audit history of the
+// file.
+func (s *AdminService) GetAdminNotes(ctx context.Context, shareID string) (types.AdminNotesResponse, error) {
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.AdminNotesResponse{}, ErrNotFound
+		}
+		return types.AdminNotesResponse{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	entries, err := s.repository.ListAuditLogByFileId(ctx, file.ID)
+	if err != nil {
+		return types.AdminNotesResponse{}, fmt.Errorf("failed to list audit log: %w", err)
+	}
+
+	history := make([]types.AuditLogEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = types.AuditLogEntry{
+			Action:    entry.Action,
+			Actor:     entry.Actor,
+			Details:   entry.Details,
+			CreatedAt: formatTimestamptz(entry.CreatedAt),
+		}
+	}
+
+	return types.AdminNotesResponse{
+		ShareID: shareID,
+		Notes:   file.AdminNotes.String,
+		History: history,
+	}, nil
+}
+
+// ListFiles returns a page of files matching filter, newest first.
+func (s *AdminService) ListFiles(ctx context.Context, filter types.AdminFileFilter) (types.AdminFileList, error) {
+	params, err := adminFileParams(filter)
+	if err != nil {
+		return types.AdminFileList{}, err
+	}
+
+	rows, err := s.repository.ListAdminFiles(ctx, params)
+	if err != nil {
+		return types.AdminFileList{}, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	files := make([]types.AdminFile, len(rows))
+	for i, row := range rows {
+		files[i] = types.AdminFile{
+			ShareID:       row.ShareID,
+			Status:        row.Status,
+			TotalSize:     row.TotalSize,
+			ChunkCount:    row.ChunkCount,
+			DownloadCount: row.DownloadCount,
+			MaxDownloads:  row.MaxDownloads,
+			UploaderIP:    row.UploaderIp.String(),
+			CreatedAt:     formatTimestamptz(row.CreatedAt),
+			ExpiresAt:     formatTimestamptz(row.ExpiresAt),
+		}
+	}
+
+	return types.AdminFileList{
+		Files:  files,
+		Limit:  int(params.Limit),
+		Offset: int(params.Offset),
+	}, nil
+}
+
+func adminFileParams(filter types.AdminFileFilter) (sqlc.ListAdminFilesParams, error) {
+	var params sqlc.ListAdminFilesParams
+
+	if filter.Status != "" {
+		if !slices.Contains(fileStatuses, filter.Status) {
+			return params, fmt.Errorf("%w: unknown status %q", ErrInvalidAdminFilter, filter.Status)
+		}
+		params.Status = pgtype.Text{String: filter.Status, Valid: true}
+	}
+	if filter.MinSize < 0 || filter.MaxSize < 0 {
+		return params, fmt.Errorf("%w: sizes must not be negative", ErrInvalidAdminFilter)
+	}
+	if filter.MinSize > 0 {
+		params.MinSize = pgtype.Int8{Int64: filter.MinSize, Valid: true}
+	}
+	if filter.MaxSize > 0 {
+		params.MaxSize = pgtype.Int8{Int64: filter.MaxSize, Valid: true}
+	}
+	if filter.UploaderIP != "" {
+		ip, err := netip.ParseAddr(filter.UploaderIP)
+		if err != nil {
+			return params, fmt.Errorf("%w: invalid uploader IP", ErrInvalidAdminFilter)
+		}
+		params.UploaderIp = &ip
+	}
+
+	switch {
+	case filter.Limit == 0:
+		params.Limit = DefaultAdminListLimit
+	case filter.Limit < 0 || filter.Limit > MaxAdminListLimit:
+		return params, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAdminFilter, MaxAdminListLimit)
+	default:
+		params.Limit = int32(filter.Limit)
+	}
+	if filter.Offset < 0 {
+		return params, fmt.Errorf("%w: offset must not be negative", ErrInvalidAdminFilter)
+	}
+	params.Offset = int32(filter.Offset)
+
+	return params, nil
+}
+
+// ForceExpire ends a share at once. Downloads stop immediately and the next
+// cleanup deletes its chunks.
+func (s *AdminService) ForceExpire(ctx context.Context, shareID, actor string) error {
+	err := s.runTx(ctx, func(q sqlc.Querier) error {
+		fileID, err := q.ForceExpireFile(ctx, shareID)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  fileID,
+			Action:  AuditActionAdminExpired,
+			Actor:   actor,
+			Details: []byte("{}"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		slog.Error("failed to force expire file",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return fmt.Errorf("failed to expire file: %w", err)
+	}
+
+	slog.Info("file expired by admin",
+		slog.String("share_id", shareID),
+		slog.String("actor", actor),
+	)
+	s.events.Publish(events.Event{Type: events.TypeRevoked, ShareID: shareID})
+	return nil
+}
+
+// StorageTotals sums the files and their sizes by status.
+func (s *AdminService) StorageTotals(ctx context.Context) (types.StorageTotals, error) {
+	rows, err := s.repository.GetStorageTotals(ctx)
+	if err != nil {
+		return types.StorageTotals{}, fmt.Errorf("failed to get storage totals: %w", err)
+	}
+
+	totals := types.StorageTotals{ByStatus: make([]types.StatusTotal, len(rows))}
+	for i, row := range rows {
+		totals.ByStatus[i] = types.StatusTotal{
+			Status: row.Status,
+			Files:  row.Files,
+			Bytes:  row.Bytes,
+		}
+		totals.Files += row.Files
+		totals.Bytes += row.Bytes
+		if row.Status != "expired" {
+			totals.StoredBytes += row.Bytes
+		}
+	}
+	return totals, nil
+}
+
+// TransferTotals sums the bytes served over the last days days, today
+// included, and lists the limit files served the most in them. Zero days or
+// limit take the defaults.
+func (s *AdminService) TransferTotals(ctx context.Context, days, limit int) (types.TransferTotals, error) {
+	switch {
+	case days == 0:
+		days = DefaultTransferDays
+	case days < 0 || days > MaxTransferDays:
+		return types.TransferTotals{}, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidAdminFilter, MaxTransferDays)
+	}
+	switch {
+	case limit == 0:
+		limit = DefaultTransferTopFiles
+	case limit < 0 || limit > MaxAdminListLimit:
+		return types.TransferTotals{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAdminFilter, MaxAdminListLimit)
+	}
+
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, time.UTC)
+	sinceDate := pgtype.Date{Time: since, Valid: true}
+
+	daily, err := s.repository.ListDailyTransferTotals(ctx, sinceDate)
+	if err != nil {
+		return types.TransferTotals{}, fmt.Errorf("failed to get daily transfer totals: %w", err)
+	}
+	top, err := s.repository.ListTopTransferFiles(ctx, sqlc.ListTopTransferFilesParams{
+		Since: sinceDate,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return types.TransferTotals{}, fmt.Errorf("failed to get top transfer files: %w", err)
+	}
+
+	totals := types.TransferTotals{
+		Days:     days,
+		Since:    since.Format(time.DateOnly),
+		ByDay:    make([]types.DailyTransfer, len(daily)),
+		TopFiles: make([]types.FileTransfer, len(top)),
+	}
+	for i, row := range daily {
+		totals.ByDay[i] = types.DailyTransfer{Day: row.Day.Time.Format(time.DateOnly), Bytes: row.Bytes}
+		totals.Bytes += row.Bytes
+	}
+	for i, row := range top {
+		totals.TopFiles[i] = types.FileTransfer{
+			ShareID:   row.ShareID,
+			Status:    row.Status,
+			TotalSize: row.TotalSize,
+			Bytes:     row.Bytes,
+		}
+	}
+	return totals, nil
+}
+
+// FeatureFlags returns the state of every feature flag on this instance.
+func (s *AdminService) FeatureFlags() []flags.Flag {
+	return s.flags.All()
+}
+
+type featureFlagChange struct {
+	Flag    string `json:"flag"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SetFeatureFlag stores a flag toggle, records it in the audit log and
+// applies it to this instance at once. Other instances apply it on their next
+// refresh.
+func (s *AdminService) SetFeatureFlag(ctx context.Context, name string, enabled bool, actor string) (flags.Flag, error) {
+	if s.flags == nil || !flags.Known(name) {
+		return flags.Flag{}, flags.ErrUnknownFlag
+	}
+
+	details, err := json.Marshal(featureFlagChange{Flag: name, Enabled: enabled})
+	if err != nil {
+		return flags.Flag{}, fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		_, err := q.UpsertFeatureFlag(ctx, sqlc.UpsertFeatureFlagParams{Name: name, Enabled: enabled})
+		if err != nil {
+			return fmt.Errorf("failed to store feature flag: %w", err)
+		}
+
+		// Flag changes belong to no file
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			Action:  AuditActionFeatureFlagSet,
+			Actor:   actor,
+			Details: details,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to set feature flag",
+			slog.String("error", err.Error()),
+			slog.String("flag", name),
+		)
+		return flags.Flag{}, err
+	}
+
+	if err := s.flags.Override(name, enabled); err != nil {
+		return flags.Flag{}, err
+	}
+
+	slog.Info("feature flag set",
+		slog.String("flag", name),
+		slog.Bool("enabled", enabled),
+		slog.String("actor", actor),
+	)
+
+	f, _ := s.flags.Get(name)
+	return f, nil
+}
+
+func (s *AdminService) ReadOnly() bool {
+	return s.readOnly.Enabled()
+}
+
+type readOnlyChange struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetReadOnly switches read-only mode on this instance. Unlike feature flags
+// it is not stored, since the database may not take writes when it is
+// needed; other instances keep theirs, and a restart goes back to READ_ONLY.
+// The change is audited when the database allows it.
+func (s *AdminService) SetReadOnly(ctx context.Context, enabled bool, actor string) {
+	s.readOnly.Set(enabled)
+
+	slog.Warn("read-only mode set",
+		slog.Bool("enabled", enabled),
+		slog.String("actor", actor),
+	)
+
+	details, err := json.Marshal(readOnlyChange{Enabled: enabled})
+	if err != nil {
+		return
+	}
+	_, err = s.repository.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		Action:  AuditActionReadOnlySet,
+		Actor:   actor,
+		Details: details,
+	})
+	if err != nil {
+		slog.Warn("failed to audit read-only mode change",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Jobs returns the scheduled jobs of this instance and how many files are
+// being verified in the background, here and on any instance.
+func (s *AdminService) Jobs(ctx context.Context) (types.AdminJobs, error) {
+	jobs := types.AdminJobs{Jobs: []types.JobStatus{}}
+	if s.jobs != nil {
+		jobs.Jobs = s.jobs.Jobs()
+	}
+	if s.files != nil {
+		jobs.Verification.Running = s.files.BackgroundVerifications()
+	}
+
+	rows, err := s.repository.GetStorageTotals(ctx)
+	if err != nil {
+		return types.AdminJobs{}, fmt.Errorf("failed to get storage totals: %w", err)
+	}
+	for _, row := range rows {
+		if row.Status == FileStatusVerifying {
+			jobs.Verification.Files = row.Files
+		}
+	}
+	return jobs, nil
+}
+
+// BlockedNetworks lists the networks blocked through the admin API.
+func (s *AdminService) BlockedNetworks(ctx context.Context) ([]types.BlockedNetwork, error) {
+	rows, err := s.repository.ListBlockedNetworks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked networks: %w", err)
+	}
+
+	networks := make([]types.BlockedNetwork, len(rows))
+	for i, row := range rows {
+		networks[i] = blockedNetwork(row)
+	}
+	return networks, nil
+}
+
+type blocklistChange struct {
+	Network string `json:"network"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// BlockNetwork stores a blocked network, given as a CIDR or an IP address,
+// and records it in the audit log. Blocking a network again replaces its
+// reason.
+func (s *AdminService) BlockNetwork(ctx context.Context, network, reason, actor string) (types.BlockedNetwork, error) {
+	prefix, err := reputation.ParseNetwork(strings.TrimSpace(network))
+	if err != nil {
+		return types.BlockedNetwork{}, ErrInvalidNetwork
+	}
+	if len(reason) > MaxBlockReasonLength {
+		return types.BlockedNetwork{}, ErrBlockReasonTooLong
+	}
+
+	details, err := json.Marshal(blocklistChange{Network: prefix.String(), Reason: reason})
+	if err != nil {
+		return types.BlockedNetwork{}, fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	var blocked sqlc.BlockedNetwork
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		blocked, err = q.UpsertBlockedNetwork(ctx, sqlc.UpsertBlockedNetworkParams{Network: prefix, Reason: reason})
+		if err != nil {
+			return fmt.Errorf("failed to store blocked network: %w", err)
+		}
+		return s.auditBlocklist(ctx, q, AuditActionNetworkBlocked, actor, details)
+	})
+	if err != nil {
+		return types.BlockedNetwork{}, err
+	}
+
+	s.refreshBlocklist(ctx)
+	slog.Warn("network blocked",
+		slog.String("network", prefix.String()),
+		slog.String("actor", actor),
+	)
+	return blockedNetwork(blocked), nil
+}
+
+// UnblockNetwork removes a blocked network and records it in the audit log.
+// It fails with ErrNotFound unless the network was blocked.
+func (s *AdminService) UnblockNetwork(ctx context.Context, network, actor string) error {
+	prefix, err := reputation.ParseNetwork(strings.TrimSpace(network))
+	if err != nil {
+		return ErrInvalidNetwork
+	}
+
+	details, err := json.Marshal(blocklistChange{Network: prefix.String()})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
+		rows, err := q.DeleteBlockedNetwork(ctx, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to remove blocked network: %w", err)
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+		return s.auditBlocklist(ctx, q, AuditActionNetworkUnblocked, actor, details)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.refreshBlocklist(ctx)
+	slog.Info("network unblocked",
+		slog.String("network", prefix.String()),
+		slog.String("actor", actor),
+	)
+	return nil
+}
+
+func (s *AdminService) auditBlocklist(ctx context.Context, q sqlc.Querier, action, actor string, details []byte) error {
+	// Blocklist changes belong to no file
+	_, err := q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		Action:  action,
+		Actor:   actor,
+		Details: details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// refreshBlocklist applies a stored change to this instance. Should that
+// fail, the next scheduled refresh applies it.
+func (s *AdminService) refreshBlocklist(ctx context.Context) {
+	if s.blocklist == nil {
+		return
+	}
+	if err := s.blocklist.Refresh(ctx); err != nil {
+		slog.Warn("failed to refresh blocked networks",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func blockedNetwork(row sqlc.BlockedNetwork) types.BlockedNetwork {
+	return types.BlockedNetwork{
+		Network:   row.Network.String(),
+		Reason:    row.Reason,
+		CreatedAt: formatTimestamptz(row.CreatedAt),
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateAdminNotes_Integration_RecordsHistory(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	queries := containers.Database.Queries
+	service := NewAdminService(containers.Querier(), database.NewTxRunner(containers.Database.Pool))
+	ctx := context.Background()
+
+	file := testutil.CreateTestFile(t, queries, ctx, testutil.DefaultTestFileOptions())
+
+	require.NoError(t, service.UpdateAdminNotes(ctx, file.ShareID, "first report", "admin"))
+	require.NoError(t, service.UpdateAdminNotes(ctx, file.ShareID, "confirmed abuse", "admin"))
+
+	resp, err := service.GetAdminNotes(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, "confirmed abuse", resp.Notes)
+	require.Len(t, resp.History, 2)
+	assert.JSONEq(t, `{"previous":"first report","notes":"confirmed abuse"}`, string(resp.History[1].Details))
+}
+
+func TestSetWatermark_Integration_ServerEncryptedOnly(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	queries := containers.Database.Queries
+	service := NewAdminService(containers.Querier(), database.NewTxRunner(containers.Database.Pool)).
+		WithWatermarking(true)
+	ctx := context.Background()
+
+	e2ee := testutil.CreateTestFile(t, queries, ctx, testutil.DefaultTestFileOptions())
+	assert.ErrorIs(t, service.SetWatermark(ctx, e2ee.ShareID, "for alice", "admin"), ErrNotServerEncrypted)
+
+	file := testutil.CreateTestFile(t, queries, ctx, testutil.DefaultTestFileOptions())
+	require.NoError(t, queries.CreateServerEncryptedFile(ctx, sqlc.CreateServerEncryptedFileParams{FileID: file.ID, KeyID: "key"}))
+
+	require.NoError(t, service.SetWatermark(ctx, file.ShareID, "for alice", "admin"))
+	mark, err := queries.GetWatermarkByShareId(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, "for alice", mark.String)
+
+	require.NoError(t, service.SetWatermark(ctx, file.ShareID, "", "admin"))
+	mark, err = queries.GetWatermarkByShareId(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.False(t, mark.Valid)
+}
+
+func TestAdminService_Integration_FilterAndForceExpire(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	queries := containers.Database.Queries
+	service := NewAdminService(containers.Querier(), database.NewTxRunner(containers.Database.Pool))
+	ctx := context.Background()
+
+	small := testutil.CreateReadyFile(t, queries, ctx)
+	opts := testutil.DefaultTestFileOptions()
+	opts.TotalSize = 4096
+	large := testutil.CreateTestFile(t, queries, ctx, opts)
+	testutil.CreateUploadingFile(t, queries, ctx)
+
+	list, err := service.ListFiles(ctx, types.AdminFileFilter{Status: "ready", MinSize: 2048})
+	require.NoError(t, err)
+	require.Len(t, list.Files, 1)
+	assert.Equal(t, large.ShareID, list.Files[0].ShareID)
+
+	list, err = service.ListFiles(ctx, types.AdminFileFilter{UploaderIP: "127.0.0.1"})
+	require.NoError(t, err)
+	assert.Len(t, list.Files, 3)
+
+	require.NoError(t, service.ForceExpire(ctx, small.ShareID, "admin"))
+
+	expired, err := queries.GetExpiredFiles(ctx)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, small.ID, expired[0].ID)
+
+	entries, err := queries.ListAuditLogByFileId(ctx, small.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditActionAdminExpired, entries[0].Action)
+
+	assert.ErrorIs(t, service.ForceExpire(ctx, "missing-share", "admin"), ErrNotFound)
+
+	totals, err := service.StorageTotals(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), totals.Files)
+}
+
+func TestSetFeatureFlag_Integration_StoresAndAudits(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	queries := containers.Database.Queries
+	provider, err := flags.New(queries, nil)
+	require.NoError(t, err)
+	service := NewAdminService(containers.Querier(), database.NewTxRunner(containers.Database.Pool)).
+		WithFlags(provider)
+	ctx := context.Background()
+
+	flag, err := service.SetFeatureFlag(ctx, flags.PresignedUploads, false, "admin")
+	require.NoError(t, err)
+	assert.False(t, flag.Enabled)
+	assert.True(t, flag.Overridden)
+	assert.False(t, provider.Enabled(flags.PresignedUploads))
+
+	// A second instance picks the toggle up from the database
+	other, err := flags.New(queries, nil)
+	require.NoError(t, err)
+	require.NoError(t, other.Refresh(ctx))
+	assert.False(t, other.Enabled(flags.PresignedUploads))
+
+	_, err = service.SetFeatureFlag(ctx, "dedup", true, "admin")
+	assert.ErrorIs(t, err, flags.ErrUnknownFlag)
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateAdminNotes_TooLong(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+
+	err := service.UpdateAdminNotes(context.Background(), "abc123def456", strings.Repeat("a", MaxAdminNotesLength+1), "admin")
+
+	assert.ErrorIs(t, err, ErrAdminNotesTooLong)
+	mockRepo.AssertNotCalled(t, "GetFileByShareID")
+}
+
+func TestUpdateAdminNotes_NotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	mockRepo.On("GetFileByShareID", ctx, "missing").Return(sqlc.File{}, database.ErrNotFound)
+
+	err := service.UpdateAdminNotes(ctx, "missing", "suspected phishing", "admin")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSetWatermark_Rejected(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	ctx := context.Background()
+
+	err := NewAdminService(mockRepo, mockTxRunner).SetWatermark(ctx, "abc123def456", "for alice", "admin")
+	assert.ErrorIs(t, err, ErrWatermarkingOff)
+
+	err = NewAdminService(mockRepo, mockTxRunner).WithWatermarking(true).
+		SetWatermark(ctx, "abc123def456", strings.Repeat("a", MaxWatermarkLength+1), "admin")
+	assert.ErrorIs(t, err, ErrWatermarkTooLong)
+
+	mockRepo.AssertNotCalled(t, "GetFileByShareID")
+}
+
+func TestSetDownloadRate(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	negative := int64(-1)
+	err := service.SetDownloadRate(ctx, "abc123def456", &negative, "admin")
+	assert.ErrorIs(t, err, ErrInvalidDownloadRate)
+	mockRepo.AssertNotCalled(t, "GetFileByShareID")
+
+	mockRepo.On("GetFileByShareID", ctx, "missing").Return(sqlc.File{}, database.ErrNotFound)
+	err = service.SetDownloadRate(ctx, "missing", nil, "admin")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSetReadOnly(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	sw := readonly.New(false)
+	service := NewAdminService(mockRepo, mockTxRunner).WithReadOnly(sw)
+	ctx := context.Background()
+
+	// Read-only mode is switched even when the database takes no writes
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(p sqlc.CreateAuditLogEntryParams) bool {
+		return p.Action == AuditActionReadOnlySet && string(p.Details) == `{"enabled":true}`
+	})).Return(sqlc.AuditLog{}, errors.New("cannot execute INSERT in a read-only transaction"))
+
+	service.SetReadOnly(ctx, true, "admin")
+
+	assert.True(t, sw.Enabled())
+	assert.True(t, service.ReadOnly())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAdminNotes_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	fileID := createTestUUID()
+	createdAt := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetFileByShareID", ctx, "abc123def456").Return(sqlc.File{
+		ID:         fileID,
+		AdminNotes: pgtype.Text{String: "reported twice", Valid: true},
+	}, nil)
+	mockRepo.On("ListAuditLogByFileId", ctx, fileID).Return([]sqlc.AuditLog{
+		{
+			FileID:    fileID,
+			Action:    AuditActionAdminNotesUpdated,
+			Actor:     "admin",
+			Details:   []byte(`{"previous":"","notes":"reported twice"}`),
+			CreatedAt: pgtype.Timestamptz{Time: createdAt, Valid: true},
+		},
+	}, nil)
+
+	resp, err := service.GetAdminNotes(ctx, "abc123def456")
+
+	require.NoError(t, err)
+	assert.Equal(t, "reported twice", resp.Notes)
+	require.Len(t, resp.History, 1)
+	assert.Equal(t, AuditActionAdminNotesUpdated, resp.History[0].Action)
+	assert.Equal(t, "2026-10-15T12:00:00Z", resp.History[0].CreatedAt)
+	assert.JSONEq(t, `{"previous":"","notes":"reported twice"}`, string(resp.History[0].Details))
+}
+
+func TestGetAdminNotes_DatabaseError(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	mockRepo.On("GetFileByShareID", ctx, "abc123def456").Return(sqlc.File{}, errors.New("database connection error"))
+
+	_, err := service.GetAdminNotes(ctx, "abc123def456")
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestListFiles_BuildsFilter(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+	ip := netip.MustParseAddr("192.0.2.7")
+
+	mockRepo.On("ListAdminFiles", ctx, sqlc.ListAdminFilesParams{
+		Status:     pgtype.Text{String: "ready", Valid: true},
+		MinSize:    pgtype.Int8{Int64: 1024, Valid: true},
+		UploaderIp: &ip,
+		Limit:      DefaultAdminListLimit,
+		Offset:     10,
+	}).Return([]sqlc.ListAdminFilesRow{{
+		ShareID:    "abc123def456",
+		Status:     "ready",
+		TotalSize:  2048,
+		UploaderIp: ip,
+		CreatedAt:  pgtype.Timestamptz{Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+	}}, nil)
+
+	list, err := service.ListFiles(ctx, types.AdminFileFilter{
+		Status:     "ready",
+		MinSize:    1024,
+		UploaderIP: "192.0.2.7",
+		Offset:     10,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, list.Files, 1)
+	assert.Equal(t, "192.0.2.7", list.Files[0].UploaderIP)
+	assert.Equal(t, "2026-01-01T00:00:00Z", list.Files[0].CreatedAt)
+	assert.Equal(t, DefaultAdminListLimit, list.Limit)
+}
+
+func TestListFiles_InvalidFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter types.AdminFileFilter
+	}{
+		{name: "unknown status", filter: types.AdminFileFilter{Status: "gone"}},
+		{name: "negative size", filter: types.AdminFileFilter{MinSize: -1}},
+		{name: "invalid IP", filter: types.AdminFileFilter{UploaderIP: "not-an-ip"}},
+		{name: "limit too large", filter: types.AdminFileFilter{Limit: MaxAdminListLimit + 1}},
+		{name: "negative offset", filter: types.AdminFileFilter{Offset: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewAdminService(mockRepo, mockTxRunner)
+
+			_, err := service.ListFiles(context.Background(), tt.filter)
+
+			assert.ErrorIs(t, err, ErrInvalidAdminFilter)
+			mockRepo.AssertNotCalled(t, "ListAdminFiles", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestStorageTotals(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	mockRepo.On("GetStorageTotals", ctx).Return([]sqlc.GetStorageTotalsRow{
+		{Status: "expired", Files: 4, Bytes: 4000},
+		{Status: "ready", Files: 2, Bytes: 2000},
+		{Status: "uploading", Files: 1, Bytes: 500},
+	}, nil)
+
+	totals, err := service.StorageTotals(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), totals.Files)
+	assert.Equal(t, int64(6500), totals.Bytes)
+	assert.Equal(t, int64(2500), totals.StoredBytes)
+	assert.Len(t, totals.ByStatus, 3)
+}
+
+func TestTransferTotals(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner)
+	ctx := context.Background()
+
+	mockRepo.On("ListDailyTransferTotals", ctx, mock.Anything).Return([]sqlc.ListDailyTransferTotalsRow{
+		{Day: pgtype.Date{Time: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), Valid: true}, Bytes: 3000},
+		{Day: pgtype.Date{Time: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), Valid: true}, Bytes: 1000},
+	}, nil)
+	mockRepo.On("ListTopTransferFiles", ctx, mock.MatchedBy(func(arg sqlc.ListTopTransferFilesParams) bool {
+		return arg.Limit == DefaultTransferTopFiles && arg.Since.Valid
+	})).Return([]sqlc.ListTopTransferFilesRow{
+		{ShareID: "abc123def456", Status: "ready", TotalSize: 500, Bytes: 4000},
+	}, nil)
+
+	totals, err := service.TransferTotals(ctx, 0, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTransferDays, totals.Days)
+	assert.Equal(t, int64(4000), totals.Bytes)
+	assert.Equal(t, "2026-10-14", totals.ByDay[0].Day)
+	assert.Equal(t, []types.FileTransfer{{ShareID: "abc123def456", Status: "ready", TotalSize: 500, Bytes: 4000}}, totals.TopFiles)
+
+	_, err = service.TransferTotals(ctx, MaxTransferDays+1, 0)
+	assert.ErrorIs(t, err, ErrInvalidAdminFilter)
+	_, err = service.TransferTotals(ctx, 0, -1)
+	assert.ErrorIs(t, err, ErrInvalidAdminFilter)
+}
+
+func TestBlockNetwork(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, txRunnerOn(mockRepo))
+	ctx := context.Background()
+	network := netip.MustParsePrefix("203.0.113.0/24")
+
+	mockRepo.On("UpsertBlockedNetwork", ctx, sqlc.UpsertBlockedNetworkParams{Network: network, Reason: "scraping"}).
+		Return(sqlc.BlockedNetwork{Network: network, Reason: "scraping"}, nil)
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(p sqlc.CreateAuditLogEntryParams) bool {
+		return p.Action == AuditActionNetworkBlocked && !p.FileID.Valid &&
+			string(p.Details) == `{"network":"203.0.113.0/24","reason":"scraping"}`
+	})).Return(sqlc.AuditLog{}, nil)
+
+	blocked, err := service.BlockNetwork(ctx, " 203.0.113.77/24 ", "scraping", "admin")
+
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.0/24", blocked.Network)
+	assert.Equal(t, "scraping", blocked.Reason)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBlockNetwork_Rejected(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, txRunnerOn(mockRepo))
+	ctx := context.Background()
+
+	_, err := service.BlockNetwork(ctx, "example.com", "", "admin")
+	assert.ErrorIs(t, err, ErrInvalidNetwork)
+
+	_, err = service.BlockNetwork(ctx, "203.0.113.7", strings.Repeat("a", MaxBlockReasonLength+1), "admin")
+	assert.ErrorIs(t, err, ErrBlockReasonTooLong)
+
+	mockRepo.AssertNotCalled(t, "UpsertBlockedNetwork")
+}
+
+func TestUnblockNetwork_NotBlocked(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, txRunnerOn(mockRepo))
+	ctx := context.Background()
+
+	mockRepo.On("DeleteBlockedNetwork", ctx, netip.MustParsePrefix("203.0.113.7/32")).Return(int64(0), nil)
+
+	err := service.UnblockNetwork(ctx, "203.0.113.7", "admin")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	mockRepo.AssertNotCalled(t, "CreateAuditLogEntry")
+}
+
+// stubJobs reports fixed scheduled jobs.
+type stubJobs []types.JobStatus
+
+func (s stubJobs) Jobs() []types.JobStatus { return s }
+
+func TestAdminJobs(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	ctx := context.Background()
+	files := &FileService{}
+	files.verifications.Add(2)
+	service := NewAdminService(mockRepo, mockTxRunner).
+		WithJobs(stubJobs{{Name: "cleanup", IntervalSeconds: 300, Runs: 4}}, files)
+
+	mockRepo.On("GetStorageTotals", ctx).Return([]sqlc.GetStorageTotalsRow{
+		{Status: "ready", Files: 10, Bytes: 100},
+		{Status: FileStatusVerifying, Files: 3, Bytes: 30},
+	}, nil)
+
+	jobs, err := service.Jobs(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, []types.JobStatus{{Name: "cleanup", IntervalSeconds: 300, Runs: 4}}, jobs.Jobs)
+	assert.Equal(t, types.VerificationStatus{Running: 2, Files: 3}, jobs.Verification)
+}
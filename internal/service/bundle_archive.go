@@ -0,0 +1,173 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/utils"
+)
+
+// bundleArchiveManifest is the name of the manifest in a bundle archive,
+// written after the files so it can list what was skipped.
+const bundleArchiveManifest = "manifest.json"
+
+// BundleArchive is a bundle about to be streamed as a ZIP archive.
+type BundleArchive struct {
+	ShareID string
+
+	cs       *ChunkService
+	ctx      context.Context
+	clientIP string
+	manifest types.BundleManifestResponse
+}
+
+// OpenBundleArchive looks up the ready files of an unexpired bundle, to be
+// written as one ZIP archive by WriteZip.
+func (cs *ChunkService) OpenBundleArchive(ctx context.Context, shareID, clientIP string) (*BundleArchive, error) {
+	manifest, err := bundleManifest(ctx, cs.repository, shareID)
+	if err != nil {
+		return nil, err
+	}
+	return &BundleArchive{
+		ShareID:  manifest.ShareID,
+		cs:       cs,
+		ctx:      ctx,
+		clientIP: clientIP,
+		manifest: manifest,
+	}, nil
+}
+
+// WriteZip streams every file of the bundle into a ZIP archive, followed by
+// a manifest.json. Files are stored as served by StreamFile, without
+// compression since they are encrypted, under files/{shareID}. Each file
+// written whole counts as one download of it; files that cannot be
+// downloaded are skipped and listed in the manifest. An error leaves the
+// archive incomplete.
+func (a *BundleArchive) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	out := types.BundleArchiveManifest{
+		ShareID:   a.manifest.ShareID,
+		ExpiresAt: a.manifest.ExpiresAt,
+		Files:     []types.BundleArchiveFile{},
+		Skipped:   []types.BundleArchiveSkip{},
+	}
+
+	for _, file := range a.manifest.Files {
+		entry, reason, err := a.writeFile(zw, file)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			slog.Info("bundle file skipped",
+				slog.String("bundle_share_id", a.ShareID),
+				slog.String("share_id", file.ShareID),
+				slog.String("reason", reason),
+			)
+			out.Skipped = append(out.Skipped, types.BundleArchiveSkip{ShareID: file.ShareID, Reason: reason})
+			continue
+		}
+		out.Files = append(out.Files, entry)
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     bundleArchiveManifest,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add manifest: %w", err)
+	}
+	if err := json.NewEncoder(mw).Encode(out); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return zw.Close()
+}
+
+// writeFile adds one file to the archive, or returns why it was skipped.
+func (a *BundleArchive) writeFile(zw *zip.Writer, file types.BundleFile) (types.BundleArchiveFile, string, error) {
+	err := a.cs.checkReadOnlyDownload(a.ctx, file.ShareID)
+	if reason := bundleSkipReason(err); reason != "" {
+		return types.BundleArchiveFile{}, reason, nil
+	}
+	if err != nil {
+		return types.BundleArchiveFile{}, "", fmt.Errorf("failed to check %s: %w", file.ShareID, err)
+	}
+
+	stream, err := a.cs.StreamFile(a.ctx, file.ShareID, a.clientIP)
+	if reason := bundleSkipReason(err); reason != "" {
+		return types.BundleArchiveFile{}, reason, nil
+	}
+	if err != nil {
+		return types.BundleArchiveFile{}, "", fmt.Errorf("failed to open %s: %w", file.ShareID, err)
+	}
+	defer stream.Body.Close()
+
+	// The archive has no nonce of its own, so it takes one per file
+	var nonce string
+	if a.cs.completer != nil {
+		nonce, err = a.cs.completer.IssueDownloadNonce(a.ctx, file.ShareID)
+		if errors.Is(err, ErrNotReady) {
+			return types.BundleArchiveFile{}, "not_ready", nil
+		}
+		if err != nil {
+			return types.BundleArchiveFile{}, "", err
+		}
+	}
+
+	path := "files/" + file.ShareID
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     path,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return types.BundleArchiveFile{}, "", fmt.Errorf("failed to add %s: %w", file.ShareID, err)
+	}
+	if _, err := io.Copy(fw, utils.Throttle(a.ctx, stream.Body, stream.MaxRate)); err != nil {
+		return types.BundleArchiveFile{}, "", fmt.Errorf("failed to write %s: %w", file.ShareID, err)
+	}
+
+	chunkIndexes := make([]int32, stream.ChunkCount)
+	for i := range chunkIndexes {
+		chunkIndexes[i] = int32(i)
+	}
+	// Sent already, so the download counts even if the client goes away
+	if err := a.cs.RecordChunksServed(context.WithoutCancel(a.ctx), file.ShareID, nonce, chunkIndexes); err != nil {
+		slog.Warn("failed to count bundle file download",
+			slog.String("error", err.Error()),
+			slog.String("share_id", file.ShareID),
+		)
+	}
+
+	return types.BundleArchiveFile{BundleFile: file, Path: path, Chunks: stream.Chunks}, "", nil
+}
+
+// bundleSkipReason names why a file of a bundle cannot be added to its
+// archive, or returns "" for errors that fail the whole archive.
+func bundleSkipReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRevoked):
+		return "revoked"
+	case errors.Is(err, ErrNotFound):
+		return "expired"
+	case errors.Is(err, ErrDownloadLimitReached):
+		return "download_limit_reached"
+	case errors.Is(err, ErrChunkMissing):
+		return "chunk_missing"
+	case errors.Is(err, ErrWatermarkUnavailable):
+		return "watermark_unavailable"
+	case errors.Is(err, readonly.ErrReadOnly):
+		return "read_only"
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleArchive_WriteZip(t *testing.T) {
+	mockRepo, service := newStreamChunkService(t, [][]byte{[]byte("first-"), []byte("second")}, []int32{6, 6})
+	completer := new(fakeCompleter)
+	service.WithDownloadCompleter(completer)
+
+	bundleID := createTestUUID()
+	mockRepo.On("GetFileBundleByShareId", mock.Anything, "bundle123456").
+		Return(sqlc.FileBundle{ID: bundleID, ShareID: "bundle123456"}, nil)
+	mockRepo.On("ListReadyBundleFiles", mock.Anything, bundleID).
+		Return([]sqlc.ListReadyBundleFilesRow{
+			{ShareID: "abc123def456", TotalSize: 12, ChunkCount: 2},
+			{ShareID: "gone12345678", TotalSize: 4, ChunkCount: 1},
+		}, nil)
+	mockRepo.On("ListChunkManifestByShareId", mock.Anything, "gone12345678").
+		Return([]sqlc.ListChunkManifestByShareIdRow{}, nil)
+	mockRepo.On("GetFileByShareID", mock.Anything, "gone12345678").Return(sqlc.File{}, database.ErrNotFound)
+	mockRepo.On("RecordDownloadNonceChunks", mock.Anything, sqlc.RecordDownloadNonceChunksParams{
+		ChunkIndexes: []int32{0, 1},
+		NonceHash:    crypto.HashBytes([]byte("nonce-abc123def456")),
+		ShareID:      "abc123def456",
+	}).Return(sqlc.RecordDownloadNonceChunksRow{ServedChunks: 2, ChunkCount: 2}, nil)
+
+	archive, err := service.OpenBundleArchive(context.Background(), "bundle123456", "")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, archive.WriteZip(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "files/abc123def456", zr.File[0].Name)
+	assert.Equal(t, zip.Store, zr.File[0].Method)
+	assert.Equal(t, "first-second", readZipEntry(t, zr.File[0]))
+
+	var manifest types.BundleArchiveManifest
+	require.Equal(t, "manifest.json", zr.File[1].Name)
+	require.NoError(t, json.Unmarshal([]byte(readZipEntry(t, zr.File[1])), &manifest))
+	require.Len(t, manifest.Files, 1)
+	assert.Equal(t, "files/abc123def456", manifest.Files[0].Path)
+	assert.Len(t, manifest.Files[0].Chunks, 2)
+	assert.Equal(t, []types.BundleArchiveSkip{{ShareID: "gone12345678", Reason: "expired"}}, manifest.Skipped)
+
+	assert.Equal(t, []string{"nonce-abc123def456"}, completer.completed)
+}
+
+func TestBundleArchive_WriteZip_ReadOnly(t *testing.T) {
+	mockRepo, service := newStreamChunkService(t, [][]byte{[]byte("first-"), []byte("second")}, []int32{6, 6})
+	service.WithReadOnly(readonly.New(true))
+
+	bundleID := createTestUUID()
+	mockRepo.On("GetFileBundleByShareId", mock.Anything, "bundle123456").
+		Return(sqlc.FileBundle{ID: bundleID, ShareID: "bundle123456"}, nil)
+	mockRepo.On("ListReadyBundleFiles", mock.Anything, bundleID).
+		Return([]sqlc.ListReadyBundleFilesRow{
+			{ShareID: "abc123def456", TotalSize: 12, ChunkCount: 2},
+			{ShareID: "limited12345", TotalSize: 4, ChunkCount: 1},
+		}, nil)
+	mockRepo.On("GetFileByShareID", mock.Anything, "abc123def456").
+		Return(sqlc.File{MaxDownloads: config.UnlimitedDownloads}, nil)
+	mockRepo.On("GetFileByShareID", mock.Anything, "limited12345").
+		Return(sqlc.File{MaxDownloads: 3}, nil)
+
+	archive, err := service.OpenBundleArchive(context.Background(), "bundle123456", "")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, archive.WriteZip(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "files/abc123def456", zr.File[0].Name)
+
+	var manifest types.BundleArchiveManifest
+	require.NoError(t, json.Unmarshal([]byte(readZipEntry(t, zr.File[1])), &manifest))
+	assert.Equal(t, []types.BundleArchiveSkip{{ShareID: "limited12345", Reason: "read_only"}}, manifest.Skipped)
+	mockRepo.AssertNotCalled(t, "ListChunkManifestByShareId", mock.Anything, "limited12345")
+}
+
+func TestOpenBundleArchive_NotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	mockRepo.On("GetFileBundleByShareId", mock.Anything, "missing").Return(sqlc.FileBundle{}, database.ErrNotFound)
+
+	_, err := service.OpenBundleArchive(context.Background(), "missing", "")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func readZipEntry(t *testing.T, f *zip.File) string {
+	t.Helper()
+	rc, err := f.Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -3,28 +3,139 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ilkin0/gzln/internal/alert"
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/envelope"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/fairshare"
+	"github.com/ilkin0/gzln/internal/readonly"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/internal/watermark"
+	"github.com/ilkin0/gzln/pkg/e2ee"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
 )
 
+var (
+	ErrStorageKeyUnavailable = errors.New("storage encryption key unavailable")
+	ErrChunkMissing          = errors.New("chunk missing from storage")
+	ErrChunkNotUploaded      = errors.New("chunk object not found")
+	ErrInvalidChunkSize      = errors.New("invalid chunk size")
+	ErrInvalidChunk          = errors.New("invalid chunk")
+	ErrInvalidChunkIndex     = errors.New("invalid chunk index")
+	ErrChunkAlreadyUploaded  = errors.New("chunk already uploaded")
+	ErrHashMismatch          = errors.New("hash mismatch for chunk upload")
+	ErrStorageTimeout        = errors.New("storage write timed out")
+	// ErrChunkMismatch means a chunk in storage does not match its row.
+	ErrChunkMismatch = errors.New("stored chunk does not match its record")
+	// ErrWatermarkedShare means a chunk of a watermarked share was requested
+	// on its own, which would skip the watermark.
+	ErrWatermarkedShare     = errors.New("share is watermarked and can only be streamed")
+	ErrWatermarkUnavailable = errors.New("watermarking is not configured")
+
+	// errChunkUnchanged means a chunk was uploaded again with the hash it is
+	// stored with, typically a client retrying after a lost response.
+	errChunkUnchanged = errors.New("chunk already uploaded with the same hash")
+)
+
+// Statuses of an accepted chunk upload. A retried chunk whose hash matches
+// the stored one is not written again and reports ChunkStatusAlreadyUploaded.
+const (
+	ChunkStatusUploaded        = "uploaded"
+	ChunkStatusAlreadyUploaded = "already_uploaded"
+)
+
+// PresignBatchSize is how many chunk uploads are presigned at once. Init
+// returns the first batch and clients fetch the next ones as they go, so a
+// file with many chunks does not sign them all up front.
+const PresignBatchSize int32 = 100
+
+// FileStatusCorrupt marks files with a chunk row whose object is gone from
+// storage. They can no longer be downloaded and must be uploaded again.
+const FileStatusCorrupt = "corrupt"
+
+// FileStatusVerifying marks finalized uploads whose chunks are being checked
+// in the background. They become ready, or corrupt, once the check is done.
+const FileStatusVerifying = "verifying"
+
+// FileStatusRevoked marks shares their uploader revoked. Their data is kept
+// until they expire, but they can no longer be downloaded.
+const FileStatusRevoked = "revoked"
+
+var missingChunks = expvar.NewInt("storage_missing_chunks")
+
+// chunkUploads sums the bytes and time of chunks uploaded through the API and
+// counts those slower than slowChunkBytesPerSecond.
+var chunkUploads = expvar.NewMap("chunk_uploads")
+
+const (
+	// throughputWindowChunks is how many recent chunks upload throughput is
+	// averaged over.
+	throughputWindowChunks = 8
+
+	// slowChunkBytesPerSecond marks a chunk as slow to receive or store.
+	slowChunkBytesPerSecond = 256 << 10
+)
+
 type ChunkService struct {
 	repository  sqlc.Querier
 	minioClient *minio.Client
 	bucketName  string
+	limits      config.Limits
+	fairShare   *fairshare.Scheduler
+	envelope    *envelope.Envelope
+	alerts      *alert.Webhook
+	// presignClient signs direct-to-storage chunk uploads. It may point at a
+	// public endpoint different from minioClient's.
+	presignClient *minio.Client
+	presignTTL    time.Duration
+	multipart     config.Multipart
+	storageUpload config.StorageUpload
+	sealer        *crypto.Sealer
+	completer     DownloadCompleter
+	watermarker   watermark.Transformer
+	verifyWorkers int
+	transfers     *TransferStats
+	events        *events.Bus
+	readOnly      *readonly.Switch
+	// maxDownloadRate throttles downloads of files without a rate of their
+	// own, in bytes per second. Zero leaves them unthrottled.
+	maxDownloadRate int64
+}
+
+// DownloadCompleter issues download nonces and counts a download once every
+// chunk was served to its nonce.
+type DownloadCompleter interface {
+	IssueDownloadNonce(ctx context.Context, shareID string) (string, error)
+	CompleteDownload(ctx context.Context, shareID, nonce string) error
 }
 
-func NewChunkService(repository sqlc.Querier, minioClient *minio.Client, bucketName string) *ChunkService {
+func NewChunkService(repository sqlc.Querier, minioClient *minio.Client, bucketName string, limits config.Limits) *ChunkService {
 	return &ChunkService{
 		repository:  repository,
 		minioClient: minioClient,
 		bucketName:  bucketName,
+		limits:      limits,
+		multipart:   config.DefaultMultipart(),
+
+		verifyWorkers: config.DefaultVerifyWorkers,
 	}
 }
 
@@ -32,6 +143,160 @@ func (cs *ChunkService) GetMinIOClient() *minio.Client {
 	return cs.minioClient
 }
 
+// MaxChunkRequestSize is the largest chunk upload request body accepted.
+func (cs *ChunkService) MaxChunkRequestSize() int64 {
+	return cs.limits.MaxChunkRequestSize
+}
+
+// PresignedUploads reports whether chunks can be uploaded straight to
+// storage through presigned URLs.
+func (cs *ChunkService) PresignedUploads() bool {
+	return cs.presignClient != nil && cs.envelope == nil
+}
+
+// WithDownloadCompleter lets RecordChunksServed count downloads through c
+// as soon as their last chunk is served.
+func (cs *ChunkService) WithDownloadCompleter(c DownloadCompleter) *ChunkService {
+	cs.completer = c
+	return cs
+}
+
+// WithMultipart sets the memory threshold and temporary directory for
+// receiving chunk uploads.
+func (cs *ChunkService) WithMultipart(m config.Multipart) *ChunkService {
+	cs.multipart = m
+	return cs
+}
+
+// Multipart bounds the resources used to receive a chunk upload.
+func (cs *ChunkService) Multipart() config.Multipart {
+	return cs.multipart
+}
+
+// WithStorageUpload tunes the part size, parallelism and multipart use of
+// chunk writes to storage.
+func (cs *ChunkService) WithStorageUpload(u config.StorageUpload) *ChunkService {
+	cs.storageUpload = u
+	return cs
+}
+
+// WithServerEncryption seals the chunks of files uploaded without client
+// encryption with s, and opens them again on download.
+func (cs *ChunkService) WithServerEncryption(s *crypto.Sealer) *ChunkService {
+	cs.sealer = s
+	return cs
+}
+
+// WithWatermarker streams server encrypted shares that have a watermark set
+// through t.
+func (cs *ChunkService) WithWatermarker(t watermark.Transformer) *ChunkService {
+	cs.watermarker = t
+	return cs
+}
+
+// WithVerifyWorkers sets how many chunks of one file VerifyChunks checks at
+// once.
+func (cs *ChunkService) WithVerifyWorkers(n int) *ChunkService {
+	cs.verifyWorkers = max(n, 1)
+	return cs
+}
+
+// WithFairShare paces chunk downloads through s so concurrent shares get an
+// equal slice of download bandwidth.
+func (cs *ChunkService) WithFairShare(s *fairshare.Scheduler) *ChunkService {
+	cs.fairShare = s
+	return cs
+}
+
+// WithEnvelope seals chunks with a per-file data key before they are written
+// to storage, on top of the client's own encryption.
+func (cs *ChunkService) WithEnvelope(e *envelope.Envelope) *ChunkService {
+	cs.envelope = e
+	return cs
+}
+
+// WithAlerts reports storage inconsistencies, such as missing chunk objects,
+// to w.
+func (cs *ChunkService) WithAlerts(w *alert.Webhook) *ChunkService {
+	cs.alerts = w
+	return cs
+}
+
+// WithTransferStats counts the bytes of every chunk served in stats.
+func (cs *ChunkService) WithTransferStats(stats *TransferStats) *ChunkService {
+	cs.transfers = stats
+	return cs
+}
+
+// WithEvents publishes every chunk stored to bus, so the uploader and
+// recipients watching the share see the upload progress.
+func (cs *ChunkService) WithEvents(bus *events.Bus) *ChunkService {
+	cs.events = bus
+	return cs
+}
+
+// WithReadOnly serves chunks and streams without a download nonce while sw
+// is on, since none are issued then, except of shares with a download limit.
+func (cs *ChunkService) WithReadOnly(sw *readonly.Switch) *ChunkService {
+	cs.readOnly = sw
+	return cs
+}
+
+// WithDownloadRate throttles each chunk download and file stream to
+// bytesPerSecond, unless its file has a rate of its own.
+func (cs *ChunkService) WithDownloadRate(bytesPerSecond int64) *ChunkService {
+	cs.maxDownloadRate = bytesPerSecond
+	return cs
+}
+
+// downloadRate is the rate downloads of a file are served at: the file's
+// override when an admin set one, and the global rate otherwise.
+func (cs *ChunkService) downloadRate(override pgtype.Int8) int64 {
+	if override.Valid {
+		return override.Int64
+	}
+	return cs.maxDownloadRate
+}
+
+// WithPresignedUploads lets clients PUT chunks straight to storage through
+// URLs signed by client that stay valid for ttl.
+func (cs *ChunkService) WithPresignedUploads(client *minio.Client, ttl time.Duration) *ChunkService {
+	cs.presignClient = client
+	cs.presignTTL = ttl
+	return cs
+}
+
+func chunkObjectName(fileID pgtype.UUID, chunkIndex int64) string {
+	return fmt.Sprintf("%s/%d.enc", fileID, chunkIndex)
+}
+
+// parseChunkObjectName returns the file and index of a chunk object name.
+// It reports false for objects not named by chunkObjectName.
+func parseChunkObjectName(name string) (pgtype.UUID, int64, bool) {
+	id, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return pgtype.UUID{}, 0, false
+	}
+	index, ok := strings.CutSuffix(rest, ".enc")
+	if !ok {
+		return pgtype.UUID{}, 0, false
+	}
+
+	var fileID pgtype.UUID
+	if err := fileID.Scan(id); err != nil {
+		return pgtype.UUID{}, 0, false
+	}
+	chunkIndex, err := strconv.ParseInt(index, 10, 64)
+	if err != nil || chunkIndex < 0 {
+		return pgtype.UUID{}, 0, false
+	}
+	// Rejects other spellings of the same name, like a leading zero
+	if chunkObjectName(fileID, chunkIndex) != name {
+		return pgtype.UUID{}, 0, false
+	}
+	return fileID, chunkIndex, true
+}
+
 func (cs *ChunkService) existsBy(ctx context.Context, fileID pgtype.UUID, chunkIndex int64) (bool, error) {
 	return cs.repository.ChunkExistsByFileIdAndIndex(ctx, sqlc.ChunkExistsByFileIdAndIndexParams{
 		FileID:     fileID,
@@ -39,44 +304,98 @@ func (cs *ChunkService) existsBy(ctx context.Context, fileID pgtype.UUID, chunkI
 	})
 }
 
-func (cs *ChunkService) createChunkRecord(ctx context.Context, fileID pgtype.UUID, chunkIndex64 int64, sotragePath string, encryptedSize int64, chunkHash string) (int64, error) {
+// createChunkRecord stores a chunk row. Zero durations are stored as
+// unknown, for chunks that did not pass through the API.
+func (cs *ChunkService) createChunkRecord(ctx context.Context, fileID pgtype.UUID, chunkIndex64 int64, sotragePath string, encryptedSize int64, chunkHash string, receive, store time.Duration) (int64, error) {
 	return cs.repository.CreateChunk(ctx, sqlc.CreateChunkParams{
 		FileID:        fileID,
 		ChunkIndex:    int32(chunkIndex64),
 		StoragePath:   sotragePath,
 		EncryptedSize: encryptedSize,
 		ChunkHash:     chunkHash,
+		ReceiveMs:     durationMs(receive),
+		StoreMs:       durationMs(store),
 	})
 }
 
+func durationMs(d time.Duration) pgtype.Int4 {
+	if d <= 0 {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: int32(min(d.Milliseconds(), math.MaxInt32)), Valid: true}
+}
+
+// bytesPerSecond treats durations under a millisecond as one, so chunks
+// faster than the clock resolution do not divide by zero.
+func bytesPerSecond(bytes, ms int64) int64 {
+	return bytes * 1000 / max(ms, 1)
+}
+
+// recordChunkTiming adds a chunk to the chunk_uploads metrics and logs it
+// when it was slow, naming which side was slow.
+func recordChunkTiming(req types.ChunkUploadRequest, store time.Duration) {
+	receiveMs, storeMs := req.ReceiveDuration.Milliseconds(), store.Milliseconds()
+	chunkUploads.Add("chunks", 1)
+	chunkUploads.Add("bytes", req.Size)
+	chunkUploads.Add("receive_ms", receiveMs)
+	chunkUploads.Add("store_ms", storeMs)
+
+	slowReceive := bytesPerSecond(req.Size, receiveMs) < slowChunkBytesPerSecond
+	slowStore := bytesPerSecond(req.Size, storeMs) < slowChunkBytesPerSecond
+	if slowReceive {
+		chunkUploads.Add("slow_receives", 1)
+	}
+	if slowStore {
+		chunkUploads.Add("slow_stores", 1)
+	}
+	if slowReceive || slowStore {
+		slog.Info("slow chunk upload",
+			slog.String("file_id", req.FileID.String()),
+			slog.Int64("chunk_index", req.ChunkIndex),
+			slog.Int64("chunk_size", req.Size),
+			slog.Int64("receive_ms", receiveMs),
+			slog.Int64("store_ms", storeMs),
+		)
+	}
+}
+
 func (cs *ChunkService) ProcessChunkUpload(ctx context.Context, req types.ChunkUploadRequest) (types.ChunkUploadResponse, error) {
 	slog.Debug("processing chunk upload",
 		slog.String("file_id", req.FileID.String()),
 		slog.Int64("chunk_index", req.ChunkIndex),
-		slog.Int("chunk_size", len(req.ChunkData)),
+		slog.Int64("chunk_size", req.Size),
 	)
 
-	// Validate chunk doesn't already exist and file exists with "uploading" status
-	err := cs.validateChunkUpload(ctx, req.FileID, req.ChunkIndex)
-	if err != nil {
-		slog.Warn("chunk validation failed",
-			slog.String("error", err.Error()),
+	// Chunks arrive encrypted, so allow for the per-chunk encryption overhead
+	maxEncryptedSize := cs.limits.MaxChunkSize + e2ee.Overhead
+	if req.Size > maxEncryptedSize {
+		slog.Warn("chunk exceeds maximum size",
 			slog.String("file_id", req.FileID.String()),
 			slog.Int64("chunk_index", req.ChunkIndex),
+			slog.Int64("chunk_size", req.Size),
+			slog.Int64("max_chunk_size", maxEncryptedSize),
 		)
-		return types.ChunkUploadResponse{}, err
+		return types.ChunkUploadResponse{}, fmt.Errorf("%w: size %d exceeds maximum of %d bytes", ErrInvalidChunk, req.Size, maxEncryptedSize)
 	}
 
-	// Validate Hash
-	slog.Debug("validating chunk hash",
-		slog.String("file_id", req.FileID.String()),
-		slog.Int64("chunk_index", req.ChunkIndex),
-		slog.String("expected_hash", req.ExpectedHash),
-	)
+	serverKeyID, err := cs.serverKeyID(ctx, req.FileID)
+	if err != nil {
+		return types.ChunkUploadResponse{}, err
+	}
+	// Server encrypted files arrive as plaintext, without the E2EE overhead
+	var overhead int64 = e2ee.Overhead
+	if serverKeyID != "" {
+		overhead = 0
+	}
 
-	err = cs.validateChunkHash(req.ChunkData, req.ExpectedHash)
+	// Validate chunk doesn't already exist, file exists with "uploading" status
+	// and the chunk has the size the file declared for it
+	file, err := cs.validateChunkUpload(ctx, req.FileID, req.ChunkIndex, req.Size, overhead, req.ExpectedHash)
+	if errors.Is(err, errChunkUnchanged) {
+		return alreadyUploaded(req.FileID, req.ChunkIndex, req.ExpectedHash), nil
+	}
 	if err != nil {
-		slog.Warn("chunk hash validation failed",
+		slog.Warn("chunk validation failed",
 			slog.String("error", err.Error()),
 			slog.String("file_id", req.FileID.String()),
 			slog.Int64("chunk_index", req.ChunkIndex),
@@ -84,16 +403,19 @@ func (cs *ChunkService) ProcessChunkUpload(ctx context.Context, req types.ChunkU
 		return types.ChunkUploadResponse{}, err
 	}
 
-	// Upload to Storage
+	// Upload to Storage, validating the hash on the way
 	slog.Debug("uploading chunk to storage",
 		slog.String("file_id", req.FileID.String()),
 		slog.Int64("chunk_index", req.ChunkIndex),
+		slog.String("expected_hash", req.ExpectedHash),
 	)
 
-	filePath, err := cs.uploadChunkToStorage(ctx, req.FileID, req.ChunkIndex, req.ChunkData, req.ContentType, req.Filename)
+	storeStart := time.Now()
+	filePath, err := cs.uploadChunkToStorage(ctx, req, serverKeyID)
 	if err != nil {
 		return types.ChunkUploadResponse{}, err
 	}
+	storeDuration := time.Since(storeStart)
 
 	// Create chunk metadata record in database
 	slog.Debug("creating chunk metadata record",
@@ -102,13 +424,23 @@ func (cs *ChunkService) ProcessChunkUpload(ctx context.Context, req types.ChunkU
 		slog.String("storage_path", filePath),
 	)
 
-	_, err = cs.createChunkRecord(ctx, req.FileID, req.ChunkIndex, filePath, int64(len(req.ChunkData)), req.ExpectedHash)
+	_, err = cs.createChunkRecord(ctx, req.FileID, req.ChunkIndex, filePath, req.Size, req.ExpectedHash, req.ReceiveDuration, storeDuration)
 	if err != nil {
 		slog.Error("failed to create chunk record",
 			slog.String("error", err.Error()),
 			slog.String("file_id", req.FileID.String()),
 			slog.Int64("chunk_index", req.ChunkIndex),
 		)
+		if errors.Is(err, database.ErrConflict) {
+			// A concurrent upload of the same chunk recorded it first, and
+			// the object written under the shared name now belongs to it
+			return types.ChunkUploadResponse{}, fmt.Errorf("%w: %w", ErrChunkAlreadyUploaded, err)
+		}
+		cs.discardUnrecordedChunk(ctx, req.FileID, req.ChunkIndex, filePath)
+		if errors.Is(err, database.ErrInvalidRef) {
+			// The file was deleted, e.g. by cleanup, while its chunk was stored
+			return types.ChunkUploadResponse{}, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
 		return types.ChunkUploadResponse{}, err
 	}
 
@@ -117,47 +449,59 @@ func (cs *ChunkService) ProcessChunkUpload(ctx context.Context, req types.ChunkU
 		slog.Int64("chunk_index", req.ChunkIndex),
 		slog.String("hash", req.ExpectedHash),
 	)
+	recordChunkTiming(req, storeDuration)
+	cs.announceChunk(file, req.ChunkIndex)
 
 	return types.ChunkUploadResponse{
 		ChunkIndex:   req.ChunkIndex,
-		Status:       "uploaded",
+		Status:       ChunkStatusUploaded,
 		ReceivedHash: req.ExpectedHash,
+		ReceiveMs:    req.ReceiveDuration.Milliseconds(),
+		StoreMs:      storeDuration.Milliseconds(),
 	}, nil
 }
 
 func (cs *ChunkService) validateChunkHash(data []byte, expectedHash string) error {
-	computedHash := crypto.HashBytes(data)
+	return compareChunkHash(expectedHash, crypto.HashBytes(data))
+}
+
+func compareChunkHash(expectedHash, computedHash string) error {
 	if !crypto.CompareHash(expectedHash, computedHash) {
-		return fmt.Errorf("hash mismatch for chunk upload")
+		return ErrHashMismatch
 	}
 
 	return nil
 }
 
-func (cs *ChunkService) uploadChunkToStorage(ctx context.Context, fileID pgtype.UUID, chunkIndex int64,
-	data []byte, contentType, filename string,
-) (string, error) {
-	objectName := fmt.Sprintf("%s/%d.enc", fileID, chunkIndex)
-	reader := bytes.NewReader(data)
-
-	_, err := cs.GetMinIOClient().PutObject(
-		ctx,
-		cs.bucketName,
-		objectName,
-		reader,
-		int64(len(data)),
-		minio.PutObjectOptions{
-			ContentType: contentType,
-			UserMetadata: map[string]string{
-				"original-filename": filename,
-			},
+// uploadChunkToStorage streams the chunk into storage while hashing it, so
+// memory stays flat however large chunks are. A chunk whose hash turns out
+// not to match is removed again. Sealed chunks are the exception: the
+// envelope cipher and the server key need the whole chunk, so they are
+// buffered and checked before upload. Chunks of server encrypted files are
+// sealed with the server key only.
+func (cs *ChunkService) uploadChunkToStorage(ctx context.Context, req types.ChunkUploadRequest, serverKeyID string) (string, error) {
+	objectName := chunkObjectName(req.FileID, req.ChunkIndex)
+	opts := minio.PutObjectOptions{
+		ContentType: req.ContentType,
+		UserMetadata: map[string]string{
+			"original-filename": req.Filename,
 		},
-	)
+	}
+
+	var err error
+	switch {
+	case serverKeyID != "":
+		err = cs.putSealedChunk(ctx, req, objectName, opts, cs.serverSealChunk)
+	case cs.envelope != nil:
+		err = cs.putSealedChunk(ctx, req, objectName, opts, cs.sealChunk)
+	default:
+		err = cs.putChunkStream(ctx, req, objectName, opts)
+	}
 	if err != nil {
 		slog.Error("failed to upload chunk to storage",
 			slog.String("error", err.Error()),
-			slog.String("file_id", fmt.Sprintf("%x-%x-%x-%x-%x", fileID.Bytes[0:4], fileID.Bytes[4:6], fileID.Bytes[6:8], fileID.Bytes[8:10], fileID.Bytes[10:16])),
-			slog.Int64("chunk_index", chunkIndex),
+			slog.String("file_id", req.FileID.String()),
+			slog.Int64("chunk_index", req.ChunkIndex),
 			slog.String("object_name", objectName),
 		)
 		return "", err
@@ -166,100 +510,1371 @@ func (cs *ChunkService) uploadChunkToStorage(ctx context.Context, fileID pgtype.
 	return objectName, nil
 }
 
-func (cs *ChunkService) validateChunkUpload(ctx context.Context, fileID pgtype.UUID, chunkIndex int64) error {
-	// Validate chunk doesn't already exist
-	exists, err := cs.existsBy(ctx, fileID, chunkIndex)
+func (cs *ChunkService) putChunkStream(ctx context.Context, req types.ChunkUploadRequest, objectName string, opts minio.PutObjectOptions) error {
+	// Everything PutObject reads is copied into the hasher
+	pr, pw := io.Pipe()
+	hashed := make(chan hashResult, 1)
+	go func() {
+		hash, err := crypto.HashReader(pr)
+		hashed <- hashResult{hash: hash, err: err}
+	}()
+
+	size := cs.tunePut(&opts, req.Size, false)
+	err := cs.putObject(ctx, objectName, io.TeeReader(req.Chunk, pw), size, opts)
+	pw.CloseWithError(err)
+	result := <-hashed
 	if err != nil {
-		return fmt.Errorf("failed to check chunk existence: %w", err)
+		return err
 	}
-	if exists {
-		return fmt.Errorf("chunk %d already uploaded for file %s", chunkIndex, fileID.Bytes)
+	if result.err != nil {
+		return fmt.Errorf("failed to hash chunk: %w", result.err)
 	}
 
-	// Validate file exists with "uploading" status
-	exists, err = cs.fileExistsByIdAndStatus(ctx, fileID, "uploading")
-	if err != nil {
-		return fmt.Errorf("failed to verify file status: %w", err)
-	}
-	if !exists {
-		return fmt.Errorf("file %s does not exist or is not in uploading state", fileID.Bytes)
+	if err := compareChunkHash(req.ExpectedHash, result.hash); err != nil {
+		cs.removeChunkObject(ctx, objectName)
+		return err
 	}
 	return nil
 }
 
-func (cs *ChunkService) fileExistsByIdAndStatus(ctx context.Context, fileID pgtype.UUID, status string) (bool, error) {
-	return cs.repository.FileExistsByIdAndStatus(ctx, sqlc.FileExistsByIdAndStatusParams{
-		ID:     fileID,
-		Status: status,
-	})
+type hashResult struct {
+	hash string
+	err  error
 }
 
-func (cs *ChunkService) DownloadChunk(ctx context.Context, shareID string, chunkIndex int64) (io.ReadCloser, error) {
-	slog.Debug("fetching chunk details",
-		slog.String("share_id", shareID),
-		slog.Int64("chunk_index", chunkIndex),
-	)
+// sealFunc encrypts a whole chunk before it is written to objectName.
+type sealFunc func(ctx context.Context, fileID pgtype.UUID, objectName string, data []byte) ([]byte, error)
 
-	chunkDetails, err := cs.repository.GetChunkByIndexAndFileShareID(ctx, sqlc.GetChunkByIndexAndFileShareIDParams{
-		ShareID:    shareID,
-		ChunkIndex: int32(chunkIndex),
-	})
+func (cs *ChunkService) putSealedChunk(ctx context.Context, req types.ChunkUploadRequest, objectName string, opts minio.PutObjectOptions, seal sealFunc) error {
+	data, err := io.ReadAll(io.LimitReader(req.Chunk, req.Size))
+	if err != nil {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if int64(len(data)) != req.Size {
+		return fmt.Errorf("%w: read %d of %d bytes", ErrInvalidChunk, len(data), req.Size)
+	}
+
+	if err := cs.validateChunkHash(data, req.ExpectedHash); err != nil {
+		return err
+	}
 
+	sealed, err := seal(ctx, req.FileID, objectName, data)
 	if err != nil {
-		slog.Warn("failed to get chunk metadata",
+		return fmt.Errorf("failed to seal chunk: %w", err)
+	}
+
+	size := cs.tunePut(&opts, int64(len(sealed)), true)
+	return cs.putObject(ctx, objectName, bytes.NewReader(sealed), size, opts)
+}
+
+// storageCleanupTimeout bounds the cleanup after a failed chunk write, which
+// outlives the request that failed.
+const storageCleanupTimeout = 30 * time.Second
+
+// putObject writes a chunk object within the storage put timeout. The
+// storage client aborts a failed multipart upload itself, but with the
+// context of the PUT, which is already done when the deadline passed or the
+// client went away; those uploads are aborted again here so their parts do
+// not linger until the stale upload sweep.
+func (cs *ChunkService) putObject(ctx context.Context, objectName string, r io.Reader, size int64, opts minio.PutObjectOptions) error {
+	putCtx := ctx
+	if timeout := cs.storageUpload.PutTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		putCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	_, err := cs.GetMinIOClient().PutObject(putCtx, cs.bucketName, objectName, r, size, opts)
+	if err == nil {
+		return nil
+	}
+	if cs.mayUseMultipart(size, opts) {
+		cs.abortIncompleteUpload(ctx, objectName)
+	}
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%w after %s: %w", ErrStorageTimeout, cs.storageUpload.PutTimeout, err)
+	}
+	return err
+}
+
+// mayUseMultipart reports whether the storage client may have started a
+// multipart upload for a PUT of size bytes with opts.
+func (cs *ChunkService) mayUseMultipart(size int64, opts minio.PutObjectOptions) bool {
+	if opts.DisableMultipart {
+		return false
+	}
+	partSize := int64(opts.PartSize)
+	if partSize == 0 {
+		partSize = defaultStoragePartSize
+	}
+	return size < 0 || size > partSize
+}
+
+// abortIncompleteUpload aborts the incomplete multipart uploads of
+// objectName. It outlives ctx so that it still runs for canceled requests.
+func (cs *ChunkService) abortIncompleteUpload(ctx context.Context, objectName string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storageCleanupTimeout)
+	defer cancel()
+	if err := cs.GetMinIOClient().RemoveIncompleteUpload(ctx, cs.bucketName, objectName); err != nil {
+		slog.Error("failed to abort incomplete chunk upload",
 			slog.String("error", err.Error()),
-			slog.String("share_id", shareID),
-			slog.Int64("chunk_index", chunkIndex),
+			slog.String("object_name", objectName),
 		)
-		return nil, fmt.Errorf("failed to get chunk storage path: %w", err)
 	}
+}
 
-	if chunkDetails.DownloadCount >= chunkDetails.MaxDownloads {
-		slog.Warn("chunk download limit reached",
-			slog.String("share_id", shareID),
-			slog.Int64("chunk_index", chunkIndex),
-			slog.Int("download_count", int(chunkDetails.DownloadCount)),
-			slog.Int("max_downloads", int(chunkDetails.MaxDownloads)),
+// serverSealChunk seals a plaintext chunk with the server key, bound to its
+// object name like envelope sealed chunks.
+func (cs *ChunkService) serverSealChunk(_ context.Context, _ pgtype.UUID, objectName string, data []byte) ([]byte, error) {
+	return cs.sealer.Seal(data, []byte(objectName))
+}
+
+// serverKeyID returns the server key a file's chunks are sealed with, or ""
+// for files the client encrypts. Without server encryption configured every
+// file is taken to be client encrypted.
+func (cs *ChunkService) serverKeyID(ctx context.Context, fileID pgtype.UUID) (string, error) {
+	if cs.sealer == nil {
+		return "", nil
+	}
+	keyID, err := cs.repository.GetServerEncryptionKeyIdByFileId(ctx, fileID)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check server encryption: %w", err)
+	}
+	return keyID, nil
+}
+
+// defaultStoragePartSize is the part size the storage client uses when none
+// is configured.
+const defaultStoragePartSize = 16 << 20
+
+// tunePut applies the storage upload tuning to opts for a PUT of size bytes
+// and returns the size to pass to PutObject. The client uploads parts of a
+// reader that cannot seek in parallel only when it is not told the size, so
+// ConcurrentStreamParts hides it; the chunk hash still covers every byte.
+func (cs *ChunkService) tunePut(opts *minio.PutObjectOptions, size int64, seekable bool) int64 {
+	u := cs.storageUpload
+	opts.PartSize = u.PartSize
+	opts.NumThreads = u.Threads
+
+	if u.SinglePutMaxBytes > 0 && size <= u.SinglePutMaxBytes {
+		opts.DisableMultipart = true
+		return size
+	}
+
+	partSize := int64(u.PartSize)
+	if partSize == 0 {
+		partSize = defaultStoragePartSize
+	}
+	if u.ConcurrentStreamParts && !seekable && size > partSize {
+		opts.ConcurrentStreamParts = true
+		return -1
+	}
+	return size
+}
+
+// sealChunk encrypts data with the file's data key, creating the key on the
+// file's first chunk. The object name is bound as associated data so sealed
+// chunks cannot be swapped between objects.
+func (cs *ChunkService) sealChunk(ctx context.Context, fileID pgtype.UUID, objectName string, data []byte) ([]byte, error) {
+	dataKey, err := cs.fileDataKey(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Seal(dataKey, data, []byte(objectName))
+}
+
+func (cs *ChunkService) fileDataKey(ctx context.Context, fileID pgtype.UUID) ([]byte, error) {
+	key, err := cs.repository.GetFileKeyByFileId(ctx, fileID)
+	if err == nil {
+		return cs.envelope.UnwrapDataKey(ctx, key.KeyID, key.WrappedKey)
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get file key: %w", err)
+	}
+
+	dataKey, wrapped, err := cs.envelope.NewDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := cs.repository.CreateFileKey(ctx, sqlc.CreateFileKeyParams{
+		FileID:     fileID,
+		KeyID:      cs.envelope.KeyID(),
+		WrappedKey: wrapped,
+		Algorithm:  envelope.Algorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store file key: %w", err)
+	}
+	if created == 1 {
+		return dataKey, nil
+	}
+
+	// A parallel chunk upload stored the file's key first; use theirs
+	key, err = cs.repository.GetFileKeyByFileId(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file key: %w", err)
+	}
+	return cs.envelope.UnwrapDataKey(ctx, key.KeyID, key.WrappedKey)
+}
+
+// PresignChunkUploads returns POST forms for up to PresignBatchSize chunks of
+// file from chunk first on, together with the time they stop working. Each
+// form only accepts its chunk at the exact encrypted size the file expects.
+// Chunks uploaded this way bypass the API, so they cannot be sealed with
+// envelope encryption.
+func (cs *ChunkService) PresignChunkUploads(ctx context.Context, file sqlc.File, first int32) ([]types.PresignedChunkUpload, time.Time, error) {
+	if !cs.PresignedUploads() {
+		return nil, time.Time{}, ErrPresignedDisabled
+	}
+	if first < 0 || first >= file.ChunkCount {
+		return nil, time.Time{}, fmt.Errorf("%w %d: file has %d chunks", ErrInvalidChunkIndex, first, file.ChunkCount)
+	}
+
+	expiresAt := time.Now().Add(cs.presignTTL)
+	uploads := make([]types.PresignedChunkUpload, min(file.ChunkCount-first, PresignBatchSize))
+	for i := range uploads {
+		index := first + int32(i)
+		size, err := expectedChunkSize(file, int64(index), e2ee.Overhead)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		policy := minio.NewPostPolicy()
+		err = errors.Join(
+			policy.SetBucket(cs.bucketName),
+			policy.SetKey(chunkObjectName(file.ID, int64(index))),
+			policy.SetExpires(expiresAt),
+			policy.SetContentLengthRange(size, size),
 		)
-		return nil, fmt.Errorf("chunk download limit reached")
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to build upload policy for chunk %d: %w", index, err)
+		}
+		u, fields, err := cs.presignClient.PresignedPostPolicy(ctx, policy)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to presign chunk %d: %w", index, err)
+		}
+		uploads[i] = types.PresignedChunkUpload{
+			ChunkIndex: index,
+			Method:     http.MethodPost,
+			URL:        u.String(),
+			Fields:     fields,
+		}
 	}
+	return uploads, expiresAt, nil
+}
 
-	slog.Debug("retrieving chunk from storage",
-		slog.String("share_id", shareID),
-		slog.Int64("chunk_index", chunkIndex),
-		slog.String("storage_path", chunkDetails.StoragePath),
-	)
+// ChunkUploadURLs presigns the next batch of chunk uploads, from chunk first
+// on, for a file still uploading. Init only returns the first batch.
+func (cs *ChunkService) ChunkUploadURLs(ctx context.Context, fileID pgtype.UUID, first int32) (types.ChunkUploadURLsResponse, error) {
+	if !cs.PresignedUploads() {
+		return types.ChunkUploadURLsResponse{}, ErrPresignedDisabled
+	}
 
-	chunk, err := cs.minioClient.GetObject(
-		ctx,
-		cs.bucketName,
-		chunkDetails.StoragePath,
-		minio.GetObjectOptions{},
-	)
+	file, err := cs.repository.GetFileByID(ctx, fileID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return types.ChunkUploadURLsResponse{}, fmt.Errorf("failed to get file: %w", err)
+	}
+	if err != nil || file.Status != "uploading" {
+		return types.ChunkUploadURLsResponse{}, fmt.Errorf("file %s does not exist or is %w", fileID.Bytes, ErrNotUploading)
+	}
+
+	uploads, expiresAt, err := cs.PresignChunkUploads(ctx, file, first)
 	if err != nil {
-		slog.Error("failed to retrieve chunk from storage",
+		return types.ChunkUploadURLsResponse{}, err
+	}
+	return types.ChunkUploadURLsResponse{
+		UploadURLs:         uploads,
+		UploadURLsExpireAt: formatTime(expiresAt),
+	}, nil
+}
+
+// ConfirmChunkUpload records a chunk the client uploaded through a presigned
+// URL once its object exists with the size and hash the file expects. A
+// chunk that does not match is removed so it can be uploaded again.
+func (cs *ChunkService) ConfirmChunkUpload(ctx context.Context, fileID pgtype.UUID, chunkIndex int64, expectedHash string) (types.ChunkUploadResponse, error) {
+	if cs.presignClient == nil {
+		return types.ChunkUploadResponse{}, ErrPresignedDisabled
+	}
+	if expectedHash == "" {
+		return types.ChunkUploadResponse{}, fmt.Errorf("%w: hash is required", ErrInvalidChunk)
+	}
+
+	objectName := chunkObjectName(fileID, chunkIndex)
+	info, err := cs.minioClient.StatObject(ctx, cs.bucketName, objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return types.ChunkUploadResponse{}, ErrChunkNotUploaded
+		}
+		return types.ChunkUploadResponse{}, fmt.Errorf("failed to stat chunk: %w", err)
+	}
+
+	file, err := cs.validateChunkUpload(ctx, fileID, chunkIndex, info.Size, e2ee.Overhead, expectedHash)
+	if errors.Is(err, errChunkUnchanged) {
+		return alreadyUploaded(fileID, chunkIndex, expectedHash), nil
+	}
+	if err != nil {
+		slog.Warn("presigned chunk validation failed",
 			slog.String("error", err.Error()),
-			slog.String("share_id", shareID),
+			slog.String("file_id", fileID.String()),
 			slog.Int64("chunk_index", chunkIndex),
-			slog.String("storage_path", chunkDetails.StoragePath),
 		)
-		return nil, fmt.Errorf("failed to download chunk from storage: %w", err)
+		if errors.Is(err, ErrInvalidChunkSize) {
+			cs.removeChunkObject(ctx, objectName)
+		}
+		return types.ChunkUploadResponse{}, err
 	}
 
-	if _, err := chunk.Stat(); err != nil {
-		chunk.Close()
-		slog.Error("failed to stat chunk object",
+	hash, err := cs.storedChunkHash(ctx, objectName, info)
+	if err != nil {
+		return types.ChunkUploadResponse{}, err
+	}
+	if err := compareChunkHash(expectedHash, hash); err != nil {
+		cs.removeChunkObject(ctx, objectName)
+		return types.ChunkUploadResponse{}, err
+	}
+
+	if _, err := cs.createChunkRecord(ctx, fileID, chunkIndex, objectName, info.Size, expectedHash, 0, 0); err != nil {
+		slog.Error("failed to create chunk record",
 			slog.String("error", err.Error()),
-			slog.String("share_id", shareID),
+			slog.String("file_id", fileID.String()),
 			slog.Int64("chunk_index", chunkIndex),
 		)
-		return nil, fmt.Errorf("failed to stat chunk: %w", err)
+		return types.ChunkUploadResponse{}, err
 	}
 
-	slog.Info("chunk retrieved successfully",
-		slog.String("share_id", shareID),
+	slog.Info("presigned chunk confirmed",
+		slog.String("file_id", fileID.String()),
 		slog.Int64("chunk_index", chunkIndex),
+		slog.String("hash", expectedHash),
 	)
+	cs.announceChunk(file, chunkIndex)
 
-	return chunk, nil
+	return types.ChunkUploadResponse{
+		ChunkIndex:   chunkIndex,
+		Status:       ChunkStatusUploaded,
+		ReceivedHash: expectedHash,
+	}, nil
+}
+
+// storedChunkHash returns the hex SHA-256 of an object. Storage reports it
+// when the client sent a checksum with the upload; otherwise the object is
+// read back and hashed.
+func (cs *ChunkService) storedChunkHash(ctx context.Context, objectName string, info minio.ObjectInfo) (string, error) {
+	if info.ChecksumSHA256 != "" {
+		sum, err := base64.StdEncoding.DecodeString(info.ChecksumSHA256)
+		if err == nil {
+			return hex.EncodeToString(sum), nil
+		}
+	}
+
+	obj, err := cs.minioClient.GetObject(ctx, cs.bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to read chunk: %w", err)
+	}
+	defer obj.Close()
+
+	hash, err := crypto.HashReader(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash chunk: %w", err)
+	}
+	return hash, nil
+}
+
+// VerifyChunks checks every chunk row of a file against its object in
+// storage: the object must exist with the size the row implies. With rehash
+// each object is also read back, opened if sealed, and hashed. A chunk that
+// does not match fails with ErrChunkMismatch; any other error means storage
+// could not be checked. Chunks are checked by up to WithVerifyWorkers at
+// once, and progress, if not nil, is called with the number checked so far
+// after each one.
+func (cs *ChunkService) VerifyChunks(ctx context.Context, fileID pgtype.UUID, rehash bool, progress func(verified int)) error {
+	chunks, err := cs.repository.ListChunksByFileId(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	// Sealed objects are larger than the chunk the client sent by the
+	// overhead of the seal, and must be opened before they are hashed
+	var overhead int64
+	var open func(objectName string, stored []byte) ([]byte, error)
+
+	serverKeyID, err := cs.serverKeyID(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if serverKeyID != "" {
+		overhead = crypto.SealerOverhead
+		open = func(objectName string, stored []byte) ([]byte, error) {
+			return cs.sealer.Open(serverKeyID, stored, []byte(objectName))
+		}
+	} else {
+		fileKey, err := cs.repository.GetFileKeyByFileId(ctx, fileID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("failed to get file key: %w", err)
+		}
+		if err == nil {
+			overhead = envelope.Overhead
+			if rehash {
+				if cs.envelope == nil {
+					return ErrStorageKeyUnavailable
+				}
+				dataKey, err := cs.envelope.UnwrapDataKey(ctx, fileKey.KeyID, fileKey.WrappedKey)
+				if err != nil {
+					return err
+				}
+				open = func(objectName string, stored []byte) ([]byte, error) {
+					return envelope.Open(dataKey, stored, []byte(objectName))
+				}
+			}
+		}
+	}
+
+	return cs.verifyInParallel(ctx, chunks, func(ctx context.Context, chunk sqlc.Chunk) error {
+		return cs.verifyChunk(ctx, chunk, overhead, rehash, open)
+	}, progress)
+}
+
+// verifyInParallel runs verify over chunks with at most verifyWorkers at
+// once. The first failure stops the rest and is returned.
+func (cs *ChunkService) verifyInParallel(ctx context.Context, chunks []sqlc.Chunk, verify func(context.Context, sqlc.Chunk) error, progress func(int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		mu       sync.Mutex
+		verified int
+	)
+	queue := make(chan sqlc.Chunk)
+	for range min(cs.verifyWorkers, len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range queue {
+				if err := verify(ctx, chunk); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				if progress != nil {
+					mu.Lock()
+					verified++
+					progress(verified)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, chunk := range chunks {
+		select {
+		case queue <- chunk:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (cs *ChunkService) verifyChunk(ctx context.Context, chunk sqlc.Chunk, overhead int64, rehash bool, open func(string, []byte) ([]byte, error)) error {
+	info, err := cs.minioClient.StatObject(ctx, cs.bucketName, chunk.StoragePath, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return fmt.Errorf("%w: chunk %d is missing from storage", ErrChunkMismatch, chunk.ChunkIndex)
+		}
+		return fmt.Errorf("failed to stat chunk %d: %w", chunk.ChunkIndex, err)
+	}
+	if want := chunk.EncryptedSize + overhead; info.Size != want {
+		return fmt.Errorf("%w: chunk %d is %d bytes in storage, expected %d", ErrChunkMismatch, chunk.ChunkIndex, info.Size, want)
+	}
+	if !rehash {
+		return nil
+	}
+
+	obj, err := cs.minioClient.GetObject(ctx, cs.bucketName, chunk.StoragePath, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %d: %w", chunk.ChunkIndex, err)
+	}
+	defer obj.Close()
+
+	var hash string
+	if open == nil {
+		hash, err = crypto.HashReader(obj)
+		if err != nil {
+			return fmt.Errorf("failed to hash chunk %d: %w", chunk.ChunkIndex, err)
+		}
+	} else {
+		stored, err := io.ReadAll(obj)
+		if err != nil {
+			return fmt.Errorf("failed to read chunk %d: %w", chunk.ChunkIndex, err)
+		}
+		data, err := open(chunk.StoragePath, stored)
+		if err != nil {
+			return fmt.Errorf("%w: chunk %d cannot be opened: %w", ErrChunkMismatch, chunk.ChunkIndex, err)
+		}
+		hash = crypto.HashBytes(data)
+	}
+
+	if !crypto.CompareHash(chunk.ChunkHash, hash) {
+		return fmt.Errorf("%w: chunk %d hash differs", ErrChunkMismatch, chunk.ChunkIndex)
+	}
+	return nil
+}
+
+// alreadyUploaded answers a retried chunk that is stored with the same hash.
+// Its body is not read or written again.
+func alreadyUploaded(fileID pgtype.UUID, chunkIndex int64, hash string) types.ChunkUploadResponse {
+	slog.Info("chunk already uploaded with the same hash",
+		slog.String("file_id", fileID.String()),
+		slog.Int64("chunk_index", chunkIndex),
+	)
+	return types.ChunkUploadResponse{
+		ChunkIndex:   chunkIndex,
+		Status:       ChunkStatusAlreadyUploaded,
+		ReceivedHash: hash,
+	}
+}
+
+// discardUnrecordedChunk removes the object of a chunk whose row could not
+// be created. An insert that reported an error may still have committed, so
+// the object is only removed once the row is known to be missing; if that
+// cannot be told, reconciliation removes it later.
+func (cs *ChunkService) discardUnrecordedChunk(ctx context.Context, fileID pgtype.UUID, chunkIndex int64, objectName string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storageCleanupTimeout)
+	defer cancel()
+
+	exists, err := cs.existsBy(ctx, fileID, chunkIndex)
+	if err != nil {
+		slog.Warn("left unrecorded chunk for reconciliation",
+			slog.String("error", err.Error()),
+			slog.String("object_name", objectName),
+		)
+		return
+	}
+	if !exists {
+		cs.removeChunkObject(ctx, objectName)
+	}
+}
+
+// isAliasViolation reports whether a live share already holds the alias a
+// file was created with.
+func isAliasViolation(err error) bool {
+	return errors.Is(err, database.ErrConflict) && database.Constraint(err) == "idx_files_alias"
+}
+
+// isShareIDViolation reports whether a file was created with a share ID
+// another file already has.
+func isShareIDViolation(err error) bool {
+	return errors.Is(err, database.ErrConflict) && database.Constraint(err) == "files_share_id_key"
+}
+
+func (cs *ChunkService) removeChunkObject(ctx context.Context, objectName string) {
+	if err := cs.minioClient.RemoveObject(ctx, cs.bucketName, objectName, minio.RemoveObjectOptions{}); err != nil {
+		slog.Error("failed to remove rejected chunk",
+			slog.String("error", err.Error()),
+			slog.String("object_name", objectName),
+		)
+	}
+}
+
+// validateChunkUpload returns the file a new chunk belongs to. It returns
+// errChunkUnchanged for a chunk already stored with expectedHash, and
+// ErrChunkAlreadyUploaded if it is stored with another.
+func (cs *ChunkService) validateChunkUpload(ctx context.Context, fileID pgtype.UUID, chunkIndex int64, size, overhead int64, expectedHash string) (sqlc.File, error) {
+	// Validate chunk doesn't already exist
+	exists, err := cs.existsBy(ctx, fileID, chunkIndex)
+	if err != nil {
+		return sqlc.File{}, fmt.Errorf("failed to check chunk existence: %w", err)
+	}
+	if exists {
+		storedHash, err := cs.repository.GetChunkHashByFileIdAndIndex(ctx, sqlc.GetChunkHashByFileIdAndIndexParams{
+			FileID:     fileID,
+			ChunkIndex: int32(chunkIndex),
+		})
+		if err != nil {
+			return sqlc.File{}, fmt.Errorf("failed to get stored chunk hash: %w", err)
+		}
+		if expectedHash != "" && crypto.CompareHash(storedHash, expectedHash) {
+			return sqlc.File{}, errChunkUnchanged
+		}
+		return sqlc.File{}, fmt.Errorf("%w: chunk %d of file %s", ErrChunkAlreadyUploaded, chunkIndex, fileID.Bytes)
+	}
+
+	// Validate file exists with "uploading" status
+	file, err := cs.repository.GetFileByID(ctx, fileID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return sqlc.File{}, fmt.Errorf("failed to verify file status: %w", err)
+	}
+	if err != nil || file.Status != "uploading" {
+		return sqlc.File{}, fmt.Errorf("file %s does not exist or is %w", fileID.Bytes, ErrNotUploading)
+	}
+
+	expected, err := expectedChunkSize(file, chunkIndex, overhead)
+	if err != nil {
+		return sqlc.File{}, err
+	}
+	if size != expected {
+		return sqlc.File{}, fmt.Errorf("%w: chunk %d is %d bytes, expected %d", ErrInvalidChunkSize, chunkIndex, size, expected)
+	}
+	return file, nil
+}
+
+// announceChunk publishes a newly recorded chunk of file. Chunk rows are
+// unique, so each chunk is announced once.
+func (cs *ChunkService) announceChunk(file sqlc.File, chunkIndex int64) {
+	cs.events.Publish(events.Event{
+		Type:       events.TypeChunkReceived,
+		ShareID:    file.ShareID,
+		ChunkIndex: int32(chunkIndex),
+		ChunkCount: file.ChunkCount,
+	})
+}
+
+// expectedChunkSize returns the uploaded size of chunk chunkIndex: the
+// declared chunk_size, or the remainder of total_size for the final chunk,
+// plus the per-chunk encryption overhead.
+func expectedChunkSize(file sqlc.File, chunkIndex, overhead int64) (int64, error) {
+	if chunkIndex < 0 || chunkIndex >= int64(file.ChunkCount) {
+		return 0, fmt.Errorf("%w %d: file has %d chunks", ErrInvalidChunkIndex, chunkIndex, file.ChunkCount)
+	}
+
+	size := int64(file.ChunkSize)
+	if chunkIndex == int64(file.ChunkCount)-1 {
+		size = file.TotalSize - int64(file.ChunkSize)*(int64(file.ChunkCount)-1)
+	}
+	return size + overhead, nil
+}
+
+// GetUploadProgress returns the indexes of chunks already persisted for a file
+// so clients can resume an interrupted upload.
+func (cs *ChunkService) GetUploadProgress(ctx context.Context, fileID pgtype.UUID) (types.UploadProgressResponse, error) {
+	file, err := cs.repository.GetFileByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.UploadProgressResponse{}, ErrNotFound
+		}
+		return types.UploadProgressResponse{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	indexes, err := cs.repository.ListChunkIndexesByFileId(ctx, fileID)
+	if err != nil {
+		slog.Error("failed to list uploaded chunks",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileID.String()),
+		)
+		return types.UploadProgressResponse{}, fmt.Errorf("failed to list uploaded chunks: %w", err)
+	}
+	// A fresh upload has none, which must still encode as []
+	if indexes == nil {
+		indexes = []int32{}
+	}
+
+	slog.Debug("upload progress fetched",
+		slog.String("file_id", fileID.String()),
+		slog.Int("uploaded_chunks", len(indexes)),
+		slog.Int("expected_chunks", int(file.ChunkCount)),
+	)
+
+	return types.UploadProgressResponse{
+		FileID:         fileID.String(),
+		ChunkCount:     file.ChunkCount,
+		UploadedChunks: indexes,
+		Throughput:     cs.uploadThroughput(ctx, fileID),
+	}, nil
+}
+
+// GetUploadStatus reports the status of a file with how many of its chunks
+// are stored, so upload UIs can show progress and notice a finalize that
+// stopped verifying.
+func (cs *ChunkService) GetUploadStatus(ctx context.Context, fileID pgtype.UUID) (types.UploadStatusResponse, error) {
+	file, err := cs.repository.GetFileByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.UploadStatusResponse{}, ErrNotFound
+		}
+		return types.UploadStatusResponse{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	uploaded, err := cs.repository.CountChunksByFileId(ctx, fileID)
+	if err != nil {
+		return types.UploadStatusResponse{}, fmt.Errorf("failed to count uploaded chunks: %w", err)
+	}
+
+	status := types.UploadStatusResponse{
+		FileID:         fileID.String(),
+		Status:         file.Status,
+		ChunkCount:     file.ChunkCount,
+		UploadedChunks: uploaded,
+	}
+	if file.Status == FileStatusVerifying && file.VerifyStartedAt.Valid {
+		started := file.VerifyStartedAt.Time.UTC()
+		status.VerifyStartedAt = &started
+		status.Stalled = time.Since(started) > StaleVerificationAge
+	}
+	return status, nil
+}
+
+// uploadThroughput averages the most recent timed chunks of a file. It is
+// advisory, so failures are logged and leave it out.
+func (cs *ChunkService) uploadThroughput(ctx context.Context, fileID pgtype.UUID) *types.UploadThroughput {
+	row, err := cs.repository.GetChunkThroughput(ctx, sqlc.GetChunkThroughputParams{
+		FileID: fileID,
+		Limit:  throughputWindowChunks,
+	})
+	if err != nil {
+		slog.Warn("failed to get upload throughput",
+			slog.String("error", err.Error()),
+			slog.String("file_id", fileID.String()),
+		)
+		return nil
+	}
+	if row.Chunks == 0 {
+		return nil
+	}
+
+	return &types.UploadThroughput{
+		WindowChunks:          row.Chunks,
+		ClientBytesPerSecond:  bytesPerSecond(row.Bytes, row.ReceiveMs),
+		StorageBytesPerSecond: bytesPerSecond(row.Bytes, row.StoreMs),
+	}
+}
+
+// GetDownloadManifest lists the size and hash of every chunk of a ready
+// share so clients can fetch chunks in parallel.
+func (cs *ChunkService) GetDownloadManifest(ctx context.Context, shareID string) (types.DownloadManifestResponse, error) {
+	rows, err := cs.repository.ListChunkManifestByShareId(ctx, shareID)
+	if err != nil {
+		slog.Error("failed to list chunk manifest",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return types.DownloadManifestResponse{}, fmt.Errorf("failed to list chunk manifest: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return types.DownloadManifestResponse{}, cs.missingShare(ctx, shareID)
+	}
+
+	if downloadLimitReached(rows[0].DownloadCount, rows[0].MaxDownloads) {
+		slog.Warn("manifest download limit reached",
+			slog.String("share_id", shareID),
+			slog.Int("download_count", int(rows[0].DownloadCount)),
+			slog.Int("max_downloads", int(rows[0].MaxDownloads)),
+		)
+		return types.DownloadManifestResponse{}, ErrDownloadLimitReached
+	}
+
+	chunks := make([]types.ManifestChunk, len(rows))
+	for i, row := range rows {
+		chunks[i] = types.ManifestChunk{
+			Index: row.ChunkIndex,
+			Size:  row.EncryptedSize,
+			Hash:  row.ChunkHash,
+			ETag:  chunkETag(row.ChunkHash),
+		}
+	}
+
+	return types.DownloadManifestResponse{
+		ShareID:    shareID,
+		ChunkCount: rows[0].ChunkCount,
+		TotalSize:  rows[0].TotalSize,
+		Chunks:     chunks,
+	}, nil
+}
+
+// StreamFile opens all chunks of a ready share as one stream, for clients
+// that cannot fetch chunks themselves. The first chunk is opened before
+// returning so that a share which cannot be served fails before any of the
+// response is written; later chunks are opened as the stream reaches them.
+//
+// Shares with a watermark are streamed through the watermarker, marked for
+// clientIP. Their size is then unknown, and Size is -1.
+func (cs *ChunkService) StreamFile(ctx context.Context, shareID, clientIP string) (types.FileStream, error) {
+	manifest, err := cs.GetDownloadManifest(ctx, shareID)
+	if err != nil {
+		return types.FileStream{}, err
+	}
+
+	mark, err := cs.repository.GetWatermarkByShareId(ctx, shareID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return types.FileStream{}, fmt.Errorf("failed to get watermark: %w", err)
+	}
+	if mark.Valid && cs.watermarker == nil {
+		slog.Error("share is watermarked but watermarking is not configured",
+			slog.String("share_id", shareID),
+		)
+		return types.FileStream{}, ErrWatermarkUnavailable
+	}
+
+	if len(manifest.Chunks) != int(manifest.ChunkCount) {
+		slog.Error("ready share is missing chunk records",
+			slog.String("share_id", shareID),
+			slog.Int("chunk_records", len(manifest.Chunks)),
+			slog.Int("chunk_count", int(manifest.ChunkCount)),
+		)
+		return types.FileStream{}, fmt.Errorf("share has %d of %d chunks", len(manifest.Chunks), manifest.ChunkCount)
+	}
+
+	var size int64
+	for _, chunk := range manifest.Chunks {
+		size += chunk.Size
+	}
+
+	stream := &chunkStream{ctx: ctx, cs: cs, shareID: shareID, chunks: manifest.Chunks, watermarking: mark.Valid}
+	if err := stream.openNext(); err != nil {
+		return types.FileStream{}, err
+	}
+
+	if !mark.Valid {
+		return types.FileStream{
+			Body:       stream,
+			Size:       size,
+			ChunkCount: manifest.ChunkCount,
+			Chunks:     manifest.Chunks,
+			MaxRate:    stream.maxRate,
+		}, nil
+	}
+
+	marked, err := cs.watermarker.Transform(ctx, stream, watermark.Mark{
+		ShareID:  shareID,
+		Text:     mark.String,
+		ClientIP: clientIP,
+		ServedAt: time.Now(),
+	})
+	if err != nil {
+		stream.Close()
+		return types.FileStream{}, fmt.Errorf("failed to watermark share: %w", err)
+	}
+	slog.Info("streaming watermarked share",
+		slog.String("share_id", shareID),
+	)
+	return types.FileStream{
+		Body:       &watermarkedStream{ReadCloser: marked, chunks: stream},
+		Size:       -1,
+		ChunkCount: manifest.ChunkCount,
+		MaxRate:    stream.maxRate,
+	}, nil
+}
+
+// watermarkedStream is the output of the watermarker, closing the chunks it
+// reads along with it.
+type watermarkedStream struct {
+	io.ReadCloser
+	chunks io.Closer
+}
+
+func (s *watermarkedStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.chunks.Close()
+	return err
+}
+
+// chunkStream reads the chunks of a share one after another. Each chunk must
+// be exactly the size in the manifest, since the total was already sent as
+// the Content-Length.
+type chunkStream struct {
+	ctx     context.Context
+	cs      *ChunkService
+	shareID string
+	chunks  []types.ManifestChunk
+	// watermarking is set when the stream is read by the watermarker
+	watermarking bool
+	// next is the position in chunks of the chunk to open after current
+	next      int
+	current   io.ReadCloser
+	remaining int64
+	// maxRate is the download rate of the share, from its first chunk
+	maxRate int64
+}
+
+func (s *chunkStream) openNext() error {
+	chunk := s.chunks[s.next]
+	download, err := s.cs.fetchChunk(s.ctx, s.shareID, int64(chunk.Index), "", s.watermarking)
+	if err != nil {
+		return err
+	}
+	s.current = download.Body
+	s.remaining = chunk.Size
+	if s.next == 0 {
+		s.maxRate = download.MaxRate
+	}
+	s.next++
+	return nil
+}
+
+func (s *chunkStream) Read(p []byte) (int, error) {
+	for {
+		if s.current == nil {
+			if s.next == len(s.chunks) {
+				return 0, io.EOF
+			}
+			if err := s.openNext(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := s.current.Read(p)
+		s.remaining -= int64(n)
+		if s.remaining < 0 {
+			return 0, fmt.Errorf("chunk %d is larger than recorded", s.chunks[s.next-1].Index)
+		}
+		if err == io.EOF {
+			if s.remaining != 0 {
+				return n, fmt.Errorf("chunk %d is smaller than recorded", s.chunks[s.next-1].Index)
+			}
+			s.current.Close()
+			s.current = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (s *chunkStream) Close() error {
+	if s.current == nil {
+		return nil
+	}
+	err := s.current.Close()
+	s.current = nil
+	return err
+}
+
+// chunkETag is the strong validator for a chunk: the hash of the bytes served.
+func chunkETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs *ChunkService) DownloadChunk(ctx context.Context, shareID string, chunkIndex int64) (io.ReadCloser, error) {
+	download, err := cs.FetchChunk(ctx, shareID, chunkIndex, "")
+	if err != nil {
+		return nil, err
+	}
+	return download.Body, nil
+}
+
+// StatChunk returns the ETag and size of a chunk of a ready share without
+// reading it from storage, for HEAD requests. NotModified is set as in
+// FetchChunk.
+func (cs *ChunkService) StatChunk(ctx context.Context, shareID string, chunkIndex int64, ifNoneMatch string) (types.ChunkDownload, error) {
+	chunkDetails, err := cs.lookupChunk(ctx, shareID, chunkIndex)
+	if err != nil {
+		return types.ChunkDownload{}, err
+	}
+
+	etag := chunkETag(chunkDetails.ChunkHash)
+	return types.ChunkDownload{
+		ETag:        etag,
+		Size:        chunkDetails.EncryptedSize,
+		NotModified: ifNoneMatch != "" && etagMatches(ifNoneMatch, etag),
+	}, nil
+}
+
+// lookupChunk reads a chunk row of a ready share whose download limit is not
+// reached yet.
+func (cs *ChunkService) lookupChunk(ctx context.Context, shareID string, chunkIndex int64) (sqlc.GetChunkByIndexAndFileShareIDRow, error) {
+	slog.Debug("fetching chunk details",
+		slog.String("share_id", shareID),
+		slog.Int64("chunk_index", chunkIndex),
+	)
+
+	chunkDetails, err := cs.repository.GetChunkByIndexAndFileShareID(ctx, sqlc.GetChunkByIndexAndFileShareIDParams{
+		ShareID:    shareID,
+		ChunkIndex: int32(chunkIndex),
+	})
+
+	if errors.Is(err, database.ErrNotFound) {
+		if missing := cs.missingShare(ctx, shareID); errors.Is(missing, ErrRevoked) {
+			return sqlc.GetChunkByIndexAndFileShareIDRow{}, ErrRevoked
+		}
+		err = fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		slog.Warn("failed to get chunk metadata",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+		)
+		return sqlc.GetChunkByIndexAndFileShareIDRow{}, fmt.Errorf("failed to get chunk storage path: %w", err)
+	}
+
+	if downloadLimitReached(chunkDetails.DownloadCount, chunkDetails.MaxDownloads) {
+		slog.Warn("chunk download limit reached",
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+			slog.Int("download_count", int(chunkDetails.DownloadCount)),
+			slog.Int("max_downloads", int(chunkDetails.MaxDownloads)),
+		)
+		return sqlc.GetChunkByIndexAndFileShareIDRow{}, fmt.Errorf("chunk %w", ErrDownloadLimitReached)
+	}
+
+	return chunkDetails, nil
+}
+
+// missingShare tells why shareID has nothing to download: ErrRevoked when its
+// uploader revoked it, ErrNotFound otherwise.
+func (cs *ChunkService) missingShare(ctx context.Context, shareID string) error {
+	file, err := cs.repository.GetFileByShareID(ctx, shareID)
+	if err == nil && file.Status == FileStatusRevoked {
+		return ErrRevoked
+	}
+	return ErrNotFound
+}
+
+// FetchChunk opens a chunk of a ready share for download. When ifNoneMatch
+// lists the chunk's ETag the client already has it, so storage is not read
+// and NotModified is set instead.
+// Chunks of watermarked shares are only served through StreamFile.
+func (cs *ChunkService) FetchChunk(ctx context.Context, shareID string, chunkIndex int64, ifNoneMatch string) (types.ChunkDownload, error) {
+	return cs.fetchChunk(ctx, shareID, chunkIndex, ifNoneMatch, false)
+}
+
+// fetchChunk is FetchChunk, serving chunks of watermarked shares too when
+// watermarking says they are about to be watermarked.
+func (cs *ChunkService) fetchChunk(ctx context.Context, shareID string, chunkIndex int64, ifNoneMatch string, watermarking bool) (types.ChunkDownload, error) {
+	chunkDetails, err := cs.lookupChunk(ctx, shareID, chunkIndex)
+	if err != nil {
+		return types.ChunkDownload{}, err
+	}
+	if chunkDetails.Watermark.Valid && !watermarking {
+		return types.ChunkDownload{}, ErrWatermarkedShare
+	}
+
+	etag := chunkETag(chunkDetails.ChunkHash)
+	if ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		slog.Debug("chunk not modified",
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+		)
+		return types.ChunkDownload{ETag: etag, Size: chunkDetails.EncryptedSize, NotModified: true}, nil
+	}
+
+	if chunkDetails.ServerKeyID.Valid {
+		return cs.fetchServerSealedChunk(ctx, shareID, chunkIndex, chunkDetails, etag)
+	}
+
+	// Chunks of files uploaded while envelope encryption was enabled carry
+	// a file key and must be opened before they are served
+	fileKey, err := cs.repository.GetFileKeyByFileId(ctx, chunkDetails.FileID)
+	sealed := err == nil
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		slog.Error("failed to get file key",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return types.ChunkDownload{}, fmt.Errorf("failed to get file key: %w", err)
+	}
+	if sealed && cs.envelope == nil {
+		slog.Error("chunk is sealed but storage encryption is not configured",
+			slog.String("share_id", shareID),
+			slog.String("key_id", fileKey.KeyID),
+		)
+		return types.ChunkDownload{}, ErrStorageKeyUnavailable
+	}
+
+	slog.Debug("retrieving chunk from storage",
+		slog.String("share_id", shareID),
+		slog.Int64("chunk_index", chunkIndex),
+		slog.String("storage_path", chunkDetails.StoragePath),
+	)
+
+	chunk, err := cs.getChunkObject(ctx, shareID, chunkIndex, chunkDetails)
+	if err != nil {
+		return types.ChunkDownload{}, err
+	}
+
+	var body io.ReadCloser = chunk
+	if sealed {
+		body, err = cs.openChunk(ctx, fileKey, chunkDetails.StoragePath, chunk)
+		if err != nil {
+			slog.Error("failed to open sealed chunk",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+				slog.Int64("chunk_index", chunkIndex),
+			)
+			return types.ChunkDownload{}, err
+		}
+	}
+
+	slog.Info("chunk retrieved successfully",
+		slog.String("share_id", shareID),
+		slog.Int64("chunk_index", chunkIndex),
+	)
+
+	return types.ChunkDownload{
+		Body:    cs.meter(chunkDetails.FileID, cs.pace(ctx, shareID, body)),
+		ETag:    etag,
+		Size:    chunkDetails.EncryptedSize,
+		MaxRate: cs.downloadRate(chunkDetails.MaxDownloadRate),
+	}, nil
+}
+
+// CheckDownloadNonce fails with ErrInvalidDownloadNonce unless nonce is a
+// live download nonce for shareID whose download was not counted yet. Chunks
+// and streams are only served to such a nonce, so every byte served counts
+// towards a download. In read-only mode no nonce is needed, but shares with a
+// download limit are refused, see checkReadOnlyDownload.
+func (cs *ChunkService) CheckDownloadNonce(ctx context.Context, shareID, nonce string) error {
+	if cs.readOnly.Enabled() {
+		return cs.checkReadOnlyDownload(ctx, shareID)
+	}
+	if nonce == "" {
+		return ErrInvalidDownloadNonce
+	}
+
+	session, err := cs.repository.GetDownloadNonce(ctx, sqlc.GetDownloadNonceParams{
+		NonceHash: crypto.HashBytes([]byte(nonce)),
+		ShareID:   shareID,
+	})
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return ErrInvalidDownloadNonce
+	case err != nil:
+		return fmt.Errorf("failed to get download nonce: %w", err)
+	case session.UsedAt.Valid:
+		return ErrInvalidDownloadNonce
+	}
+	return nil
+}
+
+// checkReadOnlyDownload fails with readonly.ErrReadOnly while read-only mode
+// is on if the share, or the share link of ctx, has a download limit. No
+// download is counted then, so such a share could otherwise be downloaded any
+// number of times, just as a paste could be read.
+func (cs *ChunkService) checkReadOnlyDownload(ctx context.Context, shareID string) error {
+	if !cs.readOnly.Enabled() {
+		return nil
+	}
+	if link := ShareLinkFromContext(ctx); link != nil && link.MaxDownloads > 0 {
+		return readonly.ErrReadOnly
+	}
+
+	file, err := cs.repository.GetFileByShareID(ctx, shareID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		// Left for the download itself to answer
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get file: %w", err)
+	case file.MaxDownloads != config.UnlimitedDownloads:
+		return readonly.ErrReadOnly
+	}
+	return nil
+}
+
+// RecordChunksServed counts chunks served to their end against the download
+// of nonce and, once every chunk of the file was served, completes it.
+// Chunks served again and requests without a live nonce count nothing.
+func (cs *ChunkService) RecordChunksServed(ctx context.Context, shareID, nonce string, chunkIndexes []int32) error {
+	if nonce == "" || len(chunkIndexes) == 0 {
+		return nil
+	}
+
+	served, err := cs.repository.RecordDownloadNonceChunks(ctx, sqlc.RecordDownloadNonceChunksParams{
+		ChunkIndexes: chunkIndexes,
+		NonceHash:    crypto.HashBytes([]byte(nonce)),
+		ShareID:      shareID,
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record served chunks: %w", err)
+	}
+
+	// Only the request serving the last new chunk sees every chunk served
+	if served.ServedChunks < served.ChunkCount || cs.completer == nil {
+		return nil
+	}
+	return cs.completer.CompleteDownload(ctx, shareID, nonce)
+}
+
+// fetchServerSealedChunk opens a chunk sealed with the server key, serving
+// the plaintext the client uploaded.
+func (cs *ChunkService) fetchServerSealedChunk(ctx context.Context, shareID string, chunkIndex int64, chunkDetails sqlc.GetChunkByIndexAndFileShareIDRow, etag string) (types.ChunkDownload, error) {
+	if cs.sealer == nil {
+		slog.Error("chunk is server encrypted but server encryption is not configured",
+			slog.String("share_id", shareID),
+			slog.String("key_id", chunkDetails.ServerKeyID.String),
+		)
+		return types.ChunkDownload{}, ErrStorageKeyUnavailable
+	}
+
+	chunk, err := cs.getChunkObject(ctx, shareID, chunkIndex, chunkDetails)
+	if err != nil {
+		return types.ChunkDownload{}, err
+	}
+	defer chunk.Close()
+
+	sealed, err := io.ReadAll(chunk)
+	if err != nil {
+		return types.ChunkDownload{}, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	data, err := cs.sealer.Open(chunkDetails.ServerKeyID.String, sealed, []byte(chunkDetails.StoragePath))
+	if errors.Is(err, crypto.ErrSealerKeyMismatch) {
+		slog.Error("chunk is sealed with a different server key",
+			slog.String("share_id", shareID),
+			slog.String("key_id", chunkDetails.ServerKeyID.String),
+		)
+		return types.ChunkDownload{}, ErrStorageKeyUnavailable
+	}
+	if err != nil {
+		slog.Error("failed to open server encrypted chunk",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+		)
+		return types.ChunkDownload{}, err
+	}
+
+	body := io.NopCloser(bytes.NewReader(data))
+	return types.ChunkDownload{
+		Body:    cs.meter(chunkDetails.FileID, cs.pace(ctx, shareID, body)),
+		ETag:    etag,
+		Size:    chunkDetails.EncryptedSize,
+		MaxRate: cs.downloadRate(chunkDetails.MaxDownloadRate),
+	}, nil
+}
+
+// getChunkObject opens a chunk's object, marking the file corrupt when the
+// object is gone.
+func (cs *ChunkService) getChunkObject(ctx context.Context, shareID string, chunkIndex int64, chunkDetails sqlc.GetChunkByIndexAndFileShareIDRow) (*minio.Object, error) {
+	chunk, err := cs.minioClient.GetObject(
+		ctx,
+		cs.bucketName,
+		chunkDetails.StoragePath,
+		minio.GetObjectOptions{},
+	)
+	if err != nil {
+		slog.Error("failed to retrieve chunk from storage",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+			slog.String("storage_path", chunkDetails.StoragePath),
+		)
+		return nil, fmt.Errorf("failed to download chunk from storage: %w", err)
+	}
+
+	if _, err := chunk.Stat(); err != nil {
+		chunk.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			cs.handleMissingChunk(ctx, shareID, chunkIndex, chunkDetails)
+			return nil, ErrChunkMissing
+		}
+		slog.Error("failed to stat chunk object",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+			slog.Int64("chunk_index", chunkIndex),
+		)
+		return nil, fmt.Errorf("failed to stat chunk: %w", err)
+	}
+	return chunk, nil
+}
+
+// pace shares download bandwidth fairly between shares, when configured.
+func (cs *ChunkService) pace(ctx context.Context, shareID string, body io.ReadCloser) io.ReadCloser {
+	if cs.fairShare == nil {
+		return body
+	}
+	return pacedReadCloser{
+		Reader: cs.fairShare.Reader(ctx, shareID, body),
+		Closer: body,
+	}
+}
+
+// meter counts the bytes read from body as served from fileID.
+func (cs *ChunkService) meter(fileID pgtype.UUID, body io.ReadCloser) io.ReadCloser {
+	return &meteredReadCloser{ReadCloser: body, stats: cs.transfers, fileID: fileID}
+}
+
+// openChunk reads a sealed chunk fully and returns its client-encrypted
+// contents. Chunks are bounded by MaxChunkSize, as on upload.
+func (cs *ChunkService) openChunk(ctx context.Context, key sqlc.FileKey, objectName string, chunk io.ReadCloser) (io.ReadCloser, error) {
+	defer chunk.Close()
+
+	sealed, err := io.ReadAll(chunk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+
+	dataKey, err := cs.envelope.UnwrapDataKey(ctx, key.KeyID, key.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := envelope.Open(dataKey, sealed, []byte(objectName))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// handleMissingChunk marks a file whose chunk object is gone from storage as
+// corrupt so it stops being served, and raises an alert.
+func (cs *ChunkService) handleMissingChunk(ctx context.Context, shareID string, chunkIndex int64, chunkDetails sqlc.GetChunkByIndexAndFileShareIDRow) {
+	missingChunks.Add(1)
+
+	slog.Error("chunk missing from storage",
+		slog.String("share_id", shareID),
+		slog.String("file_id", chunkDetails.FileID.String()),
+		slog.Int64("chunk_index", chunkIndex),
+		slog.String("storage_path", chunkDetails.StoragePath),
+	)
+
+	_, err := cs.repository.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     chunkDetails.FileID,
+		Status: FileStatusCorrupt,
+	})
+	if err != nil {
+		slog.Error("failed to mark file corrupt",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+	}
+
+	if cs.alerts != nil {
+		cs.alerts.Notify(alert.Event{
+			Type:    "chunk_missing",
+			Message: "chunk missing from storage, file marked corrupt",
+			Details: map[string]any{
+				"share_id":     shareID,
+				"file_id":      chunkDetails.FileID.String(),
+				"chunk_index":  chunkIndex,
+				"storage_path": chunkDetails.StoragePath,
+			},
+		})
+	}
+}
+
+type pacedReadCloser struct {
+	io.Reader
+	io.Closer
 }
@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/envelope"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/ilkin0/gzln/internal/testutil"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -32,7 +34,7 @@ func setupTestChunkService(t *testing.T) (*testEnv, func()) {
 
 	containers := testutil.SetupTestContainers(t)
 
-	chunkService := NewChunkService(containers.Database.Queries, containers.MinioClient.Client, containers.MinioClient.BucketName)
+	chunkService := NewChunkService(containers.Querier(), containers.MinioClient.Client, containers.MinioClient.BucketName, config.DefaultLimits())
 
 	return &testEnv{
 		chunkService: chunkService,
@@ -51,13 +53,14 @@ func TestProcessChunkUpload_Integration_Success(t *testing.T) {
 
 	file := testutil.CreateUploadingFile(t, env.queries, ctx)
 
-	chunkData := []byte("This is test chunk data for upload")
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 
 	req := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test-file.txt",
@@ -93,13 +96,14 @@ func TestProcessChunkUpload_Integration_HashMismatch(t *testing.T) {
 	ctx := context.Background()
 	file := testutil.CreateUploadingFile(t, env.queries, ctx)
 
-	chunkData := []byte("Test data")
+	chunkData := testutil.ChunkData(file, 0)
 	wrongHash := "wrong-hash-value"
 
 	req := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: wrongHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
@@ -108,6 +112,11 @@ func TestProcessChunkUpload_Integration_HashMismatch(t *testing.T) {
 	_, err := env.chunkService.ProcessChunkUpload(ctx, req)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "hash mismatch")
+
+	// The streamed object is removed once the mismatch is detected
+	objectName := fmt.Sprintf("%s/0.enc", file.ID)
+	_, err = env.minioClient.StatObject(ctx, env.bucketName, objectName, minio.StatObjectOptions{})
+	assert.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
 }
 
 func TestProcessChunkUpload_Integration_DuplicateChunk(t *testing.T) {
@@ -117,13 +126,14 @@ func TestProcessChunkUpload_Integration_DuplicateChunk(t *testing.T) {
 	ctx := context.Background()
 	file := testutil.CreateUploadingFile(t, env.queries, ctx)
 
-	chunkData := []byte("Test data")
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 
 	req := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
@@ -146,13 +156,14 @@ func TestProcessChunkUpload_Integration_InvalidFileStatus(t *testing.T) {
 	// Create a file with "ready" status (not "uploading")
 	file := testutil.CreateReadyFile(t, env.queries, ctx)
 
-	chunkData := []byte("Test data")
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 
 	req := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
@@ -163,6 +174,42 @@ func TestProcessChunkUpload_Integration_InvalidFileStatus(t *testing.T) {
 	assert.Contains(t, err.Error(), "not in uploading state")
 }
 
+func TestProcessChunkUpload_Integration_SizeMismatch(t *testing.T) {
+	env, cleanup := setupTestChunkService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	file := testutil.CreateUploadingFile(t, env.queries, ctx)
+
+	for name, chunkData := range map[string][]byte{
+		"undersized": testutil.ChunkData(file, 0)[1:],
+		"oversized":  append(testutil.ChunkData(file, 0), 'x'),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := types.ChunkUploadRequest{
+				FileID:       file.ID,
+				ChunkIndex:   0,
+				Chunk:        bytes.NewReader(chunkData),
+				Size:         int64(len(chunkData)),
+				ExpectedHash: crypto.HashBytes(chunkData),
+				ContentType:  "application/octet-stream",
+				Filename:     "test.txt",
+			}
+
+			_, err := env.chunkService.ProcessChunkUpload(ctx, req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid chunk size")
+		})
+	}
+
+	exists, err := env.queries.ChunkExistsByFileIdAndIndex(ctx, sqlc.ChunkExistsByFileIdAndIndexParams{
+		FileID:     file.ID,
+		ChunkIndex: 0,
+	})
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
 func TestDownloadChunk_Integration_Success(t *testing.T) {
 	env, cleanup := setupTestChunkService(t)
 	defer cleanup()
@@ -171,13 +218,14 @@ func TestDownloadChunk_Integration_Success(t *testing.T) {
 
 	file := testutil.CreateUploadingFile(t, env.queries, ctx)
 
-	chunkData := []byte("Test chunk data for download")
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 
 	uploadReq := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
@@ -200,6 +248,50 @@ func TestDownloadChunk_Integration_Success(t *testing.T) {
 	assert.Equal(t, chunkData, downloadedData)
 }
 
+func TestFetchChunk_Integration_ETag(t *testing.T) {
+	env, cleanup := setupTestChunkService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := testutil.CreateUploadingFile(t, env.queries, ctx)
+
+	chunkData := testutil.ChunkData(file, 0)
+	expectedHash := crypto.HashBytes(chunkData)
+
+	_, err := env.chunkService.ProcessChunkUpload(ctx, types.ChunkUploadRequest{
+		FileID:       file.ID,
+		ChunkIndex:   0,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
+		ExpectedHash: expectedHash,
+		ContentType:  "application/octet-stream",
+		Filename:     "test.txt",
+	})
+	require.NoError(t, err)
+
+	file, err = env.queries.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     file.ID,
+		Status: "ready",
+	})
+	require.NoError(t, err)
+
+	download, err := env.chunkService.FetchChunk(ctx, file.ShareID, 0, "")
+	require.NoError(t, err)
+	download.Body.Close()
+	assert.Equal(t, `"`+expectedHash+`"`, download.ETag)
+	assert.Equal(t, int64(len(chunkData)), download.Size)
+
+	manifest, err := env.chunkService.GetDownloadManifest(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, download.ETag, manifest.Chunks[0].ETag)
+
+	download, err = env.chunkService.FetchChunk(ctx, file.ShareID, 0, download.ETag)
+	require.NoError(t, err)
+	assert.True(t, download.NotModified)
+	assert.Nil(t, download.Body)
+}
+
 func TestDownloadChunk_Integration_ChunkNotFound(t *testing.T) {
 	env, cleanup := setupTestChunkService(t)
 	defer cleanup()
@@ -220,12 +312,13 @@ func TestDownloadChunk_Integration_LimitReached(t *testing.T) {
 
 	file := testutil.CreateUploadingFile(t, env.queries, ctx)
 
-	chunkData := []byte("Test data")
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 	uploadReq := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
@@ -250,13 +343,16 @@ func TestCompleteUploadDownloadFlow_Integration(t *testing.T) {
 
 	ctx := context.Background()
 
-	file := testutil.CreateUploadingFile(t, env.queries, ctx)
+	opts := testutil.DefaultTestFileOptions()
+	opts.Status = "uploading"
+	opts.ChunkCount = 4
+	opts.ChunkSize = 300
+	opts.TotalSize = 1000
+	file := testutil.CreateTestFile(t, env.queries, ctx, opts)
 
-	chunks := [][]byte{
-		[]byte("Chunk 0 data - first part"),
-		[]byte("Chunk 1 data - second part"),
-		[]byte("Chunk 2 data - third part"),
-		[]byte("Chunk 3 data - fourth part"),
+	var chunks [][]byte
+	for i := range file.ChunkCount {
+		chunks = append(chunks, testutil.ChunkData(file, i))
 	}
 
 	for i, chunkData := range chunks {
@@ -264,7 +360,8 @@ func TestCompleteUploadDownloadFlow_Integration(t *testing.T) {
 		req := types.ChunkUploadRequest{
 			FileID:       file.ID,
 			ChunkIndex:   int64(i),
-			ChunkData:    chunkData,
+			Chunk:        bytes.NewReader(chunkData),
+			Size:         int64(len(chunkData)),
 			ExpectedHash: hash,
 			ContentType:  "application/octet-stream",
 			Filename:     fmt.Sprintf("chunk-%d.txt", i),
@@ -297,15 +394,21 @@ func TestMinIOStorageIntegrity_Integration(t *testing.T) {
 	defer cleanup()
 
 	ctx := context.Background()
-	file := testutil.CreateUploadingFile(t, env.queries, ctx)
-
-	chunkData := bytes.Repeat([]byte("X"), 1024*1024)
+	opts := testutil.DefaultTestFileOptions()
+	opts.Status = "uploading"
+	opts.ChunkCount = 1
+	opts.ChunkSize = 1024 * 1024
+	opts.TotalSize = 1024 * 1024
+	file := testutil.CreateTestFile(t, env.queries, ctx, opts)
+
+	chunkData := testutil.ChunkData(file, 0)
 	expectedHash := crypto.HashBytes(chunkData)
 
 	req := types.ChunkUploadRequest{
 		FileID:       file.ID,
 		ChunkIndex:   0,
-		ChunkData:    chunkData,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
 		ExpectedHash: expectedHash,
 		ContentType:  "application/octet-stream",
 		Filename:     "large-chunk.bin",
@@ -330,3 +433,96 @@ func TestMinIOStorageIntegrity_Integration(t *testing.T) {
 	assert.Equal(t, chunkData, downloadedData)
 }
 
+func TestEnvelopeEncryption_Integration(t *testing.T) {
+	env, cleanup := setupTestChunkService(t)
+	defer cleanup()
+
+	provider, err := envelope.NewLocalKeyProvider(bytes.Repeat([]byte{9}, envelope.KeySize))
+	require.NoError(t, err)
+	env.chunkService.WithEnvelope(envelope.New(provider))
+
+	ctx := context.Background()
+	file := testutil.CreateUploadingFile(t, env.queries, ctx)
+
+	chunkData := testutil.ChunkData(file, 0)
+	_, err = env.chunkService.ProcessChunkUpload(ctx, types.ChunkUploadRequest{
+		FileID:       file.ID,
+		ChunkIndex:   0,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
+		ExpectedHash: crypto.HashBytes(chunkData),
+		ContentType:  "application/octet-stream",
+		Filename:     "sealed.bin",
+	})
+	require.NoError(t, err)
+
+	key, err := env.queries.GetFileKeyByFileId(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, provider.KeyID(), key.KeyID)
+	assert.Equal(t, envelope.Algorithm, key.Algorithm)
+
+	// The stored object must not contain the client ciphertext as-is
+	objectName := fmt.Sprintf("%s/0.enc", file.ID)
+	object, err := env.minioClient.GetObject(ctx, env.bucketName, objectName, minio.GetObjectOptions{})
+	require.NoError(t, err)
+	stored, err := io.ReadAll(object)
+	object.Close()
+	require.NoError(t, err)
+	assert.Len(t, stored, len(chunkData)+envelope.Overhead)
+	assert.False(t, bytes.Contains(stored, chunkData))
+
+	_, err = env.queries.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     file.ID,
+		Status: "ready",
+	})
+	require.NoError(t, err)
+
+	reader, err := env.chunkService.DownloadChunk(ctx, file.ShareID, 0)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	downloadedData, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, chunkData, downloadedData)
+
+	// Without the master key the sealed chunk cannot be served
+	env.chunkService.WithEnvelope(nil)
+	_, err = env.chunkService.DownloadChunk(ctx, file.ShareID, 0)
+	assert.ErrorIs(t, err, ErrStorageKeyUnavailable)
+}
+
+func TestDownloadChunk_Integration_MissingObjectMarksCorrupt(t *testing.T) {
+	env, cleanup := setupTestChunkService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	file := testutil.CreateUploadingFile(t, env.queries, ctx)
+
+	chunkData := testutil.ChunkData(file, 0)
+	_, err := env.chunkService.ProcessChunkUpload(ctx, types.ChunkUploadRequest{
+		FileID:       file.ID,
+		ChunkIndex:   0,
+		Chunk:        bytes.NewReader(chunkData),
+		Size:         int64(len(chunkData)),
+		ExpectedHash: crypto.HashBytes(chunkData),
+		ContentType:  "application/octet-stream",
+		Filename:     "test.txt",
+	})
+	require.NoError(t, err)
+
+	_, err = env.queries.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     file.ID,
+		Status: "ready",
+	})
+	require.NoError(t, err)
+
+	objectName := fmt.Sprintf("%s/0.enc", file.ID)
+	require.NoError(t, env.minioClient.RemoveObject(ctx, env.bucketName, objectName, minio.RemoveObjectOptions{}))
+
+	_, err = env.chunkService.DownloadChunk(ctx, file.ShareID, 0)
+	assert.ErrorIs(t, err, ErrChunkMissing)
+
+	stored, err := env.queries.GetFileByID(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusCorrupt, stored.Status)
+}
@@ -1,13 +1,36 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/envelope"
+	"github.com/ilkin0/gzln/internal/readonly"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/internal/watermark"
+	"github.com/ilkin0/gzln/pkg/e2ee"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -23,6 +46,11 @@ func (m *MockQuerier) CreateChunk(ctx context.Context, arg sqlc.CreateChunkParam
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockQuerier) CreateFileKey(ctx context.Context, arg sqlc.CreateFileKeyParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockQuerier) FileExistsByIdAndStatus(ctx context.Context, arg sqlc.FileExistsByIdAndStatusParams) (bool, error) {
 	args := m.Called(ctx, arg)
 	return args.Bool(0), args.Error(1)
@@ -33,18 +61,116 @@ func (m *MockQuerier) GetChunkByIndexAndFileShareID(ctx context.Context, arg sql
 	return args.Get(0).(sqlc.GetChunkByIndexAndFileShareIDRow), args.Error(1)
 }
 
+func (m *MockQuerier) GetChunkThroughput(ctx context.Context, arg sqlc.GetChunkThroughputParams) (sqlc.GetChunkThroughputRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.GetChunkThroughputRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetFileKeyByFileId(ctx context.Context, fileID pgtype.UUID) (sqlc.FileKey, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).(sqlc.FileKey), args.Error(1)
+}
+
+func (m *MockQuerier) CreateServerEncryptedFile(ctx context.Context, arg sqlc.CreateServerEncryptedFileParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockQuerier) GetServerEncryptionKeyIdByFileId(ctx context.Context, fileID pgtype.UUID) (string, error) {
+	args := m.Called(ctx, fileID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQuerier) ListChunkIndexesByFileId(ctx context.Context, fileID pgtype.UUID) ([]int32, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).([]int32), args.Error(1)
+}
+
+func (m *MockQuerier) ListChunkManifestByShareId(ctx context.Context, shareID string) ([]sqlc.ListChunkManifestByShareIdRow, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).([]sqlc.ListChunkManifestByShareIdRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetChunkHashByFileIdAndIndex(ctx context.Context, arg sqlc.GetChunkHashByFileIdAndIndexParams) (string, error) {
+	args := m.Called(ctx, arg)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQuerier) ListChunksByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.Chunk, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).([]sqlc.Chunk), args.Error(1)
+}
+
+func (m *MockQuerier) ListChunksOfUnpurgedFile(ctx context.Context, fileID pgtype.UUID) ([]sqlc.ListChunksOfUnpurgedFileRow, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).([]sqlc.ListChunksOfUnpurgedFileRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListUnpurgedFileIdsWithChunks(ctx context.Context) ([]pgtype.UUID, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]pgtype.UUID), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteChunkOfUnpurgedFile(ctx context.Context, arg sqlc.DeleteChunkOfUnpurgedFileParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetRetentionStats(ctx context.Context, arg sqlc.GetRetentionStatsParams) (sqlc.GetRetentionStatsRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.GetRetentionStatsRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetOldestRetainedFile(ctx context.Context, periodEnd pgtype.Timestamptz) (sqlc.GetOldestRetainedFileRow, error) {
+	args := m.Called(ctx, periodEnd)
+	return args.Get(0).(sqlc.GetOldestRetainedFileRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetRetentionReport(ctx context.Context, periodStart pgtype.Timestamptz) (sqlc.RetentionReport, error) {
+	args := m.Called(ctx, periodStart)
+	return args.Get(0).(sqlc.RetentionReport), args.Error(1)
+}
+
+func (m *MockQuerier) ListRetentionReports(ctx context.Context) ([]sqlc.ListRetentionReportsRow, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]sqlc.ListRetentionReportsRow), args.Error(1)
+}
+
+func (m *MockQuerier) UpsertRetentionReport(ctx context.Context, arg sqlc.UpsertRetentionReportParams) (sqlc.RetentionReport, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.RetentionReport), args.Error(1)
+}
+
 func createTestUUID() pgtype.UUID {
 	uuid := pgtype.UUID{}
 	_ = uuid.Scan("550e8400-e29b-41d4-a716-446655440000")
 	return uuid
 }
 
+// testChunkData must be longer than the encryption overhead to be a valid
+// encrypted chunk.
+var testChunkData = []byte("test chunk data, long enough to hold a nonce and tag")
+
+// createUploadingFile returns a single-chunk file in "uploading" status that
+// createValidChunkRequest's chunk fits exactly.
+func createUploadingFile() sqlc.File {
+	plainSize := int32(len(testChunkData) - e2ee.Overhead)
+	return sqlc.File{
+		ID:         createTestUUID(),
+		Status:     "uploading",
+		ChunkCount: 1,
+		ChunkSize:  plainSize,
+		TotalSize:  int64(plainSize),
+	}
+}
+
 func createValidChunkRequest() types.ChunkUploadRequest {
 	return types.ChunkUploadRequest{
 		FileID:       createTestUUID(),
 		ChunkIndex:   0,
-		ChunkData:    []byte("test chunk data"),
-		ExpectedHash: "34fa0947d659ce6343cbfe6be3a1ca882f6b21b35232210f194791d545440c40", // SHA256 of "test chunk data"
+		Chunk:        bytes.NewReader(testChunkData),
+		Size:         int64(len(testChunkData)),
+		ExpectedHash: crypto.HashBytes(testChunkData),
 		ContentType:  "application/octet-stream",
 		Filename:     "test.txt",
 	}
@@ -52,49 +178,78 @@ func createValidChunkRequest() types.ChunkUploadRequest {
 
 func TestProcessChunkUpload_ChunkAlreadyExists(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 	ctx := context.Background()
 	req := createValidChunkRequest()
 
 	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
 		Return(true, nil)
+	mockRepo.On("GetChunkHashByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.GetChunkHashByFileIdAndIndexParams")).
+		Return(crypto.HashBytes([]byte("other bytes")), nil)
 
 	result, err := service.ProcessChunkUpload(ctx, req)
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "already uploaded")
+	assert.ErrorIs(t, err, ErrChunkAlreadyUploaded)
 	assert.Equal(t, types.ChunkUploadResponse{}, result)
 
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "FileExistsByIdAndStatus")
+	mockRepo.AssertNotCalled(t, "GetFileByID")
+	mockRepo.AssertNotCalled(t, "CreateChunk")
+}
+
+func TestProcessChunkUpload_RetryWithSameHash(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(true, nil)
+	mockRepo.On("GetChunkHashByFileIdAndIndex", ctx, sqlc.GetChunkHashByFileIdAndIndexParams{
+		FileID:     req.FileID,
+		ChunkIndex: int32(req.ChunkIndex),
+	}).Return(req.ExpectedHash, nil)
+
+	result, err := service.ProcessChunkUpload(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, ChunkStatusAlreadyUploaded, result.Status)
+	assert.Equal(t, req.ExpectedHash, result.ReceivedHash)
+	mockRepo.AssertNotCalled(t, "GetFileByID")
 	mockRepo.AssertNotCalled(t, "CreateChunk")
 }
 
 func TestProcessChunkUpload_FileNotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 	ctx := context.Background()
 	req := createValidChunkRequest()
 
 	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
 		Return(false, nil)
 
-	mockRepo.On("FileExistsByIdAndStatus", ctx, mock.AnythingOfType("sqlc.FileExistsByIdAndStatusParams")).
-		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(sqlc.File{}, database.ErrNotFound)
 
 	result, err := service.ProcessChunkUpload(ctx, req)
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "does not exist or is not in uploading state")
+	assert.ErrorIs(t, err, ErrNotUploading)
 	assert.Equal(t, types.ChunkUploadResponse{}, result)
 
 	mockRepo.AssertExpectations(t)
 	mockRepo.AssertNotCalled(t, "CreateChunk")
 }
 
+// Streamed chunks are only hashed while being stored (see the integration
+// tests); sealed chunks are buffered and rejected before storage.
 func TestProcessChunkUpload_HashMismatch(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).
+		WithEnvelope(newTestEnvelope(t))
 	ctx := context.Background()
 	req := createValidChunkRequest()
 	req.ExpectedHash = "wrong-hash-value"
@@ -102,30 +257,32 @@ func TestProcessChunkUpload_HashMismatch(t *testing.T) {
 	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
 		Return(false, nil)
 
-	mockRepo.On("FileExistsByIdAndStatus", ctx, mock.AnythingOfType("sqlc.FileExistsByIdAndStatusParams")).
-		Return(true, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
 
 	result, err := service.ProcessChunkUpload(ctx, req)
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "hash mismatch")
+	assert.ErrorIs(t, err, ErrHashMismatch)
 	assert.Equal(t, types.ChunkUploadResponse{}, result)
 
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetFileKeyByFileId", mock.Anything, mock.Anything)
 	mockRepo.AssertNotCalled(t, "CreateChunk")
 }
 
 func TestProcessChunkUpload_DatabaseFailure(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 	ctx := context.Background()
 	req := createValidChunkRequest()
 
 	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
 		Return(false, nil)
 
-	mockRepo.On("FileExistsByIdAndStatus", ctx, mock.AnythingOfType("sqlc.FileExistsByIdAndStatusParams")).
-		Return(false, errors.New("database connection error"))
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(sqlc.File{}, errors.New("database connection error"))
 
 	result, err := service.ProcessChunkUpload(ctx, req)
 
@@ -136,6 +293,137 @@ func TestProcessChunkUpload_DatabaseFailure(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestProcessChunkUpload_ChunkSizeMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int64
+		file  func() sqlc.File
+	}{
+		{
+			name: "undersized",
+			file: func() sqlc.File {
+				f := createUploadingFile()
+				f.ChunkSize++
+				f.TotalSize++
+				return f
+			},
+		},
+		{
+			name: "oversized",
+			file: func() sqlc.File {
+				f := createUploadingFile()
+				f.ChunkSize--
+				f.TotalSize--
+				return f
+			},
+		},
+		{
+			name:  "full-size chunk where final chunk is shorter",
+			index: 1,
+			file: func() sqlc.File {
+				f := createUploadingFile()
+				f.ChunkCount = 2
+				f.TotalSize = int64(f.ChunkSize) + 1
+				return f
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+			ctx := context.Background()
+			req := createValidChunkRequest()
+			req.ChunkIndex = tt.index
+
+			mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+				Return(false, nil)
+			mockRepo.On("GetFileByID", ctx, req.FileID).
+				Return(tt.file(), nil)
+
+			_, err := service.ProcessChunkUpload(ctx, req)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid chunk size")
+			mockRepo.AssertNotCalled(t, "CreateChunk")
+		})
+	}
+}
+
+func TestProcessChunkUpload_ChunkIndexOutOfRange(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+	req.ChunkIndex = 1
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid chunk index")
+	assert.ErrorIs(t, err, ErrInvalidChunkIndex)
+}
+
+func TestExpectedChunkSize(t *testing.T) {
+	file := sqlc.File{ChunkCount: 3, ChunkSize: 100, TotalSize: 250}
+
+	for index, want := range []int64{100, 100, 50} {
+		got, err := expectedChunkSize(file, int64(index), e2ee.Overhead)
+		require.NoError(t, err)
+		assert.Equal(t, want+e2ee.Overhead, got)
+	}
+
+	_, err := expectedChunkSize(file, -1, e2ee.Overhead)
+	assert.Error(t, err)
+	_, err = expectedChunkSize(file, 3, e2ee.Overhead)
+	assert.Error(t, err)
+}
+
+func TestTunePut(t *testing.T) {
+	tuned := config.StorageUpload{
+		PartSize:              8 << 20,
+		Threads:               4,
+		ConcurrentStreamParts: true,
+		SinglePutMaxBytes:     4 << 20,
+	}
+	tests := []struct {
+		name       string
+		upload     config.StorageUpload
+		size       int64
+		seekable   bool
+		wantSize   int64
+		wantSingle bool
+		wantStream bool
+	}{
+		{name: "defaults", size: 32 << 20, wantSize: 32 << 20},
+		{name: "small chunk single put", upload: tuned, size: 4 << 20, wantSize: 4 << 20, wantSingle: true},
+		{name: "streamed in parallel parts", upload: tuned, size: 32 << 20, wantSize: -1, wantStream: true},
+		{name: "seekable uses its size", upload: tuned, size: 32 << 20, seekable: true, wantSize: 32 << 20},
+		{name: "fits one part", upload: tuned, size: 6 << 20, wantSize: 6 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &ChunkService{storageUpload: tt.upload}
+			var opts minio.PutObjectOptions
+
+			size := cs.tunePut(&opts, tt.size, tt.seekable)
+
+			assert.Equal(t, tt.wantSize, size)
+			assert.Equal(t, tt.wantSingle, opts.DisableMultipart)
+			assert.Equal(t, tt.wantStream, opts.ConcurrentStreamParts)
+			assert.Equal(t, tt.upload.PartSize, opts.PartSize)
+			assert.Equal(t, tt.upload.Threads, opts.NumThreads)
+		})
+	}
+}
+
 func TestValidateChunkHash_Success(t *testing.T) {
 	service := &ChunkService{}
 
@@ -173,23 +461,107 @@ func TestValidateChunkHash_EmptyData(t *testing.T) {
 
 func TestValidateChunkUpload_ChunkExistsCheckError(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 	ctx := context.Background()
 	fileID := createTestUUID()
 
 	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
 		Return(false, errors.New("database error"))
 
-	err := service.validateChunkUpload(ctx, fileID, 0)
+	_, err := service.validateChunkUpload(ctx, fileID, 0, int64(len(testChunkData)), e2ee.Overhead, crypto.HashBytes(testChunkData))
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to check chunk existence")
 	mockRepo.AssertExpectations(t)
 }
 
+func TestFetchChunk_NotModified(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			MaxDownloads:  5,
+			FileID:        createTestUUID(),
+			StoragePath:   "file/0.enc",
+			EncryptedSize: 1028,
+			ChunkHash:     "abc",
+		}, nil)
+
+	download, err := service.FetchChunk(ctx, "abc123def456", 0, `"other", "abc"`)
+
+	require.NoError(t, err)
+	assert.True(t, download.NotModified)
+	assert.Nil(t, download.Body)
+	assert.Equal(t, `"abc"`, download.ETag)
+	assert.Equal(t, int64(1028), download.Size)
+	mockRepo.AssertNotCalled(t, "GetFileKeyByFileId")
+}
+
+func TestStatChunk(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			MaxDownloads:  5,
+			FileID:        createTestUUID(),
+			StoragePath:   "file/0.enc",
+			EncryptedSize: 1028,
+			ChunkHash:     "abc",
+		}, nil)
+
+	download, err := service.StatChunk(ctx, "abc123def456", 0, "")
+
+	require.NoError(t, err)
+	assert.False(t, download.NotModified)
+	assert.Nil(t, download.Body)
+	assert.Equal(t, `"abc"`, download.ETag)
+	assert.Equal(t, int64(1028), download.Size)
+
+	download, err = service.StatChunk(ctx, "abc123def456", 0, `"abc"`)
+
+	require.NoError(t, err)
+	assert.True(t, download.NotModified)
+	mockRepo.AssertNotCalled(t, "GetFileKeyByFileId")
+}
+
+func TestStatChunk_DownloadLimitReached(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{MaxDownloads: 1, DownloadCount: 1, ChunkHash: "abc"}, nil)
+
+	_, err := service.StatChunk(ctx, "abc123def456", 0, "")
+
+	assert.ErrorContains(t, err, "limit reached")
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{`*`, true},
+		{`"abcd"`, false},
+		{`abc`, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, etagMatches(tt.ifNoneMatch, `"abc"`), tt.ifNoneMatch)
+	}
+}
+
 func TestDownloadChunk_ChunkNotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "abc123def456"
@@ -209,7 +581,7 @@ func TestDownloadChunk_ChunkNotFound(t *testing.T) {
 
 func TestDownloadChunk_DownloadLimitReached(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewChunkService(mockRepo, nil, "test-bucket")
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "abc123def456"
@@ -262,7 +634,7 @@ func TestDownloadChunk_DownloadLimitEdgeCases(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockQuerier)
-			service := NewChunkService(mockRepo, nil, "test-bucket")
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
 			ctx := context.Background()
 
 			chunkDetails := sqlc.GetChunkByIndexAndFileShareIDRow{
@@ -282,3 +654,1374 @@ func TestDownloadChunk_DownloadLimitEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadChunk_SealedWithoutEnvelope(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	chunkDetails := sqlc.GetChunkByIndexAndFileShareIDRow{
+		FileID:        createTestUUID(),
+		StoragePath:   "file-id/0.enc",
+		DownloadCount: 0,
+		MaxDownloads:  5,
+	}
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(chunkDetails, nil)
+	mockRepo.On("GetFileKeyByFileId", ctx, chunkDetails.FileID).
+		Return(sqlc.FileKey{KeyID: "local:0011223344556677"}, nil)
+
+	_, err := service.DownloadChunk(ctx, "test-share", 0)
+
+	assert.ErrorIs(t, err, ErrStorageKeyUnavailable)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHandleMissingChunk_MarksFileCorrupt(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	chunkDetails := sqlc.GetChunkByIndexAndFileShareIDRow{
+		FileID:      createTestUUID(),
+		StoragePath: "file-id/0.enc",
+	}
+	mockRepo.On("UpdateFileStatus", ctx, sqlc.UpdateFileStatusParams{
+		ID:     chunkDetails.FileID,
+		Status: FileStatusCorrupt,
+	}).Return(sqlc.File{}, nil)
+
+	before := missingChunks.Value()
+	service.handleMissingChunk(ctx, "test-share", 0, chunkDetails)
+
+	assert.Equal(t, before+1, missingChunks.Value())
+	mockRepo.AssertExpectations(t)
+}
+
+func newTestEnvelope(t *testing.T) *envelope.Envelope {
+	t.Helper()
+	provider, err := envelope.NewLocalKeyProvider(bytes.Repeat([]byte{3}, envelope.KeySize))
+	require.NoError(t, err)
+	return envelope.New(provider)
+}
+
+func TestFileDataKey_CreatesKeyOnFirstChunk(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	env := newTestEnvelope(t)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).WithEnvelope(env)
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileKeyByFileId", ctx, fileID).Return(sqlc.FileKey{}, database.ErrNotFound).Once()
+	mockRepo.On("CreateFileKey", ctx, mock.MatchedBy(func(arg sqlc.CreateFileKeyParams) bool {
+		return arg.FileID == fileID && arg.KeyID == env.KeyID() && arg.Algorithm == envelope.Algorithm
+	})).Return(int64(1), nil)
+
+	dataKey, err := service.fileDataKey(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Len(t, dataKey, envelope.KeySize)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFileDataKey_UsesExistingKey(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	env := newTestEnvelope(t)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).WithEnvelope(env)
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	dataKey, wrapped, err := env.NewDataKey(ctx)
+	require.NoError(t, err)
+
+	mockRepo.On("GetFileKeyByFileId", ctx, fileID).
+		Return(sqlc.FileKey{FileID: fileID, KeyID: env.KeyID(), WrappedKey: wrapped}, nil)
+
+	got, err := service.fileDataKey(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, got)
+	mockRepo.AssertNotCalled(t, "CreateFileKey", mock.Anything, mock.Anything)
+}
+
+func TestFileDataKey_LosesCreateRace(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	env := newTestEnvelope(t)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).WithEnvelope(env)
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	winnerKey, wrapped, err := env.NewDataKey(ctx)
+	require.NoError(t, err)
+
+	mockRepo.On("GetFileKeyByFileId", ctx, fileID).Return(sqlc.FileKey{}, database.ErrNotFound).Once()
+	mockRepo.On("CreateFileKey", ctx, mock.AnythingOfType("sqlc.CreateFileKeyParams")).Return(int64(0), nil)
+	mockRepo.On("GetFileKeyByFileId", ctx, fileID).
+		Return(sqlc.FileKey{FileID: fileID, KeyID: env.KeyID(), WrappedKey: wrapped}, nil).Once()
+
+	got, err := service.fileDataKey(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Equal(t, winnerKey, got)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetUploadProgress_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, ChunkCount: 4}, nil)
+	mockRepo.On("ListChunkIndexesByFileId", ctx, fileID).
+		Return([]int32{0, 2}, nil)
+	mockRepo.On("GetChunkThroughput", ctx, sqlc.GetChunkThroughputParams{FileID: fileID, Limit: throughputWindowChunks}).
+		Return(sqlc.GetChunkThroughputRow{Chunks: 2, Bytes: 4 << 20, ReceiveMs: 4000, StoreMs: 500}, nil)
+
+	progress, err := service.GetUploadProgress(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Equal(t, fileID.String(), progress.FileID)
+	assert.Equal(t, int32(4), progress.ChunkCount)
+	assert.Equal(t, []int32{0, 2}, progress.UploadedChunks)
+	assert.Equal(t, &types.UploadThroughput{
+		WindowChunks:          2,
+		ClientBytesPerSecond:  1 << 20,
+		StorageBytesPerSecond: 8 << 20,
+	}, progress.Throughput)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetUploadProgress_NoChunksYet(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, ChunkCount: 4}, nil)
+	mockRepo.On("ListChunkIndexesByFileId", ctx, fileID).
+		Return([]int32(nil), nil)
+	mockRepo.On("GetChunkThroughput", ctx, mock.AnythingOfType("sqlc.GetChunkThroughputParams")).
+		Return(sqlc.GetChunkThroughputRow{}, nil)
+
+	progress, err := service.GetUploadProgress(ctx, fileID)
+
+	require.NoError(t, err)
+	body, err := json.Marshal(progress)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"uploaded_chunks":[]`)
+}
+
+func TestGetUploadProgress_ThroughputOmitted(t *testing.T) {
+	tests := []struct {
+		name string
+		row  sqlc.GetChunkThroughputRow
+		err  error
+	}{
+		{name: "no timed chunks", row: sqlc.GetChunkThroughputRow{}},
+		{name: "query fails", row: sqlc.GetChunkThroughputRow{}, err: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+			ctx := context.Background()
+			fileID := createTestUUID()
+
+			mockRepo.On("GetFileByID", ctx, fileID).
+				Return(sqlc.File{ID: fileID, ChunkCount: 4}, nil)
+			mockRepo.On("ListChunkIndexesByFileId", ctx, fileID).
+				Return([]int32{}, nil)
+			mockRepo.On("GetChunkThroughput", ctx, mock.AnythingOfType("sqlc.GetChunkThroughputParams")).
+				Return(tt.row, tt.err)
+
+			progress, err := service.GetUploadProgress(ctx, fileID)
+
+			require.NoError(t, err)
+			assert.Nil(t, progress.Throughput)
+		})
+	}
+}
+
+func TestGetUploadStatus(t *testing.T) {
+	started := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	stale := started.Add(-StaleVerificationAge)
+	tests := []struct {
+		name        string
+		file        sqlc.File
+		wantStarted *time.Time
+		wantStalled bool
+	}{
+		{name: "uploading", file: sqlc.File{Status: "uploading", ChunkCount: 4}},
+		{
+			name:        "verifying",
+			file:        sqlc.File{Status: FileStatusVerifying, ChunkCount: 4, VerifyStartedAt: pgtype.Timestamptz{Time: started, Valid: true}},
+			wantStarted: &started,
+		},
+		{
+			name:        "verification stalled",
+			file:        sqlc.File{Status: FileStatusVerifying, ChunkCount: 4, VerifyStartedAt: pgtype.Timestamptz{Time: stale, Valid: true}},
+			wantStarted: &stale,
+			wantStalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+			ctx := context.Background()
+			fileID := createTestUUID()
+
+			mockRepo.On("GetFileByID", ctx, fileID).Return(tt.file, nil)
+			mockRepo.On("CountChunksByFileId", ctx, fileID).Return(int64(3), nil)
+
+			status, err := service.GetUploadStatus(ctx, fileID)
+
+			require.NoError(t, err)
+			assert.Equal(t, types.UploadStatusResponse{
+				FileID:          fileID.String(),
+				Status:          tt.file.Status,
+				ChunkCount:      4,
+				UploadedChunks:  3,
+				VerifyStartedAt: tt.wantStarted,
+				Stalled:         tt.wantStalled,
+			}, status)
+		})
+	}
+}
+
+func TestGetUploadProgress_FileNotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.GetUploadProgress(ctx, fileID)
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	mockRepo.AssertNotCalled(t, "ListChunkIndexesByFileId")
+}
+
+// fakeS3 records objects written through the minio client so the streaming
+// upload path can be exercised without a MinIO server.
+type fakeS3 struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	checksums map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *minio.Client) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, checksums: map[string]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Decoded-Content-Length") != "" {
+				body = decodeAWSChunked(body)
+			}
+			fake.objects[r.URL.Path] = body
+			if sum := r.Header.Get("X-Amz-Checksum-Sha256"); sum != "" {
+				fake.checksums[r.URL.Path] = sum
+			}
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodPost:
+			// A presigned POST form, held to the policy's length range
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body, _ := io.ReadAll(file)
+			size := int64(len(body))
+			if size < postPolicyLength(r.FormValue("policy"), 1) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `<Error><Code>EntityTooSmall</Code></Error>`)
+				return
+			}
+			if size > postPolicyLength(r.FormValue("policy"), 2) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `<Error><Code>EntityTooLarge</Code></Error>`)
+				return
+			}
+			fake.objects[strings.TrimSuffix(r.URL.Path, "/")+"/"+r.FormValue("key")] = body
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodHead, http.MethodGet:
+			body, ok := fake.objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				if r.Method == http.MethodGet {
+					fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+				}
+				return
+			}
+			if sum := fake.checksums[r.URL.Path]; sum != "" && r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" {
+				w.Header().Set("X-Amz-Checksum-Sha256", sum)
+			}
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(body)
+			}
+		case http.MethodDelete:
+			delete(fake.objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("key", "secret", ""),
+		Region: "us-east-1",
+	})
+	require.NoError(t, err)
+	return fake, client
+}
+
+// postPolicyLength returns the bound at position i (1 for the minimum, 2 for
+// the maximum) of the content-length-range condition in a base64 POST
+// policy.
+func postPolicyLength(policy string, i int) int64 {
+	raw, _ := base64.StdEncoding.DecodeString(policy)
+	var doc struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	_ = json.Unmarshal(raw, &doc)
+	for _, c := range doc.Conditions {
+		var cond []any
+		if json.Unmarshal(c, &cond) == nil && len(cond) == 3 && cond[0] == "content-length-range" {
+			return int64(cond[i].(float64))
+		}
+	}
+	return -1
+}
+
+// decodeAWSChunked strips the signed chunk framing minio uses for streamed
+// uploads over plain HTTP.
+func decodeAWSChunked(body []byte) []byte {
+	var out []byte
+	for len(body) > 0 {
+		header, rest, _ := bytes.Cut(body, []byte("\r\n"))
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		var size int
+		_, _ = fmt.Sscanf(string(sizeHex), "%x", &size)
+		if size == 0 {
+			break
+		}
+		out = append(out, rest[:size]...)
+		body = rest[size+2:]
+	}
+	return out
+}
+
+func TestProcessChunkUpload_StreamsToStorage(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(1), nil)
+
+	result, err := service.ProcessChunkUpload(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "uploaded", result.Status)
+	assert.Equal(t, testChunkData, fake.objects[fmt.Sprintf("/test-bucket/%s/0.enc", req.FileID)])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessChunkUpload_ServerEncryptionRoundTrip(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	sealer, err := crypto.NewSealer(bytes.Repeat([]byte{7}, crypto.SealerKeySize))
+	require.NoError(t, err)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits()).
+		WithServerEncryption(sealer)
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	// Server encrypted chunks arrive as plaintext, without the E2EE overhead
+	file := createUploadingFile()
+	file.ChunkSize = int32(len(testChunkData))
+	file.TotalSize = int64(len(testChunkData))
+
+	mockRepo.On("GetServerEncryptionKeyIdByFileId", ctx, req.FileID).
+		Return(sealer.KeyID(), nil)
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(file, nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(1), nil)
+
+	_, err = service.ProcessChunkUpload(ctx, req)
+	require.NoError(t, err)
+
+	objectName := fmt.Sprintf("%s/0.enc", req.FileID)
+	stored := fake.objects["/test-bucket/"+objectName]
+	assert.Len(t, stored, len(testChunkData)+crypto.SealerOverhead)
+	assert.NotContains(t, string(stored), string(testChunkData))
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			FileID:        req.FileID,
+			StoragePath:   objectName,
+			EncryptedSize: int64(len(testChunkData)),
+			MaxDownloads:  5,
+			ServerKeyID:   pgtype.Text{String: sealer.KeyID(), Valid: true},
+		}, nil)
+
+	download, err := service.FetchChunk(ctx, "test-share", 0, "")
+	require.NoError(t, err)
+	defer download.Body.Close()
+	got, err := io.ReadAll(download.Body)
+	require.NoError(t, err)
+	assert.Equal(t, testChunkData, got)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFetchChunk_ServerEncryptedWithoutSealer(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			FileID:       createTestUUID(),
+			StoragePath:  "file-id/0.enc",
+			MaxDownloads: 5,
+			ServerKeyID:  pgtype.Text{String: "server:0011223344556677", Valid: true},
+		}, nil)
+
+	_, err := service.FetchChunk(ctx, "test-share", 0, "")
+
+	assert.ErrorIs(t, err, ErrStorageKeyUnavailable)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyChunks(t *testing.T) {
+	ctx := context.Background()
+	fileID := createTestUUID()
+	chunks := []sqlc.Chunk{
+		{FileID: fileID, ChunkIndex: 0, StoragePath: "file/0.enc", EncryptedSize: int64(len(testChunkData)), ChunkHash: crypto.HashBytes(testChunkData)},
+		{FileID: fileID, ChunkIndex: 1, StoragePath: "file/1.enc", EncryptedSize: int64(len(testChunkData)), ChunkHash: crypto.HashBytes(testChunkData)},
+	}
+	tampered := bytes.ToUpper(testChunkData)
+
+	tests := []struct {
+		name    string
+		second  []byte
+		rehash  bool
+		wantErr bool
+	}{
+		{name: "intact", second: testChunkData, rehash: true},
+		{name: "missing object", second: nil, wantErr: true},
+		{name: "truncated object", second: testChunkData[1:], wantErr: true},
+		{name: "same size tampered with stat only", second: tampered},
+		{name: "same size tampered with rehash", second: tampered, rehash: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			mockRepo := new(MockQuerier)
+			service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+
+			fake.objects["/test-bucket/file/0.enc"] = testChunkData
+			if tt.second != nil {
+				fake.objects["/test-bucket/file/1.enc"] = tt.second
+			}
+			mockRepo.On("ListChunksByFileId", ctx, fileID).Return(chunks, nil)
+			mockRepo.On("GetFileKeyByFileId", ctx, fileID).Return(sqlc.FileKey{}, database.ErrNotFound)
+
+			var verified int
+			err := service.VerifyChunks(ctx, fileID, tt.rehash, func(n int) { verified = n })
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrChunkMismatch)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, len(chunks), verified)
+		})
+	}
+}
+
+func TestProcessChunkUpload_RecordsTimings(t *testing.T) {
+	_, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+	req.ReceiveDuration = 1500 * time.Millisecond
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.MatchedBy(func(arg sqlc.CreateChunkParams) bool {
+		return arg.ReceiveMs == pgtype.Int4{Int32: 1500, Valid: true} && arg.StoreMs.Valid
+	})).Return(int64(1), nil)
+
+	result, err := service.ProcessChunkUpload(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), result.ReceiveMs)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessChunkUpload_StreamedHashMismatchRemovesObject(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+	req.ExpectedHash = "wrong-hash-value"
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hash mismatch")
+	assert.Empty(t, fake.objects)
+	mockRepo.AssertNotCalled(t, "CreateChunk")
+}
+
+func TestProcessChunkUpload_RecordFailureRemovesObject(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", mock.Anything, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(0), errors.New("connection reset"))
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	require.Error(t, err)
+	assert.Empty(t, fake.objects)
+}
+
+func TestProcessChunkUpload_RecordFailureKeepsRecordedObject(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	// The insert reported an error but committed
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil).Once()
+	mockRepo.On("ChunkExistsByFileIdAndIndex", mock.Anything, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(true, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(0), errors.New("connection reset"))
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	require.Error(t, err)
+	assert.Len(t, fake.objects, 1)
+}
+
+func TestProcessChunkUpload_ConcurrentRecordKeepsObject(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(0), database.Classify(&pgconn.PgError{Code: "23505"}))
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	assert.ErrorIs(t, err, ErrChunkAlreadyUploaded)
+	assert.Len(t, fake.objects, 1)
+	mockRepo.AssertNumberOfCalls(t, "ChunkExistsByFileIdAndIndex", 1)
+}
+
+func TestProcessChunkUpload_FileDeletedMeanwhile(t *testing.T) {
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+	req := createValidChunkRequest()
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", mock.Anything, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, req.FileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(0), database.Classify(&pgconn.PgError{Code: "23503"}))
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, fake.objects)
+}
+
+func TestParseChunkObjectName(t *testing.T) {
+	fileID := createTestUUID()
+
+	id, index, ok := parseChunkObjectName(chunkObjectName(fileID, 12))
+	require.True(t, ok)
+	assert.Equal(t, fileID, id)
+	assert.Equal(t, int64(12), index)
+
+	for _, name := range []string{
+		"uploads/report.pdf",
+		fileID.String() + "/12",
+		fileID.String() + "/012.enc",
+		fileID.String() + "/-1.enc",
+		"not-a-uuid/0.enc",
+		fileID.String(),
+	} {
+		_, _, ok := parseChunkObjectName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestPutObject_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("key", "secret", ""),
+		Region: "us-east-1",
+	})
+	require.NoError(t, err)
+	service := NewChunkService(new(MockQuerier), client, "test-bucket", config.DefaultLimits()).
+		WithStorageUpload(config.StorageUpload{PutTimeout: 50 * time.Millisecond})
+
+	err = service.putObject(context.Background(), "chunk", bytes.NewReader(testChunkData), int64(len(testChunkData)),
+		minio.PutObjectOptions{DisableMultipart: true})
+
+	assert.ErrorIs(t, err, ErrStorageTimeout)
+
+	// A canceled request is not reported as a storage timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = service.putObject(ctx, "chunk", bytes.NewReader(testChunkData), int64(len(testChunkData)),
+		minio.PutObjectOptions{DisableMultipart: true})
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrStorageTimeout)
+}
+
+func TestMayUseMultipart(t *testing.T) {
+	service := NewChunkService(new(MockQuerier), nil, "test-bucket", config.DefaultLimits())
+
+	tests := []struct {
+		name string
+		size int64
+		opts minio.PutObjectOptions
+		want bool
+	}{
+		{name: "below default part size", size: defaultStoragePartSize, want: false},
+		{name: "above default part size", size: defaultStoragePartSize + 1, want: true},
+		{name: "unknown size", size: -1, want: true},
+		{name: "above configured part size", size: 6 << 20, opts: minio.PutObjectOptions{PartSize: 5 << 20}, want: true},
+		{name: "multipart disabled", size: -1, opts: minio.PutObjectOptions{DisableMultipart: true}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, service.mayUseMultipart(tt.size, tt.opts))
+		})
+	}
+}
+
+func TestProcessChunkUpload_ChunkTooLarge(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	limits := config.DefaultLimits()
+	limits.MaxChunkSize = 4
+	service := NewChunkService(mockRepo, nil, "test-bucket", limits)
+	ctx := context.Background()
+	req := createValidChunkRequest()
+	req.Size = limits.MaxChunkSize + e2ee.Overhead + 1
+
+	_, err := service.ProcessChunkUpload(ctx, req)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds maximum")
+	mockRepo.AssertNotCalled(t, "ChunkExistsByFileIdAndIndex")
+}
+
+func createManifestRows() []sqlc.ListChunkManifestByShareIdRow {
+	return []sqlc.ListChunkManifestByShareIdRow{
+		{ChunkCount: 2, TotalSize: 1500, MaxDownloads: 5, DownloadCount: 1, ChunkIndex: 0, EncryptedSize: 1028, ChunkHash: "hash-0"},
+		{ChunkCount: 2, TotalSize: 1500, MaxDownloads: 5, DownloadCount: 1, ChunkIndex: 1, EncryptedSize: 504, ChunkHash: "hash-1"},
+	}
+}
+
+func TestGetDownloadManifest_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").
+		Return(createManifestRows(), nil)
+
+	manifest, err := service.GetDownloadManifest(ctx, "abc123def456")
+
+	require.NoError(t, err)
+	assert.Equal(t, "abc123def456", manifest.ShareID)
+	assert.Equal(t, int32(2), manifest.ChunkCount)
+	assert.Equal(t, int64(1500), manifest.TotalSize)
+	assert.Equal(t, []types.ManifestChunk{
+		{Index: 0, Size: 1028, Hash: "hash-0", ETag: `"hash-0"`},
+		{Index: 1, Size: 504, Hash: "hash-1", ETag: `"hash-1"`},
+	}, manifest.Chunks)
+}
+
+func TestGetDownloadManifest_NotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("ListChunkManifestByShareId", ctx, "missing").
+		Return([]sqlc.ListChunkManifestByShareIdRow{}, nil)
+	mockRepo.On("GetFileByShareID", ctx, "missing").
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.GetDownloadManifest(ctx, "missing")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetDownloadManifest_Revoked(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").
+		Return([]sqlc.ListChunkManifestByShareIdRow{}, nil)
+	mockRepo.On("GetFileByShareID", ctx, "abc123def456").
+		Return(sqlc.File{ShareID: "abc123def456", Status: FileStatusRevoked}, nil)
+
+	_, err := service.GetDownloadManifest(ctx, "abc123def456")
+	assert.ErrorIs(t, err, ErrRevoked)
+
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, mock.AnythingOfType("sqlc.GetChunkByIndexAndFileShareIDParams")).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{}, database.ErrNotFound)
+
+	_, err = service.FetchChunk(ctx, "abc123def456", 0, "")
+	assert.ErrorIs(t, err, ErrRevoked)
+}
+
+func TestGetDownloadManifest_LimitReached(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	rows := createManifestRows()
+	for i := range rows {
+		rows[i].DownloadCount = rows[i].MaxDownloads
+	}
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").Return(rows, nil)
+
+	_, err := service.GetDownloadManifest(ctx, "abc123def456")
+
+	assert.ErrorIs(t, err, ErrDownloadLimitReached)
+}
+
+func TestGetDownloadManifest_UnlimitedDownloads(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	rows := createManifestRows()
+	for i := range rows {
+		rows[i].MaxDownloads = config.UnlimitedDownloads
+		rows[i].DownloadCount = 250
+	}
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").Return(rows, nil)
+
+	manifest, err := service.GetDownloadManifest(ctx, "abc123def456")
+
+	require.NoError(t, err)
+	assert.Len(t, manifest.Chunks, 2)
+}
+
+func TestGetDownloadManifest_DatabaseError(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").
+		Return([]sqlc.ListChunkManifestByShareIdRow(nil), errors.New("database connection error"))
+
+	_, err := service.GetDownloadManifest(ctx, "abc123def456")
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+// newStreamChunkService serves the given chunks of share "abc123def456" from a
+// fake S3, recording sizes in the manifest as given by recorded.
+func newStreamChunkService(t *testing.T, chunks [][]byte, recorded []int32) (*MockQuerier, *ChunkService) {
+	t.Helper()
+	fake, client := newFakeS3(t)
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits())
+
+	rows := make([]sqlc.ListChunkManifestByShareIdRow, len(chunks))
+	for i, data := range chunks {
+		storagePath := fmt.Sprintf("file-id/%d.enc", i)
+		fake.objects["/test-bucket/"+storagePath] = data
+
+		rows[i] = sqlc.ListChunkManifestByShareIdRow{
+			ChunkCount:    int32(len(chunks)),
+			MaxDownloads:  5,
+			ChunkIndex:    int32(i),
+			EncryptedSize: int64(recorded[i]),
+			ChunkHash:     fmt.Sprintf("hash-%d", i),
+		}
+		mockRepo.On("GetChunkByIndexAndFileShareID", mock.Anything, sqlc.GetChunkByIndexAndFileShareIDParams{
+			ShareID:    "abc123def456",
+			ChunkIndex: int32(i),
+		}).Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			StoragePath:   storagePath,
+			MaxDownloads:  5,
+			EncryptedSize: int64(recorded[i]),
+			ChunkHash:     rows[i].ChunkHash,
+		}, nil).Maybe()
+	}
+	mockRepo.On("ListChunkManifestByShareId", mock.Anything, "abc123def456").Return(rows, nil)
+	mockRepo.On("GetFileKeyByFileId", mock.Anything, mock.Anything).Return(sqlc.FileKey{}, database.ErrNotFound).Maybe()
+	mockRepo.On("GetWatermarkByShareId", mock.Anything, "abc123def456").Return(pgtype.Text{}, database.ErrNotFound).Maybe()
+
+	return mockRepo, service
+}
+
+func TestStreamFile_ConcatenatesChunks(t *testing.T) {
+	chunks := [][]byte{[]byte("first-chunk"), []byte("second"), []byte("3")}
+	_, service := newStreamChunkService(t, chunks, []int32{11, 6, 1})
+
+	stream, err := service.StreamFile(context.Background(), "abc123def456", "")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+
+	data, err := io.ReadAll(stream.Body)
+
+	require.NoError(t, err)
+	assert.Equal(t, "first-chunksecond3", string(data))
+	assert.Equal(t, int64(18), stream.Size)
+	assert.Equal(t, int32(3), stream.ChunkCount)
+}
+
+func TestStreamFile_DownloadRate(t *testing.T) {
+	_, service := newStreamChunkService(t, [][]byte{[]byte("chunk")}, []int32{5})
+	service.WithDownloadRate(1 << 20)
+
+	stream, err := service.StreamFile(context.Background(), "abc123def456", "")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+
+	assert.Equal(t, int64(1<<20), stream.MaxRate)
+	assert.Equal(t, int64(4096), service.downloadRate(pgtype.Int8{Int64: 4096, Valid: true}))
+	assert.Zero(t, service.downloadRate(pgtype.Int8{Int64: 0, Valid: true}))
+}
+
+func TestStreamFile_ChunkSizeMismatch(t *testing.T) {
+	chunks := [][]byte{[]byte("first-chunk"), []byte("second")}
+	_, service := newStreamChunkService(t, chunks, []int32{11, 8})
+
+	stream, err := service.StreamFile(context.Background(), "abc123def456", "")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+
+	_, err = io.ReadAll(stream.Body)
+
+	assert.ErrorContains(t, err, "chunk 1 is smaller than recorded")
+}
+
+func TestStreamFile_MissingFirstChunk(t *testing.T) {
+	mockRepo, service := newStreamChunkService(t, [][]byte{[]byte("data")}, []int32{4})
+	service.bucketName = "other-bucket"
+	mockRepo.On("UpdateFileStatus", mock.Anything, mock.Anything).Return(sqlc.File{}, nil).Maybe()
+
+	_, err := service.StreamFile(context.Background(), "abc123def456", "")
+
+	assert.ErrorIs(t, err, ErrChunkMissing)
+}
+
+// upperCaser is a watermark.Transformer upper-casing the file and appending
+// the mark.
+type upperCaser struct{}
+
+func (upperCaser) Transform(ctx context.Context, src io.Reader, mark watermark.Mark) (io.ReadCloser, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(strings.ToUpper(string(data)) + " " + mark.Text + " " + mark.ClientIP)), nil
+}
+
+// watermarkStreamShare sets a watermark on the share of newStreamChunkService.
+func watermarkStreamShare(mockRepo *MockQuerier, text string) {
+	for _, call := range mockRepo.ExpectedCalls {
+		if call.Method == "GetWatermarkByShareId" {
+			call.Unset()
+		}
+	}
+	mockRepo.On("GetWatermarkByShareId", mock.Anything, "abc123def456").Return(pgtype.Text{String: text, Valid: true}, nil)
+}
+
+func TestStreamFile_Watermarked(t *testing.T) {
+	mockRepo, service := newStreamChunkService(t, [][]byte{[]byte("first-"), []byte("second")}, []int32{6, 6})
+	service.WithWatermarker(upperCaser{})
+	watermarkStreamShare(mockRepo, "for alice")
+
+	stream, err := service.StreamFile(context.Background(), "abc123def456", "203.0.113.7")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+
+	data, err := io.ReadAll(stream.Body)
+
+	require.NoError(t, err)
+	assert.Equal(t, "FIRST-SECOND for alice 203.0.113.7", string(data))
+	assert.Equal(t, int64(-1), stream.Size)
+	assert.Equal(t, int32(2), stream.ChunkCount)
+}
+
+func TestStreamFile_WatermarkerMissing(t *testing.T) {
+	mockRepo, service := newStreamChunkService(t, [][]byte{[]byte("data")}, []int32{4})
+	watermarkStreamShare(mockRepo, "for alice")
+
+	_, err := service.StreamFile(context.Background(), "abc123def456", "")
+
+	assert.ErrorIs(t, err, ErrWatermarkUnavailable)
+}
+
+func TestFetchChunk_Watermarked(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).
+		WithWatermarker(upperCaser{})
+	ctx := context.Background()
+	mockRepo.On("GetChunkByIndexAndFileShareID", ctx, sqlc.GetChunkByIndexAndFileShareIDParams{ShareID: "abc123def456", ChunkIndex: 0}).
+		Return(sqlc.GetChunkByIndexAndFileShareIDRow{
+			MaxDownloads: 5,
+			ChunkHash:    "hash-0",
+			ServerKeyID:  pgtype.Text{String: "key", Valid: true},
+			Watermark:    pgtype.Text{String: "for alice", Valid: true},
+		}, nil)
+
+	_, err := service.FetchChunk(ctx, "abc123def456", 0, "")
+
+	assert.ErrorIs(t, err, ErrWatermarkedShare)
+}
+
+func TestStreamFile_LimitReached(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+	ctx := context.Background()
+
+	rows := createManifestRows()
+	for i := range rows {
+		rows[i].DownloadCount = rows[i].MaxDownloads
+	}
+	mockRepo.On("ListChunkManifestByShareId", ctx, "abc123def456").Return(rows, nil)
+
+	_, err := service.StreamFile(ctx, "abc123def456", "")
+
+	assert.ErrorIs(t, err, ErrDownloadLimitReached)
+	mockRepo.AssertNotCalled(t, "GetChunkByIndexAndFileShareID", mock.Anything, mock.Anything)
+}
+
+func newPresignedChunkService(t *testing.T, mockRepo *MockQuerier) (*fakeS3, *ChunkService) {
+	t.Helper()
+	fake, client := newFakeS3(t)
+	service := NewChunkService(mockRepo, client, "test-bucket", config.DefaultLimits()).
+		WithPresignedUploads(client, 15*time.Minute)
+	return fake, service
+}
+
+// postPresigned uploads data the way a browser would: a multipart form with
+// the presigned fields, then the chunk. It returns the storage status.
+func postPresigned(t *testing.T, upload types.PresignedChunkUpload, data []byte) int {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for k, v := range upload.Fields {
+		require.NoError(t, form.WriteField(k, v))
+	}
+	part, err := form.CreateFormFile("file", "chunk")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	resp, err := http.Post(upload.URL, form.FormDataContentType(), &body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// uploadingFileWithChunks is an uploading file of count chunks of chunk
+// size, the last one 10 bytes shorter.
+func uploadingFileWithChunks(count, size int32) sqlc.File {
+	return sqlc.File{
+		ID:         createTestUUID(),
+		Status:     "uploading",
+		ChunkCount: count,
+		ChunkSize:  size,
+		TotalSize:  int64(count)*int64(size) - 10,
+	}
+}
+
+func TestPresignChunkUploads(t *testing.T) {
+	_, service := newPresignedChunkService(t, new(MockQuerier))
+	file := uploadingFileWithChunks(3, 1024)
+
+	uploads, expiresAt, err := service.PresignChunkUploads(context.Background(), file, 0)
+
+	require.NoError(t, err)
+	require.Len(t, uploads, 3)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, time.Minute)
+	for i, u := range uploads {
+		assert.Equal(t, int32(i), u.ChunkIndex)
+		assert.Equal(t, http.MethodPost, u.Method)
+		assert.Contains(t, u.URL, "/test-bucket")
+		assert.Equal(t, fmt.Sprintf("%s/%d.enc", file.ID, i), u.Fields["key"])
+		assert.NotEmpty(t, u.Fields["x-amz-signature"])
+	}
+	assert.Equal(t, int64(1024+e2ee.Overhead), postPolicyLength(uploads[0].Fields["policy"], 1))
+	assert.Equal(t, int64(1024+e2ee.Overhead), postPolicyLength(uploads[0].Fields["policy"], 2))
+	assert.Equal(t, int64(1014+e2ee.Overhead), postPolicyLength(uploads[2].Fields["policy"], 2), "the last chunk is shorter")
+}
+
+func TestPresignChunkUploads_Batches(t *testing.T) {
+	_, service := newPresignedChunkService(t, new(MockQuerier))
+	file := uploadingFileWithChunks(PresignBatchSize+50, 1024)
+
+	uploads, _, err := service.PresignChunkUploads(context.Background(), file, 0)
+	require.NoError(t, err)
+	assert.Len(t, uploads, int(PresignBatchSize))
+
+	uploads, _, err = service.PresignChunkUploads(context.Background(), file, PresignBatchSize)
+	require.NoError(t, err)
+	require.Len(t, uploads, 50)
+	assert.Equal(t, PresignBatchSize, uploads[0].ChunkIndex)
+
+	_, _, err = service.PresignChunkUploads(context.Background(), file, file.ChunkCount)
+	assert.ErrorIs(t, err, ErrInvalidChunkIndex)
+}
+
+func TestPresignChunkUploads_UnavailableWithEnvelope(t *testing.T) {
+	_, service := newPresignedChunkService(t, new(MockQuerier))
+	service.WithEnvelope(newTestEnvelope(t))
+
+	_, _, err := service.PresignChunkUploads(context.Background(), createUploadingFile(), 0)
+
+	assert.ErrorIs(t, err, ErrPresignedDisabled)
+}
+
+func TestPresignChunkUploads_StorageRefusesOtherSizes(t *testing.T) {
+	fake, service := newPresignedChunkService(t, new(MockQuerier))
+
+	uploads, _, err := service.PresignChunkUploads(context.Background(), createUploadingFile(), 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, postPresigned(t, uploads[0], append(testChunkData, 'x')))
+	assert.Equal(t, http.StatusBadRequest, postPresigned(t, uploads[0], testChunkData[:len(testChunkData)-1]))
+	assert.Empty(t, fake.objects)
+	assert.Equal(t, http.StatusNoContent, postPresigned(t, uploads[0], testChunkData))
+}
+
+func TestChunkUploadURLs(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	_, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	file := uploadingFileWithChunks(PresignBatchSize+1, 1024)
+	mockRepo.On("GetFileByID", ctx, file.ID).Return(file, nil)
+
+	resp, err := service.ChunkUploadURLs(ctx, file.ID, PresignBatchSize)
+
+	require.NoError(t, err)
+	require.Len(t, resp.UploadURLs, 1)
+	assert.Equal(t, PresignBatchSize, resp.UploadURLs[0].ChunkIndex)
+	assert.NotEmpty(t, resp.UploadURLsExpireAt)
+}
+
+func TestChunkUploadURLs_NotUploading(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	_, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	file := uploadingFileWithChunks(2, 1024)
+	file.Status = "ready"
+	mockRepo.On("GetFileByID", ctx, file.ID).Return(file, nil)
+
+	_, err := service.ChunkUploadURLs(ctx, file.ID, 0)
+
+	assert.ErrorIs(t, err, ErrNotUploading)
+}
+
+func TestConfirmChunkUpload_HashesObject(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	_, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	file := createUploadingFile()
+	fileID := file.ID
+
+	uploads, _, err := service.PresignChunkUploads(ctx, file, 0)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, postPresigned(t, uploads[0], testChunkData))
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(file, nil)
+	mockRepo.On("CreateChunk", ctx, sqlc.CreateChunkParams{
+		FileID:        fileID,
+		ChunkIndex:    0,
+		StoragePath:   fmt.Sprintf("%s/0.enc", fileID),
+		EncryptedSize: int64(len(testChunkData)),
+		ChunkHash:     crypto.HashBytes(testChunkData),
+	}).Return(int64(1), nil)
+
+	result, err := service.ConfirmChunkUpload(ctx, fileID, 0, crypto.HashBytes(testChunkData))
+
+	require.NoError(t, err)
+	assert.Equal(t, "uploaded", result.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+// storeChunkObject puts data where chunk 0 of fileID is stored, as if it had
+// been uploaded past the policy, optionally with the checksum storage
+// computed for it.
+func storeChunkObject(fake *fakeS3, fileID pgtype.UUID, data []byte, checksum string) {
+	path := fmt.Sprintf("/test-bucket/%s/0.enc", fileID)
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.objects[path] = data
+	if checksum != "" {
+		fake.checksums[path] = checksum
+	}
+}
+
+func TestConfirmChunkUpload_UsesStoredChecksum(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	fake, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	sum := sha256.Sum256(testChunkData)
+	storeChunkObject(fake, fileID, testChunkData, base64.StdEncoding.EncodeToString(sum[:]))
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(createUploadingFile(), nil)
+	mockRepo.On("CreateChunk", ctx, mock.AnythingOfType("sqlc.CreateChunkParams")).
+		Return(int64(1), nil)
+
+	_, err := service.ConfirmChunkUpload(ctx, fileID, 0, crypto.HashBytes(testChunkData))
+
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmChunkUpload_HashMismatchRemovesObject(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	fake, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	file := createUploadingFile()
+	fileID := file.ID
+
+	uploads, _, err := service.PresignChunkUploads(ctx, file, 0)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, postPresigned(t, uploads[0], testChunkData))
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(file, nil)
+
+	_, err = service.ConfirmChunkUpload(ctx, fileID, 0, "wrong-hash-value")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hash mismatch")
+	assert.Empty(t, fake.objects)
+	mockRepo.AssertNotCalled(t, "CreateChunk")
+}
+
+func TestConfirmChunkUpload_WrongSizeRemovesObject(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	fake, service := newPresignedChunkService(t, mockRepo)
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	// Storage that ignores the policy's length range
+	short := testChunkData[:len(testChunkData)-1]
+	storeChunkObject(fake, fileID, short, "")
+
+	mockRepo.On("ChunkExistsByFileIdAndIndex", ctx, mock.AnythingOfType("sqlc.ChunkExistsByFileIdAndIndexParams")).
+		Return(false, nil)
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(createUploadingFile(), nil)
+
+	_, err := service.ConfirmChunkUpload(ctx, fileID, 0, crypto.HashBytes(short))
+
+	assert.ErrorIs(t, err, ErrInvalidChunkSize)
+	assert.Empty(t, fake.objects)
+}
+
+func TestConfirmChunkUpload_ObjectMissing(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	_, service := newPresignedChunkService(t, mockRepo)
+
+	_, err := service.ConfirmChunkUpload(context.Background(), createTestUUID(), 0, crypto.HashBytes(testChunkData))
+
+	assert.ErrorIs(t, err, ErrChunkNotUploaded)
+	mockRepo.AssertNotCalled(t, "ChunkExistsByFileIdAndIndex")
+}
+
+func TestConfirmChunkUpload_Disabled(t *testing.T) {
+	service := NewChunkService(new(MockQuerier), nil, "test-bucket", config.DefaultLimits())
+
+	_, err := service.ConfirmChunkUpload(context.Background(), createTestUUID(), 0, "hash")
+
+	assert.ErrorIs(t, err, ErrPresignedDisabled)
+}
+
+type fakeCompleter struct {
+	completed []string
+}
+
+func (f *fakeCompleter) IssueDownloadNonce(ctx context.Context, shareID string) (string, error) {
+	return "nonce-" + shareID, nil
+}
+
+func (f *fakeCompleter) CompleteDownload(ctx context.Context, shareID, nonce string) error {
+	f.completed = append(f.completed, nonce)
+	return nil
+}
+
+func TestRecordChunksServed(t *testing.T) {
+	params := sqlc.RecordDownloadNonceChunksParams{
+		ChunkIndexes: []int32{3},
+		NonceHash:    crypto.HashBytes([]byte("nonce")),
+		ShareID:      "abc123def456",
+	}
+
+	for _, tc := range []struct {
+		name      string
+		row       sqlc.RecordDownloadNonceChunksRow
+		err       error
+		completed []string
+	}{
+		{name: "last chunk", row: sqlc.RecordDownloadNonceChunksRow{ServedChunks: 4, ChunkCount: 4}, completed: []string{"nonce"}},
+		{name: "chunks left", row: sqlc.RecordDownloadNonceChunksRow{ServedChunks: 3, ChunkCount: 4}},
+		{name: "served before", err: database.ErrNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			ctx := context.Background()
+			completer := new(fakeCompleter)
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).
+				WithDownloadCompleter(completer)
+			mockRepo.On("RecordDownloadNonceChunks", ctx, params).Return(tc.row, tc.err)
+
+			err := service.RecordChunksServed(ctx, "abc123def456", "nonce", []int32{3})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.completed, completer.completed)
+		})
+	}
+}
+
+func TestCheckDownloadNonce(t *testing.T) {
+	params := sqlc.GetDownloadNonceParams{
+		NonceHash: crypto.HashBytes([]byte("nonce")),
+		ShareID:   "abc123def456",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		nonce    string
+		readOnly bool
+		row      sqlc.GetDownloadNonceRow
+		err      error
+		file     sqlc.File
+		link     *sqlc.GetShareLinkRow
+		wantErr  error
+	}{
+		{name: "live nonce", nonce: "nonce"},
+		{name: "missing nonce", wantErr: ErrInvalidDownloadNonce},
+		{name: "unknown or expired nonce", nonce: "nonce", err: database.ErrNotFound, wantErr: ErrInvalidDownloadNonce},
+		{name: "download already counted", nonce: "nonce", row: sqlc.GetDownloadNonceRow{UsedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}, wantErr: ErrInvalidDownloadNonce},
+		{name: "read-only mode", readOnly: true, file: sqlc.File{MaxDownloads: config.UnlimitedDownloads}},
+		{name: "read-only mode with a download limit", readOnly: true, file: sqlc.File{MaxDownloads: 5}, wantErr: readonly.ErrReadOnly},
+		{name: "read-only mode with a limited link", readOnly: true, file: sqlc.File{MaxDownloads: config.UnlimitedDownloads}, link: &sqlc.GetShareLinkRow{MaxDownloads: 2}, wantErr: readonly.ErrReadOnly},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			ctx := context.Background()
+			if tc.link != nil {
+				ctx = WithShareLink(ctx, tc.link)
+			}
+			service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits()).
+				WithReadOnly(readonly.New(tc.readOnly))
+			mockRepo.On("GetDownloadNonce", ctx, params).Return(tc.row, tc.err).Maybe()
+			mockRepo.On("GetFileByShareID", ctx, "abc123def456").Return(tc.file, nil).Maybe()
+
+			err := service.CheckDownloadNonce(ctx, "abc123def456", tc.nonce)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRecordChunksServed_NoNonce(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+
+	err := service.RecordChunksServed(context.Background(), "abc123def456", "", []int32{0})
+
+	require.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "RecordDownloadNonceChunks")
+}
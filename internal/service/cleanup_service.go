@@ -2,29 +2,177 @@ package service
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/notify"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
 )
 
+// AuditActionFileEvicted records a share removed early to make room under
+// the storage quota.
+const AuditActionFileEvicted = "file.evicted"
+
+// evictionBatchSize bounds how many shares one ReclaimSpace call considers.
+const evictionBatchSize = 100
+
+// purgedDeleteBatchSize bounds how many expired files one delete removes, so
+// a large backlog does not hold locks for long.
+const purgedDeleteBatchSize = 1000
+
+// OrphanGracePeriod is how old a chunk object without a chunk row must be
+// before reconciliation counts it as orphaned. Chunks are written before their row,
+// and presigned chunks are only recorded once confirmed, so younger objects
+// may still be on their way to a row.
+const OrphanGracePeriod = 24 * time.Hour
+
+// StaleVerificationAge is how long a file may stay verifying before cleanup
+// assumes the server checking it went away and moves it back to uploading,
+// so its uploader can finalize again.
+const StaleVerificationAge = 6 * time.Hour
+
 type CleanupService struct {
-	queries     *sqlc.Queries
+	queries     sqlc.Querier
 	minioClient *minio.Client
 	bucketName  string
+	// singleDeletes removes objects one request at a time, for providers
+	// without multi-object delete
+	singleDeletes bool
+	notifier      *notify.Notifier
+	events        *events.Bus
+	// staleUploadAge is how old an incomplete multipart upload must be
+	// before it is aborted; zero leaves them alone
+	staleUploadAge time.Duration
+	// expiredRetention is how long the rows of an expired file are kept
+	// after its chunks were purged; zero keeps them
+	expiredRetention time.Duration
+	// orphanGrace is how old a chunk object without a row must be before
+	// Reconcile counts it as orphaned
+	orphanGrace    time.Duration
+	reconciliation config.Reconciliation
 }
 
-func NewCleanupService(queries *sqlc.Queries, minioClient *minio.Client, bucketName string) *CleanupService {
+func NewCleanupService(queries sqlc.Querier, minioClient *minio.Client, bucketName string) *CleanupService {
 	return &CleanupService{
 		queries:     queries,
 		minioClient: minioClient,
 		bucketName:  bucketName,
+		orphanGrace: OrphanGracePeriod,
 	}
 }
 
+// WithSingleDeletes deletes expired chunks one by one instead of in bulk.
+func (s *CleanupService) WithSingleDeletes() *CleanupService {
+	s.singleDeletes = true
+	return s
+}
+
+// WithNotifier sends file_expired events to the webhooks of expired files.
+func (s *CleanupService) WithNotifier(n *notify.Notifier) *CleanupService {
+	s.notifier = n
+	return s
+}
+
+// WithEvents publishes expired and evicted shares to bus.
+func (s *CleanupService) WithEvents(bus *events.Bus) *CleanupService {
+	s.events = bus
+	return s
+}
+
+// WithOrphanGrace spares chunk objects younger than grace from
+// reconciliation. It never goes below OrphanGracePeriod.
+func (s *CleanupService) WithOrphanGrace(grace time.Duration) *CleanupService {
+	s.orphanGrace = max(grace, OrphanGracePeriod)
+	return s
+}
+
+// WithReconciliation sets what Reconcile repairs.
+func (s *CleanupService) WithReconciliation(r config.Reconciliation) *CleanupService {
+	s.reconciliation = r
+	return s
+}
+
+// WithStaleUploadSweep aborts multipart uploads in the bucket started more
+// than age ago, whose parts would otherwise take up storage for good.
+func (s *CleanupService) WithStaleUploadSweep(age time.Duration) *CleanupService {
+	s.staleUploadAge = age
+	return s
+}
+
+// WithExpiredRetention deletes the rows of expired files, and their chunk
+// rows, once their chunks were purged more than retention ago. The audit log
+// of a deleted file is kept without its file ID.
+func (s *CleanupService) WithExpiredRetention(retention time.Duration) *CleanupService {
+	s.expiredRetention = retention
+	return s
+}
+
 func (s *CleanupService) CleanupExpiredFiles(ctx context.Context) (int, error) {
+	if pruned, err := s.queries.DeleteExpiredDownloadNonces(ctx); err != nil {
+		slog.Warn("failed to prune expired download nonces",
+			slog.String("error", err.Error()),
+		)
+	} else if pruned > 0 {
+		slog.Debug("pruned expired download nonces", slog.Int64("count", pruned))
+	}
+
+	if pruned, err := s.queries.DeleteExpiredUploadSlots(ctx); err != nil {
+		slog.Warn("failed to prune expired upload slots",
+			slog.String("error", err.Error()),
+		)
+	} else if pruned > 0 {
+		slog.Debug("pruned expired upload slots", slog.Int64("count", pruned))
+	}
+
+	if pruned, err := s.queries.DeleteExpiredUploadSquatters(ctx); err != nil {
+		slog.Warn("failed to prune expired upload squatter flags",
+			slog.String("error", err.Error()),
+		)
+	} else if pruned > 0 {
+		slog.Debug("pruned expired upload squatter flags", slog.Int64("count", pruned))
+	}
+
+	if pruned, err := s.queries.DeleteExpiredPastes(ctx); err != nil {
+		slog.Warn("failed to prune expired pastes",
+			slog.String("error", err.Error()),
+		)
+	} else if pruned > 0 {
+		slog.Debug("pruned expired pastes", slog.Int64("count", pruned))
+	}
+
+	staleBefore := pgtype.Timestamptz{Time: time.Now().Add(-StaleVerificationAge), Valid: true}
+	if reset, err := s.queries.ResetStaleVerifications(ctx, staleBefore); err != nil {
+		slog.Warn("failed to reset stale verifications",
+			slog.String("error", err.Error()),
+		)
+	} else if reset > 0 {
+		slog.Info("reset stale verifications", slog.Int64("count", reset))
+	}
+
+	if aborted, err := s.abortStaleUploads(ctx); err != nil {
+		slog.Warn("failed to abort stale multipart uploads",
+			slog.String("error", err.Error()),
+		)
+	} else if aborted > 0 {
+		slog.Info("aborted stale multipart uploads", slog.Int("count", aborted))
+	}
+
+	if deleted, err := s.deletePurgedFiles(ctx); err != nil {
+		slog.Warn("failed to delete purged files",
+			slog.String("error", err.Error()),
+		)
+	} else if deleted > 0 {
+		slog.Info("deleted purged files", slog.Int64("count", deleted))
+	}
+
 	expiredFiles, err := s.queries.GetExpiredFiles(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get expired files: %w", err)
@@ -47,9 +195,110 @@ func (s *CleanupService) CleanupExpiredFiles(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("failed to expire files: %w", err)
 	}
 
+	s.notifyUploaders(ctx, expiredIds, notify.EventFileExpired)
+	for _, file := range expiredFiles {
+		s.events.Publish(events.Event{Type: events.TypeExpired, ShareID: file.ShareID})
+	}
+
 	return len(expiredFiles), nil
 }
 
+// ReclaimSpace evicts shares until at least bytes are freed, taking only
+// those that can no longer be downloaded: fully downloaded shares first,
+// then those past their expiry that cleanup has not reached yet. Evicted
+// shares are expired at once, audited and reported to their webhooks. It
+// returns the total size of the evicted shares, which may fall short of
+// bytes.
+func (s *CleanupService) ReclaimSpace(ctx context.Context, bytes int64) (int64, error) {
+	candidates, err := s.queries.ListEvictionCandidates(ctx, evictionBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list eviction candidates: %w", err)
+	}
+
+	var freed int64
+	var evicted []sqlc.GetExpiredFilesRow
+	var evictedIds []pgtype.UUID
+	for _, candidate := range candidates {
+		if freed >= bytes {
+			break
+		}
+
+		shareID, err := s.queries.EvictFile(ctx, candidate.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			// Expired by cleanup or evicted by another upload meanwhile
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to evict file",
+				slog.String("error", err.Error()),
+				slog.String("share_id", candidate.ShareID),
+			)
+			break
+		}
+
+		_, err = s.queries.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  candidate.ID,
+			Action:  AuditActionFileEvicted,
+			Actor:   "system",
+			Details: fmt.Appendf(nil, `{"total_size":%d}`, candidate.TotalSize),
+		})
+		if err != nil {
+			slog.Error("failed to record file eviction",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+		}
+
+		slog.Info("file evicted to free storage",
+			slog.String("share_id", shareID),
+			slog.Int64("total_size", candidate.TotalSize),
+		)
+
+		freed += candidate.TotalSize
+		evicted = append(evicted, sqlc.GetExpiredFilesRow{ID: candidate.ID, ShareID: shareID, ChunkCount: candidate.ChunkCount})
+		evictedIds = append(evictedIds, candidate.ID)
+		s.events.Publish(events.Event{Type: events.TypeRevoked, ShareID: shareID})
+	}
+
+	if len(evicted) == 0 {
+		return 0, nil
+	}
+
+	// The shares are already gone for downloaders, so a failed delete only
+	// leaves orphaned objects behind
+	if err := s.deleteFileChunks(ctx, evicted); err != nil {
+		slog.Error("failed to delete chunks of evicted files",
+			slog.String("error", err.Error()),
+		)
+	}
+
+	s.notifyUploaders(ctx, evictedIds, notify.EventFileEvicted)
+
+	return freed, nil
+}
+
+func (s *CleanupService) notifyUploaders(ctx context.Context, fileIDs []pgtype.UUID, eventType string) {
+	if s.notifier == nil {
+		return
+	}
+
+	webhooks, err := s.queries.ListFileWebhooksByFileIds(ctx, fileIDs)
+	if err != nil {
+		slog.Error("failed to list webhooks of files",
+			slog.String("error", err.Error()),
+			slog.String("event", eventType),
+		)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		s.notifier.Notify(notify.Target{URL: webhook.Url, Secret: webhook.Secret}, notify.Event{
+			Type:    eventType,
+			ShareID: webhook.ShareID,
+		})
+	}
+}
+
 func (s *CleanupService) deleteFileChunks(ctx context.Context, expiredFiles []sqlc.GetExpiredFilesRow) error {
 	objectsCh := make(chan minio.ObjectInfo)
 	go func() {
@@ -64,7 +313,48 @@ func (s *CleanupService) deleteFileChunks(ctx context.Context, expiredFiles []sq
 		}
 	}()
 
+	return s.removeObjects(ctx, objectsCh)
+}
+
+// deletePurgedFiles deletes the rows of files purged longer than the expired
+// retention ago, batch by batch, and returns how many it deleted.
+func (s *CleanupService) deletePurgedFiles(ctx context.Context) (int64, error) {
+	if s.expiredRetention <= 0 {
+		return 0, nil
+	}
+
+	before := pgtype.Timestamptz{Time: time.Now().Add(-s.expiredRetention), Valid: true}
+	var total int64
+	for {
+		deleted, err := s.queries.DeletePurgedFiles(ctx, sqlc.DeletePurgedFilesParams{
+			PurgedBefore: before,
+			BatchSize:    purgedDeleteBatchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted < purgedDeleteBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// removeObjects deletes the objects received from objectsCh, in bulk unless
+// the provider lacks multi-object delete. It returns the last failure.
+func (s *CleanupService) removeObjects(ctx context.Context, objectsCh <-chan minio.ObjectInfo) error {
 	var lastErr error
+	if s.singleDeletes {
+		for obj := range objectsCh {
+			if err := s.minioClient.RemoveObject(ctx, s.bucketName, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+				slog.Error("failed to delete object", slog.String("object", obj.Key),
+					slog.String("error", err.Error()))
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+
 	errorCh := s.minioClient.RemoveObjects(ctx, s.bucketName, objectsCh,
 		minio.RemoveObjectsOptions{})
 	for e := range errorCh {
@@ -75,3 +365,219 @@ func (s *CleanupService) deleteFileChunks(ctx context.Context, expiredFiles []sq
 
 	return lastErr
 }
+
+// abortStaleUploads aborts the incomplete multipart uploads older than the
+// stale upload age and returns how many it aborted.
+func (s *CleanupService) abortStaleUploads(ctx context.Context) (int, error) {
+	if s.staleUploadAge <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-s.staleUploadAge)
+	core := minio.Core{Client: s.minioClient}
+	// Stops the listing if the sweep returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	aborted := 0
+	for upload := range s.minioClient.ListIncompleteUploads(ctx, s.bucketName, "", true) {
+		if upload.Err != nil {
+			return aborted, fmt.Errorf("failed to list incomplete uploads: %w", upload.Err)
+		}
+		if upload.Initiated.After(cutoff) {
+			continue
+		}
+		if err := core.AbortMultipartUpload(ctx, s.bucketName, upload.Key, upload.UploadID); err != nil {
+			return aborted, fmt.Errorf("failed to abort upload of %s: %w", upload.Key, err)
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+// ReconcileReport counts the drift between storage and the database that
+// one reconciliation found, and what it repaired.
+type ReconcileReport struct {
+	// OrphanedObjects are chunk objects without a row of an unpurged file.
+	OrphanedObjects int
+	// MissingObjects are chunk rows of unpurged files without their object.
+	MissingObjects int
+	RemovedObjects int
+	RemovedRows    int
+}
+
+// reconciliation counts the drift found and repaired by every run.
+var reconcileStats = expvar.NewMap("storage_reconciliation")
+
+// Reconcile lists the chunk objects in storage and cross-checks them against
+// the chunk rows of files that have not been purged. Orphaned objects, such
+// as those of a chunk whose row failed to insert or of a purged file whose
+// delete failed, are removed if RemoveObjects is configured. Rows whose
+// object is gone are removed if RemoveRows is, and their file is marked
+// corrupt unless it is still uploading, so the chunk can be uploaded again.
+//
+// Objects younger than the orphan grace period, rows added after the
+// listing started and objects not named like chunks are left alone.
+func (s *CleanupService) Reconcile(ctx context.Context) (ReconcileReport, error) {
+	removeObjects, removeRows := s.reconciliation.RemoveObjects, s.reconciliation.RemoveRows
+	started := time.Now()
+	cutoff := started.Add(-s.orphanGrace)
+	// Stops the listing if reconciliation returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		report  ReconcileReport
+		orphans []minio.ObjectInfo
+		missing []chunkRef
+		fileID  pgtype.UUID
+		chunks  map[int64]time.Time
+		stored  map[int64]bool
+		seen    = make(map[pgtype.UUID]bool)
+	)
+	// Rows of the previous file whose object the listing did not contain
+	flush := func() {
+		for index, uploadedAt := range chunks {
+			if !stored[index] && uploadedAt.Before(started) {
+				missing = append(missing, chunkRef{fileID: fileID, index: index})
+			}
+		}
+	}
+
+	// Listings are sorted by key, so the chunks of a file come together
+	for obj := range s.minioClient.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if obj.Err != nil {
+			return report, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		id, index, ok := parseChunkObjectName(obj.Key)
+		if !ok {
+			continue
+		}
+		if id != fileID || chunks == nil {
+			flush()
+			var err error
+			if chunks, err = s.unpurgedChunks(ctx, id); err != nil {
+				return report, err
+			}
+			fileID, stored = id, make(map[int64]bool)
+			seen[id] = true
+		}
+		stored[index] = true
+		if _, ok := chunks[index]; !ok && obj.LastModified.Before(cutoff) {
+			orphans = append(orphans, minio.ObjectInfo{Key: obj.Key})
+		}
+	}
+	flush()
+
+	// Files with rows but not a single object in the listing
+	ids, err := s.queries.ListUnpurgedFileIdsWithChunks(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list files with chunks: %w", err)
+	}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		if chunks, err = s.unpurgedChunks(ctx, id); err != nil {
+			return report, err
+		}
+		fileID, stored = id, nil
+		flush()
+	}
+
+	report.OrphanedObjects = len(orphans)
+	report.MissingObjects = len(missing)
+	reconcileStats.Add("runs", 1)
+	reconcileStats.Add("orphaned_objects", int64(report.OrphanedObjects))
+	reconcileStats.Add("missing_objects", int64(report.MissingObjects))
+
+	for _, obj := range orphans {
+		slog.Warn("orphaned chunk object",
+			slog.String("object", obj.Key),
+			slog.Bool("removing", removeObjects),
+		)
+	}
+	if removeObjects && len(orphans) > 0 {
+		objectsCh := make(chan minio.ObjectInfo, len(orphans))
+		for _, obj := range orphans {
+			objectsCh <- obj
+		}
+		close(objectsCh)
+		if err := s.removeObjects(ctx, objectsCh); err != nil {
+			return report, fmt.Errorf("failed to remove orphaned objects: %w", err)
+		}
+		report.RemovedObjects = len(orphans)
+		reconcileStats.Add("removed_objects", int64(report.RemovedObjects))
+	}
+
+	for _, chunk := range missing {
+		removed, err := s.repairMissingChunk(ctx, chunk, removeRows)
+		if err != nil {
+			return report, err
+		}
+		if removed {
+			report.RemovedRows++
+			reconcileStats.Add("removed_rows", 1)
+		}
+	}
+
+	return report, nil
+}
+
+// chunkRef names a chunk by its file and index.
+type chunkRef struct {
+	fileID pgtype.UUID
+	index  int64
+}
+
+// unpurgedChunks returns when each chunk row of a file was added, or no rows
+// if the file has been purged.
+func (s *CleanupService) unpurgedChunks(ctx context.Context, fileID pgtype.UUID) (map[int64]time.Time, error) {
+	rows, err := s.queries.ListChunksOfUnpurgedFile(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks of %s: %w", fileID, err)
+	}
+	chunks := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		chunks[int64(row.ChunkIndex)] = row.UploadedAt.Time
+	}
+	return chunks, nil
+}
+
+// repairMissingChunk checks again that the object of a chunk row is gone,
+// since listings are not guaranteed to be consistent, and then reports it
+// and, if remove is set, removes the row. It reports whether it did.
+func (s *CleanupService) repairMissingChunk(ctx context.Context, chunk chunkRef, remove bool) (bool, error) {
+	objectName := chunkObjectName(chunk.fileID, chunk.index)
+	_, err := s.minioClient.StatObject(ctx, s.bucketName, objectName, minio.StatObjectOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if minio.ToErrorResponse(err).Code != minio.NoSuchKey {
+		return false, fmt.Errorf("failed to stat %s: %w", objectName, err)
+	}
+
+	slog.Warn("chunk row without object",
+		slog.String("file_id", chunk.fileID.String()),
+		slog.Int64("chunk_index", chunk.index),
+		slog.Bool("removing", remove),
+	)
+	if !remove {
+		return false, nil
+	}
+
+	deleted, err := s.queries.DeleteChunkOfUnpurgedFile(ctx, sqlc.DeleteChunkOfUnpurgedFileParams{
+		FileID:     chunk.fileID,
+		ChunkIndex: int32(chunk.index),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete chunk row of %s: %w", objectName, err)
+	}
+	if deleted == 0 {
+		// The file was purged in the meantime
+		return false, nil
+	}
+	if err := s.queries.MarkUnpurgedFileCorrupt(ctx, chunk.fileID); err != nil {
+		return true, fmt.Errorf("failed to mark file %s corrupt: %w", chunk.fileID, err)
+	}
+	return true, nil
+}
@@ -3,13 +3,21 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/notify"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/ilkin0/gzln/internal/testutil"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,7 +37,7 @@ func setupCleanupTestEnv(t *testing.T) (*cleanupTestEnv, func()) {
 	containers := testutil.SetupTestContainers(t)
 
 	cleanupService := NewCleanupService(
-		containers.Database.Queries,
+		containers.Querier(),
 		containers.MinioClient.Client,
 		containers.MinioClient.BucketName,
 	)
@@ -189,3 +197,222 @@ func TestCleanupExpiredFiles_Integration_ChunksDeletedFromMinIO(t *testing.T) {
 		require.Error(t, err, "Chunk %d should be deleted from MinIO", i)
 	}
 }
+
+// newWebhookReceiver returns a URL that collects the webhook events posted
+// to it.
+func TestCleanupExpiredFiles_Integration_DeletesPurgedFilesAfterRetention(t *testing.T) {
+	env, cleanup := setupCleanupTestEnv(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	env.cleanupService.WithExpiredRetention(30 * 24 * time.Hour)
+
+	old := testutil.CreateExpiredFile(t, env.queries, env.db, ctx)
+	recent := testutil.CreateExpiredFile(t, env.queries, env.db, ctx)
+	_, err := env.queries.CreateChunk(ctx, sqlc.CreateChunkParams{
+		FileID:        old.ID,
+		StoragePath:   chunkObjectName(old.ID, 0),
+		EncryptedSize: 512,
+		ChunkHash:     "hash-0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, env.queries.ExpireFilesByIds(ctx, []pgtype.UUID{old.ID, recent.ID}))
+	_, err = env.db.Pool.Exec(ctx, "UPDATE files SET purged_at = now() - interval '31 days' WHERE id = $1", old.ID)
+	require.NoError(t, err)
+
+	_, err = env.cleanupService.CleanupExpiredFiles(ctx)
+	require.NoError(t, err)
+
+	_, err = env.queries.GetFileByID(ctx, old.ID)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	indexes, err := env.queries.ListChunkIndexesByFileId(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Empty(t, indexes)
+
+	// Files purged within the retention window are kept for audits
+	kept, err := env.queries.GetFileByID(ctx, recent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "expired", kept.Status)
+}
+
+func newWebhookReceiver(t *testing.T) (string, <-chan notify.Event) {
+	t.Helper()
+	events := make(chan notify.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e notify.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		events <- e
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, events
+}
+
+func receiveEvent(t *testing.T, events <-chan notify.Event) notify.Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+		return notify.Event{}
+	}
+}
+
+func TestCleanupExpiredFiles_Integration_NotifiesWebhooks(t *testing.T) {
+	env, cleanup := setupCleanupTestEnv(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	// The receiver listens on loopback
+	env.cleanupService.WithNotifier(notify.New(true))
+
+	url, events := newWebhookReceiver(t)
+	expiredFile := testutil.CreateExpiredFile(t, env.queries, env.db, ctx)
+	_, err := env.queries.CreateFileWebhook(ctx, sqlc.CreateFileWebhookParams{
+		FileID: expiredFile.ID,
+		Url:    url,
+		Secret: "secret",
+	})
+	require.NoError(t, err)
+
+	deleted, err := env.cleanupService.CleanupExpiredFiles(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	e := receiveEvent(t, events)
+	assert.Equal(t, notify.EventFileExpired, e.Type)
+	assert.Equal(t, expiredFile.ShareID, e.ShareID)
+}
+
+func TestReclaimSpace_Integration_EvictsOnlyUndownloadableShares(t *testing.T) {
+	env, cleanup := setupCleanupTestEnv(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	// The receiver listens on loopback
+	env.cleanupService.WithNotifier(notify.New(true))
+
+	createFile := func(expiresIn time.Duration) sqlc.File {
+		opts := testutil.DefaultTestFileOptions()
+		opts.ExpiresIn = expiresIn
+		file := testutil.CreateTestFile(t, env.queries, ctx, opts)
+		testutil.UploadTestChunks(t, env.minioClient, env.bucketName, file.ID.String(), int(file.ChunkCount))
+		return file
+	}
+
+	longLived := createFile(72 * time.Hour)
+	expiringSoon := createFile(2 * time.Hour)
+	// Past its expiry but not yet reached by cleanup
+	pastExpiry := createFile(-time.Hour)
+	downloaded := createFile(48 * time.Hour)
+	_, err := env.queries.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{ID: downloaded.ID, Status: "exhausted"})
+	require.NoError(t, err)
+	uploading := testutil.CreateUploadingFile(t, env.queries, ctx)
+
+	url, events := newWebhookReceiver(t)
+	_, err = env.queries.CreateFileWebhook(ctx, sqlc.CreateFileWebhookParams{
+		FileID: downloaded.ID,
+		Url:    url,
+		Secret: "secret",
+	})
+	require.NoError(t, err)
+
+	// More than the undownloadable shares hold, so live ones would be next
+	freed, err := env.cleanupService.ReclaimSpace(ctx, 1<<20)
+
+	require.NoError(t, err)
+	assert.Equal(t, downloaded.TotalSize+pastExpiry.TotalSize, freed)
+
+	for file, want := range map[sqlc.File]string{
+		downloaded:   "expired",
+		pastExpiry:   "expired",
+		expiringSoon: "ready",
+		longLived:    "ready",
+		uploading:    "uploading",
+	} {
+		got, err := env.queries.GetFileByID(ctx, file.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.Status, "share %s", file.ShareID)
+	}
+
+	_, err = env.minioClient.StatObject(ctx, env.bucketName, fmt.Sprintf("%s/0.enc", downloaded.ID.String()), minio.StatObjectOptions{})
+	assert.Error(t, err, "chunks of evicted shares are deleted")
+
+	entries, err := env.queries.ListAuditLogByFileId(ctx, downloaded.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditActionFileEvicted, entries[0].Action)
+
+	e := receiveEvent(t, events)
+	assert.Equal(t, notify.EventFileEvicted, e.Type)
+	assert.Equal(t, downloaded.ShareID, e.ShareID)
+
+	stored, err := env.queries.GetStoredBytes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, longLived.TotalSize+expiringSoon.TotalSize+uploading.TotalSize, stored)
+}
+
+func TestReconcile_Integration(t *testing.T) {
+	env, cleanup := setupCleanupTestEnv(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Chunk 0 is stored and recorded, chunk 1 has no row and chunk 2 no object
+	file := testutil.CreateReadyFile(t, env.queries, ctx)
+	testutil.UploadTestChunks(t, env.minioClient, env.bucketName, file.ID.String(), 2)
+	for _, index := range []int32{0, 2} {
+		_, err := env.queries.CreateChunk(ctx, sqlc.CreateChunkParams{
+			FileID:        file.ID,
+			ChunkIndex:    index,
+			StoragePath:   chunkObjectName(file.ID, int64(index)),
+			EncryptedSize: 512,
+			ChunkHash:     fmt.Sprintf("hash-%d", index),
+		})
+		require.NoError(t, err)
+	}
+
+	// A purged file whose object was left behind
+	purged := testutil.CreateReadyFile(t, env.queries, ctx)
+	testutil.UploadTestChunks(t, env.minioClient, env.bucketName, purged.ID.String(), 1)
+	_, err := env.queries.CreateChunk(ctx, sqlc.CreateChunkParams{
+		FileID:        purged.ID,
+		StoragePath:   chunkObjectName(purged.ID, 0),
+		EncryptedSize: 512,
+		ChunkHash:     "hash-0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, env.queries.ExpireFilesByIds(ctx, []pgtype.UUID{purged.ID}))
+
+	_, err = env.minioClient.PutObject(ctx, env.bucketName, "uploads/report.pdf", bytes.NewReader([]byte("report")), 6, minio.PutObjectOptions{})
+	require.NoError(t, err)
+
+	// Objects within the grace period are spared, and nothing is removed
+	// unless configured
+	report, err := env.cleanupService.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ReconcileReport{MissingObjects: 1}, report)
+
+	env.cleanupService.orphanGrace = -time.Minute
+	env.cleanupService.WithReconciliation(config.Reconciliation{RemoveObjects: true, RemoveRows: true})
+	report, err = env.cleanupService.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ReconcileReport{OrphanedObjects: 2, MissingObjects: 1, RemovedObjects: 2, RemovedRows: 1}, report)
+
+	for name, kept := range map[string]bool{
+		chunkObjectName(file.ID, 0):   true,
+		chunkObjectName(file.ID, 1):   false,
+		chunkObjectName(purged.ID, 0): false,
+		"uploads/report.pdf":          true,
+	} {
+		_, err := env.minioClient.StatObject(ctx, env.bucketName, name, minio.StatObjectOptions{})
+		assert.Equal(t, kept, err == nil, name)
+	}
+
+	indexes, err := env.queries.ListChunkIndexesByFileId(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0}, indexes)
+	updated, err := env.queries.GetFileByID(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusCorrupt, updated.Status)
+}
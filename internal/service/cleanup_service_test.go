@@ -116,6 +116,23 @@ func TestDeleteFileChunks_GeneratesCorrectObjectNames(t *testing.T) {
 	assert.Equal(t, expectedObjects, generatedObjects)
 }
 
+func TestDeleteFileChunks_SingleDeletes(t *testing.T) {
+	fake, client := newFakeS3(t)
+	service := NewCleanupService(nil, client, "test-bucket").WithSingleDeletes()
+
+	fileID := createTestUUID()
+	for i := range 3 {
+		fake.objects[fmt.Sprintf("/test-bucket/%s/%d.enc", fileID, i)] = []byte("chunk")
+	}
+
+	err := service.deleteFileChunks(context.Background(), []sqlc.GetExpiredFilesRow{
+		{ID: fileID, ChunkCount: 3},
+	})
+
+	require.NoError(t, err)
+	assert.Empty(t, fake.objects)
+}
+
 func TestCollectExpiredFileIds(t *testing.T) {
 	expiredFiles := []sqlc.GetExpiredFilesRow{
 		{ID: testutil.ParseUUID(t, "550e8400-e29b-41d4-a716-446655440001"), ChunkCount: 5},
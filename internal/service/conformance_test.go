@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/pkg/e2ee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformanceVectors_Accepted checks that uploads built from the published
+// protocol vectors pass the server's init and chunk validation.
+func TestConformanceVectors_Accepted(t *testing.T) {
+	for _, v := range e2ee.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			sealed, err := base64.StdEncoding.DecodeString(v.Ciphertext)
+			require.NoError(t, err)
+
+			mockRepo := new(MockQuerier)
+			fileService := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+			chunkService := NewChunkService(mockRepo, nil, "test-bucket", config.DefaultLimits())
+
+			mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+				Return(sqlc.File{}, nil)
+
+			_, err = fileService.InitFileUpload(context.Background(), types.InitUploadRequest{
+				Salt:              v.Salt,
+				EncryptedFilename: v.Ciphertext,
+				EncryptedMimeType: v.Ciphertext,
+				TotalSize:         int64(len(sealed)),
+				ChunkCount:        1,
+				ChunkSize:         int32(len(sealed)),
+				Pbkdf2Iterations:  int32(v.Iterations),
+			}, "192.168.1.1")
+			require.NoError(t, err)
+
+			assert.NoError(t, chunkService.validateChunkHash(sealed, v.ChunkHash))
+		})
+	}
+}
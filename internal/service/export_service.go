@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// MaxExportTokens bounds how many deletion tokens one export may carry.
+const MaxExportTokens = 100
+
+var (
+	ErrNoExportTokens      = errors.New("no deletion tokens given")
+	ErrTooManyExportTokens = fmt.Errorf("at most %d deletion tokens per export", MaxExportTokens)
+)
+
+// ExportService lets uploaders export the metadata of their shares. Holding
+// a share's deletion token is the only proof of ownership, since there are
+// no accounts.
+type ExportService struct {
+	repository sqlc.Querier
+}
+
+func NewExportService(repository sqlc.Querier) *ExportService {
+	return &ExportService{
+		repository: repository,
+	}
+}
+
+// ExportShares returns the shares owned by the given deletion tokens with
+// their download history. Tokens matching no share are counted but not
+// reported individually.
+func (s *ExportService) ExportShares(ctx context.Context, deletionTokens []string) (types.ShareExportResponse, error) {
+	tokens := uniqueTokens(deletionTokens)
+	if len(tokens) == 0 {
+		return types.ShareExportResponse{}, ErrNoExportTokens
+	}
+	if len(tokens) > MaxExportTokens {
+		return types.ShareExportResponse{}, ErrTooManyExportTokens
+	}
+
+	files, err := s.repository.ListFilesByDeletionTokens(ctx, tokens)
+	if err != nil {
+		return types.ShareExportResponse{}, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	fileIDs := make([]pgtype.UUID, len(files))
+	for i, file := range files {
+		fileIDs[i] = file.ID
+	}
+
+	downloads := make(map[pgtype.UUID][]string, len(files))
+	if len(files) > 0 {
+		events, err := s.repository.ListAuditLogByFileIdsAndAction(ctx, sqlc.ListAuditLogByFileIdsAndActionParams{
+			Column1: fileIDs,
+			Action:  AuditActionDownloadCompleted,
+		})
+		if err != nil {
+			return types.ShareExportResponse{}, fmt.Errorf("failed to list download events: %w", err)
+		}
+		for _, event := range events {
+			downloads[event.FileID] = append(downloads[event.FileID], formatTimestamptz(event.CreatedAt))
+		}
+	}
+
+	matched := make(map[string]bool, len(files))
+	shares := make([]types.ShareExport, len(files))
+	for i, file := range files {
+		matched[file.DeletionTokenHash.String] = true
+
+		events := downloads[file.ID]
+		if events == nil {
+			events = []string{}
+		}
+		shares[i] = types.ShareExport{
+			ShareID:          file.ShareID,
+			Status:           file.Status,
+			TotalSize:        file.TotalSize,
+			CreatedAt:        formatTimestamptz(file.CreatedAt),
+			ExpiresAt:        formatTimestamptz(file.ExpiresAt),
+			MaxDownloads:     file.MaxDownloads,
+			DownloadCount:    file.DownloadCount,
+			LastDownloadedAt: formatTimestamptz(file.LastDownloadedAt),
+			Downloads:        events,
+		}
+	}
+
+	slog.Info("shares exported",
+		slog.Int("tokens", len(tokens)),
+		slog.Int("shares", len(shares)),
+	)
+
+	return types.ShareExportResponse{
+		ExportedAt: formatTime(time.Now()),
+		Shares:     shares,
+		Unmatched:  len(tokens) - len(matched),
+	}, nil
+}
+
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	unique := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		unique = append(unique, token)
+	}
+	return unique
+}
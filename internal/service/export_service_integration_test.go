@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportShares_Integration_IncludesDownloads(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	exportService := NewExportService(queries)
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	for range 2 {
+		err := fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
+		require.NoError(t, err)
+	}
+
+	// Test files are all created with the same deletion token
+	export, err := exportService.ExportShares(ctx, []string{"deletion-token", "other-token"})
+
+	require.NoError(t, err)
+	require.Len(t, export.Shares, 1)
+	assert.Equal(t, file.ShareID, export.Shares[0].ShareID)
+	assert.Equal(t, int32(2), export.Shares[0].DownloadCount)
+	assert.Len(t, export.Shares[0].Downloads, 2)
+	assert.Equal(t, 1, export.Unmatched)
+}
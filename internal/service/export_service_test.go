@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportShares_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewExportService(mockRepo)
+	ctx := context.Background()
+
+	fileID := createTestUUID()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	downloaded := created.Add(time.Hour)
+
+	mockRepo.On("ListFilesByDeletionTokens", ctx, []string{"token-a", "token-b"}).
+		Return([]sqlc.File{{
+			ID:                fileID,
+			ShareID:           "abc123def456",
+			Status:            "ready",
+			TotalSize:         1024,
+			CreatedAt:         pgtype.Timestamptz{Time: created, Valid: true},
+			MaxDownloads:      5,
+			DownloadCount:     1,
+			LastDownloadedAt:  pgtype.Timestamptz{Time: downloaded, Valid: true},
+			DeletionTokenHash: pgtype.Text{String: "token-a", Valid: true},
+			AdminNotes:        pgtype.Text{String: "internal", Valid: true},
+		}}, nil)
+	mockRepo.On("ListAuditLogByFileIdsAndAction", ctx, sqlc.ListAuditLogByFileIdsAndActionParams{
+		Column1: []pgtype.UUID{fileID},
+		Action:  AuditActionDownloadCompleted,
+	}).Return([]sqlc.AuditLog{{
+		FileID:    fileID,
+		Action:    AuditActionDownloadCompleted,
+		CreatedAt: pgtype.Timestamptz{Time: downloaded, Valid: true},
+	}}, nil)
+
+	export, err := service.ExportShares(ctx, []string{"token-a", "", "token-b", "token-a"})
+
+	require.NoError(t, err)
+	require.Len(t, export.Shares, 1)
+	share := export.Shares[0]
+	assert.Equal(t, "abc123def456", share.ShareID)
+	assert.Equal(t, "2026-01-02T03:04:05Z", share.CreatedAt)
+	assert.Empty(t, share.ExpiresAt)
+	assert.Equal(t, []string{"2026-01-02T04:04:05Z"}, share.Downloads)
+	assert.Equal(t, 1, export.Unmatched)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportShares_NoMatches(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewExportService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("ListFilesByDeletionTokens", ctx, []string{"unknown"}).
+		Return([]sqlc.File{}, nil)
+
+	export, err := service.ExportShares(ctx, []string{"unknown"})
+
+	require.NoError(t, err)
+	assert.Empty(t, export.Shares)
+	assert.Equal(t, 1, export.Unmatched)
+	mockRepo.AssertNotCalled(t, "ListAuditLogByFileIdsAndAction", mock.Anything, mock.Anything)
+}
+
+func TestExportShares_TokenLimits(t *testing.T) {
+	service := NewExportService(nil)
+
+	_, err := service.ExportShares(context.Background(), []string{"", ""})
+	assert.ErrorIs(t, err, ErrNoExportTokens)
+
+	tokens := make([]string, MaxExportTokens+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+	_, err = service.ExportShares(context.Background(), tokens)
+	assert.ErrorIs(t, err, ErrTooManyExportTokens)
+}
@@ -2,41 +2,208 @@ package service
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/subtle"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
-	"math/big"
+	"math"
 	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ilkin0/gzln/internal/abuse"
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
 	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/idgen"
+	"github.com/ilkin0/gzln/internal/notify"
+	"github.com/ilkin0/gzln/internal/publish"
+	"github.com/ilkin0/gzln/internal/readonly"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
 )
 
 var (
 	ErrNotFound             = errors.New("file not found")
+	ErrRevoked              = errors.New("share was revoked by its uploader")
 	ErrNotReady             = errors.New("file not ready")
 	ErrExpired              = errors.New("file expired")
 	ErrDownloadLimitReached = errors.New("download limit reached")
+	ErrInvalidUploadToken   = errors.New("invalid upload token")
+	ErrInvalidUploadSize    = errors.New("invalid upload size")
+	ErrInvalidDownloadNonce = errors.New("invalid download nonce")
+	ErrDownloadIncomplete   = errors.New("not every chunk was downloaded")
+	ErrPresignedDisabled    = errors.New("presigned uploads are not enabled")
+	ErrUploadDenied         = errors.New("upload denied")
+	ErrUploadChallenged     = errors.New("upload requires a challenge")
+	ErrWebhooksDisabled     = errors.New("webhooks are not enabled")
+	ErrServerEncryptionOff  = errors.New("server-side encryption is not enabled")
+	ErrUploadSlotsDisabled  = errors.New("upload slots are not enabled")
+	ErrInvalidAPIKey        = errors.New("invalid API key")
+	ErrInvalidUploadSlot    = errors.New("upload slot is invalid, used, expired or too small")
+	ErrInvalidSlotRequest   = errors.New("invalid upload slot request")
+	ErrInvalidBundleRequest = errors.New("invalid bundle request")
+	ErrInvalidBundleToken   = errors.New("invalid or expired bundle token")
+	ErrBundleFull           = fmt.Errorf("bundles hold at most %d files", MaxBundleFiles)
+	ErrStorageFull          = errors.New("storage quota exceeded")
+	ErrNotUploading         = errors.New("not in uploading state")
+	ErrChunkCountMismatch   = errors.New("chunk count does not match file chunk count")
+	ErrInvalidLinkRequest   = errors.New("invalid share link request")
+	ErrTooManyShareLinks    = fmt.Errorf("shares have at most %d links", MaxShareLinks)
+	ErrShareLinkNotFound    = errors.New("share link not found")
+	ErrInvalidReturnURL     = errors.New("invalid return_url")
+	ErrInvalidAlias         = errors.New("invalid alias")
+	ErrAliasTaken           = errors.New("alias is already taken")
+	ErrShareIDExhausted     = errors.New("no free share ID found")
+	ErrUnfinishedUploads    = errors.New("too many unfinished uploads")
+	ErrTooManyActiveShares  = errors.New("too many active shares")
 )
 
+// DownloadNonceTTL bounds how long a download may take between fetching the
+// metadata and serving its last chunk.
+const DownloadNonceTTL = 6 * time.Hour
+
+// AuditActionDownloadCompleted is logged for every completed download, so the
+// history outlives the download nonces.
+const AuditActionDownloadCompleted = "download.completed"
+
+// AuditActionFileReady is the lifecycle event of a finalized upload.
+const AuditActionFileReady = "file.ready"
+
+// MaxBundleFiles bounds how many files one bundle can hold.
+const MaxBundleFiles = 100
+
+// AuditActionFileRevoked records an uploader revoking their share.
+const AuditActionFileRevoked = "file.revoked"
+
+// AuditActionLinkCreated and AuditActionLinkRevoked record the recipient
+// links of a share.
+const (
+	AuditActionLinkCreated = "link.created"
+	AuditActionLinkRevoked = "link.revoked"
+)
+
+// Share link limits. Link IDs are longer than share IDs, so the two are
+// unlikely to meet.
+const (
+	ShareLinkIDLength       = 16
+	MaxShareLinks           = 100
+	MaxShareLinkDownloads   = 100
+	MaxShareLinkLabelLength = 100
+)
+
+// Share link states.
+const (
+	LinkStatusActive    = "active"
+	LinkStatusRevoked   = "revoked"
+	LinkStatusExpired   = "expired"
+	LinkStatusExhausted = "exhausted"
+)
+
+// MaxReturnURLLength bounds the return URL an uploader sets on a share.
+const MaxReturnURLLength = 2048
+
+// Alias limits. Aliases are lowercase, so two of them never differ by case
+// alone.
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 32
+)
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+// IsAlias reports whether id has the shape of an alias. Generated share IDs
+// may have it too, and take precedence when they do.
+func IsAlias(id string) bool {
+	return len(id) >= MinAliasLength && len(id) <= MaxAliasLength && aliasPattern.MatchString(id)
+}
+
+// maxShareIDAttempts bounds how many share IDs an upload init tries before
+// giving up, should the generated ones already be in use.
+const maxShareIDAttempts = 5
+
+// busyActiveUploads is the number of uploads in progress at which upload
+// advice switches to fewer, larger requests.
+const busyActiveUploads = 20
+
 type FileService struct {
 	repository  sqlc.Querier
 	minioClient *minio.Client
 	runTx       database.TxRunner
+	shareIDGen  idgen.Generator
+	limits      config.Limits
+	transfer    config.Transfer
+	presigner   ChunkPresigner
+	verifier    ChunkVerifier
+	rehash      bool
+	abuse       abuse.Scorer
+	publisher   *publish.Hook
+	notifier    *notify.Notifier
+	uploadSlots config.UploadSlots
+	quotaBytes  int64
+	reclaimer   SpaceReclaimer
+	// reclaiming is set while a reclaim started by a full quota runs
+	reclaiming  atomic.Bool
+	uploadQuota config.UploadQuota
+	events      *events.Bus
+	flags       *flags.Provider
+	sealer      *crypto.Sealer
+	// cleanupInterval bounds how long content outlives its share
+	cleanupInterval time.Duration
+	// asyncVerifyChunks is the chunk count from which rehashing runs in
+	// the background. Zero never does.
+	asyncVerifyChunks int32
+	// verifications counts the background checks running
+	verifications atomic.Int64
+	// returnURLSchemes are the schemes a share's return URL may use
+	returnURLSchemes []string
+	readOnly         *readonly.Switch
+	uploadSquatting  config.UploadSquatting
+	timeouts         config.OperationTimeouts
+	sessions         *SessionService
+}
+
+// ChunkPresigner issues forms that upload a batch of a file's chunks, from
+// chunk first on, straight to object storage.
+type ChunkPresigner interface {
+	PresignChunkUploads(ctx context.Context, file sqlc.File, first int32) ([]types.PresignedChunkUpload, time.Time, error)
+}
+
+// ChunkVerifier checks a file's chunk rows against the objects in storage,
+// failing with ErrChunkMismatch for any that differ. Sizes are always
+// compared; rehash also reads every object back. A non-nil progress is
+// called with the number of chunks checked so far.
+type ChunkVerifier interface {
+	VerifyChunks(ctx context.Context, fileID pgtype.UUID, rehash bool, progress func(verified int)) error
+}
+
+// SpaceReclaimer evicts stored shares that can no longer be downloaded to
+// free at least bytes, returning how much it freed.
+type SpaceReclaimer interface {
+	ReclaimSpace(ctx context.Context, bytes int64) (int64, error)
 }
 
-func NewFileService(repository sqlc.Querier, runTx database.TxRunner, minioClient *minio.Client) *FileService {
+func NewFileService(repository sqlc.Querier, runTx database.TxRunner, minioClient *minio.Client, limits config.Limits) *FileService {
 	return &FileService{
 		repository:  repository,
 		runTx:       runTx,
 		minioClient: minioClient,
+		shareIDGen:  idgen.Alphanumeric{Length: idgen.DefaultLength},
+		limits:      limits,
+		transfer:    config.DefaultTransfer(),
+
+		cleanupInterval:  config.DefaultCleanupInterval(),
+		returnURLSchemes: config.DefaultReturnURLSchemes,
 	}
 }
 
@@ -44,19 +211,162 @@ func (s *FileService) GetMinIOClient() *minio.Client {
 	return s.minioClient
 }
 
-func generateShareID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	length := 12
-	b := make([]byte, length)
+// WithShareIDGenerator replaces the default alphanumeric share ID generator.
+func (s *FileService) WithShareIDGenerator(g idgen.Generator) *FileService {
+	s.shareIDGen = g
+	return s
+}
 
-	for i := range b {
-		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-		b[i] = charset[n.Int64()]
-	}
-	return string(b)
+// WithTransfer replaces the parallelism hints advertised to clients.
+func (s *FileService) WithTransfer(t config.Transfer) *FileService {
+	s.transfer = t
+	return s
+}
+
+// WithServerEncryption lets clients without E2EE upload plaintext chunks,
+// which the ChunkService seals with s at rest.
+func (s *FileService) WithServerEncryption(sealer *crypto.Sealer) *FileService {
+	s.sealer = sealer
+	return s
+}
+
+// WithChunkPresigner lets clients request presigned chunk upload URLs from
+// InitFileUpload.
+func (s *FileService) WithChunkPresigner(p ChunkPresigner) *FileService {
+	s.presigner = p
+	return s
+}
+
+// WithChunkVerifier checks stored chunks with v before FinalizeUpload marks a
+// file ready. Files whose chunks do not match are marked corrupt instead.
+func (s *FileService) WithChunkVerifier(v ChunkVerifier, rehash bool) *FileService {
+	s.verifier = v
+	s.rehash = rehash
+	return s
+}
+
+// WithAsyncVerification rehashes the chunks of files with at least minChunks
+// in the background: FinalizeUpload leaves them verifying and answers at
+// once, and watchers of the share see the progress. Zero keeps every check
+// inside FinalizeUpload.
+func (s *FileService) WithAsyncVerification(minChunks int32) *FileService {
+	s.asyncVerifyChunks = minChunks
+	return s
+}
+
+// WithAbuseScorer screens upload inits with sc before any file record is
+// created.
+func (s *FileService) WithAbuseScorer(sc abuse.Scorer) *FileService {
+	s.abuse = sc
+	return s
+}
+
+// WithPublishHook announces every share that becomes ready to h. A nil hook
+// disables publishing.
+func (s *FileService) WithPublishHook(h *publish.Hook) *FileService {
+	s.publisher = h
+	return s
+}
+
+// WithNotifier lets uploaders register a webhook for their file in
+// InitFileUpload.
+func (s *FileService) WithNotifier(n *notify.Notifier) *FileService {
+	s.notifier = n
+	return s
+}
+
+// WithUploadSlots lets backends holding one of slots.APIKeys reserve uploads
+// with CreateUploadSlot.
+func (s *FileService) WithUploadSlots(slots config.UploadSlots) *FileService {
+	s.uploadSlots = slots
+	return s
+}
+
+// WithStorageQuota rejects uploads that would take the stored shares past
+// quotaBytes. A non-nil r is then asked in the background to evict shares
+// that can no longer be downloaded, so later uploads fit; the rejected one
+// never waits for it.
+func (s *FileService) WithStorageQuota(quotaBytes int64, r SpaceReclaimer) *FileService {
+	s.quotaBytes = quotaBytes
+	s.reclaimer = r
+	return s
+}
+
+// WithUploadQuota limits how many uploads, and how many bytes, each uploader
+// IP may start per UTC day.
+func (s *FileService) WithUploadQuota(q config.UploadQuota) *FileService {
+	s.uploadQuota = q
+	return s
+}
+
+// WithUploadSquatting flags IPs that leave many uploads unfinished and
+// restricts their upload inits while they are flagged.
+func (s *FileService) WithUploadSquatting(sq config.UploadSquatting) *FileService {
+	s.uploadSquatting = sq
+	return s
+}
+
+// WithEvents publishes counted downloads to bus and lets recipients watch
+// shares with WatchShare.
+func (s *FileService) WithEvents(bus *events.Bus) *FileService {
+	s.events = bus
+	return s
+}
+
+// WithFlags lets feature flags switch off presigned uploads and quota
+// eviction at runtime.
+func (s *FileService) WithFlags(p *flags.Provider) *FileService {
+	s.flags = p
+	return s
+}
+
+// WithCleanupInterval tells recipients how long the content of an ended
+// share may be kept before cleanup deletes it.
+func (s *FileService) WithCleanupInterval(d time.Duration) *FileService {
+	s.cleanupInterval = d
+	return s
+}
+
+// WithReturnURLSchemes replaces the URL schemes uploaders may use for the
+// return URL of a share.
+func (s *FileService) WithReturnURLSchemes(schemes []string) *FileService {
+	s.returnURLSchemes = schemes
+	return s
+}
+
+// WithTimeouts bounds share lookups, upload inits and finalizes, so they
+// fail with ErrOperationTimeout instead of waiting on a stuck database or
+// storage call for as long as the client does.
+func (s *FileService) WithTimeouts(t config.OperationTimeouts) *FileService {
+	s.timeouts = t
+	return s
+}
+
+// WithManagementSessions lets a live management session token from sessions
+// stand in for a share's deletion token.
+func (s *FileService) WithManagementSessions(sessions *SessionService) *FileService {
+	s.sessions = sessions
+	return s
+}
+
+// WithReadOnly stops issuing download nonces while sw is on, so downloads
+// need no database writes and are not counted.
+func (s *FileService) WithReadOnly(sw *readonly.Switch) *FileService {
+	s.readOnly = sw
+	return s
+}
+
+func (s *FileService) Transfer() config.Transfer {
+	return s.transfer
 }
 
 func (s *FileService) InitFileUpload(ctx context.Context, req types.InitUploadRequest, clientIPStr string) (*types.InitUploadResponse, error) {
+	return withTimeout(ctx, s.timeouts.Init, func(ctx context.Context) (*types.InitUploadResponse, error) {
+		return s.initFileUpload(ctx, req, clientIPStr)
+	})
+}
+
+func (s *FileService) initFileUpload(ctx context.Context, req types.InitUploadRequest, clientIPStr string) (*types.InitUploadResponse, error) {
 	slog.Debug("validating upload request",
 		slog.Int64("total_size", req.TotalSize),
 		slog.Int("chunk_count", int(req.ChunkCount)),
@@ -71,21 +381,61 @@ func (s *FileService) InitFileUpload(ctx context.Context, req types.InitUploadRe
 		return nil, err
 	}
 
-	shareID := generateShareID()
+	if req.SlotToken != "" {
+		slot, err := s.lookupUploadSlot(ctx, req.SlotToken, req.TotalSize)
+		if err != nil {
+			return nil, err
+		}
+		req.ExpiresInHours = int(slot.ExpiresInHours)
+		req.MaxDownloads = slot.MaxDownloads
+	}
+
+	var bundle *sqlc.FileBundle
+	if req.BundleToken != "" {
+		b, err := s.openBundle(ctx, req.BundleToken)
+		if err != nil {
+			return nil, err
+		}
+		bundle = &b
+	}
+
+	if req.Alias != "" {
+		taken, err := s.repository.ShareNameTaken(ctx, req.Alias)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check alias: %w", err)
+		}
+		if taken {
+			return nil, ErrAliasTaken
+		}
+	}
+
+	shareID, err := s.shareIDGen.Generate()
+	if err != nil {
+		slog.Error("failed to generate share ID",
+			slog.String("error", err.Error()),
+			slog.String("strategy", s.shareIDGen.Name()),
+		)
+		return nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
 	uploadToken := uuid.New().String()
 
 	maxDownloads := req.MaxDownloads
 	if maxDownloads == 0 {
-		maxDownloads = 5 // TODO make it configurable
+		maxDownloads = s.limits.DefaultMaxDownloads
+	}
+
+	expiresIn := time.Duration(req.ExpiresInHours) * time.Hour
+	if expiresIn == 0 {
+		expiresIn = s.limits.DefaultExpiry
 	}
 
-	expiresInHours := req.ExpiresInHours
-	if expiresInHours == 0 {
-		expiresInHours = 72 // TODO make it configurable
+	expiresAt := time.Now().Add(expiresIn)
+	if bundle != nil {
+		expiresAt = bundle.ExpiresAt.Time
+		expiresIn = time.Until(expiresAt)
 	}
 
-	expiresAt := time.Now().Add(time.Duration(expiresInHours) * time.Hour)
-	clientIP, err := netip.ParseAddr(clientIPStr)
+	clientIP, err := parseClientIP(clientIPStr)
 	if err != nil {
 		slog.Warn("invalid client IP, using default",
 			slog.String("provided_ip", clientIPStr),
@@ -94,12 +444,39 @@ func (s *FileService) InitFileUpload(ctx context.Context, req types.InitUploadRe
 		clientIP = netip.MustParseAddr("127.0.0.1")
 	}
 
+	if err := s.checkUploadQuota(ctx, clientIP, req.TotalSize); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkActiveShares(ctx, clientIP); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUploadSquatting(ctx, clientIP); err != nil {
+		return nil, err
+	}
+
+	if err := s.screenUpload(ctx, abuse.Request{
+		ClientIP:     clientIP,
+		TotalSize:    req.TotalSize,
+		ChunkCount:   req.ChunkCount,
+		ExpiresIn:    expiresIn,
+		MaxDownloads: maxDownloads,
+		Metadata:     abuse.MetadataFromContext(ctx),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.reserveStorage(ctx, req.TotalSize); err != nil {
+		return nil, err
+	}
+
 	slog.Info("creating file upload record",
 		slog.String("share_id", shareID),
 		slog.Int64("total_size", req.TotalSize),
 		slog.Int("chunk_count", int(req.ChunkCount)),
 		slog.Int("max_downloads", int(maxDownloads)),
-		slog.Int("expires_in_hours", expiresInHours),
+		slog.Duration("expires_in", expiresIn),
 	)
 
 	params := sqlc.CreateFileParams{
@@ -121,93 +498,1342 @@ func (s *FileService) InitFileUpload(ctx context.Context, req types.InitUploadRe
 			Valid:  true,
 		},
 		UploaderIp: clientIP,
+		ReturnUrl:  pgtype.Text{String: req.ReturnURL, Valid: req.ReturnURL != ""},
+		Alias:      pgtype.Text{String: req.Alias, Valid: req.Alias != ""},
+	}
+
+	// Everything init records about the file commits together, and the slot
+	// is only used up once every other step has succeeded
+	var createdFile sqlc.File
+	var webhookSecret string
+	create := func(q sqlc.Querier) error {
+		file, err := q.CreateFile(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create file record: %w", err)
+		}
+		if req.Encryption == types.EncryptionServer {
+			err := q.CreateServerEncryptedFile(ctx, sqlc.CreateServerEncryptedFileParams{
+				FileID: file.ID,
+				KeyID:  s.sealer.KeyID(),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to flag file for server encryption: %w", err)
+			}
+		}
+		if bundle != nil {
+			err := q.AddBundleFile(ctx, sqlc.AddBundleFileParams{
+				FileID:   file.ID,
+				BundleID: bundle.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to add file to bundle: %w", err)
+			}
+		}
+		if req.WebhookURL != "" {
+			webhookSecret, err = s.registerWebhook(ctx, q, file.ID, req.WebhookURL)
+			if err != nil {
+				return err
+			}
+		}
+		if req.SlotToken != "" {
+			if err := s.redeemUploadSlot(ctx, q, req.SlotToken, req.TotalSize); err != nil {
+				return err
+			}
+		}
+		createdFile = file
+		return nil
+	}
+
+	err = s.runTx(ctx, create)
+	for attempt := 1; isShareIDViolation(err) && attempt < maxShareIDAttempts; attempt++ {
+		idgen.RecordCollision()
+		idgen.RecordRetry()
+		slog.Warn("share ID collision, generating another",
+			slog.String("share_id", shareID),
+			slog.String("strategy", s.shareIDGen.Name()),
+			slog.Int("attempt", attempt),
+		)
+		shareID, err = s.shareIDGen.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate share ID: %w", err)
+		}
+		params.ShareID = shareID
+		err = s.runTx(ctx, create)
+	}
+	if err != nil {
+		if isAliasViolation(err) {
+			return nil, ErrAliasTaken
+		}
+		if isShareIDViolation(err) {
+			idgen.RecordCollision()
+			slog.Error("no free share ID found",
+				slog.String("strategy", s.shareIDGen.Name()),
+				slog.Int("attempts", maxShareIDAttempts),
+			)
+			return nil, ErrShareIDExhausted
+		}
+		if errors.Is(err, ErrInvalidUploadSlot) {
+			return nil, err
+		}
+		slog.Error("failed to create file record",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return nil, err
+	}
+
+	response := &types.InitUploadResponse{
+		FileID:            createdFile.ID.String(),
+		ShareID:           shareID,
+		UploadToken:       uploadToken,
+		ExpiresAt:         formatTime(expiresAt),
+		UploadConcurrency: s.transfer.UploadConcurrency,
+		Alias:             req.Alias,
+		WebhookSecret:     webhookSecret,
+	}
+	if req.UploadMode == types.UploadModePresigned {
+		// Signed after commit so storage is never waited on inside the
+		// transaction. A failure only leaves the client to fetch this batch
+		// like the later ones.
+		uploadURLs, expireAt, err := s.presigner.PresignChunkUploads(ctx, createdFile, 0)
+		if err != nil {
+			slog.Warn("failed to presign chunk uploads",
+				slog.String("error", err.Error()),
+				slog.String("share_id", shareID),
+			)
+		} else {
+			response.UploadURLs = uploadURLs
+			response.UploadURLsExpireAt = formatTime(expireAt)
+		}
+	}
+
+	slog.Info("file upload initialized successfully",
+		slog.String("share_id", shareID),
+		slog.String("file_id", createdFile.ID.String()),
+		slog.String("expires_at", expiresAt.Format(time.RFC3339)),
+		slog.String("upload_mode", req.UploadMode),
+	)
+
+	return response, nil
+}
+
+// CreateUploadSlot reserves a single upload for a browser on behalf of the
+// backend authenticated by apiKey. Only the returned token is needed to
+// start the upload, and it cannot be used for anything else.
+func (s *FileService) CreateUploadSlot(ctx context.Context, apiKey string, req types.CreateUploadSlotRequest) (*types.UploadSlotResponse, error) {
+	if len(s.uploadSlots.APIKeys) == 0 {
+		return nil, ErrUploadSlotsDisabled
+	}
+	if !s.validAPIKey(apiKey) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if req.MaxSize <= 0 {
+		return nil, fmt.Errorf("%w: max_size must be positive", ErrInvalidSlotRequest)
+	}
+	if req.MaxSize > s.limits.MaxFileSize {
+		return nil, fmt.Errorf("%w: max_size exceeds maximum allowed (%d bytes)", ErrInvalidSlotRequest, s.limits.MaxFileSize)
+	}
+	if req.ExpiresInHours < 0 {
+		return nil, fmt.Errorf("%w: expires_in_hours must not be negative", ErrInvalidSlotRequest)
+	}
+	if req.MaxDownloads < 0 && req.MaxDownloads != config.UnlimitedDownloads {
+		return nil, fmt.Errorf("%w: max_downloads must be positive, 0 for the default or %d for unlimited",
+			ErrInvalidSlotRequest, config.UnlimitedDownloads)
+	}
+
+	token := uuid.New().String()
+	expiresAt := time.Now().Add(s.uploadSlots.TTL)
+
+	err := s.repository.CreateUploadSlot(ctx, sqlc.CreateUploadSlotParams{
+		TokenHash:      crypto.HashBytes([]byte(token)),
+		MaxSize:        req.MaxSize,
+		ExpiresInHours: int32(req.ExpiresInHours),
+		MaxDownloads:   req.MaxDownloads,
+		ExpiresAt:      pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload slot: %w", err)
+	}
+
+	slog.Info("upload slot created",
+		slog.Int64("max_size", req.MaxSize),
+		slog.String("expires_at", expiresAt.Format(time.RFC3339)),
+	)
+
+	return &types.UploadSlotResponse{
+		SlotToken: token,
+		MaxSize:   req.MaxSize,
+		ExpiresAt: formatTime(expiresAt),
+	}, nil
+}
+
+func (s *FileService) validAPIKey(apiKey string) bool {
+	valid := 0
+	for _, key := range s.uploadSlots.APIKeys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), []byte(apiKey))
+	}
+	return valid == 1
+}
+
+// lookupUploadSlot returns the slot behind token if it can take a file of
+// totalSize bytes. A slot that is too small is left for a retry with a
+// smaller file.
+func (s *FileService) lookupUploadSlot(ctx context.Context, token string, totalSize int64) (sqlc.UploadSlot, error) {
+	if len(s.uploadSlots.APIKeys) == 0 {
+		return sqlc.UploadSlot{}, ErrUploadSlotsDisabled
+	}
+
+	slot, err := s.repository.GetUploadSlot(ctx, sqlc.GetUploadSlotParams{
+		TokenHash: crypto.HashBytes([]byte(token)),
+		MaxSize:   totalSize,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			slog.Warn("upload slot rejected",
+				slog.Int64("total_size", totalSize),
+			)
+			return sqlc.UploadSlot{}, ErrInvalidUploadSlot
+		}
+		return sqlc.UploadSlot{}, fmt.Errorf("failed to look up upload slot: %w", err)
+	}
+	return slot, nil
+}
+
+// redeemUploadSlot uses up the slot behind token within the transaction
+// creating the file, failing if a concurrent upload redeemed it first.
+func (s *FileService) redeemUploadSlot(ctx context.Context, q sqlc.Querier, token string, totalSize int64) error {
+	_, err := q.ConsumeUploadSlot(ctx, sqlc.ConsumeUploadSlotParams{
+		TokenHash: crypto.HashBytes([]byte(token)),
+		MaxSize:   totalSize,
+	})
+	if err != nil {
+		if errors.Is(database.Classify(err), database.ErrNotFound) {
+			slog.Warn("upload slot redeemed concurrently",
+				slog.Int64("total_size", totalSize),
+			)
+			return ErrInvalidUploadSlot
+		}
+		return fmt.Errorf("failed to redeem upload slot: %w", err)
+	}
+	return nil
+}
+
+// CreateBundle starts an empty bundle. Files join it through upload init with
+// the returned bundle token and are listed by GetBundleManifest once ready.
+func (s *FileService) CreateBundle(ctx context.Context, req types.CreateBundleRequest) (*types.CreateBundleResponse, error) {
+	if req.ExpiresInHours < 0 {
+		return nil, fmt.Errorf("%w: expires_in_hours must not be negative", ErrInvalidBundleRequest)
+	}
+
+	expiresIn := time.Duration(req.ExpiresInHours) * time.Hour
+	if expiresIn == 0 {
+		expiresIn = s.limits.DefaultExpiry
+	}
+	expiresAt := time.Now().Add(expiresIn)
+
+	shareID, err := s.shareIDGen.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
+	token := uuid.New().String()
+
+	bundle, err := s.repository.CreateFileBundle(ctx, sqlc.CreateFileBundleParams{
+		ShareID:   shareID,
+		TokenHash: crypto.HashBytes([]byte(token)),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		slog.Error("failed to create bundle",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	slog.Info("bundle created",
+		slog.String("share_id", bundle.ShareID),
+		slog.String("expires_at", expiresAt.Format(time.RFC3339)),
+	)
+
+	return &types.CreateBundleResponse{
+		ShareID:     bundle.ShareID,
+		BundleToken: token,
+		ExpiresAt:   formatTimestamptz(bundle.ExpiresAt),
+	}, nil
+}
+
+// checkUploadQuota rejects an upload that would take clientIP past its daily
+// upload count or bytes. Like the storage quota it is soft: concurrent inits
+// from one IP may overshoot it.
+func (s *FileService) checkUploadQuota(ctx context.Context, clientIP netip.Addr, size int64) error {
+	q := s.uploadQuota
+	if q.DailyCount == 0 && q.DailyBytes == 0 {
+		return nil
+	}
+
+	usage, resetsAt, err := s.uploaderUsage(ctx, clientIP)
+	if err != nil {
+		return err
+	}
+
+	overCount := q.DailyCount > 0 && usage.UsedCount >= q.DailyCount
+	overBytes := q.DailyBytes > 0 && usage.UsedBytes+size > q.DailyBytes
+	if !overCount && !overBytes {
+		return nil
+	}
+
+	slog.Warn("upload rejected by daily upload quota",
+		slog.String("client_ip", clientIP.String()),
+		slog.Int64("used_count", usage.UsedCount),
+		slog.Int64("used_bytes", usage.UsedBytes),
+		slog.Int64("total_size", size),
+	)
+	usage.RequestSize = size
+	return &UploadQuotaError{
+		ResetsAt: resetsAt,
+		Details:  usage,
+	}
+}
+
+// checkActiveShares rejects an upload once clientIP has as many active shares
+// as the quota allows, so one uploader cannot fill the database with rows
+// that cost it nothing. It is soft like the daily quota. clientIP must come
+// from the connection or a trusted proxy, never from a client's header.
+func (s *FileService) checkActiveShares(ctx context.Context, clientIP netip.Addr) error {
+	limit := s.uploadQuota.ActiveShares
+	if limit == 0 {
+		return nil
+	}
+
+	active, err := s.repository.CountActiveSharesByUploader(ctx, clientIP)
+	if err != nil {
+		return fmt.Errorf("failed to count active shares: %w", err)
+	}
+	if active < limit {
+		return nil
+	}
+
+	slog.Warn("upload rejected by active share limit",
+		slog.String("client_ip", clientIP.String()),
+		slog.Int64("active_shares", active),
+		slog.Int64("limit", limit),
+	)
+	return ErrTooManyActiveShares
+}
+
+// squattersFlagged counts the IPs flagged for leaving uploads unfinished.
+var squattersFlagged = expvar.NewInt("upload_squatters_flagged")
+
+// checkUploadSquatting flags clientIP once it left too many uploads
+// unfinished, and applies the configured action while it is flagged. Flags
+// are stored in the database, so they outlive restarts and apply on every
+// instance. clientIP must not be taken from a client's header, or anyone
+// could get another address flagged.
+func (s *FileService) checkUploadSquatting(ctx context.Context, clientIP netip.Addr) error {
+	sq := s.uploadSquatting
+	if sq.Threshold == 0 {
+		return nil
+	}
+	now := time.Now()
+	windowStart := pgtype.Timestamptz{Time: now.Add(-sq.Window), Valid: true}
+
+	squatter, err := s.repository.GetUploadSquatter(ctx, clientIP)
+	if errors.Is(err, database.ErrNotFound) {
+		unfinished, err := s.repository.CountUnfinishedUploads(ctx, sqlc.CountUnfinishedUploadsParams{
+			UploaderIp: clientIP,
+			Since:      windowStart,
+			Before:     pgtype.Timestamptz{Time: now.Add(-sq.Grace), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to count unfinished uploads: %w", err)
+		}
+		if unfinished < sq.Threshold {
+			return nil
+		}
+
+		squatter, err = s.repository.FlagUploadSquatter(ctx, sqlc.FlagUploadSquatterParams{
+			Ip:                clientIP,
+			UnfinishedUploads: int32(unfinished),
+			FlaggedUntil:      pgtype.Timestamptz{Time: now.Add(sq.Penalty), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to flag upload squatter: %w", err)
+		}
+		squattersFlagged.Add(1)
+		slog.Warn("uploader IP flagged for unfinished uploads",
+			slog.String("client_ip", clientIP.String()),
+			slog.Int64("unfinished_uploads", unfinished),
+			slog.Time("flagged_until", squatter.FlaggedUntil.Time),
+		)
+	} else if err != nil {
+		return fmt.Errorf("failed to get upload squatter: %w", err)
+	}
+
+	if sq.Action == config.SquatActionChallenge {
+		return ErrUploadChallenged
+	}
+
+	pending, err := s.repository.CountUnfinishedUploads(ctx, sqlc.CountUnfinishedUploadsParams{
+		UploaderIp: clientIP,
+		Since:      windowStart,
+		Before:     pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count unfinished uploads: %w", err)
+	}
+	if pending > 0 {
+		slog.Warn("upload rejected while uploader IP is flagged",
+			slog.String("client_ip", clientIP.String()),
+			slog.Int64("unfinished_uploads", pending),
+			slog.Time("flagged_until", squatter.FlaggedUntil.Time),
+		)
+		return ErrUnfinishedUploads
+	}
+	return nil
+}
+
+// UploadQuotaUsage returns what clientIP has used of its daily upload quota,
+// or nil when no quota is enforced.
+func (s *FileService) UploadQuotaUsage(ctx context.Context, clientIPStr string) (*types.UploadQuotaDetails, error) {
+	if s.uploadQuota.DailyCount == 0 && s.uploadQuota.DailyBytes == 0 {
+		return nil, nil
+	}
+
+	clientIP, err := parseClientIP(clientIPStr)
+	if err != nil {
+		return nil, err
+	}
+	usage, _, err := s.uploaderUsage(ctx, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// uploaderUsage returns the uploads of clientIP since the start of the UTC
+// day against its quota, and when the quota resets.
+func (s *FileService) uploaderUsage(ctx context.Context, clientIP netip.Addr) (types.UploadQuotaDetails, time.Time, error) {
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	usage, err := s.repository.GetUploaderUsage(ctx, sqlc.GetUploaderUsageParams{
+		UploaderIp: clientIP,
+		Since:      pgtype.Timestamptz{Time: dayStart, Valid: true},
+	})
+	if err != nil {
+		return types.UploadQuotaDetails{}, time.Time{}, fmt.Errorf("failed to get uploader usage: %w", err)
+	}
+
+	resetsAt := dayStart.Add(24 * time.Hour)
+	return types.UploadQuotaDetails{
+		DailyCount: s.uploadQuota.DailyCount,
+		UsedCount:  usage.UploadCount,
+		DailyBytes: s.uploadQuota.DailyBytes,
+		UsedBytes:  usage.TotalBytes,
+		ResetsAt:   formatTime(resetsAt),
+	}, resetsAt, nil
+}
+
+// reserveStorage checks that size more bytes fit in the storage quota. An
+// upload init is anonymous, so one that does not fit is refused rather than
+// allowed to evict anyone's share; the reclaimer only frees space for later
+// uploads.
+func (s *FileService) reserveStorage(ctx context.Context, size int64) error {
+	if s.quotaBytes == 0 {
+		return nil
+	}
+	if size > s.quotaBytes {
+		return ErrStorageFull
+	}
+
+	stored, err := s.repository.GetStoredBytes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stored bytes: %w", err)
+	}
+	excess := stored + size - s.quotaBytes
+	if excess <= 0 {
+		return nil
+	}
+	slog.Warn("upload rejected by storage quota",
+		slog.Int64("stored_bytes", stored),
+		slog.Int64("total_size", size),
+	)
+	if s.reclaimer != nil && s.flags.Enabled(flags.QuotaEviction) {
+		s.reclaimInBackground(context.WithoutCancel(ctx), excess)
+	}
+	return ErrStorageFull
+}
+
+// reclaimInBackground asks the reclaimer to free bytes unless a reclaim is
+// already running.
+func (s *FileService) reclaimInBackground(ctx context.Context, bytes int64) {
+	if !s.reclaiming.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.reclaiming.Store(false)
+
+		freed, err := s.reclaimer.ReclaimSpace(ctx, bytes)
+		if err != nil {
+			slog.Error("failed to reclaim storage",
+				slog.String("error", err.Error()),
+				slog.Int64("needed_bytes", bytes),
+			)
+			return
+		}
+		slog.Info("storage reclaimed after a full quota",
+			slog.Int64("needed_bytes", bytes),
+			slog.Int64("freed_bytes", freed),
+		)
+	}()
+}
+
+// openBundle returns the unexpired bundle behind token if it has room for
+// another file.
+func (s *FileService) openBundle(ctx context.Context, token string) (sqlc.FileBundle, error) {
+	bundle, err := s.repository.GetFileBundleByTokenHash(ctx, crypto.HashBytes([]byte(token)))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return sqlc.FileBundle{}, ErrInvalidBundleToken
+		}
+		return sqlc.FileBundle{}, fmt.Errorf("failed to get bundle: %w", err)
+	}
+
+	count, err := s.repository.CountBundleFiles(ctx, bundle.ID)
+	if err != nil {
+		return sqlc.FileBundle{}, fmt.Errorf("failed to count bundle files: %w", err)
+	}
+	if count >= MaxBundleFiles {
+		return sqlc.FileBundle{}, ErrBundleFull
+	}
+	return bundle, nil
+}
+
+// GetBundleManifest lists the ready files of an unexpired bundle.
+func (s *FileService) GetBundleManifest(ctx context.Context, shareID string) (types.BundleManifestResponse, error) {
+	return bundleManifest(ctx, s.repository, shareID)
+}
+
+func bundleManifest(ctx context.Context, repository sqlc.Querier, shareID string) (types.BundleManifestResponse, error) {
+	bundle, err := repository.GetFileBundleByShareId(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.BundleManifestResponse{}, ErrNotFound
+		}
+		return types.BundleManifestResponse{}, fmt.Errorf("failed to get bundle: %w", err)
+	}
+
+	rows, err := repository.ListReadyBundleFiles(ctx, bundle.ID)
+	if err != nil {
+		return types.BundleManifestResponse{}, fmt.Errorf("failed to list bundle files: %w", err)
+	}
+
+	files := make([]types.BundleFile, len(rows))
+	for i, row := range rows {
+		files[i] = types.BundleFile{
+			ShareID:           row.ShareID,
+			EncryptedFilename: row.EncryptedFilename,
+			EncryptedMimeType: row.EncryptedMimeType,
+			TotalSize:         row.TotalSize,
+			ChunkCount:        row.ChunkCount,
+		}
+	}
+
+	return types.BundleManifestResponse{
+		ShareID:   bundle.ShareID,
+		ExpiresAt: formatTimestamptz(bundle.ExpiresAt),
+		Files:     files,
+	}, nil
+}
+
+// registerWebhook stores the uploader's webhook for fileID and returns the
+// secret its events are signed with.
+func (s *FileService) registerWebhook(ctx context.Context, q sqlc.Querier, fileID pgtype.UUID, url string) (string, error) {
+	secret, err := notify.NewSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	_, err = q.CreateFileWebhook(ctx, sqlc.CreateFileWebhookParams{
+		FileID: fileID,
+		Url:    url,
+		Secret: secret,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return secret, nil
+}
+
+// notifyWebhook sends events to the webhook registered for fileID, if any.
+func (s *FileService) notifyWebhook(ctx context.Context, fileID pgtype.UUID, events ...notify.Event) {
+	if s.notifier == nil {
+		return
+	}
+
+	webhook, err := s.repository.GetFileWebhookByFileId(ctx, fileID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			slog.Error("failed to get webhook",
+				slog.String("error", err.Error()),
+				slog.String("file_id", fileID.String()),
+			)
+		}
+		return
+	}
+
+	target := notify.Target{URL: webhook.Url, Secret: webhook.Secret}
+	for _, e := range events {
+		s.notifier.Notify(target, e)
+	}
+}
+
+// screenUpload runs the abuse scorer, if any, over an upload init. Scorer
+// failures let the upload through rather than blocking every upload.
+func (s *FileService) screenUpload(ctx context.Context, req abuse.Request) error {
+	if s.abuse == nil {
+		return nil
+	}
+
+	decision, err := s.abuse.Score(ctx, req)
+	if err != nil {
+		slog.Error("abuse scoring failed, allowing upload",
+			slog.String("error", err.Error()),
+			slog.String("client_ip", req.ClientIP.String()),
+		)
+		return nil
+	}
+	abuse.Record(decision)
+
+	switch decision.Verdict {
+	case abuse.Deny:
+		slog.Warn("upload denied by abuse scoring",
+			slog.String("client_ip", req.ClientIP.String()),
+			slog.Int("score", decision.Score),
+			slog.Any("reasons", decision.Reasons),
+		)
+		return ErrUploadDenied
+	case abuse.Challenge:
+		slog.Warn("upload challenged by abuse scoring",
+			slog.String("client_ip", req.ClientIP.String()),
+			slog.Int("score", decision.Score),
+			slog.Any("reasons", decision.Reasons),
+		)
+		return ErrUploadChallenged
+	}
+	return nil
+}
+
+// parseClientIP accepts a bare address or an address with a port as found
+// in RemoteAddr.
+func parseClientIP(s string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+func (s *FileService) validateUploadRequest(req types.InitUploadRequest) error {
+	switch req.Encryption {
+	case "", types.EncryptionClient:
+		if req.Salt == "" {
+			return fmt.Errorf("salt is required")
+		}
+		if req.Pbkdf2Iterations <= 0 {
+			return fmt.Errorf("pbkdf2_iterations must be positive")
+		}
+	case types.EncryptionServer:
+		if s.sealer == nil {
+			return ErrServerEncryptionOff
+		}
+		// Presigned chunks would reach storage without being sealed
+		if req.UploadMode == types.UploadModePresigned {
+			return fmt.Errorf("server encrypted uploads cannot use presigned upload_mode")
+		}
+	default:
+		return fmt.Errorf("unknown encryption %q", req.Encryption)
+	}
+	if req.EncryptedFilename == "" {
+		return fmt.Errorf("encrypted_filename is required")
+	}
+	if req.EncryptedMimeType == "" {
+		return fmt.Errorf("encrypted_mime_type is required")
+	}
+	if req.TotalSize <= 0 {
+		return fmt.Errorf("total_size must be positive")
+	}
+	if req.ChunkCount <= 0 {
+		return fmt.Errorf("chunk_count must be positive")
+	}
+	if req.ChunkSize <= 0 {
+		return fmt.Errorf("chunk_size must be positive")
+	}
+
+	// Validate chunk_count calculation to ensure data integrity and prevent
+	// malicious/buggy clients from sending incorrect values that could cause
+	// incomplete uploads or storage inconsistencies
+	expectedChunkCount := (req.TotalSize + int64(req.ChunkSize) - 1) / int64(req.ChunkSize)
+	if int64(req.ChunkCount) != expectedChunkCount {
+		return &ChunkLayoutError{
+			Reason:  fmt.Sprintf("chunk_count mismatch: expected %d, got %d", expectedChunkCount, req.ChunkCount),
+			Details: s.chunkLayout(req.TotalSize, req.ChunkSize),
+		}
+	}
+
+	lastChunkSize := req.TotalSize - (int64(req.ChunkCount-1) * int64(req.ChunkSize))
+	if lastChunkSize <= 0 || lastChunkSize > int64(req.ChunkSize) {
+		return &ChunkLayoutError{
+			Reason:  fmt.Sprintf("invalid last chunk size: %d", lastChunkSize),
+			Details: s.chunkLayout(req.TotalSize, req.ChunkSize),
+		}
+	}
+
+	if req.MaxDownloads < 0 && req.MaxDownloads != config.UnlimitedDownloads {
+		return fmt.Errorf("max_downloads must be positive, 0 for the default or %d for unlimited", config.UnlimitedDownloads)
+	}
+
+	if req.WebhookURL != "" {
+		if s.notifier == nil {
+			return ErrWebhooksDisabled
+		}
+		if err := s.notifier.ValidateURL(req.WebhookURL); err != nil {
+			return err
+		}
+	}
+
+	if req.ReturnURL != "" {
+		if err := s.validateReturnURL(req.ReturnURL); err != nil {
+			return err
+		}
+	}
+
+	if req.Alias != "" && !IsAlias(req.Alias) {
+		return fmt.Errorf("%w: must be %d to %d lowercase letters, digits or hyphens, starting and ending with a letter or digit",
+			ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+
+	switch req.UploadMode {
+	case "", types.UploadModeProxy:
+	case types.UploadModePresigned:
+		if s.presigner == nil || !s.flags.Enabled(flags.PresignedUploads) {
+			return ErrPresignedDisabled
+		}
+	default:
+		return fmt.Errorf("unknown upload_mode %q", req.UploadMode)
+	}
+
+	if req.TotalSize > s.limits.MaxFileSize {
+		return fmt.Errorf("file size %d exceeds maximum of %d bytes", req.TotalSize, s.limits.MaxFileSize)
+	}
+
+	if int64(req.ChunkSize) > s.limits.MaxChunkSize {
+		// Suggest the largest chunk size allowed instead
+		return &ChunkLayoutError{
+			Reason:  fmt.Sprintf("chunk_size %d exceeds maximum of %d bytes", req.ChunkSize, s.limits.MaxChunkSize),
+			Details: s.chunkLayout(req.TotalSize, int32(min(s.limits.MaxChunkSize, math.MaxInt32))),
+		}
+	}
+
+	if req.ChunkCount > s.limits.MaxChunkCount {
+		// Suggest the smallest chunk size that stays within the count
+		return &ChunkLayoutError{
+			Reason:  fmt.Sprintf("chunk_count %d exceeds maximum of %d", req.ChunkCount, s.limits.MaxChunkCount),
+			Details: s.chunkLayout(req.TotalSize, s.minChunkSize(req.TotalSize)),
+		}
+	}
+
+	return nil
+}
+
+// ChunkLayoutError rejects an upload whose chunk layout does not add up. It
+// carries a layout that would be accepted so clients can retry with it.
+type ChunkLayoutError struct {
+	Reason  string
+	Details types.ChunkLayoutDetails
+}
+
+func (e *ChunkLayoutError) Error() string {
+	return e.Reason
+}
+
+// UploadQuotaError rejects an upload over the uploader IP's daily quota.
+type UploadQuotaError struct {
+	ResetsAt time.Time
+	Details  types.UploadQuotaDetails
+}
+
+func (e *UploadQuotaError) Error() string {
+	return "daily upload quota exceeded"
+}
+
+// chunkLayout returns the chunk count and final chunk size a file of
+// totalSize bytes must declare when split into chunkSize chunks.
+func (s *FileService) chunkLayout(totalSize int64, chunkSize int32) types.ChunkLayoutDetails {
+	count := (totalSize + int64(chunkSize) - 1) / int64(chunkSize)
+	return types.ChunkLayoutDetails{
+		TotalSize:             totalSize,
+		ChunkSize:             chunkSize,
+		ExpectedChunkCount:    count,
+		ExpectedLastChunkSize: totalSize - (count-1)*int64(chunkSize),
+		MaxChunkSize:          s.limits.MaxChunkSize,
+		MaxChunkCount:         s.limits.MaxChunkCount,
+	}
+}
+
+// GetUploadAdvice recommends a chunk size and count for a file of totalSize
+// bytes that InitFileUpload will accept. While many uploads are in progress it
+// suggests larger chunks and less parallelism to reduce request load.
+func (s *FileService) GetUploadAdvice(ctx context.Context, totalSize int64) (types.UploadAdviceResponse, error) {
+	if totalSize <= 0 {
+		return types.UploadAdviceResponse{}, fmt.Errorf("%w: size must be positive", ErrInvalidUploadSize)
+	}
+	if totalSize > s.limits.MaxFileSize {
+		return types.UploadAdviceResponse{}, fmt.Errorf("%w: file size %d exceeds maximum of %d bytes",
+			ErrInvalidUploadSize, totalSize, s.limits.MaxFileSize)
+	}
+
+	busy := false
+	activeUploads, err := s.repository.CountActiveUploads(ctx)
+	if err != nil {
+		// Advice without load information is still valid advice
+		slog.Warn("failed to count active uploads",
+			slog.String("error", err.Error()),
+		)
+	} else {
+		busy = activeUploads >= busyActiveUploads
+	}
+
+	chunkSize := recommendedChunkSize(totalSize)
+	concurrency := s.transfer.UploadConcurrency
+	if busy {
+		chunkSize *= 2
+		concurrency = max(concurrency/2, 1)
+	}
+	chunkSize = max(chunkSize, int64(s.minChunkSize(totalSize)))
+	chunkSize = min(chunkSize, s.limits.MaxChunkSize, totalSize, math.MaxInt32)
+
+	return types.UploadAdviceResponse{
+		TotalSize:         totalSize,
+		ChunkSize:         int32(chunkSize),
+		ChunkCount:        int32((totalSize + chunkSize - 1) / chunkSize),
+		MaxChunkSize:      s.limits.MaxChunkSize,
+		UploadConcurrency: concurrency,
+		Busy:              busy,
+	}, nil
+}
+
+// minChunkSize returns the smallest chunk size that splits totalSize into at
+// most MaxChunkCount chunks.
+func (s *FileService) minChunkSize(totalSize int64) int32 {
+	size := (totalSize + int64(s.limits.MaxChunkCount) - 1) / int64(s.limits.MaxChunkCount)
+	return int32(min(size, math.MaxInt32))
+}
+
+// recommendedChunkSize grows chunks with the file so large uploads do not
+// need thousands of requests.
+func recommendedChunkSize(totalSize int64) int64 {
+	const mb = 1 << 20
+
+	switch {
+	case totalSize < 100*mb:
+		return 5 * mb
+	case totalSize < 500*mb:
+		return 10 * mb
+	case totalSize < 2048*mb:
+		return 25 * mb
+	default:
+		return 50 * mb
+	}
+}
+
+func (s *FileService) GetFileByShareID(ctx context.Context, shareID string) (sqlc.File, error) {
+	return s.repository.GetFileByShareID(ctx, shareID)
+}
+
+func (s *FileService) UpdateFileStatus(ctx context.Context, fileID pgtype.UUID, status string) (sqlc.File, error) {
+	return s.repository.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     fileID,
+		Status: status,
+	})
+}
+
+func (s *FileService) GetFileByID(ctx context.Context, fileID pgtype.UUID) (sqlc.File, error) {
+	return s.repository.GetFileByID(ctx, fileID)
+}
+
+// downloadLimitReached reports whether a share has no downloads left. Shares
+// with unlimited downloads never run out.
+func downloadLimitReached(downloadCount, maxDownloads int32) bool {
+	return maxDownloads != config.UnlimitedDownloads && downloadCount >= maxDownloads
+}
+
+// VerifyUploadToken checks that token matches the upload token issued by
+// InitFileUpload for the given file.
+func (s *FileService) VerifyUploadToken(ctx context.Context, fileID pgtype.UUID, token string) error {
+	file, err := s.GetFileByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if !file.DeletionTokenHash.Valid ||
+		subtle.ConstantTimeCompare([]byte(file.DeletionTokenHash.String), []byte(token)) != 1 {
+		slog.Warn("upload token mismatch",
+			slog.String("file_id", fileID.String()),
+		)
+		return ErrInvalidUploadToken
+	}
+
+	return nil
+}
+
+// GetShareStats returns the download statistics of a share to the holder of
+// its deletion token.
+func (s *FileService) GetShareStats(ctx context.Context, shareID, deletionToken string) (types.ShareStatsResponse, error) {
+	file, err := s.uploaderFile(ctx, shareID, deletionToken)
+	if err != nil {
+		return types.ShareStatsResponse{}, err
+	}
+	return shareStats(file), nil
+}
+
+func shareStats(file sqlc.File) types.ShareStatsResponse {
+	return types.ShareStatsResponse{
+		ShareID:            file.ShareID,
+		Status:             file.Status,
+		DownloadCount:      file.DownloadCount,
+		MaxDownloads:       file.MaxDownloads,
+		RemainingDownloads: remainingDownloads(file.DownloadCount, file.MaxDownloads),
+		LastDownloadedAt:   formatTimestamptz(file.LastDownloadedAt),
+		ExpiresAt:          formatTimestamptz(file.ExpiresAt),
+		Expired:            file.ExpiresAt.Valid && !file.ExpiresAt.Time.After(time.Now()),
+	}
+}
+
+// RevokeShare stops every download of a share at once, keeping its data until
+// it expires. Revoking a revoked share changes nothing.
+func (s *FileService) RevokeShare(ctx context.Context, shareID, deletionToken string) (types.ShareStatsResponse, error) {
+	file, err := s.uploaderFile(ctx, shareID, deletionToken)
+	if err != nil {
+		return types.ShareStatsResponse{}, err
+	}
+	if file.Status == FileStatusRevoked {
+		return shareStats(file), nil
+	}
+
+	revoked, err := s.repository.RevokeFile(ctx, file.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.ShareStatsResponse{}, ErrNotReady
+		}
+		return types.ShareStatsResponse{}, fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	slog.Info("share revoked",
+		slog.String("share_id", shareID),
+	)
+	_, err = s.repository.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		FileID:  revoked.ID,
+		Action:  AuditActionFileRevoked,
+		Actor:   "uploader",
+		Details: []byte("{}"),
+	})
+	if err != nil {
+		slog.Error("failed to record share revocation",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+	}
+	s.events.Publish(events.Event{Type: events.TypeRevokedByUploader, ShareID: shareID})
+
+	return shareStats(revoked), nil
+}
+
+// uploaderFile returns the file of shareID if token is its deletion token or
+// a management session for it that has not expired.
+func (s *FileService) uploaderFile(ctx context.Context, shareID, token string) (sqlc.File, error) {
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return sqlc.File{}, ErrNotFound
+		}
+		return sqlc.File{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if s.sessions != nil && s.sessions.VerifyManagementSession(shareID, token) == nil {
+		return file, nil
+	}
+	if !file.DeletionTokenHash.Valid ||
+		subtle.ConstantTimeCompare([]byte(file.DeletionTokenHash.String), []byte(token)) != 1 {
+		slog.Warn("deletion token mismatch",
+			slog.String("share_id", shareID),
+		)
+		return sqlc.File{}, ErrInvalidDeletionToken
+	}
+	return file, nil
+}
+
+// CreateShareLink mints a link to a ready share for a single recipient, who
+// downloads through it in place of the share ID. Its downloads count toward
+// both the link and the share.
+func (s *FileService) CreateShareLink(ctx context.Context, shareID, deletionToken string, req types.CreateShareLinkRequest) (types.ShareLinkResponse, error) {
+	file, err := s.uploaderFile(ctx, shareID, deletionToken)
+	if err != nil {
+		return types.ShareLinkResponse{}, err
+	}
+	if file.Status != "ready" {
+		return types.ShareLinkResponse{}, ErrNotReady
+	}
+
+	maxDownloads := req.MaxDownloads
+	if maxDownloads == 0 {
+		maxDownloads = 1
+	}
+	switch {
+	case maxDownloads < 1 || maxDownloads > MaxShareLinkDownloads:
+		return types.ShareLinkResponse{}, fmt.Errorf("%w: max_downloads must be between 1 and %d", ErrInvalidLinkRequest, MaxShareLinkDownloads)
+	case req.ExpiresInHours < 0:
+		return types.ShareLinkResponse{}, fmt.Errorf("%w: expires_in_hours must not be negative", ErrInvalidLinkRequest)
+	case len(req.Label) > MaxShareLinkLabelLength:
+		return types.ShareLinkResponse{}, fmt.Errorf("%w: label must be at most %d bytes", ErrInvalidLinkRequest, MaxShareLinkLabelLength)
+	}
+
+	// A link without an expiry of its own lasts as long as the share
+	var expiresAt pgtype.Timestamptz
+	if req.ExpiresInHours > 0 {
+		expiresAt = pgtype.Timestamptz{Time: time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour), Valid: true}
+		if file.ExpiresAt.Valid && file.ExpiresAt.Time.Before(expiresAt.Time) {
+			expiresAt = file.ExpiresAt
+		}
+	}
+
+	links, err := s.repository.CountShareLinksByFileId(ctx, file.ID)
+	if err != nil {
+		return types.ShareLinkResponse{}, fmt.Errorf("failed to count share links: %w", err)
+	}
+	if links >= MaxShareLinks {
+		return types.ShareLinkResponse{}, ErrTooManyShareLinks
+	}
+
+	linkID, err := idgen.Alphanumeric{Length: ShareLinkIDLength}.Generate()
+	if err != nil {
+		return types.ShareLinkResponse{}, fmt.Errorf("failed to generate link ID: %w", err)
+	}
+
+	link, err := s.repository.CreateShareLink(ctx, sqlc.CreateShareLinkParams{
+		LinkID:       linkID,
+		FileID:       file.ID,
+		Label:        pgtype.Text{String: req.Label, Valid: req.Label != ""},
+		MaxDownloads: maxDownloads,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		slog.Error("failed to create share link",
+			slog.String("error", err.Error()),
+			slog.String("share_id", shareID),
+		)
+		return types.ShareLinkResponse{}, fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	slog.Info("share link created",
+		slog.String("share_id", shareID),
+		slog.Int("max_downloads", int(maxDownloads)),
+	)
+	s.auditShareLink(ctx, file, AuditActionLinkCreated, link)
+
+	return shareLinkResponse(link, time.Now()), nil
+}
+
+// ListShareLinks returns the links of a share, oldest first.
+func (s *FileService) ListShareLinks(ctx context.Context, shareID, deletionToken string) ([]types.ShareLinkResponse, error) {
+	file, err := s.uploaderFile(ctx, shareID, deletionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.repository.ListShareLinksByFileId(ctx, file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+
+	now := time.Now()
+	resp := make([]types.ShareLinkResponse, 0, len(links))
+	for _, link := range links {
+		resp = append(resp, shareLinkResponse(link, now))
 	}
+	return resp, nil
+}
 
-	createdFile, err := s.repository.CreateFile(ctx, params)
+// RevokeShareLink ends a link of a share at once, including downloads in
+// progress through it. Revoking a revoked link changes nothing.
+func (s *FileService) RevokeShareLink(ctx context.Context, shareID, deletionToken, linkID string) (types.ShareLinkResponse, error) {
+	file, err := s.uploaderFile(ctx, shareID, deletionToken)
 	if err != nil {
-		slog.Error("failed to create file record",
-			slog.String("error", err.Error()),
-			slog.String("share_id", shareID),
-		)
-		return nil, fmt.Errorf("failed to create file record: %w", err)
+		return types.ShareLinkResponse{}, err
 	}
 
-	slog.Info("file upload initialized successfully",
+	link, err := s.repository.RevokeShareLink(ctx, sqlc.RevokeShareLinkParams{
+		LinkID: linkID,
+		FileID: file.ID,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.ShareLinkResponse{}, ErrShareLinkNotFound
+		}
+		return types.ShareLinkResponse{}, fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	slog.Info("share link revoked",
 		slog.String("share_id", shareID),
-		slog.String("file_id", createdFile.ID.String()),
-		slog.String("expires_at", expiresAt.Format(time.RFC3339)),
 	)
+	s.auditShareLink(ctx, file, AuditActionLinkRevoked, link)
 
-	return &types.InitUploadResponse{
-		FileID:      createdFile.ID.String(),
-		ShareID:     shareID,
-		UploadToken: uploadToken,
-		ExpiresAt:   expiresAt.Format(time.RFC3339),
-	}, nil
+	return shareLinkResponse(link, time.Now()), nil
 }
 
-func (s *FileService) validateUploadRequest(req types.InitUploadRequest) error {
-	if req.Salt == "" {
-		return fmt.Errorf("salt is required")
-	}
-	if req.EncryptedFilename == "" {
-		return fmt.Errorf("encrypted_filename is required")
+// auditShareLink records a link change in the audit log of its file. A
+// failure is logged but does not undo the change.
+func (s *FileService) auditShareLink(ctx context.Context, file sqlc.File, action string, link sqlc.ShareLink) {
+	_, err := s.repository.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		FileID:  file.ID,
+		Action:  action,
+		Actor:   "uploader",
+		Details: fmt.Appendf(nil, `{"link_id":%q}`, link.LinkID),
+	})
+	if err != nil {
+		slog.Error("failed to record share link event",
+			slog.String("error", err.Error()),
+			slog.String("action", action),
+			slog.String("share_id", file.ShareID),
+		)
 	}
-	if req.EncryptedMimeType == "" {
-		return fmt.Errorf("encrypted_mime_type is required")
+}
+
+// ResolveAlias returns the share ID an alias stands for. It fails with
+// ErrNotFound when no live share has the alias.
+func (s *FileService) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	shareID, err := s.repository.GetShareIDByAlias(ctx, pgtype.Text{String: alias, Valid: true})
+	if errors.Is(err, database.ErrNotFound) {
+		return "", ErrNotFound
 	}
-	if req.TotalSize <= 0 {
-		return fmt.Errorf("total_size must be positive")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve alias: %w", err)
 	}
-	if req.ChunkCount <= 0 {
-		return fmt.Errorf("chunk_count must be positive")
+	return shareID, nil
+}
+
+// ResolveShareLink returns the link with linkID, or nil when there is none.
+// A link that was revoked or has expired fails with ErrNotFound, one whose
+// downloads are used up with ErrDownloadLimitReached.
+func (s *FileService) ResolveShareLink(ctx context.Context, linkID string) (*sqlc.GetShareLinkRow, error) {
+	link, err := s.repository.GetShareLink(ctx, linkID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get share link: %w", err)
 	}
-	if req.ChunkSize <= 0 {
-		return fmt.Errorf("chunk_size must be positive")
+
+	switch shareLinkStatus(link.RevokedAt, link.ExpiresAt, link.DownloadCount, link.MaxDownloads, time.Now()) {
+	case LinkStatusRevoked, LinkStatusExpired:
+		return nil, ErrNotFound
+	case LinkStatusExhausted:
+		return nil, ErrDownloadLimitReached
 	}
+	return &link, nil
+}
 
-	// Validate chunk_count calculation to ensure data integrity and prevent
-	// malicious/buggy clients from sending incorrect values that could cause
-	// incomplete uploads or storage inconsistencies
-	expectedChunkCount := (req.TotalSize + int64(req.ChunkSize) - 1) / int64(req.ChunkSize)
-	if int64(req.ChunkCount) != expectedChunkCount {
-		return fmt.Errorf("chunk_count mismatch: expected %d, got %d", expectedChunkCount,
-			req.ChunkCount)
+type shareLinkKey struct{}
+
+// WithShareLink marks ctx as a download through link, so the download is
+// counted against it.
+func WithShareLink(ctx context.Context, link *sqlc.GetShareLinkRow) context.Context {
+	return context.WithValue(ctx, shareLinkKey{}, link)
+}
+
+// ShareLinkFromContext returns the link attached by WithShareLink, or nil.
+func ShareLinkFromContext(ctx context.Context) *sqlc.GetShareLinkRow {
+	link, _ := ctx.Value(shareLinkKey{}).(*sqlc.GetShareLinkRow)
+	return link
+}
+
+func shareLinkStatus(revokedAt, expiresAt pgtype.Timestamptz, downloadCount, maxDownloads int32, now time.Time) string {
+	switch {
+	case revokedAt.Valid:
+		return LinkStatusRevoked
+	case expiresAt.Valid && !expiresAt.Time.After(now):
+		return LinkStatusExpired
+	case downloadCount >= maxDownloads:
+		return LinkStatusExhausted
 	}
+	return LinkStatusActive
+}
 
-	lastChunkSize := req.TotalSize - (int64(req.ChunkCount-1) * int64(req.ChunkSize))
-	if lastChunkSize <= 0 || lastChunkSize > int64(req.ChunkSize) {
-		return fmt.Errorf("invalid last chunk size: %d", lastChunkSize)
+func shareLinkResponse(link sqlc.ShareLink, now time.Time) types.ShareLinkResponse {
+	return types.ShareLinkResponse{
+		LinkID:             link.LinkID,
+		Label:              link.Label.String,
+		Status:             shareLinkStatus(link.RevokedAt, link.ExpiresAt, link.DownloadCount, link.MaxDownloads, now),
+		DownloadCount:      link.DownloadCount,
+		MaxDownloads:       link.MaxDownloads,
+		RemainingDownloads: max(link.MaxDownloads-link.DownloadCount, 0),
+		ExpiresAt:          formatTimestamptz(link.ExpiresAt),
+		RevokedAt:          formatTimestamptz(link.RevokedAt),
+		CreatedAt:          formatTimestamptz(link.CreatedAt),
 	}
+}
 
-	if req.Pbkdf2Iterations <= 0 {
-		return fmt.Errorf("pbkdf2_iterations must be positive")
+// remainingDownloads is nil when downloads are unlimited.
+func remainingDownloads(downloadCount, maxDownloads int32) *int32 {
+	if maxDownloads == config.UnlimitedDownloads {
+		return nil
 	}
+	left := max(maxDownloads-downloadCount, 0)
+	return &left
+}
+
+// ShareWatch is the status of a share when watching began, followed by the
+// changes published since. Stop must be called to end the watch.
+type ShareWatch struct {
+	Status types.ShareStatusResponse
+	// ExpiresAt is zero when the share never expires
+	ExpiresAt time.Time
+	Events    <-chan events.Event
+	Stop      func()
 
-	const maxFileSize = 5 << 30 // 5GB TODO make it configurable
-	if req.TotalSize > maxFileSize {
-		return fmt.Errorf("file size %d exceeds maximum of %dGB", req.TotalSize, maxFileSize)
+	// uploaded holds the indexes of the chunks stored while uploading.
+	uploaded map[int32]struct{}
+}
+
+// WatchShare subscribes to the status changes of a share. The subscription
+// starts before the share is read, so no change in between is missed.
+func (s *FileService) WatchShare(ctx context.Context, shareID string) (*ShareWatch, error) {
+	changes, stop := s.events.Subscribe(shareID)
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		stop()
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
 
-	return nil
+	w := &ShareWatch{
+		Status: types.ShareStatusResponse{
+			ShareID:            file.ShareID,
+			Status:             file.Status,
+			DownloadCount:      file.DownloadCount,
+			MaxDownloads:       file.MaxDownloads,
+			RemainingDownloads: remainingDownloads(file.DownloadCount, file.MaxDownloads),
+			ExpiresAt:          formatTimestamptz(file.ExpiresAt),
+		},
+		Events: changes,
+		Stop:   stop,
+	}
+	if file.Status == FileStatusVerifying {
+		w.Status.Verification = &types.VerificationProgress{ChunkCount: file.ChunkCount}
+	}
+	if file.Status == "uploading" {
+		indexes, err := s.repository.ListChunkIndexesByFileId(ctx, file.ID)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to list uploaded chunks: %w", err)
+		}
+		w.uploaded = make(map[int32]struct{}, len(indexes))
+		for _, index := range indexes {
+			w.uploaded[index] = struct{}{}
+		}
+		w.Status.Upload = &types.UploadedChunks{ChunksUploaded: int32(len(w.uploaded)), ChunkCount: file.ChunkCount}
+	}
+	if file.ExpiresAt.Valid {
+		w.ExpiresAt = file.ExpiresAt.Time
+		// Cleanup marks expired files on its own schedule
+		if !w.ExpiresAt.After(time.Now()) {
+			w.Status.Status = "expired"
+		}
+	}
+	return w, nil
 }
 
-func (s *FileService) GetFileByShareID(ctx context.Context, shareID string) (sqlc.File, error) {
-	return s.repository.GetFileByShareID(ctx, shareID)
+// WatchUpload subscribes to the status changes of the share of an upload,
+// for uploaders that do not know the share ID before finalizing.
+func (s *FileService) WatchUpload(ctx context.Context, fileID pgtype.UUID) (*ShareWatch, error) {
+	file, err := s.repository.GetFileByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return s.WatchShare(ctx, file.ShareID)
 }
 
-func (s *FileService) UpdateFileStatus(ctx context.Context, fileID pgtype.UUID, status string) (sqlc.File, error) {
-	return s.repository.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
-		ID:     fileID,
-		Status: status,
-	})
+// Apply updates the watched status with e.
+func (w *ShareWatch) Apply(e events.Event) {
+	switch e.Type {
+	case events.TypeChunkReceived:
+		// Chunks of a watch that began after uploading are not tracked
+		if w.uploaded == nil {
+			return
+		}
+		w.uploaded[e.ChunkIndex] = struct{}{}
+		w.Status.Upload = &types.UploadedChunks{ChunksUploaded: int32(len(w.uploaded)), ChunkCount: e.ChunkCount}
+	case events.TypeDownloadCounted:
+		w.Status.DownloadCount = e.DownloadCount
+		w.Status.MaxDownloads = e.MaxDownloads
+		w.Status.RemainingDownloads = remainingDownloads(e.DownloadCount, e.MaxDownloads)
+		if downloadLimitReached(e.DownloadCount, e.MaxDownloads) {
+			w.Status.Status = "exhausted"
+		}
+	case events.TypeRevoked, events.TypeExpired:
+		w.Status.Status = "expired"
+	case events.TypeRevokedByUploader:
+		w.Status.Status = FileStatusRevoked
+	case events.TypeVerifying:
+		w.Status.Status = FileStatusVerifying
+		w.Status.Upload = nil
+		w.Status.Verification = &types.VerificationProgress{
+			ChunksVerified: e.ChunksVerified,
+			ChunkCount:     e.ChunkCount,
+		}
+	case events.TypeReady:
+		w.Status.Status = "ready"
+		w.Status.Upload = nil
+		w.Status.Verification = nil
+	case events.TypeCorrupt:
+		w.Status.Status = FileStatusCorrupt
+		w.Status.Upload = nil
+		w.Status.Verification = nil
+	case events.TypeVerificationFailed:
+		w.Status.Status = "uploading"
+		w.Status.Verification = nil
+	}
 }
 
-func (s *FileService) GetFileByID(ctx context.Context, fileID pgtype.UUID) (sqlc.File, error) {
-	return s.repository.GetFileByID(ctx, fileID)
+// Ended reports whether the share can no longer be downloaded, after which
+// its status does not change.
+func (w *ShareWatch) Ended() bool {
+	switch w.Status.Status {
+	case "ready", "uploading", FileStatusVerifying:
+		return false
+	}
+	return true
 }
 
 func (s *FileService) FinalizeUpload(ctx context.Context, fileID pgtype.UUID) (types.FinalizeUploadResponse, error) {
+	return withTimeout(ctx, s.timeouts.Finalize, func(ctx context.Context) (types.FinalizeUploadResponse, error) {
+		return s.finalizeUpload(ctx, fileID)
+	})
+}
+
+func (s *FileService) finalizeUpload(ctx context.Context, fileID pgtype.UUID) (types.FinalizeUploadResponse, error) {
 	slog.Info("finalizing file upload",
 		slog.String("file_id", fileID.String()),
 	)
@@ -218,9 +1844,21 @@ func (s *FileService) FinalizeUpload(ctx context.Context, fileID pgtype.UUID) (t
 			slog.String("error", err.Error()),
 			slog.String("file_id", fileID.String()),
 		)
+		if errors.Is(err, database.ErrNotFound) {
+			err = ErrNotFound
+		}
 		return types.FinalizeUploadResponse{}, fmt.Errorf("failed to get file metadata: %w", err)
 	}
 
+	// Finalizing again, e.g. after a lost response, is answered like the
+	// first finalize, but the share is only announced once
+	if fileMetadata.Status == "ready" {
+		return alreadyFinalized(fileMetadata), nil
+	}
+	if fileMetadata.Status == FileStatusVerifying {
+		return verifyingResponse(fileMetadata), nil
+	}
+
 	slog.Debug("counting uploaded chunks",
 		slog.String("file_id", fileID.String()),
 		slog.Int("expected_chunks", int(fileMetadata.ChunkCount)),
@@ -241,15 +1879,32 @@ func (s *FileService) FinalizeUpload(ctx context.Context, fileID pgtype.UUID) (t
 			slog.Int64("uploaded_chunks", chunksCount),
 			slog.Int("expected_chunks", int(fileMetadata.ChunkCount)),
 		)
-		return types.FinalizeUploadResponse{}, fmt.Errorf("chunk count does not match file chunk count")
+		return types.FinalizeUploadResponse{}, ErrChunkCountMismatch
+	}
+
+	if s.verifier != nil {
+		if s.rehash && s.asyncVerifyChunks > 0 && fileMetadata.ChunkCount >= s.asyncVerifyChunks {
+			return s.startVerification(ctx, fileMetadata)
+		}
+		if err := s.verifyChunks(ctx, fileMetadata, nil); err != nil {
+			return types.FinalizeUploadResponse{}, err
+		}
 	}
 
 	slog.Debug("updating file status to ready",
 		slog.String("file_id", fileID.String()),
 	)
 
-	fileMetadata, err = s.UpdateFileStatus(ctx, fileMetadata.ID, "ready")
+	// Only the finalize that moves the file out of uploading announces it
+	fileMetadata, err = s.repository.MarkFileReady(ctx, fileMetadata.ID)
 	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// A concurrent finalize may have won the race
+			if current, gerr := s.GetFileByID(ctx, fileID); gerr == nil && current.Status == "ready" {
+				return alreadyFinalized(current), nil
+			}
+			return types.FinalizeUploadResponse{}, fmt.Errorf("file is %w", ErrNotUploading)
+		}
 		slog.Error("failed to update file status",
 			slog.String("error", err.Error()),
 			slog.String("file_id", fileID.String()),
@@ -262,34 +1917,388 @@ func (s *FileService) FinalizeUpload(ctx context.Context, fileID pgtype.UUID) (t
 		slog.String("share_id", fileMetadata.ShareID),
 	)
 
+	s.announceReady(ctx, fileMetadata)
+
 	return types.FinalizeUploadResponse{
 		ShareID:       fileMetadata.ShareID,
 		DeletionToken: fileMetadata.DeletionTokenHash.String,
 	}, nil
 }
 
-func (s *FileService) GetFileSalt(ctx context.Context, shareID string) (string, error) {
-	salt, err := s.repository.GetFileSaltByShareId(ctx, shareID)
+// verifyChunks checks the stored chunks of a file about to be finalized. A
+// file with mismatched chunks can never be served intact, so it is marked
+// corrupt; storage errors leave it uploading for the client to retry.
+func (s *FileService) verifyChunks(ctx context.Context, file sqlc.File, progress func(int)) error {
+	start := time.Now()
+	err := s.verifier.VerifyChunks(ctx, file.ID, s.rehash, progress)
+	if err == nil {
+		slog.Debug("stored chunks verified",
+			slog.String("file_id", file.ID.String()),
+			slog.Bool("rehash", s.rehash),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil
+	}
+	if !errors.Is(err, ErrChunkMismatch) {
+		slog.Error("failed to verify stored chunks",
+			slog.String("error", err.Error()),
+			slog.String("file_id", file.ID.String()),
+		)
+		return fmt.Errorf("failed to verify chunks: %w", err)
+	}
+
+	slog.Warn("stored chunks do not match, marking file corrupt",
+		slog.String("error", err.Error()),
+		slog.String("file_id", file.ID.String()),
+		slog.String("share_id", file.ShareID),
+	)
+	_, uerr := s.repository.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+		ID:     file.ID,
+		Status: FileStatusCorrupt,
+	})
+	if uerr != nil {
+		slog.Error("failed to mark file corrupt",
+			slog.String("error", uerr.Error()),
+			slog.String("file_id", file.ID.String()),
+		)
+	}
+	return err
+}
+
+// startVerification moves a file to verifying and checks its chunks in the
+// background, finishing the finalize once they pass.
+func (s *FileService) startVerification(ctx context.Context, file sqlc.File) (types.FinalizeUploadResponse, error) {
+	started, err := s.repository.StartFileVerification(ctx, file.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// A concurrent finalize may have won the race
+			current, gerr := s.GetFileByID(ctx, file.ID)
+			switch {
+			case gerr != nil:
+			case current.Status == "ready":
+				return alreadyFinalized(current), nil
+			case current.Status == FileStatusVerifying:
+				return verifyingResponse(current), nil
+			}
+			return types.FinalizeUploadResponse{}, fmt.Errorf("file is %w", ErrNotUploading)
+		}
+		slog.Error("failed to start verification",
+			slog.String("error", err.Error()),
+			slog.String("file_id", file.ID.String()),
+		)
+		return types.FinalizeUploadResponse{}, fmt.Errorf("failed to update file status: %w", err)
+	}
+
+	slog.Info("verifying stored chunks in the background",
+		slog.String("file_id", started.ID.String()),
+		slog.String("share_id", started.ShareID),
+		slog.Int("chunk_count", int(started.ChunkCount)),
+	)
+
+	// The check outlives the finalize request
+	s.verifications.Add(1)
+	go func() {
+		defer s.verifications.Add(-1)
+		s.finishVerification(context.WithoutCancel(ctx), started)
+	}()
+
+	return verifyingResponse(started), nil
+}
+
+// BackgroundVerifications returns how many files this instance is verifying
+// in the background.
+func (s *FileService) BackgroundVerifications() int64 {
+	return s.verifications.Load()
+}
+
+// finishVerification checks the chunks of a verifying file and moves it on:
+// to ready when they pass, to corrupt when they do not, and back to
+// uploading when storage could not be checked.
+func (s *FileService) finishVerification(ctx context.Context, file sqlc.File) {
+	err := s.verifyChunks(ctx, file, s.verificationProgress(file))
+	if errors.Is(err, ErrChunkMismatch) {
+		s.events.Publish(events.Event{Type: events.TypeCorrupt, ShareID: file.ShareID})
+		return
+	}
+	if err != nil {
+		_, uerr := s.repository.UpdateFileStatus(ctx, sqlc.UpdateFileStatusParams{
+			ID:     file.ID,
+			Status: "uploading",
+		})
+		if uerr != nil {
+			slog.Error("failed to reset unverified file",
+				slog.String("error", uerr.Error()),
+				slog.String("file_id", file.ID.String()),
+			)
+		}
+		s.events.Publish(events.Event{Type: events.TypeVerificationFailed, ShareID: file.ShareID})
+		return
+	}
+
+	ready, err := s.repository.MarkFileReady(ctx, file.ID)
+	if err != nil {
+		slog.Error("failed to mark verified file ready",
+			slog.String("error", err.Error()),
+			slog.String("file_id", file.ID.String()),
+		)
+		return
+	}
+
+	slog.Info("file upload finalized successfully",
+		slog.String("file_id", ready.ID.String()),
+		slog.String("share_id", ready.ShareID),
+	)
+	s.announceReady(ctx, ready)
+}
+
+// verificationProgress publishes the progress of a background check in
+// steps of at least one percent, so large files do not flood watchers.
+func (s *FileService) verificationProgress(file sqlc.File) func(int) {
+	if s.events == nil || file.ChunkCount == 0 {
+		return nil
+	}
+	lastPercent := -1
+	return func(verified int) {
+		percent := verified * 100 / int(file.ChunkCount)
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+		s.events.Publish(events.Event{
+			Type:           events.TypeVerifying,
+			ShareID:        file.ShareID,
+			ChunksVerified: int32(verified),
+			ChunkCount:     file.ChunkCount,
+		})
+	}
+}
+
+func verifyingResponse(file sqlc.File) types.FinalizeUploadResponse {
+	return types.FinalizeUploadResponse{
+		ShareID:       file.ShareID,
+		DeletionToken: file.DeletionTokenHash.String,
+		Verifying:     true,
+	}
+}
+
+func alreadyFinalized(file sqlc.File) types.FinalizeUploadResponse {
+	slog.Info("upload already finalized",
+		slog.String("file_id", file.ID.String()),
+		slog.String("share_id", file.ShareID),
+	)
+	return types.FinalizeUploadResponse{
+		ShareID:          file.ShareID,
+		DeletionToken:    file.DeletionTokenHash.String,
+		AlreadyFinalized: true,
+	}
+}
+
+// announceReady records the file.ready lifecycle event and passes the share
+// to the publish hook. Neither can fail the finalize, as the file is already
+// downloadable.
+func (s *FileService) announceReady(ctx context.Context, file sqlc.File) {
+	_, err := s.repository.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		FileID:  file.ID,
+		Action:  AuditActionFileReady,
+		Actor:   "uploader",
+		Details: []byte("{}"),
+	})
 	if err != nil {
-		return "", fmt.Errorf("salt could not be found for file with %s shareID", shareID)
+		slog.Error("failed to record file ready event",
+			slog.String("error", err.Error()),
+			slog.String("share_id", file.ShareID),
+		)
+	}
+	s.events.Publish(events.Event{Type: events.TypeReady, ShareID: file.ShareID})
+
+	if s.publisher == nil {
+		return
+	}
+
+	share := publish.Share{
+		ShareID:      file.ShareID,
+		TotalSize:    file.TotalSize,
+		ChunkCount:   file.ChunkCount,
+		MaxDownloads: file.MaxDownloads,
+		CreatedAt:    file.CreatedAt.Time.UTC(),
+	}
+	if file.ExpiresAt.Valid {
+		expiresAt := file.ExpiresAt.Time.UTC()
+		share.ExpiresAt = &expiresAt
 	}
-	return salt, nil
+	s.publisher.Publish(share)
+}
+
+func (s *FileService) GetFileSalt(ctx context.Context, shareID string) (string, error) {
+	return withTimeout(ctx, s.timeouts.Lookup, func(ctx context.Context) (string, error) {
+		salt, err := s.repository.GetFileSaltByShareId(ctx, shareID)
+		if err != nil {
+			return "", fmt.Errorf("salt could not be found for file with %s shareID: %w", shareID, err)
+		}
+		return salt, nil
+	})
 }
 
-func (s *FileService) GetFileMetadataByShareID(ctx context.Context, shareID string) (sqlc.GetFileMetadataByShareIdRow, error) {
+func (s *FileService) GetFileMetadataByShareID(ctx context.Context, shareID string) (types.ShareMetadata, error) {
+	return withTimeout(ctx, s.timeouts.Lookup, func(ctx context.Context) (types.ShareMetadata, error) {
+		return s.getFileMetadataByShareID(ctx, shareID)
+	})
+}
+
+func (s *FileService) getFileMetadataByShareID(ctx context.Context, shareID string) (types.ShareMetadata, error) {
 	mdata, err := s.repository.GetFileMetadataByShareId(ctx, shareID)
 	if err != nil {
-		return sqlc.GetFileMetadataByShareIdRow{}, fmt.Errorf("file could not be found for %s shareID", shareID)
+		return types.ShareMetadata{}, fmt.Errorf("file could not be found for %s shareID: %w", shareID, err)
+	}
+	if mdata.Status == FileStatusRevoked {
+		return types.ShareMetadata{}, ErrRevoked
+	}
+	// Recipients of a link see its limits, which end their access first
+	if link := ShareLinkFromContext(ctx); link != nil {
+		mdata.MaxDownloads = link.MaxDownloads
+		mdata.DownloadCount = link.DownloadCount
+		if link.ExpiresAt.Valid && (!mdata.ExpiresAt.Valid || link.ExpiresAt.Time.Before(mdata.ExpiresAt.Time)) {
+			mdata.ExpiresAt = link.ExpiresAt
+		}
+	}
+	return types.ShareMetadata{
+		EncryptedFilename: mdata.EncryptedFilename,
+		EncryptedMimeType: mdata.EncryptedMimeType,
+		Salt:              mdata.Salt,
+		TotalSize:         mdata.TotalSize,
+		ChunkCount:        mdata.ChunkCount,
+		ExpiresAt:         formatTimestamptz(mdata.ExpiresAt),
+		MaxDownloads:      mdata.MaxDownloads,
+		DownloadCount:     mdata.DownloadCount,
+		CreatedAt:         formatTimestamptz(mdata.CreatedAt),
+		Policy:            s.sharePolicy(mdata, time.Now()),
+		Encryption:        encryptionMode(mdata.ServerEncrypted),
+		ReturnURL:         mdata.ReturnUrl.String,
+	}, nil
+}
+
+// validateReturnURL accepts absolute URLs on one of the allowed schemes.
+// Credentials are refused, since they make a URL look like it leads to a host
+// it does not.
+func (s *FileService) validateReturnURL(rawURL string) error {
+	if len(rawURL) > MaxReturnURLLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidReturnURL, MaxReturnURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReturnURL, err)
 	}
-	return mdata, nil
+	if !slices.Contains(s.returnURLSchemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme must be one of %s", ErrInvalidReturnURL, strings.Join(s.returnURLSchemes, ", "))
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: must not contain credentials", ErrInvalidReturnURL)
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() == "" {
+		return fmt.Errorf("%w: must have a host", ErrInvalidReturnURL)
+	}
+	return nil
+}
+
+func encryptionMode(serverEncrypted bool) string {
+	if serverEncrypted {
+		return types.EncryptionServer
+	}
+	return types.EncryptionClient
+}
+
+func (s *FileService) sharePolicy(mdata sqlc.GetFileMetadataByShareIdRow, now time.Time) types.SharePolicy {
+	policy := types.SharePolicy{
+		ExpiresBy:        []string{},
+		AtLimit:          types.AtLimitDeleteContent,
+		RetentionSeconds: int64(s.cleanupInterval / time.Second),
+	}
+	if mdata.ExpiresAt.Valid {
+		policy.ExpiresBy = append(policy.ExpiresBy, types.ExpiryReasonTime)
+	}
+	if mdata.MaxDownloads != config.UnlimitedDownloads {
+		policy.ExpiresBy = append(policy.ExpiresBy, types.ExpiryReasonDownloadLimit)
+	}
+
+	switch {
+	case mdata.ExpiresAt.Valid && !mdata.ExpiresAt.Time.After(now):
+		policy.ExpiryReason = types.ExpiryReasonTime
+	case mdata.Status == "exhausted" || downloadLimitReached(mdata.DownloadCount, mdata.MaxDownloads):
+		policy.ExpiryReason = types.ExpiryReasonDownloadLimit
+	case mdata.Status == "expired":
+		policy.ExpiryReason = types.ExpiryReasonRemoved
+	}
+	return policy
+}
+
+// IssueDownloadNonce returns a single-use nonce identifying one download.
+// Chunks fetched with it are counted against it, and the download counts
+// once every chunk was, so downloads cannot be burnt without fetching the
+// data. Only the nonce hash is stored. In read-only mode no nonce is issued.
+func (s *FileService) IssueDownloadNonce(ctx context.Context, shareID string) (string, error) {
+	if s.readOnly.Enabled() {
+		return "", nil
+	}
+
+	nonce := uuid.New().String()
+
+	var linkID pgtype.UUID
+	if link := ShareLinkFromContext(ctx); link != nil {
+		linkID = link.ID
+	}
+
+	rows, err := s.repository.CreateDownloadNonce(ctx, sqlc.CreateDownloadNonceParams{
+		NonceHash:   crypto.HashBytes([]byte(nonce)),
+		ShareID:     shareID,
+		ExpiresAt:   pgtype.Timestamptz{Time: time.Now().Add(DownloadNonceTTL), Valid: true},
+		ShareLinkID: linkID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create download nonce: %w", err)
+	}
+	if rows == 0 {
+		return "", ErrNotReady
+	}
+
+	return nonce, nil
 }
 
-func (s *FileService) CompleteDownload(ctx context.Context, shareID string) error {
+// CompleteDownload counts the download of nonce once every chunk was served
+// to it, failing with ErrDownloadIncomplete before. A download that was
+// already counted is not counted again.
+func (s *FileService) CompleteDownload(ctx context.Context, shareID, nonce string) error {
 	slog.Info("processing download completion",
 		slog.String("share_id", shareID),
 	)
 
-	err := s.runTx(ctx, func(q *sqlc.Queries) error {
+	session, err := s.repository.GetDownloadNonce(ctx, sqlc.GetDownloadNonceParams{
+		NonceHash: crypto.HashBytes([]byte(nonce)),
+		ShareID:   shareID,
+	})
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		slog.Warn("download nonce missing or expired",
+			slog.String("share_id", shareID),
+		)
+		return ErrInvalidDownloadNonce
+	case err != nil:
+		return fmt.Errorf("failed to get download nonce: %w", err)
+	case session.UsedAt.Valid:
+		slog.Debug("download already counted",
+			slog.String("share_id", shareID),
+		)
+		return nil
+	case session.ServedChunks < session.ChunkCount:
+		slog.Warn("download completed before every chunk was served",
+			slog.String("share_id", shareID),
+			slog.Int("served_chunks", int(session.ServedChunks)),
+			slog.Int("chunk_count", int(session.ChunkCount)),
+		)
+		return ErrDownloadIncomplete
+	}
+
+	var completed sqlc.CompleteFileDownloadByShareIdRow
+	err = s.runTx(ctx, func(q sqlc.Querier) error {
 		row, err := q.CompleteFileDownloadByShareId(ctx, shareID)
 		if err != nil {
 			slog.Debug("download completion transaction failed",
@@ -299,6 +2308,40 @@ func (s *FileService) CompleteDownload(ctx context.Context, shareID string) erro
 			return err
 		}
 
+		// Rolls back the increment above if another request counted this
+		// download first
+		consumed, err := q.ConsumeDownloadNonce(ctx, sqlc.ConsumeDownloadNonceParams{
+			NonceHash: crypto.HashBytes([]byte(nonce)),
+			ShareID:   shareID,
+		})
+		if err != nil {
+			return err
+		}
+		if consumed == 0 {
+			return ErrInvalidDownloadNonce
+		}
+		completed = row
+
+		if session.ShareLinkID.Valid {
+			counted, err := q.CountShareLinkDownload(ctx, session.ShareLinkID)
+			if err != nil {
+				return err
+			}
+			if counted == 0 {
+				return fmt.Errorf("%w: share link was revoked, expired or used up", ErrDownloadLimitReached)
+			}
+		}
+
+		_, err = q.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+			FileID:  row.ID,
+			Action:  AuditActionDownloadCompleted,
+			Actor:   "downloader",
+			Details: fmt.Appendf(nil, `{"download_count":%d}`, row.DownloadCount),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+
 		slog.Debug("download count incremented",
 			slog.String("share_id", shareID),
 			slog.Int("new_count", int(row.DownloadCount)),
@@ -327,10 +2370,39 @@ func (s *FileService) CompleteDownload(ctx context.Context, shareID string) erro
 		slog.Info("download completed successfully",
 			slog.String("share_id", shareID),
 		)
+
+		details := map[string]any{
+			"download_count": completed.DownloadCount,
+			"max_downloads":  completed.MaxDownloads,
+		}
+		notifications := []notify.Event{{Type: notify.EventDownloadCompleted, ShareID: shareID, Details: details}}
+		if completed.ReachedLimit.Bool {
+			notifications = append(notifications, notify.Event{Type: notify.EventLimitReached, ShareID: shareID, Details: details})
+		}
+		s.notifyWebhook(ctx, completed.ID, notifications...)
+		s.events.Publish(events.Event{
+			Type:          events.TypeDownloadCounted,
+			ShareID:       shareID,
+			DownloadCount: completed.DownloadCount,
+			MaxDownloads:  completed.MaxDownloads,
+		})
+		return nil
+	}
+
+	if errors.Is(err, ErrInvalidDownloadNonce) {
+		slog.Info("download counted by a concurrent request",
+			slog.String("share_id", shareID),
+		)
 		return nil
 	}
+	if errors.Is(err, ErrDownloadLimitReached) {
+		slog.Warn("share link ended before the download was counted",
+			slog.String("share_id", shareID),
+		)
+		return err
+	}
 
-	if !errors.Is(err, pgx.ErrNoRows) {
+	if !errors.Is(err, database.ErrNotFound) {
 		slog.Error("unexpected error completing download",
 			slog.String("error", err.Error()),
 			slog.String("share_id", shareID),
@@ -355,7 +2427,7 @@ func (s *FileService) CompleteDownload(ctx context.Context, shareID string) erro
 			slog.Time("expired_at", meta.ExpiresAt.Time),
 		)
 		return ErrExpired
-	case meta.MaxDownloads > 0 && meta.DownloadCount >= meta.MaxDownloads:
+	case downloadLimitReached(meta.DownloadCount, meta.MaxDownloads):
 		slog.Warn("download limit already reached",
 			slog.String("share_id", shareID),
 			slog.Int("download_count", int(meta.DownloadCount)),
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
 	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/notify"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/ilkin0/gzln/internal/testutil"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -20,7 +24,7 @@ func setupTestFileService(t *testing.T) (*FileService, *sqlc.Queries, *database.
 	containers := testutil.SetupTestContainers(t)
 
 	txRunner := database.NewTxRunner(containers.Database.Pool)
-	fileService := NewFileService(containers.Database.Queries, txRunner, containers.MinioClient.Client)
+	fileService := NewFileService(containers.Querier(), txRunner, containers.MinioClient.Client, config.DefaultLimits())
 
 	return fileService, containers.Database.Queries, containers.Database, containers.Cleanup
 }
@@ -34,6 +38,39 @@ func createTestFileWithOpts(t *testing.T, queries *sqlc.Queries, ctx context.Con
 	return testutil.CreateTestFile(t, queries, ctx, opts)
 }
 
+// issueNonce returns a nonce every chunk of the file was served to, so its
+// download can be completed.
+func issueNonce(t *testing.T, fileService *FileService, ctx context.Context, shareID string) string {
+	t.Helper()
+	nonce, err := fileService.IssueDownloadNonce(ctx, shareID)
+	require.NoError(t, err)
+	serveAllChunks(t, fileService.repository, ctx, shareID, nonce)
+	return nonce
+}
+
+func serveAllChunks(t *testing.T, repo sqlc.Querier, ctx context.Context, shareID, nonce string) {
+	t.Helper()
+	session, err := repo.GetDownloadNonce(ctx, sqlc.GetDownloadNonceParams{
+		NonceHash: crypto.HashBytes([]byte(nonce)),
+		ShareID:   shareID,
+	})
+	require.NoError(t, err)
+	if session.ChunkCount == 0 {
+		return
+	}
+
+	chunkIndexes := make([]int32, session.ChunkCount)
+	for i := range chunkIndexes {
+		chunkIndexes[i] = int32(i)
+	}
+	_, err = repo.RecordDownloadNonceChunks(ctx, sqlc.RecordDownloadNonceChunksParams{
+		ChunkIndexes: chunkIndexes,
+		NonceHash:    crypto.HashBytes([]byte(nonce)),
+		ShareID:      shareID,
+	})
+	require.NoError(t, err)
+}
+
 func TestCompleteDownload_Integration_Success(t *testing.T) {
 	fileService, queries, _, cleanup := setupTestFileService(t)
 	defer cleanup()
@@ -42,7 +79,7 @@ func TestCompleteDownload_Integration_Success(t *testing.T) {
 
 	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
 
-	err := fileService.CompleteDownload(ctx, file.ShareID)
+	err := fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
 	require.NoError(t, err)
 
 	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
@@ -50,6 +87,139 @@ func TestCompleteDownload_Integration_Success(t *testing.T) {
 	assert.Equal(t, int32(1), updatedFile.DownloadCount)
 }
 
+func TestCompleteDownload_Integration_UnlimitedDownloads(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, config.UnlimitedDownloads, 1)
+
+	for range 3 {
+		err := fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
+		require.NoError(t, err)
+	}
+
+	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), updatedFile.DownloadCount)
+	assert.Equal(t, int32(config.UnlimitedDownloads), updatedFile.MaxDownloads)
+
+	expired, err := queries.GetExpiredFiles(ctx)
+	require.NoError(t, err)
+	for _, f := range expired {
+		assert.NotEqual(t, file.ID, f.ID, "unlimited share must not be treated as maxed out")
+	}
+}
+
+func TestCompleteDownload_Integration_NotifiesWebhook(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	// The receiver listens on loopback
+	fileService.WithNotifier(notify.New(true))
+
+	url, events := newWebhookReceiver(t)
+	file := createTestFileWithOpts(t, queries, ctx, 1, 1)
+	_, err := queries.CreateFileWebhook(ctx, sqlc.CreateFileWebhookParams{
+		FileID: file.ID,
+		Url:    url,
+		Secret: "secret",
+	})
+	require.NoError(t, err)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
+	require.NoError(t, err)
+
+	received := []string{receiveEvent(t, events).Type, receiveEvent(t, events).Type}
+	assert.ElementsMatch(t, []string{notify.EventDownloadCompleted, notify.EventLimitReached}, received)
+}
+
+func TestCompleteDownload_Integration_NonceCountedOnce(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	nonce := issueNonce(t, fileService, ctx, file.ShareID)
+
+	err := fileService.CompleteDownload(ctx, file.ShareID, nonce)
+	require.NoError(t, err)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, nonce)
+	require.NoError(t, err)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, "forged-nonce")
+	assert.ErrorIs(t, err, ErrInvalidDownloadNonce)
+
+	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), updatedFile.DownloadCount)
+}
+
+func TestCompleteDownload_Integration_ChunksNotServed(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	nonce, err := fileService.IssueDownloadNonce(ctx, file.ShareID)
+	require.NoError(t, err)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, nonce)
+	assert.ErrorIs(t, err, ErrDownloadIncomplete)
+
+	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), updatedFile.DownloadCount)
+}
+
+func TestCompleteDownload_Integration_NonceBoundToShare(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	other := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	nonce := issueNonce(t, fileService, ctx, other.ShareID)
+
+	err := fileService.CompleteDownload(ctx, file.ShareID, nonce)
+	assert.ErrorIs(t, err, ErrInvalidDownloadNonce)
+}
+
+func TestCompleteDownload_Integration_ExpiredNonce(t *testing.T) {
+	fileService, queries, db, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	nonce := issueNonce(t, fileService, ctx, file.ShareID)
+	_, err := db.Pool.Exec(ctx, "UPDATE download_nonces SET expires_at = now() - interval '1 minute'")
+	require.NoError(t, err)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, nonce)
+	assert.ErrorIs(t, err, ErrInvalidDownloadNonce)
+}
+
+func TestIssueDownloadNonce_Integration_NotReady(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	opts := testutil.DefaultTestFileOptions()
+	opts.Status = "uploading"
+	file := testutil.CreateTestFile(t, queries, ctx, opts)
+
+	_, err := fileService.IssueDownloadNonce(ctx, file.ShareID)
+	assert.ErrorIs(t, err, ErrNotReady)
+}
+
 func TestCompleteDownload_Integration_LimitReached(t *testing.T) {
 	fileService, queries, _, cleanup := setupTestFileService(t)
 	defer cleanup()
@@ -58,7 +228,7 @@ func TestCompleteDownload_Integration_LimitReached(t *testing.T) {
 
 	file := createTestFileWithOpts(t, queries, ctx, 1, 1)
 
-	err := fileService.CompleteDownload(ctx, file.ShareID)
+	err := fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
 	require.NoError(t, err)
 
 	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
@@ -66,7 +236,7 @@ func TestCompleteDownload_Integration_LimitReached(t *testing.T) {
 	assert.Equal(t, int32(1), updatedFile.DownloadCount)
 	assert.Equal(t, "exhausted", updatedFile.Status)
 
-	err = fileService.CompleteDownload(ctx, file.ShareID)
+	err = fileService.CompleteDownload(ctx, file.ShareID, "stale-nonce")
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrDownloadLimitReached)
 }
@@ -79,7 +249,7 @@ func TestCompleteDownload_Integration_FileExpired(t *testing.T) {
 
 	file := testutil.CreateExpiredFile(t, queries, db, ctx)
 
-	err := fileService.CompleteDownload(ctx, file.ShareID)
+	err := fileService.CompleteDownload(ctx, file.ShareID, "stale-nonce")
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrExpired)
 }
@@ -90,7 +260,7 @@ func TestCompleteDownload_Integration_FileNotFound(t *testing.T) {
 
 	ctx := context.Background()
 
-	err := fileService.CompleteDownload(ctx, "nonexistent")
+	err := fileService.CompleteDownload(ctx, "nonexistent", "stale-nonce")
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrNotFound)
 }
@@ -103,13 +273,13 @@ func TestCompleteDownload_Integration_MultipleDownloads(t *testing.T) {
 
 	file := createTestFileWithOpts(t, queries, ctx, 3, 10)
 
-	err := fileService.CompleteDownload(ctx, file.ShareID)
+	err := fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
 	require.NoError(t, err)
 
-	err = fileService.CompleteDownload(ctx, file.ShareID)
+	err = fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
 	require.NoError(t, err)
 
-	err = fileService.CompleteDownload(ctx, file.ShareID)
+	err = fileService.CompleteDownload(ctx, file.ShareID, issueNonce(t, fileService, ctx, file.ShareID))
 	require.NoError(t, err)
 
 	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
@@ -117,7 +287,7 @@ func TestCompleteDownload_Integration_MultipleDownloads(t *testing.T) {
 	assert.Equal(t, int32(3), updatedFile.DownloadCount)
 	assert.Equal(t, "exhausted", updatedFile.Status)
 
-	err = fileService.CompleteDownload(ctx, file.ShareID)
+	err = fileService.CompleteDownload(ctx, file.ShareID, "stale-nonce")
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrDownloadLimitReached)
 }
@@ -136,8 +306,9 @@ func TestCompleteDownload_Integration_ConcurrentAccess(t *testing.T) {
 	results := make(chan error, concurrentRequests)
 
 	for i := 0; i < concurrentRequests; i++ {
+		nonce := issueNonce(t, fileService, ctx, file.ShareID)
 		go func() {
-			results <- fileService.CompleteDownload(ctx, file.ShareID)
+			results <- fileService.CompleteDownload(ctx, file.ShareID, nonce)
 		}()
 	}
 
@@ -178,8 +349,9 @@ func TestCompleteDownload_Integration_ConcurrentAccessSingleLimit(t *testing.T)
 	results := make(chan error, concurrentRequests)
 
 	for i := 0; i < concurrentRequests; i++ {
+		nonce := issueNonce(t, fileService, ctx, file.ShareID)
 		go func() {
-			results <- fileService.CompleteDownload(ctx, file.ShareID)
+			results <- fileService.CompleteDownload(ctx, file.ShareID, nonce)
 		}()
 	}
 
@@ -252,6 +424,79 @@ func TestInitAndFinalizeUpload_Integration(t *testing.T) {
 	file, err := queries.GetFileByID(ctx, fileID)
 	require.NoError(t, err)
 	assert.Equal(t, "ready", file.Status)
+
+	// Finalizing again succeeds without a second lifecycle event
+	_, err = fileService.FinalizeUpload(ctx, fileID)
+	require.NoError(t, err)
+
+	entries, err := queries.ListAuditLogByFileId(ctx, fileID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditActionFileReady, entries[0].Action)
+}
+
+func TestUploadQuota_Integration_CountsPerIP(t *testing.T) {
+	fileService, _, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+	fileService.WithUploadQuota(config.UploadQuota{DailyCount: 2})
+
+	ctx := context.Background()
+
+	req := types.InitUploadRequest{
+		Salt:              "test-salt",
+		EncryptedFilename: "encrypted-name",
+		EncryptedMimeType: "encrypted-mime",
+		TotalSize:         256 * 1024,
+		ChunkCount:        1,
+		ChunkSize:         256 * 1024,
+		Pbkdf2Iterations:  100000,
+	}
+
+	for range 2 {
+		_, err := fileService.InitFileUpload(ctx, req, "192.168.1.1")
+		require.NoError(t, err)
+	}
+
+	_, err := fileService.InitFileUpload(ctx, req, "192.168.1.1")
+	var quotaErr *UploadQuotaError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, int64(2), quotaErr.Details.UsedCount)
+	assert.Equal(t, int64(2*256*1024), quotaErr.Details.UsedBytes)
+
+	// Other uploaders have their own quota
+	_, err = fileService.InitFileUpload(ctx, req, "192.168.1.2")
+	require.NoError(t, err)
+}
+
+func TestActiveShares_Integration_ExpiredSharesFreeSlots(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+	fileService.WithUploadQuota(config.UploadQuota{ActiveShares: 1})
+
+	ctx := context.Background()
+
+	req := types.InitUploadRequest{
+		Salt:              "test-salt",
+		EncryptedFilename: "encrypted-name",
+		EncryptedMimeType: "encrypted-mime",
+		TotalSize:         256 * 1024,
+		ChunkCount:        1,
+		ChunkSize:         256 * 1024,
+		Pbkdf2Iterations:  100000,
+	}
+
+	resp, err := fileService.InitFileUpload(ctx, req, "192.168.1.1")
+	require.NoError(t, err)
+
+	_, err = fileService.InitFileUpload(ctx, req, "192.168.1.1")
+	assert.ErrorIs(t, err, ErrTooManyActiveShares)
+
+	file, err := queries.GetFileByShareID(ctx, resp.ShareID)
+	require.NoError(t, err)
+	require.NoError(t, queries.ExpireFilesByIds(ctx, []pgtype.UUID{file.ID}))
+
+	_, err = fileService.InitFileUpload(ctx, req, "192.168.1.1")
+	require.NoError(t, err)
 }
 
 func TestFinalizeUpload_Integration_ChunkCountMismatch(t *testing.T) {
@@ -293,3 +538,126 @@ func TestFinalizeUpload_Integration_ChunkCountMismatch(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "chunk count does not match")
 }
+
+func TestUploadSlot_Integration_RedeemedOnce(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	apiKey := "backend-api-key-0123456789abcdef0123"
+	fileService.WithUploadSlots(config.UploadSlots{APIKeys: []string{apiKey}, TTL: time.Minute})
+
+	ctx := context.Background()
+
+	slot, err := fileService.CreateUploadSlot(ctx, apiKey, types.CreateUploadSlotRequest{
+		MaxSize:      1 << 20,
+		MaxDownloads: 1,
+	})
+	require.NoError(t, err)
+
+	req := types.InitUploadRequest{
+		Salt:              "slot-salt",
+		EncryptedFilename: "encrypted-name",
+		EncryptedMimeType: "encrypted-mime",
+		TotalSize:         2 << 20,
+		ChunkCount:        8,
+		ChunkSize:         256 * 1024,
+		Pbkdf2Iterations:  100000,
+		SlotToken:         slot.SlotToken,
+	}
+
+	// Too large for the slot, which stays available
+	_, err = fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidUploadSlot)
+
+	req.TotalSize = 1 << 20
+	req.ChunkCount = 4
+	resp, err := fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	require.NoError(t, err)
+
+	file, err := queries.GetFileByShareID(ctx, resp.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), file.MaxDownloads)
+
+	_, err = fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidUploadSlot)
+}
+
+func TestBundle_Integration_ListsReadyFiles(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	bundle, err := fileService.CreateBundle(ctx, types.CreateBundleRequest{ExpiresInHours: 2})
+	require.NoError(t, err)
+
+	req := types.InitUploadRequest{
+		Salt:              "bundle-salt",
+		EncryptedFilename: "encrypted-name",
+		EncryptedMimeType: "encrypted-mime",
+		TotalSize:         1024,
+		ChunkCount:        1,
+		ChunkSize:         1024,
+		Pbkdf2Iterations:  100000,
+		MaxDownloads:      1,
+		ExpiresInHours:    24,
+		BundleToken:       bundle.BundleToken,
+	}
+
+	ready, err := fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	require.NoError(t, err)
+	_, err = fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	require.NoError(t, err)
+
+	var fileID pgtype.UUID
+	require.NoError(t, fileID.Scan(ready.FileID))
+	_, err = queries.MarkFileReady(ctx, fileID)
+	require.NoError(t, err)
+
+	manifest, err := fileService.GetBundleManifest(ctx, bundle.ShareID)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 1, "files still uploading are not listed")
+	assert.Equal(t, ready.ShareID, manifest.Files[0].ShareID)
+	assert.Equal(t, bundle.ExpiresAt, manifest.ExpiresAt)
+
+	req.BundleToken = "not-a-bundle"
+	_, err = fileService.InitFileUpload(ctx, req, "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidBundleToken)
+}
+
+func TestCompleteDownload_Integration_ShareLink(t *testing.T) {
+	fileService, queries, _, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	file := createTestFileWithOpts(t, queries, ctx, 5, 10)
+	created, err := queries.CreateShareLink(ctx, sqlc.CreateShareLinkParams{
+		LinkID:       "abcdefgh12345678",
+		FileID:       file.ID,
+		MaxDownloads: 1,
+	})
+	require.NoError(t, err)
+
+	link, err := fileService.ResolveShareLink(ctx, created.LinkID)
+	require.NoError(t, err)
+	require.NotNil(t, link)
+	linkCtx := WithShareLink(ctx, link)
+
+	first := issueNonce(t, fileService, linkCtx, file.ShareID)
+	second := issueNonce(t, fileService, linkCtx, file.ShareID)
+
+	err = fileService.CompleteDownload(ctx, file.ShareID, first)
+	require.NoError(t, err)
+
+	// The link is used up, so the second download is not counted at all
+	err = fileService.CompleteDownload(ctx, file.ShareID, second)
+	assert.ErrorIs(t, err, ErrDownloadLimitReached)
+
+	updatedFile, err := queries.GetFileByShareID(ctx, file.ShareID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), updatedFile.DownloadCount)
+
+	_, err = fileService.ResolveShareLink(ctx, created.LinkID)
+	assert.ErrorIs(t, err, ErrDownloadLimitReached)
+}
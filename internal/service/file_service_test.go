@@ -2,12 +2,28 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ilkin0/gzln/internal/abuse"
 	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/events"
+	"github.com/ilkin0/gzln/internal/flags"
+	"github.com/ilkin0/gzln/internal/notify"
+	"github.com/ilkin0/gzln/internal/publish"
+	"github.com/ilkin0/gzln/internal/readonly"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,8 +37,26 @@ type MockQuerier struct {
 // mockTxRunner executes the transaction function with a nil Queries (for unit tests)
 // For tests that use transactions, the function will be executed, but queries will be nil
 // so tests need to mock the repository methods instead
-func mockTxRunner(ctx context.Context, fn func(*sqlc.Queries) error) error {
-	return fn(nil)
+func mockTxRunner(ctx context.Context, fn func(sqlc.Querier) error) error {
+	return database.Classify(fn(nil))
+}
+
+// txRunnerOn runs transaction functions against q, so the queries they make
+// can be mocked like any other.
+func txRunnerOn(q sqlc.Querier) database.TxRunner {
+	return func(ctx context.Context, fn func(sqlc.Querier) error) error {
+		return database.Classify(fn(q))
+	}
+}
+
+// trackingTxRunner is txRunnerOn that sets *inTx while a transaction
+// function runs, so mocks can check which queries share a transaction.
+func trackingTxRunner(q sqlc.Querier, inTx *bool) database.TxRunner {
+	return func(ctx context.Context, fn func(sqlc.Querier) error) error {
+		*inTx = true
+		defer func() { *inTx = false }()
+		return database.Classify(fn(q))
+	}
 }
 
 func (m *MockQuerier) CreateFile(ctx context.Context, arg sqlc.CreateFileParams) (sqlc.File, error) {
@@ -40,6 +74,11 @@ func (m *MockQuerier) GetFileByShareID(ctx context.Context, shareID string) (sql
 	return args.Get(0).(sqlc.File), args.Error(1)
 }
 
+func (m *MockQuerier) UpdateFileMaxDownloadRate(ctx context.Context, arg sqlc.UpdateFileMaxDownloadRateParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockQuerier) UpdateFileStatus(ctx context.Context, arg sqlc.UpdateFileStatusParams) (sqlc.File, error) {
 	args := m.Called(ctx, arg)
 	return args.Get(0).(sqlc.File), args.Error(1)
@@ -55,6 +94,91 @@ func (m *MockQuerier) DeleteExpiredFiles(ctx context.Context) error {
 	return args.Error(0)
 }
 
+func (m *MockQuerier) CreateDownloadNonce(ctx context.Context, arg sqlc.CreateDownloadNonceParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) ConsumeDownloadNonce(ctx context.Context, arg sqlc.ConsumeDownloadNonceParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetDownloadNonce(ctx context.Context, arg sqlc.GetDownloadNonceParams) (sqlc.GetDownloadNonceRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.GetDownloadNonceRow), args.Error(1)
+}
+
+func (m *MockQuerier) RecordDownloadNonceChunks(ctx context.Context, arg sqlc.RecordDownloadNonceChunksParams) (sqlc.RecordDownloadNonceChunksRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.RecordDownloadNonceChunksRow), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteExpiredDownloadNonces(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) CreateUploadSlot(ctx context.Context, arg sqlc.CreateUploadSlotParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockQuerier) ConsumeUploadSlot(ctx context.Context, arg sqlc.ConsumeUploadSlotParams) (sqlc.UploadSlot, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.UploadSlot), args.Error(1)
+}
+
+func (m *MockQuerier) GetUploadSlot(ctx context.Context, arg sqlc.GetUploadSlotParams) (sqlc.UploadSlot, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.UploadSlot), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteExpiredUploadSlots(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) CreateFileBundle(ctx context.Context, arg sqlc.CreateFileBundleParams) (sqlc.FileBundle, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.FileBundle), args.Error(1)
+}
+
+func (m *MockQuerier) GetFileBundleByTokenHash(ctx context.Context, tokenHash string) (sqlc.FileBundle, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(sqlc.FileBundle), args.Error(1)
+}
+
+func (m *MockQuerier) GetFileBundleByShareId(ctx context.Context, shareID string) (sqlc.FileBundle, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).(sqlc.FileBundle), args.Error(1)
+}
+
+func (m *MockQuerier) AddBundleFile(ctx context.Context, arg sqlc.AddBundleFileParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockQuerier) AddTransferBytes(ctx context.Context, arg sqlc.AddTransferBytesParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockQuerier) CountBundleFiles(ctx context.Context, bundleID pgtype.UUID) (int64, error) {
+	args := m.Called(ctx, bundleID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) ListReadyBundleFiles(ctx context.Context, bundleID pgtype.UUID) ([]sqlc.ListReadyBundleFilesRow, error) {
+	args := m.Called(ctx, bundleID)
+	return args.Get(0).([]sqlc.ListReadyBundleFilesRow), args.Error(1)
+}
+
+func (m *MockQuerier) CountActiveUploads(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockQuerier) CountChunksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error) {
 	args := m.Called(ctx, fileID)
 	return args.Get(0).(int64), args.Error(1)
@@ -65,6 +189,16 @@ func (m *MockQuerier) GetFileSaltByShareId(ctx context.Context, shareID string)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockQuerier) GetShareIDByAlias(ctx context.Context, alias pgtype.Text) (string, error) {
+	args := m.Called(ctx, alias)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQuerier) ShareNameTaken(ctx context.Context, shareID string) (bool, error) {
+	args := m.Called(ctx, shareID)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockQuerier) GetFileMetadataByShareId(ctx context.Context, shareID string) (sqlc.GetFileMetadataByShareIdRow, error) {
 	args := m.Called(ctx, shareID)
 	return args.Get(0).(sqlc.GetFileMetadataByShareIdRow), args.Error(1)
@@ -95,11 +229,236 @@ func (m *MockQuerier) GetExpiredFiles(ctx context.Context) ([]sqlc.GetExpiredFil
 	return args.Get(0).([]sqlc.GetExpiredFilesRow), args.Error(1)
 }
 
+func (m *MockQuerier) CreatePaste(ctx context.Context, arg sqlc.CreatePasteParams) (sqlc.Paste, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.Paste), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteExpiredPastes(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetPasteByShareId(ctx context.Context, shareID string) (sqlc.Paste, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).(sqlc.Paste), args.Error(1)
+}
+
+func (m *MockQuerier) ReadPasteByShareId(ctx context.Context, shareID string) (sqlc.Paste, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).(sqlc.Paste), args.Error(1)
+}
+
+func (m *MockQuerier) DeletePurgedFiles(ctx context.Context, arg sqlc.DeletePurgedFilesParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) EvictFile(ctx context.Context, id pgtype.UUID) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockQuerier) ExpireFilesByIds(ctx context.Context, ids []pgtype.UUID) error {
 	args := m.Called(ctx, ids)
 	return args.Error(0)
 }
 
+func (m *MockQuerier) UpdateFileAdminNotes(ctx context.Context, arg sqlc.UpdateFileAdminNotesParams) (sqlc.File, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.File), args.Error(1)
+}
+
+func (m *MockQuerier) CreateAuditLogEntry(ctx context.Context, arg sqlc.CreateAuditLogEntryParams) (sqlc.AuditLog, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.AuditLog), args.Error(1)
+}
+
+func (m *MockQuerier) ListAuditLogByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.AuditLog, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).([]sqlc.AuditLog), args.Error(1)
+}
+
+func (m *MockQuerier) ListAuditLogByFileIdsAndAction(ctx context.Context, arg sqlc.ListAuditLogByFileIdsAndActionParams) ([]sqlc.AuditLog, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]sqlc.AuditLog), args.Error(1)
+}
+
+func (m *MockQuerier) ListFilesByDeletionTokens(ctx context.Context, tokens []string) ([]sqlc.File, error) {
+	args := m.Called(ctx, tokens)
+	return args.Get(0).([]sqlc.File), args.Error(1)
+}
+
+func (m *MockQuerier) MarkFileReady(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(sqlc.File), args.Error(1)
+}
+
+func (m *MockQuerier) CreateShareLink(ctx context.Context, arg sqlc.CreateShareLinkParams) (sqlc.ShareLink, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.ShareLink), args.Error(1)
+}
+
+func (m *MockQuerier) CountShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) (int64, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) CountShareLinkDownload(ctx context.Context, id pgtype.UUID) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetShareLink(ctx context.Context, linkID string) (sqlc.GetShareLinkRow, error) {
+	args := m.Called(ctx, linkID)
+	return args.Get(0).(sqlc.GetShareLinkRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListShareLinksByFileId(ctx context.Context, fileID pgtype.UUID) ([]sqlc.ShareLink, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).([]sqlc.ShareLink), args.Error(1)
+}
+
+func (m *MockQuerier) RevokeFile(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(sqlc.File), args.Error(1)
+}
+
+func (m *MockQuerier) RevokeShareLink(ctx context.Context, arg sqlc.RevokeShareLinkParams) (sqlc.ShareLink, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.ShareLink), args.Error(1)
+}
+
+func (m *MockQuerier) StartFileVerification(ctx context.Context, id pgtype.UUID) (sqlc.File, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(sqlc.File), args.Error(1)
+}
+
+func (m *MockQuerier) ResetStaleVerifications(ctx context.Context, startedBefore pgtype.Timestamptz) (int64, error) {
+	args := m.Called(ctx, startedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) MarkUnpurgedFileCorrupt(ctx context.Context, id pgtype.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockQuerier) CreateFileWebhook(ctx context.Context, arg sqlc.CreateFileWebhookParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetFileWebhookByFileId(ctx context.Context, fileID pgtype.UUID) (sqlc.FileWebhook, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).(sqlc.FileWebhook), args.Error(1)
+}
+
+func (m *MockQuerier) ForceExpireFile(ctx context.Context, shareID string) (pgtype.UUID, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).(pgtype.UUID), args.Error(1)
+}
+
+func (m *MockQuerier) GetStorageTotals(ctx context.Context) ([]sqlc.GetStorageTotalsRow, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]sqlc.GetStorageTotalsRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListAdminFiles(ctx context.Context, arg sqlc.ListAdminFilesParams) ([]sqlc.ListAdminFilesRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]sqlc.ListAdminFilesRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetStoredBytes(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) CountActiveSharesByUploader(ctx context.Context, uploaderIp netip.Addr) (int64, error) {
+	args := m.Called(ctx, uploaderIp)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) CountUnfinishedUploads(ctx context.Context, arg sqlc.CountUnfinishedUploadsParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetUploadSquatter(ctx context.Context, ip netip.Addr) (sqlc.UploadSquatter, error) {
+	args := m.Called(ctx, ip)
+	return args.Get(0).(sqlc.UploadSquatter), args.Error(1)
+}
+
+func (m *MockQuerier) FlagUploadSquatter(ctx context.Context, arg sqlc.FlagUploadSquatterParams) (sqlc.UploadSquatter, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.UploadSquatter), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteExpiredUploadSquatters(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) GetUploaderUsage(ctx context.Context, arg sqlc.GetUploaderUsageParams) (sqlc.GetUploaderUsageRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.GetUploaderUsageRow), args.Error(1)
+}
+
+func (m *MockQuerier) GetWatermarkByShareId(ctx context.Context, shareID string) (pgtype.Text, error) {
+	args := m.Called(ctx, shareID)
+	return args.Get(0).(pgtype.Text), args.Error(1)
+}
+
+func (m *MockQuerier) UpdateServerEncryptedFileWatermark(ctx context.Context, arg sqlc.UpdateServerEncryptedFileWatermarkParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuerier) ListDailyTransferTotals(ctx context.Context, since pgtype.Date) ([]sqlc.ListDailyTransferTotalsRow, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).([]sqlc.ListDailyTransferTotalsRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListTopTransferFiles(ctx context.Context, arg sqlc.ListTopTransferFilesParams) ([]sqlc.ListTopTransferFilesRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]sqlc.ListTopTransferFilesRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListEvictionCandidates(ctx context.Context, limit int32) ([]sqlc.ListEvictionCandidatesRow, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]sqlc.ListEvictionCandidatesRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListFileWebhooksByFileIds(ctx context.Context, fileIDs []pgtype.UUID) ([]sqlc.ListFileWebhooksByFileIdsRow, error) {
+	args := m.Called(ctx, fileIDs)
+	return args.Get(0).([]sqlc.ListFileWebhooksByFileIdsRow), args.Error(1)
+}
+
+func (m *MockQuerier) ListFeatureFlags(ctx context.Context) ([]sqlc.FeatureFlag, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]sqlc.FeatureFlag), args.Error(1)
+}
+
+func (m *MockQuerier) UpsertFeatureFlag(ctx context.Context, arg sqlc.UpsertFeatureFlagParams) (sqlc.FeatureFlag, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.FeatureFlag), args.Error(1)
+}
+
+func (m *MockQuerier) ListBlockedNetworks(ctx context.Context) ([]sqlc.BlockedNetwork, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]sqlc.BlockedNetwork), args.Error(1)
+}
+
+func (m *MockQuerier) UpsertBlockedNetwork(ctx context.Context, arg sqlc.UpsertBlockedNetworkParams) (sqlc.BlockedNetwork, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(sqlc.BlockedNetwork), args.Error(1)
+}
+
+func (m *MockQuerier) DeleteBlockedNetwork(ctx context.Context, network netip.Prefix) (int64, error) {
+	args := m.Called(ctx, network)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func createValidRequest() types.InitUploadRequest {
 	// 1MB file, 256KB chunks = ceil(1MB/256KB) = 4 chunks
 	return types.InitUploadRequest{
@@ -115,8 +474,7 @@ func createValidRequest() types.InitUploadRequest {
 
 func TestInitFileUpload_Success(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	mockTxRunner := mockTxRunner
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	req := createValidRequest()
 	ctx := context.Background()
@@ -135,82 +493,1082 @@ func TestInitFileUpload_Success(t *testing.T) {
 	assert.NotEmpty(t, resp.FileID)
 	assert.NotEmpty(t, resp.ShareID)
 	assert.NotEmpty(t, resp.UploadToken)
+	assert.Equal(t, config.DefaultTransfer().UploadConcurrency, resp.UploadConcurrency)
 	assert.NotEmpty(t, resp.ExpiresAt)
 
-	assert.Len(t, resp.ShareID, 12)
+	assert.Len(t, resp.ShareID, 12)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_WithDefaults(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.MaxDownloads = 0
+	req.ExpiresInHours = 0
+
+	ctx := context.Background()
+	clientIP := "192.168.1.1"
+
+	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Run(func(args mock.Arguments) {
+			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+		}).
+		Return(sqlc.File{}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, clientIP)
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	assert.Equal(t, int32(5), capturedParams.MaxDownloads) // Default is 5
+
+	expiryTime, parseErr := time.Parse(time.RFC3339, resp.ExpiresAt)
+	require.NoError(t, parseErr)
+	expectedExpiry := time.Now().Add(72 * time.Hour)
+	assert.WithinDuration(t, expectedExpiry, expiryTime, 5*time.Second)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_RegistersWebhook(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	inTx := false
+	service := NewFileService(mockRepo, trackingTxRunner(mockRepo, &inTx), nil, config.DefaultLimits()).
+		WithNotifier(notify.New(false))
+
+	req := createValidRequest()
+	req.WebhookURL = "https://hooks.example.com/gzln"
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{ID: fileID}, nil)
+	var registered sqlc.CreateFileWebhookParams
+	mockRepo.On("CreateFileWebhook", ctx, mock.AnythingOfType("sqlc.CreateFileWebhookParams")).
+		Run(func(args mock.Arguments) {
+			assert.True(t, inTx, "the webhook is stored in the file record's transaction")
+			registered = args.Get(1).(sqlc.CreateFileWebhookParams)
+		}).
+		Return(int64(1), nil)
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Equal(t, fileID, registered.FileID)
+	assert.Equal(t, req.WebhookURL, registered.Url)
+	assert.Len(t, resp.WebhookSecret, 64)
+	assert.Equal(t, resp.WebhookSecret, registered.Secret)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_RejectsWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		req := createValidRequest()
+		req.WebhookURL = "https://hooks.example.com/gzln"
+
+		_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		assert.ErrorIs(t, err, ErrWebhooksDisabled)
+		mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("private address", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithNotifier(notify.New(false))
+
+		req := createValidRequest()
+		req.WebhookURL = "http://169.254.169.254/latest"
+
+		_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		assert.ErrorIs(t, err, notify.ErrPrivateAddress)
+		mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+}
+
+func TestInitFileUpload_ReturnURL(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.ReturnURL = "https://tickets.example.com/42?step=review"
+
+	ctx := context.Background()
+	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Run(func(args mock.Arguments) {
+			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+		}).
+		Return(sqlc.File{}, nil)
+
+	_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Equal(t, pgtype.Text{String: req.ReturnURL, Valid: true}, capturedParams.ReturnUrl)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_RejectsReturnURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		returnURL string
+	}{
+		{name: "scheme not allowed", returnURL: "http://tickets.example.com/42"},
+		{name: "javascript", returnURL: "javascript:alert(1)"},
+		{name: "relative", returnURL: "/tickets/42"},
+		{name: "credentials", returnURL: "https://tickets.example.com@evil.example.net/"},
+		{name: "no host", returnURL: "https:///tickets/42"},
+		{name: "too long", returnURL: "https://tickets.example.com/" + strings.Repeat("a", MaxReturnURLLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+			req := createValidRequest()
+			req.ReturnURL = tt.returnURL
+
+			_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+			assert.ErrorIs(t, err, ErrInvalidReturnURL)
+			mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("configured scheme", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithReturnURLSchemes([]string{"https", "ms-teams"})
+
+		req := createValidRequest()
+		req.ReturnURL = "ms-teams:/l/chat/0/0?users=alice@example.com"
+		mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Return(sqlc.File{}, nil)
+
+		_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+		require.NoError(t, err)
+	})
+}
+
+func TestInitFileUpload_Alias(t *testing.T) {
+	t.Run("stores the alias", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		req := createValidRequest()
+		req.Alias = "q3-report"
+
+		ctx := context.Background()
+		var capturedParams sqlc.CreateFileParams
+		mockRepo.On("ShareNameTaken", ctx, "q3-report").Return(false, nil)
+		mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Run(func(args mock.Arguments) {
+				capturedParams = args.Get(1).(sqlc.CreateFileParams)
+			}).
+			Return(sqlc.File{}, nil)
+
+		resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "q3-report", resp.Alias)
+		assert.Equal(t, pgtype.Text{String: "q3-report", Valid: true}, capturedParams.Alias)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("rejects malformed aliases", func(t *testing.T) {
+		for _, alias := range []string{"ab", "Q3-Report", "-q3", "q3-", "q3_report", strings.Repeat("a", MaxAliasLength+1)} {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+			req := createValidRequest()
+			req.Alias = alias
+
+			_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+			assert.ErrorIs(t, err, ErrInvalidAlias, alias)
+			mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("taken", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		req := createValidRequest()
+		req.Alias = "q3-report"
+		mockRepo.On("ShareNameTaken", mock.Anything, "q3-report").Return(true, nil)
+
+		_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+		assert.ErrorIs(t, err, ErrAliasTaken)
+		mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("taken concurrently", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		req := createValidRequest()
+		req.Alias = "q3-report"
+		mockRepo.On("ShareNameTaken", mock.Anything, "q3-report").Return(false, nil)
+		mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Return(sqlc.File{}, database.Classify(&pgconn.PgError{Code: "23505", ConstraintName: "idx_files_alias"}))
+
+		_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+		assert.ErrorIs(t, err, ErrAliasTaken)
+	})
+}
+
+func TestInitFileUpload_ShareIDCollision(t *testing.T) {
+	collision := database.Classify(&pgconn.PgError{Code: "23505", ConstraintName: "files_share_id_key"})
+
+	t.Run("retries with a new share ID", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		var shareIDs []string
+		mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Run(func(args mock.Arguments) {
+				shareIDs = append(shareIDs, args.Get(1).(sqlc.CreateFileParams).ShareID)
+			}).
+			Return(sqlc.File{}, collision).Twice()
+		mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Run(func(args mock.Arguments) {
+				shareIDs = append(shareIDs, args.Get(1).(sqlc.CreateFileParams).ShareID)
+			}).
+			Return(sqlc.File{}, nil).Once()
+
+		resp, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+		require.NoError(t, err)
+		require.Len(t, shareIDs, 3)
+		assert.NotEqual(t, shareIDs[0], shareIDs[1])
+		assert.Equal(t, shareIDs[2], resp.ShareID)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+		mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Return(sqlc.File{}, collision)
+
+		_, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+		assert.ErrorIs(t, err, ErrShareIDExhausted)
+		mockRepo.AssertNumberOfCalls(t, "CreateFile", maxShareIDAttempts)
+	})
+}
+
+func TestResolveAlias(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	mockRepo.On("GetShareIDByAlias", ctx, pgtype.Text{String: "q3-report", Valid: true}).Return("abc123def456", nil)
+	mockRepo.On("GetShareIDByAlias", ctx, pgtype.Text{String: "gone", Valid: true}).Return("", database.ErrNotFound)
+
+	shareID, err := service.ResolveAlias(ctx, "q3-report")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123def456", shareID)
+
+	_, err = service.ResolveAlias(ctx, "gone")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInitFileUpload_ServerEncryption(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("flags the file", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		sealer, err := crypto.NewSealer(make([]byte, crypto.SealerKeySize))
+		require.NoError(t, err)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithServerEncryption(sealer)
+
+		req := createValidRequest()
+		req.Encryption = types.EncryptionServer
+		req.Salt = ""
+		req.Pbkdf2Iterations = 0
+		fileID := createTestUUID()
+
+		mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Return(sqlc.File{ID: fileID}, nil)
+		mockRepo.On("CreateServerEncryptedFile", ctx, sqlc.CreateServerEncryptedFileParams{
+			FileID: fileID,
+			KeyID:  sealer.KeyID(),
+		}).Return(nil)
+
+		_, err = service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("flag failure fails the init", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		sealer, err := crypto.NewSealer(make([]byte, crypto.SealerKeySize))
+		require.NoError(t, err)
+		inTx := false
+		service := NewFileService(mockRepo, trackingTxRunner(mockRepo, &inTx), nil, config.DefaultLimits()).
+			WithServerEncryption(sealer)
+
+		req := createValidRequest()
+		req.Encryption = types.EncryptionServer
+		req.Salt = ""
+		req.Pbkdf2Iterations = 0
+
+		mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+			Return(sqlc.File{ID: createTestUUID()}, nil)
+		mockRepo.On("CreateServerEncryptedFile", ctx, mock.AnythingOfType("sqlc.CreateServerEncryptedFileParams")).
+			Run(func(mock.Arguments) {
+				assert.True(t, inTx, "the flag is written in the file record's transaction")
+			}).
+			Return(errors.New("connection reset"))
+
+		resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		require.Error(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+		req := createValidRequest()
+		req.Encryption = types.EncryptionServer
+
+		_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+		assert.ErrorIs(t, err, ErrServerEncryptionOff)
+		mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	})
+}
+
+func TestInitFileUpload_ConfiguredDefaults(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	limits := config.DefaultLimits()
+	limits.DefaultMaxDownloads = 2
+	limits.DefaultExpiry = 6 * time.Hour
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, limits)
+
+	req := createValidRequest()
+	req.MaxDownloads = 0
+	req.ExpiresInHours = 0
+
+	ctx := context.Background()
+
+	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Run(func(args mock.Arguments) {
+			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+		}).
+		Return(sqlc.File{}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), capturedParams.MaxDownloads)
+
+	expiryTime, parseErr := time.Parse(time.RFC3339, resp.ExpiresAt)
+	require.NoError(t, parseErr)
+	assert.WithinDuration(t, time.Now().Add(6*time.Hour), expiryTime, 5*time.Second)
+}
+
+func TestInitFileUpload_ConfiguredMaxFileSize(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	limits := config.DefaultLimits()
+	limits.MaxFileSize = 512 * 1024
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, limits)
+
+	resp, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+	require.Error(t, err)
+	assert.Nil(t, resp)
+	assert.Contains(t, err.Error(), "exceeds maximum")
+	mockRepo.AssertNotCalled(t, "CreateFile")
+}
+
+func TestInitFileUpload_CustomMaxDownloadsAndExpiry(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.MaxDownloads = 5
+	req.ExpiresInHours = 24
+
+	ctx := context.Background()
+	clientIP := "192.168.1.1"
+
+	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Run(func(args mock.Arguments) {
+			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+		}).
+		Return(sqlc.File{}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, clientIP)
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	assert.Equal(t, int32(5), capturedParams.MaxDownloads)
+
+	expiryTime, parseErr := time.Parse(time.RFC3339, resp.ExpiresAt)
+	require.NoError(t, parseErr)
+	expectedExpiry := time.Now().Add(24 * time.Hour)
+	assert.WithinDuration(t, expectedExpiry, expiryTime, 5*time.Second)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func testUploadSlots() config.UploadSlots {
+	return config.UploadSlots{
+		APIKeys: []string{"backend-api-key-0123456789abcdef0123"},
+		TTL:     15 * time.Minute,
+	}
+}
+
+func TestInitFileUpload_UploadSlot(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithUploadSlots(testUploadSlots())
+
+	req := createValidRequest()
+	req.SlotToken = "slot-token"
+	req.MaxDownloads = 50
+	req.ExpiresInHours = 720
+
+	ctx := context.Background()
+
+	mockRepo.On("GetUploadSlot", ctx, sqlc.GetUploadSlotParams{
+		TokenHash: crypto.HashBytes([]byte("slot-token")),
+		MaxSize:   req.TotalSize,
+	}).Return(sqlc.UploadSlot{ExpiresInHours: 2, MaxDownloads: 1}, nil)
+	mockRepo.On("ConsumeUploadSlot", ctx, sqlc.ConsumeUploadSlotParams{
+		TokenHash: crypto.HashBytes([]byte("slot-token")),
+		MaxSize:   req.TotalSize,
+	}).Return(sqlc.UploadSlot{ExpiresInHours: 2, MaxDownloads: 1}, nil)
+
+	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Run(func(args mock.Arguments) {
+			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+		}).
+		Return(sqlc.File{}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), capturedParams.MaxDownloads, "slot limits override the request")
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), capturedParams.ExpiresAt.Time, 5*time.Second)
+	assert.NotEmpty(t, resp.UploadToken)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_UploadSlotRejected(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithUploadSlots(testUploadSlots())
+
+	req := createValidRequest()
+	req.SlotToken = "used-slot-token"
+
+	ctx := context.Background()
+	mockRepo.On("GetUploadSlot", ctx, mock.AnythingOfType("sqlc.GetUploadSlotParams")).
+		Return(sqlc.UploadSlot{}, database.ErrNotFound)
+
+	_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrInvalidUploadSlot)
+	mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "ConsumeUploadSlot", mock.Anything, mock.Anything)
+}
+
+func TestInitFileUpload_UploadSlotKeptWhenCreateFails(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithUploadSlots(testUploadSlots())
+
+	req := createValidRequest()
+	req.SlotToken = "slot-token"
+
+	ctx := context.Background()
+	mockRepo.On("GetUploadSlot", ctx, mock.AnythingOfType("sqlc.GetUploadSlotParams")).
+		Return(sqlc.UploadSlot{ExpiresInHours: 2, MaxDownloads: 1}, nil)
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{}, errors.New("connection reset"))
+
+	_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ConsumeUploadSlot", mock.Anything, mock.Anything)
+}
+
+func TestInitFileUpload_UploadSlotRedeemedConcurrently(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithUploadSlots(testUploadSlots())
+
+	req := createValidRequest()
+	req.SlotToken = "slot-token"
+
+	ctx := context.Background()
+	mockRepo.On("GetUploadSlot", ctx, mock.AnythingOfType("sqlc.GetUploadSlotParams")).
+		Return(sqlc.UploadSlot{ExpiresInHours: 2, MaxDownloads: 1}, nil)
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{}, nil)
+	mockRepo.On("ConsumeUploadSlot", ctx, mock.AnythingOfType("sqlc.ConsumeUploadSlotParams")).
+		Return(sqlc.UploadSlot{}, database.ErrNotFound)
+
+	_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrInvalidUploadSlot)
+}
+
+func TestInitFileUpload_UploadSlotsDisabled(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.SlotToken = "slot-token"
+
+	_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrUploadSlotsDisabled)
+	mockRepo.AssertNotCalled(t, "ConsumeUploadSlot", mock.Anything, mock.Anything)
+}
+
+func TestCreateUploadSlot_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithUploadSlots(testUploadSlots())
+
+	ctx := context.Background()
+
+	var captured sqlc.CreateUploadSlotParams
+	mockRepo.On("CreateUploadSlot", ctx, mock.AnythingOfType("sqlc.CreateUploadSlotParams")).
+		Run(func(args mock.Arguments) {
+			captured = args.Get(1).(sqlc.CreateUploadSlotParams)
+		}).
+		Return(nil)
+
+	slot, err := service.CreateUploadSlot(ctx, testUploadSlots().APIKeys[0], types.CreateUploadSlotRequest{
+		MaxSize:        10 << 20,
+		ExpiresInHours: 24,
+		MaxDownloads:   config.UnlimitedDownloads,
+	})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, slot.SlotToken)
+	assert.Equal(t, int64(10<<20), slot.MaxSize)
+	assert.Equal(t, crypto.HashBytes([]byte(slot.SlotToken)), captured.TokenHash, "only the token hash is stored")
+	assert.Equal(t, int32(24), captured.ExpiresInHours)
+	assert.Equal(t, int32(config.UnlimitedDownloads), captured.MaxDownloads)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), captured.ExpiresAt.Time, 5*time.Second)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateUploadSlot_Errors(t *testing.T) {
+	valid := types.CreateUploadSlotRequest{MaxSize: 1 << 20}
+
+	tests := []struct {
+		name    string
+		slots   config.UploadSlots
+		apiKey  string
+		req     types.CreateUploadSlotRequest
+		wantErr error
+	}{
+		{name: "disabled", apiKey: "any-key", req: valid, wantErr: ErrUploadSlotsDisabled},
+		{name: "wrong API key", slots: testUploadSlots(), apiKey: "wrong-key", req: valid, wantErr: ErrInvalidAPIKey},
+		{
+			name:    "zero max size",
+			slots:   testUploadSlots(),
+			apiKey:  testUploadSlots().APIKeys[0],
+			req:     types.CreateUploadSlotRequest{},
+			wantErr: ErrInvalidSlotRequest,
+		},
+		{
+			name:    "max size above limit",
+			slots:   testUploadSlots(),
+			apiKey:  testUploadSlots().APIKeys[0],
+			req:     types.CreateUploadSlotRequest{MaxSize: config.DefaultLimits().MaxFileSize + 1},
+			wantErr: ErrInvalidSlotRequest,
+		},
+		{
+			name:    "invalid max downloads",
+			slots:   testUploadSlots(),
+			apiKey:  testUploadSlots().APIKeys[0],
+			req:     types.CreateUploadSlotRequest{MaxSize: 1 << 20, MaxDownloads: -5},
+			wantErr: ErrInvalidSlotRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithUploadSlots(tt.slots)
+
+			_, err := service.CreateUploadSlot(context.Background(), tt.apiKey, tt.req)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "CreateUploadSlot", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// fakeReclaimer reports each ReclaimSpace call on needed, and blocks it
+// until release is closed if release is set.
+type fakeReclaimer struct {
+	freed   int64
+	needed  chan int64
+	release chan struct{}
+}
+
+func newFakeReclaimer(freed int64) *fakeReclaimer {
+	return &fakeReclaimer{freed: freed, needed: make(chan int64, 10)}
+}
+
+func (r *fakeReclaimer) ReclaimSpace(_ context.Context, bytes int64) (int64, error) {
+	r.needed <- bytes
+	if r.release != nil {
+		<-r.release
+	}
+	return r.freed, nil
+}
+
+func TestInitFileUpload_StorageQuota(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     int64
+		reclaimer  *fakeReclaimer
+		wantErr    error
+		wantNeeded int64
+	}{
+		{name: "fits", stored: 1000, reclaimer: newFakeReclaimer(0)},
+		{name: "full without eviction", stored: 3 << 19, wantErr: ErrStorageFull},
+		// The upload that found the quota full is refused even if the
+		// reclaimer could make room; only later uploads benefit
+		{name: "full with eviction", stored: 3 << 19, reclaimer: newFakeReclaimer(1 << 20), wantErr: ErrStorageFull, wantNeeded: 1 << 19},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+			quota := int64(2 << 20)
+			if tt.reclaimer != nil {
+				service.WithStorageQuota(quota, tt.reclaimer)
+			} else {
+				service.WithStorageQuota(quota, nil)
+			}
+
+			req := createValidRequest()
+			req.TotalSize = 1 << 20
+			req.ChunkCount = 1
+			req.ChunkSize = 1 << 20
+
+			mockRepo.On("GetStoredBytes", mock.Anything).Return(tt.stored, nil)
+			mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+				Return(sqlc.File{ID: createTestUUID()}, nil).Maybe()
+
+			_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantNeeded != 0 {
+				select {
+				case needed := <-tt.reclaimer.needed:
+					assert.Equal(t, tt.wantNeeded, needed)
+				case <-time.After(time.Second):
+					t.Fatal("reclaimer was not called")
+				}
+			} else if tt.reclaimer != nil {
+				assert.Empty(t, tt.reclaimer.needed)
+			}
+		})
+	}
+}
+
+func TestInitFileUpload_StorageQuotaReclaimsOnce(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	reclaimer := newFakeReclaimer(1 << 20)
+	reclaimer.release = make(chan struct{})
+	defer close(reclaimer.release)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithStorageQuota(2<<20, reclaimer)
+
+	req := createValidRequest()
+	req.TotalSize = 1 << 20
+	req.ChunkCount = 1
+	req.ChunkSize = 1 << 20
+	mockRepo.On("GetStoredBytes", mock.Anything).Return(int64(3<<19), nil)
+
+	for range 3 {
+		_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+		assert.ErrorIs(t, err, ErrStorageFull)
+	}
+
+	<-reclaimer.needed
+	assert.Empty(t, reclaimer.needed, "uploads rejected while a reclaim runs do not start another")
+}
+
+func TestInitFileUpload_LargerThanStorageQuota(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	reclaimer := newFakeReclaimer(1 << 30)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithStorageQuota(1024, reclaimer)
+
+	_, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrStorageFull)
+	assert.Empty(t, reclaimer.needed, "nothing is evicted for an upload that can never fit")
+}
+
+func TestInitFileUpload_UploadQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   config.UploadQuota
+		usage   sqlc.GetUploaderUsageRow
+		wantErr bool
+	}{
+		{name: "under both limits", quota: config.UploadQuota{DailyCount: 5, DailyBytes: 10 << 20}, usage: sqlc.GetUploaderUsageRow{UploadCount: 4, TotalBytes: 9 << 20}},
+		{name: "count used up", quota: config.UploadQuota{DailyCount: 5}, usage: sqlc.GetUploaderUsageRow{UploadCount: 5}, wantErr: true},
+		{name: "bytes would be exceeded", quota: config.UploadQuota{DailyBytes: 10 << 20}, usage: sqlc.GetUploaderUsageRow{UploadCount: 1, TotalBytes: 9<<20 + 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithUploadQuota(tt.quota)
+
+			req := createValidRequest()
+			dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+
+			mockRepo.On("GetUploaderUsage", mock.Anything, mock.MatchedBy(func(arg sqlc.GetUploaderUsageParams) bool {
+				return arg.UploaderIp == netip.MustParseAddr("192.168.1.1") && arg.Since.Time.Equal(dayStart)
+			})).Return(tt.usage, nil)
+			mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+				Return(sqlc.File{ID: createTestUUID()}, nil).Maybe()
+
+			_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			var quotaErr *UploadQuotaError
+			require.ErrorAs(t, err, &quotaErr)
+			assert.Equal(t, dayStart.Add(24*time.Hour), quotaErr.ResetsAt)
+			assert.Equal(t, tt.usage.UploadCount, quotaErr.Details.UsedCount)
+			assert.Equal(t, tt.usage.TotalBytes, quotaErr.Details.UsedBytes)
+			assert.Equal(t, req.TotalSize, quotaErr.Details.RequestSize)
+			mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestInitFileUpload_ActiveShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  int64
+		wantErr bool
+	}{
+		{name: "under the limit", active: 2},
+		{name: "limit reached", active: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithUploadQuota(config.UploadQuota{ActiveShares: 3})
+
+			mockRepo.On("CountActiveSharesByUploader", mock.Anything, netip.MustParseAddr("192.168.1.1")).Return(tt.active, nil)
+			mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+				Return(sqlc.File{ID: createTestUUID()}, nil).Maybe()
+
+			_, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrTooManyActiveShares)
+			mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestInitFileUpload_UploadSquatting(t *testing.T) {
+	squatting := config.UploadSquatting{
+		Threshold: 3,
+		Window:    24 * time.Hour,
+		Grace:     time.Hour,
+		Penalty:   24 * time.Hour,
+		Action:    config.SquatActionLimit,
+	}
+	clientIP := netip.MustParseAddr("192.168.1.1")
+	// The flagging count leaves out uploads started within the grace period
+	pastGrace := mock.MatchedBy(func(arg sqlc.CountUnfinishedUploadsParams) bool {
+		return arg.UploaderIp == clientIP && arg.Before.Time.Before(time.Now().Add(-30*time.Minute))
+	})
+	inProgress := mock.MatchedBy(func(arg sqlc.CountUnfinishedUploadsParams) bool {
+		return arg.UploaderIp == clientIP && arg.Before.Time.After(time.Now().Add(-time.Minute))
+	})
+	flagged := sqlc.UploadSquatter{Ip: clientIP, FlaggedUntil: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}}
+
+	tests := []struct {
+		name       string
+		action     string
+		flagged    bool
+		unfinished int64
+		pending    int64
+		wantFlag   bool
+		wantErr    error
+	}{
+		{name: "below threshold", unfinished: 2},
+		{name: "flagged on reaching threshold", unfinished: 3, pending: 3, wantFlag: true, wantErr: ErrUnfinishedUploads},
+		{name: "flagged without uploads in progress", flagged: true},
+		{name: "flagged with an upload in progress", flagged: true, pending: 1, wantErr: ErrUnfinishedUploads},
+		{name: "flagged and challenged", action: config.SquatActionChallenge, flagged: true, wantErr: ErrUploadChallenged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sq := squatting
+			if tt.action != "" {
+				sq.Action = tt.action
+			}
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithUploadSquatting(sq)
+
+			if tt.flagged {
+				mockRepo.On("GetUploadSquatter", mock.Anything, clientIP).Return(flagged, nil)
+			} else {
+				mockRepo.On("GetUploadSquatter", mock.Anything, clientIP).Return(sqlc.UploadSquatter{}, database.ErrNotFound)
+				mockRepo.On("CountUnfinishedUploads", mock.Anything, pastGrace).Return(tt.unfinished, nil).Once()
+			}
+			mockRepo.On("CountUnfinishedUploads", mock.Anything, inProgress).Return(tt.pending, nil).Maybe()
+			mockRepo.On("FlagUploadSquatter", mock.Anything, mock.MatchedBy(func(arg sqlc.FlagUploadSquatterParams) bool {
+				return arg.Ip == clientIP && arg.UnfinishedUploads == int32(tt.unfinished)
+			})).Return(flagged, nil).Maybe()
+			mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+				Return(sqlc.File{ID: createTestUUID()}, nil).Maybe()
+
+			_, err := service.InitFileUpload(context.Background(), createValidRequest(), "192.168.1.1")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantFlag {
+				mockRepo.AssertCalled(t, "FlagUploadSquatter", mock.Anything, mock.Anything)
+			} else {
+				mockRepo.AssertNotCalled(t, "FlagUploadSquatter", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUploadQuotaUsage(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	usage, err := service.UploadQuotaUsage(ctx, "192.168.1.1")
+	require.NoError(t, err)
+	assert.Nil(t, usage, "no quota is enforced")
 
-	mockRepo.AssertExpectations(t)
+	service.WithUploadQuota(config.UploadQuota{DailyCount: 5, DailyBytes: 10 << 20})
+	mockRepo.On("GetUploaderUsage", ctx, mock.AnythingOfType("sqlc.GetUploaderUsageParams")).
+		Return(sqlc.GetUploaderUsageRow{UploadCount: 6, TotalBytes: 4 << 20}, nil)
+
+	usage, err = service.UploadQuotaUsage(ctx, "192.168.1.1")
+	require.NoError(t, err)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(0), usage.RemainingCount())
+	assert.Equal(t, int64(6<<20), usage.RemainingBytes())
 }
 
-func TestInitFileUpload_WithDefaults(t *testing.T) {
+func TestInitFileUpload_Bundle(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	req := createValidRequest()
-	req.MaxDownloads = 0
-	req.ExpiresInHours = 0
+	req.BundleToken = "bundle-token"
+	req.ExpiresInHours = 720
 
 	ctx := context.Background()
-	clientIP := "192.168.1.1"
+	bundleID := createTestUUID()
+	bundleExpiry := time.Now().Add(3 * time.Hour)
+	fileID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+
+	mockRepo.On("GetFileBundleByTokenHash", ctx, crypto.HashBytes([]byte("bundle-token"))).
+		Return(sqlc.FileBundle{ID: bundleID, ShareID: "bundle-share", ExpiresAt: pgtype.Timestamptz{Time: bundleExpiry, Valid: true}}, nil)
+	mockRepo.On("CountBundleFiles", ctx, bundleID).Return(int64(2), nil)
 
 	var capturedParams sqlc.CreateFileParams
 	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
 		Run(func(args mock.Arguments) {
 			capturedParams = args.Get(1).(sqlc.CreateFileParams)
 		}).
-		Return(sqlc.File{}, nil)
+		Return(sqlc.File{ID: fileID}, nil)
+	mockRepo.On("AddBundleFile", ctx, sqlc.AddBundleFileParams{FileID: fileID, BundleID: bundleID}).Return(nil)
 
-	resp, err := service.InitFileUpload(ctx, req, clientIP)
+	_, err := service.InitFileUpload(ctx, req, "192.168.1.1")
 
 	require.NoError(t, err)
-	require.NotNil(t, resp)
-
-	assert.Equal(t, int32(5), capturedParams.MaxDownloads) // Default is 5
-
-	expiryTime, parseErr := time.Parse(time.RFC3339, resp.ExpiresAt)
-	require.NoError(t, parseErr)
-	expectedExpiry := time.Now().Add(72 * time.Hour)
-	assert.WithinDuration(t, expectedExpiry, expiryTime, 5*time.Second)
-
+	assert.True(t, bundleExpiry.Equal(capturedParams.ExpiresAt.Time), "files expire with their bundle")
 	mockRepo.AssertExpectations(t)
 }
 
-func TestInitFileUpload_CustomMaxDownloadsAndExpiry(t *testing.T) {
+func TestInitFileUpload_BundleAddFails(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	inTx := false
+	service := NewFileService(mockRepo, trackingTxRunner(mockRepo, &inTx), nil, config.DefaultLimits())
 
 	req := createValidRequest()
-	req.MaxDownloads = 5
-	req.ExpiresInHours = 24
+	req.BundleToken = "bundle-token"
 
 	ctx := context.Background()
-	clientIP := "192.168.1.1"
+	bundleID := createTestUUID()
 
-	var capturedParams sqlc.CreateFileParams
+	mockRepo.On("GetFileBundleByTokenHash", ctx, mock.Anything).
+		Return(sqlc.FileBundle{ID: bundleID, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}}, nil)
+	mockRepo.On("CountBundleFiles", ctx, bundleID).Return(int64(0), nil)
 	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{ID: createTestUUID()}, nil)
+	mockRepo.On("AddBundleFile", ctx, mock.AnythingOfType("sqlc.AddBundleFileParams")).
+		Run(func(mock.Arguments) {
+			assert.True(t, inTx, "the bundle entry is written in the file record's transaction")
+		}).
+		Return(errors.New("connection reset"))
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.Error(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestInitFileUpload_BundleRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(m *MockQuerier)
+		wantErr error
+	}{
+		{
+			name: "unknown or expired bundle",
+			setup: func(m *MockQuerier) {
+				m.On("GetFileBundleByTokenHash", mock.Anything, mock.Anything).Return(sqlc.FileBundle{}, database.ErrNotFound)
+			},
+			wantErr: ErrInvalidBundleToken,
+		},
+		{
+			name: "full bundle",
+			setup: func(m *MockQuerier) {
+				m.On("GetFileBundleByTokenHash", mock.Anything, mock.Anything).Return(sqlc.FileBundle{ID: createTestUUID()}, nil)
+				m.On("CountBundleFiles", mock.Anything, mock.Anything).Return(int64(MaxBundleFiles), nil)
+			},
+			wantErr: ErrBundleFull,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			tt.setup(mockRepo)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+			req := createValidRequest()
+			req.BundleToken = "bundle-token"
+
+			_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "CreateFile", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateBundle(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+	ctx := context.Background()
+
+	var captured sqlc.CreateFileBundleParams
+	mockRepo.On("CreateFileBundle", ctx, mock.AnythingOfType("sqlc.CreateFileBundleParams")).
 		Run(func(args mock.Arguments) {
-			capturedParams = args.Get(1).(sqlc.CreateFileParams)
+			captured = args.Get(1).(sqlc.CreateFileBundleParams)
 		}).
-		Return(sqlc.File{}, nil)
+		Return(sqlc.FileBundle{ShareID: "bundle-share"}, nil)
 
-	resp, err := service.InitFileUpload(ctx, req, clientIP)
+	bundle, err := service.CreateBundle(ctx, types.CreateBundleRequest{ExpiresInHours: 6})
 
 	require.NoError(t, err)
-	require.NotNil(t, resp)
+	assert.Equal(t, "bundle-share", bundle.ShareID)
+	assert.Equal(t, crypto.HashBytes([]byte(bundle.BundleToken)), captured.TokenHash, "only the token hash is stored")
+	assert.WithinDuration(t, time.Now().Add(6*time.Hour), captured.ExpiresAt.Time, 5*time.Second)
 
-	assert.Equal(t, int32(5), capturedParams.MaxDownloads)
+	_, err = service.CreateBundle(ctx, types.CreateBundleRequest{ExpiresInHours: -1})
+	assert.ErrorIs(t, err, ErrInvalidBundleRequest)
+}
 
-	expiryTime, parseErr := time.Parse(time.RFC3339, resp.ExpiresAt)
-	require.NoError(t, parseErr)
-	expectedExpiry := time.Now().Add(24 * time.Hour)
-	assert.WithinDuration(t, expectedExpiry, expiryTime, 5*time.Second)
+func TestGetBundleManifest(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+	ctx := context.Background()
+	bundleID := createTestUUID()
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
 
-	mockRepo.AssertExpectations(t)
+	mockRepo.On("GetFileBundleByShareId", ctx, "bundle-share").
+		Return(sqlc.FileBundle{ID: bundleID, ShareID: "bundle-share", ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true}}, nil)
+	mockRepo.On("ListReadyBundleFiles", ctx, bundleID).
+		Return([]sqlc.ListReadyBundleFilesRow{
+			{ShareID: "file-one", EncryptedFilename: "name-1", EncryptedMimeType: "mime-1", TotalSize: 10, ChunkCount: 1},
+			{ShareID: "file-two", EncryptedFilename: "name-2", EncryptedMimeType: "mime-2", TotalSize: 20, ChunkCount: 2},
+		}, nil)
+	mockRepo.On("GetFileBundleByShareId", ctx, "gone").Return(sqlc.FileBundle{}, database.ErrNotFound)
+
+	manifest, err := service.GetBundleManifest(ctx, "bundle-share")
+
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-02T03:04:05Z", manifest.ExpiresAt)
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, types.BundleFile{
+		ShareID: "file-two", EncryptedFilename: "name-2", EncryptedMimeType: "mime-2", TotalSize: 20, ChunkCount: 2,
+	}, manifest.Files[1])
+
+	_, err = service.GetBundleManifest(ctx, "gone")
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 func TestInitFileUpload_InvalidIP(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	req := createValidRequest()
 	ctx := context.Background()
@@ -235,7 +1593,7 @@ func TestInitFileUpload_InvalidIP(t *testing.T) {
 
 func TestInitFileUpload_RepositoryError(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	req := createValidRequest()
 	ctx := context.Background()
@@ -255,8 +1613,220 @@ func TestInitFileUpload_RepositoryError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// stubPresigner signs one batch of made up URLs. inTx, if set, is checked
+// to record whether it was called inside a transaction.
+type stubPresigner struct {
+	file       sqlc.File
+	first      int32
+	err        error
+	inTx       *bool
+	calledInTx bool
+}
+
+func (p *stubPresigner) PresignChunkUploads(ctx context.Context, file sqlc.File, first int32) ([]types.PresignedChunkUpload, time.Time, error) {
+	p.file = file
+	p.first = first
+	if p.inTx != nil {
+		p.calledInTx = *p.inTx
+	}
+	if p.err != nil {
+		return nil, time.Time{}, p.err
+	}
+	uploads := make([]types.PresignedChunkUpload, min(file.ChunkCount-first, PresignBatchSize))
+	for i := range uploads {
+		index := first + int32(i)
+		uploads[i] = types.PresignedChunkUpload{ChunkIndex: index, Method: "POST", URL: fmt.Sprintf("https://storage.example.com/%d", index)}
+	}
+	return uploads, time.Now().Add(15 * time.Minute), nil
+}
+
+func TestInitFileUpload_Presigned(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	var inTx bool
+	presigner := &stubPresigner{inTx: &inTx}
+	service := NewFileService(mockRepo, trackingTxRunner(mockRepo, &inTx), nil, config.DefaultLimits()).
+		WithChunkPresigner(presigner)
+
+	req := createValidRequest()
+	req.UploadMode = types.UploadModePresigned
+	ctx := context.Background()
+
+	testFileID := createTestUUID()
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{ID: testFileID, ChunkCount: req.ChunkCount}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Equal(t, testFileID, presigner.file.ID)
+	assert.Zero(t, presigner.first)
+	assert.False(t, presigner.calledInTx, "presigning must not hold the transaction open")
+	assert.Len(t, resp.UploadURLs, int(req.ChunkCount))
+	assert.NotEmpty(t, resp.UploadURLsExpireAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitFileUpload_PresignedFirstBatch(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithChunkPresigner(&stubPresigner{})
+
+	req := createValidRequest()
+	req.UploadMode = types.UploadModePresigned
+	req.ChunkSize = 1 << 20
+	req.ChunkCount = PresignBatchSize * 3
+	req.TotalSize = int64(req.ChunkCount) * int64(req.ChunkSize)
+
+	mockRepo.On("CreateFile", mock.Anything, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{ID: createTestUUID(), ChunkCount: req.ChunkCount}, nil)
+
+	resp, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+	require.NoError(t, err)
+	assert.Len(t, resp.UploadURLs, int(PresignBatchSize))
+}
+
+func TestInitFileUpload_PresignFails(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithChunkPresigner(&stubPresigner{err: errors.New("signing key unavailable")})
+
+	req := createValidRequest()
+	req.UploadMode = types.UploadModePresigned
+	ctx := context.Background()
+
+	mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+		Return(sqlc.File{ID: createTestUUID(), ChunkCount: req.ChunkCount}, nil)
+
+	resp, err := service.InitFileUpload(ctx, req, "192.168.1.1")
+
+	// The file is committed; its URLs can still be fetched batch by batch
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.UploadToken)
+	assert.Empty(t, resp.UploadURLs)
+	assert.Empty(t, resp.UploadURLsExpireAt)
+}
+
+func TestInitFileUpload_PresignedDisabled(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.UploadMode = types.UploadModePresigned
+
+	resp, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrPresignedDisabled)
+	assert.Nil(t, resp)
+	mockRepo.AssertNotCalled(t, "CreateFile")
+}
+
+func TestInitFileUpload_PresignedSwitchedOff(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	provider, err := flags.New(mockRepo, map[string]bool{flags.PresignedUploads: false})
+	require.NoError(t, err)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithChunkPresigner(&stubPresigner{}).
+		WithFlags(provider)
+
+	req := createValidRequest()
+	req.UploadMode = types.UploadModePresigned
+
+	resp, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+	assert.ErrorIs(t, err, ErrPresignedDisabled)
+	assert.Nil(t, resp)
+	mockRepo.AssertNotCalled(t, "CreateFile")
+}
+
+func TestInitFileUpload_UnknownUploadMode(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	req := createValidRequest()
+	req.UploadMode = "carrier-pigeon"
+
+	_, err := service.InitFileUpload(context.Background(), req, "192.168.1.1")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown upload_mode")
+	mockRepo.AssertNotCalled(t, "CreateFile")
+}
+
+type stubScorer struct {
+	decision abuse.Decision
+	err      error
+	req      abuse.Request
+}
+
+func (s *stubScorer) Score(ctx context.Context, req abuse.Request) (abuse.Decision, error) {
+	s.req = req
+	return s.decision, s.err
+}
+
+func TestInitFileUpload_AbuseScoring(t *testing.T) {
+	tests := []struct {
+		name    string
+		scorer  *stubScorer
+		wantErr error
+	}{
+		{name: "allow", scorer: &stubScorer{decision: abuse.Decision{Verdict: abuse.Allow}}},
+		{name: "deny", scorer: &stubScorer{decision: abuse.Decision{Verdict: abuse.Deny}}, wantErr: ErrUploadDenied},
+		{name: "challenge", scorer: &stubScorer{decision: abuse.Decision{Verdict: abuse.Challenge}}, wantErr: ErrUploadChallenged},
+		{name: "scorer failure fails open", scorer: &stubScorer{err: errors.New("reputation service down")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithAbuseScorer(tt.scorer)
+			ctx := abuse.WithMetadata(context.Background(), abuse.Metadata{UserAgent: "test-agent"})
+			if tt.wantErr == nil {
+				mockRepo.On("CreateFile", ctx, mock.AnythingOfType("sqlc.CreateFileParams")).
+					Return(sqlc.File{}, nil)
+			}
+
+			_, err := service.InitFileUpload(ctx, createValidRequest(), "192.0.2.1:51234")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "CreateFile")
+			} else {
+				require.NoError(t, err)
+				mockRepo.AssertExpectations(t)
+			}
+			assert.Equal(t, "192.0.2.1", tt.scorer.req.ClientIP.String())
+			assert.Equal(t, "test-agent", tt.scorer.req.Metadata.UserAgent)
+			assert.Equal(t, createValidRequest().TotalSize, tt.scorer.req.TotalSize)
+		})
+	}
+}
+
+func TestParseClientIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.5":        "203.0.113.5",
+		"203.0.113.5:443":    "203.0.113.5",
+		"[2001:db8::1]:443":  "2001:db8::1",
+		"::ffff:203.0.113.5": "203.0.113.5",
+	}
+
+	for in, want := range tests {
+		addr, err := parseClientIP(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, addr.String(), in)
+	}
+
+	_, err := parseClientIP("invalid-ip-address")
+	assert.Error(t, err)
+
+	// Forwarding headers are resolved by the handler, never here
+	_, err = parseClientIP("203.0.113.5, 10.0.0.1")
+	assert.Error(t, err)
+}
+
 func TestValidateUploadRequest(t *testing.T) {
-	service := NewFileService(nil, nil, nil)
+	service := NewFileService(nil, nil, nil, config.DefaultLimits())
 
 	tests := []struct {
 		name        string
@@ -318,6 +1888,20 @@ func TestValidateUploadRequest(t *testing.T) {
 			req:         func() types.InitUploadRequest { r := createValidRequest(); r.Pbkdf2Iterations = -1000; return r }(),
 			expectError: "pbkdf2_iterations must be positive",
 		},
+		{
+			name:        "negative max downloads",
+			req:         func() types.InitUploadRequest { r := createValidRequest(); r.MaxDownloads = -2; return r }(),
+			expectError: "max_downloads must be positive",
+		},
+		{
+			name: "unlimited max downloads",
+			req: func() types.InitUploadRequest {
+				r := createValidRequest()
+				r.MaxDownloads = config.UnlimitedDownloads
+				return r
+			}(),
+			expectError: "",
+		},
 		{
 			name: "file size exceeds 5GB",
 			req: func() types.InitUploadRequest {
@@ -394,9 +1978,155 @@ func TestValidateUploadRequest(t *testing.T) {
 	}
 }
 
+func TestValidateUploadRequest_LayoutDetailsAccepted(t *testing.T) {
+	limits := config.DefaultLimits()
+	limits.MaxChunkSize = 1 << 20
+	limits.MaxChunkCount = 100
+	service := NewFileService(nil, mockTxRunner, nil, limits)
+
+	requests := map[string]types.InitUploadRequest{
+		"chunk count mismatch": func() types.InitUploadRequest {
+			r := createValidRequest()
+			r.TotalSize = 1024 * 1024
+			r.ChunkSize = 100 * 1024
+			r.ChunkCount = 5
+			return r
+		}(),
+		"chunk size above maximum": func() types.InitUploadRequest {
+			r := createValidRequest()
+			r.TotalSize = 3 << 20
+			r.ChunkSize = 2 << 20
+			r.ChunkCount = 2
+			return r
+		}(),
+		"chunk count above maximum": func() types.InitUploadRequest {
+			r := createValidRequest()
+			r.TotalSize = 10 << 20
+			r.ChunkSize = 64 * 1024
+			r.ChunkCount = 160
+			return r
+		}(),
+	}
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			err := service.validateUploadRequest(req)
+
+			var layoutErr *ChunkLayoutError
+			require.ErrorAs(t, err, &layoutErr)
+
+			// Retrying with the suggested layout must succeed
+			req.ChunkSize = layoutErr.Details.ChunkSize
+			req.ChunkCount = int32(layoutErr.Details.ExpectedChunkCount)
+			assert.NoError(t, service.validateUploadRequest(req))
+			assert.Equal(t, req.TotalSize-int64(req.ChunkCount-1)*int64(req.ChunkSize), layoutErr.Details.ExpectedLastChunkSize)
+		})
+	}
+}
+
+func TestGetUploadAdvice_AcceptedByInit(t *testing.T) {
+	sizes := []int64{1, 256 * 1024, 5 << 20, 5<<20 + 1, 300 << 20, 1 << 30, 5 << 30}
+
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+			ctx := context.Background()
+
+			mockRepo.On("CountActiveUploads", ctx).Return(int64(0), nil)
+
+			advice, err := service.GetUploadAdvice(ctx, size)
+			require.NoError(t, err)
+
+			req := createValidRequest()
+			req.TotalSize = size
+			req.ChunkSize = advice.ChunkSize
+			req.ChunkCount = advice.ChunkCount
+
+			assert.NoError(t, service.validateUploadRequest(req))
+			assert.False(t, advice.Busy)
+			assert.Equal(t, config.DefaultTransfer().UploadConcurrency, advice.UploadConcurrency)
+		})
+	}
+}
+
+func TestGetUploadAdvice_Busy(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("CountActiveUploads", ctx).Return(int64(busyActiveUploads), nil)
+
+	advice, err := service.GetUploadAdvice(ctx, 50<<20)
+
+	require.NoError(t, err)
+	assert.True(t, advice.Busy)
+	assert.Equal(t, int32(10<<20), advice.ChunkSize)
+	assert.Equal(t, int32(5), advice.ChunkCount)
+	assert.Equal(t, config.DefaultTransfer().UploadConcurrency/2, advice.UploadConcurrency)
+}
+
+func TestGetUploadAdvice_CappedAtMaxChunkSize(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	limits := config.DefaultLimits()
+	limits.MaxChunkSize = 1 << 20
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, limits)
+	ctx := context.Background()
+
+	mockRepo.On("CountActiveUploads", ctx).Return(int64(0), nil)
+
+	advice, err := service.GetUploadAdvice(ctx, 10<<20)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1<<20), advice.ChunkSize)
+	assert.Equal(t, int32(10), advice.ChunkCount)
+	assert.Equal(t, int64(1<<20), advice.MaxChunkSize)
+}
+
+func TestGetUploadAdvice_WithinMaxChunkCount(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	limits := config.DefaultLimits()
+	limits.MaxChunkCount = 10
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, limits)
+	ctx := context.Background()
+
+	mockRepo.On("CountActiveUploads", ctx).Return(int64(0), nil)
+
+	advice, err := service.GetUploadAdvice(ctx, 90<<20)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(9<<20), advice.ChunkSize)
+	assert.Equal(t, int32(10), advice.ChunkCount)
+}
+
+func TestGetUploadAdvice_InvalidSize(t *testing.T) {
+	service := NewFileService(new(MockQuerier), mockTxRunner, nil, config.DefaultLimits())
+
+	for _, size := range []int64{0, -1, config.DefaultLimits().MaxFileSize + 1} {
+		_, err := service.GetUploadAdvice(context.Background(), size)
+
+		assert.ErrorIs(t, err, ErrInvalidUploadSize)
+	}
+}
+
+func TestGetUploadAdvice_CountFailureIgnored(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("CountActiveUploads", ctx).Return(int64(0), errors.New("database connection error"))
+
+	advice, err := service.GetUploadAdvice(ctx, 1<<20)
+
+	require.NoError(t, err)
+	assert.False(t, advice.Busy)
+	assert.Equal(t, int32(1<<20), advice.ChunkSize)
+	assert.Equal(t, int32(1), advice.ChunkCount)
+}
+
 func TestGetFileByShareID(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "test-share-id"
@@ -417,7 +2147,7 @@ func TestGetFileByShareID(t *testing.T) {
 
 func TestGetFileByShareID_NotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "non-existent"
@@ -436,7 +2166,7 @@ func TestGetFileByShareID_NotFound(t *testing.T) {
 
 func TestUpdateFileStatus(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -457,7 +2187,7 @@ func TestUpdateFileStatus(t *testing.T) {
 
 func TestUpdateFileStatus_Error(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -475,23 +2205,57 @@ func TestUpdateFileStatus_Error(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGenerateShareID(t *testing.T) {
-	shareID1 := generateShareID()
-	assert.Len(t, shareID1, 12)
+func TestVerifyUploadToken_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, DeletionTokenHash: pgtype.Text{String: "upload-token", Valid: true}}, nil)
 
-	shareID2 := generateShareID()
-	assert.Len(t, shareID2, 12)
-	assert.NotEqual(t, shareID1, shareID2)
+	err := service.VerifyUploadToken(ctx, fileID, "upload-token")
 
-	validChars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	for _, char := range shareID1 {
-		assert.Contains(t, validChars, string(char))
-	}
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyUploadToken_Mismatch(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, DeletionTokenHash: pgtype.Text{String: "upload-token", Valid: true}}, nil)
+
+	err := service.VerifyUploadToken(ctx, fileID, "other-token")
+
+	assert.ErrorIs(t, err, ErrInvalidUploadToken)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyUploadToken_FileNotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	err := service.VerifyUploadToken(ctx, fileID, "upload-token")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	mockRepo.AssertExpectations(t)
 }
 
 func TestFinalizeUpload_Success(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -513,9 +2277,13 @@ func TestFinalizeUpload_Success(t *testing.T) {
 
 	updatedFile := expectedFile
 	updatedFile.Status = "ready"
-	mockRepo.On("UpdateFileStatus", ctx, mock.AnythingOfType("sqlc.UpdateFileStatusParams")).
+	mockRepo.On("MarkFileReady", ctx, fileID).
 		Return(updatedFile, nil)
 
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(arg sqlc.CreateAuditLogEntryParams) bool {
+		return arg.Action == AuditActionFileReady && arg.FileID == fileID
+	})).Return(sqlc.AuditLog{}, nil)
+
 	result, err := service.FinalizeUpload(ctx, fileID)
 
 	require.NoError(t, err)
@@ -526,7 +2294,7 @@ func TestFinalizeUpload_Success(t *testing.T) {
 
 func TestFinalizeUpload_ChunkCountMismatch(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -549,14 +2317,125 @@ func TestFinalizeUpload_ChunkCountMismatch(t *testing.T) {
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "chunk count does not match")
+	assert.ErrorIs(t, err, ErrChunkCountMismatch)
 	assert.Equal(t, types.FinalizeUploadResponse{}, result)
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "UpdateFileStatus")
+	mockRepo.AssertNotCalled(t, "MarkFileReady")
+}
+
+type stubVerifier struct {
+	err    error
+	rehash bool
+}
+
+func (v *stubVerifier) VerifyChunks(ctx context.Context, fileID pgtype.UUID, rehash bool, progress func(int)) error {
+	v.rehash = rehash
+	if v.err == nil && progress != nil {
+		for i := range 2 {
+			progress(i + 1)
+		}
+	}
+	return v.err
+}
+
+func TestFinalizeUpload_VerifyChunks(t *testing.T) {
+	ctx := context.Background()
+	fileID := createTestUUID()
+	uploading := sqlc.File{ID: fileID, ShareID: "abc123def456", ChunkCount: 2, Status: "uploading"}
+
+	t.Run("mismatch marks file corrupt", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		verifier := &stubVerifier{err: fmt.Errorf("%w: chunk 1 hash differs", ErrChunkMismatch)}
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithChunkVerifier(verifier, true)
+
+		mockRepo.On("GetFileByID", ctx, fileID).Return(uploading, nil)
+		mockRepo.On("CountChunksByFileId", ctx, fileID).Return(int64(2), nil)
+		mockRepo.On("UpdateFileStatus", ctx, sqlc.UpdateFileStatusParams{ID: fileID, Status: FileStatusCorrupt}).
+			Return(sqlc.File{}, nil)
+
+		_, err := service.FinalizeUpload(ctx, fileID)
+
+		assert.ErrorIs(t, err, ErrChunkMismatch)
+		assert.True(t, verifier.rehash)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "MarkFileReady", mock.Anything, mock.Anything)
+	})
+
+	t.Run("storage error leaves file uploading", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithChunkVerifier(&stubVerifier{err: errors.New("connection refused")}, false)
+
+		mockRepo.On("GetFileByID", ctx, fileID).Return(uploading, nil)
+		mockRepo.On("CountChunksByFileId", ctx, fileID).Return(int64(2), nil)
+
+		_, err := service.FinalizeUpload(ctx, fileID)
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrChunkMismatch)
+		mockRepo.AssertNotCalled(t, "UpdateFileStatus", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "MarkFileReady", mock.Anything, mock.Anything)
+	})
+
+	t.Run("large file verifies in the background", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		bus := events.NewBus()
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithChunkVerifier(&stubVerifier{}, true).
+			WithAsyncVerification(2).
+			WithEvents(bus)
+		verifying := uploading
+		verifying.Status = FileStatusVerifying
+		ready := uploading
+		ready.Status = "ready"
+
+		changes, stop := bus.Subscribe(uploading.ShareID)
+		defer stop()
+		mockRepo.On("GetFileByID", ctx, fileID).Return(uploading, nil)
+		mockRepo.On("CountChunksByFileId", ctx, fileID).Return(int64(2), nil)
+		mockRepo.On("StartFileVerification", ctx, fileID).Return(verifying, nil)
+		mockRepo.On("MarkFileReady", mock.Anything, fileID).Return(ready, nil)
+		mockRepo.On("CreateAuditLogEntry", mock.Anything, mock.AnythingOfType("sqlc.CreateAuditLogEntryParams")).
+			Return(sqlc.AuditLog{}, nil)
+
+		result, err := service.FinalizeUpload(ctx, fileID)
+
+		require.NoError(t, err)
+		assert.True(t, result.Verifying)
+
+		var seen []string
+		for e := range changes {
+			seen = append(seen, e.Type)
+			if e.Type == events.TypeReady {
+				break
+			}
+		}
+		assert.Equal(t, []string{events.TypeVerifying, events.TypeVerifying, events.TypeReady}, seen)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("finalize while verifying", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithChunkVerifier(&stubVerifier{}, true).
+			WithAsyncVerification(2)
+		verifying := uploading
+		verifying.Status = FileStatusVerifying
+
+		mockRepo.On("GetFileByID", ctx, fileID).Return(verifying, nil)
+
+		result, err := service.FinalizeUpload(ctx, fileID)
+
+		require.NoError(t, err)
+		assert.True(t, result.Verifying)
+		mockRepo.AssertNotCalled(t, "StartFileVerification", mock.Anything, mock.Anything)
+	})
 }
 
 func TestFinalizeUpload_FileNotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -573,12 +2452,12 @@ func TestFinalizeUpload_FileNotFound(t *testing.T) {
 	assert.Equal(t, types.FinalizeUploadResponse{}, result)
 	mockRepo.AssertExpectations(t)
 	mockRepo.AssertNotCalled(t, "CountChunksByFileId")
-	mockRepo.AssertNotCalled(t, "UpdateFileStatus")
+	mockRepo.AssertNotCalled(t, "MarkFileReady")
 }
 
 func TestFinalizeUpload_CountChunksFailed(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -603,12 +2482,12 @@ func TestFinalizeUpload_CountChunksFailed(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to count chunks")
 	assert.Equal(t, types.FinalizeUploadResponse{}, result)
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "UpdateFileStatus")
+	mockRepo.AssertNotCalled(t, "MarkFileReady")
 }
 
 func TestFinalizeUpload_UpdateStatusFailed(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	fileID := pgtype.UUID{Valid: true}
@@ -627,7 +2506,7 @@ func TestFinalizeUpload_UpdateStatusFailed(t *testing.T) {
 		Return(int64(10), nil)
 
 	expectedErr := errors.New("update failed")
-	mockRepo.On("UpdateFileStatus", ctx, mock.AnythingOfType("sqlc.UpdateFileStatusParams")).
+	mockRepo.On("MarkFileReady", ctx, fileID).
 		Return(sqlc.File{}, expectedErr)
 
 	result, err := service.FinalizeUpload(ctx, fileID)
@@ -638,9 +2517,151 @@ func TestFinalizeUpload_UpdateStatusFailed(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestFinalizeUpload_AlreadyReady(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{
+			ID:                fileID,
+			ShareID:           "abc123def456",
+			ChunkCount:        10,
+			Status:            "ready",
+			DeletionTokenHash: pgtype.Text{String: "deletion-token-123", Valid: true},
+		}, nil)
+
+	result, err := service.FinalizeUpload(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Equal(t, "abc123def456", result.ShareID)
+	assert.Equal(t, "deletion-token-123", result.DeletionToken)
+	assert.True(t, result.AlreadyFinalized)
+	mockRepo.AssertNotCalled(t, "CountChunksByFileId", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkFileReady", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateAuditLogEntry", mock.Anything, mock.Anything)
+}
+
+func TestFinalizeUpload_LostRace(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, ChunkCount: 10, Status: "uploading"}, nil)
+	mockRepo.On("CountChunksByFileId", ctx, fileID).
+		Return(int64(10), nil)
+	mockRepo.On("MarkFileReady", ctx, fileID).
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.FinalizeUpload(ctx, fileID)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not in uploading state")
+	assert.ErrorIs(t, err, ErrNotUploading)
+	mockRepo.AssertNotCalled(t, "CreateAuditLogEntry", mock.Anything, mock.Anything)
+}
+
+func TestFinalizeUpload_LostRaceToConcurrentFinalize(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{ID: fileID, ChunkCount: 10, Status: "uploading"}, nil).Once()
+	mockRepo.On("CountChunksByFileId", ctx, fileID).
+		Return(int64(10), nil)
+	mockRepo.On("MarkFileReady", ctx, fileID).
+		Return(sqlc.File{}, database.ErrNotFound)
+	mockRepo.On("GetFileByID", ctx, fileID).
+		Return(sqlc.File{
+			ID:                fileID,
+			ShareID:           "abc123def456",
+			ChunkCount:        10,
+			Status:            "ready",
+			DeletionTokenHash: pgtype.Text{String: "deletion-token-123", Valid: true},
+		}, nil).Once()
+
+	result, err := service.FinalizeUpload(ctx, fileID)
+
+	require.NoError(t, err)
+	assert.Equal(t, "abc123def456", result.ShareID)
+	assert.Equal(t, "deletion-token-123", result.DeletionToken)
+	assert.True(t, result.AlreadyFinalized)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateAuditLogEntry", mock.Anything, mock.Anything)
+}
+
+func TestFinalizeUpload_PublishesShare(t *testing.T) {
+	received := make(chan publish.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e publish.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		received <- e
+	}))
+	defer srv.Close()
+
+	hook, err := publish.NewHook(srv.URL, nil)
+	require.NoError(t, err)
+
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithPublishHook(hook)
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+	file := sqlc.File{
+		ID:                fileID,
+		ShareID:           "abc123def456",
+		TotalSize:         1024,
+		ChunkCount:        1,
+		Status:            "uploading",
+		DeletionTokenHash: pgtype.Text{String: "deletion-token-123", Valid: true},
+	}
+	ready := file
+	ready.Status = "ready"
+
+	mockRepo.On("GetFileByID", ctx, fileID).Return(file, nil)
+	mockRepo.On("CountChunksByFileId", ctx, fileID).Return(int64(1), nil)
+	mockRepo.On("MarkFileReady", ctx, fileID).Return(ready, nil)
+	// A failed lifecycle record does not stop publishing
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.Anything).
+		Return(sqlc.AuditLog{}, errors.New("database connection error"))
+
+	_, err = service.FinalizeUpload(ctx, fileID)
+	require.NoError(t, err)
+
+	select {
+	case e := <-received:
+		assert.Equal(t, publish.EventShareReady, e.Type)
+		assert.Equal(t, "abc123def456", e.Share.ShareID)
+		assert.Equal(t, int64(1024), e.Share.TotalSize)
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish hook was not called")
+	}
+}
+
+func TestIssueDownloadNonce_ReadOnly(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithReadOnly(readonly.New(true))
+
+	nonce, err := service.IssueDownloadNonce(context.Background(), "abc123def456")
+
+	require.NoError(t, err)
+	assert.Empty(t, nonce)
+	mockRepo.AssertNotCalled(t, "CreateDownloadNonce", mock.Anything, mock.Anything)
+}
+
 func TestGetFileSalt_Success(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "test-share-12"
@@ -658,7 +2679,7 @@ func TestGetFileSalt_Success(t *testing.T) {
 
 func TestGetFileSalt_NotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "non-existent"
@@ -675,13 +2696,49 @@ func TestGetFileSalt_NotFound(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetFileSalt_Timeout(t *testing.T) {
+	blockUntilDone := func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}
+
+	t.Run("lookup runs out of time", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithTimeouts(config.OperationTimeouts{Lookup: 10 * time.Millisecond})
+
+		mockRepo.On("GetFileSaltByShareId", mock.Anything, "abc123").
+			Run(blockUntilDone).Return("", context.DeadlineExceeded)
+
+		_, err := service.GetFileSalt(context.Background(), "abc123")
+
+		assert.ErrorIs(t, err, ErrOperationTimeout)
+	})
+
+	t.Run("request canceled first", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+			WithTimeouts(config.OperationTimeouts{Lookup: time.Minute})
+
+		mockRepo.On("GetFileSaltByShareId", mock.Anything, "abc123").
+			Run(blockUntilDone).Return("", context.Canceled)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := service.GetFileSalt(ctx, "abc123")
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrOperationTimeout)
+	})
+}
+
 func TestGetFileMetadataByShareID_Success(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "abc123def456"
 
+	baku := time.FixedZone("AZT", 4*60*60)
 	expectedMetadata := sqlc.GetFileMetadataByShareIdRow{
 		EncryptedFilename: "encrypted-filename",
 		EncryptedMimeType: "encrypted-mime",
@@ -689,11 +2746,16 @@ func TestGetFileMetadataByShareID_Success(t *testing.T) {
 		TotalSize:         1024 * 1024,
 		ChunkCount:        10,
 		ExpiresAt: pgtype.Timestamptz{
-			Time:  time.Now().Add(24 * time.Hour),
+			Time:  time.Date(2026, 3, 2, 4, 30, 0, 0, baku),
 			Valid: true,
 		},
 		MaxDownloads:  100,
 		DownloadCount: 5,
+		CreatedAt: pgtype.Timestamptz{
+			Time:  time.Date(2026, 3, 1, 4, 30, 0, 0, baku),
+			Valid: true,
+		},
+		ReturnUrl: pgtype.Text{String: "https://tickets.example.com/42", Valid: true},
 	}
 
 	mockRepo.On("GetFileMetadataByShareId", ctx, shareID).
@@ -709,12 +2771,15 @@ func TestGetFileMetadataByShareID_Success(t *testing.T) {
 	assert.Equal(t, expectedMetadata.ChunkCount, result.ChunkCount)
 	assert.Equal(t, expectedMetadata.MaxDownloads, result.MaxDownloads)
 	assert.Equal(t, expectedMetadata.DownloadCount, result.DownloadCount)
+	assert.Equal(t, "2026-03-02T00:30:00Z", result.ExpiresAt)
+	assert.Equal(t, "2026-03-01T00:30:00Z", result.CreatedAt)
+	assert.Equal(t, "https://tickets.example.com/42", result.ReturnURL)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetFileMetadataByShareID_NotFound(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "non-existent"
@@ -726,14 +2791,14 @@ func TestGetFileMetadataByShareID_NotFound(t *testing.T) {
 	result, err := service.GetFileMetadataByShareID(ctx, shareID)
 
 	require.Error(t, err)
-	assert.Equal(t, sqlc.GetFileMetadataByShareIdRow{}, result)
+	assert.Equal(t, types.ShareMetadata{}, result)
 	assert.Contains(t, err.Error(), "file could not be found")
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetFileMetadataByShareID_DatabaseError(t *testing.T) {
 	mockRepo := new(MockQuerier)
-	service := NewFileService(mockRepo, mockTxRunner, nil)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
 
 	ctx := context.Background()
 	shareID := "test-share-12"
@@ -745,7 +2810,490 @@ func TestGetFileMetadataByShareID_DatabaseError(t *testing.T) {
 	result, err := service.GetFileMetadataByShareID(ctx, shareID)
 
 	require.Error(t, err)
-	assert.Equal(t, sqlc.GetFileMetadataByShareIdRow{}, result)
+	assert.Equal(t, types.ShareMetadata{}, result)
 	assert.Contains(t, err.Error(), "file could not be found")
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetFileMetadataByShareID_Policy(t *testing.T) {
+	future := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	past := pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}
+
+	tests := []struct {
+		name          string
+		row           sqlc.GetFileMetadataByShareIdRow
+		wantExpiresBy []string
+		wantReason    string
+	}{
+		{
+			name:          "active with both limits",
+			row:           sqlc.GetFileMetadataByShareIdRow{ExpiresAt: future, MaxDownloads: 3, DownloadCount: 1, Status: "ready"},
+			wantExpiresBy: []string{types.ExpiryReasonTime, types.ExpiryReasonDownloadLimit},
+		},
+		{
+			name:          "unlimited downloads",
+			row:           sqlc.GetFileMetadataByShareIdRow{ExpiresAt: future, MaxDownloads: config.UnlimitedDownloads, Status: "ready"},
+			wantExpiresBy: []string{types.ExpiryReasonTime},
+		},
+		{
+			name:          "expired by time",
+			row:           sqlc.GetFileMetadataByShareIdRow{ExpiresAt: past, MaxDownloads: 3, DownloadCount: 3, Status: "expired"},
+			wantExpiresBy: []string{types.ExpiryReasonTime, types.ExpiryReasonDownloadLimit},
+			wantReason:    types.ExpiryReasonTime,
+		},
+		{
+			name:          "download limit reached",
+			row:           sqlc.GetFileMetadataByShareIdRow{ExpiresAt: future, MaxDownloads: 3, DownloadCount: 3, Status: "exhausted"},
+			wantExpiresBy: []string{types.ExpiryReasonTime, types.ExpiryReasonDownloadLimit},
+			wantReason:    types.ExpiryReasonDownloadLimit,
+		},
+		{
+			name:          "removed early",
+			row:           sqlc.GetFileMetadataByShareIdRow{ExpiresAt: future, MaxDownloads: 3, DownloadCount: 1, Status: "expired"},
+			wantExpiresBy: []string{types.ExpiryReasonTime, types.ExpiryReasonDownloadLimit},
+			wantReason:    types.ExpiryReasonRemoved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+				WithCleanupInterval(5 * time.Minute)
+			mockRepo.On("GetFileMetadataByShareId", mock.Anything, "abc123def456").Return(tt.row, nil)
+
+			result, err := service.GetFileMetadataByShareID(context.Background(), "abc123def456")
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExpiresBy, result.Policy.ExpiresBy)
+			assert.Equal(t, tt.wantReason, result.Policy.ExpiryReason)
+			assert.Equal(t, types.AtLimitDeleteContent, result.Policy.AtLimit)
+			assert.Equal(t, int64(300), result.Policy.RetentionSeconds)
+		})
+	}
+}
+
+func TestGetShareStats_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	downloaded := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{
+			ShareID:           "test-share-12",
+			Status:            "ready",
+			MaxDownloads:      5,
+			DownloadCount:     2,
+			LastDownloadedAt:  pgtype.Timestamptz{Time: downloaded, Valid: true},
+			ExpiresAt:         pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+			DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+		}, nil)
+
+	stats, err := service.GetShareStats(ctx, "test-share-12", "deletion-token")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), stats.DownloadCount)
+	require.NotNil(t, stats.RemainingDownloads)
+	assert.Equal(t, int32(3), *stats.RemainingDownloads)
+	assert.Equal(t, "2026-01-02T03:04:05Z", stats.LastDownloadedAt)
+	assert.NotEmpty(t, stats.ExpiresAt)
+	assert.False(t, stats.Expired)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetShareStats_UnlimitedDownloads(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{
+			ShareID:           "test-share-12",
+			DownloadCount:     7,
+			MaxDownloads:      config.UnlimitedDownloads,
+			ExpiresAt:         pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+			DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+		}, nil)
+
+	stats, err := service.GetShareStats(ctx, "test-share-12", "deletion-token")
+
+	require.NoError(t, err)
+	assert.Nil(t, stats.RemainingDownloads)
+	assert.Empty(t, stats.LastDownloadedAt)
+	assert.True(t, stats.Expired)
+}
+
+func TestGetShareStats_Errors(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true}}, nil)
+	mockRepo.On("GetFileByShareID", ctx, "missing").
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.GetShareStats(ctx, "test-share-12", "wrong-token")
+	assert.ErrorIs(t, err, ErrInvalidDeletionToken)
+
+	_, err = service.GetShareStats(ctx, "missing", "deletion-token")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRevokeShare(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	bus := events.NewBus()
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithEvents(bus)
+
+	ctx := context.Background()
+	file := sqlc.File{
+		ID:                pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+		ShareID:           "test-share-12",
+		Status:            "ready",
+		DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+	}
+	revoked := file
+	revoked.Status = FileStatusRevoked
+
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(file, nil).Once()
+	mockRepo.On("RevokeFile", ctx, file.ID).Return(revoked, nil).Once()
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(arg sqlc.CreateAuditLogEntryParams) bool {
+		return arg.Action == AuditActionFileRevoked
+	})).Return(sqlc.AuditLog{}, nil).Once()
+
+	changes, stop := bus.Subscribe("test-share-12")
+	defer stop()
+
+	stats, err := service.RevokeShare(ctx, "test-share-12", "deletion-token")
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusRevoked, stats.Status)
+	assert.Equal(t, events.TypeRevokedByUploader, (<-changes).Type)
+
+	// Revoking again is a no-op
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(revoked, nil).Once()
+	stats, err = service.RevokeShare(ctx, "test-share-12", "deletion-token")
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusRevoked, stats.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRevokeShare_NotReady(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	file := sqlc.File{
+		Status:            "uploading",
+		DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+	}
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(file, nil)
+	mockRepo.On("RevokeFile", ctx, file.ID).Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.RevokeShare(ctx, "test-share-12", "deletion-token")
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	_, err = service.RevokeShare(ctx, "test-share-12", "wrong-token")
+	assert.ErrorIs(t, err, ErrInvalidDeletionToken)
+}
+
+func TestGetFileMetadataByShareID_Revoked(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	mockRepo.On("GetFileMetadataByShareId", ctx, "abc123def456").
+		Return(sqlc.GetFileMetadataByShareIdRow{Status: FileStatusRevoked}, nil)
+
+	_, err := service.GetFileMetadataByShareID(ctx, "abc123def456")
+	assert.ErrorIs(t, err, ErrRevoked)
+}
+
+func TestCreateShareLink(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	fileExpiry := time.Now().Add(2 * time.Hour)
+	file := sqlc.File{
+		ID:                pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+		ShareID:           "test-share-12",
+		Status:            "ready",
+		ExpiresAt:         pgtype.Timestamptz{Time: fileExpiry, Valid: true},
+		DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+	}
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(file, nil)
+	mockRepo.On("CountShareLinksByFileId", ctx, file.ID).Return(int64(3), nil)
+	mockRepo.On("CreateShareLink", ctx, mock.MatchedBy(func(arg sqlc.CreateShareLinkParams) bool {
+		// Links cannot outlive their share
+		return len(arg.LinkID) == ShareLinkIDLength &&
+			arg.MaxDownloads == 1 &&
+			arg.Label.String == "for alice" &&
+			arg.ExpiresAt.Time.Equal(fileExpiry)
+	})).Return(sqlc.ShareLink{
+		LinkID:       "abcdefgh12345678",
+		Label:        pgtype.Text{String: "for alice", Valid: true},
+		MaxDownloads: 1,
+		ExpiresAt:    file.ExpiresAt,
+	}, nil)
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(arg sqlc.CreateAuditLogEntryParams) bool {
+		return arg.Action == AuditActionLinkCreated
+	})).Return(sqlc.AuditLog{}, nil)
+
+	link, err := service.CreateShareLink(ctx, "test-share-12", "deletion-token", types.CreateShareLinkRequest{
+		Label:          "for alice",
+		ExpiresInHours: 48,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefgh12345678", link.LinkID)
+	assert.Equal(t, LinkStatusActive, link.Status)
+	assert.Equal(t, int32(1), link.RemainingDownloads)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateShareLink_Rejects(t *testing.T) {
+	ctx := context.Background()
+	ready := sqlc.File{
+		Status:            "ready",
+		DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true},
+	}
+
+	tests := []struct {
+		name    string
+		file    sqlc.File
+		links   int64
+		req     types.CreateShareLinkRequest
+		wantErr error
+	}{
+		{
+			name:    "too many downloads",
+			file:    ready,
+			req:     types.CreateShareLinkRequest{MaxDownloads: MaxShareLinkDownloads + 1},
+			wantErr: ErrInvalidLinkRequest,
+		},
+		{
+			name:    "negative expiry",
+			file:    ready,
+			req:     types.CreateShareLinkRequest{ExpiresInHours: -1},
+			wantErr: ErrInvalidLinkRequest,
+		},
+		{
+			name:    "not ready",
+			file:    sqlc.File{Status: "uploading", DeletionTokenHash: ready.DeletionTokenHash},
+			wantErr: ErrNotReady,
+		},
+		{
+			name:    "too many links",
+			file:    ready,
+			links:   MaxShareLinks,
+			wantErr: ErrTooManyShareLinks,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+			mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(tt.file, nil)
+			mockRepo.On("CountShareLinksByFileId", ctx, tt.file.ID).Return(tt.links, nil).Maybe()
+
+			_, err := service.CreateShareLink(ctx, "test-share-12", "deletion-token", tt.req)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "CreateShareLink", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestResolveShareLink(t *testing.T) {
+	ctx := context.Background()
+	past := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	tests := []struct {
+		name    string
+		link    sqlc.GetShareLinkRow
+		err     error
+		wantErr error
+		wantNil bool
+	}{
+		{name: "active", link: sqlc.GetShareLinkRow{ShareID: "test-share-12", MaxDownloads: 2, DownloadCount: 1}},
+		{name: "unknown", err: database.ErrNotFound, wantNil: true},
+		{name: "revoked", link: sqlc.GetShareLinkRow{MaxDownloads: 1, RevokedAt: past}, wantErr: ErrNotFound},
+		{name: "expired", link: sqlc.GetShareLinkRow{MaxDownloads: 1, ExpiresAt: past}, wantErr: ErrNotFound},
+		{name: "exhausted", link: sqlc.GetShareLinkRow{MaxDownloads: 1, DownloadCount: 1}, wantErr: ErrDownloadLimitReached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+			mockRepo.On("GetShareLink", ctx, "abcdefgh12345678").Return(tt.link, tt.err)
+
+			link, err := service.ResolveShareLink(ctx, "abcdefgh12345678")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, link)
+				return
+			}
+			require.NotNil(t, link)
+			assert.Equal(t, "test-share-12", link.ShareID)
+		})
+	}
+}
+
+func TestGetFileMetadataByShareID_ShareLink(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	linkExpiry := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := WithShareLink(context.Background(), &sqlc.GetShareLinkRow{
+		ShareID:       "abc123def456",
+		MaxDownloads:  1,
+		DownloadCount: 0,
+		ExpiresAt:     pgtype.Timestamptz{Time: linkExpiry, Valid: true},
+	})
+
+	mockRepo.On("GetFileMetadataByShareId", ctx, "abc123def456").
+		Return(sqlc.GetFileMetadataByShareIdRow{
+			MaxDownloads:  100,
+			DownloadCount: 5,
+			ExpiresAt:     pgtype.Timestamptz{Time: linkExpiry.Add(24 * time.Hour), Valid: true},
+		}, nil)
+
+	result, err := service.GetFileMetadataByShareID(ctx, "abc123def456")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), result.MaxDownloads)
+	assert.Equal(t, int32(0), result.DownloadCount)
+	assert.Equal(t, "2026-03-01T12:00:00Z", result.ExpiresAt)
+}
+
+func TestWatchShare_AppliesEvents(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	bus := events.NewBus()
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithEvents(bus)
+
+	ctx := context.Background()
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{
+			ShareID:       "test-share-12",
+			Status:        "ready",
+			MaxDownloads:  2,
+			DownloadCount: 0,
+			ExpiresAt:     pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		}, nil)
+
+	watch, err := service.WatchShare(ctx, "test-share-12")
+	require.NoError(t, err)
+	defer watch.Stop()
+
+	assert.Equal(t, "ready", watch.Status.Status)
+	require.NotNil(t, watch.Status.RemainingDownloads)
+	assert.Equal(t, int32(2), *watch.Status.RemainingDownloads)
+	assert.False(t, watch.ExpiresAt.IsZero())
+	assert.False(t, watch.Ended())
+
+	bus.Publish(events.Event{Type: events.TypeDownloadCounted, ShareID: "test-share-12", DownloadCount: 2, MaxDownloads: 2})
+	watch.Apply(<-watch.Events)
+
+	assert.Equal(t, "exhausted", watch.Status.Status)
+	assert.Equal(t, int32(0), *watch.Status.RemainingDownloads)
+	assert.True(t, watch.Ended())
+}
+
+func TestWatchShare_Verification(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{
+			ShareID:      "test-share-12",
+			Status:       FileStatusVerifying,
+			ChunkCount:   200,
+			MaxDownloads: 2,
+		}, nil)
+
+	watch, err := service.WatchShare(ctx, "test-share-12")
+	require.NoError(t, err)
+	defer watch.Stop()
+
+	assert.Equal(t, &types.VerificationProgress{ChunkCount: 200}, watch.Status.Verification)
+	assert.False(t, watch.Ended())
+
+	watch.Apply(events.Event{Type: events.TypeVerifying, ShareID: "test-share-12", ChunksVerified: 50, ChunkCount: 200})
+	assert.Equal(t, &types.VerificationProgress{ChunksVerified: 50, ChunkCount: 200}, watch.Status.Verification)
+
+	watch.Apply(events.Event{Type: events.TypeReady, ShareID: "test-share-12"})
+	assert.Equal(t, "ready", watch.Status.Status)
+	assert.Nil(t, watch.Status.Verification)
+
+	watch.Apply(events.Event{Type: events.TypeCorrupt, ShareID: "test-share-12"})
+	assert.True(t, watch.Ended())
+}
+
+func TestWatchUpload_TracksChunks(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	bus := events.NewBus()
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits()).
+		WithEvents(bus)
+
+	ctx := context.Background()
+	fileID := createTestUUID()
+	file := sqlc.File{ID: fileID, ShareID: "test-share-12", Status: "uploading", ChunkCount: 3, MaxDownloads: 1}
+	mockRepo.On("GetFileByID", ctx, fileID).Return(file, nil)
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").Return(file, nil)
+	mockRepo.On("ListChunkIndexesByFileId", ctx, fileID).Return([]int32{0}, nil)
+
+	watch, err := service.WatchUpload(ctx, fileID)
+	require.NoError(t, err)
+	defer watch.Stop()
+
+	assert.Equal(t, &types.UploadedChunks{ChunksUploaded: 1, ChunkCount: 3}, watch.Status.Upload)
+	assert.False(t, watch.Ended())
+
+	bus.Publish(events.Event{Type: events.TypeChunkReceived, ShareID: "test-share-12", ChunkIndex: 2, ChunkCount: 3})
+	watch.Apply(<-watch.Events)
+	watch.Apply(events.Event{Type: events.TypeChunkReceived, ShareID: "test-share-12", ChunkIndex: 0, ChunkCount: 3})
+	assert.Equal(t, &types.UploadedChunks{ChunksUploaded: 2, ChunkCount: 3}, watch.Status.Upload)
+
+	watch.Apply(events.Event{Type: events.TypeReady, ShareID: "test-share-12"})
+	assert.Equal(t, "ready", watch.Status.Status)
+	assert.Nil(t, watch.Status.Upload)
+}
+
+func TestWatchShare_AlreadyExpired(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewFileService(mockRepo, txRunnerOn(mockRepo), nil, config.DefaultLimits())
+
+	ctx := context.Background()
+	mockRepo.On("GetFileByShareID", ctx, "test-share-12").
+		Return(sqlc.File{
+			ShareID:      "test-share-12",
+			Status:       "ready",
+			MaxDownloads: config.UnlimitedDownloads,
+			ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+		}, nil)
+	mockRepo.On("GetFileByShareID", ctx, "missing").
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	watch, err := service.WatchShare(ctx, "test-share-12")
+	require.NoError(t, err)
+	defer watch.Stop()
+
+	assert.Equal(t, "expired", watch.Status.Status)
+	assert.Nil(t, watch.Status.RemainingDownloads)
+	assert.True(t, watch.Ended())
+
+	_, err = service.WatchShare(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/idgen"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// MaxPasteSize bounds the decoded ciphertext of a paste. Anything larger is
+// uploaded as a file.
+const MaxPasteSize = 64 << 10
+
+var ErrInvalidPasteRequest = errors.New("invalid paste request")
+
+// PasteService shares small encrypted texts stored inline in Postgres, with
+// the expiry and download limits of files but without chunks.
+type PasteService struct {
+	repository sqlc.Querier
+	shareIDGen idgen.Generator
+	limits     config.Limits
+	readOnly   *readonly.Switch
+}
+
+func NewPasteService(repository sqlc.Querier, limits config.Limits) *PasteService {
+	return &PasteService{
+		repository: repository,
+		shareIDGen: idgen.Alphanumeric{Length: idgen.DefaultLength},
+		limits:     limits,
+	}
+}
+
+// WithReadOnly refuses paste reads while sw is on, since every read is
+// counted.
+func (s *PasteService) WithReadOnly(sw *readonly.Switch) *PasteService {
+	s.readOnly = sw
+	return s
+}
+
+// WithShareIDGenerator replaces the default alphanumeric share ID generator.
+func (s *PasteService) WithShareIDGenerator(g idgen.Generator) *PasteService {
+	s.shareIDGen = g
+	return s
+}
+
+func (s *PasteService) CreatePaste(ctx context.Context, req types.CreatePasteRequest) (*types.CreatePasteResponse, error) {
+	if err := validatePasteRequest(req); err != nil {
+		return nil, err
+	}
+
+	maxDownloads := req.MaxDownloads
+	if maxDownloads == 0 {
+		maxDownloads = s.limits.DefaultMaxDownloads
+	}
+
+	expiresIn := time.Duration(req.ExpiresInHours) * time.Hour
+	if expiresIn == 0 {
+		expiresIn = s.limits.DefaultExpiry
+	}
+	expiresAt := time.Now().Add(expiresIn)
+
+	shareID, err := s.shareIDGen.Generate()
+	if err != nil {
+		slog.Error("failed to generate share ID",
+			slog.String("error", err.Error()),
+			slog.String("strategy", s.shareIDGen.Name()),
+		)
+		return nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
+
+	params := sqlc.CreatePasteParams{
+		ShareID:          shareID,
+		Ciphertext:       req.Ciphertext,
+		Salt:             req.Salt,
+		Pbkdf2Iterations: req.Pbkdf2Iterations,
+		MaxDownloads:     maxDownloads,
+		ExpiresAt:        pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	}
+	paste, err := s.repository.CreatePaste(ctx, params)
+	for attempt := 1; isPasteShareIDViolation(err) && attempt < maxShareIDAttempts; attempt++ {
+		idgen.RecordCollision()
+		idgen.RecordRetry()
+		slog.Warn("paste share ID collision, generating another",
+			slog.String("share_id", params.ShareID),
+			slog.String("strategy", s.shareIDGen.Name()),
+			slog.Int("attempt", attempt),
+		)
+		params.ShareID, err = s.shareIDGen.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate share ID: %w", err)
+		}
+		paste, err = s.repository.CreatePaste(ctx, params)
+	}
+	if isPasteShareIDViolation(err) {
+		idgen.RecordCollision()
+		slog.Error("no free paste share ID found",
+			slog.String("strategy", s.shareIDGen.Name()),
+			slog.Int("attempts", maxShareIDAttempts),
+		)
+		return nil, ErrShareIDExhausted
+	}
+	if err != nil {
+		slog.Error("failed to create paste",
+			slog.String("error", err.Error()),
+			slog.String("share_id", params.ShareID),
+		)
+		return nil, fmt.Errorf("failed to create paste: %w", err)
+	}
+
+	slog.Info("paste created",
+		slog.String("share_id", paste.ShareID),
+		slog.Int("max_downloads", int(maxDownloads)),
+		slog.Duration("expires_in", expiresIn),
+	)
+
+	return &types.CreatePasteResponse{
+		ShareID:   paste.ShareID,
+		ExpiresAt: formatTimestamptz(paste.ExpiresAt),
+	}, nil
+}
+
+// ReadPaste returns a paste and counts the read as a download. Reads of an
+// expired or used up paste fail with ErrExpired or ErrDownloadLimitReached.
+func (s *PasteService) ReadPaste(ctx context.Context, shareID string) (*types.PasteResponse, error) {
+	// Reading counts towards the paste's download limit
+	if s.readOnly.Enabled() {
+		return nil, readonly.ErrReadOnly
+	}
+
+	paste, err := s.repository.ReadPasteByShareId(ctx, shareID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, s.unreadableReason(ctx, shareID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paste: %w", err)
+	}
+
+	slog.Info("paste read",
+		slog.String("share_id", shareID),
+		slog.Int("download_count", int(paste.DownloadCount)),
+	)
+
+	return &types.PasteResponse{
+		ShareID:          paste.ShareID,
+		Ciphertext:       paste.Ciphertext,
+		Salt:             paste.Salt,
+		Pbkdf2Iterations: paste.Pbkdf2Iterations,
+		DownloadCount:    paste.DownloadCount,
+		MaxDownloads:     paste.MaxDownloads,
+		ExpiresAt:        formatTimestamptz(paste.ExpiresAt),
+	}, nil
+}
+
+func (s *PasteService) unreadableReason(ctx context.Context, shareID string) error {
+	paste, err := s.repository.GetPasteByShareId(ctx, shareID)
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get paste: %w", err)
+	}
+
+	if !paste.ExpiresAt.Time.After(time.Now()) {
+		return ErrExpired
+	}
+	return ErrDownloadLimitReached
+}
+
+func validatePasteRequest(req types.CreatePasteRequest) error {
+	if req.Ciphertext == "" {
+		return fmt.Errorf("%w: ciphertext is required", ErrInvalidPasteRequest)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(req.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("%w: ciphertext must be base64", ErrInvalidPasteRequest)
+	}
+	if len(ciphertext) > MaxPasteSize {
+		return fmt.Errorf("%w: ciphertext must be at most %d bytes", ErrInvalidPasteRequest, MaxPasteSize)
+	}
+	if req.Salt == "" {
+		return fmt.Errorf("%w: salt is required", ErrInvalidPasteRequest)
+	}
+	if req.Pbkdf2Iterations <= 0 {
+		return fmt.Errorf("%w: pbkdf2_iterations must be positive", ErrInvalidPasteRequest)
+	}
+	if req.ExpiresInHours < 0 {
+		return fmt.Errorf("%w: expires_in_hours must not be negative", ErrInvalidPasteRequest)
+	}
+	if req.MaxDownloads < 0 && req.MaxDownloads != config.UnlimitedDownloads {
+		return fmt.Errorf("%w: max_downloads must be positive, 0 for the default or %d for unlimited", ErrInvalidPasteRequest, config.UnlimitedDownloads)
+	}
+	return nil
+}
+
+// isPasteShareIDViolation reports whether a paste was created with a share
+// ID another paste already has.
+func isPasteShareIDViolation(err error) bool {
+	return errors.Is(err, database.ErrConflict) && database.Constraint(err) == "pastes_share_id_key"
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaste_Integration_ReadUntilLimit(t *testing.T) {
+	containers := testutil.SetupTestContainers(t)
+	defer containers.Cleanup()
+
+	pasteService := NewPasteService(containers.Querier(), config.DefaultLimits())
+	ctx := context.Background()
+
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("encrypted secret"))
+	created, err := pasteService.CreatePaste(ctx, types.CreatePasteRequest{
+		Ciphertext:       ciphertext,
+		Salt:             "paste-salt",
+		Pbkdf2Iterations: 100000,
+		MaxDownloads:     2,
+	})
+	require.NoError(t, err)
+
+	for i := int32(1); i <= 2; i++ {
+		paste, err := pasteService.ReadPaste(ctx, created.ShareID)
+		require.NoError(t, err)
+		assert.Equal(t, ciphertext, paste.Ciphertext)
+		assert.Equal(t, i, paste.DownloadCount)
+	}
+
+	_, err = pasteService.ReadPaste(ctx, created.ShareID)
+	assert.ErrorIs(t, err, ErrDownloadLimitReached)
+
+	pruned, err := containers.Database.Queries.DeleteExpiredPastes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	_, err = pasteService.ReadPaste(ctx, created.ShareID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/config"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/readonly"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func validPasteRequest() types.CreatePasteRequest {
+	return types.CreatePasteRequest{
+		Ciphertext:       base64.StdEncoding.EncodeToString([]byte("encrypted secret")),
+		Salt:             "paste-salt",
+		Pbkdf2Iterations: 100000,
+	}
+}
+
+func TestCreatePaste_AppliesDefaults(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewPasteService(mockRepo, config.DefaultLimits())
+	ctx := context.Background()
+
+	var captured sqlc.CreatePasteParams
+	mockRepo.On("CreatePaste", ctx, mock.AnythingOfType("sqlc.CreatePasteParams")).
+		Run(func(args mock.Arguments) {
+			captured = args.Get(1).(sqlc.CreatePasteParams)
+		}).
+		Return(sqlc.Paste{ShareID: "paste-share"}, nil)
+
+	paste, err := service.CreatePaste(ctx, validPasteRequest())
+
+	require.NoError(t, err)
+	assert.Equal(t, "paste-share", paste.ShareID)
+	assert.Equal(t, config.DefaultLimits().DefaultMaxDownloads, captured.MaxDownloads)
+	assert.WithinDuration(t, time.Now().Add(config.DefaultLimits().DefaultExpiry), captured.ExpiresAt.Time, 5*time.Second)
+	assert.Equal(t, validPasteRequest().Ciphertext, captured.Ciphertext)
+}
+
+func TestCreatePaste_ShareIDCollision(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewPasteService(mockRepo, config.DefaultLimits())
+	ctx := context.Background()
+	collision := database.Classify(&pgconn.PgError{Code: "23505", ConstraintName: "pastes_share_id_key"})
+	collisionsBefore := expvar.Get("share_id_collisions").(*expvar.Int).Value()
+
+	var shareIDs []string
+	mockRepo.On("CreatePaste", ctx, mock.AnythingOfType("sqlc.CreatePasteParams")).
+		Run(func(args mock.Arguments) {
+			shareIDs = append(shareIDs, args.Get(1).(sqlc.CreatePasteParams).ShareID)
+		}).
+		Return(sqlc.Paste{}, collision).Once()
+	mockRepo.On("CreatePaste", ctx, mock.AnythingOfType("sqlc.CreatePasteParams")).
+		Run(func(args mock.Arguments) {
+			shareIDs = append(shareIDs, args.Get(1).(sqlc.CreatePasteParams).ShareID)
+		}).
+		Return(sqlc.Paste{ShareID: "paste-share"}, nil).Once()
+
+	_, err := service.CreatePaste(ctx, validPasteRequest())
+
+	require.NoError(t, err)
+	require.Len(t, shareIDs, 2)
+	assert.NotEqual(t, shareIDs[0], shareIDs[1])
+	assert.Equal(t, collisionsBefore+1, expvar.Get("share_id_collisions").(*expvar.Int).Value())
+}
+
+func TestCreatePaste_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *types.CreatePasteRequest)
+	}{
+		{name: "missing ciphertext", modify: func(req *types.CreatePasteRequest) { req.Ciphertext = "" }},
+		{name: "ciphertext not base64", modify: func(req *types.CreatePasteRequest) { req.Ciphertext = "not base64!" }},
+		{name: "ciphertext too large", modify: func(req *types.CreatePasteRequest) {
+			req.Ciphertext = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", MaxPasteSize+1)))
+		}},
+		{name: "missing salt", modify: func(req *types.CreatePasteRequest) { req.Salt = "" }},
+		{name: "zero iterations", modify: func(req *types.CreatePasteRequest) { req.Pbkdf2Iterations = 0 }},
+		{name: "negative expiry", modify: func(req *types.CreatePasteRequest) { req.ExpiresInHours = -1 }},
+		{name: "max downloads below -1", modify: func(req *types.CreatePasteRequest) { req.MaxDownloads = -2 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewPasteService(mockRepo, config.DefaultLimits())
+			req := validPasteRequest()
+			tt.modify(&req)
+
+			_, err := service.CreatePaste(context.Background(), req)
+
+			assert.ErrorIs(t, err, ErrInvalidPasteRequest)
+			mockRepo.AssertNotCalled(t, "CreatePaste", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestReadPaste_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewPasteService(mockRepo, config.DefaultLimits())
+	ctx := context.Background()
+
+	mockRepo.On("ReadPasteByShareId", ctx, "paste-share").
+		Return(sqlc.Paste{
+			ShareID:          "paste-share",
+			Ciphertext:       "Y2lwaGVydGV4dA==",
+			Salt:             "paste-salt",
+			Pbkdf2Iterations: 100000,
+			MaxDownloads:     2,
+			DownloadCount:    1,
+			ExpiresAt:        pgtype.Timestamptz{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+		}, nil)
+
+	paste, err := service.ReadPaste(ctx, "paste-share")
+
+	require.NoError(t, err)
+	assert.Equal(t, types.PasteResponse{
+		ShareID:          "paste-share",
+		Ciphertext:       "Y2lwaGVydGV4dA==",
+		Salt:             "paste-salt",
+		Pbkdf2Iterations: 100000,
+		DownloadCount:    1,
+		MaxDownloads:     2,
+		ExpiresAt:        "2026-01-02T03:04:05Z",
+	}, *paste)
+}
+
+func TestReadPaste_ReadOnly(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewPasteService(mockRepo, config.DefaultLimits()).
+		WithReadOnly(readonly.New(true))
+
+	_, err := service.ReadPaste(context.Background(), "paste-share")
+
+	assert.ErrorIs(t, err, readonly.ErrReadOnly)
+	mockRepo.AssertNotCalled(t, "ReadPasteByShareId", mock.Anything, mock.Anything)
+}
+
+func TestReadPaste_Unreadable(t *testing.T) {
+	tests := []struct {
+		name    string
+		paste   sqlc.Paste
+		err     error
+		wantErr error
+	}{
+		{name: "unknown", err: database.ErrNotFound, wantErr: ErrNotFound},
+		{
+			name:    "expired",
+			paste:   sqlc.Paste{ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}},
+			wantErr: ErrExpired,
+		},
+		{
+			name:    "used up",
+			paste:   sqlc.Paste{MaxDownloads: 1, DownloadCount: 1, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}},
+			wantErr: ErrDownloadLimitReached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuerier)
+			service := NewPasteService(mockRepo, config.DefaultLimits())
+			ctx := context.Background()
+
+			mockRepo.On("ReadPasteByShareId", ctx, "paste-share").Return(sqlc.Paste{}, database.ErrNotFound)
+			mockRepo.On("GetPasteByShareId", ctx, "paste-share").Return(tt.paste, tt.err)
+
+			_, err := service.ReadPaste(ctx, "paste-share")
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
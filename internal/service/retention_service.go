@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// reportMonthLayout names a report by its month, e.g. 2026-09.
+const reportMonthLayout = "2006-01"
+
+var ErrInvalidReportMonth = errors.New("month must be a past month as YYYY-MM")
+
+// RetentionService builds the monthly retention reports kept for compliance
+// reviews. Reports cover calendar months in UTC and are stored once the
+// month is over.
+type RetentionService struct {
+	repository sqlc.Querier
+	now        func() time.Time
+}
+
+func NewRetentionService(repository sqlc.Querier) *RetentionService {
+	return &RetentionService{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// ParseReportMonth returns the start of the month named YYYY-MM.
+func ParseReportMonth(s string) (time.Time, error) {
+	month, err := time.Parse(reportMonthLayout, s)
+	if err != nil {
+		return time.Time{}, ErrInvalidReportMonth
+	}
+	return month, nil
+}
+
+// GenerateReport computes the report of the month starting at month and
+// stores it, replacing any earlier report of that month.
+func (s *RetentionService) GenerateReport(ctx context.Context, month time.Time) (types.RetentionReport, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	now := s.now().UTC()
+	if end.After(now) {
+		return types.RetentionReport{}, ErrInvalidReportMonth
+	}
+	periodStart := pgtype.Timestamptz{Time: start, Valid: true}
+	periodEnd := pgtype.Timestamptz{Time: end, Valid: true}
+
+	stats, err := s.repository.GetRetentionStats(ctx, sqlc.GetRetentionStatsParams{
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+	})
+	if err != nil {
+		return types.RetentionReport{}, fmt.Errorf("failed to get retention stats: %w", err)
+	}
+
+	report := types.RetentionReport{
+		Month:                  start.Format(reportMonthLayout),
+		PeriodStart:            formatTime(start),
+		PeriodEnd:              formatTime(end),
+		GeneratedAt:            formatTime(now),
+		FilesCreated:           stats.FilesCreated,
+		BytesCreated:           stats.BytesCreated,
+		FilesExpired:           stats.FilesExpired,
+		FilesPurged:            stats.FilesPurged,
+		BytesPurged:            stats.BytesPurged,
+		AverageLifetimeSeconds: int64(stats.AverageLifetimeSeconds),
+	}
+
+	oldest, err := s.repository.GetOldestRetainedFile(ctx, periodEnd)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+	case err != nil:
+		return types.RetentionReport{}, fmt.Errorf("failed to get oldest retained file: %w", err)
+	default:
+		report.OldestRetained = &types.RetainedFileAge{
+			ShareID:    oldest.ShareID,
+			CreatedAt:  formatTimestamptz(oldest.CreatedAt),
+			AgeSeconds: int64(end.Sub(oldest.CreatedAt.Time).Seconds()),
+		}
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return types.RetentionReport{}, fmt.Errorf("failed to encode retention report: %w", err)
+	}
+	_, err = s.repository.UpsertRetentionReport(ctx, sqlc.UpsertRetentionReportParams{
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Report:      body,
+	})
+	if err != nil {
+		return types.RetentionReport{}, fmt.Errorf("failed to store retention report: %w", err)
+	}
+
+	slog.Info("retention report generated",
+		slog.String("month", report.Month),
+		slog.Int64("files_created", report.FilesCreated),
+		slog.Int64("files_purged", report.FilesPurged),
+	)
+	return report, nil
+}
+
+// GenerateDueReport stores the report of last month unless it exists. It
+// reports whether a report was generated.
+func (s *RetentionService) GenerateDueReport(ctx context.Context) (bool, error) {
+	now := s.now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	_, err := s.repository.GetRetentionReport(ctx, pgtype.Timestamptz{Time: lastMonth, Valid: true})
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return false, fmt.Errorf("failed to get retention report: %w", err)
+	}
+
+	if _, err := s.GenerateReport(ctx, lastMonth); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetReport returns the stored report of the month starting at month.
+func (s *RetentionService) GetReport(ctx context.Context, month time.Time) (types.RetentionReport, error) {
+	row, err := s.repository.GetRetentionReport(ctx, pgtype.Timestamptz{Time: month, Valid: true})
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.RetentionReport{}, ErrNotFound
+		}
+		return types.RetentionReport{}, fmt.Errorf("failed to get retention report: %w", err)
+	}
+
+	var report types.RetentionReport
+	if err := json.Unmarshal(row.Report, &report); err != nil {
+		return types.RetentionReport{}, fmt.Errorf("failed to decode retention report: %w", err)
+	}
+	return report, nil
+}
+
+// ListReports returns the stored reports, newest month first.
+func (s *RetentionService) ListReports(ctx context.Context) ([]types.RetentionReportSummary, error) {
+	rows, err := s.repository.ListRetentionReports(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention reports: %w", err)
+	}
+
+	reports := make([]types.RetentionReportSummary, len(rows))
+	for i, row := range rows {
+		reports[i] = types.RetentionReportSummary{
+			Month:       row.PeriodStart.Time.UTC().Format(reportMonthLayout),
+			GeneratedAt: formatTimestamptz(row.CreatedAt),
+		}
+	}
+	return reports, nil
+}
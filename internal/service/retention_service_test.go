@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetentionService(repo sqlc.Querier, now time.Time) *RetentionService {
+	s := NewRetentionService(repo)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestGenerateReport(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	ctx := context.Background()
+	service := newTestRetentionService(mockRepo, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+
+	start := pgtype.Timestamptz{Time: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	end := pgtype.Timestamptz{Time: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	mockRepo.On("GetRetentionStats", ctx, sqlc.GetRetentionStatsParams{PeriodStart: start, PeriodEnd: end}).Return(sqlc.GetRetentionStatsRow{
+		FilesCreated:           12,
+		BytesCreated:           4096,
+		FilesExpired:           9,
+		FilesPurged:            8,
+		BytesPurged:            2048,
+		AverageLifetimeSeconds: 86400.6,
+	}, nil)
+	mockRepo.On("GetOldestRetainedFile", ctx, end).Return(sqlc.GetOldestRetainedFileRow{
+		ShareID:   "abc123def456",
+		CreatedAt: pgtype.Timestamptz{Time: time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), Valid: true},
+	}, nil)
+	var stored []byte
+	mockRepo.On("UpsertRetentionReport", ctx, mock.MatchedBy(func(p sqlc.UpsertRetentionReportParams) bool {
+		stored = p.Report
+		return p.PeriodStart == start && p.PeriodEnd == end
+	})).Return(sqlc.RetentionReport{}, nil)
+
+	report, err := service.GenerateReport(ctx, start.Time)
+
+	require.NoError(t, err)
+	assert.Equal(t, "2026-09", report.Month)
+	assert.Equal(t, "2026-10-01T00:00:00Z", report.PeriodEnd)
+	assert.Equal(t, int64(12), report.FilesCreated)
+	assert.Equal(t, int64(8), report.FilesPurged)
+	assert.Equal(t, int64(86400), report.AverageLifetimeSeconds)
+	require.NotNil(t, report.OldestRetained)
+	assert.Equal(t, "abc123def456", report.OldestRetained.ShareID)
+	assert.Equal(t, int64(86400), report.OldestRetained.AgeSeconds)
+
+	var decoded types.RetentionReport
+	require.NoError(t, json.Unmarshal(stored, &decoded))
+	assert.Equal(t, report, decoded)
+}
+
+func TestGenerateReport_NothingRetained(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	ctx := context.Background()
+	service := newTestRetentionService(mockRepo, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+
+	mockRepo.On("GetRetentionStats", ctx, mock.Anything).Return(sqlc.GetRetentionStatsRow{}, nil)
+	mockRepo.On("GetOldestRetainedFile", ctx, mock.Anything).Return(sqlc.GetOldestRetainedFileRow{}, database.ErrNotFound)
+	mockRepo.On("UpsertRetentionReport", ctx, mock.Anything).Return(sqlc.RetentionReport{}, nil)
+
+	report, err := service.GenerateReport(ctx, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	assert.Nil(t, report.OldestRetained)
+}
+
+func TestGenerateReport_MonthNotOver(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := newTestRetentionService(mockRepo, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+
+	_, err := service.GenerateReport(context.Background(), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.ErrorIs(t, err, ErrInvalidReportMonth)
+	mockRepo.AssertNotCalled(t, "GetRetentionStats")
+}
+
+func TestGenerateDueReport(t *testing.T) {
+	lastMonth := pgtype.Timestamptz{Time: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	t.Run("already stored", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		ctx := context.Background()
+		service := newTestRetentionService(mockRepo, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+		mockRepo.On("GetRetentionReport", ctx, lastMonth).Return(sqlc.RetentionReport{}, nil)
+
+		generated, err := service.GenerateDueReport(ctx)
+
+		require.NoError(t, err)
+		assert.False(t, generated)
+		mockRepo.AssertNotCalled(t, "UpsertRetentionReport")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		mockRepo := new(MockQuerier)
+		ctx := context.Background()
+		service := newTestRetentionService(mockRepo, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+		mockRepo.On("GetRetentionReport", ctx, lastMonth).Return(sqlc.RetentionReport{}, database.ErrNotFound)
+		mockRepo.On("GetRetentionStats", ctx, mock.Anything).Return(sqlc.GetRetentionStatsRow{}, nil)
+		mockRepo.On("GetOldestRetainedFile", ctx, mock.Anything).Return(sqlc.GetOldestRetainedFileRow{}, database.ErrNotFound)
+		mockRepo.On("UpsertRetentionReport", ctx, mock.Anything).Return(sqlc.RetentionReport{}, nil)
+
+		generated, err := service.GenerateDueReport(ctx)
+
+		require.NoError(t, err)
+		assert.True(t, generated)
+	})
+}
+
+func TestParseReportMonth(t *testing.T) {
+	month, err := ParseReportMonth("2026-09")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), month)
+
+	for _, s := range []string{"", "2026-9", "2026-13", "september"} {
+		_, err := ParseReportMonth(s)
+		assert.ErrorIs(t, err, ErrInvalidReportMonth, s)
+	}
+}
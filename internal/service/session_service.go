@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+)
+
+var (
+	ErrInvalidDeletionToken = errors.New("invalid deletion token")
+	ErrInvalidSession       = errors.New("invalid management session")
+	ErrSessionExpired       = errors.New("management session expired")
+)
+
+// SessionService exchanges a share's long-lived deletion token for a
+// short-lived signed management session token.
+type SessionService struct {
+	repository sqlc.Querier
+	secret     []byte
+	ttl        time.Duration
+}
+
+func NewSessionService(repository sqlc.Querier, secret []byte, ttl time.Duration) *SessionService {
+	return &SessionService{
+		repository: repository,
+		secret:     secret,
+		ttl:        ttl,
+	}
+}
+
+func (s *SessionService) CreateManagementSession(ctx context.Context, shareID, deletionToken string) (types.ManagementSessionResponse, error) {
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.ManagementSessionResponse{}, ErrNotFound
+		}
+		return types.ManagementSessionResponse{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if !file.DeletionTokenHash.Valid ||
+		subtle.ConstantTimeCompare([]byte(file.DeletionTokenHash.String), []byte(deletionToken)) != 1 {
+		slog.Warn("deletion token mismatch",
+			slog.String("share_id", shareID),
+		)
+		return types.ManagementSessionResponse{}, ErrInvalidDeletionToken
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	payload := sessionPayload(shareID, expiresAt.Unix())
+
+	slog.Info("management session created",
+		slog.String("share_id", shareID),
+		slog.String("expires_at", expiresAt.Format(time.RFC3339)),
+	)
+
+	return types.ManagementSessionResponse{
+		SessionToken: payload + "." + crypto.Sign(s.secret, payload),
+		ExpiresAt:    formatTime(expiresAt),
+	}, nil
+}
+
+// VerifyManagementSession checks that token was issued by this service for
+// shareID and has not expired.
+func (s *SessionService) VerifyManagementSession(shareID, token string) error {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || !crypto.VerifySignature(s.secret, payload, signature) {
+		return ErrInvalidSession
+	}
+
+	tokenShareID, expStr, ok := cutLast(payload, ".")
+	if !ok || tokenShareID != shareID {
+		return ErrInvalidSession
+	}
+
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return ErrInvalidSession
+	}
+	if time.Now().Unix() >= exp {
+		return ErrSessionExpired
+	}
+
+	return nil
+}
+
+func sessionPayload(shareID string, expiresAt int64) string {
+	return shareID + "." + strconv.FormatInt(expiresAt, 10)
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
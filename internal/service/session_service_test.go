@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSessionSecret = []byte("test-session-secret")
+
+func TestCreateManagementSession_Success(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewSessionService(mockRepo, testSessionSecret, 15*time.Minute)
+	ctx := context.Background()
+
+	mockRepo.On("GetFileByShareID", ctx, "abc123def456").
+		Return(sqlc.File{ShareID: "abc123def456", DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true}}, nil)
+
+	session, err := service.CreateManagementSession(ctx, "abc123def456", "deletion-token")
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, session.SessionToken)
+	assert.NotEmpty(t, session.ExpiresAt)
+	assert.NoError(t, service.VerifyManagementSession("abc123def456", session.SessionToken))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateManagementSession_InvalidDeletionToken(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewSessionService(mockRepo, testSessionSecret, 15*time.Minute)
+	ctx := context.Background()
+
+	mockRepo.On("GetFileByShareID", ctx, "abc123def456").
+		Return(sqlc.File{ShareID: "abc123def456", DeletionTokenHash: pgtype.Text{String: "deletion-token", Valid: true}}, nil)
+
+	_, err := service.CreateManagementSession(ctx, "abc123def456", "wrong-token")
+
+	assert.ErrorIs(t, err, ErrInvalidDeletionToken)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateManagementSession_FileNotFound(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewSessionService(mockRepo, testSessionSecret, 15*time.Minute)
+	ctx := context.Background()
+
+	mockRepo.On("GetFileByShareID", ctx, "missing").
+		Return(sqlc.File{}, database.ErrNotFound)
+
+	_, err := service.CreateManagementSession(ctx, "missing", "deletion-token")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyManagementSession_WrongShareID(t *testing.T) {
+	service := NewSessionService(nil, testSessionSecret, 15*time.Minute)
+	payload := sessionPayload("abc123def456", time.Now().Add(time.Minute).Unix())
+	token := payload + "." + crypto.Sign(testSessionSecret, payload)
+
+	err := service.VerifyManagementSession("otherShareID", token)
+
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
+
+func TestVerifyManagementSession_Tampered(t *testing.T) {
+	service := NewSessionService(nil, testSessionSecret, 15*time.Minute)
+	payload := sessionPayload("abc123def456", time.Now().Add(time.Minute).Unix())
+	token := payload + "." + crypto.Sign(testSessionSecret, payload) + "00"
+
+	err := service.VerifyManagementSession("abc123def456", token)
+
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
+
+func TestVerifyManagementSession_Expired(t *testing.T) {
+	service := NewSessionService(nil, testSessionSecret, 15*time.Minute)
+	payload := sessionPayload("abc123def456", time.Now().Add(-time.Minute).Unix())
+	token := payload + "." + crypto.Sign(testSessionSecret, payload)
+
+	err := service.VerifyManagementSession("abc123def456", token)
+
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
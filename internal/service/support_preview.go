@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/api/types"
+	"github.com/ilkin0/gzln/internal/crypto"
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	AuditActionSupportPreviewCreated = "support_preview.created"
+	AuditActionSupportPreviewViewed  = "support_preview.viewed"
+
+	// supportActor is recorded in the audit log for previews, which support
+	// staff open with a bearer token rather than as a named user.
+	supportActor = "support"
+
+	// previewTokenPrefix keeps preview tokens apart from management
+	// sessions signed with the same secret.
+	previewTokenPrefix = "preview"
+)
+
+var (
+	ErrSupportPreviewsOff  = errors.New("support previews are not enabled")
+	ErrInvalidPreviewToken = errors.New("invalid preview token")
+	ErrPreviewTokenExpired = errors.New("preview token expired")
+)
+
+// WithSupportPreviews lets the admin API hand out preview tokens signed with
+// secret, valid for ttl.
+func (s *AdminService) WithSupportPreviews(secret []byte, ttl time.Duration) *AdminService {
+	s.previewSecret = secret
+	s.previewTTL = ttl
+	return s
+}
+
+type previewAudit struct {
+	ExpiresAt string `json:"expires_at"`
+}
+
+// CreatePreviewToken issues a token that lets support staff view the
+// metadata and event history of one share until it expires. Issuing it is
+// audited.
+func (s *AdminService) CreatePreviewToken(ctx context.Context, shareID, actor string) (types.SupportPreviewToken, error) {
+	if s.previewSecret == nil {
+		return types.SupportPreviewToken{}, ErrSupportPreviewsOff
+	}
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.SupportPreviewToken{}, ErrNotFound
+		}
+		return types.SupportPreviewToken{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.previewTTL)
+	if err := s.auditPreview(ctx, file.ID, AuditActionSupportPreviewCreated, actor, expiresAt); err != nil {
+		return types.SupportPreviewToken{}, err
+	}
+
+	slog.Info("support preview token created",
+		slog.String("share_id", shareID),
+		slog.String("expires_at", formatTime(expiresAt)),
+		slog.String("actor", actor),
+	)
+
+	payload := previewPayload(shareID, expiresAt.Unix())
+	return types.SupportPreviewToken{
+		ShareID:   shareID,
+		Token:     payload + "." + crypto.Sign(s.previewSecret, payload),
+		ExpiresAt: formatTime(expiresAt),
+	}, nil
+}
+
+// PreviewShare returns what support may see of a share: its state, sizes
+// and event history, never its salt, names, tokens or content. The access
+// is audited before anything is returned.
+func (s *AdminService) PreviewShare(ctx context.Context, shareID, token string) (types.SupportPreview, error) {
+	if s.previewSecret == nil {
+		return types.SupportPreview{}, ErrSupportPreviewsOff
+	}
+	expiresAt, err := s.verifyPreviewToken(shareID, token)
+	if err != nil {
+		return types.SupportPreview{}, err
+	}
+
+	file, err := s.repository.GetFileByShareID(ctx, shareID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return types.SupportPreview{}, ErrNotFound
+		}
+		return types.SupportPreview{}, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if err := s.auditPreview(ctx, file.ID, AuditActionSupportPreviewViewed, supportActor, expiresAt); err != nil {
+		return types.SupportPreview{}, err
+	}
+
+	uploaded, err := s.repository.CountChunksByFileId(ctx, file.ID)
+	if err != nil {
+		return types.SupportPreview{}, fmt.Errorf("failed to count chunks: %w", err)
+	}
+
+	entries, err := s.repository.ListAuditLogByFileId(ctx, file.ID)
+	if err != nil {
+		return types.SupportPreview{}, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	history := make([]types.SupportEvent, len(entries))
+	for i, entry := range entries {
+		// Details may name IPs, links or watermarks, so only the event is shown
+		history[i] = types.SupportEvent{
+			Action:    entry.Action,
+			Actor:     entry.Actor,
+			CreatedAt: formatTimestamptz(entry.CreatedAt),
+		}
+	}
+
+	slog.Info("support preview viewed",
+		slog.String("share_id", shareID),
+	)
+
+	return types.SupportPreview{
+		ShareID:          file.ShareID,
+		Status:           file.Status,
+		TotalSize:        file.TotalSize,
+		ChunkCount:       file.ChunkCount,
+		ChunkSize:        file.ChunkSize,
+		ChunksUploaded:   uploaded,
+		DownloadCount:    file.DownloadCount,
+		MaxDownloads:     file.MaxDownloads,
+		CreatedAt:        formatTimestamptz(file.CreatedAt),
+		ExpiresAt:        formatTimestamptz(file.ExpiresAt),
+		LastDownloadedAt: formatTimestamptz(file.LastDownloadedAt),
+		History:          history,
+	}, nil
+}
+
+func (s *AdminService) auditPreview(ctx context.Context, fileID pgtype.UUID, action, actor string, expiresAt time.Time) error {
+	details, err := json.Marshal(previewAudit{ExpiresAt: formatTime(expiresAt)})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	_, err = s.repository.CreateAuditLogEntry(ctx, sqlc.CreateAuditLogEntryParams{
+		FileID:  fileID,
+		Action:  action,
+		Actor:   actor,
+		Details: details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// verifyPreviewToken checks that token was issued for shareID and has not
+// expired, returning when it expires.
+func (s *AdminService) verifyPreviewToken(shareID, token string) (time.Time, error) {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || !crypto.VerifySignature(s.previewSecret, payload, signature) {
+		return time.Time{}, ErrInvalidPreviewToken
+	}
+
+	scope, expStr, ok := cutLast(payload, ".")
+	if !ok || scope != previewTokenPrefix+"."+shareID {
+		return time.Time{}, ErrInvalidPreviewToken
+	}
+
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidPreviewToken
+	}
+	if time.Now().Unix() >= exp {
+		return time.Time{}, ErrPreviewTokenExpired
+	}
+	return time.Unix(exp, 0), nil
+}
+
+func previewPayload(shareID string, expiresAt int64) string {
+	return strings.Join([]string{previewTokenPrefix, shareID, strconv.FormatInt(expiresAt, 10)}, ".")
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupportPreview(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner).WithSupportPreviews([]byte("preview-secret"), time.Hour)
+	ctx := context.Background()
+
+	file := sqlc.File{ID: createTestUUID(), ShareID: "abc123def456", Status: "ready", Salt: "c2VjcmV0LXNhbHQ="}
+
+	mockRepo.On("GetFileByShareID", ctx, file.ShareID).Return(file, nil)
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(p sqlc.CreateAuditLogEntryParams) bool {
+		return p.Action == AuditActionSupportPreviewCreated && p.Actor == "admin"
+	})).Return(sqlc.AuditLog{}, nil).Once()
+
+	token, err := service.CreatePreviewToken(ctx, file.ShareID, "admin")
+	require.NoError(t, err)
+	assert.NotEmpty(t, token.Token)
+
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.MatchedBy(func(p sqlc.CreateAuditLogEntryParams) bool {
+		return p.Action == AuditActionSupportPreviewViewed && p.Actor == supportActor
+	})).Return(sqlc.AuditLog{}, nil).Once()
+	mockRepo.On("CountChunksByFileId", ctx, file.ID).Return(int64(2), nil)
+	mockRepo.On("ListAuditLogByFileId", ctx, file.ID).Return([]sqlc.AuditLog{
+		{Action: AuditActionLinkCreated, Actor: "uploader", Details: []byte(`{"label":"alice"}`)},
+	}, nil)
+
+	preview, err := service.PreviewShare(ctx, file.ShareID, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), preview.ChunksUploaded)
+	require.Len(t, preview.History, 1)
+	assert.Equal(t, AuditActionLinkCreated, preview.History[0].Action)
+
+	body, err := json.Marshal(preview)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), file.Salt)
+	assert.NotContains(t, string(body), "alice")
+	mockRepo.AssertExpectations(t)
+
+	t.Run("other share", func(t *testing.T) {
+		_, err := service.PreviewShare(ctx, "zzz999zzz999", token.Token)
+		assert.ErrorIs(t, err, ErrInvalidPreviewToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := service.PreviewShare(ctx, file.ShareID, strings.Replace(token.Token, file.ShareID+".", file.ShareID+".9", 1))
+		assert.ErrorIs(t, err, ErrInvalidPreviewToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := NewAdminService(mockRepo, mockTxRunner).WithSupportPreviews([]byte("preview-secret"), -time.Minute)
+		mockRepo.On("CreateAuditLogEntry", ctx, mock.Anything).Return(sqlc.AuditLog{}, nil).Once()
+
+		token, err := expired.CreatePreviewToken(ctx, file.ShareID, "admin")
+		require.NoError(t, err)
+
+		_, err = expired.PreviewShare(ctx, file.ShareID, token.Token)
+		assert.ErrorIs(t, err, ErrPreviewTokenExpired)
+	})
+}
+
+func TestSupportPreview_AuditFailureHidesShare(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewAdminService(mockRepo, mockTxRunner).WithSupportPreviews([]byte("preview-secret"), time.Hour)
+	ctx := context.Background()
+	file := sqlc.File{ID: createTestUUID(), ShareID: "abc123def456", Status: "ready"}
+
+	mockRepo.On("GetFileByShareID", ctx, file.ShareID).Return(file, nil)
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.Anything).Return(sqlc.AuditLog{}, nil).Once()
+	token, err := service.CreatePreviewToken(ctx, file.ShareID, "admin")
+	require.NoError(t, err)
+
+	mockRepo.On("CreateAuditLogEntry", ctx, mock.Anything).Return(sqlc.AuditLog{}, errors.New("connection reset")).Once()
+
+	_, err = service.PreviewShare(ctx, file.ShareID, token.Token)
+
+	require.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ListAuditLogByFileId", mock.Anything, mock.Anything)
+}
+
+func TestCreatePreviewToken_Rejected(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	ctx := context.Background()
+
+	_, err := NewAdminService(mockRepo, mockTxRunner).CreatePreviewToken(ctx, "abc123def456", "admin")
+	assert.ErrorIs(t, err, ErrSupportPreviewsOff)
+
+	mockRepo.On("GetFileByShareID", ctx, "missing").Return(sqlc.File{}, database.ErrNotFound)
+	_, err = NewAdminService(mockRepo, mockTxRunner).WithSupportPreviews([]byte("preview-secret"), time.Hour).
+		CreatePreviewToken(ctx, "missing", "admin")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
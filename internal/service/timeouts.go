@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrOperationTimeout = errors.New("operation timed out")
+
+// withTimeout runs op with ctx bounded by d; zero leaves it to ctx. Running
+// out of d, rather than ctx ending, is reported as ErrOperationTimeout.
+func withTimeout[T any](ctx context.Context, d time.Duration, op func(context.Context) (T, error)) (T, error) {
+	if d <= 0 {
+		return op(ctx)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	v, err := op(opCtx)
+	if err != nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return v, fmt.Errorf("%w after %s: %w", ErrOperationTimeout, d, err)
+	}
+	return v, err
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// formatTime renders a timestamp for an API response. Every timestamp the
+// API returns is RFC 3339 in UTC, whatever the server's local zone.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatTimestamptz is formatTime for a nullable column; NULL is empty.
+func formatTimestamptz(ts pgtype.Timestamptz) string {
+	if !ts.Valid {
+		return ""
+	}
+	return formatTime(ts.Time)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// bytesServed sums the chunk bytes sent to downloaders since the process
+// started, whether or not transfer stats are recorded.
+var bytesServed = expvar.NewInt("download_bytes_served")
+
+type transferKey struct {
+	fileID pgtype.UUID
+	day    time.Time
+}
+
+// TransferStats sums the bytes served per file and UTC day in memory until
+// Flush adds them to the transfer_stats table, so streaming a chunk costs no
+// database write.
+type TransferStats struct {
+	repository sqlc.Querier
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending map[transferKey]int64
+}
+
+func NewTransferStats(repository sqlc.Querier) *TransferStats {
+	return &TransferStats{
+		repository: repository,
+		now:        time.Now,
+		pending:    make(map[transferKey]int64),
+	}
+}
+
+// Add counts n bytes of fileID served today.
+func (t *TransferStats) Add(fileID pgtype.UUID, n int64) {
+	if n <= 0 {
+		return
+	}
+	now := t.now().UTC()
+	key := transferKey{fileID: fileID, day: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)}
+
+	t.mu.Lock()
+	t.pending[key] += n
+	t.mu.Unlock()
+}
+
+// Flush writes the bytes counted since the last flush. Counts that fail to
+// be written are kept for the next flush, except those of files deleted in
+// the meantime.
+func (t *TransferStats) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[transferKey]int64)
+	t.mu.Unlock()
+
+	var failed int
+	var lastErr error
+	for key, n := range pending {
+		err := t.repository.AddTransferBytes(ctx, sqlc.AddTransferBytesParams{
+			FileID:      key.fileID,
+			Day:         pgtype.Date{Time: key.day, Valid: true},
+			BytesServed: n,
+		})
+		if err == nil || errors.Is(err, database.ErrInvalidRef) {
+			continue
+		}
+		failed++
+		lastErr = err
+
+		t.mu.Lock()
+		t.pending[key] += n
+		t.mu.Unlock()
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("failed to record %d transfer stat(s): %w", failed, lastErr)
+	}
+	return nil
+}
+
+// meteredReadCloser counts the bytes read from a chunk and adds them to the
+// transfer stats when it is closed, so a download cut short counts only what
+// was sent.
+type meteredReadCloser struct {
+	io.ReadCloser
+	stats  *TransferStats
+	fileID pgtype.UUID
+	n      int64
+	once   sync.Once
+}
+
+func (m *meteredReadCloser) Read(p []byte) (int, error) {
+	n, err := m.ReadCloser.Read(p)
+	m.n += int64(n)
+	return n, err
+}
+
+func (m *meteredReadCloser) Close() error {
+	m.once.Do(func() {
+		bytesServed.Add(m.n)
+		if m.stats != nil {
+			m.stats.Add(m.fileID, m.n)
+		}
+	})
+	return m.ReadCloser.Close()
+}
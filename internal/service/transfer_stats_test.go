@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferStats_MeterCountsBytesRead(t *testing.T) {
+	stats := NewTransferStats(new(MockQuerier))
+	stats.now = func() time.Time { return time.Date(2026, 10, 15, 23, 30, 0, 0, time.FixedZone("", -2*3600)) }
+	cs := &ChunkService{transfers: stats}
+	fileID := createTestUUID()
+
+	body := cs.meter(fileID, io.NopCloser(strings.NewReader("0123456789")))
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(body, buf)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	require.NoError(t, body.Close())
+
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, map[transferKey]int64{{fileID: fileID, day: day}: 4}, stats.pending)
+}
+
+func TestTransferStats_Flush(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	stats := NewTransferStats(mockRepo)
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	stats.now = func() time.Time { return day.Add(time.Hour) }
+	ctx := context.Background()
+
+	served, deleted, failing := createTestUUID(), createTestUUID(), createTestUUID()
+	deleted.Bytes[0], failing.Bytes[0] = 1, 2
+	stats.Add(served, 100)
+	stats.Add(served, 50)
+	stats.Add(deleted, 10)
+	stats.Add(failing, 20)
+
+	params := func(fileID pgtype.UUID, n int64) sqlc.AddTransferBytesParams {
+		return sqlc.AddTransferBytesParams{FileID: fileID, Day: pgtype.Date{Time: day, Valid: true}, BytesServed: n}
+	}
+	mockRepo.On("AddTransferBytes", ctx, params(served, 150)).Return(nil).Once()
+	mockRepo.On("AddTransferBytes", ctx, params(deleted, 10)).Return(database.ErrInvalidRef).Once()
+	mockRepo.On("AddTransferBytes", ctx, params(failing, 20)).Return(errors.New("connection reset")).Once()
+
+	err := stats.Flush(ctx)
+
+	assert.Error(t, err)
+	assert.Equal(t, map[transferKey]int64{{fileID: failing, day: day}: 20}, stats.pending)
+
+	mockRepo.On("AddTransferBytes", ctx, params(failing, 20)).Return(nil).Once()
+	require.NoError(t, stats.Flush(ctx))
+	assert.Empty(t, stats.pending)
+	mockRepo.AssertExpectations(t)
+}
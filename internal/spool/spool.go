@@ -0,0 +1,167 @@
+// Package spool buffers uploaded parts in memory up to a threshold and
+// spills anything larger to a temporary file in a configured directory, so
+// the memory and disk a request can take are both bounded and known.
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// filePrefix names spilled files so stale ones can be told apart from other
+// files in a shared temporary directory.
+const filePrefix = "gzln-spool-"
+
+// staleAfter is how old a spilled file must be before RemoveStale deletes it.
+// Spilled files live no longer than the request that created them.
+const staleAfter = time.Hour
+
+var (
+	ErrTooLarge = errors.New("part exceeds size limit")
+	// ErrDisk marks failures writing a spilled part, as opposed to reading
+	// it from the client.
+	ErrDisk = errors.New("spool disk failure")
+)
+
+// stats counts parts kept in memory and spilled to disk, the bytes spilled
+// and spilled files currently open.
+var stats = expvar.NewMap("multipart_spool")
+
+// Spooler holds up to memory bytes of a part in memory and writes the rest
+// to a temporary file in dir.
+type Spooler struct {
+	memory int64
+	dir    string
+}
+
+// New returns a Spooler. An empty dir uses the system temporary directory.
+func New(memory int64, dir string) *Spooler {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return &Spooler{memory: memory, dir: dir}
+}
+
+// Part is a fully received part. Close releases it and removes its spilled
+// file, if any.
+type Part struct {
+	io.Reader
+	size int64
+	file *os.File
+}
+
+// Size is the exact length of the part.
+func (p *Part) Size() int64 {
+	return p.size
+}
+
+// Spilled reports whether the part did not fit in memory.
+func (p *Part) Spilled() bool {
+	return p.file != nil
+}
+
+func (p *Part) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	stats.Add("open_files", -1)
+	closeErr := p.file.Close()
+	removeErr := os.Remove(p.file.Name())
+	p.file = nil
+	return errors.Join(closeErr, removeErr)
+}
+
+// Spool reads r to the end. Parts larger than limit fail with ErrTooLarge,
+// leaving nothing behind on disk.
+func (s *Spooler) Spool(r io.Reader, limit int64) (*Part, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, min(s.memory, limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= s.memory && n <= limit {
+		stats.Add("in_memory", 1)
+		return &Part{Reader: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+	if n > limit {
+		return nil, ErrTooLarge
+	}
+
+	f, err := os.CreateTemp(s.dir, filePrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDisk, err)
+	}
+	stats.Add("open_files", 1)
+	part := &Part{size: n, file: f}
+
+	rest, err := io.Copy(diskWriter{f}, io.MultiReader(&buf, io.LimitReader(r, limit-n+1)))
+	if err == nil && rest > limit {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		if _, seekErr := f.Seek(0, io.SeekStart); seekErr != nil {
+			err = fmt.Errorf("%w: %w", ErrDisk, seekErr)
+		}
+	}
+	if err != nil {
+		part.Close()
+		return nil, err
+	}
+
+	stats.Add("spilled", 1)
+	stats.Add("spilled_bytes", rest)
+	part.size = rest
+	part.Reader = f
+	return part, nil
+}
+
+// diskWriter tells write failures, such as a full disk, apart from read
+// failures in io.Copy.
+type diskWriter struct {
+	f *os.File
+}
+
+func (w diskWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("%w: %w", ErrDisk, err)
+	}
+	return n, nil
+}
+
+// RemoveStale deletes spilled files left behind by a crashed process and
+// returns how many it removed.
+func (s *Spooler) RemoveStale() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), filePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleAfter {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+			slog.Warn("failed to remove stale spool file",
+				slog.String("name", entry.Name()),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
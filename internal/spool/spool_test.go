@@ -0,0 +1,102 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool_InMemory(t *testing.T) {
+	dir := t.TempDir()
+	s := New(16, dir)
+
+	part, err := s.Spool(strings.NewReader("small chunk"), 64)
+	require.NoError(t, err)
+	defer part.Close()
+
+	assert.False(t, part.Spilled())
+	assert.Equal(t, int64(11), part.Size())
+	data, err := io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "small chunk", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_SpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	s := New(16, dir)
+	payload := bytes.Repeat([]byte("x"), 100)
+
+	before := stats.Get("spilled")
+	part, err := s.Spool(bytes.NewReader(payload), 100)
+	require.NoError(t, err)
+
+	assert.True(t, part.Spilled())
+	assert.Equal(t, int64(100), part.Size())
+	assert.NotEqual(t, before, stats.Get("spilled"))
+	data, err := io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, part.Close())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_TooLarge(t *testing.T) {
+	for _, memory := range []int64{16, 1 << 10} {
+		dir := t.TempDir()
+		s := New(memory, dir)
+
+		_, err := s.Spool(bytes.NewReader(bytes.Repeat([]byte("x"), 101)), 100)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "memory %d", memory)
+	}
+}
+
+func TestSpool_DiskFailure(t *testing.T) {
+	s := New(16, filepath.Join(t.TempDir(), "missing"))
+
+	_, err := s.Spool(bytes.NewReader(bytes.Repeat([]byte("x"), 100)), 100)
+	assert.ErrorIs(t, err, ErrDisk)
+}
+
+func TestRemoveStale(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * staleAfter)
+
+	stale := filepath.Join(dir, filePrefix+"stale")
+	require.NoError(t, os.WriteFile(stale, []byte("x"), 0o600))
+	require.NoError(t, os.Chtimes(stale, old, old))
+	fresh := filepath.Join(dir, filePrefix+"fresh")
+	require.NoError(t, os.WriteFile(fresh, []byte("x"), 0o600))
+	other := filepath.Join(dir, "unrelated")
+	require.NoError(t, os.WriteFile(other, []byte("x"), 0o600))
+	require.NoError(t, os.Chtimes(other, old, old))
+
+	removed, err := New(16, dir).RemoveStale()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, fresh)
+	assert.FileExists(t, other)
+}
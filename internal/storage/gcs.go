@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	gcsEndpoint         = "storage.googleapis.com"
+	defaultMetadataHost = "metadata.google.internal"
+	metadataTokenPath   = "/computeMetadata/v1/instance/service-accounts/default/token"
+	// tokenRefreshMargin renews tokens this long before they expire.
+	tokenRefreshMargin = time.Minute
+)
+
+// NewGCSClient connects to Google Cloud Storage through its S3-compatible
+// XML API. Requests carry an OAuth token for the service account attached
+// to the instance, GKE workload or Cloud Run service, fetched from the
+// metadata server, so no static keys are needed.
+//
+// GCS supports neither multi-object deletes nor SigV4 presigning with OAuth
+// credentials, so chunks are removed one at a time and presigned uploads are
+// unavailable.
+func NewGCSClient() (*MinIOClient, error) {
+	bucketName, err := bucketFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := minio.DefaultTransport(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcs transport: %w", err)
+	}
+	transport := &bearerTransport{
+		base:   base,
+		tokens: newMetadataTokenSource(metadataHost()),
+	}
+
+	client, err := minio.New(gcsEndpoint, &minio.Options{
+		// Requests are authorized by the bearer token, not a signature
+		Creds:     credentials.NewStatic("", "", "", credentials.SignatureAnonymous),
+		Secure:    true,
+		Region:    "auto",
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcs client: %w", err)
+	}
+
+	if err := requireBucket(client, bucketName); err != nil {
+		return nil, err
+	}
+
+	slog.Info("using gcs storage",
+		slog.String("bucket_name", bucketName),
+	)
+
+	return &MinIOClient{
+		Client:     client,
+		BucketName: bucketName,
+		Provider:   ProviderGCS,
+		BulkDelete: false,
+		transport:  base,
+	}, nil
+}
+
+// metadataHost honours GCE_METADATA_HOST like Google's own client libraries.
+func metadataHost() string {
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		return host
+	}
+	return defaultMetadataHost
+}
+
+// bearerTransport authorizes every request with a token from tokens.
+type bearerTransport struct {
+	base   http.RoundTripper
+	tokens *metadataTokenSource
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gcs access token: %w", err)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// metadataTokenSource caches access tokens from the GCE metadata server.
+type metadataTokenSource struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newMetadataTokenSource(host string) *metadataTokenSource {
+	return &metadataTokenSource{
+		url:    "http://" + host + metadataTokenPath,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *metadataTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(tokenRefreshMargin).Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid metadata token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("metadata server returned no access token")
+	}
+
+	s.token = body.AccessToken
+	s.expires = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return s.token, nil
+}
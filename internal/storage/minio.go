@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 
 	"github.com/minio/minio-go/v7"
@@ -12,8 +13,17 @@ import (
 )
 
 type MinIOClient struct {
-	Client     *minio.Client
-	BucketName string
+	Client *minio.Client
+	// PresignClient signs URLs handed to browsers. It targets
+	// MINIO_PUBLIC_ENDPOINT when that differs from the internal endpoint,
+	// and is nil when the provider cannot presign.
+	PresignClient *minio.Client
+	BucketName    string
+	// Provider is the STORAGE_PROVIDER the client talks to.
+	Provider string
+	// BulkDelete reports whether the provider supports multi-object deletes.
+	BulkDelete bool
+	transport  http.RoundTripper
 }
 
 func NewMinIOClient() (*MinIOClient, error) {
@@ -23,9 +33,15 @@ func NewMinIOClient() (*MinIOClient, error) {
 	useSSL := os.Getenv("MINIO_USE_SSL") == "true"
 	bucketName := os.Getenv("MINIO_BUCKET_NAME")
 
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minio transport: %w", err)
+	}
+
 	client, error := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
+		Creds:     credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:    useSSL,
+		Transport: transport,
 	})
 
 	if error != nil {
@@ -48,12 +64,41 @@ func NewMinIOClient() (*MinIOClient, error) {
 		)
 	}
 
+	presignClient := client
+	if publicEndpoint := os.Getenv("MINIO_PUBLIC_ENDPOINT"); publicEndpoint != "" && publicEndpoint != endpoint {
+		// Signing needs no connection, but the region must be known up front
+		// or the client asks the public endpoint for it
+		region := os.Getenv("MINIO_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		presignClient, err = minio.New(publicEndpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+			Secure: os.Getenv("MINIO_PUBLIC_USE_SSL") == "true",
+			Region: region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create minio presign client: %w", err)
+		}
+	}
+
 	return &MinIOClient{
-		Client:     client,
-		BucketName: bucketName,
+		Client:        client,
+		PresignClient: presignClient,
+		BucketName:    bucketName,
+		Provider:      ProviderMinIO,
+		BulkDelete:    true,
+		transport:     transport,
 	}, nil
 }
 
+// Close releases idle connections held by the client.
+func (m MinIOClient) Close() {
+	if t, ok := m.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+}
+
 func (m MinIOClient) UploadFile(ctx context.Context, file io.Reader, fileID string, chunkIndex string, fileSize int64) (minio.UploadInfo, error) {
 	uniqueFileName := fmt.Sprintf("%s::%s", fileID, chunkIndex)
 
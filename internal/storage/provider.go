@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Providers accepted in STORAGE_PROVIDER.
+const (
+	ProviderMinIO = "minio"
+	ProviderS3    = "s3"
+	ProviderGCS   = "gcs"
+)
+
+// New connects to the object storage selected by STORAGE_PROVIDER, MinIO by
+// default.
+func New() (*MinIOClient, error) {
+	switch provider := strings.ToLower(os.Getenv("STORAGE_PROVIDER")); provider {
+	case "", ProviderMinIO:
+		return NewMinIOClient()
+	case ProviderS3:
+		return NewS3Client()
+	case ProviderGCS:
+		return NewGCSClient()
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_PROVIDER %q", provider)
+	}
+}
+
+// bucketFromEnv returns STORAGE_BUCKET, falling back to MINIO_BUCKET_NAME.
+func bucketFromEnv() (string, error) {
+	bucket := os.Getenv("STORAGE_BUCKET")
+	if bucket == "" {
+		bucket = os.Getenv("MINIO_BUCKET_NAME")
+	}
+	if bucket == "" {
+		return "", fmt.Errorf("STORAGE_BUCKET is required")
+	}
+	return bucket, nil
+}
+
+// requireBucket fails unless bucket exists. Managed buckets are provisioned
+// with their IAM policies outside the app, so they are never created here.
+func requireBucket(client *minio.Client, bucket string) error {
+	exists, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", bucket)
+	}
+	return nil
+}
+
+// IsUnreachable reports whether err means the storage endpoint could not be
+// reached or is not ready yet, as while it is still starting, rather than
+// that it refused the configuration.
+func IsUnreachable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && resp.StatusCode == http.StatusServiceUnavailable
+}
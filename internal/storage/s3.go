@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// NewS3Client connects to Amazon S3. Credentials come from the standard AWS
+// chain: AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, the shared credentials
+// file, then the instance, ECS task or web identity (IRSA) role.
+func NewS3Client() (*MinIOClient, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION is required for the s3 storage provider")
+	}
+
+	bucketName, err := bucketFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+
+	transport, err := minio.DefaultTransport(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 transport: %w", err)
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure:    true,
+		Region:    region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	if err := requireBucket(client, bucketName); err != nil {
+		return nil, err
+	}
+
+	slog.Info("using s3 storage",
+		slog.String("bucket_name", bucketName),
+		slog.String("region", region),
+	)
+
+	return &MinIOClient{
+		Client:        client,
+		PresignClient: client,
+		BucketName:    bucketName,
+		Provider:      ProviderS3,
+		BulkDelete:    true,
+		transport:     transport,
+	}, nil
+}
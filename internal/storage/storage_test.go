@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_UnknownProvider(t *testing.T) {
+	t.Setenv("STORAGE_PROVIDER", "azure")
+
+	_, err := New()
+
+	assert.ErrorContains(t, err, "unknown STORAGE_PROVIDER")
+}
+
+func TestNewS3Client_RequiresRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := NewS3Client()
+
+	assert.ErrorContains(t, err, "AWS_REGION")
+}
+
+func TestIsUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := srv.Listener.Addr().String()
+	srv.Close()
+
+	_, err := http.Get("http://" + addr)
+	require.Error(t, err)
+	assert.True(t, IsUnreachable(fmt.Errorf("failed to check bucket: %w", err)))
+
+	assert.True(t, IsUnreachable(minio.ErrorResponse{StatusCode: http.StatusServiceUnavailable, Code: "XMinioServerNotInitialized"}))
+	assert.False(t, IsUnreachable(minio.ErrorResponse{StatusCode: http.StatusForbidden, Code: "AccessDenied"}))
+	assert.False(t, IsUnreachable(fmt.Errorf("bucket %q does not exist", "gzln")))
+}
+
+func TestBucketFromEnv(t *testing.T) {
+	t.Setenv("STORAGE_BUCKET", "")
+	t.Setenv("MINIO_BUCKET_NAME", "legacy")
+	bucket, err := bucketFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", bucket)
+
+	t.Setenv("STORAGE_BUCKET", "managed")
+	bucket, err = bucketFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "managed", bucket)
+
+	t.Setenv("STORAGE_BUCKET", "")
+	t.Setenv("MINIO_BUCKET_NAME", "")
+	_, err = bucketFromEnv()
+	assert.Error(t, err)
+}
+
+// newMetadataServer serves tokens like the GCE metadata server, each with a
+// new value so refreshes are visible.
+func newMetadataServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, metadataTokenPath, r.URL.Path)
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		n := calls.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d,"token_type":"Bearer"}`, n, expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestMetadataTokenSource_CachesToken(t *testing.T) {
+	srv, calls := newMetadataServer(t, 3600)
+	tokens := newMetadataTokenSource(strings.TrimPrefix(srv.URL, "http://"))
+	ctx := context.Background()
+
+	first, err := tokens.Token(ctx)
+	require.NoError(t, err)
+	second, err := tokens.Token(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, "token-1", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestMetadataTokenSource_RefreshesNearExpiry(t *testing.T) {
+	// Tokens inside the refresh margin are renewed on every use
+	srv, calls := newMetadataServer(t, 30)
+	tokens := newMetadataTokenSource(strings.TrimPrefix(srv.URL, "http://"))
+	ctx := context.Background()
+
+	_, err := tokens.Token(ctx)
+	require.NoError(t, err)
+	second, err := tokens.Token(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, "token-2", second)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestBearerTransport_SetsAuthorization(t *testing.T) {
+	metadata, _ := newMetadataServer(t, 3600)
+
+	var auth string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: &bearerTransport{
+		base:   http.DefaultTransport,
+		tokens: newMetadataTokenSource(strings.TrimPrefix(metadata.URL, "http://")),
+	}}
+	req, err := http.NewRequest(http.MethodGet, backend.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "Bearer token-1", auth)
+	assert.Empty(t, req.Header.Get("Authorization"), "caller's request must not be modified")
+}
+
+func TestBearerTransport_MetadataUnavailable(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer metadata.Close()
+
+	client := &http.Client{Transport: &bearerTransport{
+		base:   http.DefaultTransport,
+		tokens: newMetadataTokenSource(strings.TrimPrefix(metadata.URL, "http://")),
+	}}
+
+	_, err := client.Get("http://127.0.0.1:1/")
+
+	assert.ErrorContains(t, err, "failed to get gcs access token")
+}
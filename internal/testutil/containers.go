@@ -2,14 +2,13 @@ package testutil
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"testing"
 	"time"
 
+	migrations "github.com/ilkin0/gzln/db"
+	"github.com/ilkin0/gzln/internal/config"
 	"github.com/ilkin0/gzln/internal/database"
+	"github.com/ilkin0/gzln/internal/repository/sqlc"
 	"github.com/ilkin0/gzln/internal/storage"
 	"github.com/minio/minio-go/v7"
 	"github.com/testcontainers/testcontainers-go"
@@ -26,6 +25,12 @@ type TestContainers struct {
 	Cleanup           func()
 }
 
+// Querier returns the database's queries behind the repository layer the
+// server uses, so services see classified errors.
+func (c *TestContainers) Querier() sqlc.Querier {
+	return database.NewRetryingQuerier(c.Database.Queries, database.DefaultRetryPolicy(1))
+}
+
 func SetupTestContainers(t *testing.T) *TestContainers {
 	t.Helper()
 
@@ -52,13 +57,13 @@ func SetupTestContainers(t *testing.T) *TestContainers {
 
 	t.Setenv("DB_URL", connStr)
 
-	db, err := database.NewDatabase(ctx)
+	db, err := database.NewDatabase(ctx, config.Database{})
 	if err != nil {
 		pgContainer.Terminate(ctx)
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	if err := runMigrations(); err != nil {
+	if _, err := database.Migrate(ctx, db.Pool, migrations.Migrations()); err != nil {
 		db.Pool.Close()
 		pgContainer.Terminate(ctx)
 		t.Fatalf("Failed to run migrations: %v", err)
@@ -125,45 +130,6 @@ func SetupTestContainers(t *testing.T) *TestContainers {
 	}
 }
 
-func findProjectRoot() (string, error) {
-	dir, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
-			return dir, nil
-		}
-
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			return "", fmt.Errorf("could not find project root (go.mod)")
-		}
-		dir = parent
-	}
-}
-
-func runMigrations() error {
-	projectRoot, err := findProjectRoot()
-	if err != nil {
-		return fmt.Errorf("failed to find project root: %w", err)
-	}
-
-	migrationDir := filepath.Join(projectRoot, "db/migration")
-	dbURL := os.Getenv("DB_URL")
-
-	cmd := exec.Command("goose", "-dir", migrationDir, "postgres", dbURL, "up")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run goose migrations: %w", err)
-	}
-
-	return nil
-}
-
 func CleanDatabase(ctx context.Context, db *database.Database) {
 	db.Pool.Exec(ctx, "TRUNCATE TABLE files CASCADE")
 }
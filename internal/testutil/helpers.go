@@ -12,6 +12,7 @@ import (
 
 	"github.com/ilkin0/gzln/internal/database"
 	"github.com/ilkin0/gzln/internal/repository/sqlc"
+	"github.com/ilkin0/gzln/pkg/e2ee"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/require"
@@ -160,6 +161,16 @@ func CreateUploadingFile(t *testing.T, queries *sqlc.Queries, ctx context.Contex
 	return CreateTestFile(t, queries, ctx, opts)
 }
 
+// ChunkData returns filler of exactly the encrypted size the server expects
+// for chunk chunkIndex of file.
+func ChunkData(file sqlc.File, chunkIndex int32) []byte {
+	size := int64(file.ChunkSize)
+	if chunkIndex == file.ChunkCount-1 {
+		size = file.TotalSize - int64(file.ChunkSize)*int64(file.ChunkCount-1)
+	}
+	return bytes.Repeat([]byte{byte('a' + chunkIndex%26)}, int(size)+e2ee.Overhead)
+}
+
 func UploadTestChunks(t *testing.T, minioClient *minio.Client, bucketName string, fileID string, chunkCount int) {
 	t.Helper()
 	ctx := context.Background()
@@ -2,19 +2,40 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// NDJSONContentType is newline-delimited JSON, one value per line.
+const NDJSONContentType = "application/x-ndjson"
+
+// TextContentType is the content type of errors rendered for clients that
+// prefer plain text.
+const TextContentType = "text/plain; charset=utf-8"
+
 type APIResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
-	Data    any    `json:"data,omitempty"`
+	// Code is a stable, machine-readable reason for errors clients act on.
+	Code string `json:"code,omitempty"`
+	// Details carries structured context for an error code.
+	Details any `json:"details,omitempty"`
+	Data    any `json:"data,omitempty"`
 }
 
+// WriteJSON writes resp as JSON, or an error as one line of text to clients
+// that prefer text/plain (see WithTextErrors).
 func WriteJSON(w http.ResponseWriter, status int, resp APIResponse) {
+	if !resp.Success && prefersTextErrors(w) {
+		writeTextError(w, status, resp)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 
@@ -39,6 +60,26 @@ func Error(w http.ResponseWriter, status int, msg string) {
 	})
 }
 
+// ErrorWithCode is Error with a machine-readable code clients can branch on.
+func ErrorWithCode(w http.ResponseWriter, status int, code, msg string) {
+	WriteJSON(w, status, APIResponse{
+		Success: false,
+		Message: msg,
+		Code:    code,
+	})
+}
+
+// ErrorWithDetails is ErrorWithCode plus structured details, such as values
+// a client can use to correct and retry its request.
+func ErrorWithDetails(w http.ResponseWriter, status int, code, msg string, details any) {
+	WriteJSON(w, status, APIResponse{
+		Success: false,
+		Message: msg,
+		Code:    code,
+		Details: details,
+	})
+}
+
 func StreamBinary(
 	w http.ResponseWriter,
 	r io.Reader,
@@ -55,6 +96,19 @@ func StreamBinary(
 	return err
 }
 
+// WithoutTimeouts exempts a download from the server's read and write
+// timeouts. How long it takes depends on the file, its throttle and the
+// client's bandwidth, so no single deadline fits.
+func WithoutTimeouts(w http.ResponseWriter) {
+	clearDeadlines(http.NewResponseController(w))
+}
+
+func WithETag(etag string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("ETag", etag)
+	}
+}
+
 func WithContentLength(n int64) func(http.ResponseWriter) {
 	return func(w http.ResponseWriter) {
 		w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
@@ -69,3 +123,205 @@ func WithFilename(name string) func(http.ResponseWriter) {
 		)
 	}
 }
+
+// textErrorWriter marks a response whose errors WriteJSON renders as text.
+type textErrorWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (t *textErrorWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// WithTextErrors returns w marked so that errors written to it, or to
+// writers wrapping it, are rendered as a line of text instead of JSON.
+func WithTextErrors(w http.ResponseWriter) http.ResponseWriter {
+	return &textErrorWriter{ResponseWriter: w}
+}
+
+// prefersTextErrors reports whether w, or a writer it wraps, was marked by
+// WithTextErrors.
+func prefersTextErrors(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case *textErrorWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// writeTextError writes an error as "code: message", or just the message
+// for errors without a code.
+func writeTextError(w http.ResponseWriter, status int, resp APIResponse) {
+	line := resp.Message
+	if line == "" {
+		line = http.StatusText(status)
+	}
+	if resp.Code != "" {
+		line = resp.Code + ": " + line
+	}
+
+	w.Header().Set("Content-Type", TextContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, line+"\n")
+}
+
+// PrefersText reports whether the request's Accept header ranks text/plain
+// above JSON. Requests without one, or accepting both equally, get JSON.
+func PrefersText(r *http.Request) bool {
+	return acceptQuality(r, "text/plain") > acceptQuality(r, "application/json")
+}
+
+// acceptQuality returns the quality the Accept header gives mediaType, from
+// the most specific media range that matches it.
+func acceptQuality(r *http.Request, mediaType string) float64 {
+	accepts := r.Header.Values("Accept")
+	if len(accepts) == 0 {
+		return 1
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, accept := range accepts {
+		for mediaRange := range strings.SplitSeq(accept, ",") {
+			name, params, _ := strings.Cut(mediaRange, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+
+			var s int
+			switch {
+			case name == mediaType:
+				s = 2
+			case name == typ+"/*":
+				s = 1
+			case name == "*/*":
+				s = 0
+			default:
+				continue
+			}
+			if s < specificity {
+				continue
+			}
+			specificity, quality = s, rangeQuality(params)
+		}
+	}
+	return quality
+}
+
+// rangeQuality parses the q parameter of a media range, defaulting to 1.
+func rangeQuality(params string) float64 {
+	for param := range strings.SplitSeq(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
+
+// AcceptsNDJSON reports whether the request's Accept header asks for
+// NDJSON.
+func AcceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for mediaRange := range strings.SplitSeq(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), NDJSONContentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NDJSONWriter writes values to a response one per line, flushing after each
+// so the response is never held in full by the server.
+type NDJSONWriter struct {
+	enc *json.Encoder
+	rc  *http.ResponseController
+}
+
+func NewNDJSONWriter(w http.ResponseWriter) *NDJSONWriter {
+	w.Header().Set("Content-Type", NDJSONContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	rc := http.NewResponseController(w)
+	clearDeadlines(rc)
+	return &NDJSONWriter{
+		enc: json.NewEncoder(w),
+		rc:  rc,
+	}
+}
+
+func (n *NDJSONWriter) Write(v any) error {
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	if err := n.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// SSEWriter writes server-sent events, flushing after each so clients see
+// them as they happen.
+type SSEWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func NewSSEWriter(w http.ResponseWriter) *SSEWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Keep reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+	clearDeadlines(rc)
+	return &SSEWriter{
+		w:  w,
+		rc: rc,
+	}
+}
+
+// Event writes v as the JSON data of an event named name.
+func (s *SSEWriter) Event(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// Ping writes a comment, which clients ignore, to keep idle connections from
+// being closed by proxies.
+func (s *SSEWriter) Ping() error {
+	if _, err := io.WriteString(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *SSEWriter) flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// clearDeadlines exempts a stream from the server's read and write timeouts,
+// which bound ordinary requests but would cut streams off while they are
+// still in use.
+func clearDeadlines(rc *http.ResponseController) {
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithoutTimeouts_ThrottledStreamOutlivesWriteTimeout(t *testing.T) {
+	// At 64 KiB/s, 48 KiB take most of a second, well past the timeout
+	data := bytes.Repeat([]byte("x"), 48<<10)
+	const rate = 64 << 10
+
+	serve := func(opts ...func(http.ResponseWriter)) *httptest.Server {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			opts := append([]func(http.ResponseWriter){WithContentLength(int64(len(data)))}, opts...)
+			_ = StreamBinary(w, Throttle(r.Context(), bytes.NewReader(data), rate), opts...)
+		}))
+		srv.Config.WriteTimeout = 100 * time.Millisecond
+		srv.Start()
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	t.Run("exempt", func(t *testing.T) {
+		resp, err := http.Get(serve(WithoutTimeouts).URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, data, body)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		resp, err := http.Get(serve().URL)
+		if err == nil {
+			defer resp.Body.Close()
+			_, err = io.ReadAll(resp.Body)
+		}
+		assert.Error(t, err, "the write timeout should cut the stream short")
+	})
+}
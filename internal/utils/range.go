@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// ByteRange is an inclusive range of bytes requested in a Range header.
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (b ByteRange) Length() int64 {
+	return b.End - b.Start + 1
+}
+
+// ContentRange is the Content-Range header value for b within size bytes.
+func (b ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", b.Start, b.End, size)
+}
+
+// ParseRange reads a single byte range from a Range header for a body of
+// size bytes. ok is false when the header is absent, malformed or asks for
+// several ranges; the whole body should be sent then, as RFC 9110 allows.
+// Ranges starting past the end fail with ErrRangeNotSatisfiable.
+func ParseRange(header string, size int64) (ByteRange, bool, error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return ByteRange{}, false, nil
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return ByteRange{}, false, nil
+	}
+
+	// A suffix range asks for the last n bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return ByteRange{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return ByteRange{}, false, ErrRangeNotSatisfiable
+		}
+		return ByteRange{Start: max(size-n, 0), End: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return ByteRange{}, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return ByteRange{}, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return ByteRange{}, false, ErrRangeNotSatisfiable
+	}
+	return ByteRange{Start: start, End: end}, true, nil
+}
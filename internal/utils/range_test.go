@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    ByteRange
+		wantOK  bool
+		wantErr error
+	}{
+		{header: "", wantOK: false},
+		{header: "bytes=0-99", want: ByteRange{0, 99}, wantOK: true},
+		{header: "bytes=100-", want: ByteRange{100, 999}, wantOK: true},
+		{header: "bytes=900-5000", want: ByteRange{900, 999}, wantOK: true},
+		{header: "bytes=-100", want: ByteRange{900, 999}, wantOK: true},
+		{header: "bytes=-5000", want: ByteRange{0, 999}, wantOK: true},
+		{header: "bytes=1000-", wantErr: ErrRangeNotSatisfiable},
+		{header: "bytes=-0", wantErr: ErrRangeNotSatisfiable},
+		{header: "bytes=0-9,20-29", wantOK: false},
+		{header: "bytes=9-0", wantOK: false},
+		{header: "bytes=abc", wantOK: false},
+		{header: "items=0-9", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok, err := ParseRange(tt.header, 1000)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestByteRange_ContentRange(t *testing.T) {
+	assert.Equal(t, "bytes 900-999/1000", ByteRange{900, 999}.ContentRange(1000))
+	assert.Equal(t, int64(100), ByteRange{900, 999}.Length())
+}
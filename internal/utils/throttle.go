@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// minThrottleBurst is the smallest read a throttled reader passes through
+// at once, so slow rates do not turn into tiny writes.
+const minThrottleBurst = 4 * 1024
+
+// Throttle limits reads from r to bytesPerSecond with a token bucket holding
+// a tenth of a second of data, or minThrottleBurst if more. A rate of zero
+// or less returns r unchanged. Waiting for tokens ends with ctx's error once
+// ctx is done.
+func Throttle(ctx context.Context, r io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+	burst := max(bytesPerSecond/10, minThrottleBurst)
+	return &throttledReader{
+		ctx:    ctx,
+		r:      r,
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+		sleep:  sleepContext,
+	}
+}
+
+type throttledReader struct {
+	ctx    context.Context
+	r      io.Reader
+	rate   float64
+	burst  int64
+	tokens float64
+	last   time.Time
+	sleep  func(context.Context, time.Duration) error
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > t.burst {
+		p = p[:t.burst]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.wait(n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// wait takes n tokens, sleeping until the bucket has refilled enough to
+// cover them.
+func (t *throttledReader) wait(n int) error {
+	now := time.Now()
+	t.tokens = min(t.tokens+now.Sub(t.last).Seconds()*t.rate, float64(t.burst))
+	t.last = now
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return nil
+	}
+	return t.sleep(t.ctx, time.Duration(-t.tokens/t.rate*float64(time.Second)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	data := strings.Repeat("x", 20_000)
+	plain := strings.NewReader(data)
+	assert.Equal(t, io.Reader(plain), Throttle(context.Background(), plain, 0))
+
+	r := Throttle(context.Background(), strings.NewReader(data), 8_000).(*throttledReader)
+	var slept time.Duration
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		// Pretend d passed since the bucket was last refilled
+		slept += d
+		r.last = r.last.Add(-d)
+		return nil
+	}
+
+	var out bytes.Buffer
+	_, err := io.Copy(&out, r)
+	require.NoError(t, err)
+	assert.Equal(t, data, out.String())
+	// The first burst is free and the rest comes at the rate
+	assert.InDelta(t, (20_000-minThrottleBurst)/8_000.0, slept.Seconds(), 0.05)
+}
+
+func TestThrottle_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := Throttle(ctx, strings.NewReader(strings.Repeat("x", 20_000)), 1_000)
+	_, err := io.ReadAll(r)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
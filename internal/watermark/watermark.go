@@ -0,0 +1,142 @@
+// Package watermark rewrites the plaintext of server encrypted files as they
+// are downloaded, e.g. stamping a PDF with the name of its recipient, so a
+// leaked copy can be traced. Transformers only see files the server can
+// decrypt; end-to-end encrypted files are never transformed.
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mark describes the download a watermark is applied to.
+type Mark struct {
+	ShareID string
+	// Text is the watermark set on the share, usually naming its recipient.
+	Text     string
+	ClientIP string
+	ServedAt time.Time
+}
+
+// Transformer rewrites the plaintext of a file on its way to a downloader.
+type Transformer interface {
+	// Transform returns the transformed plaintext read from src. Reading
+	// it fails when the transformation does, and closing it before the end
+	// stops the transformation.
+	Transform(ctx context.Context, src io.Reader, mark Mark) (io.ReadCloser, error)
+}
+
+// maxStderr bounds how much of a failing command's stderr is reported.
+const maxStderr = 4 << 10
+
+// Command is a Transformer running an external program, which reads the
+// plaintext on stdin and writes the transformed file to stdout. The mark is
+// passed in the GZLN_SHARE_ID, GZLN_WATERMARK, GZLN_CLIENT_IP and
+// GZLN_SERVED_AT environment variables, and a non-zero exit fails the
+// download.
+type Command struct {
+	path string
+	args []string
+}
+
+func NewCommand(path string, args ...string) *Command {
+	return &Command{path: path, args: args}
+}
+
+// CommandFromEnv builds a Command from WATERMARK_COMMAND, a program and its
+// arguments separated by spaces. It returns nil when the variable is unset.
+func CommandFromEnv() (*Command, error) {
+	fields := strings.Fields(os.Getenv("WATERMARK_COMMAND"))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	path, err := exec.LookPath(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATERMARK_COMMAND: %w", err)
+	}
+	return NewCommand(path, fields[1:]...), nil
+}
+
+func (c *Command) Transform(ctx context.Context, src io.Reader, mark Mark) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.Env = append(os.Environ(),
+		"GZLN_SHARE_ID="+mark.ShareID,
+		"GZLN_WATERMARK="+mark.Text,
+		"GZLN_CLIENT_IP="+mark.ClientIP,
+		"GZLN_SERVED_AT="+mark.ServedAt.UTC().Format(time.RFC3339),
+	)
+	cmd.Stdin = src
+	out := &commandOutput{cmd: cmd}
+	cmd.Stderr = &out.stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start watermark command: %w", err)
+	}
+	out.stdout = stdout
+	return out, nil
+}
+
+// commandOutput reads a command's stdout, reporting how it exited once
+// stdout ends.
+type commandOutput struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr limitedBuffer
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func (o *commandOutput) Read(p []byte) (int, error) {
+	n, err := o.stdout.Read(p)
+	if errors.Is(err, io.EOF) {
+		if werr := o.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if it is still running.
+func (o *commandOutput) Close() error {
+	if o.cmd.ProcessState == nil {
+		o.cmd.Process.Kill()
+	}
+	o.wait()
+	return nil
+}
+
+func (o *commandOutput) wait() error {
+	o.waitOnce.Do(func() {
+		if err := o.cmd.Wait(); err != nil {
+			o.waitErr = fmt.Errorf("watermark command failed: %w: %s", err, bytes.TrimSpace(o.stderr.Bytes()))
+		}
+	})
+	return o.waitErr
+}
+
+// limitedBuffer keeps the first maxStderr bytes written to it and discards
+// the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxStderr - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
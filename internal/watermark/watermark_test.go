@@ -0,0 +1,57 @@
+package watermark
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Transform(t *testing.T) {
+	cmd := NewCommand("/bin/sh", "-c", `cat; printf '\n%s %s' "$GZLN_WATERMARK" "$GZLN_SHARE_ID"`)
+
+	out, err := cmd.Transform(context.Background(), strings.NewReader("plaintext"), Mark{
+		ShareID:  "abc123def456",
+		Text:     "for alice",
+		ServedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	defer out.Close()
+
+	body, err := io.ReadAll(out)
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext\nfor alice abc123def456", string(body))
+}
+
+func TestCommand_TransformFails(t *testing.T) {
+	cmd := NewCommand("/bin/sh", "-c", "echo not a pdf >&2; exit 3")
+
+	out, err := cmd.Transform(context.Background(), strings.NewReader("plaintext"), Mark{})
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = io.ReadAll(out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a pdf")
+}
+
+func TestCommandFromEnv(t *testing.T) {
+	t.Setenv("WATERMARK_COMMAND", "")
+	cmd, err := CommandFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, cmd)
+
+	t.Setenv("WATERMARK_COMMAND", "sh -c cat")
+	cmd, err = CommandFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, cmd)
+	assert.Equal(t, []string{"-c", "cat"}, cmd.args)
+
+	t.Setenv("WATERMARK_COMMAND", "gzln-no-such-watermarker")
+	_, err = CommandFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,485 @@
+// Package client uploads and downloads files through a gzln server the way
+// the web frontend does. Files are encrypted with pkg/e2ee before they leave
+// the machine and decrypted after download, so the server only ever sees
+// ciphertext and the password stays in the share link fragment.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ilkin0/gzln/pkg/e2ee"
+)
+
+// DefaultConcurrency is used when neither the caller nor the server suggests
+// how many chunks to transfer at once.
+const DefaultConcurrency = 4
+
+// APIError is a failed API request. Code is the server's machine-readable
+// reason, when it gave one.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("server returned %d (%s): %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+}
+
+// Client talks to the API of one gzln server.
+type Client struct {
+	baseURL     string
+	http        *http.Client
+	concurrency int
+}
+
+// New returns a Client for the server at baseURL, e.g.
+// https://gzln.example.com.
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    http.DefaultClient,
+	}
+}
+
+// WithHTTPClient replaces http.DefaultClient.
+func (c *Client) WithHTTPClient(h *http.Client) *Client {
+	c.http = h
+	return c
+}
+
+// WithConcurrency sets how many chunks are transferred at once, overriding
+// the server's hint.
+func (c *Client) WithConcurrency(n int) *Client {
+	c.concurrency = n
+	return c
+}
+
+// UploadOptions mirror the optional fields of an upload init. Zero values
+// use the server defaults.
+type UploadOptions struct {
+	ExpiresInHours int
+	// MaxDownloads of -1 allows unlimited downloads.
+	MaxDownloads int32
+}
+
+// Upload is a finished upload. Password decrypts the file and belongs in the
+// share link only; DeletionToken lets the uploader manage the share.
+type Upload struct {
+	ShareID       string
+	Password      string
+	DeletionToken string
+	ExpiresAt     string
+	// Verifying is set while the server still checks the uploaded chunks.
+	// The share can be downloaded once it is done.
+	Verifying bool
+}
+
+// ShareURL is the link recipients open, on the frontend at baseURL.
+func (u *Upload) ShareURL(baseURL string) string {
+	return e2ee.ShareURL(baseURL, u.ShareID, u.Password)
+}
+
+// Upload encrypts size bytes of r and uploads them as a new share.
+func (c *Client) Upload(ctx context.Context, r io.ReaderAt, size int64, name, mimeType string, opts UploadOptions) (*Upload, error) {
+	password, err := e2ee.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := e2ee.GenerateSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := e2ee.DeriveKeyPBKDF2(password, salt, e2ee.DefaultPBKDF2Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedName, err := e2ee.EncryptString(key, name)
+	if err != nil {
+		return nil, err
+	}
+	encryptedMime, err := e2ee.EncryptString(key, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	var advice struct {
+		ChunkSize         int32 `json:"chunk_size"`
+		ChunkCount        int32 `json:"chunk_count"`
+		UploadConcurrency int   `json:"upload_concurrency"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/files/upload/advice?size="+strconv.FormatInt(size, 10), nil, nil, &advice); err != nil {
+		return nil, fmt.Errorf("failed to get upload advice: %w", err)
+	}
+
+	initReq := map[string]any{
+		"salt":                salt,
+		"encrypted_filename":  encryptedName,
+		"encrypted_mime_type": encryptedMime,
+		"total_size":          size,
+		"chunk_count":         advice.ChunkCount,
+		"chunk_size":          advice.ChunkSize,
+		"pbkdf2_iterations":   e2ee.DefaultPBKDF2Iterations,
+	}
+	if opts.ExpiresInHours != 0 {
+		initReq["expires_in_hours"] = opts.ExpiresInHours
+	}
+	if opts.MaxDownloads != 0 {
+		initReq["max_downloads"] = opts.MaxDownloads
+	}
+	var initResp struct {
+		FileID            string `json:"file_id"`
+		ShareID           string `json:"share_id"`
+		UploadToken       string `json:"upload_token"`
+		ExpiresAt         string `json:"expires_at"`
+		UploadConcurrency int    `json:"upload_concurrency"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/files/upload/init", "", initReq, &initResp); err != nil {
+		return nil, fmt.Errorf("failed to init upload: %w", err)
+	}
+
+	concurrency := c.pickConcurrency(initResp.UploadConcurrency)
+	err = parallel(ctx, int(advice.ChunkCount), concurrency, func(ctx context.Context, i int) error {
+		offset := int64(i) * int64(advice.ChunkSize)
+		plaintext := make([]byte, min(int64(advice.ChunkSize), size-offset))
+		if _, err := r.ReadAt(plaintext, offset); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read chunk %d: %w", i, err)
+		}
+		sealed, err := e2ee.EncryptChunk(key, plaintext)
+		if err != nil {
+			return err
+		}
+		if err := c.uploadChunk(ctx, initResp.FileID, initResp.UploadToken, i, sealed); err != nil {
+			return fmt.Errorf("failed to upload chunk %d: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var finalized struct {
+		ShareID       string `json:"share_id"`
+		DeletionToken string `json:"deletion_token"`
+		Verifying     bool   `json:"verifying"`
+	}
+	path := "/api/v1/files/" + initResp.FileID + "/finalize"
+	if err := c.doJSON(ctx, http.MethodPost, path, initResp.UploadToken, nil, &finalized); err != nil {
+		return nil, fmt.Errorf("failed to finalize upload: %w", err)
+	}
+
+	return &Upload{
+		ShareID:       finalized.ShareID,
+		Password:      password,
+		DeletionToken: finalized.DeletionToken,
+		ExpiresAt:     initResp.ExpiresAt,
+		Verifying:     finalized.Verifying,
+	}, nil
+}
+
+func (c *Client) uploadChunk(ctx context.Context, fileID, uploadToken string, index int, sealed []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	// The server reads the text fields before the chunk
+	if err := form.WriteField("chunk_index", strconv.Itoa(index)); err != nil {
+		return err
+	}
+	if err := form.WriteField("hash", e2ee.ChunkHash(sealed)); err != nil {
+		return err
+	}
+	part, err := form.CreateFormFile("chunk", "chunk.enc")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(sealed); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	header := http.Header{
+		"Content-Type":  {form.FormDataContentType()},
+		"Authorization": {"Bearer " + uploadToken},
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/files/"+fileID+"/chunks", header, &body, nil)
+}
+
+// Download is a downloaded file's decrypted metadata.
+type Download struct {
+	Name     string
+	MimeType string
+	Size     int64
+}
+
+// Metadata fetches and decrypts the name and type of a share without
+// downloading it.
+func (c *Client) Metadata(ctx context.Context, shareID, password string) (*Download, error) {
+	meta, key, err := c.metadata(ctx, shareID, password)
+	if err != nil {
+		return nil, err
+	}
+	return decryptMetadata(meta, key)
+}
+
+type shareMetadata struct {
+	EncryptedFilename string `json:"encrypted_filename"`
+	EncryptedMimeType string `json:"encrypted_mime_type"`
+	Salt              string `json:"salt"`
+	TotalSize         int64  `json:"total_size"`
+	DownloadPrefetch  int    `json:"download_prefetch"`
+	DownloadNonce     string `json:"download_nonce"`
+}
+
+func (c *Client) metadata(ctx context.Context, shareID, password string) (shareMetadata, []byte, error) {
+	var meta shareMetadata
+	if err := c.do(ctx, http.MethodGet, "/api/v1/download/"+url.PathEscape(shareID)+"/metadata", nil, nil, &meta); err != nil {
+		return shareMetadata{}, nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	key, err := e2ee.DeriveKeyPBKDF2(password, meta.Salt, e2ee.DefaultPBKDF2Iterations)
+	if err != nil {
+		return shareMetadata{}, nil, err
+	}
+	return meta, key, nil
+}
+
+func decryptMetadata(meta shareMetadata, key []byte) (*Download, error) {
+	name, err := e2ee.DecryptString(key, meta.EncryptedFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file name, is the password right? %w", err)
+	}
+	mimeType, err := e2ee.DecryptString(key, meta.EncryptedMimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file type: %w", err)
+	}
+	return &Download{Name: name, MimeType: mimeType, Size: meta.TotalSize}, nil
+}
+
+// Download fetches a share, verifies and decrypts every chunk and writes the
+// plaintext to w. The download is counted once every chunk is written.
+func (c *Client) Download(ctx context.Context, shareID, password string, w io.WriterAt) (*Download, error) {
+	meta, key, err := c.metadata(ctx, shareID, password)
+	if err != nil {
+		return nil, err
+	}
+	download, err := decryptMetadata(meta, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Chunks []struct {
+			Index int32  `json:"index"`
+			Size  int64  `json:"size"`
+			Hash  string `json:"hash"`
+		} `json:"chunks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/download/"+url.PathEscape(shareID)+"/manifest", nil, nil, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+
+	// Every sealed chunk carries the same overhead, which gives each
+	// chunk's plaintext offset
+	offsets := make([]int64, len(manifest.Chunks))
+	var offset int64
+	for i, chunk := range manifest.Chunks {
+		offsets[i] = offset
+		offset += chunk.Size - e2ee.Overhead
+	}
+
+	// Chunks fetched with the nonce count towards its download, so the
+	// server counts it once the last one is served
+	header := http.Header{"X-Download-Nonce": {meta.DownloadNonce}}
+	err = parallel(ctx, len(manifest.Chunks), c.pickConcurrency(meta.DownloadPrefetch), func(ctx context.Context, i int) error {
+		chunk := manifest.Chunks[i]
+		sealed, err := c.fetch(ctx, "/api/v1/download/"+url.PathEscape(shareID)+"/chunks/"+strconv.Itoa(int(chunk.Index)), header)
+		if err != nil {
+			return fmt.Errorf("failed to download chunk %d: %w", chunk.Index, err)
+		}
+		if e2ee.ChunkHash(sealed) != chunk.Hash {
+			return fmt.Errorf("chunk %d does not match its hash", chunk.Index)
+		}
+		plaintext, err := e2ee.DecryptChunk(key, sealed)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt chunk %d: %w", chunk.Index, err)
+		}
+		if _, err := w.WriteAt(plaintext, offsets[i]); err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", chunk.Index, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.do(ctx, http.MethodPost, "/api/v1/download/"+url.PathEscape(shareID)+"/complete", header, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to complete download: %w", err)
+	}
+	return download, nil
+}
+
+// ParseShareURL splits a share link into the frontend origin, the share ID
+// and the password in its fragment.
+func ParseShareURL(link string) (baseURL, shareID, password string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid share link: %w", err)
+	}
+	shareID = strings.Trim(u.Path, "/")
+	if u.Scheme == "" || u.Host == "" || shareID == "" || strings.Contains(shareID, "/") || u.Fragment == "" {
+		return "", "", "", fmt.Errorf("share links look like https://host/SHARE_ID#PASSWORD")
+	}
+	return u.Scheme + "://" + u.Host, shareID, u.Fragment, nil
+}
+
+func (c *Client) pickConcurrency(serverHint int) int {
+	switch {
+	case c.concurrency > 0:
+		return c.concurrency
+	case serverHint > 0:
+		return serverHint
+	default:
+		return DefaultConcurrency
+	}
+}
+
+// doJSON sends body as JSON, with token as a Bearer token when set.
+func (c *Client) doJSON(ctx context.Context, method, path, token string, body any, out any) error {
+	header := http.Header{}
+	var r io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		header.Set("Content-Type", "application/json")
+		r = bytes.NewReader(encoded)
+	}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return c.do(ctx, method, path, header, r, out)
+}
+
+// do sends a request and decodes the data of its JSON envelope into out.
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body io.Reader, out any) error {
+	resp, err := c.send(ctx, method, path, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// fetch returns the raw body of a GET request.
+func (c *Client) fetch(ctx context.Context, path string, header http.Header) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, path, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// send returns the response of a successful request and turns any other
+// into an APIError.
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &APIError{Status: resp.StatusCode}
+	var failure struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, &failure) == nil && failure.Message != "" {
+		apiErr.Code, apiErr.Message = failure.Code, failure.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	return nil, apiErr
+}
+
+// parallel runs fn for 0..n-1 with at most limit running at once. The first
+// error cancels the rest and is returned.
+func parallel(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+	for range min(limit, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ilkin0/gzln/pkg/e2ee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer keeps one share in memory and checks requests the way the real
+// handlers do.
+type fakeServer struct {
+	t         *testing.T
+	chunkSize int32
+
+	mu        sync.Mutex
+	init      map[string]any
+	chunks    map[int][]byte
+	finalized bool
+	completed bool
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ok := func(data any) {
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "data": data})
+	}
+	authorized := r.Header.Get("Authorization") == "Bearer upload-token"
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/files/upload/advice":
+		size, _ := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+		count := (size + int64(f.chunkSize) - 1) / int64(f.chunkSize)
+		ok(map[string]any{"chunk_size": f.chunkSize, "chunk_count": count, "upload_concurrency": 2})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/files/upload/init":
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&f.init))
+		ok(map[string]any{"file_id": "file-1", "share_id": "share-1", "upload_token": "upload-token", "expires_at": "2026-10-16T00:00:00Z"})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/files/file-1/chunks" && authorized:
+		index, _ := strconv.Atoi(r.FormValue("chunk_index"))
+		file, _, err := r.FormFile("chunk")
+		require.NoError(f.t, err)
+		data, _ := io.ReadAll(file)
+		assert.Equal(f.t, e2ee.ChunkHash(data), r.FormValue("hash"))
+		f.chunks[index] = data
+		ok(map[string]any{"chunk_index": index})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/files/file-1/finalize" && authorized:
+		f.finalized = true
+		ok(map[string]any{"share_id": "share-1", "deletion_token": "delete-me"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/download/share-1/metadata":
+		ok(map[string]any{
+			"encrypted_filename":  f.init["encrypted_filename"],
+			"encrypted_mime_type": f.init["encrypted_mime_type"],
+			"salt":                f.init["salt"],
+			"total_size":          f.init["total_size"],
+			"download_nonce":      "nonce-1",
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/download/share-1/manifest":
+		chunks := make([]map[string]any, len(f.chunks))
+		for i := range chunks {
+			chunks[i] = map[string]any{"index": i, "size": len(f.chunks[i]), "hash": e2ee.ChunkHash(f.chunks[i])}
+		}
+		ok(map[string]any{"chunks": chunks})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/download/share-1/chunks/"):
+		assert.Equal(f.t, "nonce-1", r.Header.Get("X-Download-Nonce"))
+		index, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/download/share-1/chunks/"))
+		w.Write(f.chunks[index])
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/download/share-1/complete":
+		assert.Equal(f.t, "nonce-1", r.Header.Get("X-Download-Nonce"))
+		f.completed = true
+		ok(nil)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "message": "not found", "code": "file_not_found"})
+	}
+}
+
+// buffer is an in-memory io.WriterAt.
+type buffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	return copy(b.data[off:], p), nil
+}
+
+func TestUploadDownload_RoundTrip(t *testing.T) {
+	fake := &fakeServer{t: t, chunkSize: 1000, chunks: make(map[int][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	content := bytes.Repeat([]byte("gzln cli "), 500) // 4500 bytes, a short last chunk
+	c := New(srv.URL)
+
+	up, err := c.Upload(context.Background(), bytes.NewReader(content), int64(len(content)), "notes.txt", "text/plain", UploadOptions{MaxDownloads: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "share-1", up.ShareID)
+	assert.Equal(t, "delete-me", up.DeletionToken)
+	assert.True(t, fake.finalized)
+	assert.Len(t, fake.chunks, 5)
+	assert.EqualValues(t, 3, fake.init["max_downloads"])
+	assert.EqualValues(t, e2ee.DefaultPBKDF2Iterations, fake.init["pbkdf2_iterations"])
+	assert.NotContains(t, fake.init["encrypted_filename"], "notes")
+
+	link := up.ShareURL("https://gzln.example.com")
+	base, shareID, password, err := ParseShareURL(link)
+	require.NoError(t, err)
+	assert.Equal(t, "https://gzln.example.com", base)
+	assert.Equal(t, "share-1", shareID)
+
+	var out buffer
+	down, err := c.Download(context.Background(), shareID, password, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "notes.txt", down.Name)
+	assert.Equal(t, "text/plain", down.MimeType)
+	assert.Equal(t, content, out.data)
+	assert.True(t, fake.completed)
+}
+
+func TestDownload_WrongPassword(t *testing.T) {
+	fake := &fakeServer{t: t, chunkSize: 1000, chunks: make(map[int][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err := c.Upload(context.Background(), bytes.NewReader([]byte("secret")), 6, "a.txt", "text/plain", UploadOptions{})
+	require.NoError(t, err)
+
+	wrong, err := e2ee.GeneratePassword()
+	require.NoError(t, err)
+	_, err = c.Download(context.Background(), "share-1", wrong, &buffer{})
+	assert.ErrorContains(t, err, "is the password right")
+	assert.False(t, fake.completed)
+}
+
+func TestDownload_APIError(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{t: t, chunks: make(map[int][]byte)})
+	defer srv.Close()
+
+	_, err := New(srv.URL).Download(context.Background(), "missing", "pw", &buffer{})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, "file_not_found", apiErr.Code)
+}
+
+func TestParseShareURL_Invalid(t *testing.T) {
+	for _, link := range []string{
+		"share-1#pw",
+		"https://gzln.example.com/share-1",
+		"https://gzln.example.com/#pw",
+		"https://gzln.example.com/a/b#pw",
+	} {
+		_, _, _, err := ParseShareURL(link)
+		assert.Error(t, err, link)
+	}
+}
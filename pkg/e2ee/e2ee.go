@@ -0,0 +1,180 @@
+// Package e2ee implements the client-side encryption scheme used by the gzln
+// web frontend so Go clients can produce and read compatible ciphertext.
+//
+// Keys are derived from a password and a base64 salt with PBKDF2-SHA256
+// (Argon2id is available for clients that opt into it). Chunks and strings are
+// sealed with AES-256-GCM as nonce || ciphertext || tag; strings are then
+// base64 encoded.
+package e2ee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KeySize   = 32
+	SaltSize  = 16
+	NonceSize = 12
+	TagSize   = 16
+	// Overhead is the number of bytes encryption adds to every chunk.
+	Overhead = NonceSize + TagSize
+
+	DefaultPBKDF2Iterations = 100_000
+	passwordSize            = 16
+)
+
+var ErrCiphertextTooShort = errors.New("ciphertext too short")
+
+// Argon2Params configures Argon2id key derivation.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// GenerateSalt returns a random base64 encoded salt as sent in the
+// upload init request.
+func GenerateSalt() (string, error) {
+	return randomBase64(SaltSize)
+}
+
+// GeneratePassword returns a random base64 password, the value carried in
+// the share link fragment.
+func GeneratePassword() (string, error) {
+	return randomBase64(passwordSize)
+}
+
+// DeriveKeyPBKDF2 derives an AES-256 key from password and a base64 salt.
+func DeriveKeyPBKDF2(password, salt string, iterations int) ([]byte, error) {
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if iterations <= 0 {
+		return nil, fmt.Errorf("iterations must be positive")
+	}
+
+	return pbkdf2.Key(sha256.New, password, saltBytes, iterations, KeySize)
+}
+
+// DeriveKeyArgon2id derives an AES-256 key from password and a base64 salt.
+func DeriveKeyArgon2id(password, salt string, params Argon2Params) ([]byte, error) {
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	return argon2.IDKey([]byte(password), saltBytes, params.Time, params.Memory, params.Threads, KeySize), nil
+}
+
+// EncryptChunk seals a plaintext chunk with a random nonce.
+func EncryptChunk(key, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return seal(key, nonce, plaintext)
+}
+
+// DecryptChunk opens a chunk produced by EncryptChunk or the web frontend.
+func DecryptChunk(key, data []byte) ([]byte, error) {
+	if len(data) < Overhead {
+		return nil, ErrCiphertextTooShort
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, data[:NonceSize], data[NonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptString seals s and returns it base64 encoded, the format used for
+// encrypted_filename and encrypted_mime_type.
+func EncryptString(key []byte, s string) (string, error) {
+	sealed, err := EncryptChunk(key, []byte(s))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString reverses EncryptString.
+func DecryptString(key []byte, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+
+	plaintext, err := DecryptChunk(key, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// ChunkHash returns the hex SHA-256 of an encrypted chunk, sent as the
+// hash form field on chunk upload.
+func ChunkHash(encrypted []byte) string {
+	sum := sha256.Sum256(encrypted)
+	return hex.EncodeToString(sum[:])
+}
+
+// ShareURL builds the link handed to recipients. The password travels in the
+// fragment so it is never sent to the server.
+func ShareURL(baseURL, shareID, password string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + shareID + "#" + password
+}
+
+func seal(key, nonce, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, NonceSize, NonceSize+len(plaintext)+TagSize)
+	copy(out, nonce)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBase64(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
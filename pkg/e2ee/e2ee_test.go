@@ -0,0 +1,113 @@
+package e2ee
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectors(t *testing.T) {
+	for _, v := range Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			key, err := DeriveKeyPBKDF2(v.Password, v.Salt, v.Iterations)
+			require.NoError(t, err)
+			assert.Equal(t, v.Key, hex.EncodeToString(key))
+
+			nonce, err := hex.DecodeString(v.Nonce)
+			require.NoError(t, err)
+
+			sealed, err := seal(key, nonce, []byte(v.Plaintext))
+			require.NoError(t, err)
+			assert.Equal(t, v.Ciphertext, base64.StdEncoding.EncodeToString(sealed))
+			assert.Equal(t, v.ChunkHash, ChunkHash(sealed))
+
+			plaintext, err := DecryptString(key, v.Ciphertext)
+			require.NoError(t, err)
+			assert.Equal(t, v.Plaintext, plaintext)
+		})
+	}
+}
+
+func TestEncryptChunk_RoundTrip(t *testing.T) {
+	key := make([]byte, KeySize)
+	data := []byte("test chunk data")
+
+	encrypted, err := EncryptChunk(key, data)
+	require.NoError(t, err)
+	assert.Len(t, encrypted, len(data)+Overhead)
+
+	decrypted, err := DecryptChunk(key, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+}
+
+func TestEncryptChunk_UniqueNonces(t *testing.T) {
+	key := make([]byte, KeySize)
+
+	a, err := EncryptChunk(key, []byte("same"))
+	require.NoError(t, err)
+	b, err := EncryptChunk(key, []byte("same"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a[:NonceSize], b[:NonceSize])
+}
+
+func TestDecryptChunk_Tampered(t *testing.T) {
+	key := make([]byte, KeySize)
+
+	encrypted, err := EncryptChunk(key, []byte("test chunk data"))
+	require.NoError(t, err)
+	encrypted[len(encrypted)-1] ^= 0xff
+
+	_, err = DecryptChunk(key, encrypted)
+	assert.Error(t, err)
+}
+
+func TestDecryptChunk_TooShort(t *testing.T) {
+	_, err := DecryptChunk(make([]byte, KeySize), make([]byte, Overhead-1))
+	assert.ErrorIs(t, err, ErrCiphertextTooShort)
+}
+
+func TestEncryptString_RoundTrip(t *testing.T) {
+	password, err := GeneratePassword()
+	require.NoError(t, err)
+	salt, err := GenerateSalt()
+	require.NoError(t, err)
+
+	key, err := DeriveKeyPBKDF2(password, salt, 1000)
+	require.NoError(t, err)
+
+	encrypted, err := EncryptString(key, "report.pdf")
+	require.NoError(t, err)
+
+	decrypted, err := DecryptString(key, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "report.pdf", decrypted)
+}
+
+func TestDeriveKeyArgon2id(t *testing.T) {
+	params := Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1}
+
+	a, err := DeriveKeyArgon2id("password", "c2FsdA==", params)
+	require.NoError(t, err)
+	b, err := DeriveKeyArgon2id("password", "c2FsdA==", params)
+	require.NoError(t, err)
+
+	assert.Len(t, a, KeySize)
+	assert.Equal(t, a, b)
+}
+
+func TestDeriveKey_InvalidSalt(t *testing.T) {
+	_, err := DeriveKeyPBKDF2("password", "not base64!", 1)
+	assert.Error(t, err)
+
+	_, err = DeriveKeyArgon2id("password", "not base64!", DefaultArgon2Params)
+	assert.Error(t, err)
+}
+
+func TestShareURL(t *testing.T) {
+	assert.Equal(t, "https://gzln.app/abc123#pw", ShareURL("https://gzln.app/", "abc123", "pw"))
+}
@@ -0,0 +1,66 @@
+package e2ee
+
+// Vector is a known-answer test case for the scheme. Ciphertext is the
+// base64 encoding of nonce || ciphertext || tag for Plaintext sealed with
+// Key and Nonce; ChunkHash is the hash of those sealed bytes.
+type Vector struct {
+	Name       string `json:"name"`
+	Password   string `json:"password"`
+	Salt       string `json:"salt"`
+	Iterations int    `json:"pbkdf2_iterations"`
+	Key        string `json:"key_hex"`
+	Nonce      string `json:"nonce_hex"`
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext_base64"`
+	ChunkHash  string `json:"chunk_hash"`
+}
+
+// Vectors lists the test vectors every compatible client must reproduce.
+// The first two keys are the published PBKDF2-HMAC-SHA256 vectors for
+// password "password" and salt "salt".
+var Vectors = []Vector{
+	{
+		Name:       "pbkdf2-1-iteration-empty-plaintext",
+		Password:   "password",
+		Salt:       "c2FsdA==",
+		Iterations: 1,
+		Key:        "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		Nonce:      "000000000000000000000000",
+		Plaintext:  "",
+		Ciphertext: "AAAAAAAAAAAAAAAANuRvVWcU2XZJqf3V7bO8KQ==",
+		ChunkHash:  "c460b754cef89a45d0e8e16e945c58c2d688dc1c99e99428210961d1c9259e71",
+	},
+	{
+		Name:       "pbkdf2-4096-iterations-filename",
+		Password:   "password",
+		Salt:       "c2FsdA==",
+		Iterations: 4096,
+		Key:        "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a",
+		Nonce:      "000102030405060708090a0b",
+		Plaintext:  "hello.txt",
+		Ciphertext: "AAECAwQFBgcICQoLwab39WR2ylw0SxBE3JAhgcAwVVE67Bsq7g==",
+		ChunkHash:  "18a05d8c28894295092699a2dfd9bf4233fa278a0064ea6f0e4db8cfbdf2bfd8",
+	},
+	{
+		Name:       "web-defaults-mime-type",
+		Password:   "q2pMGFtR1JDSFgKZzcvFjA==",
+		Salt:       "AAECAwQFBgcICQoLDA0ODw==",
+		Iterations: DefaultPBKDF2Iterations,
+		Key:        "19418b52f3222f8c82f9242d1953dec43380374cc854e31dcfcbf1660229be4b",
+		Nonce:      "0c0d0e0f1011121314151617",
+		Plaintext:  "text/plain",
+		Ciphertext: "DA0ODxAREhMUFRYXwXb0jLv/vAZHXu19ZyWSNBPC0SC+Tgx6e/s=",
+		ChunkHash:  "d6d3c8b25f2e2acb4170d9b383e687df11abbc3a010e039438caf1c60a79faf9",
+	},
+	{
+		Name:       "web-defaults-utf8-payload",
+		Password:   "q2pMGFtR1JDSFgKZzcvFjA==",
+		Salt:       "AAECAwQFBgcICQoLDA0ODw==",
+		Iterations: DefaultPBKDF2Iterations,
+		Key:        "19418b52f3222f8c82f9242d1953dec43380374cc854e31dcfcbf1660229be4b",
+		Nonce:      "ffeeddccbbaa998877665544",
+		Plaintext:  "gzln chunk payload é☃",
+		Ciphertext: "/+7dzLuqmYh3ZlVETXxR2gS8LVUliZ4v2GqA8sC79vwQNE0w8XbfabTkwBjDDgpjalOYrQ==",
+		ChunkHash:  "fa077ca62a15f9f8da09a2e5b431dd26a81b4322634288dfd9ec54f16beaf654",
+	},
+}
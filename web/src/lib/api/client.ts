@@ -20,8 +20,10 @@ class ApiClient {
       const payload: ApiResponse<T> = await res.json();
       if (!payload?.success) {
         throw {
-          message: (payload as unknown as { message?: string })?.message ?? `Request failed`,
+          message: payload?.message ?? `Request failed`,
           status: res.status,
+          code: payload?.code,
+          details: payload?.details,
         } as ApiError;
       }
 
@@ -49,19 +51,21 @@ class ApiClient {
    * @param endpoint - API endpoint
    * @returns Promise<Response>
    */
-  async getRaw(endpoint: string): Promise<Response> {
+  async getRaw(endpoint: string, extraHeaders?: Record<string, string>): Promise<Response> {
     const url = `${this.baseUrl}${endpoint}`;
 
     try {
       const response = await fetch(url, {
         method: "GET",
-        headers: this.mergeHeaders(),
+        headers: this.mergeHeaders({headers: extraHeaders}),
       });
 
       if (!response.ok) {
+        const payload: ApiResponse | null = await response.json().catch(() => null);
         throw {
-          message: `Request failed: ${response.statusText}`,
+          message: payload?.message ?? `Request failed: ${response.statusText}`,
           status: response.status,
+          code: payload?.code,
         } as ApiError;
       }
 
@@ -77,10 +81,11 @@ class ApiClient {
     }
   }
 
-  post<T>(endpoint: string, data?: unknown): Promise<T> {
+  post<T>(endpoint: string, data?: unknown, extraHeaders?: Record<string, string>): Promise<T> {
     return this.request<T>(endpoint, {
       method: "POST",
       body: data === undefined ? undefined : JSON.stringify(data),
+      headers: extraHeaders,
     });
   }
 
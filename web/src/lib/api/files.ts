@@ -1,17 +1,49 @@
 import {apiClient} from "./client";
 import type {
+  ApiError,
+  ChunkLayoutDetails,
   ChunkUploadResponse,
   FileMetadata,
   FinalizeUploadResponse,
   InitUploadRequest,
-  InitUploadResponse
+  InitUploadResponse,
+  UploadAdvice
 } from "$lib/types/api";
 
 export const filesApi = {
+  async getUploadAdvice(size: number): Promise<UploadAdvice> {
+    return apiClient.get<UploadAdvice>(`/api/v1/files/upload/advice?size=${size}`);
+  },
+
   async initUpload(data: InitUploadRequest): Promise<InitUploadResponse> {
     return apiClient.post<InitUploadResponse>("/api/v1/files/upload/init", data);
   },
 
+  /**
+   * initUpload that retries once with the chunk layout the server suggests
+   * when it rejects the requested one. Resolves with the request that was
+   * accepted so callers chunk the file accordingly.
+   */
+  async initUploadWithLayoutCorrection(
+      data: InitUploadRequest
+  ): Promise<{ request: InitUploadRequest; response: InitUploadResponse }> {
+    try {
+      return {request: data, response: await this.initUpload(data)};
+    } catch (err) {
+      const apiErr = err as ApiError;
+      if (apiErr.code !== "invalid_chunk_layout" || !apiErr.details) {
+        throw err;
+      }
+      const layout = apiErr.details as ChunkLayoutDetails;
+      const corrected: InitUploadRequest = {
+        ...data,
+        chunk_size: layout.chunk_size,
+        chunk_count: layout.expected_chunk_count,
+      };
+      return {request: corrected, response: await this.initUpload(corrected)};
+    }
+  },
+
   async getFileMetadata(shareId: string): Promise<FileMetadata> {
     return apiClient.get<FileMetadata>(`/api/v1/download/${shareId}/metadata`);
   },
@@ -35,14 +67,25 @@ export const filesApi = {
     );
   },
 
-  async finalizeUpload(fileId: string): Promise<FinalizeUploadResponse> {
-    return apiClient.post<FinalizeUploadResponse>(`/api/v1/files/${fileId}/finalize`);
+  async finalizeUpload(fileId: string, uploadToken: string): Promise<FinalizeUploadResponse> {
+    return apiClient.post<FinalizeUploadResponse>(
+        `/api/v1/files/${fileId}/finalize`,
+        undefined,
+        {Authorization: `Bearer ${uploadToken}`}
+    );
   },
 
-  async downloadChunk(shareId: string, chunkIndex: number): Promise<Response> {
-    return apiClient.getRaw(`/api/v1/download/${shareId}/chunks/${chunkIndex}`);
+  async downloadChunk(shareId: string, chunkIndex: number, downloadNonce?: string): Promise<Response> {
+    return apiClient.getRaw(
+        `/api/v1/download/${shareId}/chunks/${chunkIndex}`,
+        downloadNonce ? {"X-Download-Nonce": downloadNonce} : undefined
+    );
   },
-    async completeDownload(shareId: string): Promise<void> {
-    await apiClient.post(`/api/v1/download/${shareId}/complete`);
+  async completeDownload(shareId: string, downloadNonce?: string): Promise<void> {
+    await apiClient.post(
+        `/api/v1/download/${shareId}/complete`,
+        undefined,
+        downloadNonce ? {"X-Download-Nonce": downloadNonce} : undefined
+    );
   },
 };
@@ -13,16 +13,19 @@ export interface ChunkDownloadOptions {
     totalChunks: number;
     decryptionKey: CryptoKey;
     onProgress?: (progress: DownloadProgress) => void;
+    prefetch?: number;
+    // Chunks fetched with the nonce count towards its download
+    downloadNonce?: string;
 }
 
 export async function downloadFileInChunks(
     options: ChunkDownloadOptions
 ): Promise<Uint8Array[]> {
-    const {shareId, totalChunks, decryptionKey, onProgress} = options;
-    const chunks: Uint8Array[] = [];
+    const {shareId, totalChunks, decryptionKey, onProgress, downloadNonce} = options;
+    const prefetch = Math.max(1, options.prefetch ?? 1);
 
-    for (let chunkIndex = 0; chunkIndex < totalChunks; chunkIndex++) {
-        const response = await filesApi.downloadChunk(shareId, chunkIndex);
+    const fetchChunk = async (chunkIndex: number): Promise<Uint8Array> => {
+        const response = await filesApi.downloadChunk(shareId, chunkIndex, downloadNonce);
         const blob = await responseToBlob(response, (streamProgress) => {
             if (onProgress) {
                 onProgress({
@@ -35,7 +38,23 @@ export async function downloadFileInChunks(
 
         const decryptedChunk = await decryptChunk(blob, decryptionKey);
         const blobBuffer = await decryptedChunk.arrayBuffer();
-        chunks.push(new Uint8Array(blobBuffer));
+        return new Uint8Array(blobBuffer);
+    };
+
+    // Keep up to `prefetch` chunk requests in flight while preserving order
+    const pending: Promise<Uint8Array>[] = [];
+    for (let chunkIndex = 0; chunkIndex < Math.min(prefetch, totalChunks); chunkIndex++) {
+        pending.push(fetchChunk(chunkIndex));
+    }
+
+    const chunks: Uint8Array[] = [];
+    for (let chunkIndex = 0; chunkIndex < totalChunks; chunkIndex++) {
+        chunks.push(await pending[chunkIndex]);
+
+        const next = chunkIndex + prefetch;
+        if (next < totalChunks) {
+            pending.push(fetchChunk(next));
+        }
     }
 
     return chunks;
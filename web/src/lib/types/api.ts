@@ -6,6 +6,7 @@ export interface InitUploadRequest {
   chunk_count: number;
   chunk_size: number;
   expires_in_hours?: number;
+  // 0 or omitted uses the server default, -1 allows unlimited downloads
   max_downloads?: number;
   pbkdf2_iterations: number;
 }
@@ -15,6 +16,32 @@ export interface InitUploadResponse {
   share_id: string;
   upload_token: string;
   expires_at: string;
+  upload_concurrency?: number;
+}
+
+export interface UploadAdvice {
+  total_size: number;
+  chunk_size: number;
+  chunk_count: number;
+  max_chunk_size: number;
+  upload_concurrency: number;
+  busy: boolean;
+}
+
+/** Chunk layout the server accepts, sent with `invalid_chunk_layout` errors. */
+export interface ChunkLayoutDetails {
+  total_size: number;
+  chunk_size: number;
+  expected_chunk_count: number;
+  expected_last_chunk_size: number;
+  max_chunk_size: number;
+}
+
+export interface SharePolicy {
+  expires_by: ('time' | 'download_limit')[];
+  expiry_reason?: 'time' | 'download_limit' | 'removed';
+  at_limit: 'delete_content';
+  retention_seconds: number;
 }
 
 export interface FileMetadata {
@@ -26,6 +53,12 @@ export interface FileMetadata {
   expires_at: string;
   max_downloads: number;
   download_count: number;
+  created_at: string;
+  policy: SharePolicy;
+  download_prefetch?: number;
+  download_nonce?: string;
+  // Where the uploader wants the recipient sent once the download finished.
+  return_url?: string;
 }
 
 export interface ChunkUploadResponse {
@@ -37,6 +70,10 @@ export interface ChunkUploadResponse {
 export interface FinalizeUploadResponse {
   share_id: string;
   deletion_token: string;
+  already_finalized?: boolean;
+  // Set while the server still verifies the chunks; the share is not
+  // downloadable until it is ready.
+  verifying?: boolean;
 }
 
 export interface ChunkMetadata {
@@ -47,10 +84,14 @@ export interface ChunkMetadata {
 export interface ApiError {
   message: string;
   status: number;
+  code?: string;
+  details?: unknown;
 }
 
 export interface ApiResponse<T = any> {
   success: boolean;
   message?: string;
+  code?: string;
+  details?: unknown;
   data?: T;
 }